@@ -0,0 +1,201 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ImageAnalyzer is an optional stage detecting the content of images, such
+// as faces and objects, e.g. with a machine learning model. If set in
+// Options.ImageAnalyzer, it is run on image files after the backends, and
+// its results are set in Metadata.Analysis.
+type ImageAnalyzer interface {
+	// Name returns the name of the analyzer, used in errors and in the
+	// stages of the audit log.
+	Name() string
+
+	// Analyze detects the content of the image. It should return ctx.Err()
+	// when ctx is done.
+	Analyze(ctx context.Context, filePath string) (*ImageAnalysis, error)
+}
+
+// ImageAnalysis is the content of an image detected by an ImageAnalyzer.
+type ImageAnalysis struct {
+	// Labels are the objects or scenes detected in the image, in
+	// decreasing order of confidence.
+	Labels []ImageLabel `json:"labels,omitempty"`
+
+	// Faces is the number of faces detected in the image.
+	Faces int `json:"faces"`
+}
+
+// ImageLabel is an object or scene detected in an image.
+type ImageLabel struct {
+	// Name is the name of the label (e.g., "dog", "beach").
+	Name string `json:"name"`
+
+	// Confidence is the highest confidence (0-1) of the detections.
+	Confidence float64 `json:"confidence"`
+
+	// Count is the number of detections of the label.
+	Count int `json:"count"`
+}
+
+// DefaultAnalyzerTimeout is the default CommandAnalyzer.Timeout.
+const DefaultAnalyzerTimeout = time.Minute
+
+// CommandAnalyzer is an ImageAnalyzer running an object detection model
+// (e.g., YOLO or a face detector) through an external runner, such as a
+// Python script using ONNX Runtime, so that the extractor does not depend
+// on a machine learning runtime.
+//
+// The runner is invoked as Command followed by the model and image paths.
+// It must print a JSON object with the detections to standard output:
+//
+//	{"detections": [{"label": "dog", "score": 0.92}, {"label": "face", "score": 0.88}]}
+//
+// Detections are counted per label; those of FaceLabel are counted in
+// ImageAnalysis.Faces instead.
+type CommandAnalyzer struct {
+	// Command is the runner and its leading arguments (e.g.,
+	// []string{"python3", "detect.py"}).
+	Command []string
+
+	// Model is the path of the model, passed to the runner (e.g., an ONNX
+	// model file).
+	Model string
+
+	// MinScore is the minimum score (0-1) of the detections kept.
+	MinScore float64
+
+	// FaceLabel is the label of face detections. Defaults to "face".
+	FaceLabel string
+
+	// Timeout is the maximum time the runner may take on an image.
+	// Defaults to DefaultAnalyzerTimeout.
+	Timeout time.Duration
+}
+
+// commandAnalyzerOutput is the output of a CommandAnalyzer runner.
+type commandAnalyzerOutput struct {
+	Detections []struct {
+		Label string  `json:"label"`
+		Score float64 `json:"score"`
+	} `json:"detections"`
+}
+
+// Name returns "command".
+func (a CommandAnalyzer) Name() string {
+	return "command"
+}
+
+// Analyze runs the model on the image.
+func (a CommandAnalyzer) Analyze(ctx context.Context, filePath string) (*ImageAnalysis, error) {
+	if len(a.Command) == 0 {
+		return nil, errors.New("no runner command")
+	}
+
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultAnalyzerTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := append(append([]string(nil), a.Command[1:]...), a.Model, filePath)
+	cmd := exec.CommandContext(ctx, a.Command[0], args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	var output commandAnalyzerOutput
+	if err := json.Unmarshal(out, &output); err != nil {
+		return nil, fmt.Errorf("error parsing runner output: %w", err)
+	}
+
+	faceLabel := a.FaceLabel
+	if faceLabel == "" {
+		faceLabel = "face"
+	}
+
+	analysis := &ImageAnalysis{}
+	labels := make(map[string]*ImageLabel)
+
+	for _, d := range output.Detections {
+		if d.Score < a.MinScore || d.Label == "" {
+			continue
+		}
+
+		if d.Label == faceLabel {
+			analysis.Faces++
+			continue
+		}
+
+		label, ok := labels[d.Label]
+		if !ok {
+			label = &ImageLabel{Name: d.Label}
+			labels[d.Label] = label
+		}
+		label.Count++
+		label.Confidence = max(label.Confidence, d.Score)
+	}
+
+	for _, label := range labels {
+		analysis.Labels = append(analysis.Labels, *label)
+	}
+	sort.Slice(analysis.Labels, func(i, j int) bool {
+		li, lj := analysis.Labels[i], analysis.Labels[j]
+		if li.Confidence != lj.Confidence {
+			return li.Confidence > lj.Confidence
+		}
+		return li.Name < lj.Name
+	})
+
+	return analysis, nil
+}
+
+// analyzeStage runs the image analyzer on images and sets
+// Metadata.Analysis.
+func (me *MetaExtractor) analyzeStage(ctx context.Context, toolPath string, metadata *Metadata, trace *stageTrace) error {
+	if me.imageAnalyzer == nil || toolPath == "" || mimeTypeKind(metadata.MimeType) != KindImage {
+		return nil
+	}
+
+	start := time.Now()
+	analysis, err := me.imageAnalyzer.Analyze(ctx, toolPath)
+	trace.done("analyze:"+me.imageAnalyzer.Name(), start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error running image analyzer %q: %w", me.imageAnalyzer.Name(), err))
+	}
+
+	metadata.Analysis = analysis
+	if analysis != nil {
+		metadata.setSource("Analysis", me.imageAnalyzer.Name())
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAnalyzer is an ImageAnalyzer recording the files it analyzed.
+type fakeAnalyzer struct {
+	analysis *ImageAnalysis
+	err      error
+	files    *[]string
+}
+
+func (a fakeAnalyzer) Name() string {
+	return "fake"
+}
+
+func (a fakeAnalyzer) Analyze(ctx context.Context, filePath string) (*ImageAnalysis, error) {
+	*a.files = append(*a.files, filePath)
+	return a.analysis, a.err
+}
+
+func TestMetaExtractor_ImageAnalyzer(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))))
+	img := filepath.Join(dir, "photo.png")
+	require.NoError(t, os.WriteFile(img, buf.Bytes(), 0o644))
+
+	text := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(text, []byte("notes\n"), 0o644))
+
+	analysis := &ImageAnalysis{Labels: []ImageLabel{{Name: "dog", Confidence: 0.9, Count: 1}}, Faces: 2}
+
+	t.Run("Images", func(t *testing.T) {
+		var files []string
+		me := NewMetaExtractor(Options{PureGo: true, Provenance: true, ImageAnalyzer: fakeAnalyzer{analysis: analysis, files: &files}})
+
+		metadata, err := me.Extract(img)
+		require.NoError(t, err)
+		assert.Equal(t, analysis, metadata.Analysis)
+		assert.Equal(t, "fake", metadata.Provenance["Analysis"])
+
+		metadata, err = me.Extract(text)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Analysis)
+
+		assert.Equal(t, []string{img}, files)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var files []string
+		analyzer := fakeAnalyzer{err: errors.New("model not found"), files: &files}
+
+		_, err := NewMetaExtractor(Options{PureGo: true, ImageAnalyzer: analyzer}).Extract(img)
+		assert.ErrorContains(t, err, `error running image analyzer "fake": model not found`)
+
+		metadata, err := NewMetaExtractor(Options{PureGo: true, BestEffort: true, ImageAnalyzer: analyzer}).Extract(img)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Analysis)
+		assert.Len(t, metadata.Warnings, 1)
+	})
+}
+
+func TestCommandAnalyzer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	runner := filepath.Join(dir, "detect.sh")
+	require.NoError(t, os.WriteFile(runner, []byte(`#!/bin/sh
+[ "$1" = "model.onnx" ] || { echo "unexpected model $1" >&2; exit 1; }
+[ -f "$2" ] || { echo "missing image $2" >&2; exit 1; }
+cat <<'EOF'
+{"detections": [
+	{"label": "dog", "score": 0.7},
+	{"label": "face", "score": 0.9},
+	{"label": "dog", "score": 0.8},
+	{"label": "ball", "score": 0.8},
+	{"label": "face", "score": 0.95},
+	{"label": "cat", "score": 0.2}
+]}
+EOF
+`), 0o755))
+
+	img := filepath.Join(dir, "photo.jpg")
+	require.NoError(t, os.WriteFile(img, []byte("jpeg"), 0o644))
+
+	analyzer := CommandAnalyzer{Command: []string{"sh", runner}, Model: "model.onnx", MinScore: 0.5}
+	analysis, err := analyzer.Analyze(context.Background(), img)
+	require.NoError(t, err)
+	assert.Equal(t, &ImageAnalysis{
+		Labels: []ImageLabel{
+			{Name: "ball", Confidence: 0.8, Count: 1},
+			{Name: "dog", Confidence: 0.8, Count: 2},
+		},
+		Faces: 2,
+	}, analysis)
+
+	analyzer.Model = "other.onnx"
+	_, err = analyzer.Analyze(context.Background(), img)
+	assert.ErrorContains(t, err, "unexpected model other.onnx")
+
+	_, err = CommandAnalyzer{Model: "model.onnx"}.Analyze(context.Background(), img)
+	assert.Error(t, err)
+}
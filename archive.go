@@ -0,0 +1,499 @@
+package metaextractor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ArchiveSeparator separates the path of an archive from the path of an entry
+// inside it (e.g., "photos.zip!/2024/beach.jpg").
+const ArchiveSeparator = "!/"
+
+// maxArchiveDepth limits how deeply nested archives are descended into.
+const maxArchiveDepth = 8
+
+const (
+	// DefaultMaxEntrySize is the default of Options.MaxEntrySize.
+	DefaultMaxEntrySize = 4 << 30
+
+	// DefaultMaxEntryRatio is the default of Options.MaxEntryRatio.
+	DefaultMaxEntryRatio = 100
+
+	// entryRatioGrace is the size up to which ZIP entries are not subject
+	// to Options.MaxEntryRatio, as small entries of repetitive content
+	// compress well.
+	entryRatioGrace = 1 << 20
+)
+
+// errEntryTooLarge is reported for archive entries exceeding
+// Options.MaxEntrySize or Options.MaxEntryRatio.
+var errEntryTooLarge = errors.New("archive entry exceeds the size limit")
+
+type archiveKind int
+
+const (
+	archiveNone archiveKind = iota
+	archiveZip
+	archiveTar
+	archiveTarGz
+	archiveEmail
+	archiveMsg
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	zipEmpty  = []byte("PK\x05\x06")
+	gzipMagic = []byte{0x1f, 0x8b}
+	tarMagic  = []byte("ustar")
+)
+
+// entryFilter decides whether an archive entry is extracted, given its
+// virtual path (e.g., "photos.zip!/2024/beach.jpg").
+type entryFilter func(entryPath string, info fs.FileInfo) bool
+
+// detectArchive determines the archive format of the file from its content.
+func detectArchive(filePath string) (archiveKind, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return archiveNone, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return archiveNone, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic), bytes.HasPrefix(head, zipEmpty):
+		return archiveZip, nil
+	case isTarHeader(head):
+		return archiveTar, nil
+	case bytes.HasPrefix(head, cfbMagic):
+		return archiveMsg, nil
+	case isEmailHeader(head):
+		return archiveEmail, nil
+	case bytes.HasPrefix(head, gzipMagic):
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return archiveNone, err
+		}
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return archiveNone, nil
+		}
+		defer gz.Close()
+
+		n, _ := io.ReadFull(gz, head[:cap(head)])
+		if isTarHeader(head[:n]) {
+			return archiveTarGz, nil
+		}
+	}
+
+	return archiveNone, nil
+}
+
+// isTarHeader reports whether the block starts with a POSIX tar header.
+func isTarHeader(block []byte) bool {
+	return len(block) >= 262 && bytes.Equal(block[257:262], tarMagic)
+}
+
+// ExtractTar extracts metadata from every regular entry of a TAR stream,
+// which may be gzip-compressed. Entries are processed sequentially and only
+// one entry is buffered on disk at a time, so archives larger than the
+// available disk space can be scanned. Results are passed to fn as soon as
+// they are available; their paths are the entry names. Nested archives are
+// descended into as in directory walks.
+func (me *MetaExtractor) ExtractTar(r io.Reader, fn func(Result)) error {
+	br := bufio.NewReader(r)
+
+	r = br
+	if head, _ := br.Peek(len(gzipMagic)); bytes.Equal(head, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return me.walkTar(context.Background(), r, "", 0, nil, fn)
+}
+
+// walkArchive extracts metadata from every entry of the archive at filePath
+// and passes the results to emit. virtualPath is the path reported for the
+// archive itself; entries are reported as virtualPath + ArchiveSeparator + name.
+// Nested archives are descended into recursively. Files that are not
+// supported archives are ignored.
+func (me *MetaExtractor) walkArchive(ctx context.Context, filePath, virtualPath string, depth int, accept entryFilter, emit func(Result)) error {
+	if depth >= maxArchiveDepth {
+		return nil
+	}
+
+	kind, err := detectArchive(filePath)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case archiveZip:
+		return me.walkZip(ctx, filePath, virtualPath, depth, accept, emit)
+	case archiveTar, archiveTarGz:
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		var r io.Reader = f
+		if kind == archiveTarGz {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return err
+			}
+			defer gz.Close()
+			r = gz
+		}
+
+		return me.walkTar(ctx, r, virtualPath, depth, accept, emit)
+	case archiveEmail:
+		return me.walkEmail(ctx, filePath, virtualPath, depth, accept, emit)
+	case archiveMsg:
+		data, password, err := me.decryptOffice(filePath)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			return me.walkOfficePackage(ctx, data, password, virtualPath, depth, accept, emit)
+		}
+
+		return me.walkMsg(ctx, filePath, virtualPath, depth, accept, emit)
+	}
+
+	return nil
+}
+
+// walkZip processes the entries of a ZIP archive.
+func (me *MetaExtractor) walkZip(ctx context.Context, filePath, virtualPath string, depth int, accept entryFilter, emit func(Result)) error {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	return me.walkZipReader(ctx, &zr.Reader, virtualPath, depth, accept, emit)
+}
+
+// walkZipReader processes the entries of an opened ZIP archive. It stops when
+// ctx is done.
+func (me *MetaExtractor) walkZipReader(ctx context.Context, zr *zip.Reader, virtualPath string, depth int, accept entryFilter, emit func(Result)) error {
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		info := f.FileInfo()
+		entryPath := joinEntryPath(virtualPath, f.Name)
+
+		rc, password, err := me.openZipEntry(f)
+		if err != nil {
+			emit(Result{Path: entryPath, Parent: virtualPath, Depth: depth + 1, Err: err})
+			continue
+		}
+
+		entryEmit := emit
+		if password != "" {
+			entryEmit = func(r Result) {
+				if r.Path == entryPath {
+					r.Metadata.Password = password
+				}
+				emit(r)
+			}
+		}
+
+		me.extractEntry(ctx, rc, f.Name, info, entryPath, virtualPath, depth, accept, entryEmit)
+		rc.Close()
+	}
+
+	return nil
+}
+
+// walkOfficePackage processes the entries of the decrypted ZIP package of a
+// password-protected OOXML document, recording the password on the entries.
+func (me *MetaExtractor) walkOfficePackage(ctx context.Context, data []byte, password, virtualPath string, depth int, accept entryFilter, emit func(Result)) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	return me.walkZipReader(ctx, zr, virtualPath, depth, accept, func(r Result) {
+		if r.Parent == virtualPath {
+			r.Metadata.Password = password
+		}
+		emit(r)
+	})
+}
+
+// walkTar processes the entries of a TAR stream sequentially. Only one entry
+// is buffered on disk at a time. It stops when ctx is done.
+func (me *MetaExtractor) walkTar(ctx context.Context, r io.Reader, virtualPath string, depth int, accept entryFilter, emit func(Result)) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		info := hdr.FileInfo()
+		entryPath := joinEntryPath(virtualPath, hdr.Name)
+
+		me.extractEntry(ctx, tr, hdr.Name, info, entryPath, virtualPath, depth, accept, emit)
+	}
+}
+
+// extractEntry spools an archive entry to a temporary file, extracts its
+// metadata if it is accepted by the filter, and descends into it if it is an
+// archive itself. Entries that are neither accepted nor archives are skipped
+// without being spooled. The results of the entry are linked to the
+// container at parentPath, which is nested depth levels deep.
+func (me *MetaExtractor) extractEntry(ctx context.Context, r io.Reader, name string, info fs.FileInfo, entryPath, parentPath string, depth int, accept entryFilter, emit func(Result)) {
+	accepted := accept == nil || accept(entryPath, info)
+
+	br := bufio.NewReaderSize(r, 1024)
+	if !accepted {
+		head, _ := br.Peek(512)
+		if !bytes.HasPrefix(head, zipMagic) && !bytes.HasPrefix(head, gzipMagic) && !isTarHeader(head) && !bytes.HasPrefix(head, cfbMagic) && !isEmailHeader(head) {
+			return
+		}
+	}
+	r = br
+
+	// The declared size is checked first, and the actual size while the
+	// entry is spooled, as archives may declare false sizes.
+	limit := me.entryLimit(info)
+	if limit >= 0 && info.Size() > limit {
+		emit(me.skippedEntry(name, info, entryPath, parentPath, depth, limit))
+		return
+	}
+	if limit >= 0 {
+		r = &entryLimitReader{r: r, n: limit}
+	}
+
+	tmpFile, cleanup, err := spoolEntry(r, name)
+	if errors.Is(err, errEntryTooLarge) {
+		emit(me.skippedEntry(name, info, entryPath, parentPath, depth, limit))
+		return
+	}
+	if err != nil {
+		emit(Result{Path: entryPath, Parent: parentPath, Depth: depth + 1, Err: err})
+		return
+	}
+	defer cleanup()
+
+	if accepted {
+		metadata, err := me.extractFile(withFileName(ctx, entryFileName(name)), tmpFile, entryPath, nil)
+		metadata.Time = me.timeOpts.fileTime(mergeFileTime(metadata.Time, entryFileTime(info)))
+		emit(Result{Path: entryPath, Parent: parentPath, Depth: depth + 1, Metadata: metadata, Err: err})
+	}
+
+	if err := me.walkArchive(ctx, tmpFile, entryPath, depth+1, accept, emit); err != nil {
+		emit(Result{Path: entryPath, Parent: parentPath, Depth: depth + 1, Err: fmt.Errorf("error reading archive: %w", err)})
+	}
+}
+
+// entryLimit returns the maximum size of an archive entry written to disk,
+// or -1 if it is not limited. The compression ratio is limited for ZIP
+// entries, whose compressed size is known.
+func (me *MetaExtractor) entryLimit(info fs.FileInfo) int64 {
+	limit := int64(-1)
+	if me.maxEntrySize > 0 {
+		limit = me.maxEntrySize
+	}
+
+	if hdr, ok := info.Sys().(*zip.FileHeader); ok && me.maxEntryRatio > 0 && hdr.Method != zip.Store {
+		ratioLimit := max(int64(float64(hdr.CompressedSize64)*me.maxEntryRatio), entryRatioGrace)
+		if limit < 0 || ratioLimit < limit {
+			limit = ratioLimit
+		}
+	}
+
+	return limit
+}
+
+// skippedEntry returns the result of an archive entry skipped because it
+// exceeds the limit of entryLimit.
+func (me *MetaExtractor) skippedEntry(name string, info fs.FileInfo, entryPath, parentPath string, depth int, limit int64) Result {
+	metadata := Metadata{
+		Name:     entryFileName(name),
+		Size:     info.Size(),
+		Time:     me.timeOpts.fileTime(entryFileTime(info)),
+		Warnings: []string{fmt.Sprintf("skipped: %v (%d bytes)", errEntryTooLarge, limit)},
+	}
+
+	return Result{Path: entryPath, Parent: parentPath, Depth: depth + 1, Metadata: metadata}
+}
+
+// entryFileTime returns the times of an archive entry recorded by the
+// archive: the modification time and, for TAR entries in PAX or GNU
+// format, the access and change times.
+func entryFileTime(info fs.FileInfo) FileTime {
+	ft := FileTime{ModTime: info.ModTime()}
+	if hdr, ok := info.Sys().(*tar.Header); ok {
+		ft.AccessTime = hdr.AccessTime
+		ft.ChangeTime = hdr.ChangeTime
+	}
+
+	return ft
+}
+
+// mergeFileTime returns ft with the times recorded in other, which take
+// precedence.
+func mergeFileTime(ft, other FileTime) FileTime {
+	if !other.ModTime.IsZero() {
+		ft.ModTime = other.ModTime
+	}
+	if !other.AccessTime.IsZero() {
+		ft.AccessTime = other.AccessTime
+	}
+	if !other.ChangeTime.IsZero() {
+		ft.ChangeTime = other.ChangeTime
+	}
+	if !other.BirthTime.IsZero() {
+		ft.BirthTime = other.BirthTime
+	}
+
+	return ft
+}
+
+// entryOption returns the value of an entry limit option: def if it is
+// zero, and 0 (no limit) if it is negative.
+func entryOption[T int64 | float64](value, def T) T {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 0
+	}
+
+	return value
+}
+
+// entryLimitReader reads from r and fails with errEntryTooLarge once more
+// than n bytes have been read.
+type entryLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *entryLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errEntryTooLarge
+	}
+
+	return n, err
+}
+
+// spoolEntry writes the contents of r into a temporary file with a
+// generated name, keeping the extension of the given name if it is safe to
+// use in a file name. The returned function removes the file.
+func spoolEntry(r io.Reader, name string) (string, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "metaextractor-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.CreateTemp(tmpDir, "entry-*"+spoolExt(name))
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", nil, err
+	}
+
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", nil, err
+	}
+
+	return f.Name(), func() { os.RemoveAll(tmpDir) }, nil
+}
+
+// maxSpoolExt is the maximum length of the extension kept by spoolEntry.
+const maxSpoolExt = 16
+
+// spoolExt returns the extension of the entry name if it consists of ASCII
+// letters and digits only, or an empty string.
+func spoolExt(name string) string {
+	ext := filepath.Ext(entryFileName(name))
+	if len(ext) < 2 || len(ext) > maxSpoolExt {
+		return ""
+	}
+
+	for _, c := range ext[1:] {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return ""
+		}
+	}
+
+	return ext
+}
+
+// fileNameKey is the context key of the name a spooled file is reported
+// with.
+type fileNameKey struct{}
+
+// withFileName returns a copy of ctx under which the file extracted is
+// reported with the given name rather than the name of its spooled copy.
+func withFileName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, fileNameKey{}, name)
+}
+
+// fileNameFromContext returns the name the file at filePath is reported
+// with under ctx.
+func fileNameFromContext(ctx context.Context, filePath string) string {
+	if name, ok := ctx.Value(fileNameKey{}).(string); ok {
+		return name
+	}
+
+	return filepath.Base(filePath)
+}
+
+// joinEntryPath returns the virtual path of an archive entry. Entries of a
+// stream without a path are reported by name only.
+func joinEntryPath(virtualPath, name string) string {
+	if virtualPath == "" {
+		return name
+	}
+
+	return virtualPath + ArchiveSeparator + name
+}
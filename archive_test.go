@@ -0,0 +1,275 @@
+package metaextractor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipBytes returns a ZIP archive containing the given files.
+func zipBytes(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+// tarGzBytes returns a gzip-compressed TAR archive containing the given files.
+func tarGzBytes(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(content)),
+			ModTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		}))
+		_, err := tw.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	return buf.Bytes()
+}
+
+func TestDetectArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		name     string
+		content  []byte
+		expected archiveKind
+	}{
+		{"Zip", zipBytes(t, map[string][]byte{"a.txt": []byte("a")}), archiveZip},
+		{"TarGz", tarGzBytes(t, map[string][]byte{"a.txt": []byte("a")}), archiveTarGz},
+		{"Text", []byte("hello"), archiveNone},
+		{"Empty", nil, archiveNone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := filepath.Join(dir, tc.name)
+			require.NoError(t, os.WriteFile(p, tc.content, 0o644))
+
+			kind, err := detectArchive(p)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, kind)
+		})
+	}
+}
+
+func TestExtractDir_Archives(t *testing.T) {
+	inner := tarGzBytes(t, map[string][]byte{"docs/2.txt": []byte("two")})
+	outer := zipBytes(t, map[string][]byte{
+		"x/1.jpg":      []byte("one"),
+		"inner.tar.gz": inner,
+	})
+
+	root := createTree(t, map[string]string{
+		"plain.txt": "plain",
+		"a.zip":     string(outer),
+	})
+
+	extractor := NewMetaExtractor(Options{})
+
+	results, err := extractor.ExtractDir(root, WalkOptions{Archives: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"a.zip",
+		"a.zip!/inner.tar.gz",
+		"a.zip!/inner.tar.gz!/docs/2.txt",
+		"a.zip!/x/1.jpg",
+		"plain.txt",
+	}, resultPaths(t, root, results))
+
+	for _, r := range results {
+		if filepath.Base(r.Path) == "2.txt" {
+			assert.Equal(t, "2.txt", r.Metadata.Name)
+			assert.Equal(t, int64(3), r.Metadata.Size)
+			assert.True(t, r.Metadata.Time.ModTime.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+		}
+	}
+
+	results, err = extractor.ExtractDir(root, WalkOptions{Archives: true, Extensions: []string{".txt"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.zip!/inner.tar.gz!/docs/2.txt", "plain.txt"}, resultPaths(t, root, results))
+}
+
+func TestWalkArchive_Canceled(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"a.zip": string(zipBytes(t, map[string][]byte{"a.txt": []byte("a")})),
+	})
+	filePath := filepath.Join(root, "a.zip")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var results []Result
+	err := NewMetaExtractor(Options{PureGo: true}).walkArchive(ctx, filePath, filePath, 0, nil, func(r Result) {
+		results = append(results, r)
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, results)
+}
+
+func TestExtractTar(t *testing.T) {
+	stream := tarGzBytes(t, map[string][]byte{
+		"a.txt":      []byte("a"),
+		"sub/b.zip":  zipBytes(t, map[string][]byte{"c.txt": []byte("c")}),
+		"sub/d.json": []byte("{}"),
+	})
+
+	extractor := NewMetaExtractor(Options{})
+
+	var paths []string
+	err := extractor.ExtractTar(bytes.NewReader(stream), func(r Result) {
+		paths = append(paths, r.Path)
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a.txt", "sub/b.zip", "sub/b.zip!/c.txt", "sub/d.json"}, paths)
+
+	err = extractor.ExtractTar(bytes.NewReader([]byte("not a tar stream")), func(Result) {})
+	assert.Error(t, err)
+}
+
+func TestExtractEntry_Limits(t *testing.T) {
+	zeros := make([]byte, 2<<20)
+	root := createTree(t, map[string]string{
+		"a.zip": string(zipBytes(t, map[string][]byte{"zeros.bin": zeros, "a.txt": []byte("a")})),
+	})
+
+	byName := func(results []Result) map[string]Result {
+		m := make(map[string]Result)
+		for _, r := range results {
+			m[filepath.Base(r.Path)] = r
+		}
+		return m
+	}
+
+	t.Run("Ratio", func(t *testing.T) {
+		results, err := NewMetaExtractor(Options{PureGo: true}).ExtractDir(root, WalkOptions{Archives: true})
+		require.NoError(t, err)
+
+		entries := byName(results)
+		require.NoError(t, entries["zeros.bin"].Err)
+		assert.Equal(t, []string{"skipped: archive entry exceeds the size limit (1048576 bytes)"}, entries["zeros.bin"].Metadata.Warnings)
+		assert.Equal(t, int64(2<<20), entries["zeros.bin"].Metadata.Size)
+		assert.Empty(t, entries["a.txt"].Metadata.Warnings)
+		assert.Equal(t, int64(1), entries["a.txt"].Metadata.Size)
+
+		results, err = NewMetaExtractor(Options{PureGo: true, MaxEntryRatio: -1}).ExtractDir(root, WalkOptions{Archives: true})
+		require.NoError(t, err)
+		entries = byName(results)
+		assert.Empty(t, entries["zeros.bin"].Metadata.Warnings)
+		assert.Equal(t, int64(2<<20), entries["zeros.bin"].Metadata.Size)
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		stream := tarGzBytes(t, map[string][]byte{"a.txt": []byte("a"), "b.txt": []byte("hello")})
+
+		var results []Result
+		err := NewMetaExtractor(Options{PureGo: true, MaxEntrySize: 2}).ExtractTar(bytes.NewReader(stream), func(r Result) {
+			results = append(results, r)
+		})
+		require.NoError(t, err)
+
+		entries := byName(results)
+		assert.Empty(t, entries["a.txt"].Metadata.Warnings)
+		assert.Equal(t, []string{"skipped: archive entry exceeds the size limit (2 bytes)"}, entries["b.txt"].Metadata.Warnings)
+		assert.Equal(t, int64(5), entries["b.txt"].Metadata.Size)
+		assert.True(t, entries["b.txt"].Metadata.Time.ModTime.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+	})
+
+	t.Run("False Size", func(t *testing.T) {
+		_, err := io.Copy(io.Discard, &entryLimitReader{r: strings.NewReader("hello"), n: 4})
+		assert.ErrorIs(t, err, errEntryTooLarge)
+
+		_, err = io.Copy(io.Discard, &entryLimitReader{r: strings.NewReader("hello"), n: 5})
+		assert.NoError(t, err)
+	})
+}
+
+func TestExtractEntry_Times(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	accessTime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name:       "a.txt",
+		Mode:       0o644,
+		Size:       1,
+		ModTime:    modTime,
+		AccessTime: accessTime,
+		Format:     tar.FormatPAX,
+	}))
+	_, err := tw.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	var results []Result
+	err = NewMetaExtractor(Options{PureGo: true}).ExtractTar(&buf, func(r Result) {
+		results = append(results, r)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	ft := results[0].Metadata.Time
+	assert.True(t, ft.ModTime.Equal(modTime))
+	assert.True(t, ft.AccessTime.Equal(accessTime))
+	assert.False(t, ft.ChangeTime.IsZero(), "the times not recorded by the archive are kept")
+}
+
+func TestExtractEntry_Names(t *testing.T) {
+	stream := tarGzBytes(t, map[string][]byte{
+		"x/photo.txt": []byte("x"),
+		"y/photo.txt": []byte("y"),
+		"a\nb.txt":    []byte("a"),
+		"..":          []byte("dots"),
+	})
+
+	results := make(map[string]Result)
+	err := NewMetaExtractor(Options{PureGo: true}).ExtractTar(bytes.NewReader(stream), func(r Result) {
+		results[r.Path] = r
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	for p, r := range results {
+		require.NoError(t, r.Err, p)
+		assert.Equal(t, entryFileName(p), r.Metadata.Name, p)
+	}
+	assert.Equal(t, int64(1), results["y/photo.txt"].Metadata.Size)
+	assert.Equal(t, int64(4), results[".."].Metadata.Size)
+
+	assert.Equal(t, ".jpg", spoolExt("dir/photo.jpg"))
+	assert.Equal(t, ".gz", spoolExt("archive.tar.gz"))
+	assert.Empty(t, spoolExt(".."))
+	assert.Empty(t, spoolExt("photo.j\npg"))
+	assert.Empty(t, spoolExt("photo"))
+}
@@ -0,0 +1,179 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Audit outcomes reported in AuditRecord.Outcome.
+const (
+	AuditSuccess = "success"
+	AuditWarning = "warning"
+	AuditError   = "error"
+)
+
+// AuditOptions configures the audit log, which records every extraction as
+// one JSON line, for chain-of-custody processes.
+type AuditOptions struct {
+	// Writer receives the audit records. The audit log is disabled if it is
+	// nil. Use OpenAuditLog to append to a file.
+	Writer io.Writer
+
+	// Context is operator-supplied context included in every record (e.g.,
+	// the case number or the operator name).
+	Context map[string]string
+}
+
+// AuditRecord is an entry of the audit log.
+type AuditRecord struct {
+	// Time is the time the extraction started.
+	Time time.Time `json:"time"`
+
+	// Path is the path of the file. Archive entries are reported by their
+	// virtual path (see ArchiveSeparator).
+	Path string `json:"path"`
+
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+
+	// Hashes are the digests of the file content (see Options.Hashes).
+	Hashes map[string]string `json:"hashes,omitempty"`
+
+	// Stages are the pipeline stages that ran, in order.
+	Stages []AuditStage `json:"stages"`
+
+	// Duration is the total duration of the extraction.
+	Duration time.Duration `json:"duration"`
+
+	// Outcome is AuditSuccess, AuditWarning (the metadata has warnings or
+	// is unstable) or AuditError.
+	Outcome string `json:"outcome"`
+
+	// Error is the error returned by the extraction, if any.
+	Error string `json:"error,omitempty"`
+
+	// Context is the operator-supplied context (AuditOptions.Context).
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// AuditStage records a pipeline stage that ran during an extraction.
+type AuditStage struct {
+	// Name is the name of the stage (e.g., "scan", "detect", "exif"), or
+	// the name of a stage selected by Options.Routes.
+	Name string `json:"name"`
+
+	// Duration is the time the stage took.
+	Duration time.Duration `json:"duration"`
+
+	// Failed reports whether the stage failed, either failing the
+	// extraction or adding a warning in best-effort mode.
+	Failed bool `json:"failed,omitempty"`
+}
+
+// OpenAuditLog opens the file at path for appending audit records, creating
+// it if it does not exist.
+func OpenAuditLog(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+}
+
+// auditLog writes audit records. Writes are serialized, so that records of
+// concurrent extractions are not interleaved.
+type auditLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	context map[string]string
+}
+
+// newAuditLog returns the audit log of the options, or nil if it is disabled.
+func newAuditLog(opts AuditOptions) *auditLog {
+	if opts.Writer == nil {
+		return nil
+	}
+
+	context := make(map[string]string, len(opts.Context))
+	for k, v := range opts.Context {
+		context[k] = v
+	}
+
+	return &auditLog{w: opts.Writer, context: context}
+}
+
+// write appends the record of an extraction to the log.
+func (l *auditLog) write(path string, start time.Time, trace *stageTrace, metadata Metadata, extractErr error) error {
+	record := AuditRecord{
+		Time:     start,
+		Path:     path,
+		Size:     metadata.Size,
+		Hashes:   metadata.Hashes,
+		Stages:   trace.stages,
+		Duration: time.Since(start),
+		Outcome:  AuditSuccess,
+		Context:  l.context,
+	}
+
+	if extractErr != nil {
+		record.Outcome = AuditError
+		record.Error = extractErr.Error()
+	} else if len(metadata.Warnings) > 0 || metadata.Unstable {
+		record.Outcome = AuditWarning
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+
+	return nil
+}
+
+// stageTrace records the stages of an extraction. A nil trace records
+// nothing.
+type stageTrace struct {
+	stages []AuditStage
+}
+
+// done records that the named stage, started at start, has completed.
+func (t *stageTrace) done(name string, start time.Time) {
+	if t == nil {
+		return
+	}
+
+	t.stages = append(t.stages, AuditStage{Name: name, Duration: time.Since(start)})
+}
+
+// fail marks the stage recorded last as failed.
+func (t *stageTrace) fail() {
+	if t == nil || len(t.stages) == 0 {
+		return
+	}
+
+	t.stages[len(t.stages)-1].Failed = true
+}
+
+// failed returns the names of the failed stages, each once.
+func (t *stageTrace) failed() []string {
+	if t == nil {
+		return nil
+	}
+
+	var names []string
+	for _, s := range t.stages {
+		if s.Failed && !slices.Contains(names, s.Name) {
+			names = append(names, s.Name)
+		}
+	}
+
+	return names
+}
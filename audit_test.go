@@ -0,0 +1,107 @@
+package metaextractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// readAudit decodes the records of an audit log.
+func readAudit(t *testing.T, data []byte) []AuditRecord {
+	t.Helper()
+
+	var records []AuditRecord
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var record AuditRecord
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &record))
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestMetaExtractor_Audit(t *testing.T) {
+	var buf bytes.Buffer
+
+	me := NewMetaExtractor(Options{
+		PureGo: true,
+		Hashes: []string{"sha256"},
+		Routes: []Route{{
+			Extensions: []string{".pdf"},
+			Stages: []Stage{StageFunc{StageName: "text", Fn: func(context.Context, string, *Metadata) (interface{}, error) {
+				return "", nil
+			}}},
+		}},
+		Audit: AuditOptions{
+			Writer:  &buf,
+			Context: map[string]string{"case": "2024-17", "operator": "jdoe"},
+		},
+	})
+
+	_, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+
+	_, err = me.Extract("nonexistent_file")
+	require.ErrorIs(t, err, ErrFileNotFound)
+
+	records := readAudit(t, buf.Bytes())
+	require.Len(t, records, 2)
+
+	ok := records[0]
+	assert.Equal(t, filepath.Join("testdata", "sample.doc"), ok.Path)
+	assert.Equal(t, AuditSuccess, ok.Outcome)
+	assert.Len(t, ok.Hashes["sha256"], 64)
+	assert.Equal(t, map[string]string{"case": "2024-17", "operator": "jdoe"}, ok.Context)
+	assert.False(t, ok.Time.IsZero())
+
+	var stages []string
+	for _, s := range ok.Stages {
+		stages = append(stages, s.Name)
+	}
+	assert.Equal(t, []string{"scan", "detect", "text"}, stages)
+
+	failed := records[1]
+	assert.Equal(t, AuditError, failed.Outcome)
+	assert.Equal(t, ErrFileNotFound.Error(), failed.Error)
+}
+
+func TestMetaExtractor_AuditWriteError(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true, Audit: AuditOptions{Writer: failingWriter{}}})
+
+	metadata, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+	assert.ErrorContains(t, err, "error writing audit log: disk full")
+	assert.Equal(t, "sample.doc", metadata.Name)
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	for i := 0; i < 2; i++ {
+		f, err := OpenAuditLog(path)
+		require.NoError(t, err)
+
+		me := NewMetaExtractor(Options{PureGo: true, Audit: AuditOptions{Writer: f}})
+		_, err = me.Extract(filepath.Join("testdata", "sample.mp3"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, readAudit(t, data), 2)
+}
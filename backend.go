@@ -0,0 +1,363 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extractor is an extraction backend: a stage run on the content of every
+// non-empty file, such as ffprobe for media files or a parser for a
+// proprietary format.
+// Backends are run in the order configured by Options.Backends, and each
+// backend adds its results to the metadata, e.g., to Metadata.Extra or
+// Metadata.Exif.
+type Extractor interface {
+	// Name returns the name of the backend, used in errors and in the
+	// stages of the audit log.
+	Name() string
+
+	// Extract extracts metadata from the file into metadata. It should
+	// return ctx.Err() when ctx is done.
+	Extract(ctx context.Context, filePath string, metadata *Metadata) error
+}
+
+// DependentBackend is an Extractor that must run after other backends, e.g. a
+// PII scanner after the backend extracting the text of documents, or a
+// malware lookup after the type detection. Options.Backends are reordered so
+// that every backend runs after its dependencies; the configured order is
+// kept otherwise.
+type DependentBackend interface {
+	Extractor
+
+	// DependsOn returns the names of the backends that must run first
+	// (e.g., "trid", "exiftool" or the name of a custom backend).
+	DependsOn() []string
+}
+
+// BackendFunc adapts an ordinary function to the Extractor and
+// DependentBackend interfaces.
+type BackendFunc struct {
+	// BackendName is the name returned by Name.
+	BackendName string
+
+	// Dependencies are the names returned by DependsOn.
+	Dependencies []string
+
+	// Fn is the function invoked by Extract.
+	Fn func(ctx context.Context, filePath string, metadata *Metadata) error
+}
+
+// Name returns the name of the backend.
+func (bf BackendFunc) Name() string {
+	return bf.BackendName
+}
+
+// DependsOn returns bf.Dependencies.
+func (bf BackendFunc) DependsOn() []string {
+	return bf.Dependencies
+}
+
+// Extract calls bf.Fn(ctx, filePath, metadata).
+func (bf BackendFunc) Extract(ctx context.Context, filePath string, metadata *Metadata) error {
+	return bf.Fn(ctx, filePath, metadata)
+}
+
+// Built-in backends, in the order of the default configuration.
+var (
+	// TridBackend identifies the type of files using the detector chain
+	// configured by Options.Detectors (TrID by default) and sets
+	// Metadata.Types.
+	TridBackend Extractor = tridBackend{}
+
+	// ExifToolBackend extracts the EXIF metadata of files using ExifTool
+	// and sets Metadata.Exif. It is skipped by pure-Go extractors and with
+	// Options.SkipExif.
+	ExifToolBackend Extractor = exifToolBackend{}
+
+	// MagicBackend identifies files using libmagic through the file command
+	// and sets Metadata.Magic, and Metadata.Types if no earlier stage
+	// detected a type. It is not part of DefaultBackends; it replaces
+	// TridBackend on hosts without TrID, such as containers. It is skipped
+	// by pure-Go extractors.
+	MagicBackend Extractor = magicBackend{}
+
+	// NativeExifBackend extracts the EXIF metadata of JPEG, TIFF and PNG
+	// images with a built-in parser and sets Metadata.Exif. It is not part
+	// of DefaultBackends; it replaces ExifToolBackend where no external
+	// tool can be installed, and also runs in pure-Go mode. It only reports
+	// the tags of the IFD0, EXIF and GPS directories, without maker notes,
+	// XMP or IPTC, and most values without ExifTool's print conversions.
+	// It is skipped with Options.SkipExif.
+	NativeExifBackend Extractor = nativeExifBackend{}
+
+	// FFprobeBackend reads the container and stream properties of audio and
+	// video files (e.g., codecs, bit rates, frame rates and channel
+	// layouts) with ffprobe and sets Metadata.Media. It is not part of
+	// DefaultBackends; listed after TridBackend, it only runs on files
+	// detected as audio or video. It is skipped by pure-Go extractors.
+	FFprobeBackend Extractor = ffprobeBackend{}
+
+	// MediaInfoBackend reads the container and stream properties of audio
+	// and video files with MediaInfo and sets Metadata.Media, mapping its
+	// fields to those reported by FFprobeBackend. It is not part of
+	// DefaultBackends; listed after FFprobeBackend, it only fills the
+	// properties ffprobe did not report. It is skipped by pure-Go
+	// extractors.
+	MediaInfoBackend Extractor = mediaInfoBackend{}
+)
+
+// DefaultBackends are the backends run if Options.Backends is empty.
+var DefaultBackends = []Extractor{TridBackend, ExifToolBackend}
+
+// errBackendNotConfigured is returned by the built-in backends when they are
+// called directly rather than through an extractor.
+var errBackendNotConfigured = errors.New("backend is not configured")
+
+// tridBackend is the type detection stage bound to an extractor.
+type tridBackend struct {
+	me *MetaExtractor
+}
+
+func (b tridBackend) Name() string {
+	return "trid"
+}
+
+func (b tridBackend) Extract(ctx context.Context, filePath string, metadata *Metadata) error {
+	if b.me == nil {
+		return fmt.Errorf("%s: %w", b.Name(), errBackendNotConfigured)
+	}
+
+	detected, err := b.me.detectTypes(ctx, filePath, nil)
+	metadata.Types = withMimeTypes(detected.types)
+	metadata.Detector = detected.detector
+	metadata.Warnings = append(metadata.Warnings, detected.warnings...)
+
+	return err
+}
+
+// exifToolBackend is the ExifTool stage bound to an extractor.
+type exifToolBackend struct {
+	me *MetaExtractor
+}
+
+func (b exifToolBackend) Name() string {
+	return "exiftool"
+}
+
+func (b exifToolBackend) Extract(ctx context.Context, filePath string, metadata *Metadata) error {
+	if b.me == nil {
+		return fmt.Errorf("%s: %w", b.Name(), errBackendNotConfigured)
+	}
+
+	if metadata.Exif == nil {
+		metadata.Exif = ExifMetadata{}
+	}
+	if b.me.pureGo || b.me.skipExif {
+		return nil
+	}
+
+	exifData, err := b.me.extractExifData(ctx, filePath)
+	if errors.Is(err, ErrNoMetadataExtracted) {
+		return nil
+	}
+	maps.Copy(metadata.Exif, exifData)
+	if err == nil {
+		metadata.addExifBackend(b.Name())
+	}
+
+	return err
+}
+
+// bindBackends returns the backends with the built-in ones bound to me.
+func bindBackends(backends []Extractor, me *MetaExtractor) []Extractor {
+	bound := make([]Extractor, len(backends))
+	for i, b := range backends {
+		switch b.(type) {
+		case tridBackend:
+			bound[i] = tridBackend{me: me}
+		case exifToolBackend:
+			bound[i] = exifToolBackend{me: me}
+		case magicBackend:
+			bound[i] = magicBackend{me: me}
+		case nativeExifBackend:
+			bound[i] = nativeExifBackend{me: me}
+		case ffprobeBackend:
+			bound[i] = ffprobeBackend{me: me}
+		case mediaInfoBackend:
+			bound[i] = mediaInfoBackend{me: me}
+		default:
+			bound[i] = b
+		}
+	}
+
+	return bound
+}
+
+// orderBackends returns the backends ordered so that every backend runs
+// after its dependencies (see DependentBackend). Of the backends whose
+// dependencies have run, the first configured one runs next. Dependencies
+// on backends that are not configured and cyclic dependencies are errors.
+func orderBackends(backends []Extractor) ([]Extractor, error) {
+	// pending counts the backends of each name that have not run yet.
+	pending := make(map[string]int)
+	for _, b := range backends {
+		pending[b.Name()]++
+	}
+
+	for _, b := range backends {
+		for _, dep := range backendDependencies(b) {
+			if pending[dep] == 0 {
+				return nil, fmt.Errorf("backend %q depends on %q, which is not configured", b.Name(), dep)
+			}
+		}
+	}
+
+	ordered := make([]Extractor, 0, len(backends))
+	remaining := slices.Clone(backends)
+
+	for len(remaining) > 0 {
+		i := slices.IndexFunc(remaining, func(b Extractor) bool {
+			for _, dep := range backendDependencies(b) {
+				if pending[dep] > 0 {
+					return false
+				}
+			}
+			return true
+		})
+
+		if i < 0 {
+			names := make([]string, len(remaining))
+			for i, b := range remaining {
+				names[i] = strconv.Quote(b.Name())
+			}
+			return nil, fmt.Errorf("backends %s have cyclic dependencies", strings.Join(names, ", "))
+		}
+
+		b := remaining[i]
+		ordered = append(ordered, b)
+		remaining = slices.Delete(remaining, i, i+1)
+		pending[b.Name()]--
+	}
+
+	return ordered, nil
+}
+
+// backendDependencies returns the dependencies of the backend, without the
+// backend itself.
+func backendDependencies(b Extractor) []string {
+	d, ok := b.(DependentBackend)
+	if !ok {
+		return nil
+	}
+
+	return slices.DeleteFunc(slices.Clone(d.DependsOn()), func(dep string) bool {
+		return dep == b.Name()
+	})
+}
+
+// BackendOrder returns the names of the backends in the order they are run
+// on every non-empty file, after ordering them by their dependencies.
+func (me *MetaExtractor) BackendOrder() []string {
+	names := make([]string, len(me.backends))
+	for i, b := range me.backends {
+		names[i] = b.Name()
+	}
+
+	return names
+}
+
+// hasTridBackend reports whether the backends contain the TridBackend.
+func hasTridBackend(backends []Extractor) bool {
+	for _, b := range backends {
+		if _, ok := b.(tridBackend); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasMagicBackend reports whether the backends contain the MagicBackend.
+func hasMagicBackend(backends []Extractor) bool {
+	for _, b := range backends {
+		if _, ok := b.(magicBackend); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasNativeExifBackend reports whether the backends contain the
+// NativeExifBackend.
+func hasNativeExifBackend(backends []Extractor) bool {
+	for _, b := range backends {
+		if _, ok := b.(nativeExifBackend); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasFFprobeBackend reports whether the backends contain the FFprobeBackend.
+func hasFFprobeBackend(backends []Extractor) bool {
+	for _, b := range backends {
+		if _, ok := b.(ffprobeBackend); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasMediaInfoBackend reports whether the backends contain the
+// MediaInfoBackend.
+func hasMediaInfoBackend(backends []Extractor) bool {
+	for _, b := range backends {
+		if _, ok := b.(mediaInfoBackend); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasCustomBackend reports whether any of the backends is not built in.
+func hasCustomBackend(backends []Extractor) bool {
+	for _, b := range backends {
+		switch b.(type) {
+		case tridBackend, exifToolBackend, magicBackend, nativeExifBackend, ffprobeBackend, mediaInfoBackend:
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+// runBackend runs a custom backend on the file.
+func (me *MetaExtractor) runBackend(ctx context.Context, b Extractor, filePath string, metadata *Metadata, trace *stageTrace) error {
+	before := snapshotKeys(metadata)
+
+	start := time.Now()
+	err := b.Extract(ctx, filePath, metadata)
+	trace.done("backend:"+b.Name(), start)
+	tagAddedKeys(metadata, before, b.Name())
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("backend %q: %w", b.Name(), err))
+	}
+
+	return nil
+}
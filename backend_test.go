@@ -0,0 +1,218 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordBackend returns a backend recording its name in Metadata.Extra and
+// the detected types it saw.
+func recordBackend(name string, order *[]string) Extractor {
+	return BackendFunc{
+		BackendName: name,
+		Fn: func(ctx context.Context, filePath string, metadata *Metadata) error {
+			*order = append(*order, name)
+			if metadata.Extra == nil {
+				metadata.Extra = make(map[string]interface{})
+			}
+			metadata.Extra[name] = len(metadata.Types)
+			return nil
+		},
+	}
+}
+
+func TestBackends(t *testing.T) {
+	samplePath := filepath.Join("testdata", "sample.doc")
+
+	t.Run("Order", func(t *testing.T) {
+		var order []string
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{
+			recordBackend("before", &order), TridBackend, ExifToolBackend, recordBackend("after", &order),
+		}})
+
+		metadata, err := me.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"before", "after"}, order)
+		assert.Equal(t, 0, metadata.Extra["before"])
+		assert.Equal(t, len(metadata.Types), metadata.Extra["after"])
+		assert.NotEmpty(t, metadata.Types)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+		require.Len(t, me.backends, 2)
+		assert.False(t, hasCustomBackend(me.backends))
+		assert.True(t, hasTridBackend(me.backends))
+	})
+
+	t.Run("Without Detection", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{ExifToolBackend}})
+
+		metadata, err := me.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Empty(t, metadata.Types)
+		assert.NotNil(t, metadata.Exif)
+
+		metadata, err = me.ExtractStreamInput(bytes.NewReader([]byte("%PDF-1.7\n")))
+		require.NoError(t, err)
+		assert.Empty(t, metadata.Types)
+	})
+
+	errFailed := errors.New("failed")
+	failing := BackendFunc{
+		BackendName: "failing",
+		Fn: func(ctx context.Context, filePath string, metadata *Metadata) error {
+			return errFailed
+		},
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{failing}}).Extract(samplePath)
+		assert.ErrorIs(t, err, errFailed)
+		assert.Contains(t, err.Error(), `backend "failing"`)
+
+		metadata, err := NewMetaExtractor(Options{PureGo: true, BestEffort: true, Backends: []Extractor{failing}}).Extract(samplePath)
+		require.NoError(t, err)
+		assert.Len(t, metadata.Warnings, 1)
+	})
+
+	t.Run("Empty File", func(t *testing.T) {
+		var order []string
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{recordBackend("custom", &order)}})
+
+		p := filepath.Join(t.TempDir(), "empty")
+		require.NoError(t, os.WriteFile(p, nil, 0o644))
+
+		_, err := me.Extract(p)
+		require.NoError(t, err)
+		assert.Empty(t, order)
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		var paths []string
+		custom := BackendFunc{
+			BackendName: "custom",
+			Fn: func(ctx context.Context, filePath string, metadata *Metadata) error {
+				paths = append(paths, filePath)
+				return nil
+			},
+		}
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{TridBackend, custom}})
+		assert.True(t, me.streamNeedsPath())
+
+		_, err := me.ExtractStreamInput(bytes.NewReader([]byte("content")))
+		require.NoError(t, err)
+		require.Len(t, paths, 1)
+		assert.NotEqual(t, StreamPath, paths[0])
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+		profiled, err := me.WithProfile("photos")
+		require.NoError(t, err)
+		assert.Same(t, profiled, profiled.backends[0].(tridBackend).me)
+	})
+}
+
+func TestBuiltinBackends(t *testing.T) {
+	var metadata Metadata
+	assert.ErrorIs(t, TridBackend.Extract(context.Background(), "file", &metadata), errBackendNotConfigured)
+	assert.ErrorIs(t, ExifToolBackend.Extract(context.Background(), "file", &metadata), errBackendNotConfigured)
+
+	me := NewMetaExtractor(Options{PureGo: true})
+	for _, b := range me.backends {
+		require.NoError(t, b.Extract(context.Background(), filepath.Join("testdata", "sample.doc"), &metadata))
+	}
+	assert.NotEmpty(t, metadata.Types)
+	assert.Equal(t, "signature", metadata.Detector)
+	assert.NotNil(t, metadata.Exif)
+	assert.Equal(t, []string{"trid", "exiftool"}, []string{TridBackend.Name(), ExifToolBackend.Name()})
+}
+
+func TestDisableBackends(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	sample := filepath.Join("testdata", "sample.doc")
+
+	me := NewMetaExtractor(Options{
+		TridPath:     missing,
+		ExifToolPath: missing,
+		DisableTrid:  true,
+		DisableExif:  true,
+		Hashes:       []string{"sha256"},
+	})
+	defer me.Close()
+
+	require.NoError(t, me.Check())
+
+	metadata, err := me.Extract(sample)
+	require.NoError(t, err)
+	assert.NotEmpty(t, metadata.Types)
+	assert.Equal(t, "signature", metadata.Detector)
+	assert.Empty(t, metadata.Exif)
+	assert.NotEmpty(t, metadata.Hashes["sha256"])
+
+	// Profiles cannot enable ExifTool again.
+	profiled, err := me.WithProfile("photos")
+	require.NoError(t, err)
+	_, err = profiled.Extract(sample)
+	require.NoError(t, err)
+
+	if !pureGoBuild {
+		_, err = NewMetaExtractor(Options{TridPath: missing, ExifToolPath: missing}).Extract(sample)
+		assert.Error(t, err)
+	}
+}
+
+func TestBackendDependencies(t *testing.T) {
+	samplePath := filepath.Join("testdata", "sample.doc")
+
+	dependent := func(name string, order *[]string, deps ...string) Extractor {
+		b := recordBackend(name, order).(BackendFunc)
+		b.Dependencies = deps
+		return b
+	}
+
+	t.Run("Order", func(t *testing.T) {
+		var order []string
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{
+			dependent("pii", &order, "text"),
+			dependent("lookup", &order, "trid", "lookup"),
+			recordBackend("text", &order),
+			TridBackend,
+			ExifToolBackend,
+		}})
+		assert.Equal(t, []string{"text", "pii", "trid", "lookup", "exiftool"}, me.BackendOrder())
+
+		metadata, err := me.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"text", "pii", "lookup"}, order)
+		assert.Equal(t, len(metadata.Types), metadata.Extra["lookup"])
+		assert.NotEmpty(t, metadata.Types)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		var order []string
+
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{dependent("pii", &order, "text"), TridBackend}})
+		assert.EqualError(t, me.Check(), `backend "pii" depends on "text", which is not configured`)
+
+		me = NewMetaExtractor(Options{PureGo: true, DisableExif: true, Backends: []Extractor{
+			dependent("thumbnails", &order, "exiftool"), ExifToolBackend,
+		}})
+		assert.EqualError(t, me.Check(), `backend "thumbnails" depends on "exiftool", which is not configured`)
+
+		me = NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{
+			TridBackend, dependent("a", &order, "b"), dependent("b", &order, "a"),
+		}})
+		_, err := me.Extract(samplePath)
+		assert.EqualError(t, err, `backends "a", "b" have cyclic dependencies`)
+		assert.Empty(t, order)
+	})
+}
@@ -0,0 +1,466 @@
+package metaextractor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotBag is returned by ExtractBag for directories without a bagit.txt
+// declaration.
+var ErrNotBag = errors.New("not a BagIt bag")
+
+// BagProblem kinds, as reported in BagProblem.Kind.
+const (
+	// BagMissing is reported for files listed in a manifest but not present
+	// in the bag (and not listed in fetch.txt).
+	BagMissing = "missing"
+
+	// BagUnlisted is reported for payload files not listed in a payload
+	// manifest.
+	BagUnlisted = "unlisted"
+
+	// BagMismatch is reported for files whose digest differs from a
+	// manifest.
+	BagMismatch = "mismatch"
+
+	// BagOxumMismatch is reported if the payload differs from the
+	// Payload-Oxum of bag-info.txt.
+	BagOxumMismatch = "oxum-mismatch"
+
+	// BagUnsupportedAlgorithm is reported for manifests of algorithms that
+	// are not supported; their entries are not verified.
+	BagUnsupportedAlgorithm = "unsupported-algorithm"
+
+	// BagInvalid is reported for required elements that are missing or
+	// malformed, such as the payload directory or the payload manifests.
+	BagInvalid = "invalid"
+)
+
+// Bag is the bag-level metadata of a BagIt bag (RFC 8493) and the result of
+// verifying it.
+type Bag struct {
+	// Path is the path of the bag directory.
+	Path string
+
+	// Version and Encoding are the BagIt-Version and
+	// Tag-File-Character-Encoding declared in bagit.txt.
+	Version  string
+	Encoding string
+
+	// Info contains the metadata of bag-info.txt, keyed by label. Labels
+	// may be repeated, so every label has a list of values.
+	Info map[string][]string
+
+	// Algorithms are the algorithms of the payload manifests.
+	Algorithms []string
+
+	// PayloadFiles and PayloadBytes are the number and total size of the
+	// files in the payload directory.
+	PayloadFiles int
+	PayloadBytes int64
+
+	// FetchItems is the number of files listed in fetch.txt, which are
+	// fetched separately and not expected to be present.
+	FetchItems int
+
+	// Complete indicates that every manifest entry is present, every
+	// payload file is listed in every payload manifest and the payload
+	// matches the Payload-Oxum, if declared.
+	Complete bool
+
+	// Valid indicates that the bag is complete and the digests of all files
+	// match the payload and tag manifests.
+	Valid bool
+
+	// Problems are the problems found, sorted by path.
+	Problems []BagProblem
+}
+
+// BagProblem is a problem found by verifying a bag.
+type BagProblem struct {
+	// Path is the slash-separated path relative to the bag directory, if
+	// the problem concerns a file.
+	Path string
+
+	// Kind is the kind of problem (e.g., BagMissing or BagMismatch).
+	Kind string
+
+	// Algorithm is the algorithm of the manifest, if any.
+	Algorithm string
+
+	// Message describes the problem.
+	Message string
+}
+
+// bagManifest is a payload or tag manifest of a bag.
+type bagManifest struct {
+	algorithm string
+	tag       bool
+	digests   map[string]string
+}
+
+// IsBag reports whether the directory is a BagIt bag, i.e., whether it
+// contains a bagit.txt declaration.
+func IsBag(dirPath string) bool {
+	info, err := os.Stat(longPath(filepath.Join(dirPath, "bagit.txt")))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ExtractBag reads the BagIt bag at dirPath and verifies its completeness
+// and the fixity of its payload and tag files against their manifests. The
+// files are hashed on up to Options.Concurrency goroutines. Problems of the
+// bag are reported in Bag.Problems; an error is only returned if the bag
+// cannot be read.
+func (me *MetaExtractor) ExtractBag(dirPath string) (*Bag, error) {
+	if dirPath == "" {
+		return nil, ErrNoFileSpecified
+	}
+	if !IsBag(dirPath) {
+		return nil, ErrNotBag
+	}
+
+	bag := &Bag{Path: dirPath}
+
+	declaration, err := readBagTagFile(filepath.Join(dirPath, "bagit.txt"))
+	if err != nil {
+		return nil, err
+	}
+	bag.Version = firstValue(declaration, "BagIt-Version")
+	bag.Encoding = firstValue(declaration, "Tag-File-Character-Encoding")
+	if bag.Version == "" || bag.Encoding == "" {
+		bag.problem("bagit.txt", BagInvalid, "", "missing BagIt-Version or Tag-File-Character-Encoding")
+	}
+
+	if bag.Info, err = readBagTagFile(filepath.Join(dirPath, "bag-info.txt")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	fetched, err := readBagFetch(filepath.Join(dirPath, "fetch.txt"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	bag.FetchItems = len(fetched)
+
+	manifests, err := readBagManifests(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := bagPayload(dirPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		bag.problem("data", BagInvalid, "", "missing payload directory")
+	} else if err != nil {
+		bag.problem("data", BagInvalid, "", err.Error())
+	}
+	bag.PayloadFiles = len(payload)
+	for _, size := range payload {
+		bag.PayloadBytes += size
+	}
+
+	me.verifyBag(bag, manifests, payload, fetched)
+
+	sort.SliceStable(bag.Problems, func(i, j int) bool {
+		return bag.Problems[i].Path < bag.Problems[j].Path
+	})
+
+	return bag, nil
+}
+
+// verifyBag checks the completeness and fixity of the bag.
+func (me *MetaExtractor) verifyBag(bag *Bag, manifests []bagManifest, payload map[string]int64, fetched map[string]bool) {
+	complete, valid := true, true
+
+	// digests holds the supported algorithms to compute for every file.
+	digests := make(map[string][]string)
+	for _, m := range manifests {
+		if !m.tag {
+			bag.Algorithms = append(bag.Algorithms, m.algorithm)
+		}
+
+		if _, ok := hashFuncs[m.algorithm]; !ok {
+			bag.problem("", BagUnsupportedAlgorithm, m.algorithm, fmt.Sprintf("unsupported manifest algorithm %q", m.algorithm))
+			continue
+		}
+
+		for p := range m.digests {
+			if !slices.Contains(digests[p], m.algorithm) {
+				digests[p] = append(digests[p], m.algorithm)
+			}
+		}
+	}
+
+	if len(bag.Algorithms) == 0 {
+		bag.problem("", BagInvalid, "", "no payload manifest")
+		complete = false
+	}
+
+	for _, m := range manifests {
+		if m.tag {
+			continue
+		}
+		for p := range payload {
+			if _, ok := m.digests[p]; !ok {
+				bag.problem(p, BagUnlisted, m.algorithm, "payload file not listed in the manifest")
+				complete = false
+			}
+		}
+	}
+
+	if oxum := firstValue(bag.Info, "Payload-Oxum"); oxum != "" {
+		octets, count, ok := strings.Cut(oxum, ".")
+		wantBytes, err1 := strconv.ParseInt(octets, 10, 64)
+		wantFiles, err2 := strconv.Atoi(count)
+		if !ok || err1 != nil || err2 != nil || wantBytes != bag.PayloadBytes || wantFiles != bag.PayloadFiles {
+			bag.problem("bag-info.txt", BagOxumMismatch, "", fmt.Sprintf("Payload-Oxum %s does not match the payload (%d.%d)", oxum, bag.PayloadBytes, bag.PayloadFiles))
+			complete = false
+		}
+	}
+
+	paths := make([]string, 0, len(digests))
+	for p := range digests {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	type fileResult struct {
+		digests map[string]string
+		err     error
+	}
+	results := make([]fileResult, len(paths))
+	me.parallel(len(paths), func(i int) {
+		d, err := fileDigests(filepath.Join(bag.Path, filepath.FromSlash(paths[i])), digests[paths[i]])
+		results[i] = fileResult{digests: d, err: err}
+	})
+
+	for i, p := range paths {
+		if err := results[i].err; err != nil {
+			if errors.Is(err, fs.ErrNotExist) && fetched[p] {
+				continue
+			}
+
+			if errors.Is(err, fs.ErrNotExist) {
+				bag.problem(p, BagMissing, "", "file listed in a manifest is missing")
+				complete = false
+			} else {
+				bag.problem(p, BagMismatch, "", err.Error())
+				valid = false
+			}
+			continue
+		}
+
+		for _, m := range manifests {
+			want, ok := m.digests[p]
+			if !ok {
+				continue
+			}
+			if got, ok := results[i].digests[m.algorithm]; ok && !strings.EqualFold(got, want) {
+				bag.problem(p, BagMismatch, m.algorithm, fmt.Sprintf("digest %s does not match the manifest (%s)", got, want))
+				valid = false
+			}
+		}
+	}
+
+	for _, problem := range bag.Problems {
+		if problem.Kind == BagInvalid {
+			complete = false
+		}
+	}
+
+	bag.Complete = complete
+	bag.Valid = complete && valid
+}
+
+// problem records a problem of the bag.
+func (bag *Bag) problem(p, kind, algorithm, message string) {
+	bag.Problems = append(bag.Problems, BagProblem{Path: p, Kind: kind, Algorithm: algorithm, Message: message})
+}
+
+// readBagTagFile parses a tag file of "Label: value" lines, where lines
+// starting with whitespace continue the previous value.
+func readBagTagFile(filePath string) (map[string][]string, error) {
+	f, err := os.Open(longPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string][]string)
+	var label string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		line = strings.TrimPrefix(line, "\ufeff")
+
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			if label != "" {
+				last := len(values[label]) - 1
+				values[label][last] += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			label = ""
+			continue
+		}
+
+		label = strings.TrimSpace(key)
+		values[label] = append(values[label], strings.TrimSpace(value))
+	}
+
+	return values, scanner.Err()
+}
+
+// firstValue returns the first value of the label, or an empty string.
+func firstValue(values map[string][]string, label string) string {
+	if v := values[label]; len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
+
+// readBagFetch returns the paths listed in fetch.txt ("URL LENGTH PATH").
+func readBagFetch(filePath string) (map[string]bool, error) {
+	f, err := os.Open(longPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fetched := make(map[string]bool)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 3)
+		if len(fields) == 3 {
+			fetched[decodeBagPath(strings.TrimSpace(fields[2]))] = true
+		}
+	}
+
+	return fetched, scanner.Err()
+}
+
+// readBagManifests reads the payload (manifest-ALG.txt) and tag
+// (tagmanifest-ALG.txt) manifests of the bag.
+func readBagManifests(dirPath string) ([]bagManifest, error) {
+	entries, err := os.ReadDir(longPath(dirPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []bagManifest
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var m bagManifest
+		if algorithm, ok := bagManifestAlgorithm(name, "manifest-"); ok {
+			m.algorithm = algorithm
+		} else if algorithm, ok := bagManifestAlgorithm(name, "tagmanifest-"); ok {
+			m.algorithm, m.tag = algorithm, true
+		} else {
+			continue
+		}
+
+		if m.digests, err = readBagManifest(filepath.Join(dirPath, name)); err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+
+	return manifests, nil
+}
+
+// bagManifestAlgorithm returns the algorithm of a manifest file name with
+// the given prefix (e.g., "manifest-sha256.txt").
+func bagManifestAlgorithm(name, prefix string) (string, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".txt") {
+		return "", false
+	}
+
+	algorithm := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".txt")
+	return strings.ToLower(algorithm), algorithm != ""
+}
+
+// readBagManifest parses the "DIGEST PATH" lines of a manifest.
+func readBagManifest(filePath string) (map[string]string, error) {
+	f, err := os.Open(longPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	digests := make(map[string]string)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		digest, p, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+
+		p = decodeBagPath(strings.TrimLeft(p, " *"))
+		if p != "" {
+			digests[path.Clean(p)] = strings.ToLower(digest)
+		}
+	}
+
+	return digests, scanner.Err()
+}
+
+// decodeBagPath decodes the percent-encoded line breaks and percent signs of
+// a path in a manifest or fetch file.
+func decodeBagPath(p string) string {
+	return strings.NewReplacer("%0D", "\r", "%0d", "\r", "%0A", "\n", "%0a", "\n", "%25", "%").Replace(p)
+}
+
+// bagPayload returns the sizes of the files of the payload directory, keyed
+// by slash-separated path relative to the bag.
+func bagPayload(dirPath string) (map[string]int64, error) {
+	payload := make(map[string]int64)
+	root := filepath.Join(dirPath, "data")
+
+	info, err := os.Stat(longPath(root))
+	if err != nil {
+		return payload, err
+	}
+	if !info.IsDir() {
+		return payload, fs.ErrNotExist
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dirPath, p)
+		if err != nil {
+			return err
+		}
+		payload[filepath.ToSlash(rel)] = info.Size()
+
+		return nil
+	})
+
+	return payload, err
+}
@@ -0,0 +1,170 @@
+package metaextractor
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// createBag creates a valid bag with two payload files.
+func createBag(t *testing.T) string {
+	t.Helper()
+
+	bagit := "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"
+	bagInfo := "Source-Organization: Example Archive\nExternal-Description: A description\n  continued here\nKeyword: one\nKeyword: two\nPayload-Oxum: 8.2\n"
+	manifest := sha256Hex("hello") + "  data/hello.txt\n" + sha256Hex("abc") + "  data/sub/a%25b.txt\n"
+
+	return createTree(t, map[string]string{
+		"bagit.txt":           bagit,
+		"bag-info.txt":        bagInfo,
+		"manifest-sha256.txt": manifest,
+		"tagmanifest-md5.txt": md5Hex(bagit) + " bagit.txt\n" + md5Hex(bagInfo) + " bag-info.txt\n" + md5Hex(manifest) + " manifest-sha256.txt\n",
+		"data/hello.txt":      "hello",
+		"data/sub/a%b.txt":    "abc",
+	})
+}
+
+func TestExtractBag(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true, Concurrency: 2})
+
+	t.Run("Valid", func(t *testing.T) {
+		root := createBag(t)
+		assert.True(t, IsBag(root))
+
+		bag, err := me.ExtractBag(root)
+		require.NoError(t, err)
+		assert.Empty(t, bag.Problems)
+		assert.True(t, bag.Complete)
+		assert.True(t, bag.Valid)
+		assert.Equal(t, "1.0", bag.Version)
+		assert.Equal(t, "UTF-8", bag.Encoding)
+		assert.Equal(t, []string{"sha256"}, bag.Algorithms)
+		assert.Equal(t, 2, bag.PayloadFiles)
+		assert.Equal(t, int64(8), bag.PayloadBytes)
+		assert.Equal(t, []string{"A description continued here"}, bag.Info["External-Description"])
+		assert.Equal(t, []string{"one", "two"}, bag.Info["Keyword"])
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		root := createBag(t)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "data", "hello.txt"), []byte("HELLO"), 0o644))
+
+		bag, err := me.ExtractBag(root)
+		require.NoError(t, err)
+		assert.True(t, bag.Complete)
+		assert.False(t, bag.Valid)
+		require.Len(t, bag.Problems, 1)
+		assert.Equal(t, BagProblem{
+			Path:      "data/hello.txt",
+			Kind:      BagMismatch,
+			Algorithm: "sha256",
+			Message:   "digest " + sha256Hex("HELLO") + " does not match the manifest (" + sha256Hex("hello") + ")",
+		}, bag.Problems[0])
+	})
+
+	t.Run("Incomplete", func(t *testing.T) {
+		root := createBag(t)
+		require.NoError(t, os.Remove(filepath.Join(root, "data", "hello.txt")))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "data", "extra.txt"), []byte("123456"), 0o644))
+
+		bag, err := me.ExtractBag(root)
+		require.NoError(t, err)
+		assert.False(t, bag.Complete)
+		assert.False(t, bag.Valid)
+
+		kinds := make(map[string]string)
+		for _, p := range bag.Problems {
+			kinds[p.Path] = p.Kind
+		}
+		assert.Equal(t, map[string]string{
+			"bag-info.txt":   BagOxumMismatch,
+			"data/extra.txt": BagUnlisted,
+			"data/hello.txt": BagMissing,
+		}, kinds)
+	})
+
+	t.Run("Fetched", func(t *testing.T) {
+		root := createBag(t)
+		require.NoError(t, os.Remove(filepath.Join(root, "data", "hello.txt")))
+		require.NoError(t, os.Remove(filepath.Join(root, "bag-info.txt")))
+		require.NoError(t, os.Remove(filepath.Join(root, "tagmanifest-md5.txt")))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "fetch.txt"), []byte("https://example.com/hello.txt 5 data/hello.txt\n"), 0o644))
+
+		bag, err := me.ExtractBag(root)
+		require.NoError(t, err)
+		assert.Empty(t, bag.Problems)
+		assert.True(t, bag.Valid)
+		assert.Equal(t, 1, bag.FetchItems)
+	})
+
+	t.Run("Unsupported Algorithm", func(t *testing.T) {
+		root := createBag(t)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "manifest-sha3.txt"), []byte("00  data/hello.txt\n00  data/sub/a%25b.txt\n"), 0o644))
+
+		bag, err := me.ExtractBag(root)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"sha256", "sha3"}, bag.Algorithms)
+		require.Len(t, bag.Problems, 1)
+		assert.Equal(t, BagUnsupportedAlgorithm, bag.Problems[0].Kind)
+		assert.True(t, bag.Valid)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		root := createTree(t, map[string]string{"bagit.txt": "BagIt-Version: 1.0\n"})
+
+		bag, err := me.ExtractBag(root)
+		require.NoError(t, err)
+		assert.False(t, bag.Complete)
+		assert.Len(t, bag.Problems, 3)
+	})
+
+	t.Run("Not a Bag", func(t *testing.T) {
+		_, err := me.ExtractBag(t.TempDir())
+		assert.ErrorIs(t, err, ErrNotBag)
+
+		_, err = me.ExtractBag("")
+		assert.ErrorIs(t, err, ErrNoFileSpecified)
+	})
+}
+
+func TestExtractDir_Bags(t *testing.T) {
+	root := createBag(t)
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	results, err := me.ExtractDir(root, WalkOptions{Bags: true})
+	require.NoError(t, err)
+
+	var bags []*Bag
+	files := 0
+	for _, r := range results {
+		if r.Bag != nil {
+			bags = append(bags, r.Bag)
+			assert.Equal(t, root, r.Path)
+		} else {
+			files++
+		}
+	}
+	require.Len(t, bags, 1)
+	assert.True(t, bags[0].Valid)
+	assert.Equal(t, 6, files)
+
+	results, err = me.ExtractDir(root, WalkOptions{})
+	require.NoError(t, err)
+	assert.Len(t, results, 6)
+}
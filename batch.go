@@ -0,0 +1,124 @@
+package metaextractor
+
+import "context"
+
+// ExtractBatch extracts metadata from each of the given files on up to
+// Options.Concurrency goroutines. Results are returned in the order of the
+// paths; failures are reported per file. The run is aborted once a stage
+// exceeds its error budget (see Options.ErrorBudgets).
+func (me *MetaExtractor) ExtractBatch(paths []string) []Result {
+	ctx := withSplitDirCache(withBudget(context.Background(), me.newBudgetTracker()))
+	dedup := me.newDedupIndex()
+	queue := me.newExtractQueue()
+	progress := me.newProgress(len(paths))
+
+	results := make([]Result, 0, len(paths))
+	for _, p := range paths {
+		if queue != nil {
+			results = queue.add(results, p)
+		} else {
+			results = dedup.extract(ctx, me, results, p)
+			progress.report(p)
+		}
+	}
+
+	if queue != nil {
+		queue.run(ctx, me, results)
+	}
+
+	me.stampResults(results)
+
+	return results
+}
+
+// ExtractStream extracts metadata from each path received from paths on up
+// to Options.Concurrency goroutines and sends the results to the returned
+// channel as they become available, in the order of the paths, so that large
+// trees can be processed without keeping every result in memory. The channel
+// is closed once paths is closed and drained, or when ctx is done; in the
+// latter case, the results of the files in progress are dropped. The results
+// are not deduplicated (see Options.Deduplicate), as this would require
+// keeping them. Once a stage exceeds its error budget (see
+// Options.ErrorBudgets), the remaining files fail with the BudgetError.
+//
+// Results the consumer is not ready to receive are buffered up to
+// Options.ResultBuffer; beyond that, the extraction waits for the consumer,
+// or the results are spilled to Options.SpillDir.
+func (me *MetaExtractor) ExtractStream(ctx context.Context, paths <-chan string) <-chan Result {
+	results := make(chan Result, me.resultBuffer)
+	if me.spillDir != "" {
+		results = make(chan Result)
+	}
+	budget := me.newBudgetTracker()
+
+	// Every file in progress has a channel receiving its result, queued in
+	// the order of the paths; the capacity of the queue bounds the number of
+	// files in progress.
+	queue := make(chan chan Result, max(me.concurrency, 1)-1)
+
+	go func() {
+		defer close(queue)
+
+		for {
+			var p string
+			select {
+			case <-ctx.Done():
+				return
+			case path, ok := <-paths:
+				if !ok {
+					return
+				}
+				p = path
+			}
+
+			result := make(chan Result, 1)
+			select {
+			case <-ctx.Done():
+				return
+			case queue <- result:
+			}
+
+			if err := budget.err(); err != nil {
+				result <- Result{Path: p, Err: err}
+				continue
+			}
+
+			go func() {
+				metadata, err := me.ExtractContext(withBudget(ctx, budget), p)
+				result <- Result{Path: p, Metadata: metadata, Err: err}
+			}()
+		}
+	}()
+
+	go func() {
+		defer close(results)
+
+		stamp := me.newStamp()
+
+		for result := range queue {
+			var r Result
+			select {
+			case <-ctx.Done():
+				return
+			case r = <-result:
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+			stamp(&r)
+
+			select {
+			case <-ctx.Done():
+				return
+			case results <- r:
+			}
+		}
+	}()
+
+	if me.spillDir != "" {
+		return me.bufferResults(ctx, results)
+	}
+
+	return results
+}
@@ -0,0 +1,86 @@
+package metaextractor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractBatch(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	paths := []string{
+		filepath.Join("testdata", "sample.doc"),
+		"nonexistent_file",
+		filepath.Join("testdata", "sample.mp3"),
+	}
+
+	results := me.ExtractBatch(paths)
+	require.Len(t, results, 3)
+	for i, r := range results {
+		assert.Equal(t, paths[i], r.Path)
+	}
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "sample.doc", results[0].Metadata.Name)
+	assert.ErrorIs(t, results[1].Err, ErrFileNotFound)
+	assert.NoError(t, results[2].Err)
+
+	assert.Empty(t, me.ExtractBatch(nil))
+}
+
+func TestExtractStream(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true, RunID: "run-1"})
+
+	paths := []string{
+		filepath.Join("testdata", "sample.doc"),
+		"nonexistent_file",
+		filepath.Join("testdata", "sample.mp3"),
+	}
+
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, p := range paths {
+			in <- p
+		}
+	}()
+
+	var results []Result
+	for r := range me.ExtractStream(context.Background(), in) {
+		results = append(results, r)
+	}
+
+	require.Len(t, results, 3)
+	for i, r := range results {
+		assert.Equal(t, paths[i], r.Path)
+		assert.Equal(t, "run-1", r.RunID)
+		assert.NotEmpty(t, r.RecordID)
+	}
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrFileNotFound)
+	assert.NotEqual(t, results[0].RecordID, results[2].RecordID)
+}
+
+func TestExtractStream_Cancel(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	// The paths channel is never closed; canceling the context must close
+	// the results.
+	in := make(chan string, 1)
+	in <- filepath.Join("testdata", "sample.doc")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := me.ExtractStream(ctx, in)
+
+	r, ok := <-results
+	require.True(t, ok)
+	assert.NoError(t, r.Err)
+
+	cancel()
+	_, ok = <-results
+	assert.False(t, ok)
+}
@@ -0,0 +1,168 @@
+package metaextractor
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// fixtureKinds are the synthetic files generated for the benchmarks: a
+// header identifying the type, followed by random content of the given size.
+var fixtureKinds = []struct {
+	ext    string
+	header string
+	size   int
+}{
+	{".jpg", "\xff\xd8\xff\xe0\x00\x10JFIF\x00", 64 << 10},
+	{".pdf", "%PDF-1.7\n", 16 << 10},
+	{".png", "\x89PNG\r\n\x1a\n", 128 << 10},
+	{".bin", "", 256 << 10},
+}
+
+// writeFixtures creates n synthetic files in dir and returns their paths.
+func writeFixtures(b *testing.B, dir string, n int) []string {
+	b.Helper()
+
+	rnd := rand.New(rand.NewSource(1))
+
+	paths := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		kind := fixtureKinds[i%len(fixtureKinds)]
+
+		content := make([]byte, kind.size)
+		rnd.Read(content)
+		copy(content, kind.header)
+
+		p := filepath.Join(dir, fmt.Sprintf("sub%d", i%4), fmt.Sprintf("file%03d%s", i, kind.ext))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(p, content, 0o644); err != nil {
+			b.Fatal(err)
+		}
+
+		paths = append(paths, p)
+	}
+
+	return paths
+}
+
+// benchmarkExtractors returns the extractor configurations to benchmark. The
+// configuration using TrID and ExifTool is only included if both are
+// installed.
+func benchmarkExtractors() map[string]Options {
+	configs := map[string]Options{
+		"PureGo": {PureGo: true, Hashes: []string{"sha256"}},
+	}
+
+	_, tridErr := exec.LookPath("trid")
+	_, exifErr := exec.LookPath("exiftool")
+	if tridErr == nil && exifErr == nil && !pureGoBuild {
+		configs["Tools"] = Options{Hashes: []string{"sha256"}}
+	}
+
+	return configs
+}
+
+// reportThroughput sets the number of bytes processed per operation.
+func reportThroughput(b *testing.B, paths []string) {
+	var size int64
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			size += info.Size()
+		}
+	}
+
+	b.SetBytes(size)
+}
+
+func BenchmarkExtract(b *testing.B) {
+	for name, opts := range benchmarkExtractors() {
+		b.Run(name, func(b *testing.B) {
+			paths := writeFixtures(b, b.TempDir(), len(fixtureKinds))
+			me := NewMetaExtractor(opts)
+
+			reportThroughput(b, paths[:1])
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := me.Extract(paths[0]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExtractBatch(b *testing.B) {
+	for name, opts := range benchmarkExtractors() {
+		b.Run(name, func(b *testing.B) {
+			paths := writeFixtures(b, b.TempDir(), 64)
+			me := NewMetaExtractor(opts)
+
+			reportThroughput(b, paths)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for _, r := range me.ExtractBatch(paths) {
+					if r.Err != nil {
+						b.Fatal(r.Err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExtractDir(b *testing.B) {
+	for name, opts := range benchmarkExtractors() {
+		b.Run(name, func(b *testing.B) {
+			dir := b.TempDir()
+			paths := writeFixtures(b, dir, 64)
+			me := NewMetaExtractor(opts)
+
+			reportThroughput(b, paths)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := me.ExtractDir(dir, WalkOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDetectSignature(b *testing.B) {
+	paths := writeFixtures(b, b.TempDir(), len(fixtureKinds))
+
+	reportThroughput(b, paths)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, p := range paths {
+			if _, err := signatureAnalysis(p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkScanFile(b *testing.B) {
+	paths := writeFixtures(b, b.TempDir(), len(fixtureKinds))
+
+	reportThroughput(b, paths)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, p := range paths {
+			opts := scanOptions{hashes: []string{"md5", "sha1", "sha256", "sha256-tree"}, entropy: true, headSize: signatureHeadSize}
+			if _, err := scanFile(p, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
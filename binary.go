@@ -0,0 +1,123 @@
+package metaextractor
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BinaryStore stores binary EXIF values (e.g., embedded thumbnails) outside
+// the metadata. Implementations may write to a directory (see DirStore) or to
+// an object store.
+type BinaryStore interface {
+	// Store stores data under the given key and returns its location (e.g.,
+	// a file path or an object URL). Keys are derived from the content, so
+	// storing the same key twice may be skipped.
+	Store(key string, data []byte) (string, error)
+}
+
+// DirStore is a BinaryStore writing each value to a file named after its key
+// in the directory.
+type DirStore string
+
+// Store writes data to the file named key in the directory, unless it
+// already exists, and returns its path.
+func (d DirStore) Store(key string, data []byte) (string, error) {
+	dir := string(d)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, key)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	// The data is written to a temporary file first, so that concurrent
+	// extractions never observe a partially written value.
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return path, nil
+}
+
+// BinaryRef replaces a binary EXIF value that has been written to the
+// BinaryStore.
+type BinaryRef struct {
+	// Path is the location returned by the BinaryStore.
+	Path string `json:"path"`
+
+	// Size is the size of the value in bytes.
+	Size int `json:"size"`
+
+	// SHA256 is the hex-encoded SHA-256 digest of the value. It is also the
+	// key the value was stored under.
+	SHA256 string `json:"sha256"`
+}
+
+// externalizeBinary writes the binary values of exif (encoded by ExifTool as
+// "base64:...") of at least minSize bytes to the store, replacing them with
+// a BinaryRef.
+func externalizeBinary(exif ExifMetadata, store BinaryStore, minSize int) error {
+	externalize := func(values map[string]interface{}, key string) error {
+		s, ok := values[key].(string)
+		if !ok || !strings.HasPrefix(s, "base64:") {
+			return nil
+		}
+
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, "base64:"))
+		if err != nil || len(data) < minSize {
+			return nil
+		}
+
+		sum := sha256.Sum256(data)
+		digest := hex.EncodeToString(sum[:])
+
+		path, err := store.Store(digest, data)
+		if err != nil {
+			return fmt.Errorf("error storing binary value %s: %w", key, err)
+		}
+
+		values[key] = BinaryRef{Path: path, Size: len(data), SHA256: digest}
+		return nil
+	}
+
+	for key, value := range exif {
+		if nested, ok := value.(map[string]interface{}); ok {
+			for tag := range nested {
+				if err := externalize(nested, tag); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err := externalize(exif, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
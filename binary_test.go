@@ -0,0 +1,75 @@
+package metaextractor
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct{}
+
+func (failingStore) Store(string, []byte) (string, error) {
+	return "", errors.New("store unavailable")
+}
+
+func TestExternalizeBinary(t *testing.T) {
+	thumbnail := []byte("thumbnail data")
+	sum := sha256.Sum256(thumbnail)
+	digest := hex.EncodeToString(sum[:])
+	encoded := "base64:" + base64.StdEncoding.EncodeToString(thumbnail)
+
+	t.Run("Store", func(t *testing.T) {
+		dir := t.TempDir()
+		exif := ExifMetadata{
+			"Make":           "Canon",
+			"ThumbnailImage": encoded,
+			"Preview":        "base64:AA==",
+			"EXIF":           map[string]interface{}{"ThumbnailImage": encoded},
+		}
+
+		require.NoError(t, externalizeBinary(exif, DirStore(dir), 4))
+
+		ref := BinaryRef{Path: filepath.Join(dir, digest), Size: len(thumbnail), SHA256: digest}
+		assert.Equal(t, ref, exif["ThumbnailImage"])
+		assert.Equal(t, ref, exif["EXIF"].(map[string]interface{})["ThumbnailImage"])
+		assert.Equal(t, "Canon", exif["Make"])
+		assert.Equal(t, "base64:AA==", exif["Preview"], "values below the threshold stay inline")
+
+		data, err := os.ReadFile(ref.Path)
+		require.NoError(t, err)
+		assert.Equal(t, thumbnail, data)
+	})
+
+	t.Run("Store Error", func(t *testing.T) {
+		exif := ExifMetadata{"ThumbnailImage": encoded}
+		assert.ErrorContains(t, externalizeBinary(exif, failingStore{}, 0), "store unavailable")
+	})
+}
+
+func TestDirStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blobs")
+	store := DirStore(dir)
+
+	path, err := store.Store("key", []byte("first"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "key"), path)
+
+	// Existing keys are not rewritten.
+	_, err = store.Store("key", []byte("second"))
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,230 @@
+package metaextractor
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// BLAKE3 constants, following the reference implementation.
+const (
+	blake3BlockLen = 64
+	blake3ChunkLen = 1024
+
+	blake3ChunkStart = 1 << 0
+	blake3ChunkEnd   = 1 << 1
+	blake3Parent     = 1 << 2
+	blake3Root       = 1 << 3
+)
+
+var blake3IV = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+var blake3Permutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+// blake3Hash is the BLAKE3 hash with a 256-bit digest. It is a portable
+// implementation of the reference algorithm, without SIMD.
+type blake3Hash struct {
+	chunk blake3Chunk
+
+	// stack holds the chaining values of the completed subtrees.
+	stack [][8]uint32
+}
+
+// newBLAKE3 returns a new BLAKE3 hash.
+func newBLAKE3() hash.Hash {
+	h := &blake3Hash{}
+	h.Reset()
+	return h
+}
+
+func (h *blake3Hash) Reset() {
+	h.chunk = newBLAKE3Chunk(0)
+	h.stack = h.stack[:0]
+}
+
+func (h *blake3Hash) Size() int { return 32 }
+
+func (h *blake3Hash) BlockSize() int { return blake3BlockLen }
+
+func (h *blake3Hash) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		if h.chunk.len() == blake3ChunkLen {
+			cv := h.chunk.output().chainingValue()
+			total := h.chunk.counter + 1
+			h.addChunk(cv, total)
+			h.chunk = newBLAKE3Chunk(total)
+		}
+
+		c := min(blake3ChunkLen-h.chunk.len(), len(p))
+		h.chunk.update(p[:c])
+		p = p[c:]
+	}
+
+	return n, nil
+}
+
+// addChunk merges the chaining value of a completed chunk into the subtrees
+// of the stack; total is the number of chunks completed so far.
+func (h *blake3Hash) addChunk(cv [8]uint32, total uint64) {
+	for total&1 == 0 {
+		left := h.stack[len(h.stack)-1]
+		h.stack = h.stack[:len(h.stack)-1]
+		cv = blake3ParentOutput(left, cv).chainingValue()
+		total >>= 1
+	}
+
+	h.stack = append(h.stack, cv)
+}
+
+func (h *blake3Hash) Sum(b []byte) []byte {
+	out := h.chunk.output()
+	for i := len(h.stack) - 1; i >= 0; i-- {
+		out = blake3ParentOutput(h.stack[i], out.chainingValue())
+	}
+
+	words := blake3Compress(&out.cv, &out.block, 0, out.blockLen, out.flags|blake3Root)
+	for _, w := range words[:8] {
+		b = binary.LittleEndian.AppendUint32(b, w)
+	}
+
+	return b
+}
+
+// blake3Chunk is the state of the chunk being hashed.
+type blake3Chunk struct {
+	cv         [8]uint32
+	counter    uint64
+	block      [blake3BlockLen]byte
+	blockLen   int
+	compressed int
+}
+
+func newBLAKE3Chunk(counter uint64) blake3Chunk {
+	return blake3Chunk{cv: blake3IV, counter: counter}
+}
+
+// len returns the number of bytes of the chunk hashed so far.
+func (c *blake3Chunk) len() int {
+	return blake3BlockLen*c.compressed + c.blockLen
+}
+
+func (c *blake3Chunk) startFlag() uint32 {
+	if c.compressed == 0 {
+		return blake3ChunkStart
+	}
+
+	return 0
+}
+
+func (c *blake3Chunk) update(p []byte) {
+	for len(p) > 0 {
+		// The last block is kept, as it is compressed with the end flag.
+		if c.blockLen == blake3BlockLen {
+			words := blake3Words(&c.block)
+			out := blake3Compress(&c.cv, &words, c.counter, blake3BlockLen, c.startFlag())
+			copy(c.cv[:], out[:8])
+			c.compressed++
+			c.block = [blake3BlockLen]byte{}
+			c.blockLen = 0
+		}
+
+		n := copy(c.block[c.blockLen:], p)
+		c.blockLen += n
+		p = p[n:]
+	}
+}
+
+func (c *blake3Chunk) output() blake3Output {
+	return blake3Output{
+		cv:       c.cv,
+		block:    blake3Words(&c.block),
+		counter:  c.counter,
+		blockLen: uint32(c.blockLen),
+		flags:    c.startFlag() | blake3ChunkEnd,
+	}
+}
+
+// blake3Output is the input of the last compression of a node, which yields
+// either its chaining value or, for the root, the digest.
+type blake3Output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o blake3Output) chainingValue() [8]uint32 {
+	out := blake3Compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags)
+
+	var cv [8]uint32
+	copy(cv[:], out[:8])
+
+	return cv
+}
+
+func blake3ParentOutput(left, right [8]uint32) blake3Output {
+	o := blake3Output{cv: blake3IV, blockLen: blake3BlockLen, flags: blake3Parent}
+	copy(o.block[:8], left[:])
+	copy(o.block[8:], right[:])
+
+	return o
+}
+
+func blake3Words(block *[blake3BlockLen]byte) [16]uint32 {
+	var words [16]uint32
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(block[4*i:])
+	}
+
+	return words
+}
+
+func blake3Compress(cv *[8]uint32, block *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	m := *block
+
+	for r := 0; r < 7; r++ {
+		blake3G(&s, 0, 4, 8, 12, m[0], m[1])
+		blake3G(&s, 1, 5, 9, 13, m[2], m[3])
+		blake3G(&s, 2, 6, 10, 14, m[4], m[5])
+		blake3G(&s, 3, 7, 11, 15, m[6], m[7])
+		blake3G(&s, 0, 5, 10, 15, m[8], m[9])
+		blake3G(&s, 1, 6, 11, 12, m[10], m[11])
+		blake3G(&s, 2, 7, 8, 13, m[12], m[13])
+		blake3G(&s, 3, 4, 9, 14, m[14], m[15])
+
+		var permuted [16]uint32
+		for i, j := range blake3Permutation {
+			permuted[i] = m[j]
+		}
+		m = permuted
+	}
+
+	for i := 0; i < 8; i++ {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+
+	return s
+}
+
+func blake3G(s *[16]uint32, a, b, c, d int, mx, my uint32) {
+	s[a] += s[b] + mx
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] += s[b] + my
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
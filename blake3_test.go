@@ -0,0 +1,55 @@
+package metaextractor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blake3Input returns the input of the official BLAKE3 test vectors: the
+// byte sequence 0, 1, ..., 250, 0, 1, ... of length n.
+func blake3Input(n int) []byte {
+	input := make([]byte, n)
+	for i := range input {
+		input[i] = byte(i % 251)
+	}
+
+	return input
+}
+
+func TestBLAKE3(t *testing.T) {
+	testCases := []struct {
+		length int
+		want   string
+	}{
+		{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+		{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+		{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+		{2048, "e776b6028c7cd22a4d0ba182a8bf62205d2ef576467e838ed6f2529b85fba24a"},
+		{2049, "5f4d72f40d7a5f82b15ca2b2e44b1de3c2ef86c426c95c1af0b6879522563030"},
+		{102400, "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d Bytes", tc.length), func(t *testing.T) {
+			input := blake3Input(tc.length)
+
+			h := newBLAKE3()
+			h.Write(input)
+			assert.Equal(t, tc.want, hex.EncodeToString(h.Sum(nil)))
+
+			// Uneven writes and repeated sums give the same digest.
+			h.Reset()
+			for off := 0; off < len(input); off += 100 {
+				h.Write(input[off:min(off+100, len(input))])
+			}
+			assert.Equal(t, tc.want, hex.EncodeToString(h.Sum(nil)))
+			assert.Equal(t, tc.want, hex.EncodeToString(h.Sum(nil)))
+		})
+	}
+
+	assert.Equal(t, "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85", hashString(newBLAKE3(), "abc"))
+}
@@ -0,0 +1,103 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// budgetEntry is a value of ExifMetadata that may be dropped to meet the
+// size budget.
+type budgetEntry struct {
+	group  string // Group of a nested value, or "" for a top-level value.
+	tag    string
+	size   int
+	binary bool
+}
+
+// key returns the key reported in Metadata.ExifDropped.
+func (e budgetEntry) key() string {
+	if e.group != "" {
+		return e.group + ":" + e.tag
+	}
+	return e.tag
+}
+
+// limitExifSize drops values from exif until its JSON encoding fits into
+// maxSize bytes. Binary values (encoded by ExifTool as "base64:...") are
+// dropped first, then the remaining values, largest first. It returns the keys
+// of the dropped values in the order they were dropped; nested values are
+// reported as "Group:Tag".
+func limitExifSize(exif ExifMetadata, maxSize int) []string {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	total := jsonSize(exif)
+	if total <= maxSize {
+		return nil
+	}
+
+	var entries []budgetEntry
+	for key, value := range exif {
+		if nested, ok := value.(map[string]interface{}); ok {
+			for tag, v := range nested {
+				entries = append(entries, newBudgetEntry(key, tag, v))
+			}
+			continue
+		}
+
+		entries = append(entries, newBudgetEntry("", key, value))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.binary != b.binary {
+			return a.binary
+		}
+		if a.size != b.size {
+			return a.size > b.size
+		}
+		return a.key() < b.key()
+	})
+
+	var dropped []string
+	for _, e := range entries {
+		if total <= maxSize {
+			break
+		}
+
+		if e.group != "" {
+			delete(exif[e.group].(map[string]interface{}), e.tag)
+		} else {
+			delete(exif, e.tag)
+		}
+
+		total -= e.size
+		dropped = append(dropped, e.key())
+	}
+
+	return dropped
+}
+
+// newBudgetEntry returns the entry of a value. Its size includes the key, the
+// colon and the separating comma.
+func newBudgetEntry(group, tag string, value interface{}) budgetEntry {
+	s, isString := value.(string)
+
+	return budgetEntry{
+		group:  group,
+		tag:    tag,
+		size:   jsonSize(tag) + jsonSize(value) + 2,
+		binary: isString && strings.HasPrefix(s, "base64:"),
+	}
+}
+
+// jsonSize returns the length of the JSON encoding of v.
+func jsonSize(v interface{}) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
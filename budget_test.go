@@ -0,0 +1,67 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitExifSize(t *testing.T) {
+	newExif := func() ExifMetadata {
+		return ExifMetadata{
+			"SourceFile":     "a.jpg",
+			"Make":           "Canon",
+			"Comment":        strings.Repeat("c", 400),
+			"Description":    strings.Repeat("d", 200),
+			"ThumbnailImage": "base64:" + strings.Repeat("A", 100),
+			"Orientation":    1.0,
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		maxSize int
+		dropped []string
+	}{
+		{"Disabled", 0, nil},
+		{"Within Limit", 10000, nil},
+		{"Binary First", 700, []string{"ThumbnailImage"}},
+		{"Largest Next", 400, []string{"ThumbnailImage", "Comment"}},
+		{"Everything", 1, []string{"ThumbnailImage", "Comment", "Description", "SourceFile", "Orientation", "Make"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exif := newExif()
+			dropped := limitExifSize(exif, tc.maxSize)
+			assert.Equal(t, tc.dropped, dropped)
+
+			for _, key := range dropped {
+				assert.NotContains(t, exif, key)
+			}
+
+			if tc.maxSize > 2 {
+				data, err := json.Marshal(exif)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(data), tc.maxSize)
+			}
+		})
+	}
+}
+
+func TestLimitExifSize_Nested(t *testing.T) {
+	exif := ExifMetadata{
+		"SourceFile": "a.jpg",
+		"EXIF": map[string]interface{}{
+			"Make":           "Canon",
+			"ThumbnailImage": "base64:" + strings.Repeat("A", 500),
+		},
+	}
+
+	dropped := limitExifSize(exif, 100)
+	assert.Equal(t, []string{"EXIF:ThumbnailImage"}, dropped)
+	assert.Equal(t, map[string]interface{}{"Make": "Canon"}, exif["EXIF"])
+}
@@ -0,0 +1,324 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+// Names of the entries of an evidence bundle.
+const (
+	BundleManifest  = "manifest.json"
+	BundleMetadata  = "metadata.json"
+	BundleSignature = "manifest.sig"
+	BundlePublicKey = "signer.pem"
+	BundleFileDir   = "evidence"
+)
+
+var (
+	// ErrBundleTampered is returned by VerifyBundle when an entry of the
+	// bundle does not match the manifest.
+	ErrBundleTampered = errors.New("bundle entry does not match manifest")
+
+	// ErrBadSignature is returned by VerifyBundle when the signature of the
+	// manifest is invalid.
+	ErrBadSignature = errors.New("invalid bundle signature")
+)
+
+// Examiner identifies the person producing an evidence bundle.
+type Examiner struct {
+	Name         string `json:"name,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+}
+
+// BundleOptions configures ExportBundle.
+type BundleOptions struct {
+	// Examiner identifies the person producing the bundle.
+	Examiner Examiner
+
+	// ToolVersions are the versions of the tools used for the extraction
+	// (e.g., {"trid": "2.24"}). The ExifTool version is taken from the
+	// metadata if present.
+	ToolVersions map[string]string
+
+	// Notes are free-form notes of the examiner.
+	Notes string
+
+	// IncludeFile adds a copy of the file to the bundle under BundleFileDir.
+	IncludeFile bool
+
+	// Signer signs the manifest. Ed25519, ECDSA and RSA (PKCS #1 v1.5) keys
+	// are supported. The bundle is not signed if it is nil.
+	Signer crypto.Signer
+
+	// Time is the creation time recorded in the manifest. Defaults to the
+	// current time.
+	Time time.Time
+}
+
+// Manifest describes the contents of an evidence bundle.
+type Manifest struct {
+	Created      time.Time         `json:"created"`
+	Path         string            `json:"path"`
+	Size         int64             `json:"size"`
+	Hashes       map[string]string `json:"hashes"`
+	Examiner     Examiner          `json:"examiner"`
+	ToolVersions map[string]string `json:"tool_versions,omitempty"`
+	Notes        string            `json:"notes,omitempty"`
+	Entries      []ManifestEntry   `json:"entries"`
+}
+
+// ManifestEntry records an entry of an evidence bundle.
+type ManifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ExportBundle writes an evidence bundle of the file to w: a ZIP archive
+// holding the metadata, optionally the file itself, and a manifest listing
+// the SHA-256 digest of every entry together with the file hashes, the tool
+// versions and the examiner. If a signer is configured, the manifest is
+// signed.
+func ExportBundle(w io.Writer, filePath string, metadata Metadata, opts BundleOptions) error {
+	manifest := Manifest{
+		Created:      opts.Time,
+		Path:         filePath,
+		Size:         metadata.Size,
+		Hashes:       make(map[string]string, len(metadata.Hashes)+1),
+		Examiner:     opts.Examiner,
+		ToolVersions: make(map[string]string, len(opts.ToolVersions)+1),
+		Notes:        opts.Notes,
+	}
+	if manifest.Created.IsZero() {
+		manifest.Created = time.Now()
+	}
+
+	for k, v := range metadata.Hashes {
+		manifest.Hashes[k] = v
+	}
+	if _, ok := manifest.Hashes["sha256"]; !ok {
+		digest, err := fileSHA256(filePath)
+		if err != nil {
+			return fmt.Errorf("error hashing file: %w", err)
+		}
+		manifest.Hashes["sha256"] = digest
+	}
+
+	if version := stringValue(exifValue(metadata.Exif, "ExifToolVersion")); version != "" {
+		manifest.ToolVersions["exiftool"] = version
+	}
+	for k, v := range opts.ToolVersions {
+		manifest.ToolVersions[k] = v
+	}
+
+	zw := zip.NewWriter(w)
+
+	add := func(name string, r io.Reader) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.Created})
+		if err != nil {
+			return err
+		}
+
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(fw, h), r)
+		if err != nil {
+			return err
+		}
+
+		manifest.Entries = append(manifest.Entries, ManifestEntry{Name: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))})
+		return nil
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding metadata: %w", err)
+	}
+	if err := add(BundleMetadata, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+
+	if opts.IncludeFile {
+		f, err := os.Open(longPath(filePath))
+		if err != nil {
+			return fmt.Errorf("error reading file: %w", err)
+		}
+		err = add(path.Join(BundleFileDir, metadata.Name), f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("error writing bundle: %w", err)
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+
+	entries := map[string][]byte{BundleManifest: manifestData}
+
+	if opts.Signer != nil {
+		signature, err := signManifest(opts.Signer, manifestData)
+		if err != nil {
+			return fmt.Errorf("error signing manifest: %w", err)
+		}
+
+		der, err := x509.MarshalPKIXPublicKey(opts.Signer.Public())
+		if err != nil {
+			return fmt.Errorf("error encoding public key: %w", err)
+		}
+
+		entries[BundleSignature] = signature
+		entries[BundlePublicKey] = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+
+	for _, name := range []string{BundleManifest, BundleSignature, BundlePublicKey} {
+		data, ok := entries[name]
+		if !ok {
+			continue
+		}
+
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.Created})
+		if err != nil {
+			return fmt.Errorf("error writing bundle: %w", err)
+		}
+		if _, err := fw.Write(data); err != nil {
+			return fmt.Errorf("error writing bundle: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyBundle checks the entries of an evidence bundle against its manifest
+// and, if the bundle is signed, the signature of the manifest. It returns the
+// manifest. The public key stored in the bundle is used unless a key is
+// given; to establish who signed the bundle, pass the expected key.
+func VerifyBundle(r io.ReaderAt, size int64, publicKey crypto.PublicKey) (*Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundle: %w", err)
+	}
+
+	manifestData, err := readZipEntry(zr, BundleManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("error decoding manifest: %w", err)
+	}
+
+	if signature, err := readZipEntry(zr, BundleSignature); err == nil {
+		if publicKey == nil {
+			pemData, err := readZipEntry(zr, BundlePublicKey)
+			if err != nil {
+				return nil, err
+			}
+
+			block, _ := pem.Decode(pemData)
+			if block == nil {
+				return nil, fmt.Errorf("%w: malformed public key", ErrBadSignature)
+			}
+
+			if publicKey, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrBadSignature, err)
+			}
+		}
+
+		if !verifyManifest(publicKey, manifestData, signature) {
+			return nil, ErrBadSignature
+		}
+	} else if publicKey != nil {
+		return nil, fmt.Errorf("%w: bundle is not signed", ErrBadSignature)
+	}
+
+	for _, entry := range manifest.Entries {
+		data, err := readZipEntry(zr, entry.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != entry.Size || hex.EncodeToString(sum[:]) != entry.SHA256 {
+			return nil, fmt.Errorf("%w: %s", ErrBundleTampered, entry.Name)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// signManifest signs the manifest. Ed25519 keys sign the manifest itself,
+// other keys its SHA-256 digest.
+func signManifest(signer crypto.Signer, manifest []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, manifest, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(manifest)
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// verifyManifest reports whether the signature of the manifest is valid.
+func verifyManifest(publicKey crypto.PublicKey, manifest, signature []byte) bool {
+	digest := sha256.Sum256(manifest)
+
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, manifest, signature)
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	return false
+}
+
+// readZipEntry returns the contents of the named entry.
+func readZipEntry(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundle entry %s: %w", name, err)
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 digest of the file content.
+func fileSHA256(filePath string) (string, error) {
+	f, err := os.Open(longPath(filePath))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
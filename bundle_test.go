@@ -0,0 +1,112 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportBundle(t *testing.T) {
+	path := filepath.Join("testdata", "sample.doc")
+
+	metadata, err := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"md5"}}).Extract(path)
+	require.NoError(t, err)
+	metadata.Exif = ExifMetadata{"ExifToolVersion": 12.76}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	signers := []struct {
+		name   string
+		signer crypto.Signer
+	}{
+		{"Unsigned", nil},
+		{"Ed25519", edKey},
+		{"ECDSA", ecKey},
+		{"RSA", rsaKey},
+	}
+
+	created := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	for _, tc := range signers {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, ExportBundle(&buf, path, metadata, BundleOptions{
+				Examiner:     Examiner{Name: "J. Doe", Organization: "Lab"},
+				ToolVersions: map[string]string{"trid": "2.24"},
+				IncludeFile:  true,
+				Signer:       tc.signer,
+				Time:         created,
+			}))
+
+			manifest, err := VerifyBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, created, manifest.Created)
+			assert.Equal(t, path, manifest.Path)
+			assert.Equal(t, "J. Doe", manifest.Examiner.Name)
+			assert.Equal(t, map[string]string{"exiftool": "12.76", "trid": "2.24"}, manifest.ToolVersions)
+			assert.Len(t, manifest.Hashes["md5"], 32)
+			assert.Len(t, manifest.Hashes["sha256"], 64)
+			require.Len(t, manifest.Entries, 2)
+			assert.Equal(t, BundleMetadata, manifest.Entries[0].Name)
+			assert.Equal(t, "evidence/sample.doc", manifest.Entries[1].Name)
+			assert.Equal(t, manifest.Hashes["sha256"], manifest.Entries[1].SHA256)
+
+			if tc.signer != nil {
+				_, err := VerifyBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()), tc.signer.Public())
+				assert.NoError(t, err)
+
+				otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+				require.NoError(t, err)
+				_, err = VerifyBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()), otherPub)
+				assert.ErrorIs(t, err, ErrBadSignature)
+			}
+		})
+	}
+}
+
+func TestVerifyBundle_Tampered(t *testing.T) {
+	path := filepath.Join("testdata", "sample.mp3")
+
+	var buf bytes.Buffer
+	require.NoError(t, ExportBundle(&buf, path, Metadata{Name: "sample.mp3"}, BundleOptions{}))
+
+	// Rewrite the bundle with modified metadata but the original manifest.
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	var tampered bytes.Buffer
+	zw := zip.NewWriter(&tampered)
+	for _, f := range zr.File {
+		data, err := readZipEntry(zr, f.Name)
+		require.NoError(t, err)
+		if f.Name == BundleMetadata {
+			data = bytes.Replace(data, []byte("sample.mp3"), []byte("other.mp3"), 1)
+		}
+
+		fw, err := zw.Create(f.Name)
+		require.NoError(t, err)
+		_, err = fw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	_, err = VerifyBundle(bytes.NewReader(tampered.Bytes()), int64(tampered.Len()), nil)
+	assert.ErrorIs(t, err, ErrBundleTampered)
+}
@@ -0,0 +1,286 @@
+package metaextractor
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// cfbMagic is the signature of OLE2 compound files (MS-CFB), the container
+// of Outlook messages and legacy Office documents.
+var cfbMagic = []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")
+
+// Special sector numbers.
+const (
+	cfbMaxSector  = 0xfffffffa
+	cfbEndOfChain = 0xfffffffe
+	cfbNoStream   = 0xffffffff
+)
+
+// Directory entry types.
+const (
+	cfbStorage = 1
+	cfbStream  = 2
+)
+
+const (
+	cfbHeaderSize   = 512
+	cfbDirEntrySize = 128
+	cfbHeaderDIFAT  = 109
+)
+
+var errInvalidCFB = errors.New("invalid compound file")
+
+// cfbFile is a compound file opened for reading.
+type cfbFile struct {
+	r          io.ReaderAt
+	size       int64
+	sectorSize int64
+	miniSize   int64
+	miniCutoff uint64
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	entries    []cfbEntry
+}
+
+// cfbEntry is an entry of the directory of a compound file.
+type cfbEntry struct {
+	name  string
+	kind  byte
+	left  uint32
+	right uint32
+	child uint32
+	start uint32
+	size  uint64
+}
+
+// openCFB reads the header, allocation tables and directory of the
+// compound file read from r.
+func openCFB(r io.ReaderAt, size int64) (*cfbFile, error) {
+	header := make([]byte, cfbHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if string(header[:len(cfbMagic)]) != string(cfbMagic) {
+		return nil, errInvalidCFB
+	}
+
+	sectorShift := binary.LittleEndian.Uint16(header[0x1e:])
+	miniShift := binary.LittleEndian.Uint16(header[0x20:])
+	if (sectorShift != 9 && sectorShift != 12) || miniShift != 6 {
+		return nil, errInvalidCFB
+	}
+
+	c := &cfbFile{
+		r:          r,
+		size:       size,
+		sectorSize: 1 << sectorShift,
+		miniSize:   1 << miniShift,
+		miniCutoff: uint64(binary.LittleEndian.Uint32(header[0x38:])),
+	}
+
+	// The sectors of the FAT are listed by the DIFAT: 109 entries in the
+	// header, followed by a chain of DIFAT sectors.
+	numFAT := binary.LittleEndian.Uint32(header[0x2c:])
+	if int64(numFAT) > size/c.sectorSize+1 {
+		return nil, errInvalidCFB
+	}
+
+	difat := make([]uint32, 0, numFAT)
+	for i := 0; i < cfbHeaderDIFAT && uint32(len(difat)) < numFAT; i++ {
+		difat = append(difat, binary.LittleEndian.Uint32(header[0x4c+4*i:]))
+	}
+
+	next := binary.LittleEndian.Uint32(header[0x44:])
+	for visited := 0; uint32(len(difat)) < numFAT; visited++ {
+		if next > cfbMaxSector || int64(visited) > size/c.sectorSize {
+			return nil, errInvalidCFB
+		}
+
+		sector, err := c.sector(next)
+		if err != nil {
+			return nil, err
+		}
+
+		last := len(sector) - 4
+		for i := 0; i < last && uint32(len(difat)) < numFAT; i += 4 {
+			difat = append(difat, binary.LittleEndian.Uint32(sector[i:]))
+		}
+		next = binary.LittleEndian.Uint32(sector[last:])
+	}
+
+	for _, s := range difat {
+		sector, err := c.sector(s)
+		if err != nil {
+			return nil, err
+		}
+		c.fat = append(c.fat, uint32s(sector)...)
+	}
+
+	dir, err := c.readChain(binary.LittleEndian.Uint32(header[0x30:]), c.fat, c.sectorSize, c.sector, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	for off := 0; off+cfbDirEntrySize <= len(dir); off += cfbDirEntrySize {
+		c.entries = append(c.entries, parseCFBEntry(dir[off:off+cfbDirEntrySize]))
+	}
+	if len(c.entries) == 0 {
+		return nil, errInvalidCFB
+	}
+
+	if first := binary.LittleEndian.Uint32(header[0x3c:]); first <= cfbMaxSector {
+		miniFAT, err := c.readChain(first, c.fat, c.sectorSize, c.sector, -1)
+		if err != nil {
+			return nil, err
+		}
+		c.miniFAT = uint32s(miniFAT)
+	}
+
+	// The mini stream holding the small streams is the stream of the root
+	// entry.
+	root := c.entries[0]
+	if root.start <= cfbMaxSector {
+		if c.miniStream, err = c.readChain(root.start, c.fat, c.sectorSize, c.sector, int64(root.size)); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// parseCFBEntry parses a directory entry.
+func parseCFBEntry(b []byte) cfbEntry {
+	nameLen := int(binary.LittleEndian.Uint16(b[64:]))
+	if nameLen > 64 {
+		nameLen = 64
+	}
+
+	return cfbEntry{
+		name:  decodeUTF16(b[:nameLen]),
+		kind:  b[66],
+		left:  binary.LittleEndian.Uint32(b[68:]),
+		right: binary.LittleEndian.Uint32(b[72:]),
+		child: binary.LittleEndian.Uint32(b[76:]),
+		start: binary.LittleEndian.Uint32(b[116:]),
+		size:  binary.LittleEndian.Uint64(b[120:]),
+	}
+}
+
+// sector returns the content of the given sector.
+func (c *cfbFile) sector(n uint32) ([]byte, error) {
+	off := (int64(n) + 1) * c.sectorSize
+	if n > cfbMaxSector || off+c.sectorSize > c.size {
+		return nil, errInvalidCFB
+	}
+
+	b := make([]byte, c.sectorSize)
+	if _, err := c.r.ReadAt(b, off); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// miniSector returns the content of the given sector of the mini stream.
+func (c *cfbFile) miniSector(n uint32) ([]byte, error) {
+	off := int64(n) * c.miniSize
+	if off+c.miniSize > int64(len(c.miniStream)) {
+		return nil, errInvalidCFB
+	}
+
+	return c.miniStream[off : off+c.miniSize], nil
+}
+
+// readChain reads the chain of sectors starting at start, following the
+// given allocation table, and truncates it to size unless size is negative.
+func (c *cfbFile) readChain(start uint32, table []uint32, sectorSize int64, read func(uint32) ([]byte, error), size int64) ([]byte, error) {
+	var data []byte
+	for n := start; n != cfbEndOfChain; n = table[n] {
+		if size >= 0 && int64(len(data)) >= size {
+			break
+		}
+		if int(n) >= len(table) || int64(len(data)) > c.size {
+			return nil, errInvalidCFB
+		}
+
+		sector, err := read(n)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, sector...)
+	}
+
+	if size >= 0 {
+		if int64(len(data)) < size {
+			return nil, errInvalidCFB
+		}
+		data = data[:size]
+	}
+
+	return data, nil
+}
+
+// stream returns the content of the stream entry.
+func (c *cfbFile) stream(e cfbEntry) ([]byte, error) {
+	if e.size == 0 {
+		return []byte{}, nil
+	}
+	if e.size > uint64(c.size) {
+		return nil, errInvalidCFB
+	}
+
+	if e.size < c.miniCutoff {
+		return c.readChain(e.start, c.miniFAT, c.miniSize, c.miniSector, int64(e.size))
+	}
+
+	return c.readChain(e.start, c.fat, c.sectorSize, c.sector, int64(e.size))
+}
+
+// children returns the IDs of the entries of the storage with the given ID,
+// collected from the tree of its siblings.
+func (c *cfbFile) children(id uint32) []uint32 {
+	var (
+		ids     []uint32
+		visited = make(map[uint32]bool)
+		walk    func(uint32)
+	)
+
+	walk = func(n uint32) {
+		if n == cfbNoStream || int(n) >= len(c.entries) || visited[n] {
+			return
+		}
+		visited[n] = true
+
+		walk(c.entries[n].left)
+		ids = append(ids, n)
+		walk(c.entries[n].right)
+	}
+	walk(c.entries[id].child)
+
+	return ids
+}
+
+// uint32s decodes a sector of little-endian 32-bit values.
+func uint32s(b []byte) []uint32 {
+	values := make([]uint32, len(b)/4)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+
+	return values
+}
+
+// decodeUTF16 decodes a little-endian UTF-16 string, dropping the
+// terminating null characters.
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
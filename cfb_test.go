@@ -0,0 +1,226 @@
+package metaextractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cfbNode is an entry of a compound file built by cfbBytes.
+type cfbNode struct {
+	name     string
+	data     []byte
+	children []cfbNode
+}
+
+// cfbBytes returns a version 3 compound file with the given entries below
+// the root storage. Nodes with children are storages, others are streams;
+// streams smaller than 4096 bytes are stored in the mini stream.
+func cfbBytes(t *testing.T, nodes []cfbNode) []byte {
+	t.Helper()
+
+	type dirEntry struct {
+		name                      string
+		kind                      byte
+		left, right, child, start uint32
+		size                      uint64
+	}
+
+	var (
+		dir        []dirEntry
+		miniStream []byte
+		miniFAT    []uint32
+		big        [][]byte
+		bigIdx     []int
+	)
+
+	var add func(name string, kind byte, node cfbNode) uint32
+	add = func(name string, kind byte, node cfbNode) uint32 {
+		id := uint32(len(dir))
+		dir = append(dir, dirEntry{name: name, kind: kind, left: cfbNoStream, right: cfbNoStream, child: cfbNoStream})
+
+		switch {
+		case kind == cfbStream && len(node.data) < 4096:
+			start := uint32(len(miniStream) / 64)
+			miniStream = append(miniStream, node.data...)
+			for len(miniStream)%64 != 0 {
+				miniStream = append(miniStream, 0)
+			}
+			for n := start; n < uint32(len(miniStream)/64); n++ {
+				miniFAT = append(miniFAT, n+1)
+			}
+			if len(node.data) > 0 {
+				miniFAT[len(miniFAT)-1] = cfbEndOfChain
+			} else {
+				start = cfbEndOfChain
+			}
+			dir[id].start, dir[id].size = start, uint64(len(node.data))
+		case kind == cfbStream:
+			big = append(big, node.data)
+			bigIdx = append(bigIdx, int(id))
+			dir[id].size = uint64(len(node.data))
+		}
+
+		// Children are linked as a chain of right siblings.
+		prev := uint32(cfbNoStream)
+		for _, child := range node.children {
+			childKind := byte(cfbStream)
+			if child.children != nil {
+				childKind = cfbStorage
+			}
+
+			childID := add(child.name, childKind, child)
+			if prev == cfbNoStream {
+				dir[id].child = childID
+			} else {
+				dir[prev].right = childID
+			}
+			prev = childID
+		}
+
+		return id
+	}
+	add("Root Entry", 5, cfbNode{children: nodes})
+
+	sectors := func(n int) int { return (n + 511) / 512 }
+
+	// Layout: FAT, directory, mini FAT, mini stream, large streams.
+	var fat []uint32
+	chain := func(n int) uint32 {
+		if n == 0 {
+			return cfbEndOfChain
+		}
+		start := uint32(len(fat))
+		for i := 0; i < n; i++ {
+			fat = append(fat, uint32(len(fat))+1)
+		}
+		fat[len(fat)-1] = cfbEndOfChain
+		return start
+	}
+
+	fat = append(fat, 0xfffffffd)
+	dirStart := chain(sectors(len(dir) * cfbDirEntrySize))
+	miniFATStart := chain(sectors(len(miniFAT) * 4))
+	dir[0].start = chain(sectors(len(miniStream)))
+	dir[0].size = uint64(len(miniStream))
+	for i, data := range big {
+		dir[bigIdx[i]].start = chain(sectors(len(data)))
+	}
+	require.LessOrEqual(t, len(fat), 128, "the FAT must fit in one sector")
+	for len(fat) < 128 {
+		fat = append(fat, cfbNoStream)
+	}
+
+	var out bytes.Buffer
+	header := make([]byte, cfbHeaderSize)
+	copy(header, cfbMagic)
+	binary.LittleEndian.PutUint16(header[0x18:], 0x3e)
+	binary.LittleEndian.PutUint16(header[0x1a:], 3)
+	binary.LittleEndian.PutUint16(header[0x1c:], 0xfffe)
+	binary.LittleEndian.PutUint16(header[0x1e:], 9)
+	binary.LittleEndian.PutUint16(header[0x20:], 6)
+	binary.LittleEndian.PutUint32(header[0x2c:], 1)
+	binary.LittleEndian.PutUint32(header[0x30:], dirStart)
+	binary.LittleEndian.PutUint32(header[0x38:], 4096)
+	binary.LittleEndian.PutUint32(header[0x3c:], miniFATStart)
+	binary.LittleEndian.PutUint32(header[0x40:], uint32(sectors(len(miniFAT)*4)))
+	binary.LittleEndian.PutUint32(header[0x44:], cfbEndOfChain)
+	for i := 0; i < cfbHeaderDIFAT; i++ {
+		binary.LittleEndian.PutUint32(header[0x4c+4*i:], cfbNoStream)
+	}
+	binary.LittleEndian.PutUint32(header[0x4c:], 0)
+	out.Write(header)
+
+	pad := func() {
+		for out.Len()%512 != 0 {
+			out.WriteByte(0)
+		}
+	}
+
+	binary.Write(&out, binary.LittleEndian, fat)
+
+	for _, e := range dir {
+		b := make([]byte, cfbDirEntrySize)
+		name := utf16.Encode([]rune(e.name + "\x00"))
+		for i, u := range name {
+			binary.LittleEndian.PutUint16(b[2*i:], u)
+		}
+		binary.LittleEndian.PutUint16(b[64:], uint16(2*len(name)))
+		b[66] = e.kind
+		binary.LittleEndian.PutUint32(b[68:], e.left)
+		binary.LittleEndian.PutUint32(b[72:], e.right)
+		binary.LittleEndian.PutUint32(b[76:], e.child)
+		binary.LittleEndian.PutUint32(b[116:], e.start)
+		binary.LittleEndian.PutUint64(b[120:], e.size)
+		out.Write(b)
+	}
+	pad()
+
+	binary.Write(&out, binary.LittleEndian, miniFAT)
+	pad()
+	out.Write(miniStream)
+	pad()
+	for _, data := range big {
+		out.Write(data)
+		pad()
+	}
+
+	return out.Bytes()
+}
+
+func TestOpenCFB(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	data := cfbBytes(t, []cfbNode{
+		{name: "small", data: []byte("hello")},
+		{name: "storage", children: []cfbNode{
+			{name: "large", data: large},
+			{name: "empty", data: []byte{}},
+		}},
+	})
+
+	c, err := openCFB(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	names := func(ids []uint32) []string {
+		var s []string
+		for _, id := range ids {
+			s = append(s, c.entries[id].name)
+		}
+		return s
+	}
+
+	root := c.children(0)
+	require.Equal(t, []string{"small", "storage"}, names(root))
+
+	b, err := c.stream(c.entries[root[0]])
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), b)
+
+	inner := c.children(root[1])
+	require.Equal(t, []string{"large", "empty"}, names(inner))
+
+	b, err = c.stream(c.entries[inner[0]])
+	require.NoError(t, err)
+	assert.Equal(t, large, b)
+
+	b, err = c.stream(c.entries[inner[1]])
+	require.NoError(t, err)
+	assert.Empty(t, b)
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := openCFB(bytes.NewReader(make([]byte, 512)), 512)
+		assert.ErrorIs(t, err, errInvalidCFB)
+
+		_, err = openCFB(bytes.NewReader(data[:600]), 600)
+		assert.Error(t, err)
+
+		corrupt := bytes.Clone(data)
+		binary.LittleEndian.PutUint32(corrupt[0x30:], 1000)
+		_, err = openCFB(bytes.NewReader(corrupt), int64(len(corrupt)))
+		assert.Error(t, err)
+	})
+}
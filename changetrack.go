@@ -0,0 +1,78 @@
+package metaextractor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+// ChangeTracking identifies a file and its last change in the NTFS change
+// journal (USN journal), so that repeated scans can read the journal deltas
+// since the recorded USN instead of walking the whole tree again.
+type ChangeTracking struct {
+	// VolumeSerial is the serial number of the volume holding the file.
+	VolumeSerial uint64 `json:"volume_serial"`
+
+	// FileID is the file reference number, which identifies the file on
+	// the volume across renames.
+	FileID FileID `json:"file_id"`
+
+	// USN is the update sequence number of the last change of the file. It
+	// is zero if the volume has no change journal.
+	USN int64 `json:"usn"`
+
+	// JournalID identifies the instance of the change journal. USNs are
+	// only comparable within the same journal. It is zero if the journal
+	// could not be queried, which usually requires administrator rights.
+	JournalID uint64 `json:"journal_id"`
+}
+
+// FileID is a 128-bit file identifier (FILE_ID_128), as used by ReFS; the
+// 64-bit file reference numbers of NTFS take its low 64 bits. The bytes are
+// in little-endian order. It is encoded as 32 hexadecimal digits, most
+// significant first.
+type FileID [16]byte
+
+// String returns the identifier as 32 hexadecimal digits.
+func (id FileID) String() string {
+	b := id
+	slices.Reverse(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// MarshalText encodes the identifier as 32 hexadecimal digits.
+func (id FileID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText decodes an identifier encoded by MarshalText.
+func (id *FileID) UnmarshalText(text []byte) error {
+	var b FileID
+	if n, err := hex.Decode(b[:], text); err != nil || n != len(b) || len(text) != 2*len(b) {
+		return fmt.Errorf("invalid file ID %q", text)
+	}
+
+	slices.Reverse(b[:])
+	*id = b
+	return nil
+}
+
+// parseUsnRecord returns the USN of a USN_RECORD_V2 or USN_RECORD_V3 (which
+// has 128-bit file reference numbers).
+func parseUsnRecord(record []byte) int64 {
+	if len(record) < 8 {
+		return 0
+	}
+
+	offset := 24
+	if binary.LittleEndian.Uint16(record[4:]) >= 3 {
+		offset = 40
+	}
+
+	if len(record) < offset+8 {
+		return 0
+	}
+
+	return int64(binary.LittleEndian.Uint64(record[offset:]))
+}
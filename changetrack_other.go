@@ -0,0 +1,9 @@
+//go:build !windows
+
+package metaextractor
+
+// readChangeTracking returns nil; the NTFS change journal only exists on
+// Windows.
+func readChangeTracking(filePath string) (*ChangeTracking, error) {
+	return nil, nil
+}
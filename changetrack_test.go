@@ -0,0 +1,58 @@
+package metaextractor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUsnRecord(t *testing.T) {
+	v2 := make([]byte, 64)
+	binary.LittleEndian.PutUint16(v2[4:], 2)
+	binary.LittleEndian.PutUint64(v2[24:], 12345)
+
+	v3 := make([]byte, 80)
+	binary.LittleEndian.PutUint16(v3[4:], 3)
+	binary.LittleEndian.PutUint64(v3[40:], 67890)
+
+	assert.Equal(t, int64(12345), parseUsnRecord(v2))
+	assert.Equal(t, int64(67890), parseUsnRecord(v3))
+	assert.Zero(t, parseUsnRecord(v2[:16]))
+	assert.Zero(t, parseUsnRecord(nil))
+}
+
+func TestFileID(t *testing.T) {
+	var id FileID
+	binary.LittleEndian.PutUint64(id[:], 0x0001000000000abc)
+	id[15] = 0x80
+
+	text, err := json.Marshal(id)
+	require.NoError(t, err)
+	assert.Equal(t, `"80000000000000000001000000000abc"`, string(text))
+
+	var decoded FileID
+	require.NoError(t, json.Unmarshal(text, &decoded))
+	assert.Equal(t, id, decoded)
+
+	assert.Error(t, decoded.UnmarshalText([]byte("abc")))
+}
+
+func TestMetaExtractor_ChangeTracking(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true, ChangeTracking: true})
+
+	metadata, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+
+	if runtime.GOOS != "windows" {
+		assert.Nil(t, metadata.ChangeTracking)
+		return
+	}
+
+	require.NotNil(t, metadata.ChangeTracking)
+	assert.NotZero(t, metadata.ChangeTracking.FileID)
+}
@@ -0,0 +1,152 @@
+//go:build windows
+
+package metaextractor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	fsctlReadFileUsnData = 0x000900eb
+	fsctlQueryUsnJournal = 0x000900f4
+	fileReadAttributes   = 0x0080
+)
+
+// fileIDInfo is the FILE_ID_INFO structure.
+type fileIDInfo struct {
+	VolumeSerialNumber uint64
+	FileID             FileID
+}
+
+// readChangeTracking reads the file ID and the USN of the file, and the ID
+// of the change journal of its volume.
+func readChangeTracking(filePath string) (*ChangeTracking, error) {
+	h, err := openForQuery(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading change tracking: %w", err)
+	}
+	defer syscall.CloseHandle(h)
+
+	ct, err := readFileID(h)
+	if err != nil {
+		return nil, fmt.Errorf("error reading change tracking: %w", err)
+	}
+
+	// Volumes without a change journal (e.g., FAT) fail the query; the USN
+	// is left zero for them.
+	var buf [1024]byte
+	var n uint32
+	if err := syscall.DeviceIoControl(h, fsctlReadFileUsnData, nil, 0, &buf[0], uint32(len(buf)), &n, nil); err == nil {
+		ct.USN = parseUsnRecord(buf[:n])
+	}
+
+	if ct.JournalID, err = queryJournalID(filePath); err != nil {
+		return nil, fmt.Errorf("error reading change tracking: %w", err)
+	}
+
+	return ct, nil
+}
+
+// readFileID reads the 128-bit file ID and the volume serial number of the
+// file. File systems not supporting FILE_ID_INFO report the 64-bit file
+// index instead.
+func readFileID(h syscall.Handle) (*ChangeTracking, error) {
+	var idInfo fileIDInfo
+	if err := windows.GetFileInformationByHandleEx(windows.Handle(h), windows.FileIdInfo, (*byte)(unsafe.Pointer(&idInfo)), uint32(unsafe.Sizeof(idInfo))); err == nil {
+		return &ChangeTracking{VolumeSerial: idInfo.VolumeSerialNumber, FileID: idInfo.FileID}, nil
+	}
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &info); err != nil {
+		return nil, err
+	}
+
+	ct := &ChangeTracking{VolumeSerial: uint64(info.VolumeSerialNumber)}
+	binary.LittleEndian.PutUint32(ct.FileID[0:], info.FileIndexLow)
+	binary.LittleEndian.PutUint32(ct.FileID[4:], info.FileIndexHigh)
+
+	return ct, nil
+}
+
+// openForQuery opens the file (or directory) for querying its attributes
+// without preventing other processes from using it.
+func openForQuery(filePath string) (syscall.Handle, error) {
+	p, err := syscall.UTF16PtrFromString(filePath)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+
+	return syscall.CreateFile(p, fileReadAttributes,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+}
+
+// queryJournalID returns the ID of the change journal of the volume holding
+// the file. It is zero for network volumes, volumes without an active
+// journal and if opening the volume is denied, which requires administrator
+// rights.
+func queryJournalID(filePath string) (uint64, error) {
+	volume, err := volumeDevicePath(filePath)
+	if err != nil || volume == "" {
+		return 0, err
+	}
+
+	h, err := openForQuery(volume)
+	if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error opening volume %s: %w", volume, err)
+	}
+	defer syscall.CloseHandle(h)
+
+	// USN_JOURNAL_DATA_V0 starts with the 64-bit journal ID.
+	var data [56]byte
+	var n uint32
+	err = syscall.DeviceIoControl(h, fsctlQueryUsnJournal, nil, 0, &data[0], uint32(len(data)), &n, nil)
+	switch {
+	case errors.Is(err, windows.ERROR_JOURNAL_NOT_ACTIVE), errors.Is(err, windows.ERROR_INVALID_FUNCTION):
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("error querying the change journal of %s: %w", volume, err)
+	case n < 8:
+		return 0, fmt.Errorf("error querying the change journal of %s: short response", volume)
+	}
+
+	return binary.LittleEndian.Uint64(data[:]), nil
+}
+
+// volumeDevicePath returns the device path of the volume holding the file
+// (e.g., `\\?\Volume{...}`), whatever the form of the path: drive letters,
+// long paths, volume GUID paths and mounted folders. It is empty for network
+// volumes (UNC paths and mapped drives), whose journal cannot be queried.
+func volumeDevicePath(filePath string) (string, error) {
+	p, err := windows.UTF16PtrFromString(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	mountPoint := make([]uint16, windows.MAX_LONG_PATH)
+	if err := windows.GetVolumePathName(p, &mountPoint[0], uint32(len(mountPoint))); err != nil {
+		return "", fmt.Errorf("error finding the volume of %s: %w", filePath, err)
+	}
+
+	if windows.GetDriveType(&mountPoint[0]) == windows.DRIVE_REMOTE {
+		return "", nil
+	}
+
+	var name [windows.MAX_PATH]uint16
+	if err := windows.GetVolumeNameForVolumeMountPoint(&mountPoint[0], &name[0], uint32(len(name))); err != nil {
+		return "", fmt.Errorf("error finding the volume of %s: %w", filePath, err)
+	}
+
+	// Volumes are opened without the trailing separator of their name.
+	return strings.TrimSuffix(windows.UTF16ToString(name[:]), `\`), nil
+}
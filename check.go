@@ -0,0 +1,262 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attilabuti/trid"
+)
+
+// ToolStatus is the outcome of checking an external tool (see
+// MetaExtractor.CheckTools).
+type ToolStatus struct {
+	// Name is the name of the tool: "trid", "exiftool", "file", "ffprobe",
+	// "mediainfo" or "fpcalc".
+	Name string
+
+	// Path is the command the tool is run with.
+	Path string
+
+	// Version is the version reported by the tool (e.g., "2.24" for TrID,
+	// "12.76" for ExifTool, "5.44" for file, "6.1.1" for ffprobe, "23.11"
+	// for MediaInfo, "1.5.1" for fpcalc).
+	Version string
+
+	// Definitions is the number of file type definitions loaded by TrID.
+	Definitions int
+
+	// Err is the reason the tool cannot be used, if any.
+	Err error
+}
+
+// defaultTridTimeout is the TrID timeout used if Options.TridTimeout is not
+// set, as in the trid package.
+const defaultTridTimeout = 30 * time.Second
+
+var (
+	reTridVersion     = regexp.MustCompile(`File Identifier v(\d+(?:\.\d+)*)`)
+	reTridDefinitions = regexp.MustCompile(`Definitions found:\s*(\d+)`)
+)
+
+// Check verifies that the external tools the extractor is configured to use
+// (TrID and its definitions, ExifTool, the file command, ffprobe, MediaInfo
+// and fpcalc) are present and runnable, so that a misconfiguration is
+// reported before any extraction rather than as a stage error. It also
+// starts the first ExifTool process.
+// It returns the configuration error of the extractor, if any, or the
+// errors of the tools that cannot be used, joined.
+func (me *MetaExtractor) Check() error {
+	statuses, err := me.CheckTools()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, status := range statuses {
+		if status.Err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", status.Name, status.Path, status.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckTools runs every external tool the extractor is configured to use on
+// a small probe file and reports their versions. Tools disabled by the
+// options (e.g., ExifTool if ExifToolBackend is not among Options.Backends)
+// are not reported, nor are any in pure-Go mode. The error is the
+// configuration error of the extractor, in which case no tool is run.
+func (me *MetaExtractor) CheckTools() ([]ToolStatus, error) {
+	if me.initErr != nil {
+		return nil, me.initErr
+	}
+
+	if me.pureGo {
+		return nil, nil
+	}
+
+	var tridUsed, exifToolUsed, fileUsed, ffprobeUsed, mediaInfoUsed bool
+	for _, b := range me.backends {
+		switch b.(type) {
+		case tridBackend:
+			for _, d := range me.detectors {
+				switch d.(type) {
+				case tridDetector:
+					tridUsed = true
+				case magicDetector:
+					fileUsed = true
+				}
+			}
+		case exifToolBackend:
+			exifToolUsed = !me.skipExif
+		case magicBackend:
+			fileUsed = true
+		case ffprobeBackend:
+			ffprobeUsed = true
+		case mediaInfoBackend:
+			mediaInfoUsed = true
+		}
+	}
+
+	if !tridUsed && !exifToolUsed && !fileUsed && !ffprobeUsed && !mediaInfoUsed && !me.audioFingerprint.Enabled {
+		return nil, nil
+	}
+
+	probe, cleanup, err := me.probeFile()
+	var statuses []ToolStatus
+
+	if tridUsed {
+		status := ToolStatus{Name: "trid", Path: me.tridPath, Err: err}
+		if err == nil {
+			status.Version, status.Definitions, status.Err = checkTrid(me.tridPath, me.tridDefs, me.tridTimeout, probe)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if exifToolUsed {
+		status := ToolStatus{Name: "exiftool", Path: me.exifToolPath, Err: err}
+		if err == nil {
+			status.Version, status.Err = me.checkExifTool(probe)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if fileUsed {
+		status := ToolStatus{Name: "file", Path: me.fileCmd}
+		status.Version, status.Err = checkFileCommand(me.fileCmd)
+		statuses = append(statuses, status)
+	}
+
+	if ffprobeUsed {
+		status := ToolStatus{Name: "ffprobe", Path: me.ffprobeCmd}
+		status.Version, status.Err = checkFFprobe(me.ffprobeCmd)
+		statuses = append(statuses, status)
+	}
+
+	if mediaInfoUsed {
+		status := ToolStatus{Name: "mediainfo", Path: me.mediaInfoCmd}
+		status.Version, status.Err = checkMediaInfo(me.mediaInfoCmd)
+		statuses = append(statuses, status)
+	}
+
+	if me.audioFingerprint.Enabled {
+		status := ToolStatus{Name: "fpcalc", Path: fpcalcPath(me.audioFingerprint)}
+		status.Version, status.Err = checkFpcalc(status.Path)
+		statuses = append(statuses, status)
+	}
+
+	if cleanup != nil {
+		cleanup()
+	}
+
+	return statuses, nil
+}
+
+// probeFile writes the file the tools are checked on, copied into the
+// sandbox if one is configured.
+func (me *MetaExtractor) probeFile() (string, func(), error) {
+	dir, err := os.MkdirTemp("", "metaextractor-check-*")
+	if err != nil {
+		return "", nil, err
+	}
+
+	probe := filepath.Join(dir, "probe.txt")
+	if err := os.WriteFile(probe, []byte("metaextractor\n"), 0o600); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	toolPath, cleanup, err := me.sandboxFile(probe)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	return toolPath, func() {
+		cleanup()
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// checkTrid runs TrID on the probe file and returns its version and the
+// number of definitions it loaded.
+func checkTrid(cmd, defs string, timeout time.Duration, probe string) (string, int, error) {
+	if timeout <= 0 {
+		timeout = defaultTridTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	args := []string{"-n:1"}
+	if defs != "" {
+		args = append(args, "-d:"+defs)
+	}
+	args = append(args, probe)
+
+	out, err := exec.CommandContext(ctx, cmd, args...).CombinedOutput()
+
+	var version string
+	if m := reTridVersion.FindSubmatch(out); m != nil {
+		version = string(m[1])
+	} else if err != nil {
+		return "", 0, err
+	} else {
+		return "", 0, fmt.Errorf("unexpected output: %q", strings.TrimSpace(string(out)))
+	}
+
+	switch {
+	case strings.Contains(string(out), "No definitions available!"):
+		return version, 0, trid.ErrNoDefinitions
+	case strings.Contains(string(out), "is empty!"):
+		return version, 0, trid.ErrEmptyDefPackage
+	}
+
+	var definitions int
+	if m := reTridDefinitions.FindSubmatch(out); m != nil {
+		definitions, _ = strconv.Atoi(string(m[1]))
+	}
+	if definitions == 0 {
+		return version, 0, trid.ErrNoDefinitions
+	}
+
+	return version, definitions, nil
+}
+
+// checkExifTool extracts the metadata of the probe file using a process of
+// the ExifTool pool and returns the version of ExifTool.
+func (me *MetaExtractor) checkExifTool(probe string) (string, error) {
+	exif, err := me.exifTools.extract(context.Background(), probe)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := exif["ExifToolVersion"].(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64), nil
+	}
+
+	return "", errors.New("no version reported")
+}
+
+// checkFileCommand runs the file command and returns its version.
+func checkFileCommand(cmd string) (string, error) {
+	out, err := exec.Command(cmd, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	// The first line is the version (e.g., "file-5.44").
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimPrefix(strings.TrimSpace(line), "file-"), nil
+}
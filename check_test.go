@@ -0,0 +1,86 @@
+//go:build !purego
+
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTrid writes a script printing the banner of TrID, which reports no
+// definitions unless they are given with -d.
+func fakeTrid(t *testing.T) string {
+	t.Helper()
+
+	script := filepath.Join(t.TempDir(), "trid")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo "TrID - File Identifier v2.24 - (C) 2003-16 By M.Pontello"
+case "$2" in
+-d:*) echo "Definitions found:  18420" ;;
+*) echo "No definitions available!"; exit 1 ;;
+esac
+`), 0o755))
+
+	return script
+}
+
+func TestMetaExtractor_Check(t *testing.T) {
+	exifTool, log := fakeExifTool(t)
+	tridPath := fakeTrid(t)
+	fileCmd := fakeFileCommand(t, "text/plain; charset=us-ascii", "???")
+
+	t.Run("Tools", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			TridPath:        tridPath,
+			TridDefs:        "triddefs.trd",
+			ExifToolPath:    exifTool,
+			FileCommandPath: fileCmd,
+			Backends:        append(slices.Clone(DefaultBackends), MagicBackend),
+		})
+		defer me.Close()
+
+		require.NoError(t, me.Check())
+		statuses, err := me.CheckTools()
+		require.NoError(t, err)
+		assert.Equal(t, []ToolStatus{
+			{Name: "trid", Path: tridPath, Version: "2.24", Definitions: 18420},
+			{Name: "exiftool", Path: exifTool, Version: "12.70"},
+			{Name: "file", Path: fileCmd, Version: "5.44"},
+		}, statuses)
+		assert.Equal(t, 1, starts(t, log), "the ExifTool process is kept")
+	})
+
+	t.Run("Misconfigured", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			TridPath:     tridPath,
+			ExifToolPath: filepath.Join(t.TempDir(), "missing"),
+		})
+		defer me.Close()
+
+		err := me.Check()
+		assert.ErrorIs(t, err, trid.ErrNoDefinitions)
+		assert.ErrorContains(t, err, "trid ("+tridPath+")")
+		assert.ErrorContains(t, err, "exiftool (")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		me := NewMetaExtractor(Options{TridPath: "missing", Backends: []Extractor{ExifToolBackend}, ExifToolPath: exifTool})
+		defer me.Close()
+
+		statuses, err := me.CheckTools()
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "exiftool", statuses[0].Name)
+
+		statuses, err = NewMetaExtractor(Options{PureGo: true}).CheckTools()
+		require.NoError(t, err)
+		assert.Empty(t, statuses)
+		assert.ErrorContains(t, NewMetaExtractor(Options{PureGo: true, Hashes: []string{"md4"}}).Check(), "md4")
+	})
+}
@@ -0,0 +1,73 @@
+package metaextractor
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ContentClassifier is an optional stage scoring the content of images and
+// videos for safety, e.g. with a moderation model or service, so that
+// uploads can be screened while they are cataloged. If set in
+// Options.ContentClassifier, it is run on image and video files after the
+// backends, and its scores are set in Metadata.ContentScores.
+type ContentClassifier interface {
+	// Name returns the name of the classifier, used in errors and in the
+	// stages of the audit log.
+	Name() string
+
+	// Classify returns the scores (0-1) of the content by category (e.g.,
+	// "nsfw", "violence"). It should return ctx.Err() when ctx is done.
+	Classify(ctx context.Context, filePath string) (map[string]float64, error)
+}
+
+// ContentClassifierFunc adapts an ordinary function to the
+// ContentClassifier interface.
+type ContentClassifierFunc struct {
+	// ClassifierName is the name returned by Name.
+	ClassifierName string
+
+	// Fn is the function invoked by Classify.
+	Fn func(ctx context.Context, filePath string) (map[string]float64, error)
+}
+
+// Name returns the name of the classifier.
+func (cf ContentClassifierFunc) Name() string {
+	return cf.ClassifierName
+}
+
+// Classify calls cf.Fn(ctx, filePath).
+func (cf ContentClassifierFunc) Classify(ctx context.Context, filePath string) (map[string]float64, error) {
+	return cf.Fn(ctx, filePath)
+}
+
+// classifyStage runs the content classifier on images and videos and sets
+// Metadata.ContentScores.
+func (me *MetaExtractor) classifyStage(ctx context.Context, toolPath string, metadata *Metadata, trace *stageTrace) error {
+	if me.contentClassifier == nil || toolPath == "" {
+		return nil
+	}
+
+	if kind := mimeTypeKind(metadata.MimeType); kind != KindImage && kind != KindVideo {
+		return nil
+	}
+
+	start := time.Now()
+	scores, err := me.contentClassifier.Classify(ctx, toolPath)
+	trace.done("classify:"+me.contentClassifier.Name(), start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error running content classifier %q: %w", me.contentClassifier.Name(), err))
+	}
+
+	metadata.ContentScores = scores
+	if len(scores) > 0 {
+		metadata.setSource("ContentScores", me.contentClassifier.Name())
+	}
+
+	return nil
+}
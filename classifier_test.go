@@ -0,0 +1,75 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaExtractor_ContentClassifier(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))))
+	img := filepath.Join(dir, "upload.png")
+	require.NoError(t, os.WriteFile(img, buf.Bytes(), 0o644))
+
+	text := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(text, []byte("notes\n"), 0o644))
+
+	var files []string
+	classifier := ContentClassifierFunc{
+		ClassifierName: "moderation",
+		Fn: func(ctx context.Context, filePath string) (map[string]float64, error) {
+			files = append(files, filePath)
+			return map[string]float64{"nsfw": 0.93, "violence": 0.01}, nil
+		},
+	}
+
+	t.Run("Scores", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			PureGo:            true,
+			Provenance:        true,
+			ContentClassifier: classifier,
+			Rules:             []Rule{{Label: "review", Condition: `ContentScores["nsfw"] > 0.8`}},
+		})
+
+		metadata, err := me.Extract(img)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]float64{"nsfw": 0.93, "violence": 0.01}, metadata.ContentScores)
+		assert.Equal(t, "moderation", metadata.Provenance["ContentScores"])
+		assert.Equal(t, []string{"review"}, metadata.Labels)
+
+		metadata, err = me.Extract(text)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.ContentScores)
+		assert.Empty(t, metadata.Labels)
+
+		assert.Equal(t, []string{img}, files)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		failing := ContentClassifierFunc{
+			ClassifierName: "moderation",
+			Fn: func(ctx context.Context, filePath string) (map[string]float64, error) {
+				return nil, errors.New("service unavailable")
+			},
+		}
+
+		_, err := NewMetaExtractor(Options{PureGo: true, ContentClassifier: failing}).Extract(img)
+		assert.ErrorContains(t, err, `error running content classifier "moderation": service unavailable`)
+
+		metadata, err := NewMetaExtractor(Options{PureGo: true, BestEffort: true, ContentClassifier: failing}).Extract(img)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.ContentScores)
+		assert.Len(t, metadata.Warnings, 1)
+	})
+}
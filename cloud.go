@@ -0,0 +1,460 @@
+package metaextractor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default API endpoints of the cloud drive connectors.
+const (
+	DefaultGoogleDriveURL = "https://www.googleapis.com/drive/v3"
+	DefaultOneDriveURL    = "https://graph.microsoft.com/v1.0/me/drive"
+)
+
+// CloudFile describes a file of a cloud drive, as reported by the provider.
+type CloudFile struct {
+	// Provider is the name of the connector (e.g., "gdrive" or "onedrive").
+	Provider string `json:"provider"`
+
+	// ID identifies the file at the provider.
+	ID string `json:"id,omitempty"`
+
+	// Path is the slash-separated path of the file in the drive, starting
+	// with a slash (e.g., "/Photos/photo.jpg").
+	Path string `json:"path"`
+
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+
+	// ModTime is the last modification time of the file.
+	ModTime time.Time `json:"mod_time,omitempty"`
+
+	// MimeType is the MIME type reported by the provider.
+	MimeType string `json:"mime_type,omitempty"`
+
+	// Owners are the e-mail addresses (or names) of the owners of the file.
+	Owners []string `json:"owners,omitempty"`
+
+	// SharedWith are the e-mail addresses of the users and groups the file
+	// is shared with.
+	SharedWith []string `json:"shared_with,omitempty"`
+
+	// Links are the links through which the file is shared with anyone or
+	// with the whole organization.
+	Links []string `json:"links,omitempty"`
+
+	// WebURL is the URL of the file in the web interface of the provider.
+	WebURL string `json:"web_url,omitempty"`
+}
+
+// CloudConnector enumerates and downloads the files of a cloud drive.
+type CloudConnector interface {
+	// List calls fn for every file of the drive. It stops and returns the
+	// error if fn returns one.
+	List(ctx context.Context, fn func(CloudFile) error) error
+
+	// Open returns the content of the file.
+	Open(ctx context.Context, file CloudFile) (io.ReadCloser, error)
+}
+
+// ExtractCloud enumerates the files of a cloud drive through its API,
+// downloads the files accepted by the walk options one at a time and
+// extracts their metadata. The metadata reported by the provider (owners,
+// sharing links etc.) is merged into Metadata.Cloud. Result paths are of the
+// form "provider:path" (e.g., "onedrive:/Documents/report.pdf").
+//
+// Of the walk options, the filters and Archives are honored; the filters
+// are matched against the path in the drive.
+func (me *MetaExtractor) ExtractCloud(ctx context.Context, c CloudConnector, opts WalkOptions) ([]Result, error) {
+	var results []Result
+	emit := func(r Result) {
+		results = append(results, r)
+	}
+
+	err := c.List(ctx, func(file CloudFile) error {
+		p := file.Provider + ":" + file.Path
+		rel := strings.TrimPrefix(file.Path, "/")
+		info := cloudFileInfo{file}
+
+		accepted := opts.accept(rel, info)
+		descend := opts.Archives && !matchAny(opts.Exclude, rel)
+		if !accepted && !descend {
+			return nil
+		}
+
+		rc, err := c.Open(ctx, file)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			emit(Result{Path: p, Err: err})
+			return nil
+		}
+
+		accept := func(entryPath string, info fs.FileInfo) bool {
+			return opts.accept(strings.TrimPrefix(strings.TrimPrefix(entryPath, file.Provider+":"), "/"), info)
+		}
+		err = me.extractStream(ctx, rc, info.Name(), p, info, accepted, descend, accept, func(r Result) {
+			if r.Path == p {
+				cloud := file
+				r.Metadata.Cloud = &cloud
+			}
+			emit(r)
+		})
+		rc.Close()
+
+		if err != nil {
+			emit(Result{Path: p, Err: err})
+		}
+
+		return ctx.Err()
+	})
+
+	me.stampResults(results)
+
+	return results, err
+}
+
+// cloudFileInfo adapts a CloudFile to fs.FileInfo.
+type cloudFileInfo struct {
+	file CloudFile
+}
+
+func (fi cloudFileInfo) Name() string       { return path.Base(fi.file.Path) }
+func (fi cloudFileInfo) Size() int64        { return fi.file.Size }
+func (fi cloudFileInfo) Mode() fs.FileMode  { return 0o444 }
+func (fi cloudFileInfo) ModTime() time.Time { return fi.file.ModTime }
+func (fi cloudFileInfo) IsDir() bool        { return false }
+func (fi cloudFileInfo) Sys() interface{}   { return fi.file }
+
+// cloudGet sends a GET request and returns the response if its status is
+// 200 OK.
+func cloudGet(ctx context.Context, client *http.Client, rawURL string) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("cloud request failed: %s: %w", resp.Status, fs.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("cloud request failed: %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// cloudGetJSON sends a GET request and decodes the JSON response into v.
+func cloudGetJSON(ctx context.Context, client *http.Client, rawURL string, v interface{}) error {
+	resp, err := cloudGet(ctx, client, rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding cloud response: %w", err)
+	}
+
+	return nil
+}
+
+// GoogleDrive is a CloudConnector for Google Drive (API v3).
+type GoogleDrive struct {
+	// Client sends the requests. It must authorize them, e.g. an OAuth 2.0
+	// client with the drive.readonly scope. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// BaseURL is the API endpoint. Defaults to DefaultGoogleDriveURL.
+	BaseURL string
+
+	// Query is an optional search query restricting the files (e.g.,
+	// "'<folder ID>' in parents"). Trashed files are always excluded.
+	Query string
+}
+
+// googleFields are the file fields requested from Google Drive.
+const googleFields = "nextPageToken,files(id,name,parents,mimeType,size,modifiedTime," +
+	"owners(emailAddress,displayName),permissions(type,role,emailAddress),webViewLink)"
+
+// List calls fn for every file of the drive with binary content. Google
+// Docs, Sheets etc. and folders are skipped. Paths are built from the
+// folders of the files, each fetched once.
+func (g GoogleDrive) List(ctx context.Context, fn func(CloudFile) error) error {
+	folders := make(map[string]string)
+
+	query := "trashed = false"
+	if g.Query != "" {
+		query = "(" + g.Query + ") and " + query
+	}
+
+	pageToken := ""
+	for {
+		params := url.Values{
+			"q":        {query},
+			"fields":   {googleFields},
+			"pageSize": {"1000"},
+		}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			NextPageToken string `json:"nextPageToken"`
+			Files         []struct {
+				ID           string    `json:"id"`
+				Name         string    `json:"name"`
+				Parents      []string  `json:"parents"`
+				MimeType     string    `json:"mimeType"`
+				Size         string    `json:"size"`
+				ModifiedTime time.Time `json:"modifiedTime"`
+				WebViewLink  string    `json:"webViewLink"`
+				Owners       []struct {
+					EmailAddress string `json:"emailAddress"`
+					DisplayName  string `json:"displayName"`
+				} `json:"owners"`
+				Permissions []struct {
+					Type         string `json:"type"`
+					Role         string `json:"role"`
+					EmailAddress string `json:"emailAddress"`
+				} `json:"permissions"`
+			} `json:"files"`
+		}
+		if err := cloudGetJSON(ctx, g.Client, g.baseURL()+"/files?"+params.Encode(), &page); err != nil {
+			return err
+		}
+
+		for _, f := range page.Files {
+			// Native Google documents and folders have no binary content.
+			if strings.HasPrefix(f.MimeType, "application/vnd.google-apps.") {
+				continue
+			}
+
+			dir := "/"
+			if len(f.Parents) > 0 {
+				var err error
+				if dir, err = g.folderPath(ctx, folders, f.Parents[0]); err != nil {
+					return err
+				}
+			}
+
+			file := CloudFile{
+				Provider: "gdrive",
+				ID:       f.ID,
+				Path:     path.Join(dir, f.Name),
+				ModTime:  f.ModifiedTime,
+				MimeType: f.MimeType,
+				WebURL:   f.WebViewLink,
+			}
+			file.Size, _ = strconv.ParseInt(f.Size, 10, 64)
+
+			for _, owner := range f.Owners {
+				file.Owners = append(file.Owners, firstNonEmpty(owner.EmailAddress, owner.DisplayName))
+			}
+
+			for _, perm := range f.Permissions {
+				switch {
+				case perm.Type == "anyone" || perm.Type == "domain":
+					if f.WebViewLink != "" && len(file.Links) == 0 {
+						file.Links = append(file.Links, f.WebViewLink)
+					}
+				case perm.Role != "owner" && perm.EmailAddress != "":
+					file.SharedWith = append(file.SharedWith, perm.EmailAddress)
+				}
+			}
+
+			if err := fn(file); err != nil {
+				return err
+			}
+		}
+
+		if page.NextPageToken == "" {
+			return nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// folderPath returns the path of the folder, caching the paths of the folders
+// fetched in paths. The root folder of the drive and folders whose parent
+// cannot be accessed (e.g., of files shared with the user) are at "/".
+func (g GoogleDrive) folderPath(ctx context.Context, paths map[string]string, id string) (string, error) {
+	if p, ok := paths[id]; ok {
+		return p, nil
+	}
+
+	var folder struct {
+		Name    string   `json:"name"`
+		Parents []string `json:"parents"`
+	}
+	err := cloudGetJSON(ctx, g.Client, g.baseURL()+"/files/"+url.PathEscape(id)+"?fields=name,parents", &folder)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	p := "/"
+	if err == nil && len(folder.Parents) > 0 {
+		parent, err := g.folderPath(ctx, paths, folder.Parents[0])
+		if err != nil {
+			return "", err
+		}
+		p = path.Join(parent, folder.Name)
+	}
+
+	paths[id] = p
+	return p, nil
+}
+
+// Open downloads the content of the file.
+func (g GoogleDrive) Open(ctx context.Context, file CloudFile) (io.ReadCloser, error) {
+	resp, err := cloudGet(ctx, g.Client, g.baseURL()+"/files/"+url.PathEscape(file.ID)+"?alt=media")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (g GoogleDrive) baseURL() string {
+	return strings.TrimSuffix(firstNonEmpty(g.BaseURL, DefaultGoogleDriveURL), "/")
+}
+
+// OneDrive is a CloudConnector for OneDrive and SharePoint document
+// libraries (Microsoft Graph).
+type OneDrive struct {
+	// Client sends the requests. It must authorize them, e.g. an OAuth 2.0
+	// client with the Files.Read.All scope. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// BaseURL is the drive endpoint. Defaults to DefaultOneDriveURL, the
+	// drive of the signed-in user; other drives are addressed as
+	// "https://graph.microsoft.com/v1.0/drives/<drive ID>".
+	BaseURL string
+}
+
+// List calls fn for every file of the drive, using the delta query to
+// enumerate the whole drive.
+func (o OneDrive) List(ctx context.Context, fn func(CloudFile) error) error {
+	type identity struct {
+		User struct {
+			Email       string `json:"email"`
+			DisplayName string `json:"displayName"`
+		} `json:"user"`
+	}
+
+	next := o.baseURL() + "/root/delta"
+	for next != "" {
+		var page struct {
+			NextLink string `json:"@odata.nextLink"`
+			Value    []struct {
+				ID                   string    `json:"id"`
+				Name                 string    `json:"name"`
+				Size                 int64     `json:"size"`
+				LastModifiedDateTime time.Time `json:"lastModifiedDateTime"`
+				WebURL               string    `json:"webUrl"`
+				File                 *struct {
+					MimeType string `json:"mimeType"`
+				} `json:"file"`
+				Deleted         *struct{} `json:"deleted"`
+				ParentReference struct {
+					Path string `json:"path"`
+				} `json:"parentReference"`
+				CreatedBy identity `json:"createdBy"`
+				Shared    *struct {
+					Scope string   `json:"scope"`
+					Owner identity `json:"owner"`
+				} `json:"shared"`
+			} `json:"value"`
+		}
+		if err := cloudGetJSON(ctx, o.Client, next, &page); err != nil {
+			return err
+		}
+
+		for _, item := range page.Value {
+			if item.File == nil || item.Deleted != nil {
+				continue
+			}
+
+			// Parent paths have the form "/drive/root:/Documents".
+			parent := item.ParentReference.Path
+			if _, p, ok := strings.Cut(parent, "root:"); ok {
+				parent = p
+			}
+
+			file := CloudFile{
+				Provider: "onedrive",
+				ID:       item.ID,
+				Path:     path.Join("/", parent, item.Name),
+				Size:     item.Size,
+				ModTime:  item.LastModifiedDateTime,
+				MimeType: item.File.MimeType,
+				WebURL:   item.WebURL,
+			}
+
+			owner := item.CreatedBy
+			if item.Shared != nil {
+				if item.Shared.Owner.User.Email != "" || item.Shared.Owner.User.DisplayName != "" {
+					owner = item.Shared.Owner
+				}
+				if item.Shared.Scope == "anonymous" || item.Shared.Scope == "organization" {
+					file.Links = []string{item.WebURL}
+				}
+			}
+			if name := firstNonEmpty(owner.User.Email, owner.User.DisplayName); name != "" {
+				file.Owners = []string{name}
+			}
+
+			if err := fn(file); err != nil {
+				return err
+			}
+		}
+
+		next = page.NextLink
+	}
+
+	return nil
+}
+
+// Open downloads the content of the file.
+func (o OneDrive) Open(ctx context.Context, file CloudFile) (io.ReadCloser, error) {
+	resp, err := cloudGet(ctx, o.Client, o.baseURL()+"/items/"+url.PathEscape(file.ID)+"/content")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (o OneDrive) baseURL() string {
+	return strings.TrimSuffix(firstNonEmpty(o.BaseURL, DefaultOneDriveURL), "/")
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package metaextractor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractCloud_GoogleDrive(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "('root' in parents) and trashed = false", r.URL.Query().Get("q"))
+
+		if r.URL.Query().Get("pageToken") == "" {
+			w.Write([]byte(`{"nextPageToken": "p2", "files": [
+				{"id": "1", "name": "photo.jpg", "parents": ["f2"], "mimeType": "image/jpeg", "size": "8",
+				 "modifiedTime": "2024-01-02T03:04:05Z", "webViewLink": "https://drive/1",
+				 "owners": [{"emailAddress": "alice@example.com"}],
+				 "permissions": [{"type": "user", "role": "owner", "emailAddress": "alice@example.com"},
+				                 {"type": "user", "role": "reader", "emailAddress": "bob@example.com"},
+				                 {"type": "anyone", "role": "reader"}]},
+				{"id": "2", "name": "Notes", "mimeType": "application/vnd.google-apps.document"}
+			]}`))
+			return
+		}
+
+		w.Write([]byte(`{"files": [
+			{"id": "3", "name": "gone.jpg", "parents": ["shared"], "mimeType": "image/jpeg", "size": "4"},
+			{"id": "4", "name": "skip.txt", "mimeType": "text/plain", "size": "4"}
+		]}`))
+	})
+	mux.HandleFunc("/files/1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "media", r.URL.Query().Get("alt"))
+		w.Write([]byte("\xff\xd8\xff\xe0jpeg"))
+	})
+	folders := map[string]string{
+		"root": `{"name": "My Drive"}`,
+		"f1":   `{"name": "Photos", "parents": ["root"]}`,
+		"f2":   `{"name": "2024", "parents": ["f1"]}`,
+	}
+	for id, folder := range folders {
+		mux.HandleFunc("/files/"+id, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "name,parents", r.URL.Query().Get("fields"))
+			w.Write([]byte(folder))
+		})
+	}
+	mux.HandleFunc("/files/shared", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/files/3", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	me := NewMetaExtractor(Options{PureGo: true, UTC: true})
+	drive := GoogleDrive{Client: srv.Client(), BaseURL: srv.URL, Query: "'root' in parents"}
+
+	results, err := me.ExtractCloud(context.Background(), drive, WalkOptions{Extensions: []string{".jpg"}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	r := results[0]
+	require.NoError(t, r.Err)
+	assert.Equal(t, "gdrive:/Photos/2024/photo.jpg", r.Path)
+	assert.Equal(t, "photo.jpg", r.Metadata.Name)
+	assert.Equal(t, int64(8), r.Metadata.Size)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), r.Metadata.Time.ModTime)
+	require.NotNil(t, r.Metadata.Cloud)
+	assert.Equal(t, "1", r.Metadata.Cloud.ID)
+	assert.Equal(t, []string{"alice@example.com"}, r.Metadata.Cloud.Owners)
+	assert.Equal(t, []string{"bob@example.com"}, r.Metadata.Cloud.SharedWith)
+	assert.Equal(t, []string{"https://drive/1"}, r.Metadata.Cloud.Links)
+
+	assert.Equal(t, "gdrive:/gone.jpg", results[1].Path)
+	assert.ErrorContains(t, results[1].Err, "404")
+}
+
+func TestExtractCloud_OneDrive(t *testing.T) {
+	var srv *httptest.Server
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/root/delta", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") == "" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"@odata.nextLink": srv.URL + "/root/delta?token=2",
+				"value": []map[string]interface{}{
+					{"id": "root", "name": "root", "folder": map[string]interface{}{}},
+					{
+						"id": "a", "name": "a.zip", "size": 100,
+						"lastModifiedDateTime": "2024-01-02T03:04:05Z",
+						"webUrl":               "https://onedrive/a",
+						"file":                 map[string]interface{}{"mimeType": "application/zip"},
+						"parentReference":      map[string]interface{}{"path": "/drive/root:/Docs"},
+						"createdBy":            map[string]interface{}{"user": map[string]interface{}{"displayName": "Carol"}},
+						"shared":               map[string]interface{}{"scope": "anonymous"},
+					},
+				},
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"value": []map[string]interface{}{
+				{"id": "d", "name": "deleted.jpg", "file": map[string]interface{}{}, "deleted": map[string]interface{}{}},
+			},
+		})
+	})
+	mux.HandleFunc("/items/a/content", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipBytes(t, map[string][]byte{"inner.jpg": []byte("zipped")}))
+	})
+
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	me := NewMetaExtractor(Options{PureGo: true})
+	drive := OneDrive{Client: srv.Client(), BaseURL: srv.URL}
+
+	results, err := me.ExtractCloud(context.Background(), drive, WalkOptions{Archives: true})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "onedrive:/Docs/a.zip", results[0].Path)
+	require.NotNil(t, results[0].Metadata.Cloud)
+	assert.Equal(t, "/Docs/a.zip", results[0].Metadata.Cloud.Path)
+	assert.Equal(t, []string{"Carol"}, results[0].Metadata.Cloud.Owners)
+	assert.Equal(t, []string{"https://onedrive/a"}, results[0].Metadata.Cloud.Links)
+
+	assert.Equal(t, "onedrive:/Docs/a.zip"+ArchiveSeparator+"inner.jpg", results[1].Path)
+	assert.Nil(t, results[1].Metadata.Cloud)
+}
+
+func TestExtractCloud_Canceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"value": []}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewMetaExtractor(Options{PureGo: true}).ExtractCloud(ctx, OneDrive{Client: srv.Client(), BaseURL: srv.URL}, WalkOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,543 @@
+// Command metaextract extracts metadata from files and directories and prints
+// it as JSON lines. With -perf, it reports the extraction throughput instead,
+// which can be used to validate performance-sensitive changes.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attilabuti/metaextractor"
+)
+
+// record is the JSON representation of a result.
+type record struct {
+	Path        string                      `json:"path"`
+	RunID       string                      `json:"run_id"`
+	RecordID    string                      `json:"record_id"`
+	Host        string                      `json:"host,omitempty"`
+	Metadata    *metaextractor.Metadata     `json:"metadata,omitempty"`
+	Dir         *metaextractor.DirMetadata  `json:"dir,omitempty"`
+	Bag         *metaextractor.Bag          `json:"bag,omitempty"`
+	Parent      string                      `json:"parent,omitempty"`
+	Depth       int                         `json:"depth,omitempty"`
+	SourcePath  string                      `json:"source_path,omitempty"`
+	DuplicateOf string                      `json:"duplicate_of,omitempty"`
+	Aliases     []string                    `json:"aliases,omitempty"`
+	Related     []metaextractor.RelatedFile `json:"related,omitempty"`
+	Error       string                      `json:"error,omitempty"`
+}
+
+// checkTools prints the status of the external tools of the extractor and
+// returns the exit code: 0 if they are all usable, 1 otherwise.
+func checkTools(me *metaextractor.MetaExtractor, stdout, stderr io.Writer) int {
+	statuses, err := me.CheckTools()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	code := 0
+	for _, status := range statuses {
+		switch {
+		case status.Err != nil:
+			fmt.Fprintf(stdout, "%s\t%s\terror: %v\n", status.Name, status.Path, status.Err)
+			code = 1
+		case status.Definitions > 0:
+			fmt.Fprintf(stdout, "%s\t%s\t%s (%d definitions)\n", status.Name, status.Path, status.Version, status.Definitions)
+		default:
+			fmt.Fprintf(stdout, "%s\t%s\t%s\n", status.Name, status.Path, status.Version)
+		}
+	}
+
+	return code
+}
+
+// perfStats accumulates the throughput statistics reported by -perf.
+type perfStats struct {
+	files   int
+	errors  int
+	bytes   int64
+	aborted string
+}
+
+// stdin is the input read for the path "-".
+var stdin io.Reader = os.Stdin
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command with the given arguments and returns the exit
+// code: 0 on success, 1 if any file failed, and 2 on usage errors.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("metaextract", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: metaextract [flags] path...")
+		fs.PrintDefaults()
+	}
+
+	var (
+		tridPath     = fs.String("trid", "", "path to the TrID executable")
+		tridDefs     = fs.String("triddefs", "", "path to the TrID definitions file")
+		exifToolPath = fs.String("exiftool", "", "path to the ExifTool executable")
+		exifToolArgs = fs.String("exiftool-args", "", "space-separated additional ExifTool arguments (e.g., \"-fast2 -n\")")
+		noBinary     = fs.Bool("no-binary", false, "do not extract binary EXIF values such as embedded previews")
+		magic        = fs.Bool("magic", false, "also identify files with libmagic (file command)")
+		media        = fs.Bool("media", false, "also read the codecs and streams of audio and video files with ffprobe")
+		ffprobePath  = fs.String("ffprobe", "", "path to the ffprobe executable")
+		mediaInfo    = fs.Bool("media-info", false, "also read the codecs and streams of audio and video files with MediaInfo (after ffprobe with -media)")
+		mediaInfoBin = fs.String("mediainfo", "", "path to the MediaInfo executable")
+		pureGo       = fs.Bool("purego", false, "disable external tools and use the built-in signature detector")
+		noTrid       = fs.Bool("no-trid", false, "do not run TrID; detect types with the built-in signature detector")
+		noExif       = fs.Bool("no-exif", false, "do not run ExifTool")
+		hashes       = fs.String("hash", "", "comma-separated hash algorithms (md5, sha1, sha256, sha512, blake3, xxhash64, crc32, ssdeep)")
+		verify       = fs.Bool("verify-manifests", false, "verify files against the checksum manifests (SFV, SHA256SUMS, PAR2) in their directory")
+		entropy      = fs.Bool("entropy", false, "compute the entropy of the file content")
+		sketch       = fs.Bool("sketch", false, "compute a similarity sketch of the file content")
+		imageChecks  = fs.Bool("image-checks", false, "cross-check images against their EXIF metadata")
+		onnxModel    = fs.String("onnx-model", "", "detect objects and faces in images with this ONNX model (requires -onnx-runner)")
+		onnxRunner   = fs.String("onnx-runner", "", "command running the ONNX model, invoked with the model and image paths (e.g., \"python3 detect.py\")")
+		fingerprint  = fs.Bool("fingerprint", false, "compute the Chromaprint fingerprint of audio files with fpcalc")
+		fpcalcPath   = fs.String("fpcalc", "", "path to the fpcalc executable")
+		acoustIDKey  = fs.String("acoustid-key", "", "identify fingerprinted recordings with AcoustID using this API key (implies -fingerprint)")
+		zipMeta      = fs.Bool("zip", false, "read the comment, entry systems, compression methods and timestamps of ZIP archives")
+		provenance   = fs.Bool("provenance", false, "record the source stage of every metadata field")
+		createdAt    = fs.String("created-at", "", "comma-separated precedence of the creation date candidates (BirthTime, ModTime or EXIF tags)")
+		earliest     = fs.Bool("earliest", false, "choose the earliest creation date candidate instead of the first by precedence")
+		binaryDir    = fs.String("binary-dir", "", "directory binary EXIF values are written to instead of the records")
+		maxExifSize  = fs.Int("max-exif-size", 0, "maximum size of the EXIF metadata of a record in bytes (0: no limit)")
+		bestEffort   = fs.Bool("best-effort", false, "report stage errors as warnings instead of failing the file")
+		strict       = fs.Bool("strict", false, "fail files with incomplete metadata (no type, EXIF or birth time)")
+		failEmpty    = fs.Bool("fail-empty", false, "fail zero-byte files instead of reporting them as empty")
+		concurrency  = fs.Int("j", 1, "number of files extracted in parallel")
+		dedup        = fs.Bool("dedup", false, "extract the content of files with identical content only once")
+		profile      = fs.String("profile", "", "extraction profile (photos, documents, malware-triage) replacing the hash, entropy, sketch and sample flags")
+		placeholders = fs.String("placeholders", "skip", "handling of cloud placeholder files (skip, hydrate, fail)")
+		archives     = fs.Bool("archives", false, "descend into archives and email attachments found in directories")
+		dirs         = fs.Bool("dirs", false, "also report metadata of the walked directories")
+		bags         = fs.Bool("bags", false, "also report and verify the BagIt bags found in directories")
+		originalRoot = fs.String("original-root", "", "live path of the scanned directory if it is a snapshot (VSS, LVM, btrfs)")
+		maxDepth     = fs.Int("max-depth", 0, "maximum number of directory levels walked below each directory (0 means no limit)")
+		follow       = fs.Bool("follow", false, "follow symbolic links when walking directories")
+		bundles      = fs.Bool("bundles", false, "descend into macOS bundles instead of reporting them as single items")
+		related      = fs.Bool("related", false, "link live photos, RAW+JPEG pairs and burst sequences found in directories")
+		perf         = fs.Bool("perf", false, "report throughput instead of printing metadata")
+		csvOut       = fs.Bool("csv", false, "print the results as CSV instead of JSON lines")
+		xmlOut       = fs.Bool("xml", false, "print the results as RDF/XML compatible with exiftool -X instead of JSON lines")
+		yamlOut      = fs.Bool("yaml", false, "print the results as YAML documents instead of JSON lines")
+		csvFields    = fs.String("csv-fields", "", "comma-separated CSV columns, e.g. Path,Size,Exif.Model (default: Path,Name,Size,Kind,MimeType,Time.ModTime,Error)")
+		progress     = fs.Bool("progress", false, "report the progress of directory extractions on standard error")
+		auditLog     = fs.String("audit-log", "", "append an audit record of every extraction to this file")
+		auditContext = fs.String("audit-context", "", "comma-separated key=value pairs included in every audit record")
+		redact       = fs.Bool("redact", false, "redact serial numbers, owner names and GPS precision (DefaultRedactionPolicy)")
+		catalogPath  = fs.String("catalog", "", "JSON message catalog used to translate type descriptions and labels")
+		runID        = fs.String("run-id", "", "run ID reported in every record (default: a new random ID)")
+		errorBudget  = fs.String("error-budget", "", "comma-separated stage=rate pairs aborting the run when a stage fails on more files (e.g., exif=20%,detect=0.5)")
+		check        = fs.Bool("check", false, "check that the external tools are runnable, print their versions and exit")
+	)
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 && !*check {
+		fs.Usage()
+		return 2
+	}
+
+	opts := metaextractor.Options{
+		TridPath:        *tridPath,
+		TridDefs:        *tridDefs,
+		ExifToolPath:    *exifToolPath,
+		ExifToolArgs:    strings.Fields(*exifToolArgs),
+		SkipExifBinary:  *noBinary,
+		FFprobePath:     *ffprobePath,
+		MediaInfoPath:   *mediaInfoBin,
+		PureGo:          *pureGo,
+		DisableTrid:     *noTrid,
+		DisableExif:     *noExif,
+		Entropy:         *entropy,
+		VerifyManifests: *verify,
+		Sketch:          *sketch,
+		ImageChecks:     *imageChecks,
+		ZipMetadata:     *zipMeta,
+		Provenance:      *provenance,
+		MaxExifSize:     *maxExifSize,
+		BestEffort:      *bestEffort,
+		Strict:          *strict,
+		FailEmpty:       *failEmpty,
+		Deduplicate:     *dedup,
+		Concurrency:     *concurrency,
+		RunID:           *runID,
+	}
+	if *errorBudget != "" {
+		budgets, err := parseBudgets(*errorBudget)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		opts.ErrorBudgets = budgets
+	}
+	if *magic || *media || *mediaInfo {
+		opts.Backends = slices.Clone(metaextractor.DefaultBackends)
+		if *magic {
+			opts.Backends = append(opts.Backends, metaextractor.MagicBackend)
+		}
+		if *media {
+			opts.Backends = append(opts.Backends, metaextractor.FFprobeBackend)
+		}
+		if *mediaInfo {
+			opts.Backends = append(opts.Backends, metaextractor.MediaInfoBackend)
+		}
+	}
+	if opts.RunID == "" {
+		opts.RunID = metaextractor.NewRunID()
+	}
+	switch *placeholders {
+	case "skip":
+	case "hydrate":
+		opts.Placeholders = metaextractor.PlaceholderHydrate
+	case "fail":
+		opts.Placeholders = metaextractor.PlaceholderFail
+	default:
+		fmt.Fprintf(stderr, "invalid -placeholders value %q\n", *placeholders)
+		return 2
+	}
+	if *binaryDir != "" {
+		opts.BinaryStore = metaextractor.DirStore(*binaryDir)
+	}
+	if *auditLog != "" {
+		f, err := metaextractor.OpenAuditLog(*auditLog)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer f.Close()
+
+		opts.Audit = metaextractor.AuditOptions{Writer: f, Context: parseContext(*auditContext)}
+	}
+	if *onnxModel != "" || *onnxRunner != "" {
+		if *onnxModel == "" || *onnxRunner == "" {
+			fmt.Fprintln(stderr, "-onnx-model and -onnx-runner must be set together")
+			return 2
+		}
+		opts.ImageAnalyzer = metaextractor.CommandAnalyzer{Command: strings.Fields(*onnxRunner), Model: *onnxModel}
+	}
+	if *fingerprint || *acoustIDKey != "" {
+		opts.AudioFingerprint = metaextractor.AudioFingerprintOptions{Enabled: true, FpcalcPath: *fpcalcPath}
+		if *acoustIDKey != "" {
+			opts.AudioFingerprint.Lookup = metaextractor.AcoustID{APIKey: *acoustIDKey}
+		}
+	}
+	if *hashes != "" {
+		opts.Hashes = strings.Split(*hashes, ",")
+	}
+	if *createdAt != "" {
+		opts.CreatedAt.Precedence = strings.Split(*createdAt, ",")
+	}
+	opts.CreatedAt.Earliest = *earliest
+
+	if *progress {
+		opts.Progress = printProgress(stderr)
+	}
+
+	if *redact {
+		opts.PostProcessors = append(opts.PostProcessors, metaextractor.DefaultRedactionPolicy)
+	}
+	if *catalogPath != "" {
+		f, err := os.Open(*catalogPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		catalog, err := metaextractor.ParseMessageCatalog(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		opts.PostProcessors = append(opts.PostProcessors, catalog)
+	}
+
+	me := metaextractor.NewMetaExtractor(opts)
+	defer me.Close()
+
+	if *profile != "" {
+		var err error
+		if me, err = me.WithProfile(*profile); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	if *check {
+		return checkTools(me, stdout, stderr)
+	}
+
+	enc := json.NewEncoder(stdout)
+
+	if (*csvOut && *xmlOut) || (*csvOut && *yamlOut) || (*xmlOut && *yamlOut) {
+		fmt.Fprintln(stderr, "only one of -csv, -xml and -yaml can be used")
+		return 2
+	}
+
+	var xmlWriter *metaextractor.XMLWriter
+	if *xmlOut {
+		xmlWriter = metaextractor.NewXMLWriter(stdout)
+	}
+
+	var yamlWriter *metaextractor.YAMLWriter
+	if *yamlOut {
+		yamlWriter = metaextractor.NewYAMLWriter(stdout)
+	}
+
+	var csvWriter *metaextractor.CSVWriter
+	if *csvOut {
+		var fields []string
+		if *csvFields != "" {
+			fields = strings.Split(*csvFields, ",")
+		}
+
+		var err error
+		if csvWriter, err = metaextractor.NewCSVWriter(stdout, fields); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	var stats perfStats
+	start := time.Now()
+
+	for _, path := range fs.Args() {
+		var results []metaextractor.Result
+
+		info, statErr := os.Stat(path)
+		switch {
+		case path == metaextractor.StreamPath:
+			results = []metaextractor.Result{extractStream(me, stdin, path, opts.RunID)}
+		case statErr == nil && info.Mode()&(os.ModeCharDevice|os.ModeNamedPipe) != 0:
+			// Devices and pipes have no size and can only be read once.
+			f, err := os.Open(path)
+			if err != nil {
+				results = []metaextractor.Result{{Path: path, Err: err}}
+				break
+			}
+			results = []metaextractor.Result{extractStream(me, f, path, opts.RunID)}
+			f.Close()
+		case statErr == nil && info.IsDir():
+			var err error
+			results, err = me.ExtractDir(path, metaextractor.WalkOptions{
+				Archives:       *archives,
+				Dirs:           *dirs,
+				Bags:           *bags,
+				MaxDepth:       *maxDepth,
+				FollowSymlinks: *follow,
+				DescendBundles: *bundles,
+				RelatedFiles:   *related,
+				OriginalRoot:   *originalRoot,
+			})
+			if err != nil {
+				results = append(results, metaextractor.Result{Path: path, Err: err})
+			}
+		default:
+			results = me.ExtractBatch([]string{path})
+		}
+
+		for _, r := range results {
+			if r.Dir == nil && r.Bag == nil {
+				stats.files++
+			}
+			stats.bytes += r.Metadata.Size
+			if r.Err != nil {
+				stats.errors++
+			}
+			if errors.Is(r.Err, metaextractor.ErrBudgetExceeded) && stats.aborted == "" {
+				stats.aborted = r.Err.Error()
+			}
+
+			if *perf {
+				continue
+			}
+
+			if csvWriter != nil {
+				if err := csvWriter.Write(r); err != nil {
+					fmt.Fprintln(stderr, err)
+					return 1
+				}
+				continue
+			}
+
+			if xmlWriter != nil {
+				if err := xmlWriter.Write(r); err != nil {
+					fmt.Fprintln(stderr, err)
+					return 1
+				}
+				continue
+			}
+
+			if yamlWriter != nil {
+				if err := yamlWriter.Write(r); err != nil {
+					fmt.Fprintln(stderr, err)
+					return 1
+				}
+				continue
+			}
+
+			rec := record{
+				Path:        r.Path,
+				RunID:       r.RunID,
+				RecordID:    r.RecordID,
+				Host:        r.Host,
+				Parent:      r.Parent,
+				Depth:       r.Depth,
+				SourcePath:  r.SourcePath,
+				DuplicateOf: r.DuplicateOf,
+				Aliases:     r.Aliases,
+				Related:     r.RelatedFiles,
+			}
+			if r.Err != nil {
+				rec.Error = r.Err.Error()
+			} else if r.Dir != nil {
+				rec.Dir = r.Dir
+			} else if r.Bag != nil {
+				rec.Bag = r.Bag
+			} else {
+				rec.Metadata = &r.Metadata
+			}
+
+			if err := enc.Encode(rec); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+		}
+	}
+
+	if stats.aborted != "" {
+		fmt.Fprintln(stderr, stats.aborted)
+	}
+
+	if *perf {
+		stats.report(stdout, time.Since(start))
+	} else if csvWriter != nil {
+		if err := csvWriter.Flush(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	} else if xmlWriter != nil {
+		if err := xmlWriter.Close(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	} else if yamlWriter != nil {
+		if err := yamlWriter.Close(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	if stats.errors > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// extractStream extracts the metadata of the content read from r and
+// reports it under path in the given run.
+func extractStream(me *metaextractor.MetaExtractor, r io.Reader, path, runID string) metaextractor.Result {
+	metadata, err := me.ExtractStreamInput(r)
+	host, _ := os.Hostname()
+
+	return metaextractor.Result{
+		Path:     path,
+		Metadata: metadata,
+		Err:      err,
+		RunID:    runID,
+		RecordID: metaextractor.NewRunID(),
+		Host:     host,
+	}
+}
+
+// printProgress returns a ProgressFunc writing a line per extracted file to
+// w. The total is printed as "?" when it is not known in advance.
+func printProgress(w io.Writer) metaextractor.ProgressFunc {
+	return func(done, total int, current string) {
+		if total > 0 {
+			fmt.Fprintf(w, "[%d/%d] %s\n", done, total, current)
+		} else {
+			fmt.Fprintf(w, "[%d/?] %s\n", done, current)
+		}
+	}
+}
+
+// parseContext parses comma-separated key=value pairs. A pair without "="
+// is stored with an empty value.
+func parseContext(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+
+	context := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(pair, "=")
+		context[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return context
+}
+
+// parseBudgets parses comma-separated stage=rate pairs, where the rate is a
+// fraction (0.2) or a percentage (20%).
+func parseBudgets(s string) ([]metaextractor.ErrorBudget, error) {
+	var budgets []metaextractor.ErrorBudget
+	for _, pair := range strings.Split(s, ",") {
+		stage, rate, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid error budget %q: expected stage=rate", pair)
+		}
+
+		rate = strings.TrimSpace(rate)
+		percent := strings.HasSuffix(rate, "%")
+
+		maxRate, err := strconv.ParseFloat(strings.TrimSuffix(rate, "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid error budget %q: %w", pair, err)
+		}
+		if percent {
+			maxRate /= 100
+		}
+		if maxRate < 0 || maxRate > 1 {
+			return nil, fmt.Errorf("invalid error budget %q: rate must be between 0 and 1", pair)
+		}
+
+		budgets = append(budgets, metaextractor.ErrorBudget{Stage: strings.TrimSpace(stage), MaxRate: maxRate})
+	}
+
+	return budgets, nil
+}
+
+// report writes the throughput statistics.
+func (s perfStats) report(w io.Writer, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = 1e-9
+	}
+
+	fmt.Fprintf(w, "files:      %d\n", s.files)
+	fmt.Fprintf(w, "errors:     %d\n", s.errors)
+	fmt.Fprintf(w, "bytes:      %d (%.1f MiB)\n", s.bytes, float64(s.bytes)/(1<<20))
+	fmt.Fprintf(w, "elapsed:    %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "throughput: %.1f files/s, %.1f MiB/s\n", float64(s.files)/seconds, float64(s.bytes)/(1<<20)/seconds)
+	if s.aborted != "" {
+		fmt.Fprintf(w, "aborted:    %s\n", s.aborted)
+	}
+}
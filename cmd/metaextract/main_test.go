@@ -0,0 +1,283 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/attilabuti/metaextractor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testdata = filepath.Join("..", "..", "testdata")
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"-purego", "-hash", "md5", filepath.Join(testdata, "sample.doc")}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	var rec record
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &rec))
+	assert.Equal(t, filepath.Join(testdata, "sample.doc"), rec.Path)
+	require.NotNil(t, rec.Metadata)
+	assert.Equal(t, "sample.doc", rec.Metadata.Name)
+	assert.Len(t, rec.Metadata.Hashes["md5"], 32)
+}
+
+func TestRun_Perf(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"-purego", "-perf", testdata}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	out := stdout.String()
+	assert.Contains(t, out, "files:      3\n")
+	assert.Contains(t, out, "errors:     0\n")
+	assert.Contains(t, out, "throughput:")
+}
+
+func TestRun_CSV(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	sample := filepath.Join(testdata, "sample.doc")
+	code := run([]string{"-purego", "-csv", "-csv-fields", "Path,Name,Extension", sample}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "Path,Name,Extension\n"+sample+",sample.doc,.doc\n", stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"-purego", "-csv", "-csv-fields", "Size >", sample}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Empty(t, stdout.String())
+}
+
+func TestRun_XML(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	sample := filepath.Join(testdata, "sample.doc")
+	code := run([]string{"-purego", "-xml", sample}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	out := stdout.String()
+	assert.True(t, strings.HasPrefix(out, "<?xml"), out)
+	assert.Contains(t, out, `<rdf:Description rdf:about="`+sample+`"`)
+	assert.Contains(t, out, "<File:FileName>sample.doc</File:FileName>")
+	assert.True(t, strings.HasSuffix(out, "</rdf:RDF>"), out)
+
+	stdout.Reset()
+	code = run([]string{"-purego", "-xml", "-csv", sample}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Empty(t, stdout.String())
+}
+
+func TestRun_YAML(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	sample := filepath.Join(testdata, "sample.doc")
+	code := run([]string{"-purego", "-yaml", sample}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	out := stdout.String()
+	assert.True(t, strings.HasPrefix(out, "path: "+sample+"\nmetadata:\n  name: sample.doc\n"), out)
+
+	stdout.Reset()
+	code = run([]string{"-purego", "-yaml", "-xml", sample}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Empty(t, stdout.String())
+}
+
+func TestRun_ErrorBudget(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"-purego", "-perf", "-error-budget", "detect=10%", testdata}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.NotContains(t, stdout.String(), "aborted:")
+
+	stdout.Reset()
+	code = run([]string{"-purego", "-error-budget", "detect", testdata}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Empty(t, stdout.String())
+}
+
+func TestParseBudgets(t *testing.T) {
+	budgets, err := parseBudgets("exif=20%, backend:ffprobe=0.5")
+	require.NoError(t, err)
+	assert.Equal(t, []metaextractor.ErrorBudget{
+		{Stage: "exif", MaxRate: 0.2},
+		{Stage: "backend:ffprobe", MaxRate: 0.5},
+	}, budgets)
+
+	for _, s := range []string{"exif", "exif=", "exif=x%", "exif=150%", "exif=-0.1"} {
+		_, err := parseBudgets(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRun_Progress(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"-purego", "-perf", "-progress", testdata}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "[1/3] "), lines[0])
+	assert.True(t, strings.HasPrefix(lines[2], "[3/3] "), lines[2])
+
+	var buf bytes.Buffer
+	printProgress(&buf)(2, 0, "file")
+	assert.Equal(t, "[2/?] file\n", buf.String())
+}
+
+func TestRun_VerifyManifests(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.sfv"), []byte("hello.txt 3610a686\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-purego", "-verify-manifests", filepath.Join(dir, "hello.txt")}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	var rec record
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &rec))
+	require.NotNil(t, rec.Metadata)
+	require.Len(t, rec.Metadata.Checksums, 1)
+	assert.Equal(t, metaextractor.ChecksumOK, rec.Metadata.Checksums[0].Status)
+}
+
+func TestRun_Zip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	require.NoError(t, zw.SetComment("release"))
+	_, err := zw.Create("hello.txt")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	archivePath := filepath.Join(t.TempDir(), "hello.zip")
+	require.NoError(t, os.WriteFile(archivePath, buf.Bytes(), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-purego", "-zip", archivePath}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	var rec record
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &rec))
+	require.NotNil(t, rec.Metadata)
+	require.NotNil(t, rec.Metadata.Zip)
+	assert.Equal(t, "release", rec.Metadata.Zip.Comment)
+	assert.Equal(t, 1, rec.Metadata.Zip.EntryCount)
+}
+
+func TestRun_Bags(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "data"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bagit.txt"), []byte("BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest-md5.txt"), []byte("5d41402abc4b2a76b9719d911017c592  data/hello.txt\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data", "hello.txt"), []byte("hello"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-purego", "-bags", dir}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	var bags int
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var rec record
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		if rec.Bag != nil {
+			bags++
+			assert.True(t, rec.Bag.Valid)
+		}
+	}
+	assert.Equal(t, 1, bags)
+}
+
+func TestRun_Errors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(nil, &stdout, &stderr))
+	assert.True(t, strings.HasPrefix(stderr.String(), "Usage: metaextract"))
+
+	stdout.Reset()
+	assert.Equal(t, 1, run([]string{"-purego", "nonexistent_file"}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), `"error":"file not found"`)
+
+	assert.Equal(t, 2, run([]string{"-unknown"}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"-placeholders", "download", "file"}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"-profile", "unknown", "file"}, &stdout, &stderr))
+}
+
+func TestRun_RunID(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"-purego", "-run-id", "run-1", filepath.Join(testdata, "sample.doc"), testdata}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	dec := json.NewDecoder(&stdout)
+	recordIDs := map[string]bool{}
+	for dec.More() {
+		var rec record
+		require.NoError(t, dec.Decode(&rec))
+		assert.Equal(t, "run-1", rec.RunID)
+		assert.NotEmpty(t, rec.RecordID)
+		recordIDs[rec.RecordID] = true
+	}
+
+	assert.Len(t, recordIDs, 4)
+}
+
+func TestRun_AuditLog(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	code := run([]string{"-purego", "-audit-log", path, "-audit-context", "case=17, operator=jdoe", testdata}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+
+	var rec metaextractor.AuditRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &rec))
+	assert.Equal(t, metaextractor.AuditSuccess, rec.Outcome)
+	assert.Equal(t, map[string]string{"case": "17", "operator": "jdoe"}, rec.Context)
+}
+
+func TestRun_Stdin(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(testdata, "sample.doc"))
+	require.NoError(t, err)
+
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = bytes.NewReader(data)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-purego", "-hash", "md5", "-run-id", "run-1", "-"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	var rec record
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &rec))
+	assert.Equal(t, "-", rec.Path)
+	assert.Equal(t, "run-1", rec.RunID)
+	assert.NotEmpty(t, rec.RecordID)
+	require.NotNil(t, rec.Metadata)
+	assert.Equal(t, int64(len(data)), rec.Metadata.Size)
+	assert.Len(t, rec.Metadata.Hashes["md5"], 32)
+	assert.NotEmpty(t, rec.Metadata.Types)
+}
+
+func TestRun_Check(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-check", "-purego"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Empty(t, stdout.String())
+
+	code = run([]string{"-check", "-purego", "-hash", "md4"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "md4")
+}
@@ -0,0 +1,93 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetaExtractor_Concurrent runs extractions sharing one extractor from
+// many goroutines. Run with -race to detect shared mutable state.
+func TestMetaExtractor_Concurrent(t *testing.T) {
+	opts := Options{
+		PureGo:        true,
+		Hashes:        []string{"md5", "sha256-tree"},
+		HashChunkSize: 4 << 10,
+		Entropy:       true,
+		SampleSize:    16,
+		Detectors:     []Detector{SignatureDetector, ExtensionDetector},
+		FuseDetectors: true,
+		NameForm:      NameNFC,
+		Rules:         []Rule{{Label: "nonempty", Condition: `Size > 0`}},
+	}
+	me := NewMetaExtractor(opts)
+
+	// Modifying the options must not affect the extractor.
+	opts.Hashes[0] = "sha1"
+
+	paths := []string{
+		filepath.Join("testdata", "sample.doc"),
+		filepath.Join("testdata", "sample.mp3"),
+		filepath.Join("testdata", "empty"),
+	}
+
+	want := make(map[string]Metadata)
+	for _, p := range paths {
+		metadata, err := me.Extract(p)
+		require.NoError(t, err)
+		want[p] = metadata
+	}
+	assert.Contains(t, want[paths[0]].Hashes, "md5")
+
+	const goroutines = 16
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*len(paths))
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			switch i % 3 {
+			case 0:
+				for _, p := range paths {
+					metadata, err := me.Extract(p)
+					if err != nil {
+						errs <- err
+						continue
+					}
+					assert.Equal(t, want[p], withoutTimes(metadata, want[p]))
+				}
+			case 1:
+				for _, r := range me.ExtractBatch(paths) {
+					assert.NoError(t, r.Err)
+					assert.Equal(t, want[r.Path].Hashes, r.Metadata.Hashes)
+				}
+			case 2:
+				results, err := me.ExtractDir("testdata", WalkOptions{})
+				if err != nil {
+					errs <- err
+				}
+				assert.Len(t, results, len(paths))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+// withoutTimes returns the metadata with the file times of ref, as access
+// times may change between extractions.
+func withoutTimes(metadata, ref Metadata) Metadata {
+	metadata.Time = ref.Time
+	return metadata
+}
@@ -0,0 +1,31 @@
+package metaextractor
+
+import "context"
+
+// runContext runs fn and returns its results, or ctx.Err() if ctx is done
+// first. In that case, fn is abandoned and keeps running in the background
+// until it returns.
+func runContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,113 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunContext(t *testing.T) {
+	value, err := runContext(context.Background(), func() (int, error) {
+		return 42, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42, value)
+
+	errFailed := errors.New("failed")
+	_, err = runContext(context.Background(), func() (int, error) {
+		return 0, errFailed
+	})
+	assert.ErrorIs(t, err, errFailed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+
+	go cancel()
+	_, err = runContext(ctx, func() (int, error) {
+		<-release
+		return 42, nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestExtractContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0o644))
+
+	release := make(chan struct{})
+	defer close(release)
+
+	blocking := DetectorFunc{
+		DetectorName: "blocking",
+		Fn: func(string) ([]trid.FileType, error) {
+			<-release
+			return nil, nil
+		},
+	}
+
+	t.Run("Canceled", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := me.ExtractContext(ctx, path)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, BestEffort: true, Detectors: []Detector{blocking}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		metadata, err := me.ExtractContext(ctx, path)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		assert.Equal(t, "file.txt", metadata.Name)
+		assert.Empty(t, metadata.Warnings)
+	})
+
+	t.Run("Background", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+
+		metadata, err := me.ExtractContext(context.Background(), path)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), metadata.Size)
+	})
+}
+
+// contextDetector is a ContextDetector blocking until its context is done.
+type contextDetector struct{}
+
+func (contextDetector) Name() string {
+	return "context"
+}
+
+func (d contextDetector) Detect(filePath string) ([]trid.FileType, error) {
+	return d.DetectContext(context.Background(), filePath)
+}
+
+func (contextDetector) DetectContext(ctx context.Context, filePath string) ([]trid.FileType, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestDetectTypesContext(t *testing.T) {
+	me := &MetaExtractor{detectors: []Detector{contextDetector{}, staticDetector("next", nil, nil)}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := me.detectTypes(ctx, "file", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,173 @@
+package metaextractor
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BestCreatedAt is the creation time of the content chosen from the
+// candidate dates of the file (see Options.CreatedAt).
+type BestCreatedAt struct {
+	// Time is the chosen creation time.
+	Time time.Time `json:"time"`
+
+	// Source is the candidate the time was taken from: "BirthTime" or
+	// "ModTime" for the file times, or the key of the date in ExifTimes
+	// (e.g., "DateTimeOriginal" or "PDF:CreateDate").
+	Source string `json:"source"`
+
+	// Conflicts are the other candidates whose date differs from Time by
+	// more than a day, in the order of the policy.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// CreatedAtPolicy configures how Metadata.BestCreatedAt is chosen when
+// several candidate creation dates exist (e.g., the birth time of the file,
+// EXIF DateTimeOriginal, XMP CreateDate and PDF CreationDate).
+type CreatedAtPolicy struct {
+	// Precedence lists the candidates in order of preference: "BirthTime"
+	// and "ModTime" select the file times, other entries are EXIF tags,
+	// either with a group (e.g., "XMP:CreateDate", which requires
+	// Options.ExifKeys to keep the groups) or without one (e.g.,
+	// "DateTimeOriginal", which matches the tag in any group). Defaults to
+	// DefaultCreatedAtPrecedence.
+	Precedence []string
+
+	// Earliest chooses the earliest candidate instead of the first one in
+	// order of preference, as copying or downloading a file resets its
+	// birth time but never moves the embedded dates back.
+	Earliest bool
+}
+
+// DefaultCreatedAtPrecedence is the default order of preference of the
+// candidate creation dates: the capture time of photos, the creation dates
+// of documents and videos, then the birth time of the file.
+var DefaultCreatedAtPrecedence = []string{
+	"EXIF:DateTimeOriginal",
+	"XMP:DateTimeOriginal",
+	"XMP:DateCreated",
+	"EXIF:CreateDate",
+	"XMP:CreateDate",
+	"QuickTime:CreateDate",
+	"PDF:CreateDate",
+	"DateTimeOriginal",
+	"DateCreated",
+	"CreateDate",
+	"BirthTime",
+}
+
+// minCreatedAt is the earliest plausible creation time. Earlier dates, such
+// as the zero dates of FAT (1980-01-01) and QuickTime (1904-01-01), are
+// written by devices without a clock.
+var minCreatedAt = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// conflictTolerance is the difference from the chosen time above which a
+// candidate is reported as a conflict.
+const conflictTolerance = 24 * time.Hour
+
+// createdAtCandidate is a plausible candidate creation date.
+type createdAtCandidate struct {
+	source string
+	time   time.Time
+}
+
+// bestCreatedAt chooses the creation time of the content from the file
+// times and ExifTimes according to the policy. Dates before 1980 or more
+// than a day in the future are ignored. It returns nil if no candidate is
+// plausible.
+func bestCreatedAt(metadata Metadata, policy CreatedAtPolicy, now time.Time) *BestCreatedAt {
+	precedence := policy.Precedence
+	if len(precedence) == 0 {
+		precedence = DefaultCreatedAtPrecedence
+	}
+
+	plausible := func(t time.Time) bool {
+		return t.After(minCreatedAt) && !t.After(now.Add(24*time.Hour))
+	}
+
+	var candidates []createdAtCandidate
+	seen := make(map[string]bool)
+	add := func(source string, t time.Time) {
+		if !seen[source] && plausible(t) {
+			seen[source] = true
+			candidates = append(candidates, createdAtCandidate{source: source, time: t})
+		}
+	}
+
+	for _, entry := range precedence {
+		switch entry {
+		case "BirthTime":
+			add(entry, metadata.Time.BirthTime)
+			continue
+		case "ModTime":
+			add(entry, metadata.Time.ModTime)
+			continue
+		}
+
+		if t, ok := metadata.ExifTimes[entry]; ok {
+			add(entry, t)
+		}
+		if strings.Contains(entry, ":") {
+			continue
+		}
+
+		// A tag without a group matches it in every group.
+		var keys []string
+		for key := range metadata.ExifTimes {
+			if _, tag, ok := strings.Cut(key, ":"); ok && tag == entry {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			add(key, metadata.ExifTimes[key])
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	best := candidates[0]
+	if policy.Earliest {
+		for _, c := range candidates[1:] {
+			if c.time.Before(best.time) {
+				best = c
+			}
+		}
+	}
+
+	result := &BestCreatedAt{Time: best.time, Source: best.source}
+	for _, c := range candidates {
+		if d := c.time.Sub(best.time); d > conflictTolerance || d < -conflictTolerance {
+			result.Conflicts = append(result.Conflicts, c.source)
+		}
+	}
+
+	return result
+}
+
+// contentCreated chooses the creation time of the content like
+// bestCreatedAt, leaving out the file times. It returns the zero time if no
+// EXIF date is plausible.
+func contentCreated(metadata Metadata, policy CreatedAtPolicy, now time.Time) time.Time {
+	precedence := policy.Precedence
+	if len(precedence) == 0 {
+		precedence = DefaultCreatedAtPrecedence
+	}
+
+	policy.Precedence = slices.DeleteFunc(slices.Clone(precedence), func(entry string) bool {
+		return entry == "BirthTime" || entry == "ModTime"
+	})
+	if len(policy.Precedence) == 0 {
+		return time.Time{}
+	}
+
+	if best := bestCreatedAt(metadata, policy, now); best != nil {
+		return best.Time
+	}
+	return time.Time{}
+}
@@ -0,0 +1,121 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBestCreatedAt(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	birth := time.Date(2024, 5, 20, 8, 0, 0, 0, time.UTC)
+	taken := time.Date(2023, 7, 14, 18, 30, 0, 0, time.UTC)
+	pdf := time.Date(2023, 7, 14, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		metadata Metadata
+		policy   CreatedAtPolicy
+		expect   *BestCreatedAt
+	}{
+		{
+			name: "Capture Time",
+			metadata: Metadata{
+				Time:      FileTime{BirthTime: birth},
+				ExifTimes: map[string]time.Time{"DateTimeOriginal": taken, "ModifyDate": now},
+			},
+			expect: &BestCreatedAt{Time: taken, Source: "DateTimeOriginal", Conflicts: []string{"BirthTime"}},
+		},
+		{
+			name: "Grouped",
+			metadata: Metadata{
+				Time:      FileTime{BirthTime: birth},
+				ExifTimes: map[string]time.Time{"XMP:CreateDate": pdf, "PDF:CreateDate": taken},
+			},
+			expect: &BestCreatedAt{Time: pdf, Source: "XMP:CreateDate", Conflicts: []string{"BirthTime"}},
+		},
+		{
+			name:     "Birth Time",
+			metadata: Metadata{Time: FileTime{BirthTime: birth, ModTime: now}},
+			expect:   &BestCreatedAt{Time: birth, Source: "BirthTime"},
+		},
+		{
+			name: "Implausible Dates",
+			metadata: Metadata{
+				Time: FileTime{BirthTime: birth},
+				ExifTimes: map[string]time.Time{
+					"QuickTime:CreateDate": time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC),
+					"DateTimeOriginal":     now.AddDate(1, 0, 0),
+				},
+			},
+			expect: &BestCreatedAt{Time: birth, Source: "BirthTime"},
+		},
+		{
+			name: "Precedence",
+			metadata: Metadata{
+				Time:      FileTime{BirthTime: birth},
+				ExifTimes: map[string]time.Time{"DateTimeOriginal": taken},
+			},
+			policy: CreatedAtPolicy{Precedence: []string{"BirthTime", "DateTimeOriginal"}},
+			expect: &BestCreatedAt{Time: birth, Source: "BirthTime", Conflicts: []string{"DateTimeOriginal"}},
+		},
+		{
+			name: "Earliest",
+			metadata: Metadata{
+				Time:      FileTime{BirthTime: birth, ModTime: now},
+				ExifTimes: map[string]time.Time{"PDF:CreateDate": pdf, "XMP:CreateDate": taken},
+			},
+			policy: CreatedAtPolicy{Precedence: []string{"ModTime", "PDF:CreateDate", "CreateDate"}, Earliest: true},
+			expect: &BestCreatedAt{Time: taken, Source: "XMP:CreateDate", Conflicts: []string{"ModTime"}},
+		},
+		{
+			name:     "None",
+			metadata: Metadata{ExifTimes: map[string]time.Time{"ModifyDate": taken}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, bestCreatedAt(tt.metadata, tt.policy, now))
+		})
+	}
+}
+
+func TestMetaExtractor_BestCreatedAt(t *testing.T) {
+	me := NewMetaExtractor(Options{
+		PureGo:     true,
+		Provenance: true,
+		CreatedAt:  CreatedAtPolicy{Precedence: []string{"ModTime"}},
+	})
+
+	metadata, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+	require.NotNil(t, metadata.BestCreatedAt)
+	assert.Equal(t, "ModTime", metadata.BestCreatedAt.Source)
+	assert.True(t, metadata.Time.ModTime.Equal(metadata.BestCreatedAt.Time))
+	assert.Equal(t, SourceFileSystem, metadata.Provenance["BestCreatedAt"])
+}
+
+func TestContentCreated(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	birth := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	taken := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	metadata := Metadata{
+		Time: FileTime{BirthTime: birth},
+		ExifTimes: map[string]time.Time{
+			"DateTimeOriginal": taken,
+			"ModifyDate":       birth,
+		},
+	}
+	assert.Equal(t, taken, contentCreated(metadata, CreatedAtPolicy{}, now))
+	assert.Equal(t, taken, contentCreated(metadata, CreatedAtPolicy{Precedence: []string{"BirthTime", "DateTimeOriginal"}}, now))
+	assert.True(t, contentCreated(metadata, CreatedAtPolicy{Precedence: []string{"BirthTime"}}, now).IsZero())
+
+	// The file times are never candidates, and implausible dates are ignored.
+	metadata.ExifTimes = map[string]time.Time{"CreateDate": time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)}
+	assert.True(t, contentCreated(metadata, CreatedAtPolicy{}, now).IsZero())
+}
@@ -0,0 +1,163 @@
+package metaextractor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCSVFields are the columns written by WriteCSV and NewCSVWriter if
+// no fields are given.
+var DefaultCSVFields = []string{"Path", "Name", "Size", "Kind", "MimeType", "Time.ModTime", "Error"}
+
+// resultColumns are the CSV columns taken from the Result instead of its
+// Metadata.
+var resultColumns = map[string]func(Result) string{
+	"Path":        func(r Result) string { return r.Path },
+	"Parent":      func(r Result) string { return r.Parent },
+	"Depth":       func(r Result) string { return strconv.Itoa(r.Depth) },
+	"SourcePath":  func(r Result) string { return r.SourcePath },
+	"DuplicateOf": func(r Result) string { return r.DuplicateOf },
+	"Error": func(r Result) string {
+		if r.Err != nil {
+			return r.Err.Error()
+		}
+		return ""
+	},
+}
+
+// CSVWriter writes results as CSV rows, one per result, flattening the
+// selected fields for spreadsheet analysis.
+type CSVWriter struct {
+	w       *csv.Writer
+	fields  []string
+	columns []*Expr
+	header  bool
+}
+
+// NewCSVWriter returns a CSVWriter writing the given fields to w. Fields are
+// field accesses of the expression language (see Expr) on Metadata, such as
+// "Size", "Types[0].Extension", "Hashes.sha256" or `Exif["Model"]`, or one of
+// the Result columns Path, Parent, Depth, SourcePath, DuplicateOf and
+// Error. The fields are also the header row. DefaultCSVFields are written if
+// fields is empty.
+func NewCSVWriter(w io.Writer, fields []string) (*CSVWriter, error) {
+	if len(fields) == 0 {
+		fields = DefaultCSVFields
+	}
+
+	cw := &CSVWriter{w: csv.NewWriter(w), fields: fields, columns: make([]*Expr, len(fields))}
+	for i, field := range fields {
+		if _, ok := resultColumns[field]; ok {
+			continue
+		}
+
+		expr, err := CompileExpr(field)
+		if err != nil {
+			return nil, err
+		}
+		cw.columns[i] = expr
+	}
+
+	return cw, nil
+}
+
+// Write writes the row of the result, preceded by the header row on the
+// first call. Missing map keys, out-of-range indexes and nil pointers give
+// empty cells; times are written in RFC 3339 format, lists of strings are
+// joined with "; " and other composite values are encoded as JSON.
+func (cw *CSVWriter) Write(r Result) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make([]string, len(cw.fields))
+	for i, field := range cw.fields {
+		if cw.columns[i] == nil {
+			row[i] = resultColumns[field](r)
+			continue
+		}
+
+		v, err := cw.columns[i].root.eval(reflect.ValueOf(r.Metadata))
+		if err != nil {
+			return fmt.Errorf("error evaluating CSV field %q: %w", field, err)
+		}
+
+		if row[i], err = csvValue(v); err != nil {
+			return fmt.Errorf("error encoding CSV field %q: %w", field, err)
+		}
+	}
+
+	return cw.w.Write(row)
+}
+
+// Flush writes the header row if no result was written, and flushes the
+// buffered rows to the underlying writer.
+func (cw *CSVWriter) Flush() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	cw.w.Flush()
+
+	return cw.w.Error()
+}
+
+func (cw *CSVWriter) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+	cw.header = true
+
+	return cw.w.Write(cw.fields)
+}
+
+// WriteCSV writes the results to w as CSV with a header row and a row per
+// result (see NewCSVWriter for the fields).
+func WriteCSV(w io.Writer, results []Result, fields []string) error {
+	cw, err := NewCSVWriter(w, fields)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if err := cw.Write(r); err != nil {
+			return err
+		}
+	}
+
+	return cw.Flush()
+}
+
+// csvValue formats a value evaluated by an expression as a CSV cell.
+func csvValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil, string, float64:
+		return stringValue(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	case []string:
+		return strings.Join(v, "; "), nil
+	}
+
+	if rv := reflect.ValueOf(v); (rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice) && rv.Len() == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,69 @@
+package metaextractor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	modTime := time.Date(2024, 8, 3, 14, 2, 11, 0, time.UTC)
+	results := []Result{
+		{
+			Path: "photos/a.jpg",
+			Metadata: Metadata{
+				Name:     "a.jpg",
+				Size:     2048,
+				Kind:     KindImage,
+				MimeType: "image/jpeg",
+				Time:     FileTime{ModTime: modTime},
+				Hashes:   map[string]string{"sha256": "9f2c"},
+				Types:    []trid.FileType{{Extension: ".jpg", Probability: 100}},
+				Exif:     ExifMetadata{"Model": "EOS R6, Mark II", "Keywords": []interface{}{"beach", "sea"}},
+				Labels:   []string{"holiday", "family"},
+			},
+		},
+		{Path: "photos/b.raw", Err: errors.New("permission denied")},
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		var buf bytes.Buffer
+		fields := []string{"Path", "Size", "Types[0].Extension", `Exif["Model"]`, "Exif.Keywords", "Hashes.sha256", "Labels", "Time.ModTime", "Zip.EntryCount", "Error"}
+		require.NoError(t, WriteCSV(&buf, results, fields))
+
+		assert.Equal(t, strings.Join([]string{
+			`Path,Size,Types[0].Extension,"Exif[""Model""]",Exif.Keywords,Hashes.sha256,Labels,Time.ModTime,Zip.EntryCount,Error`,
+			`photos/a.jpg,2048,.jpg,"EOS R6, Mark II","[""beach"",""sea""]",9f2c,holiday; family,2024-08-03T14:02:11Z,,`,
+			`photos/b.raw,0,,,,,,,,permission denied`,
+			``,
+		}, "\n"), buf.String())
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteCSV(&buf, results[:1], nil))
+
+		assert.Equal(t, "Path,Name,Size,Kind,MimeType,Time.ModTime,Error\n"+
+			"photos/a.jpg,a.jpg,2048,image,image/jpeg,2024-08-03T14:02:11Z,\n", buf.String())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteCSV(&buf, nil, []string{"Path", "Size"}))
+		assert.Equal(t, "Path,Size\n", buf.String())
+	})
+
+	t.Run("InvalidField", func(t *testing.T) {
+		_, err := NewCSVWriter(&bytes.Buffer{}, []string{"Size >"})
+		assert.ErrorContains(t, err, "error compiling expression")
+
+		err = WriteCSV(&bytes.Buffer{}, results, []string{"Unknown"})
+		assert.EqualError(t, err, `error evaluating CSV field "Unknown": unknown field "Unknown"`)
+	})
+}
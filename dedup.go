@@ -0,0 +1,237 @@
+package metaextractor
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dedupKey identifies the content of a file.
+type dedupKey struct {
+	size int64
+	sum  string
+}
+
+// dedupIndex shares the results of the content stages between files with
+// identical content within a batch (see Options.Deduplicate). To avoid
+// reading every file twice, a file is only hashed once another file of the
+// same size has been seen.
+type dedupIndex struct {
+	// unhashed holds the indices of the results not hashed yet, by size.
+	unhashed map[int64][]int
+
+	// hashed holds the sizes whose files are hashed.
+	hashed map[int64]bool
+
+	// first holds the index of the first result with the content.
+	first map[dedupKey]int
+}
+
+// newDedupIndex returns a new index, or nil if deduplication is disabled.
+func (me *MetaExtractor) newDedupIndex() *dedupIndex {
+	if !me.deduplicate {
+		return nil
+	}
+
+	return &dedupIndex{
+		unhashed: make(map[int64][]int),
+		hashed:   make(map[int64]bool),
+		first:    make(map[dedupKey]int),
+	}
+}
+
+// extract extracts the metadata of the file and appends its result to
+// results. If an earlier result has the same content, its content stages
+// are shared and the paths are recorded in DuplicateOf and Aliases. A nil
+// index extracts every file on its own. Once an error budget of the batch
+// run in ctx is exceeded, the file fails with the BudgetError instead.
+func (d *dedupIndex) extract(ctx context.Context, me *MetaExtractor, results []Result, p string) []Result {
+	if err := budgetFromContext(ctx).err(); err != nil {
+		return append(results, Result{Path: p, Err: err})
+	}
+
+	extract := func() []Result {
+		metadata, err := me.ExtractContext(ctx, p)
+		return append(results, Result{Path: p, Metadata: metadata, Err: err})
+	}
+
+	if d == nil {
+		return extract()
+	}
+
+	// Empty files have no content stages, and reading a placeholder would
+	// trigger its download.
+	info, err := os.Stat(longPath(p))
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 || isPlaceholder(p, info) {
+		return extract()
+	}
+
+	size := info.Size()
+	if len(d.unhashed[size]) == 0 && !d.hashed[size] {
+		results = extract()
+		if results[len(results)-1].Err == nil {
+			d.unhashed[size] = append(d.unhashed[size], len(results)-1)
+		}
+		return results
+	}
+
+	for _, i := range d.unhashed[size] {
+		if sum, err := fileSHA256(results[i].Path); err == nil {
+			key := dedupKey{size: size, sum: sum}
+			if _, ok := d.first[key]; !ok {
+				d.first[key] = i
+			}
+		}
+	}
+	delete(d.unhashed, size)
+	d.hashed[size] = true
+
+	sum, err := fileSHA256(p)
+	if err != nil {
+		return extract()
+	}
+	key := dedupKey{size: size, sum: sum}
+
+	if i, ok := d.first[key]; ok {
+		shared := results[i].Metadata
+		metadata, err := me.extractFile(ctx, p, p, &shared)
+		results[i].Aliases = append(results[i].Aliases, p)
+		return append(results, Result{Path: p, Metadata: metadata, Err: err, DuplicateOf: results[i].Path})
+	}
+
+	results = extract()
+	if results[len(results)-1].Err == nil {
+		d.first[key] = len(results) - 1
+	}
+
+	return results
+}
+
+// pathExifTags are the ExifTool tags describing the path of a file in the
+// file system rather than its content, which duplicates do not share.
+var pathExifTags = map[string]bool{
+	"SourceFile": true, "FileName": true, "Directory": true,
+	"FileModifyDate": true, "FileAccessDate": true, "FileInodeChangeDate": true,
+	"FileCreateDate": true, "FilePermissions": true, "FileAttributes": true,
+}
+
+// exifDateLayout is the layout of the dates ExifTool reports.
+const exifDateLayout = "2006:01:02 15:04:05-07:00"
+
+// pathExifValues returns the values of the tags of pathExifTags for the file,
+// as ExifTool reports them. Tags that cannot be derived from the path, the
+// file info and the file times are left out.
+func pathExifValues(filePath string, info fs.FileInfo, t FileTime) map[string]interface{} {
+	values := map[string]interface{}{
+		"SourceFile": filepath.ToSlash(filePath),
+		"FileName":   filepath.Base(filePath),
+		"Directory":  filepath.ToSlash(filepath.Dir(filePath)),
+	}
+
+	if info != nil {
+		values["FilePermissions"] = info.Mode().String()
+	}
+
+	for tag, ts := range map[string]time.Time{
+		"FileModifyDate":      t.ModTime,
+		"FileAccessDate":      t.AccessTime,
+		"FileInodeChangeDate": t.ChangeTime,
+		"FileCreateDate":      t.BirthTime,
+	} {
+		if !ts.IsZero() {
+			values[tag] = ts.Format(exifDateLayout)
+		}
+	}
+
+	return values
+}
+
+// shareExif returns a deep copy of exif in which the tags of pathExifTags,
+// in any group, hold the values of own; tags missing from own are removed.
+func shareExif(exif ExifMetadata, own map[string]interface{}) ExifMetadata {
+	if exif == nil {
+		return nil
+	}
+
+	result := make(ExifMetadata, len(exif))
+	for key, v := range exif {
+		_, tag, grouped := strings.Cut(key, ":")
+		if !grouped {
+			tag = key
+		}
+
+		if pathExifTags[tag] {
+			if value, ok := own[tag]; ok {
+				result[key] = value
+			}
+			continue
+		}
+
+		result[key] = cloneExifValue(v, own)
+	}
+
+	return result
+}
+
+// cloneExifValue returns a deep copy of a tag value; the groups of nested
+// metadata (see ExifKeysNested) are copied with shareExif.
+func cloneExifValue(v interface{}, own map[string]interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return map[string]interface{}(shareExif(v, own))
+	case ExifMetadata:
+		return shareExif(v, own)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = cloneExifValue(e, own)
+		}
+		return values
+	}
+
+	return v
+}
+
+// shareContent copies the results of the content stages (hashes, samples,
+// types, EXIF metadata, anomalies, image analysis, content scores, audio
+// fingerprints and media properties) of shared into metadata. The EXIF tags
+// describing the path of the file (e.g., FileName, FileModifyDate) are those
+// of filePath, and the EXIF times are derived from them.
+func (me *MetaExtractor) shareContent(metadata *Metadata, shared *Metadata, filePath string, info fs.FileInfo) {
+	metadata.Hashes = maps.Clone(shared.Hashes)
+	metadata.Entropy = shared.Entropy
+	metadata.Sketch = shared.Sketch
+	metadata.Head = slices.Clone(shared.Head)
+	metadata.Tail = slices.Clone(shared.Tail)
+	metadata.Types = slices.Clone(shared.Types)
+	metadata.Detector = shared.Detector
+	metadata.Magic = shared.Magic
+	metadata.MimeType = shared.MimeType
+	metadata.Exif = shareExif(shared.Exif, pathExifValues(filePath, info, metadata.Time))
+	metadata.ExifBackend = shared.ExifBackend
+	metadata.ExifTimes = me.timeOpts.exifTimes(metadata.Exif)
+	metadata.ExifDropped = slices.Clone(shared.ExifDropped)
+	metadata.Anomalies = slices.Clone(shared.Anomalies)
+	metadata.Analysis = shared.Analysis
+	metadata.ContentScores = maps.Clone(shared.ContentScores)
+	metadata.AudioFingerprint = shared.AudioFingerprint
+	metadata.Media = shared.Media
+	metadata.Summary = shared.Summary
+	metadata.Password = shared.Password
+	metadata.Zip = shared.Zip
+	metadata.Extra = maps.Clone(shared.Extra)
+
+	if shared.BestType != nil {
+		bestType := *shared.BestType
+		metadata.BestType = &bestType
+	}
+
+	if metadata.Provenance != nil {
+		maps.Copy(metadata.Provenance, shared.Provenance)
+	}
+}
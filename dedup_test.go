@@ -0,0 +1,144 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaExtractor_Deduplicate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.jpg":      "\xff\xd8\xff\xe0same",
+		"b.jpg":      "\xff\xd8\xff\xe0diff",
+		"copy/a.txt": "\xff\xd8\xff\xe0same",
+		"copy/c.jpg": "\xff\xd8\xff\xe0same",
+		"d.jpg":      "\xff\xd8\xff\xe0longer",
+		"empty":      "",
+		"empty2":     "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	var calls atomic.Int32
+	detector := DetectorFunc{
+		DetectorName: "counting",
+		Fn: func(string) ([]trid.FileType, error) {
+			calls.Add(1)
+			return []trid.FileType{{Extension: ".jpg", Name: "JPEG image", Probability: 100}}, nil
+		},
+	}
+
+	paths := []string{"a.jpg", "b.jpg", "copy/a.txt", "d.jpg", "copy/c.jpg", "empty", "empty2", "missing"}
+	for i, p := range paths {
+		paths[i] = filepath.Join(dir, filepath.FromSlash(p))
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		calls.Store(0)
+		me := NewMetaExtractor(Options{PureGo: true, Detectors: []Detector{detector}})
+
+		results := me.ExtractBatch(paths)
+		assert.EqualValues(t, 5, calls.Load())
+		for _, r := range results {
+			assert.Empty(t, r.DuplicateOf)
+			assert.Empty(t, r.Aliases)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		calls.Store(0)
+		me := NewMetaExtractor(Options{
+			PureGo:      true,
+			Detectors:   []Detector{detector},
+			Hashes:      []string{"md5"},
+			Deduplicate: true,
+		})
+
+		results := me.ExtractBatch(paths)
+		require.Len(t, results, len(paths))
+		assert.EqualValues(t, 3, calls.Load())
+
+		first, dupTxt, dupJpg := results[0], results[2], results[4]
+		assert.Equal(t, []string{paths[2], paths[4]}, first.Aliases)
+		assert.Empty(t, first.DuplicateOf)
+
+		for _, dup := range []Result{dupTxt, dupJpg} {
+			require.NoError(t, dup.Err)
+			assert.Equal(t, paths[0], dup.DuplicateOf)
+			assert.Equal(t, first.Metadata.Types, dup.Metadata.Types)
+			assert.Equal(t, first.Metadata.Hashes, dup.Metadata.Hashes)
+			assert.Equal(t, filepath.Base(dup.Path), dup.Metadata.Name)
+		}
+		assert.True(t, dupTxt.Metadata.ExtMismatch)
+		assert.False(t, dupJpg.Metadata.ExtMismatch)
+
+		for _, r := range []Result{results[1], results[3], results[5], results[6]} {
+			assert.Empty(t, r.DuplicateOf, r.Path)
+			assert.Empty(t, r.Aliases, r.Path)
+		}
+		assert.ErrorIs(t, results[7].Err, ErrFileNotFound)
+	})
+
+	t.Run("ExtractDir", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Deduplicate: true})
+
+		results, err := me.ExtractDir(dir, WalkOptions{})
+		require.NoError(t, err)
+
+		duplicates := 0
+		for _, r := range results {
+			if r.DuplicateOf != "" {
+				duplicates++
+			}
+		}
+		assert.Equal(t, 2, duplicates)
+	})
+}
+
+func TestShareExif(t *testing.T) {
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("", 2*60*60))
+	own := pathExifValues(filepath.Join("copy", "b.jpg"), nil, FileTime{ModTime: modTime})
+
+	shared := ExifMetadata{
+		"SourceFile":               "a.jpg",
+		"System:FileName":          "a.jpg",
+		"FileModifyDate":           "2020:01:01 00:00:00+00:00",
+		"FileAccessDate":           "2020:01:01 00:00:00+00:00",
+		"Make":                     "Canon",
+		"Keywords":                 []interface{}{"x", "y"},
+		"EXIF":                     map[string]interface{}{"Model": "EOS R6"},
+		"System":                   map[string]interface{}{"Directory": "."},
+		"Composite:ImageSize":      "1x1",
+		"File:FilePermissions":     "-rw-r--r--",
+		"ExifTool:ExifToolVersion": 12.76,
+	}
+
+	exif := shareExif(shared, own)
+	assert.Equal(t, ExifMetadata{
+		"SourceFile":               "copy/b.jpg",
+		"System:FileName":          "b.jpg",
+		"FileModifyDate":           "2024:05:06 07:08:09+02:00",
+		"Make":                     "Canon",
+		"Keywords":                 []interface{}{"x", "y"},
+		"EXIF":                     map[string]interface{}{"Model": "EOS R6"},
+		"System":                   map[string]interface{}{"Directory": "copy"},
+		"Composite:ImageSize":      "1x1",
+		"ExifTool:ExifToolVersion": 12.76,
+	}, exif)
+
+	// The copy is deep.
+	exif["Keywords"].([]interface{})[0] = "z"
+	exif["EXIF"].(map[string]interface{})["Model"] = "R5"
+	assert.Equal(t, "x", shared["Keywords"].([]interface{})[0])
+	assert.Equal(t, "EOS R6", shared["EXIF"].(map[string]interface{})["Model"])
+}
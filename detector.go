@@ -0,0 +1,316 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/attilabuti/trid"
+)
+
+var (
+	// ErrUnknownType is returned by detectors that cannot identify a file.
+	ErrUnknownType = errors.New("unknown file type")
+
+	// ErrTridTimeout is returned when TrID does not finish within
+	// Options.TridTimeout. Extract still returns the metadata extracted by
+	// the other stages together with the error.
+	ErrTridTimeout = errors.New("TrID timed out")
+)
+
+// Detector identifies the type of a file. Detectors are run as an ordered
+// chain configured by Options.Detectors.
+type Detector interface {
+	// Name returns the name of the detector, recorded in Metadata.Detector
+	// when the detector wins.
+	Name() string
+
+	// Detect returns the possible types of the file, sorted by likelihood.
+	Detect(filePath string) ([]trid.FileType, error)
+}
+
+// ContextDetector is implemented by detectors that can abort the detection
+// when a context is done. Other custom detectors are abandoned, and left to
+// finish in the background.
+type ContextDetector interface {
+	Detector
+
+	// DetectContext is like Detect, but returns ctx.Err() when ctx is done.
+	DetectContext(ctx context.Context, filePath string) ([]trid.FileType, error)
+}
+
+// DetectorFunc adapts an ordinary function to the Detector interface.
+type DetectorFunc struct {
+	// DetectorName is the name returned by Name.
+	DetectorName string
+
+	// Fn is the function invoked by Detect.
+	Fn func(filePath string) ([]trid.FileType, error)
+}
+
+// Name returns the name of the detector.
+func (df DetectorFunc) Name() string {
+	return df.DetectorName
+}
+
+// Detect calls df.Fn(filePath).
+func (df DetectorFunc) Detect(filePath string) ([]trid.FileType, error) {
+	return df.Fn(filePath)
+}
+
+// Built-in detectors, in the order of the default fallback chain.
+var (
+	// TridDetector identifies files using TrID, as configured by Options.
+	TridDetector Detector = tridDetector{}
+
+	// MagicDetector identifies files using libmagic through the file command.
+	MagicDetector Detector = magicDetector{cmd: "file"}
+
+	// SignatureDetector identifies files using the built-in signature
+	// detector, without running external tools.
+	SignatureDetector Detector = signatureDetector{}
+
+	// ExtensionDetector derives the type from the file extension alone. It
+	// is only useful as the last resort of a chain.
+	ExtensionDetector Detector = extensionDetector{}
+)
+
+// tridDetector is the TrID detector bound to the TrID configuration of an
+// extractor.
+type tridDetector struct {
+	opts    trid.Options
+	matches int
+}
+
+func (d tridDetector) Name() string {
+	return "trid"
+}
+
+func (d tridDetector) Detect(filePath string) ([]trid.FileType, error) {
+	return d.DetectContext(context.Background(), filePath)
+}
+
+func (d tridDetector) DetectContext(ctx context.Context, filePath string) ([]trid.FileType, error) {
+	if d.matches == 0 {
+		return nil, errors.New("TrID detector is not configured")
+	}
+
+	return runTrid(ctx, d.opts, d.matches, filePath)
+}
+
+// magicDetector runs the file command, which uses libmagic.
+type magicDetector struct {
+	cmd string
+}
+
+func (d magicDetector) Name() string {
+	return "magic"
+}
+
+func (d magicDetector) Detect(filePath string) ([]trid.FileType, error) {
+	return d.DetectContext(context.Background(), filePath)
+}
+
+func (d magicDetector) DetectContext(ctx context.Context, filePath string) ([]trid.FileType, error) {
+	magic, err := runMagic(ctx, d.cmd, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	fileType, ok := magic.fileType()
+	if !ok {
+		return nil, ErrUnknownType
+	}
+
+	return []trid.FileType{fileType}, nil
+}
+
+// signatureDetector wraps the built-in signature detector.
+type signatureDetector struct{}
+
+func (signatureDetector) Name() string {
+	return "signature"
+}
+
+func (signatureDetector) Detect(filePath string) ([]trid.FileType, error) {
+	return signatureAnalysis(filePath)
+}
+
+// extensionDetector derives the type from the file extension using the
+// built-in signatures and the MIME types known to the system.
+type extensionDetector struct{}
+
+func (extensionDetector) Name() string {
+	return "extension"
+}
+
+func (extensionDetector) Detect(filePath string) ([]trid.FileType, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if ext == "" {
+		return nil, nil
+	}
+
+	for _, sig := range signatures {
+		if sig.fileType.Extension != "" && !extMismatch(ext, sig.fileType) {
+			fileType := sig.fileType
+			fileType.Extension = ext
+			fileType.Probability = 10
+			return []trid.FileType{fileType}, nil
+		}
+	}
+
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		mimeType, _, _ = strings.Cut(mimeType, ";")
+		return []trid.FileType{{Extension: ext, Probability: 10, MimeType: mimeType}}, nil
+	}
+
+	return nil, nil
+}
+
+// bindDetector returns the detector bound to the configuration of the
+// extractor. External detectors are bound to the given (possibly wrapped)
+// commands.
+func bindDetector(d Detector, tridOpts trid.Options, tridMatches int, fileCmd string) Detector {
+	switch d.(type) {
+	case tridDetector:
+		return tridDetector{opts: tridOpts, matches: tridMatches}
+	case magicDetector:
+		return magicDetector{cmd: fileCmd}
+	}
+
+	return d
+}
+
+// hasSignatureDetector reports whether the chain contains the
+// SignatureDetector.
+func hasSignatureDetector(detectors []Detector) bool {
+	for _, d := range detectors {
+		if _, ok := d.(signatureDetector); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasMagicDetector reports whether the chain contains the MagicDetector.
+func hasMagicDetector(detectors []Detector) bool {
+	for _, d := range detectors {
+		if _, ok := d.(magicDetector); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isExternalDetector reports whether the detector runs an external tool.
+func isExternalDetector(d Detector) bool {
+	switch d.(type) {
+	case tridDetector, magicDetector:
+		return true
+	}
+
+	return false
+}
+
+// detection is the outcome of the detector chain.
+type detection struct {
+	// types are the types reported by the winning detector.
+	types []trid.FileType
+
+	// detector is the name of the winning detector.
+	detector string
+
+	// votes are the most likely types reported by every detector that ran.
+	votes []typeVote
+
+	// warnings are the errors of the detectors that failed while others
+	// succeeded, reported in Metadata.Warnings.
+	warnings []string
+}
+
+// detectTypes runs the detector chain. Later detectors only run if the
+// earlier ones fail, identify nothing, or report a probability below
+// Options.MinConfidence, unless Options.FuseDetectors is set. The most
+// probable result wins. If every detector fails, the error of the first one
+// is returned; otherwise the errors are kept as warnings. The head of the
+// file, if already read, is passed to the signature detector. The chain
+// stops with ctx.Err() when ctx is done.
+func (me *MetaExtractor) detectTypes(ctx context.Context, filePath string, head []byte) (detection, error) {
+	var (
+		result    detection
+		succeeded bool
+		decided   bool
+		firstErr  error
+	)
+
+	for _, d := range me.detectors {
+		if decided && !me.fuseDetectors {
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			return detection{}, err
+		}
+
+		// An empty path means the file could not be prepared for the
+		// external tools (see Options.BestEffort).
+		if filePath == "" && isExternalDetector(d) {
+			continue
+		}
+
+		var (
+			types []trid.FileType
+			err   error
+		)
+
+		if _, ok := d.(signatureDetector); ok && head != nil {
+			types = detectSignature(head)
+		} else if cd, ok := d.(ContextDetector); ok {
+			types, err = cd.DetectContext(ctx, filePath)
+		} else {
+			types, err = runContext(ctx, func() ([]trid.FileType, error) {
+				return d.Detect(filePath)
+			})
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return detection{}, ctxErr
+		}
+
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			result.warnings = append(result.warnings, fmt.Sprintf("detector %s: %v", d.Name(), err))
+			continue
+		}
+
+		if len(types) > 0 {
+			result.votes = append(result.votes, typeVote{detector: d.Name(), fileType: types[0]})
+		}
+
+		if decided {
+			continue
+		}
+
+		if !succeeded || (len(types) > 0 && (len(result.types) == 0 || types[0].Probability > result.types[0].Probability)) {
+			result.types, result.detector = types, d.Name()
+		}
+		succeeded = true
+
+		if len(types) > 0 && types[0].Probability >= me.minConfidence {
+			decided = true
+		}
+	}
+
+	if !succeeded && firstErr != nil {
+		return detection{}, firstErr
+	}
+
+	return result, nil
+}
@@ -0,0 +1,201 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staticDetector(name string, types []trid.FileType, err error) Detector {
+	return DetectorFunc{
+		DetectorName: name,
+		Fn: func(string) ([]trid.FileType, error) {
+			return types, err
+		},
+	}
+}
+
+func TestDetectTypes(t *testing.T) {
+	errFailed := errors.New("failed")
+	low := []trid.FileType{{Extension: ".txt", Probability: 20}}
+	high := []trid.FileType{{Extension: ".pdf", Probability: 90}}
+	lower := []trid.FileType{{Extension: ".bin", Probability: 5}}
+
+	testCases := []struct {
+		name          string
+		detectors     []Detector
+		minConfidence float64
+		types         []trid.FileType
+		detector      string
+		err           error
+	}{
+		{"First Wins", []Detector{staticDetector("a", low, nil), staticDetector("b", high, nil)}, 0, low, "a", nil},
+		{"Fallback On Error", []Detector{staticDetector("a", nil, errFailed), staticDetector("b", high, nil)}, 0, high, "b", nil},
+		{"Fallback On Empty", []Detector{staticDetector("a", nil, nil), staticDetector("b", high, nil)}, 0, high, "b", nil},
+		{"Fallback On Low Confidence", []Detector{staticDetector("a", low, nil), staticDetector("b", high, nil)}, 50, high, "b", nil},
+		{"Keep Most Probable", []Detector{staticDetector("a", low, nil), staticDetector("b", lower, nil)}, 50, low, "a", nil},
+		{"Nothing Identified", []Detector{staticDetector("a", nil, errFailed), staticDetector("b", nil, nil)}, 0, nil, "b", nil},
+		{"All Fail", []Detector{staticDetector("a", nil, errFailed), staticDetector("b", nil, errors.New("other"))}, 0, nil, "", errFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			me := &MetaExtractor{detectors: tc.detectors, minConfidence: tc.minConfidence}
+
+			result, err := me.detectTypes(context.Background(), "file", nil)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.types, result.types)
+			assert.Equal(t, tc.detector, result.detector)
+			if tc.name == "Fallback On Error" || tc.name == "Nothing Identified" {
+				assert.Equal(t, []string{"detector a: " + errFailed.Error()}, result.warnings)
+			} else {
+				assert.Empty(t, result.warnings)
+			}
+		})
+	}
+}
+
+func TestDetectTypes_Fuse(t *testing.T) {
+	counted := 0
+	counting := DetectorFunc{DetectorName: "c", Fn: func(string) ([]trid.FileType, error) {
+		counted++
+		return []trid.FileType{{Extension: ".pdf", Probability: 50}}, nil
+	}}
+
+	detectors := []Detector{
+		staticDetector("a", []trid.FileType{{Extension: ".pdf", Probability: 90}}, nil),
+		staticDetector("b", nil, errors.New("failed")),
+		counting,
+	}
+
+	me := &MetaExtractor{detectors: detectors}
+	result, err := me.detectTypes(context.Background(), "file", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a", result.detector)
+	assert.Len(t, result.votes, 1)
+	assert.Zero(t, counted)
+
+	me.fuseDetectors = true
+	result, err = me.detectTypes(context.Background(), "file", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a", result.detector)
+	assert.Len(t, result.votes, 2)
+	assert.Equal(t, 1, counted)
+}
+
+func TestExtensionDetector(t *testing.T) {
+	types, err := ExtensionDetector.Detect("photo.JPEG")
+	require.NoError(t, err)
+	require.Len(t, types, 1)
+	assert.Equal(t, ".jpeg", types[0].Extension)
+	assert.Equal(t, "image/jpeg", types[0].MimeType)
+
+	types, err = ExtensionDetector.Detect("README")
+	require.NoError(t, err)
+	assert.Empty(t, types)
+}
+
+func TestMagicDetector(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	types, err := magicDetector{cmd: fakeFileCommand(t, "image/png; charset=binary", "png")}.Detect("image")
+	require.NoError(t, err)
+	require.Len(t, types, 1)
+	assert.Equal(t, ".png", types[0].Extension)
+	assert.Equal(t, "image/png", types[0].MimeType)
+	assert.Equal(t, "PNG image data, 1 x 1, 8-bit/color RGBA", types[0].Name)
+}
+
+func TestTridDetector_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "trid")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 5\n"), 0o755))
+
+	detector := bindDetector(TridDetector, trid.Options{Cmd: script, Timeout: 50 * time.Millisecond}, 1, "")
+
+	_, err := detector.Detect(filepath.Join("testdata", "sample.mp3"))
+	assert.ErrorIs(t, err, ErrTridTimeout)
+
+	t.Run("Fallback", func(t *testing.T) {
+		me := &MetaExtractor{detectors: []Detector{detector, ExtensionDetector}}
+
+		result, err := me.detectTypes(context.Background(), filepath.Join("testdata", "sample.mp3"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "extension", result.detector)
+		require.Len(t, result.warnings, 1)
+		assert.True(t, strings.HasPrefix(result.warnings[0], "detector trid: "+ErrTridTimeout.Error()), result.warnings[0])
+
+		extractor := NewMetaExtractor(Options{PureGo: true})
+		extractor.detectors = me.detectors
+		metadata, err := extractor.Extract(filepath.Join("testdata", "sample.mp3"))
+		require.NoError(t, err)
+		assert.Equal(t, "extension", metadata.Detector)
+		assert.Equal(t, result.warnings, metadata.Warnings, "the failure of TrID is reported")
+	})
+
+	t.Run("Partial Results", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"md5"}})
+		me.detectors = []Detector{detector}
+
+		metadata, err := me.Extract(filepath.Join("testdata", "sample.mp3"))
+		assert.ErrorIs(t, err, ErrTridTimeout)
+		assert.Equal(t, "sample.mp3", metadata.Name)
+		assert.NotEmpty(t, metadata.Hashes["md5"])
+		assert.Empty(t, metadata.Types)
+	})
+}
+
+func TestMetaExtractor_Detectors(t *testing.T) {
+	me := NewMetaExtractor(Options{
+		PureGo:    true,
+		Detectors: []Detector{TridDetector, SignatureDetector, ExtensionDetector},
+	})
+
+	metadata, err := me.Extract(filepath.Join("testdata", "sample.mp3"))
+	require.NoError(t, err)
+	assert.Equal(t, "signature", metadata.Detector)
+	require.NotEmpty(t, metadata.Types)
+	assert.Equal(t, "audio/mpeg", metadata.Types[0].MimeType)
+
+	require.NotNil(t, metadata.BestType)
+	assert.Equal(t, ".mp3", metadata.BestType.Extension)
+	assert.Equal(t, []string{"signature"}, metadata.BestType.Detectors)
+}
+
+func TestMetaExtractor_DetectorFallback(t *testing.T) {
+	if pureGoBuild {
+		t.Skip("external tools are disabled in pure-Go builds")
+	}
+
+	me := NewMetaExtractor(Options{
+		TridPath: filepath.Join(t.TempDir(), "trid"),
+		Backends: []Extractor{TridBackend},
+	})
+
+	metadata, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+	assert.Equal(t, "signature", metadata.Detector)
+	require.NotEmpty(t, metadata.Types)
+	assert.Equal(t, ".pdf", metadata.Types[0].Extension)
+	assert.True(t, metadata.ExtMismatch)
+	assert.Equal(t, "sample.pdf", metadata.SuggestedName)
+}
@@ -0,0 +1,177 @@
+package metaextractor
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DirMetadata contains metadata of a directory.
+type DirMetadata struct {
+	// Name is the base name of the directory.
+	Name string
+
+	// Path is the path of the directory.
+	Path string
+
+	// Mode contains the permissions of the directory.
+	Mode fs.FileMode
+
+	// Time contains various timestamps associated with the directory.
+	Time FileTime
+
+	// Files, Dirs and Others are the numbers of regular files,
+	// subdirectories and other entries (e.g., symbolic links) directly in
+	// the directory.
+	Files  int
+	Dirs   int
+	Others int
+
+	// TotalFiles and TotalDirs are the numbers of regular files and
+	// subdirectories in the whole tree below the directory.
+	TotalFiles int
+	TotalDirs  int
+
+	// TotalSize is the total size of the regular files in the tree in bytes.
+	TotalSize int64
+
+	// Newest and Oldest are the most and the least recently modified
+	// regular files in the tree. They are nil if the tree has no files.
+	Newest *DirChild
+	Oldest *DirChild
+
+	// Incomplete indicates that parts of the tree could not be read, so the
+	// counts and totals are lower bounds.
+	Incomplete bool
+}
+
+// DirChild identifies a file in a directory tree.
+type DirChild struct {
+	// Path is the path of the file.
+	Path string
+
+	// ModTime is the last modification time of the file.
+	ModTime time.Time
+}
+
+// ExtractDirMetadata returns metadata of the directory: its permissions and
+// times, the numbers of entries, and the total size and newest and oldest
+// files of the tree below it.
+func (me *MetaExtractor) ExtractDirMetadata(dirPath string) (DirMetadata, error) {
+	if dirPath == "" {
+		return DirMetadata{}, ErrNoFileSpecified
+	}
+
+	dirs, err := me.collectDirMetadata(dirPath)
+	if err != nil {
+		return DirMetadata{}, err
+	}
+
+	return *dirs[filepath.Clean(dirPath)], nil
+}
+
+// collectDirMetadata walks the tree rooted at root once and returns the
+// metadata of every directory in it, keyed by cleaned path.
+func (me *MetaExtractor) collectDirMetadata(root string) (map[string]*DirMetadata, error) {
+	root = filepath.Clean(root)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: root, Err: fs.ErrInvalid}
+	}
+
+	dirs := make(map[string]*DirMetadata)
+
+	// ancestors calls fn for every directory from the parent of p up to
+	// the root.
+	ancestors := func(p string, fn func(*DirMetadata)) {
+		for p != root {
+			p = filepath.Dir(p)
+			if dir, ok := dirs[p]; ok {
+				fn(dir)
+			}
+		}
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// A directory that could not be read has already been recorded.
+			dir, ok := dirs[p]
+			if !ok && p == root {
+				return err
+			}
+			if ok {
+				dir.Incomplete = true
+			}
+			ancestors(p, func(dir *DirMetadata) { dir.Incomplete = true })
+			return nil
+		}
+
+		if d.IsDir() {
+			if _, ok := dirs[p]; ok {
+				return nil
+			}
+
+			dir := &DirMetadata{Name: me.nameForm.normalize(filepath.Base(p)), Path: p}
+			if info, err := d.Info(); err == nil {
+				dir.Mode = info.Mode()
+			}
+			if fileTime, err := getFileTimes(longPath(p)); err == nil {
+				dir.Time = me.timeOpts.fileTime(fileTime)
+			}
+			dirs[p] = dir
+
+			if p != root {
+				dirs[filepath.Dir(p)].Dirs++
+				ancestors(p, func(dir *DirMetadata) { dir.TotalDirs++ })
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			dirs[filepath.Dir(p)].Others++
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			ancestors(p, func(dir *DirMetadata) { dir.Incomplete = true })
+			return nil
+		}
+
+		child := DirChild{Path: p, ModTime: info.ModTime()}
+		if me.timeOpts.utc {
+			child.ModTime = child.ModTime.UTC()
+		}
+
+		dirs[filepath.Dir(p)].Files++
+		ancestors(p, func(dir *DirMetadata) {
+			dir.TotalFiles++
+			dir.TotalSize += info.Size()
+
+			if dir.Newest == nil || child.ModTime.After(dir.Newest.ModTime) {
+				newest := child
+				dir.Newest = &newest
+			}
+			if dir.Oldest == nil || child.ModTime.Before(dir.Oldest.ModTime) {
+				oldest := child
+				dir.Oldest = &oldest
+			}
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dirs, nil
+}
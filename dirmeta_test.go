@@ -0,0 +1,97 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree creates files with the given contents and modification times.
+func writeTree(t *testing.T, root string, files map[string]time.Time) {
+	t.Helper()
+
+	for name, modTime := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(name), 0o644))
+		require.NoError(t, os.Chtimes(p, modTime, modTime))
+	}
+}
+
+func TestExtractDirMetadata(t *testing.T) {
+	root := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeTree(t, root, map[string]time.Time{
+		"a.txt":         base.Add(2 * time.Hour),
+		"sub/b.txt":     base,
+		"sub/deep/c.go": base.Add(5 * time.Hour),
+	})
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "empty"), 0o755))
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(root, "link")))
+
+	me := NewMetaExtractor(Options{PureGo: true, UTC: true})
+
+	dir, err := me.ExtractDirMetadata(root + string(filepath.Separator))
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Base(root), dir.Name)
+	assert.True(t, dir.Mode.IsDir())
+	assert.Equal(t, 1, dir.Files)
+	assert.Equal(t, 2, dir.Dirs)
+	assert.Equal(t, 1, dir.Others)
+	assert.Equal(t, 3, dir.TotalFiles)
+	assert.Equal(t, 3, dir.TotalDirs)
+	assert.Equal(t, int64(len("a.txt")+len("sub/b.txt")+len("sub/deep/c.go")), dir.TotalSize)
+	require.NotNil(t, dir.Newest)
+	assert.Equal(t, filepath.Join(root, "sub", "deep", "c.go"), dir.Newest.Path)
+	assert.Equal(t, base.Add(5*time.Hour), dir.Newest.ModTime)
+	require.NotNil(t, dir.Oldest)
+	assert.Equal(t, filepath.Join(root, "sub", "b.txt"), dir.Oldest.Path)
+	assert.False(t, dir.Incomplete)
+
+	empty, err := me.ExtractDirMetadata(filepath.Join(root, "empty"))
+	require.NoError(t, err)
+	assert.Zero(t, empty.TotalFiles)
+	assert.Nil(t, empty.Newest)
+
+	_, err = me.ExtractDirMetadata(filepath.Join(root, "missing"))
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	_, err = me.ExtractDirMetadata(filepath.Join(root, "a.txt"))
+	assert.Error(t, err)
+}
+
+func TestExtractDir_Dirs(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]time.Time{
+		"a.txt":     time.Now(),
+		"sub/b.txt": time.Now(),
+		"skip/c.go": time.Now(),
+	})
+
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	results, err := me.ExtractDir(root, WalkOptions{Dirs: true, Exclude: []string{"skip"}})
+	require.NoError(t, err)
+
+	dirs := make(map[string]*DirMetadata)
+	files := 0
+	for _, r := range results {
+		require.NoError(t, r.Err)
+		if r.Dir != nil {
+			dirs[r.Path] = r.Dir
+		} else {
+			files++
+		}
+	}
+
+	assert.Equal(t, 2, files)
+	require.Len(t, dirs, 2)
+	assert.Equal(t, 3, dirs[root].TotalFiles, "totals cover the whole tree")
+	assert.Equal(t, 1, dirs[filepath.Join(root, "sub")].Files)
+}
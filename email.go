@@ -0,0 +1,207 @@
+package metaextractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// emailFields are the header fields identifying a file as an email; at
+// least two of them must appear in the header at the start of the file.
+var emailFields = map[string]bool{
+	"from":           true,
+	"to":             true,
+	"cc":             true,
+	"subject":        true,
+	"date":           true,
+	"message-id":     true,
+	"mime-version":   true,
+	"received":       true,
+	"return-path":    true,
+	"delivered-to":   true,
+	"reply-to":       true,
+	"dkim-signature": true,
+	"x-mailer":       true,
+}
+
+// isEmailHeader reports whether the block starts with the header of an
+// email (RFC 5322): header fields, possibly folded, including at least two
+// well-known ones.
+func isEmailHeader(block []byte) bool {
+	lines := bytes.Split(block, []byte("\n"))
+	if len(lines) > 1 {
+		// The last line may be cut off.
+		lines = lines[:len(lines)-1]
+	}
+
+	known := make(map[string]bool)
+	for i, line := range lines {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			break
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if i == 0 {
+				return false
+			}
+			continue
+		}
+
+		name, _, ok := bytes.Cut(line, []byte(":"))
+		if !ok || len(name) == 0 || bytes.ContainsAny(name, " \t") {
+			return false
+		}
+
+		if field := strings.ToLower(string(name)); emailFields[field] {
+			known[field] = true
+		}
+	}
+
+	return len(known) >= 2
+}
+
+// attachmentInfo describes an email attachment as an fs.FileInfo.
+type attachmentInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi attachmentInfo) Name() string       { return path.Base(fi.name) }
+func (fi attachmentInfo) Size() int64        { return fi.size }
+func (fi attachmentInfo) Mode() fs.FileMode  { return 0o444 }
+func (fi attachmentInfo) ModTime() time.Time { return fi.modTime }
+func (fi attachmentInfo) IsDir() bool        { return false }
+func (fi attachmentInfo) Sys() interface{}   { return nil }
+
+// walkEmail processes the attachments of an email (RFC 5322 with MIME
+// parts). Parts with a file name or an attachment disposition, and
+// attached messages (message/rfc822), are extracted as entries, and
+// attached messages are descended into in turn.
+func (me *MetaExtractor) walkEmail(ctx context.Context, filePath, virtualPath string, depth int, accept entryFilter, emit func(Result)) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+
+	// Attachments without a modification date are dated by the message.
+	date, _ := msg.Header.Date()
+
+	w := emailWalker{ctx: ctx, me: me, virtualPath: virtualPath, depth: depth, accept: accept, emit: emit, date: date}
+	return w.part(textproto.MIMEHeader(msg.Header), msg.Body)
+}
+
+// emailWalker walks the MIME parts of an email.
+type emailWalker struct {
+	ctx         context.Context
+	me          *MetaExtractor
+	virtualPath string
+	depth       int
+	accept      entryFilter
+	emit        func(Result)
+	date        time.Time
+	count       int
+}
+
+// part processes a MIME part, descending into multipart bodies.
+func (w *emailWalker) part(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := w.part(p.Header, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+
+	name := dispParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if disposition != "attachment" && name == "" && mediaType != "message/rfc822" {
+		return nil
+	}
+
+	w.count++
+	name = decodeHeaderWord(name)
+	if name == "" {
+		name = fmt.Sprintf("attachment-%d", w.count)
+		if mediaType == "message/rfc822" {
+			name += ".eml"
+		}
+	}
+	entryPath := joinEntryPath(w.virtualPath, name)
+
+	data, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		w.emit(Result{Path: entryPath, Parent: w.virtualPath, Depth: w.depth + 1, Err: fmt.Errorf("error decoding attachment: %w", err)})
+		return nil
+	}
+
+	info := attachmentInfo{name: name, size: int64(len(data)), modTime: w.date}
+	if modified, err := mail.ParseDate(dispParams["modification-date"]); err == nil {
+		info.modTime = modified
+	}
+
+	w.me.extractEntry(w.ctx, bytes.NewReader(data), name, info, entryPath, w.virtualPath, w.depth, w.accept, w.emit)
+
+	return nil
+}
+
+// decodeTransfer returns a reader decoding the body of a part with the
+// given Content-Transfer-Encoding.
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+
+	return body
+}
+
+// decodeHeaderWord decodes the encoded words (RFC 2047) of a file name.
+func decodeHeaderWord(s string) string {
+	var dec mime.WordDecoder
+	if decoded, err := dec.DecodeHeader(s); err == nil {
+		return decoded
+	}
+
+	return s
+}
@@ -0,0 +1,139 @@
+package metaextractor
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsEmailHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		block  string
+		expect bool
+	}{
+		{"Email", "From: a@example.com\r\nTo: b@example.com\r\nSubject: Hi\r\n\r\nBody", true},
+		{"Folded", "Received: from mx.example.com\n\tby mx2.example.com\nReceived: from host\nDate: Mon, 1 Jan 2024 00:00:00 +0000\n", true},
+		{"Cut Off", "Return-Path: <a@example.com>\nDelivered-To: b@example.com\nX-Very-Long-Hea", true},
+		{"One Field", "Subject: Hi\n\nBody", false},
+		{"Unknown Fields", "Name: value\nOther: value\n\n", false},
+		{"Not a Header", "From: a@example.com\nthis is text\nTo: b@example.com\n", false},
+		{"Continuation First", " From: a@example.com\nTo: b@example.com\n", false},
+		{"Text", "Hello, world!\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, isEmailHeader([]byte(tt.block)))
+		})
+	}
+}
+
+func TestWalkEmail(t *testing.T) {
+	inner := strings.Join([]string{
+		"From: c@example.com",
+		"To: a@example.com",
+		"Subject: Invoice",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="inner"`,
+		"",
+		"--inner",
+		"Content-Type: text/plain",
+		"",
+		"Please see the attachment.",
+		"--inner",
+		`Content-Type: application/zip; name="invoice.zip"`,
+		"Content-Transfer-Encoding: base64",
+		"",
+		base64.StdEncoding.EncodeToString(zipBytes(t, map[string][]byte{"invoice.exe": []byte("MZ\x90\x00")})),
+		"--inner--",
+		"",
+	}, "\r\n")
+
+	outer := strings.Join([]string{
+		"From: a@example.com",
+		"To: b@example.com",
+		"Subject: Fwd: Invoice",
+		"Date: Tue, 5 Mar 2024 09:30:00 +0100",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="outer"`,
+		"",
+		"--outer",
+		`Content-Type: multipart/alternative; boundary="alt"`,
+		"",
+		"--alt",
+		"Content-Type: text/plain",
+		"",
+		"FYI",
+		"--alt",
+		"Content-Type: text/html",
+		"",
+		"<p>FYI</p>",
+		"--alt--",
+		"--outer",
+		"Content-Type: text/plain; charset=utf-8",
+		`Content-Disposition: attachment; filename="=?utf-8?q?r=C3=A9sum=C3=A9.txt?="; modification-date="Mon, 4 Mar 2024 12:00:00 +0000"`,
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		"caf=C3=A9",
+		"--outer",
+		"Content-Type: message/rfc822",
+		"",
+		inner,
+		"--outer--",
+		"",
+	}, "\r\n")
+
+	root := createTree(t, map[string]string{"mail.eml": outer})
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	results, err := me.ExtractDir(root, WalkOptions{Archives: true})
+	require.NoError(t, err)
+
+	mail := filepath.Join(root, "mail.eml")
+	byPath := make(map[string]Result)
+	for _, r := range results {
+		require.NoError(t, r.Err, r.Path)
+		byPath[r.Path] = r
+	}
+
+	var paths []string
+	for p := range byPath {
+		paths = append(paths, p)
+	}
+	assert.ElementsMatch(t, []string{
+		mail,
+		mail + "!/résumé.txt",
+		mail + "!/attachment-2.eml",
+		mail + "!/attachment-2.eml!/invoice.zip",
+		mail + "!/attachment-2.eml!/invoice.zip!/invoice.exe",
+	}, paths)
+
+	resume := byPath[mail+"!/résumé.txt"]
+	assert.Equal(t, mail, resume.Parent)
+	assert.Equal(t, 1, resume.Depth)
+	assert.Equal(t, int64(len("café")), resume.Metadata.Size)
+	assert.True(t, time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC).Equal(resume.Metadata.Time.ModTime))
+
+	forwarded := byPath[mail+"!/attachment-2.eml"]
+	assert.Equal(t, 1, forwarded.Depth)
+	assert.True(t, time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC).Equal(forwarded.Metadata.Time.ModTime), "attachments are dated by the message")
+
+	exe := byPath[mail+"!/attachment-2.eml!/invoice.zip!/invoice.exe"]
+	assert.Equal(t, mail+"!/attachment-2.eml!/invoice.zip", exe.Parent)
+	assert.Equal(t, 3, exe.Depth)
+	assert.Equal(t, mail+"!/attachment-2.eml", byPath[exe.Parent].Parent)
+
+	t.Run("Filtered", func(t *testing.T) {
+		results, err := me.ExtractDir(root, WalkOptions{Archives: true, Extensions: []string{".exe"}})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, mail+"!/attachment-2.eml!/invoice.zip!/invoice.exe", results[0].Path)
+		assert.Equal(t, 3, results[0].Depth)
+	})
+}
@@ -0,0 +1,153 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultBudgetMinFiles is the number of extracted files after which the
+// error budgets are enforced if ErrorBudget.MinFiles is not set.
+const DefaultBudgetMinFiles = 20
+
+// ErrorBudget aborts batch runs (ExtractBatch, ExtractDir and
+// ExtractStream) when a stage fails on too many files, which usually
+// indicates a broken installation rather than broken files, e.g. ExifTool
+// failing on more than 20% of the files.
+type ErrorBudget struct {
+	// Stage is the name of the stage, as reported in the audit log (e.g.,
+	// "detect", "exif", "magic" or "backend:ffprobe"). A stage fails on a
+	// file if it fails the extraction or, in best-effort mode, adds a
+	// warning.
+	Stage string
+
+	// MaxRate is the maximum fraction (0-1) of the extracted files the
+	// stage may fail on.
+	MaxRate float64
+
+	// MinFiles is the number of extracted files after which the budget is
+	// enforced, so that a few failures at the start of a run do not abort
+	// it. Defaults to DefaultBudgetMinFiles.
+	MinFiles int
+}
+
+// ErrBudgetExceeded is matched by the BudgetError of the files not
+// extracted because an error budget was exceeded.
+var ErrBudgetExceeded = errors.New("error budget exceeded")
+
+// BudgetError is the error of the files of a batch run that were not
+// extracted because a stage exceeded its error budget (see
+// Options.ErrorBudgets). It describes why the run was aborted.
+type BudgetError struct {
+	// Stage is the name of the stage that exceeded its budget.
+	Stage string
+
+	// Failures is the number of files the stage failed on.
+	Failures int
+
+	// Files is the number of files extracted before the run was aborted.
+	Files int
+
+	// MaxRate is the exceeded ErrorBudget.MaxRate.
+	MaxRate float64
+}
+
+// Error returns the abort reason.
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("batch aborted: stage %q failed on %d of %d files (%.0f%%), exceeding its error budget of %.0f%%",
+		e.Stage, e.Failures, e.Files, 100*float64(e.Failures)/float64(e.Files), 100*e.MaxRate)
+}
+
+// Is reports whether target is ErrBudgetExceeded.
+func (e *BudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// budgetTracker counts the failed stages of the files of a batch run. It is
+// safe for concurrent use.
+type budgetTracker struct {
+	budgets []ErrorBudget
+
+	mu       sync.Mutex
+	files    int
+	failures map[string]int
+	exceeded *BudgetError
+}
+
+// newBudgetTracker returns a tracker of the error budgets of a batch run,
+// or nil if no budget is configured.
+func (me *MetaExtractor) newBudgetTracker() *budgetTracker {
+	if len(me.errorBudgets) == 0 {
+		return nil
+	}
+
+	return &budgetTracker{budgets: me.errorBudgets, failures: make(map[string]int)}
+}
+
+// record records an extracted file and the stages that failed on it.
+func (b *budgetTracker) record(failed []string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.files++
+	for _, stage := range failed {
+		b.failures[stage]++
+	}
+
+	if b.exceeded != nil {
+		return
+	}
+
+	for _, budget := range b.budgets {
+		minFiles := budget.MinFiles
+		if minFiles <= 0 {
+			minFiles = DefaultBudgetMinFiles
+		}
+
+		failures := b.failures[budget.Stage]
+		if b.files >= minFiles && float64(failures) > budget.MaxRate*float64(b.files) {
+			b.exceeded = &BudgetError{Stage: budget.Stage, Failures: failures, Files: b.files, MaxRate: budget.MaxRate}
+			return
+		}
+	}
+}
+
+// err returns the BudgetError if a budget was exceeded, or nil.
+func (b *budgetTracker) err() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.exceeded == nil {
+		return nil
+	}
+
+	return b.exceeded
+}
+
+// budgetKey is the context key of the budget tracker of a batch run.
+type budgetKey struct{}
+
+// withBudget returns ctx carrying the budget tracker, which records the
+// files extracted with it.
+func withBudget(ctx context.Context, b *budgetTracker) context.Context {
+	if b == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// budgetFromContext returns the budget tracker of ctx, or nil.
+func budgetFromContext(ctx context.Context) *budgetTracker {
+	b, _ := ctx.Value(budgetKey{}).(*budgetTracker)
+	return b
+}
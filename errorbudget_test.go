@@ -0,0 +1,152 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyBackend returns a backend failing on the files whose name contains
+// "bad".
+func flakyBackend() Extractor {
+	return BackendFunc{
+		BackendName: "flaky",
+		Fn: func(ctx context.Context, filePath string, metadata *Metadata) error {
+			if strings.Contains(filepath.Base(filePath), "bad") {
+				return errors.New("broken install")
+			}
+			return nil
+		},
+	}
+}
+
+// budgetFiles writes files named by names into a new directory and returns
+// their paths.
+func budgetFiles(t *testing.T, names ...string) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(paths[i], []byte("content of "+name), 0o644))
+	}
+
+	return paths
+}
+
+func TestErrorBudgets(t *testing.T) {
+	paths := budgetFiles(t, "1-ok.txt", "2-bad.txt", "3-bad.txt", "4-ok.txt", "5-ok.txt", "6-ok.txt")
+	budgets := []ErrorBudget{{Stage: "backend:flaky", MaxRate: 0.5, MinFiles: 3}}
+
+	t.Run("Batch", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{TridBackend, flakyBackend()}, ErrorBudgets: budgets})
+
+		results := me.ExtractBatch(paths)
+		require.Len(t, results, 6)
+		assert.NoError(t, results[0].Err)
+		assert.ErrorContains(t, results[1].Err, "broken install")
+		assert.ErrorContains(t, results[2].Err, "broken install")
+
+		for _, r := range results[3:] {
+			assert.ErrorIs(t, r.Err, ErrBudgetExceeded)
+			assert.EqualError(t, r.Err, `batch aborted: stage "backend:flaky" failed on 2 of 3 files (67%), exceeding its error budget of 50%`)
+
+			var budgetErr *BudgetError
+			require.ErrorAs(t, r.Err, &budgetErr)
+			assert.Equal(t, BudgetError{Stage: "backend:flaky", Failures: 2, Files: 3, MaxRate: 0.5}, *budgetErr)
+		}
+
+		// Budgets are per run.
+		results = me.ExtractBatch(paths[:1])
+		assert.NoError(t, results[0].Err)
+	})
+
+	t.Run("BestEffort", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			PureGo:       true,
+			BestEffort:   true,
+			Progress:     func(done, total int, current string) {},
+			Backends:     []Extractor{TridBackend, flakyBackend()},
+			ErrorBudgets: budgets,
+		})
+
+		results := me.ExtractBatch(paths)
+		require.Len(t, results, 6)
+		assert.NoError(t, results[1].Err)
+		assert.Len(t, results[1].Metadata.Warnings, 1)
+		assert.ErrorIs(t, results[5].Err, ErrBudgetExceeded)
+	})
+
+	t.Run("Dir", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{TridBackend, flakyBackend()}, ErrorBudgets: budgets})
+
+		results, err := me.ExtractDir(filepath.Dir(paths[0]), WalkOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 6)
+		assert.ErrorIs(t, results[5].Err, ErrBudgetExceeded)
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{TridBackend, flakyBackend()}, ErrorBudgets: budgets})
+
+		in := make(chan string, len(paths))
+		for _, p := range paths {
+			in <- p
+		}
+		close(in)
+
+		var results []Result
+		for r := range me.ExtractStream(context.Background(), in) {
+			results = append(results, r)
+		}
+		require.Len(t, results, 6)
+		assert.ErrorIs(t, results[5].Err, ErrBudgetExceeded)
+	})
+
+	t.Run("WithinBudget", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			PureGo:       true,
+			Backends:     []Extractor{TridBackend, flakyBackend()},
+			ErrorBudgets: []ErrorBudget{{Stage: "backend:flaky", MaxRate: 0.5}, {Stage: "exif", MaxRate: 0}},
+		})
+
+		for _, r := range me.ExtractBatch(paths) {
+			assert.NotErrorIs(t, r.Err, ErrBudgetExceeded)
+		}
+	})
+}
+
+func TestMetaExtractor_AuditFailedStages(t *testing.T) {
+	var buf bytes.Buffer
+
+	me := NewMetaExtractor(Options{
+		PureGo:     true,
+		BestEffort: true,
+		Backends:   []Extractor{TridBackend, flakyBackend()},
+		Audit:      AuditOptions{Writer: &buf},
+	})
+
+	paths := budgetFiles(t, "bad.txt")
+	_, err := me.Extract(paths[0])
+	require.NoError(t, err)
+
+	records := readAudit(t, buf.Bytes())
+	require.Len(t, records, 1)
+
+	var failed []string
+	for _, s := range records[0].Stages {
+		if s.Failed {
+			failed = append(failed, s.Name)
+		}
+	}
+	assert.Equal(t, []string{"backend:flaky"}, failed, fmt.Sprint(records[0].Stages))
+}
@@ -0,0 +1,145 @@
+package metaextractor
+
+import (
+	"sort"
+	"strings"
+)
+
+// ExifKeyMode selects how tags present in several metadata groups (e.g.,
+// DateTimeOriginal in both EXIF and XMP) are represented in ExifMetadata.
+type ExifKeyMode int
+
+const (
+	// ExifKeysDefault uses plain tag names and keeps the value ExifTool
+	// chooses for duplicate tags.
+	ExifKeysDefault ExifKeyMode = iota
+
+	// ExifKeysDeduplicate uses plain tag names. Duplicate tags are resolved
+	// using the group precedence order (Options.ExifPrecedence).
+	ExifKeysDeduplicate
+
+	// ExifKeysGrouped exposes every tag with its family 0 group as a prefix
+	// (e.g., "EXIF:DateTimeOriginal", "XMP:DateTimeOriginal").
+	ExifKeysGrouped
+
+	// ExifKeysNested nests every tag under its family 0 group, so that
+	// Exif["EXIF"] is a map[string]interface{} holding the EXIF tags. Keys
+	// without a group (e.g., SourceFile) stay at the top level.
+	ExifKeysNested
+)
+
+// DefaultExifPrecedence is the group precedence used to resolve duplicate
+// tags in ExifKeysDeduplicate mode: values stored in the file's native
+// metadata win over derived and file system values. Groups not listed rank
+// after the listed ones, in alphabetical order.
+var DefaultExifPrecedence = []string{
+	"EXIF", "MakerNotes", "QuickTime", "IPTC", "XMP", "ICC_Profile", "Photoshop",
+	"PDF", "ID3", "RIFF", "PNG", "JFIF", "Composite", "File", "ExifTool",
+}
+
+// Get returns the value of a tag. If the tag is not present under its plain
+// name, group-prefixed keys (e.g., "EXIF:Make" for "Make") and nested groups
+// are searched in the order of DefaultExifPrecedence. A tag with a group
+// (e.g., "XMP:Creator") also matches the tag in the nested group.
+func (em ExifMetadata) Get(tag string) (interface{}, bool) {
+	return em.GetPrecedence(tag, nil)
+}
+
+// GetPrecedence is like Get, but searches the groups in the given precedence
+// order, such as the Options.ExifPrecedence of the extractor. A nil order is
+// DefaultExifPrecedence. Metadata.Normalized, Metadata.GPS and
+// Metadata.ExifTimes are resolved with Options.ExifPrecedence.
+func (em ExifMetadata) GetPrecedence(tag string, precedence []string) (interface{}, bool) {
+	_, v, ok := lookupExif(em, tag, precedence)
+	return v, ok
+}
+
+// exifValue returns the value of the tag, or nil if it is not present.
+// Groups are searched in the precedence order, if given.
+func exifValue(exif ExifMetadata, tag string, precedence ...string) interface{} {
+	v, _ := exif.GetPrecedence(tag, precedence)
+	return v
+}
+
+// groupRank orders groups by their position in a precedence list, then by
+// name.
+type groupRank struct {
+	index int
+	group string
+}
+
+func (r groupRank) less(o groupRank) bool {
+	if r.index != o.index {
+		return r.index < o.index
+	}
+	return r.group < o.group
+}
+
+// rankGroup returns the rank of the group in the precedence list, or in
+// DefaultExifPrecedence if the list is nil.
+func rankGroup(group string, precedence []string) groupRank {
+	if precedence == nil {
+		precedence = DefaultExifPrecedence
+	}
+
+	for i, g := range precedence {
+		if strings.EqualFold(g, group) {
+			return groupRank{index: i, group: group}
+		}
+	}
+
+	return groupRank{index: len(precedence), group: group}
+}
+
+// deduplicateExif folds group-prefixed keys into plain tag names. For tags
+// present in several groups, the value of the group ranking first in the
+// precedence list is kept.
+func deduplicateExif(exif ExifMetadata, precedence []string) ExifMetadata {
+	keys := make([]string, 0, len(exif))
+	for key := range exif {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make(ExifMetadata, len(exif))
+	ranks := make(map[string]groupRank, len(exif))
+
+	for _, key := range keys {
+		group, name, ok := strings.Cut(key, ":")
+		if !ok {
+			// Keys without a group (e.g., SourceFile) are kept as is.
+			result[key] = exif[key]
+			continue
+		}
+
+		rank := rankGroup(group, precedence)
+		if current, exists := ranks[name]; !exists || rank.less(current) {
+			result[name] = exif[key]
+			ranks[name] = rank
+		}
+	}
+
+	return result
+}
+
+// nestExif moves group-prefixed keys into a map per group.
+func nestExif(exif ExifMetadata) ExifMetadata {
+	result := make(ExifMetadata)
+
+	for key, v := range exif {
+		group, name, ok := strings.Cut(key, ":")
+		if !ok {
+			result[key] = v
+			continue
+		}
+
+		tags, ok := result[group].(map[string]interface{})
+		if !ok {
+			tags = make(map[string]interface{})
+			result[group] = tags
+		}
+		tags[name] = v
+	}
+
+	return result
+}
@@ -0,0 +1,111 @@
+package metaextractor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExifMetadata_Get(t *testing.T) {
+	exif := ExifMetadata{
+		"SourceFile":            "a.jpg",
+		"XMP:DateTimeOriginal":  "2024:01:02 10:00:00",
+		"EXIF:DateTimeOriginal": "2024:01:02 09:00:00",
+		"Vendor:Model":          "X100",
+	}
+
+	testCases := []struct {
+		name     string
+		tag      string
+		expected interface{}
+		found    bool
+	}{
+		{"Plain Key", "SourceFile", "a.jpg", true},
+		{"Grouped By Precedence", "DateTimeOriginal", "2024:01:02 09:00:00", true},
+		{"Unlisted Group", "Model", "X100", true},
+		{"Missing", "Make", nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, ok := exif.Get(tc.tag)
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.expected, v)
+		})
+	}
+
+	t.Run("Configured Precedence", func(t *testing.T) {
+		v, ok := exif.GetPrecedence("DateTimeOriginal", []string{"XMP", "EXIF"})
+		assert.True(t, ok)
+		assert.Equal(t, "2024:01:02 10:00:00", v)
+	})
+}
+
+func TestRankGroup(t *testing.T) {
+	precedence := []string{"EXIF", "XMP"}
+
+	assert.Equal(t, groupRank{0, "exif"}, rankGroup("exif", precedence))
+	assert.Equal(t, groupRank{1, "XMP"}, rankGroup("XMP", precedence))
+	assert.Equal(t, groupRank{2, "File"}, rankGroup("File", precedence))
+	assert.True(t, rankGroup("File", precedence).less(rankGroup("PNG", precedence)))
+}
+
+func TestDeduplicateExif(t *testing.T) {
+	exif := ExifMetadata{
+		"SourceFile":            "a.jpg",
+		"EXIF:DateTimeOriginal": "exif",
+		"XMP:DateTimeOriginal":  "xmp",
+		"File:FileType":         "JPEG",
+		"PNG:Make":              "png",
+		"Other:Make":            "other",
+	}
+
+	t.Run("Default Precedence", func(t *testing.T) {
+		assert.Equal(t, ExifMetadata{
+			"SourceFile":       "a.jpg",
+			"DateTimeOriginal": "exif",
+			"FileType":         "JPEG",
+			"Make":             "png",
+		}, deduplicateExif(exif, DefaultExifPrecedence))
+	})
+
+	t.Run("Custom Precedence", func(t *testing.T) {
+		assert.Equal(t, ExifMetadata{
+			"SourceFile":       "a.jpg",
+			"DateTimeOriginal": "xmp",
+			"FileType":         "JPEG",
+			"Make":             "other",
+		}, deduplicateExif(exif, []string{"XMP", "Other"}))
+	})
+}
+
+func TestIsPasswordProtected_Grouped(t *testing.T) {
+	assert.True(t, isPasswordProtected(ExifMetadata{"ExifTool:Warning": "File is password protected"}))
+}
+
+func TestNestExif(t *testing.T) {
+	nested := nestExif(ExifMetadata{
+		"SourceFile":            "a.jpg",
+		"EXIF:DateTimeOriginal": "exif",
+		"EXIF:Make":             "Canon",
+		"XMP:DateTimeOriginal":  "xmp",
+	})
+
+	assert.Equal(t, ExifMetadata{
+		"SourceFile": "a.jpg",
+		"EXIF": map[string]interface{}{
+			"DateTimeOriginal": "exif",
+			"Make":             "Canon",
+		},
+		"XMP": map[string]interface{}{
+			"DateTimeOriginal": "xmp",
+		},
+	}, nested)
+
+	v, ok := nested.Get("DateTimeOriginal")
+	assert.True(t, ok)
+	assert.Equal(t, "exif", v)
+
+	_, ok = nested.Get("Model")
+	assert.False(t, ok)
+}
@@ -0,0 +1,825 @@
+package metaextractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the native EXIF parser, protecting it against corrupt files.
+const (
+	// maxIFDEntries is the maximum number of entries read from an IFD.
+	maxIFDEntries = 1024
+
+	// maxTIFFValueSize is the maximum size of a tag value in bytes; larger
+	// values (e.g., the strip offsets of huge TIFF images) are skipped.
+	maxTIFFValueSize = 1 << 20
+
+	// maxEXIfChunkSize is the maximum size of the eXIf chunk of PNG images.
+	maxEXIfChunkSize = 16 << 20
+)
+
+// TIFF tags pointing to sub-IFDs.
+const (
+	tiffExifIFD = 0x8769
+	tiffGPSIFD  = 0x8825
+)
+
+// TIFF field types.
+const (
+	tiffByte      = 1
+	tiffASCII     = 2
+	tiffShort     = 3
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffSByte     = 6
+	tiffUndefined = 7
+	tiffSShort    = 8
+	tiffSLong     = 9
+	tiffSRational = 10
+	tiffFloat     = 11
+	tiffDouble    = 12
+	tiffIFD       = 13
+)
+
+// tiffTypeSizes are the sizes in bytes of the TIFF field types.
+var tiffTypeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8, 4}
+
+// exifTagNames are the ExifTool names of the tags of IFD0 and the EXIF IFD
+// reported by the native parser.
+var exifTagNames = map[uint16]string{
+	0x00fe: "SubfileType",
+	0x0100: "ImageWidth",
+	0x0101: "ImageHeight",
+	0x0102: "BitsPerSample",
+	0x0103: "Compression",
+	0x0106: "PhotometricInterpretation",
+	0x010d: "DocumentName",
+	0x010e: "ImageDescription",
+	0x010f: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x0115: "SamplesPerPixel",
+	0x011a: "XResolution",
+	0x011b: "YResolution",
+	0x011c: "PlanarConfiguration",
+	0x0128: "ResolutionUnit",
+	0x0131: "Software",
+	0x0132: "ModifyDate",
+	0x013b: "Artist",
+	0x013e: "WhitePoint",
+	0x013f: "PrimaryChromaticities",
+	0x0211: "YCbCrCoefficients",
+	0x0213: "YCbCrPositioning",
+	0x0214: "ReferenceBlackWhite",
+	0x8298: "Copyright",
+	0x829a: "ExposureTime",
+	0x829d: "FNumber",
+	0x8822: "ExposureProgram",
+	0x8827: "ISO",
+	0x8830: "SensitivityType",
+	0x8832: "RecommendedExposureIndex",
+	0x9000: "ExifVersion",
+	0x9003: "DateTimeOriginal",
+	0x9004: "CreateDate",
+	0x9010: "OffsetTime",
+	0x9011: "OffsetTimeOriginal",
+	0x9012: "OffsetTimeDigitized",
+	0x9101: "ComponentsConfiguration",
+	0x9102: "CompressedBitsPerPixel",
+	0x9201: "ShutterSpeedValue",
+	0x9202: "ApertureValue",
+	0x9203: "BrightnessValue",
+	0x9204: "ExposureCompensation",
+	0x9205: "MaxApertureValue",
+	0x9206: "SubjectDistance",
+	0x9207: "MeteringMode",
+	0x9208: "LightSource",
+	0x9209: "Flash",
+	0x920a: "FocalLength",
+	0x9214: "SubjectArea",
+	0x9286: "UserComment",
+	0x9290: "SubSecTime",
+	0x9291: "SubSecTimeOriginal",
+	0x9292: "SubSecTimeDigitized",
+	0xa000: "FlashpixVersion",
+	0xa001: "ColorSpace",
+	0xa002: "ExifImageWidth",
+	0xa003: "ExifImageHeight",
+	0xa004: "RelatedSoundFile",
+	0xa20e: "FocalPlaneXResolution",
+	0xa20f: "FocalPlaneYResolution",
+	0xa210: "FocalPlaneResolutionUnit",
+	0xa215: "ExposureIndex",
+	0xa217: "SensingMethod",
+	0xa300: "FileSource",
+	0xa301: "SceneType",
+	0xa401: "CustomRendered",
+	0xa402: "ExposureMode",
+	0xa403: "WhiteBalance",
+	0xa404: "DigitalZoomRatio",
+	0xa405: "FocalLengthIn35mmFormat",
+	0xa406: "SceneCaptureType",
+	0xa407: "GainControl",
+	0xa408: "Contrast",
+	0xa409: "Saturation",
+	0xa40a: "Sharpness",
+	0xa40b: "DeviceSettingDescription",
+	0xa40c: "SubjectDistanceRange",
+	0xa420: "ImageUniqueID",
+	0xa430: "OwnerName",
+	0xa431: "SerialNumber",
+	0xa432: "LensInfo",
+	0xa433: "LensMake",
+	0xa434: "LensModel",
+	0xa435: "LensSerialNumber",
+}
+
+// gpsTagNames are the ExifTool names of the tags of the GPS IFD.
+var gpsTagNames = map[uint16]string{
+	0x0000: "GPSVersionID",
+	0x0001: "GPSLatitudeRef",
+	0x0002: "GPSLatitude",
+	0x0003: "GPSLongitudeRef",
+	0x0004: "GPSLongitude",
+	0x0005: "GPSAltitudeRef",
+	0x0006: "GPSAltitude",
+	0x0007: "GPSTimeStamp",
+	0x0008: "GPSSatellites",
+	0x0009: "GPSStatus",
+	0x000a: "GPSMeasureMode",
+	0x000b: "GPSDOP",
+	0x000c: "GPSSpeedRef",
+	0x000d: "GPSSpeed",
+	0x000e: "GPSTrackRef",
+	0x000f: "GPSTrack",
+	0x0010: "GPSImgDirectionRef",
+	0x0011: "GPSImgDirection",
+	0x0012: "GPSMapDatum",
+	0x0017: "GPSDestBearingRef",
+	0x0018: "GPSDestBearing",
+	0x001b: "GPSProcessingMethod",
+	0x001d: "GPSDateStamp",
+	0x001e: "GPSDifferential",
+	0x001f: "GPSHPositioningError",
+}
+
+// orientationNames are the values of the Orientation tag as printed by
+// ExifTool.
+var orientationNames = map[float64]string{
+	1: "Horizontal (normal)",
+	2: "Mirror horizontal",
+	3: "Rotate 180",
+	4: "Mirror vertical",
+	5: "Mirror horizontal and rotate 270 CW",
+	6: "Rotate 90 CW",
+	7: "Mirror horizontal and rotate 90 CW",
+	8: "Rotate 270 CW",
+}
+
+// errNoExifData is returned by the native parser for files without EXIF
+// data or of an unsupported format.
+var errNoExifData = errors.New("no EXIF data")
+
+// nativeExifBackend is the native EXIF stage bound to an extractor.
+type nativeExifBackend struct {
+	me *MetaExtractor
+}
+
+func (b nativeExifBackend) Name() string {
+	return "exif-native"
+}
+
+func (b nativeExifBackend) Extract(ctx context.Context, filePath string, metadata *Metadata) error {
+	if b.me == nil {
+		return fmt.Errorf("%s: %w", b.Name(), errBackendNotConfigured)
+	}
+
+	if metadata.Exif == nil {
+		metadata.Exif = ExifMetadata{}
+	}
+	if b.me.skipExif {
+		return nil
+	}
+
+	exifData, err := b.me.extractNativeExif(filePath)
+	if errors.Is(err, errNoExifData) {
+		return nil
+	}
+	if err == nil {
+		mergeExif(metadata.Exif, exifData)
+		metadata.addExifBackend(b.Name())
+	}
+
+	return err
+}
+
+// nativeExifStage extracts the EXIF metadata of the file with the native
+// parser.
+func (me *MetaExtractor) nativeExifStage(ctx context.Context, toolPath string, metadata *Metadata, trace *stageTrace) error {
+	if me.skipExif || toolPath == "" {
+		return nil
+	}
+
+	before := snapshotKeys(metadata)
+
+	start := time.Now()
+	exifData, err := me.extractNativeExif(toolPath)
+	trace.done("exif-native", start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if errors.Is(err, errNoExifData) {
+		return nil
+	}
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error parsing EXIF data: %w", err))
+	}
+
+	mergeExif(metadata.Exif, exifData)
+	metadata.addExifBackend(SourceNativeExif)
+	tagAddedKeys(metadata, before, SourceNativeExif)
+
+	return nil
+}
+
+// mergeExif adds the tags of src missing from dst, so that the native
+// parser only fills the gaps when it runs after ExifTool.
+func mergeExif(dst, src ExifMetadata) {
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+}
+
+// addExifBackend records the name of a backend that extracted Exif in
+// Metadata.ExifBackend.
+func (m *Metadata) addExifBackend(name string) {
+	if m.ExifBackend == "" {
+		m.ExifBackend = name
+	} else if !strings.Contains(","+m.ExifBackend+",", ","+name+",") {
+		m.ExifBackend += "," + name
+	}
+}
+
+// extractNativeExif extracts the EXIF metadata of the file with the native
+// parser, keyed as configured by Options.ExifKeys.
+func (me *MetaExtractor) extractNativeExif(filePath string) (ExifMetadata, error) {
+	exif, err := readNativeExif(filePath, !me.skipExifBinary)
+	if err != nil {
+		return nil, err
+	}
+
+	if me.exifKeys == ExifKeysDefault {
+		return ungroupExif(exif), nil
+	}
+
+	return exif, nil
+}
+
+// ungroupExif removes the group prefixes of the keys. Composite tags, which
+// are derived from the others, replace the tags of the same name, as in the
+// output of ExifTool without -G0 and -a.
+func ungroupExif(exif ExifMetadata) ExifMetadata {
+	result := make(ExifMetadata, len(exif))
+	for key, v := range exif {
+		group, name, _ := strings.Cut(key, ":")
+		if _, exists := result[name]; !exists || group == "Composite" {
+			result[name] = v
+		}
+	}
+
+	return result
+}
+
+// readNativeExif parses the EXIF data of a JPEG, TIFF or PNG image, with
+// keys prefixed by their family 0 group ("EXIF" or "Composite"). Binary
+// values are encoded as "base64:..." if withBinary is true, and replaced with a
+// placeholder otherwise. It returns errNoExifData for files without EXIF
+// data or of another format.
+func readNativeExif(filePath string, withBinary bool) (ExifMetadata, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, len(pngMagic))
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	var (
+		r    io.ReaderAt
+		size int64
+	)
+
+	switch {
+	case bytes.HasPrefix(head, jpegMagic):
+		data, err := jpegExif(io.NewSectionReader(f, 0, info.Size()))
+		if err != nil {
+			return nil, err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	case bytes.HasPrefix(head, pngMagic):
+		data, err := pngExif(io.NewSectionReader(f, 0, info.Size()))
+		if err != nil {
+			return nil, err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	case bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")):
+		r, size = f, info.Size()
+	default:
+		return nil, errNoExifData
+	}
+
+	return parseTIFF(r, size, withBinary)
+}
+
+// jpegExif returns the TIFF structure of the Exif APP1 segment of a JPEG
+// image.
+func jpegExif(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	if _, err := br.Discard(2); err != nil {
+		return nil, errNoExifData
+	}
+
+	for {
+		marker, err := nextJPEGMarker(br)
+		if err != nil {
+			return nil, errNoExifData
+		}
+
+		// The metadata segments precede the first scan.
+		switch {
+		case marker == jpegSOS || marker == jpegEOI:
+			return nil, errNoExifData
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// Markers without a segment.
+			continue
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(br, length[:]); err != nil {
+			return nil, errNoExifData
+		}
+		size := int(binary.BigEndian.Uint16(length[:])) - 2
+		if size < 0 {
+			return nil, errNoExifData
+		}
+
+		if marker != 0xe1 {
+			if _, err := br.Discard(size); err != nil {
+				return nil, errNoExifData
+			}
+			continue
+		}
+
+		segment := make([]byte, size)
+		if _, err := io.ReadFull(br, segment); err != nil {
+			return nil, errNoExifData
+		}
+		if data, ok := bytes.CutPrefix(segment, []byte("Exif\x00\x00")); ok {
+			return data, nil
+		}
+	}
+}
+
+// pngExif returns the content of the eXIf chunk of a PNG image.
+func pngExif(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(int64(len(pngMagic)), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, errNoExifData
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		switch string(header[4:]) {
+		case "eXIf":
+			if length > maxEXIfChunkSize {
+				return nil, errNoExifData
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, errNoExifData
+			}
+			return data, nil
+		case "IEND":
+			return nil, errNoExifData
+		}
+
+		// Skip the data and the CRC.
+		if _, err := r.Seek(int64(length)+4, io.SeekCurrent); err != nil {
+			return nil, errNoExifData
+		}
+	}
+}
+
+// tiffEntry is an entry of an IFD.
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// tiffReader reads the IFDs of a TIFF structure.
+type tiffReader struct {
+	r       io.ReaderAt
+	size    int64
+	order   binary.ByteOrder
+	visited map[uint32]bool
+}
+
+// parseTIFF parses IFD0 and its EXIF and GPS IFDs.
+func parseTIFF(r io.ReaderAt, size int64, withBinary bool) (ExifMetadata, error) {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, errNoExifData
+	}
+
+	tr := &tiffReader{r: r, size: size, visited: make(map[uint32]bool)}
+	switch string(header[:2]) {
+	case "II":
+		tr.order = binary.LittleEndian
+	case "MM":
+		tr.order = binary.BigEndian
+	default:
+		return nil, errNoExifData
+	}
+	if tr.order.Uint16(header[2:]) != 42 {
+		return nil, errNoExifData
+	}
+
+	exif := ExifMetadata{}
+
+	entries, err := tr.readIFD(tr.order.Uint32(header[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	var pointers []tiffEntry
+	for _, e := range entries {
+		switch e.tag {
+		case tiffExifIFD, tiffGPSIFD:
+			pointers = append(pointers, e)
+		default:
+			tr.addTag(exif, exifTagNames, e, withBinary)
+		}
+	}
+
+	for _, p := range pointers {
+		offset, ok := tr.uint(p)
+		if !ok {
+			continue
+		}
+
+		entries, err := tr.readIFD(uint32(offset))
+		if err != nil {
+			// A corrupt sub-IFD does not discard the tags read so far.
+			continue
+		}
+
+		names := exifTagNames
+		if p.tag == tiffGPSIFD {
+			names = gpsTagNames
+		}
+		for _, e := range entries {
+			tr.addTag(exif, names, e, withBinary)
+		}
+	}
+
+	if len(exif) == 0 {
+		return nil, errNoExifData
+	}
+
+	addCompositeGPS(exif)
+
+	return exif, nil
+}
+
+// readIFD reads the entries of the IFD at offset.
+func (tr *tiffReader) readIFD(offset uint32) ([]tiffEntry, error) {
+	if tr.visited[offset] {
+		return nil, fmt.Errorf("IFD loop at offset %d", offset)
+	}
+	tr.visited[offset] = true
+
+	var count [2]byte
+	if _, err := tr.r.ReadAt(count[:], int64(offset)); err != nil {
+		return nil, fmt.Errorf("invalid IFD offset %d", offset)
+	}
+
+	n := int(tr.order.Uint16(count[:]))
+	if n > maxIFDEntries {
+		return nil, fmt.Errorf("IFD at offset %d has too many entries (%d)", offset, n)
+	}
+
+	raw := make([]byte, n*12)
+	if _, err := tr.r.ReadAt(raw, int64(offset)+2); err != nil {
+		return nil, fmt.Errorf("truncated IFD at offset %d", offset)
+	}
+
+	entries := make([]tiffEntry, 0, n)
+	for i := 0; i < n; i++ {
+		b := raw[i*12 : (i+1)*12]
+		e := tiffEntry{
+			tag:   tr.order.Uint16(b),
+			typ:   tr.order.Uint16(b[2:]),
+			count: tr.order.Uint32(b[4:]),
+		}
+
+		if e.typ == 0 || int(e.typ) >= len(tiffTypeSizes) {
+			continue
+		}
+
+		size := int64(tiffTypeSizes[e.typ]) * int64(e.count)
+		if size > maxTIFFValueSize {
+			continue
+		}
+
+		if size <= 4 {
+			e.data = b[8 : 8+size]
+		} else {
+			valueOffset := int64(tr.order.Uint32(b[8:]))
+			if valueOffset+size > tr.size {
+				continue
+			}
+			e.data = make([]byte, size)
+			if _, err := tr.r.ReadAt(e.data, valueOffset); err != nil {
+				continue
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// uint returns the value of an entry holding a single unsigned integer.
+func (tr *tiffReader) uint(e tiffEntry) (uint64, bool) {
+	if e.count != 1 {
+		return 0, false
+	}
+
+	switch e.typ {
+	case tiffByte:
+		return uint64(e.data[0]), true
+	case tiffShort:
+		return uint64(tr.order.Uint16(e.data)), true
+	case tiffLong, tiffIFD:
+		return uint64(tr.order.Uint32(e.data)), true
+	}
+
+	return 0, false
+}
+
+// numbers returns the values of a numeric entry. Rationals with a zero
+// denominator are reported as ExifTool does, as "inf" or "undef".
+func (tr *tiffReader) numbers(e tiffEntry) []interface{} {
+	size := tiffTypeSizes[e.typ]
+	values := make([]interface{}, 0, e.count)
+
+	for i := 0; i+size <= len(e.data); i += size {
+		b := e.data[i:]
+
+		switch e.typ {
+		case tiffByte, tiffUndefined:
+			values = append(values, float64(b[0]))
+		case tiffSByte:
+			values = append(values, float64(int8(b[0])))
+		case tiffShort:
+			values = append(values, float64(tr.order.Uint16(b)))
+		case tiffSShort:
+			values = append(values, float64(int16(tr.order.Uint16(b))))
+		case tiffLong, tiffIFD:
+			values = append(values, float64(tr.order.Uint32(b)))
+		case tiffSLong:
+			values = append(values, float64(int32(tr.order.Uint32(b))))
+		case tiffFloat:
+			values = append(values, float64(math.Float32frombits(tr.order.Uint32(b))))
+		case tiffDouble:
+			values = append(values, math.Float64frombits(tr.order.Uint64(b)))
+		case tiffRational, tiffSRational:
+			var num, den float64
+			if e.typ == tiffRational {
+				num, den = float64(tr.order.Uint32(b)), float64(tr.order.Uint32(b[4:]))
+			} else {
+				num, den = float64(int32(tr.order.Uint32(b))), float64(int32(tr.order.Uint32(b[4:])))
+			}
+
+			switch {
+			case den != 0:
+				values = append(values, num/den)
+			case num != 0:
+				values = append(values, "inf")
+			default:
+				values = append(values, "undef")
+			}
+		}
+	}
+
+	return values
+}
+
+// addTag adds the value of an entry to exif under the EXIF group, if the
+// tag is named in names.
+func (tr *tiffReader) addTag(exif ExifMetadata, names map[uint16]string, e tiffEntry, withBinary bool) {
+	name, ok := names[e.tag]
+	if !ok {
+		return
+	}
+
+	if v := tr.tagValue(name, e, withBinary); v != nil {
+		exif["EXIF:"+name] = v
+	}
+}
+
+// tagValue converts the value of an entry as ExifTool prints it. Single
+// numbers are reported as float64, like the numbers of the JSON output of
+// ExifTool, and lists of numbers are separated by spaces.
+func (tr *tiffReader) tagValue(name string, e tiffEntry, withBinary bool) interface{} {
+	switch name {
+	case "ExifVersion", "FlashpixVersion":
+		return strings.TrimRight(string(e.data), "\x00")
+	case "UserComment", "GPSProcessingMethod":
+		// The comment is preceded by an 8-byte character code.
+		if len(e.data) < 8 {
+			return nil
+		}
+		return strings.TrimSpace(strings.TrimRight(string(e.data[8:]), "\x00"))
+	}
+
+	if e.typ == tiffASCII {
+		s, _, _ := strings.Cut(string(e.data), "\x00")
+		return strings.TrimSpace(s)
+	}
+
+	if e.typ == tiffUndefined && len(e.data) > 4 {
+		if !withBinary {
+			return fmt.Sprintf("(Binary data %d bytes, use -b option to extract)", len(e.data))
+		}
+		return "base64:" + base64.StdEncoding.EncodeToString(e.data)
+	}
+
+	values := tr.numbers(e)
+	if len(values) == 0 {
+		return nil
+	}
+
+	if v, ok := gpsTagValue(name, values); ok {
+		return v
+	}
+
+	if name == "Orientation" {
+		if f, ok := values[0].(float64); ok {
+			if s, ok := orientationNames[f]; ok {
+				return s
+			}
+		}
+	}
+
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = stringValue(v)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// gpsTagValue converts the values of a GPS tag as ExifTool prints them
+// (e.g., `47 deg 30' 12.30"` or "123.4 m"). It reports false for other
+// tags.
+func gpsTagValue(name string, values []interface{}) (interface{}, bool) {
+	f := make([]float64, len(values))
+	for i, v := range values {
+		n, ok := v.(float64)
+		if !ok {
+			return nil, false
+		}
+		f[i] = n
+	}
+
+	switch name {
+	case "GPSVersionID":
+		parts := make([]string, len(f))
+		for i, n := range f {
+			parts[i] = strconv.Itoa(int(n))
+		}
+		return strings.Join(parts, "."), true
+	case "GPSLatitude", "GPSLongitude", "GPSDestLatitude", "GPSDestLongitude":
+		if len(f) != 3 {
+			return nil, false
+		}
+		return formatDMS(f[0] + f[1]/60 + f[2]/3600), true
+	case "GPSAltitude":
+		return strconv.FormatFloat(f[0], 'f', -1, 64) + " m", true
+	case "GPSAltitudeRef":
+		if f[0] == 1 {
+			return "Below Sea Level", true
+		}
+		return "Above Sea Level", true
+	case "GPSTimeStamp":
+		if len(f) != 3 {
+			return nil, false
+		}
+		seconds := strconv.FormatFloat(f[2], 'f', -1, 64)
+		if f[2] < 10 {
+			seconds = "0" + seconds
+		}
+		return fmt.Sprintf("%02d:%02d:%s", int(f[0]), int(f[1]), seconds), true
+	}
+
+	return nil, false
+}
+
+// formatDMS formats decimal degrees in degrees, minutes and seconds, as
+// ExifTool prints coordinates (e.g., `47 deg 30' 12.30"`).
+func formatDMS(degrees float64) string {
+	degrees = math.Abs(degrees)
+	d := math.Floor(degrees)
+	m := math.Floor((degrees - d) * 60)
+	s := (degrees - d - m/60) * 3600
+
+	// Rounding the seconds may carry over to the minutes and degrees.
+	if math.Round(s*100) >= 6000 {
+		s, m = 0, m+1
+	}
+	if m >= 60 {
+		m, d = m-60, d+1
+	}
+
+	return fmt.Sprintf(`%d deg %d' %.2f"`, int(d), int(m), s)
+}
+
+// gpsRefNames are the hemisphere references as printed by ExifTool.
+var gpsRefNames = map[string]string{
+	"N": "North",
+	"S": "South",
+	"E": "East",
+	"W": "West",
+}
+
+// addCompositeGPS prints the hemisphere references of exif in full and adds
+// the Composite tags ExifTool derives from the GPS tags: GPSLatitude and
+// GPSLongitude with their reference, GPSPosition and GPSDateTime.
+func addCompositeGPS(exif ExifMetadata) {
+	refs := make(map[string]string)
+	for _, tag := range []string{"GPSLatitudeRef", "GPSLongitudeRef"} {
+		if ref, ok := exif["EXIF:"+tag].(string); ok {
+			refs[tag] = strings.ToUpper(ref)
+			if name, ok := gpsRefNames[refs[tag]]; ok {
+				exif["EXIF:"+tag] = name
+			}
+		}
+	}
+
+	lat, latOK := exif["EXIF:GPSLatitude"].(string)
+	lon, lonOK := exif["EXIF:GPSLongitude"].(string)
+	if latOK && refs["GPSLatitudeRef"] != "" {
+		lat += " " + refs["GPSLatitudeRef"]
+		exif["Composite:GPSLatitude"] = lat
+	}
+	if lonOK && refs["GPSLongitudeRef"] != "" {
+		lon += " " + refs["GPSLongitudeRef"]
+		exif["Composite:GPSLongitude"] = lon
+	}
+	if latOK && lonOK && refs["GPSLatitudeRef"] != "" && refs["GPSLongitudeRef"] != "" {
+		exif["Composite:GPSPosition"] = lat + ", " + lon
+	}
+
+	date, dateOK := exif["EXIF:GPSDateStamp"].(string)
+	clock, clockOK := exif["EXIF:GPSTimeStamp"].(string)
+	if dateOK && clockOK {
+		exif["Composite:GPSDateTime"] = date + " " + clock + "Z"
+	}
+}
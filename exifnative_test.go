@@ -0,0 +1,298 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTag is an IFD entry of a test TIFF structure.
+type testTag struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func asciiTag(tag uint16, s string) testTag {
+	return testTag{tag, tiffASCII, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func shortTag(tag uint16, v uint16) testTag {
+	return testTag{tag, tiffShort, 1, binary.LittleEndian.AppendUint16(nil, v)}
+}
+
+func rationalTag(tag uint16, values ...uint32) testTag {
+	var data []byte
+	for _, v := range values {
+		data = binary.LittleEndian.AppendUint32(data, v)
+	}
+	return testTag{tag, tiffRational, uint32(len(values) / 2), data}
+}
+
+// buildTIFF returns a little-endian TIFF structure with IFD0 pointing to the
+// EXIF and GPS IFDs.
+func buildTIFF(ifd0, exifIFD, gpsIFD []testTag) []byte {
+	le := binary.LittleEndian
+
+	ifds := [][]testTag{slices.Clone(ifd0), exifIFD, gpsIFD}
+	ifds[0] = append(ifds[0], testTag{tiffExifIFD, tiffLong, 1, nil}, testTag{tiffGPSIFD, tiffLong, 1, nil})
+
+	offsets := make([]int, len(ifds))
+	offset := 8
+	for i, tags := range ifds {
+		offsets[i] = offset
+		offset += 2 + 12*len(tags) + 4
+		for _, t := range tags {
+			if len(t.data) > 4 {
+				offset += len(t.data)
+			}
+		}
+	}
+	ifds[0][len(ifds[0])-2].data = le.AppendUint32(nil, uint32(offsets[1]))
+	ifds[0][len(ifds[0])-1].data = le.AppendUint32(nil, uint32(offsets[2]))
+
+	buf := le.AppendUint32([]byte("II*\x00"), 8)
+	for i, tags := range ifds {
+		dataOffset := offsets[i] + 2 + 12*len(tags) + 4
+		var data []byte
+
+		buf = le.AppendUint16(buf, uint16(len(tags)))
+		for _, t := range tags {
+			buf = le.AppendUint16(buf, t.tag)
+			buf = le.AppendUint16(buf, t.typ)
+			buf = le.AppendUint32(buf, t.count)
+			if len(t.data) <= 4 {
+				buf = append(buf, t.data...)
+				buf = append(buf, make([]byte, 4-len(t.data))...)
+			} else {
+				buf = le.AppendUint32(buf, uint32(dataOffset+len(data)))
+				data = append(data, t.data...)
+			}
+		}
+		buf = le.AppendUint32(buf, 0)
+		buf = append(buf, data...)
+	}
+
+	return buf
+}
+
+// testTIFF returns a TIFF structure with camera, date and GPS tags.
+func testTIFF() []byte {
+	return buildTIFF(
+		[]testTag{asciiTag(0x010f, "Canon"), asciiTag(0x0110, "EOS R5 "), shortTag(0x0112, 6)},
+		[]testTag{
+			asciiTag(0x9003, "2023:01:02 15:04:05"),
+			rationalTag(0x829a, 1, 125),
+			{0x9000, tiffUndefined, 4, []byte("0232")},
+			{0x927c, tiffUndefined, 8, []byte("makernote")},
+			{0xa40b, tiffUndefined, 8, []byte("settings")},
+			{0x9286, tiffUndefined, 14, []byte("ASCII\x00\x00\x00hello\x00")},
+		},
+		[]testTag{
+			asciiTag(0x0001, "N"),
+			rationalTag(0x0002, 47, 1, 30, 1, 1230, 100),
+			asciiTag(0x0003, "W"),
+			rationalTag(0x0004, 8, 1, 15, 1, 0, 1),
+			{0x0005, tiffByte, 1, []byte{1}},
+			rationalTag(0x0006, 1234, 10),
+			rationalTag(0x0007, 13, 1, 4, 1, 5, 1),
+			asciiTag(0x001d, "2023:01:02"),
+		},
+	)
+}
+
+// jpegWithExif returns a JPEG image with the TIFF structure in an Exif APP1
+// segment.
+func jpegWithExif(t *testing.T, tiff []byte) []byte {
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	data := []byte{0xff, 0xd8, 0xff, 0xe1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+
+	return append(data, encodeImage(t, "jpeg")[2:]...)
+}
+
+// pngWithExif returns a PNG image with the TIFF structure in an eXIf chunk.
+func pngWithExif(t *testing.T, tiff []byte) []byte {
+	img := encodeImage(t, "png")
+
+	// The signature and the IHDR chunk come first.
+	data := slices.Clone(img[:33])
+	data = binary.BigEndian.AppendUint32(data, uint32(len(tiff)))
+	chunk := append([]byte("eXIf"), tiff...)
+	data = append(data, chunk...)
+	data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(chunk))
+
+	return append(data, img[33:]...)
+}
+
+func TestReadNativeExif(t *testing.T) {
+	dir := t.TempDir()
+	tiff := testTIFF()
+
+	expected := ExifMetadata{
+		"EXIF:Make":                     "Canon",
+		"EXIF:Model":                    "EOS R5",
+		"EXIF:Orientation":              "Rotate 90 CW",
+		"EXIF:DateTimeOriginal":         "2023:01:02 15:04:05",
+		"EXIF:ExposureTime":             0.008,
+		"EXIF:ExifVersion":              "0232",
+		"EXIF:UserComment":              "hello",
+		"EXIF:GPSLatitudeRef":           "North",
+		"EXIF:GPSLatitude":              `47 deg 30' 12.30"`,
+		"EXIF:GPSLongitudeRef":          "West",
+		"EXIF:GPSLongitude":             `8 deg 15' 0.00"`,
+		"EXIF:GPSAltitudeRef":           "Below Sea Level",
+		"EXIF:GPSAltitude":              "123.4 m",
+		"EXIF:GPSTimeStamp":             "13:04:05",
+		"EXIF:GPSDateStamp":             "2023:01:02",
+		"Composite:GPSLatitude":         `47 deg 30' 12.30" N`,
+		"Composite:GPSLongitude":        `8 deg 15' 0.00" W`,
+		"Composite:GPSPosition":         `47 deg 30' 12.30" N, 8 deg 15' 0.00" W`,
+		"Composite:GPSDateTime":         "2023:01:02 13:04:05Z",
+		"EXIF:DeviceSettingDescription": "base64:c2V0dGluZ3M=",
+	}
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"tiff", tiff},
+		{"jpeg", jpegWithExif(t, tiff)},
+		{"png", pngWithExif(t, tiff)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			require.NoError(t, os.WriteFile(path, tc.data, 0o644))
+
+			exif, err := readNativeExif(path, true)
+			require.NoError(t, err)
+			assert.Equal(t, expected, exif)
+		})
+	}
+
+	t.Run("Without EXIF", func(t *testing.T) {
+		for name, data := range map[string][]byte{
+			"jpeg": encodeImage(t, "jpeg"),
+			"png":  encodeImage(t, "png"),
+			"text": []byte("not an image"),
+		} {
+			path := filepath.Join(dir, name+"-plain")
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+
+			_, err := readNativeExif(path, true)
+			assert.ErrorIs(t, err, errNoExifData, name)
+		}
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		// An EXIF IFD pointer to IFD0, and truncated structures.
+		loop := buildTIFF([]testTag{asciiTag(0x010f, "Canon")}, nil, nil)
+		binary.LittleEndian.PutUint32(loop[8+2+12+8:], 8)
+
+		for i, data := range [][]byte{loop, tiff[:20], tiff[:len(tiff)/2], []byte("II*\x00\xff\xff\xff\xff")} {
+			path := filepath.Join(dir, "corrupt")
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+
+			assert.NotPanics(t, func() {
+				exif, err := readNativeExif(path, true)
+				if err == nil {
+					assert.Equal(t, "Canon", exif["EXIF:Make"], i)
+				}
+			})
+		}
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		path := filepath.Join(dir, "binary.tif")
+		require.NoError(t, os.WriteFile(path, tiff, 0o644))
+
+		exif, err := readNativeExif(path, true)
+		require.NoError(t, err)
+		assert.Equal(t, "base64:c2V0dGluZ3M=", exif["EXIF:DeviceSettingDescription"])
+
+		exif, err = readNativeExif(path, false)
+		require.NoError(t, err)
+		assert.Equal(t, "(Binary data 8 bytes, use -b option to extract)", exif["EXIF:DeviceSettingDescription"])
+	})
+}
+
+func TestNativeExifBackend(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	require.NoError(t, os.WriteFile(path, jpegWithExif(t, testTIFF()), 0o644))
+
+	t.Run("Extract", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			PureGo:     true,
+			Provenance: true,
+			Backends:   []Extractor{TridBackend, NativeExifBackend},
+		})
+
+		metadata, err := me.Extract(path)
+		require.NoError(t, err)
+		assert.Equal(t, "Canon", metadata.Exif["Make"])
+		assert.Equal(t, `47 deg 30' 12.30" N`, metadata.Exif["GPSLatitude"])
+		assert.Equal(t, "exif-native", metadata.ExifBackend)
+		assert.Equal(t, SourceNativeExif, metadata.Provenance["Exif.Make"])
+		assert.Equal(t, SourceNativeExif, metadata.Provenance["ExifTimes"])
+		assert.Contains(t, metadata.ExifTimes, "DateTimeOriginal")
+
+		require.NotNil(t, metadata.Normalized)
+		require.NotNil(t, metadata.Normalized.GPS)
+		assert.InDelta(t, 47.5034, metadata.Normalized.GPS.Latitude, 1e-4)
+		assert.InDelta(t, -8.25, metadata.Normalized.GPS.Longitude, 1e-4)
+		require.NotNil(t, metadata.Normalized.GPS.Altitude)
+		assert.Equal(t, -123.4, *metadata.Normalized.GPS.Altitude)
+	})
+
+	t.Run("Grouped", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			PureGo:   true,
+			ExifKeys: ExifKeysGrouped,
+			Backends: []Extractor{NativeExifBackend},
+		})
+
+		metadata, err := me.Extract(path)
+		require.NoError(t, err)
+		assert.Equal(t, "Canon", metadata.Exif["EXIF:Make"])
+		assert.Equal(t, `47 deg 30' 12.30"`, metadata.Exif["EXIF:GPSLatitude"])
+		assert.Equal(t, `47 deg 30' 12.30" N`, metadata.Exif["Composite:GPSLatitude"])
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			PureGo:      true,
+			DisableExif: true,
+			Backends:    []Extractor{TridBackend, NativeExifBackend},
+		})
+
+		metadata, err := me.Extract(path)
+		require.NoError(t, err)
+		assert.Empty(t, metadata.Exif)
+		assert.Empty(t, metadata.ExifBackend)
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{NativeExifBackend}})
+
+		metadata, err := me.ExtractStreamInput(bytes.NewReader(jpegWithExif(t, testTIFF())))
+		require.NoError(t, err)
+		assert.Equal(t, "Canon", metadata.Exif["Make"])
+	})
+
+	t.Run("Unbound", func(t *testing.T) {
+		err := NativeExifBackend.Extract(context.Background(), path, &Metadata{})
+		assert.ErrorIs(t, err, errBackendNotConfigured)
+	})
+}
@@ -0,0 +1,546 @@
+//go:build !purego
+
+package metaextractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pureGoBuild reports whether the package was built with the purego build
+// tag, which removes all external-tool stages.
+const pureGoBuild = false
+
+// exifToolOption is a group of arguments ExifTool is started with, which
+// apply to every file it processes (e.g., {"-api", "FastScan=2"}).
+type exifToolOption = []string
+
+// newExifToolOpts returns the options ExifTool is started with. If grouped
+// is true, tags are prefixed with their family 0 group and duplicate tags are
+// kept (as with ExifTool's -G0 and -a options). If binary is true, binary
+// values such as embedded previews are extracted (as with -b). Files larger
+// than 2 GB are always read (LargeFileSupport), as older ExifTool versions
+// refuse them by default. The options translated from args (see
+// Options.ExifToolArgs) are added last.
+func newExifToolOpts(grouped, binary bool, args []string) ([]exifToolOption, error) {
+	opts := []exifToolOption{{"-ee"}, {"-api", "LargeFileSupport=1"}}
+
+	if binary {
+		opts = append(opts, exifToolOption{"-b"})
+	}
+
+	if grouped {
+		opts = append(opts, exifToolOption{"-G0"}, exifToolOption{"-api", "Duplicates=1"})
+	}
+
+	// File names are sent to ExifTool in UTF-8, and paths exceeding MAX_PATH
+	// carry the long-path prefix (see longPath), which ExifTool only
+	// supports on Windows with these options.
+	if runtime.GOOS == "windows" {
+		opts = append(opts, exifToolOption{"-charset", "filename=utf8"}, exifToolOption{"-api", "WindowsLongPath=1"})
+	}
+
+	argOpts, err := exifToolArgOpts(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(opts, argOpts...), nil
+}
+
+// exifToolFlags are the ExifTool flags without a value supported by
+// Options.ExifToolArgs, with the API options they are passed as.
+var exifToolFlags = map[string]string{
+	"-fast":   "FastScan=1",
+	"-fast1":  "FastScan=1",
+	"-fast2":  "FastScan=2",
+	"-fast3":  "FastScan=3",
+	"-fast4":  "FastScan=4",
+	"-fast5":  "FastScan=5",
+	"-m":      "IgnoreMinorErrors=1",
+	"-u":      "Unknown=1",
+	"-U":      "Unknown=2",
+	"-struct": "Struct=1",
+	"-L":      "Charset=Latin",
+	"-latin":  "Charset=Latin",
+}
+
+// exifToolArgOpts translates ExifTool command-line arguments into options.
+// Only the flags that do not change the format of the output are
+// supported, along with the API options equivalent to further flags.
+func exifToolArgOpts(args []string) ([]exifToolOption, error) {
+	var opts []exifToolOption
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if apiOption, ok := exifToolFlags[arg]; ok {
+			opts = append(opts, exifToolOption{"-api", apiOption})
+			continue
+		}
+
+		var name string
+		switch arg {
+		case "-n":
+			opts = append(opts, exifToolOption{"-n"})
+			continue
+		case "-charset", "-api":
+			name = arg
+		case "-d", "-dateFormat":
+			name = "-dateFormat"
+		case "-c", "-coordFormat":
+			name = "-coordFormat"
+		default:
+			return nil, fmt.Errorf("unsupported ExifTool argument %q", arg)
+		}
+
+		if i+1 == len(args) {
+			return nil, fmt.Errorf("missing value of ExifTool argument %q", arg)
+		}
+		i++
+		opts = append(opts, exifToolOption{name, args[i]})
+	}
+
+	return opts, nil
+}
+
+// passwordOption returns the option passing a document password to ExifTool.
+func passwordOption(password string) exifToolOption {
+	return exifToolOption{"-api", "Password=" + password}
+}
+
+// exifToolReady is printed by ExifTool in stay-open mode once it has
+// processed the arguments of an extraction. It is also echoed to the
+// standard error (with -echo4), which marks the end of the messages of the
+// extraction there.
+var exifToolReady = []byte("{ready}")
+
+// errExifToolArgument is returned for arguments containing a line break,
+// which would be read as several arguments from the argument stream of
+// ExifTool (e.g., a file name smuggling in an "-o" option).
+var errExifToolArgument = errors.New("ExifTool argument contains a line break")
+
+// exifToolCloseTimeout bounds the wait for an ExifTool process to exit once
+// it is asked to, after which it is killed.
+const exifToolCloseTimeout = time.Second
+
+// exifToolProcess is an ExifTool process running in stay-open mode, which
+// reads the arguments of each extraction from its standard input. It
+// serves one extraction at a time.
+type exifToolProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	out    *os.File
+	r      *bufio.Reader
+	errOut *os.File
+	errR   *bufio.Reader
+
+	// busy is the time the process has spent starting and extracting
+	// metadata. ExifTool is single-threaded and idles between extractions,
+	// so this bounds the CPU time it has used.
+	busy time.Duration
+
+	// idleSince is when the process last finished starting or extracting.
+	idleSince time.Time
+
+	// used reports whether the process has served an extraction.
+	used bool
+
+	// failed reports whether the process was killed or hit an I/O or
+	// protocol error, after which it cannot be reused.
+	failed bool
+}
+
+// startExifTool starts ExifTool in stay-open mode with the given options.
+// Its standard output and standard error are read separately, so that
+// messages do not corrupt the JSON output.
+func startExifTool(cmd string, opts []exifToolOption) (*exifToolProcess, error) {
+	args := []string{"-stay_open", "True", "-@", "-"}
+	if len(opts) > 0 {
+		args = append(args, "-common_args")
+		for _, opt := range opts {
+			args = append(args, opt...)
+		}
+	}
+
+	c := exec.Command(cmd, args...)
+
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	out, w, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+
+	errOut, errW, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		out.Close()
+		w.Close()
+		return nil, err
+	}
+	c.Stdout, c.Stderr = w, errW
+
+	start := time.Now()
+	err = c.Start()
+	w.Close()
+	errW.Close()
+	if err != nil {
+		stdin.Close()
+		out.Close()
+		errOut.Close()
+		return nil, err
+	}
+
+	// The startup of ExifTool is charged to the first extraction, as it
+	// completes while the first file is sent.
+	return &exifToolProcess{
+		cmd:       c,
+		stdin:     stdin,
+		out:       out,
+		r:         bufio.NewReader(out),
+		errOut:    errOut,
+		errR:      bufio.NewReader(errOut),
+		idleSince: start,
+	}, nil
+}
+
+// extract extracts the metadata of the file. If ctx is done first, the
+// process is killed and ctx.Err() is returned. The process must be closed
+// once it has failed; an error of the file alone (e.g., an unsupported
+// format) leaves it usable.
+func (p *exifToolProcess) extract(ctx context.Context, filePath string) (ExifMetadata, error) {
+	stop := context.AfterFunc(ctx, p.kill)
+	defer stop()
+
+	start := time.Now()
+	if !p.used {
+		start = p.idleSince
+	}
+	p.used = true
+
+	out, errOut, err := p.execute("-j", filePath)
+	p.idleSince = time.Now()
+	p.busy += p.idleSince.Sub(start)
+
+	if ctx.Err() != nil {
+		p.failed = true
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		if !errors.Is(err, errExifToolArgument) {
+			p.failed = true
+		}
+		return nil, fmt.Errorf("error running ExifTool: %w", err)
+	}
+
+	var fields []map[string]interface{}
+	if err := json.Unmarshal(out, &fields); err != nil {
+		msg := bytes.TrimSpace(errOut)
+		if len(msg) == 0 {
+			msg = bytes.TrimSpace(out)
+		}
+		return nil, fmt.Errorf("error extracting metadata: %s", msg)
+	}
+	if len(fields) == 0 {
+		return nil, ErrNoMetadataExtracted
+	}
+
+	return fields[0], nil
+}
+
+// execute sends the arguments of an extraction to ExifTool and returns its
+// output and the messages it wrote to the standard error. Arguments
+// containing a line break are rejected with errExifToolArgument before
+// anything is sent.
+func (p *exifToolProcess) execute(args ...string) ([]byte, []byte, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "\r\n") {
+			return nil, nil, fmt.Errorf("%w: %q", errExifToolArgument, arg)
+		}
+		b.WriteString(arg + "\n")
+	}
+	b.WriteString("-echo4\n" + string(exifToolReady) + "\n-execute\n")
+
+	if _, err := io.WriteString(p.stdin, b.String()); err != nil {
+		return nil, nil, err
+	}
+
+	// The standard error is read concurrently, so that ExifTool does not
+	// block on it while the output is read.
+	type result struct {
+		b   []byte
+		err error
+	}
+	errDone := make(chan result, 1)
+	go func() {
+		b, err := readExifToolReply(p.errR)
+		errDone <- result{b, err}
+	}()
+
+	out, err := readExifToolReply(p.r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errOut := <-errDone
+	if errOut.err != nil {
+		return nil, nil, errOut.err
+	}
+
+	return out, errOut.b, nil
+}
+
+// readExifToolReply reads the reply of ExifTool to an extraction up to
+// exifToolReady, which is left out.
+func readExifToolReply(r *bufio.Reader) ([]byte, error) {
+	var out []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		out = append(out, line...)
+
+		if trimmed := bytes.TrimRight(out, "\r\n"); bytes.HasSuffix(trimmed, exifToolReady) {
+			return trimmed[:len(trimmed)-len(exifToolReady)], nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// kill stops the process immediately. The outputs are closed as well, in
+// case a child of the process (e.g., of a sandbox wrapper) still holds them
+// open.
+func (p *exifToolProcess) kill() {
+	p.cmd.Process.Kill()
+	p.out.Close()
+	p.errOut.Close()
+}
+
+// close asks the process to exit and waits for it, killing it if it does
+// not exit in time.
+func (p *exifToolProcess) close() error {
+	io.WriteString(p.stdin, "-stay_open\nFalse\n-execute\n")
+	p.stdin.Close()
+	defer p.out.Close()
+	defer p.errOut.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(exifToolCloseTimeout):
+		p.kill()
+		<-done
+		return errors.New("timed out waiting for ExifTool to exit")
+	}
+}
+
+// runExifTool extracts EXIF metadata from the file using a new ExifTool
+// process started with the given options. If ctx is done first, ExifTool is
+// killed and ctx.Err() is returned.
+func runExifTool(ctx context.Context, cmd, filePath string, opts []exifToolOption) (ExifMetadata, error) {
+	if err := checkExifToolFile(filePath); err != nil {
+		return nil, err
+	}
+
+	p, err := startExifTool(cmd, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing ExifTool: %v", err)
+	}
+	defer p.close()
+
+	return p.extract(ctx, filePath)
+}
+
+// checkExifToolFile reports an error if the file does not exist or is a
+// directory, before it is sent to ExifTool.
+func checkExifToolFile(filePath string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("error extracting metadata: %w", err)
+	}
+	if info.IsDir() {
+		return errors.New("error extracting metadata: can't extract metadata from folder")
+	}
+
+	return nil
+}
+
+// exifToolPool keeps ExifTool processes running in stay-open mode between
+// extractions, so that each file is not charged the startup time of
+// ExifTool. Processes are started on demand, each serves one extraction at a
+// time, and idle processes are kept until the pool is closed. A process
+// whose extraction is canceled is killed and replaced.
+//
+// If cpuTime is set (see Limits.CPUTime), the CPU time limit of the processes
+// applies to each file: a process is replaced once it has been busy for half
+// of cpuTime, and an extraction exceeding the remaining time of a reused
+// process is retried on a new one.
+type exifToolPool struct {
+	cmd     string
+	opts    []exifToolOption
+	cpuTime time.Duration
+
+	mu     sync.Mutex
+	idle   []*exifToolProcess
+	busy   map[*exifToolProcess]struct{}
+	closed bool
+}
+
+func newExifToolPool(cmd string, opts []exifToolOption, cpuTime time.Duration) *exifToolPool {
+	if cmd == "" {
+		cmd = "exiftool"
+	}
+
+	return &exifToolPool{cmd: cmd, opts: opts, cpuTime: cpuTime, busy: make(map[*exifToolProcess]struct{})}
+}
+
+// worn reports whether the process has used up so much of its CPU time that
+// it must be replaced.
+func (p *exifToolPool) worn(et *exifToolProcess) bool {
+	return p.cpuTime > 0 && et.busy >= p.cpuTime/2
+}
+
+// get returns an idle process, or starts a new one, and marks it as busy.
+func (p *exifToolPool) get() (*exifToolProcess, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		et := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.busy[et] = struct{}{}
+		p.mu.Unlock()
+		return et, nil
+	}
+	p.mu.Unlock()
+
+	return p.start()
+}
+
+// start starts a new process and marks it as busy.
+func (p *exifToolPool) start() (*exifToolProcess, error) {
+	et, err := startExifTool(p.cmd, p.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.busy[et] = struct{}{}
+	p.mu.Unlock()
+
+	return et, nil
+}
+
+// put returns a busy process to the pool, or closes it if the pool is closed
+// or the process is worn.
+func (p *exifToolPool) put(et *exifToolProcess) {
+	p.mu.Lock()
+	delete(p.busy, et)
+	if !p.closed && !p.worn(et) {
+		p.idle = append(p.idle, et)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+
+	et.close()
+}
+
+// discard closes a busy process that cannot be reused.
+func (p *exifToolPool) discard(et *exifToolProcess) {
+	p.mu.Lock()
+	delete(p.busy, et)
+	p.mu.Unlock()
+
+	et.close()
+}
+
+// extract extracts EXIF metadata from the file using a process of the pool.
+// If ctx is done first, the process is killed and ctx.Err() is returned.
+func (p *exifToolPool) extract(ctx context.Context, filePath string) (ExifMetadata, error) {
+	// A file that does not exist is rejected before it is sent to ExifTool.
+	if err := checkExifToolFile(filePath); err != nil {
+		return nil, err
+	}
+
+	et, err := p.get()
+	if err != nil {
+		return nil, fmt.Errorf("error initializing ExifTool: %v", err)
+	}
+
+	if p.cpuTime <= 0 || !et.used {
+		return p.extractWith(ctx, et, filePath)
+	}
+
+	// The reused process is killed before it exceeds its CPU time limit, and
+	// the file gets the full limit on a new process.
+	fileCtx, cancel := context.WithTimeout(ctx, p.cpuTime-et.busy)
+	defer cancel()
+
+	exif, err := p.extractWith(fileCtx, et, filePath)
+	if ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+		return exif, err
+	}
+
+	// The file already took half of the limit, so it is not retried on
+	// another reused process.
+	if et, err = p.start(); err != nil {
+		return nil, fmt.Errorf("error initializing ExifTool: %v", err)
+	}
+
+	return p.extractWith(ctx, et, filePath)
+}
+
+// extractWith extracts EXIF metadata from the file using a busy process of
+// the pool, and returns it to the pool unless it failed.
+func (p *exifToolPool) extractWith(ctx context.Context, et *exifToolProcess, filePath string) (ExifMetadata, error) {
+	exif, err := et.extract(ctx, filePath)
+	if et.failed {
+		// The process was killed, or is in an unknown state.
+		p.discard(et)
+		return nil, err
+	}
+
+	p.put(et)
+
+	return exif, err
+}
+
+// close stops the idle processes and kills the processes still in use,
+// failing their extractions.
+func (p *exifToolPool) close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle, p.closed = nil, true
+	for et := range p.busy {
+		et.kill()
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, et := range idle {
+		errs = append(errs, et.close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,44 @@
+//go:build purego
+
+package metaextractor
+
+import (
+	"context"
+	"time"
+)
+
+// pureGoBuild reports whether the package was built with the purego build
+// tag, which removes all external-tool stages.
+const pureGoBuild = true
+
+// exifToolOption is a placeholder, as ExifTool is not available in pure-Go
+// builds.
+type exifToolOption = []string
+
+func newExifToolOpts(grouped, binary bool, args []string) ([]exifToolOption, error) {
+	return nil, nil
+}
+
+func passwordOption(password string) exifToolOption {
+	return nil
+}
+
+func runExifTool(ctx context.Context, cmd, filePath string, opts []exifToolOption) (ExifMetadata, error) {
+	return nil, ErrNoMetadataExtracted
+}
+
+type exifToolPool struct {
+	cmd string
+}
+
+func newExifToolPool(cmd string, opts []exifToolOption, cpuTime time.Duration) *exifToolPool {
+	return &exifToolPool{cmd: cmd}
+}
+
+func (p *exifToolPool) extract(ctx context.Context, filePath string) (ExifMetadata, error) {
+	return nil, ErrNoMetadataExtracted
+}
+
+func (p *exifToolPool) close() error {
+	return nil
+}
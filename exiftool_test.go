@@ -0,0 +1,304 @@
+//go:build !purego
+
+package metaextractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExifTool writes a script speaking ExifTool's stay-open protocol, which
+// reports its version and process ID for every file and logs each start. Its
+// last command line is written to "args.log" next to the log. It returns the
+// script and the log paths.
+func fakeExifTool(t *testing.T) (string, string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "exiftool")
+	log := filepath.Join(dir, "starts.log")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo $$ >> "`+log+`"
+echo "$@" > "`+filepath.Join(dir, "args.log")+`"
+while read -r line; do
+	case "$line" in
+	False) quit=1 ;;
+	-echo4) read -r echo ;;
+	-execute)
+		[ -n "$quit" ] && exit 0
+		case "$file" in
+		*.unsupported)
+			echo "Error: Unknown file type - $file" >&2
+			printf '{ready}\n' ;;
+		*) printf '[{"SourceFile":"%s","ExifToolVersion":12.7,"Pid":%s}]\n{ready}\n' "$file" $$ ;;
+		esac
+		echo "$echo" >&2 ;;
+	-*) ;;
+	*) file=$line ;;
+	esac
+done
+`), 0o755))
+
+	return script, log
+}
+
+// starts returns the number of processes the fake ExifTool started.
+func starts(t *testing.T, log string) int {
+	b, err := os.ReadFile(log)
+	require.NoError(t, err)
+	return len(strings.Fields(string(b)))
+}
+
+func TestExifToolPool(t *testing.T) {
+	script, log := fakeExifTool(t)
+	sample := filepath.Join("testdata", "sample.doc")
+
+	me := NewMetaExtractor(Options{ExifToolPath: script})
+	ctx := context.Background()
+
+	first, err := me.extractExifData(ctx, sample)
+	require.NoError(t, err)
+	assert.Equal(t, sample, first["SourceFile"])
+
+	second, err := me.extractExifData(ctx, sample)
+	require.NoError(t, err)
+	assert.Equal(t, first["Pid"], second["Pid"], "the process is reused")
+
+	_, err = me.extractExifData(ctx, filepath.Join("testdata", "missing"))
+	assert.Error(t, err)
+
+	profiled, err := me.WithProfile("photos")
+	require.NoError(t, err)
+	third, err := profiled.extractExifData(ctx, sample)
+	require.NoError(t, err)
+	assert.Equal(t, first["Pid"], third["Pid"], "profiles share the processes")
+	assert.Equal(t, 1, starts(t, log))
+
+	require.NoError(t, me.Close())
+
+	// Once closed, the pool runs a process per extraction.
+	for i := 0; i < 2; i++ {
+		exif, err := me.extractExifData(ctx, sample)
+		require.NoError(t, err)
+		assert.NotEqual(t, first["Pid"], exif["Pid"])
+	}
+	assert.Equal(t, 3, starts(t, log))
+	assert.Empty(t, me.exifTools.idle)
+}
+
+func TestExifToolArgs(t *testing.T) {
+	script, log := fakeExifTool(t)
+	sample := filepath.Join("testdata", "sample.doc")
+
+	me := NewMetaExtractor(Options{
+		ExifToolPath:   script,
+		ExifToolArgs:   []string{"-fast2", "-n", "-charset", "exif=utf8", "-m"},
+		SkipExifBinary: true,
+	})
+	defer me.Close()
+
+	_, err := me.extractExifData(context.Background(), sample)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(log), "args.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "-stay_open True -@ - -common_args -ee -api LargeFileSupport=1 -api FastScan=2 -n -charset exif=utf8 -api IgnoreMinorErrors=1", strings.TrimSpace(string(b)))
+
+	defaults := NewMetaExtractor(Options{ExifToolPath: script})
+	defer defaults.Close()
+	_, err = defaults.extractExifData(context.Background(), sample)
+	require.NoError(t, err)
+
+	b, err = os.ReadFile(filepath.Join(filepath.Dir(log), "args.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "-stay_open True -@ - -common_args -ee -api LargeFileSupport=1 -b", strings.TrimSpace(string(b)))
+
+	for _, args := range [][]string{{"-fast2", "-X"}, {"-charset"}} {
+		err := NewMetaExtractor(Options{ExifToolPath: script, ExifToolArgs: args}).Check()
+		assert.ErrorContains(t, err, "ExifTool argument", args)
+	}
+}
+
+func TestExifToolHostileFileName(t *testing.T) {
+	script, log := fakeExifTool(t)
+
+	hostile := filepath.Join(t.TempDir(), "photo.jpg\n-o\nevil.jpg")
+	require.NoError(t, os.WriteFile(hostile, []byte("data"), 0o644))
+
+	me := NewMetaExtractor(Options{ExifToolPath: script})
+	defer me.Close()
+
+	_, err := me.extractExifData(context.Background(), hostile)
+	assert.ErrorIs(t, err, errExifToolArgument)
+
+	// The process was not sent anything and is reused.
+	_, err = me.extractExifData(context.Background(), filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, starts(t, log))
+}
+
+func TestExifToolFileError(t *testing.T) {
+	script, log := fakeExifTool(t)
+
+	unsupported := filepath.Join(t.TempDir(), "data.unsupported")
+	require.NoError(t, os.WriteFile(unsupported, []byte("data"), 0o644))
+
+	me := NewMetaExtractor(Options{ExifToolPath: script})
+	defer me.Close()
+
+	// The message written to the standard error is reported, and the
+	// process is kept, as the failure is that of the file.
+	_, err := me.extractExifData(context.Background(), unsupported)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Error: Unknown file type")
+
+	exif, err := me.extractExifData(context.Background(), filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+	assert.NotContains(t, exif, "Error")
+	assert.Equal(t, 1, starts(t, log))
+}
+
+func TestExifToolCancel(t *testing.T) {
+	_, log := fakeExifTool(t)
+
+	// The script never answers, and exits only when killed.
+	script := filepath.Join(filepath.Dir(log), "exiftool-hang")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo $$ >> "`+log+`"
+exec sleep 60
+`), 0o755))
+
+	me := NewMetaExtractor(Options{ExifToolPath: script})
+	defer me.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := me.extractExifData(ctx, filepath.Join("testdata", "sample.doc"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	b, err := os.ReadFile(log)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	require.NoError(t, err)
+
+	// The process was killed and reaped.
+	p, err := os.FindProcess(pid)
+	require.NoError(t, err)
+	assert.Error(t, p.Signal(syscall.Signal(0)))
+	assert.Empty(t, me.exifTools.idle)
+}
+
+func TestExifToolPool_Close(t *testing.T) {
+	_, log := fakeExifTool(t)
+
+	// The script never answers, and exits only when killed.
+	script := filepath.Join(filepath.Dir(log), "exiftool-hang")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo $$ >> "`+log+`"
+exec sleep 60
+`), 0o755))
+
+	me := NewMetaExtractor(Options{ExifToolPath: script})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := me.extractExifData(context.Background(), filepath.Join("testdata", "sample.doc"))
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		me.exifTools.mu.Lock()
+		defer me.exifTools.mu.Unlock()
+		return len(me.exifTools.busy) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, me.Close())
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ExifTool was not killed")
+	}
+	assert.Equal(t, 1, starts(t, log))
+}
+
+func TestExifToolPool_CPUTime(t *testing.T) {
+	_, log := fakeExifTool(t)
+	dir := filepath.Dir(log)
+
+	// The script takes 300 ms for files named "slow".
+	script := filepath.Join(dir, "exiftool-slow")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo $$ >> "`+log+`"
+while read -r line; do
+	case "$line" in
+	False) quit=1 ;;
+	-echo4) read -r echo ;;
+	-execute)
+		[ -n "$quit" ] && exit 0
+		case "$file" in */slow) sleep 0.3 ;; esac
+		printf '[{"SourceFile":"%s","Pid":%s}]\n{ready}\n' "$file" $$
+		echo "$echo" >&2 ;;
+	-*) ;;
+	*) file=$line ;;
+	esac
+done
+`), 0o755))
+
+	fast := filepath.Join("testdata", "sample.doc")
+	slow := filepath.Join(dir, "slow")
+	require.NoError(t, os.WriteFile(slow, nil, 0o644))
+
+	t.Run("Worn", func(t *testing.T) {
+		pool := newExifToolPool(script, nil, 500*time.Millisecond)
+		defer pool.close()
+
+		first, err := pool.extract(context.Background(), fast)
+		require.NoError(t, err)
+
+		// The process was busy for more than half of the limit.
+		second, err := pool.extract(context.Background(), slow)
+		require.NoError(t, err)
+		assert.Equal(t, first["Pid"], second["Pid"])
+		assert.Empty(t, pool.idle)
+
+		third, err := pool.extract(context.Background(), fast)
+		require.NoError(t, err)
+		assert.NotEqual(t, first["Pid"], third["Pid"])
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		pool := newExifToolPool(script, nil, time.Second)
+		defer pool.close()
+
+		first, err := pool.extract(context.Background(), fast)
+		require.NoError(t, err)
+		require.Len(t, pool.idle, 1)
+		pool.idle[0].busy = 900 * time.Millisecond
+
+		// The reused process is killed before its limit, and the file is
+		// extracted by a new process.
+		exif, err := pool.extract(context.Background(), slow)
+		require.NoError(t, err)
+		assert.NotEqual(t, first["Pid"], exif["Pid"])
+		assert.Len(t, pool.idle, 1)
+	})
+}
@@ -0,0 +1,858 @@
+package metaextractor
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expr is a compiled condition over Metadata, as used by Rule.
+//
+// The expression language supports:
+//   - field access on Metadata: Size, Name, Types[0].Extension, Exif["FileType"], Exif.FileType
+//   - literals: numbers with optional size suffix (KB, MB, GB, TB; binary units),
+//     "strings", 'strings', true, false, nil and lists ([".jpg", ".png"])
+//   - comparison: ==, !=, <, <=, >, >=
+//   - string and collection operators: contains, startsWith, endsWith, matches (regexp), in
+//   - logical operators: &&, ||, ! and parentheses
+//   - functions: len(x), lower(s), upper(s)
+//
+// Missing map keys and out-of-range indexes evaluate to nil, so conditions on
+// optional fields do not fail. Strings are compared as numbers or times (RFC 3339
+// or "2006-01-02") when the other operand is a number or time.
+type Expr struct {
+	src  string
+	root node
+}
+
+// CompileExpr parses the expression and returns a compiled Expr.
+func CompileExpr(src string) (*Expr, error) {
+	p := &parser{lexer: lexer{src: src}}
+	p.next()
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("error compiling expression %q: %w", src, err)
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("error compiling expression %q: unexpected %q at position %d", src, p.tok.text, p.tok.pos)
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against the metadata. The expression must
+// evaluate to a boolean; nil is treated as false.
+func (e *Expr) Eval(metadata Metadata) (bool, error) {
+	v, err := e.root.eval(reflect.ValueOf(metadata))
+	if err != nil {
+		return false, fmt.Errorf("error evaluating expression %q: %w", e.src, err)
+	}
+
+	b, err := truthy(v)
+	if err != nil {
+		return false, fmt.Errorf("error evaluating expression %q: %w", e.src, err)
+	}
+
+	return b, nil
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+var sizeSuffixes = map[string]float64{
+	"":   1,
+	"B":  1,
+	"KB": 1 << 10, "KIB": 1 << 10,
+	"MB": 1 << 20, "MIB": 1 << 20,
+	"GB": 1 << 30, "GIB": 1 << 30,
+	"TB": 1 << 40, "TIB": 1 << 40,
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c >= '0' && c <= '9':
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		numEnd := l.pos
+		for l.pos < len(l.src) && unicode.IsLetter(rune(l.src[l.pos])) {
+			l.pos++
+		}
+
+		n, err := strconv.ParseFloat(l.src[start:numEnd], 64)
+		if err != nil {
+			return token{}, fmt.Errorf("invalid number %q", l.src[start:l.pos])
+		}
+
+		mult, ok := sizeSuffixes[strings.ToUpper(l.src[numEnd:l.pos])]
+		if !ok {
+			return token{}, fmt.Errorf("invalid size suffix %q", l.src[numEnd:l.pos])
+		}
+
+		return token{kind: tokNumber, text: l.src[start:l.pos], num: n * mult, pos: start}, nil
+
+	case c == '"' || c == '\'':
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] != c {
+			if l.src[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return token{}, fmt.Errorf("unterminated string at position %d", start)
+		}
+		l.pos++
+
+		raw := l.src[start:l.pos]
+		if c == '\'' {
+			raw = `"` + strings.ReplaceAll(raw[1:len(raw)-1], `"`, `\"`) + `"`
+		}
+
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return token{}, fmt.Errorf("invalid string %s", l.src[start:l.pos])
+		}
+
+		return token{kind: tokString, text: s, pos: start}, nil
+
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isDigit(l.src[l.pos]) || unicode.IsLetter(rune(l.src[l.pos]))) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	}
+
+	for _, op := range []string{"&&", "||", "==", "!=", "<=", ">="} {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokPunct, text: op, pos: start}, nil
+		}
+	}
+
+	if strings.ContainsRune("!<>()[].,-", rune(c)) {
+		l.pos++
+		return token{kind: tokPunct, text: string(c), pos: start}, nil
+	}
+
+	return token{}, fmt.Errorf("unexpected character %q at position %d", c, start)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Parser
+
+type parser struct {
+	lexer lexer
+	tok   token
+	err   error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+
+	p.tok, p.err = p.lexer.next()
+	if p.err != nil {
+		p.tok = token{kind: tokEOF}
+	}
+}
+
+func (p *parser) is(text string) bool {
+	return (p.tok.kind == tokPunct || p.tok.kind == tokIdent) && p.tok.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	if !p.is(text) {
+		return fmt.Errorf("expected %q at position %d", text, p.tok.pos)
+	}
+	p.next()
+
+	return p.err
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.is("||") {
+		p.next()
+
+		var right node
+		if right, err = p.parseAnd(); err == nil {
+			left = logicalNode{op: "||", left: left, right: right}
+		}
+	}
+
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	for err == nil && p.is("&&") {
+		p.next()
+
+		var right node
+		if right, err = p.parseComparison(); err == nil {
+			left = logicalNode{op: "&&", left: left, right: right}
+		}
+	}
+
+	return left, err
+}
+
+var comparisonOps = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"contains": true, "startsWith": true, "endsWith": true, "matches": true, "in": true,
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	if !comparisonOps[p.tok.text] || p.tok.kind == tokString {
+		return left, p.err
+	}
+
+	op := p.tok.text
+	p.next()
+
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	n := comparisonNode{op: op, left: left, right: right}
+	if lit, ok := right.(literalNode); ok && op == "matches" {
+		s, ok := lit.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("matches requires a string pattern")
+		}
+		if n.re, err = regexp.Compile(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.is("!") || p.is("-") {
+		op := p.tok.text
+		p.next()
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return unaryNode{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	for err == nil {
+		switch {
+		case p.is("."):
+			p.next()
+			if p.tok.kind != tokIdent {
+				return nil, fmt.Errorf("expected field name at position %d", p.tok.pos)
+			}
+			n = fieldNode{target: n, name: p.tok.text}
+			p.next()
+
+		case p.is("["):
+			p.next()
+
+			var index node
+			if index, err = p.parseOr(); err == nil {
+				err = p.expect("]")
+				n = indexNode{target: n, index: index}
+			}
+
+		default:
+			return n, p.err
+		}
+	}
+
+	return nil, err
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		p.next()
+		return literalNode{value: tok.num}, p.err
+
+	case tokString:
+		p.next()
+		return literalNode{value: tok.text}, p.err
+
+	case tokIdent:
+		p.next()
+
+		switch tok.text {
+		case "true", "false":
+			return literalNode{value: tok.text == "true"}, p.err
+		case "nil":
+			return literalNode{value: nil}, p.err
+		}
+
+		if !p.is("(") {
+			return fieldNode{name: tok.text}, p.err
+		}
+
+		fn, ok := builtinFuncs[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q", tok.text)
+		}
+		p.next()
+
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		return callNode{name: tok.text, fn: fn, arg: arg}, p.expect(")")
+
+	case tokPunct:
+		switch tok.text {
+		case "(":
+			p.next()
+
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			return n, p.expect(")")
+
+		case "[":
+			p.next()
+
+			var items []node
+			for !p.is("]") {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+
+				if !p.is(",") {
+					break
+				}
+				p.next()
+			}
+
+			return listNode{items: items}, p.expect("]")
+		}
+	}
+
+	if tok.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// Evaluation
+
+type node interface {
+	eval(root reflect.Value) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(reflect.Value) (interface{}, error) {
+	return n.value, nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n listNode) eval(root reflect.Value) (interface{}, error) {
+	list := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(root)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+
+	return list, nil
+}
+
+type fieldNode struct {
+	target node
+	name   string
+}
+
+func (n fieldNode) eval(root reflect.Value) (interface{}, error) {
+	target := root
+	if n.target != nil {
+		v, err := n.target.eval(root)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		target = reflect.ValueOf(v)
+	}
+
+	target = indirect(target)
+	switch target.Kind() {
+	case reflect.Struct:
+		f := target.FieldByName(n.name)
+		if !f.IsValid() || !f.CanInterface() {
+			return nil, fmt.Errorf("unknown field %q", n.name)
+		}
+		return normalize(f), nil
+
+	case reflect.Map:
+		return mapIndex(target, n.name)
+	}
+
+	return nil, fmt.Errorf("cannot access field %q of %s", n.name, target.Kind())
+}
+
+type indexNode struct {
+	target node
+	index  node
+}
+
+func (n indexNode) eval(root reflect.Value) (interface{}, error) {
+	v, err := n.target.eval(root)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	idx, err := n.index.eval(root)
+	if err != nil {
+		return nil, err
+	}
+
+	target := indirect(reflect.ValueOf(v))
+	switch target.Kind() {
+	case reflect.Slice, reflect.Array, reflect.String:
+		i, ok := idx.(float64)
+		if !ok {
+			return nil, fmt.Errorf("index must be a number")
+		}
+		if i < 0 || int(i) >= target.Len() {
+			return nil, nil
+		}
+		return normalize(target.Index(int(i))), nil
+
+	case reflect.Map:
+		key, ok := idx.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key must be a string")
+		}
+		return mapIndex(target, key)
+	}
+
+	return nil, fmt.Errorf("cannot index %s", target.Kind())
+}
+
+type callNode struct {
+	name string
+	fn   func(interface{}) (interface{}, error)
+	arg  node
+}
+
+func (n callNode) eval(root reflect.Value) (interface{}, error) {
+	v, err := n.arg.eval(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.fn(v)
+}
+
+var builtinFuncs = map[string]func(interface{}) (interface{}, error){
+	"len": func(v interface{}) (interface{}, error) {
+		if v == nil {
+			return float64(0), nil
+		}
+		rv := indirect(reflect.ValueOf(v))
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+			return float64(rv.Len()), nil
+		}
+		return nil, fmt.Errorf("len: invalid argument of type %T", v)
+	},
+	"lower": func(v interface{}) (interface{}, error) {
+		return strings.ToLower(stringValue(v)), nil
+	},
+	"upper": func(v interface{}) (interface{}, error) {
+		return strings.ToUpper(stringValue(v)), nil
+	},
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n unaryNode) eval(root reflect.Value) (interface{}, error) {
+	v, err := n.operand.eval(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "-" {
+		f, ok := numberValue(v)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %T", v)
+		}
+		return -f, nil
+	}
+
+	b, err := truthy(v)
+	return !b, err
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n logicalNode) eval(root reflect.Value) (interface{}, error) {
+	lv, err := n.left.eval(root)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := truthy(lv)
+	if err != nil {
+		return nil, err
+	}
+
+	if (n.op == "&&" && !l) || (n.op == "||" && l) {
+		return l, nil
+	}
+
+	rv, err := n.right.eval(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return truthy(rv)
+}
+
+type comparisonNode struct {
+	op          string
+	left, right node
+	re          *regexp.Regexp
+}
+
+func (n comparisonNode) eval(root reflect.Value) (interface{}, error) {
+	l, err := n.left.eval(root)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := n.right.eval(root)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(l, r), nil
+	case "!=":
+		return !valuesEqual(l, r), nil
+	case "<", "<=", ">", ">=":
+		if l == nil || r == nil {
+			return false, nil
+		}
+		c, err := compareValues(l, r)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "contains":
+		return containsValue(l, r), nil
+	case "in":
+		return containsValue(r, l), nil
+	case "startsWith":
+		return l != nil && strings.HasPrefix(stringValue(l), stringValue(r)), nil
+	case "endsWith":
+		return l != nil && strings.HasSuffix(stringValue(l), stringValue(r)), nil
+	case "matches":
+		if l == nil {
+			return false, nil
+		}
+		re := n.re
+		if re == nil {
+			if re, err = regexp.Compile(stringValue(r)); err != nil {
+				return nil, err
+			}
+		}
+		return re.MatchString(stringValue(l)), nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %q", n.op)
+}
+
+// Value helpers
+
+// indirect dereferences pointers and interfaces.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// normalize converts a reflected value into one of the canonical value types
+// used by the evaluator: nil, bool, float64, string, time.Time or the
+// underlying Go value for composite types.
+func normalize(v reflect.Value) interface{} {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	}
+
+	return v.Interface()
+}
+
+func mapIndex(m reflect.Value, key string) (interface{}, error) {
+	if m.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("map key must be a string")
+	}
+
+	v := m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key()))
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	return normalize(v), nil
+}
+
+func truthy(v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	}
+
+	return false, fmt.Errorf("expected boolean, got %T", v)
+}
+
+func stringValue(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(v)
+}
+
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+func timeValue(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func valuesEqual(l, r interface{}) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+
+	if _, ok := l.(float64); ok {
+		if rf, ok := numberValue(r); ok {
+			lf, _ := numberValue(l)
+			return lf == rf
+		}
+	}
+	if _, ok := r.(float64); ok {
+		if lf, ok := numberValue(l); ok {
+			rf, _ := numberValue(r)
+			return lf == rf
+		}
+	}
+
+	if lt, ok := l.(time.Time); ok {
+		rt, ok := timeValue(r)
+		return ok && lt.Equal(rt)
+	}
+	if rt, ok := r.(time.Time); ok {
+		lt, ok := timeValue(l)
+		return ok && lt.Equal(rt)
+	}
+
+	return reflect.DeepEqual(l, r)
+}
+
+func compareValues(l, r interface{}) (int, error) {
+	_, lNum := l.(float64)
+	_, rNum := r.(float64)
+	if lNum || rNum {
+		lf, lok := numberValue(l)
+		rf, rok := numberValue(r)
+		if lok && rok {
+			switch {
+			case lf < rf:
+				return -1, nil
+			case lf > rf:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	_, lTime := l.(time.Time)
+	_, rTime := r.(time.Time)
+	if lTime || rTime {
+		lt, lok := timeValue(l)
+		rt, rok := timeValue(r)
+		if lok && rok {
+			return lt.Compare(rt), nil
+		}
+	}
+
+	ls, lok := l.(string)
+	rs, rok := r.(string)
+	if lok && rok {
+		return strings.Compare(ls, rs), nil
+	}
+
+	return 0, fmt.Errorf("cannot compare %T and %T", l, r)
+}
+
+func containsValue(collection, item interface{}) bool {
+	switch c := collection.(type) {
+	case nil:
+		return false
+	case string:
+		return strings.Contains(c, stringValue(item))
+	}
+
+	rv := indirect(reflect.ValueOf(collection))
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if valuesEqual(normalize(rv.Index(i)), item) {
+				return true
+			}
+		}
+	case reflect.Map:
+		if key, ok := item.(string); ok && rv.Type().Key().Kind() == reflect.String {
+			return rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())).IsValid()
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,96 @@
+package metaextractor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpr(t *testing.T) {
+	metadata := Metadata{
+		Name:      "holiday.mov",
+		Extension: ".mov",
+		Size:      2 << 30,
+		Time:      FileTime{ModTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		Types:     []trid.FileType{{Extension: ".mov", MimeType: "video/quicktime", Probability: 87.5}},
+		Exif:      ExifMetadata{"FileType": "MOV", "ImageWidth": float64(1920), "Rotation": "90"},
+		Labels:    []string{"video"},
+	}
+
+	testCases := []struct {
+		expr     string
+		expected bool
+	}{
+		{`Size > 1GB && Types[0].Extension == ".mov"`, true},
+		{`Size > 3GB`, false},
+		{`Exif["FileType"] == "MOV"`, true},
+		{`Exif.FileType == 'MOV'`, true},
+		{`Exif.ImageWidth >= 1920`, true},
+		{`Exif.Rotation == 90`, true},
+		{`Exif.Missing == nil`, true},
+		{`Exif.Missing > 10`, false},
+		{`Types[5].Extension == ".mov"`, false},
+		{`Types[0].Probability > 80.5`, true},
+		{`Name endsWith ".mov" && Name startsWith "holiday"`, true},
+		{`Name matches "^hol.*\\.mov$"`, true},
+		{`Extension in [".mov", ".mp4"]`, true},
+		{`Labels contains "video"`, true},
+		{`Exif contains "FileType"`, true},
+		{`len(Types) == 1 && upper(Extension) == ".MOV"`, true},
+		{`!(ExtMismatch || Size < 1KB)`, true},
+		{`Time.ModTime > "2024-01-01"`, true},
+		{`Time.ModTime < "2024-01-01T00:00:00Z"`, false},
+		{`-Size < 0`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, err := CompileExpr(tc.expr)
+			require.NoError(t, err)
+
+			result, err := expr.Eval(metadata)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestExpr_Errors(t *testing.T) {
+	compileErrors := []string{
+		``,
+		`Size >`,
+		`Size > 1XB`,
+		`Name == "unterminated`,
+		`(Size > 1`,
+		`unknown(Size)`,
+		`Name matches "["`,
+		`Size # 1`,
+	}
+
+	for _, src := range compileErrors {
+		t.Run(src, func(t *testing.T) {
+			_, err := CompileExpr(src)
+			assert.Error(t, err)
+		})
+	}
+
+	evalErrors := []string{
+		`Unknown == 1`,
+		`Size`,
+		`Name > 1 && Size`,
+		`Types["x"] == nil`,
+	}
+
+	for _, src := range evalErrors {
+		t.Run(src, func(t *testing.T) {
+			expr, err := CompileExpr(src)
+			require.NoError(t, err)
+
+			_, err = expr.Eval(Metadata{Name: "a", Types: []trid.FileType{{}}})
+			assert.Error(t, err)
+		})
+	}
+}
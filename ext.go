@@ -0,0 +1,55 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/attilabuti/trid"
+)
+
+// DefaultCompoundExtensions are the multi-part extensions recognized when
+// Options.CompoundExtensions is nil.
+var DefaultCompoundExtensions = []string{
+	".tar.gz", ".tar.bz2", ".tar.xz", ".tar.zst", ".tar.lz", ".tar.lzma", ".tar.z",
+	".nii.gz", ".warc.gz", ".svg.gz", ".ps.gz", ".fits.gz",
+}
+
+// fileExtension returns the extension of the file name, lowercased and as it
+// appears in the name. Compound extensions (e.g., ".tar.gz") are returned as
+// a whole.
+func (me *MetaExtractor) fileExtension(name string) (ext, raw string) {
+	lower := strings.ToLower(name)
+	for _, compound := range me.compoundExts {
+		compound = strings.ToLower(compound)
+		if len(lower) > len(compound) && strings.HasSuffix(lower, compound) {
+			raw = name[len(name)-len(compound):]
+			return strings.ToLower(raw), raw
+		}
+	}
+
+	raw = filepath.Ext(name)
+	return strings.ToLower(raw), raw
+}
+
+// isCompressorExt reports whether the lowercased extension is the compression
+// suffix of a compound extension (e.g., ".gz" of ".tar.gz").
+func (me *MetaExtractor) isCompressorExt(ext string) bool {
+	for _, compound := range me.compoundExts {
+		if strings.EqualFold(filepath.Ext(compound), ext) && !strings.EqualFold(compound, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extMismatch reports whether the extension differs from the extension of
+// the file type, using the raw extension if Options.CaseSensitiveExtensions
+// is set.
+func (me *MetaExtractor) extMismatch(ext, raw string, fileType trid.FileType) bool {
+	if me.caseSensitiveExts {
+		return extMismatch(raw, fileType)
+	}
+
+	return extMismatch(ext, fileType)
+}
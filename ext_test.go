@@ -0,0 +1,88 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileExtension(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	testCases := []struct {
+		name string
+		ext  string
+		raw  string
+	}{
+		{"photo.JPG", ".jpg", ".JPG"},
+		{"archive.tar.gz", ".tar.gz", ".tar.gz"},
+		{"Brain.NII.GZ", ".nii.gz", ".NII.GZ"},
+		{"backup.old.gz", ".gz", ".gz"},
+		{".tar.gz", ".gz", ".gz"},
+		{"README", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ext, raw := me.fileExtension(tc.name)
+			assert.Equal(t, tc.ext, ext)
+			assert.Equal(t, tc.raw, raw)
+		})
+	}
+
+	custom := NewMetaExtractor(Options{PureGo: true, CompoundExtensions: []string{".TAR.GZ"}})
+	ext, _ := custom.fileExtension("brain.nii.gz")
+	assert.Equal(t, ".gz", ext)
+	ext, _ = custom.fileExtension("a.tar.gz")
+	assert.Equal(t, ".tar.gz", ext)
+}
+
+func TestExtMismatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ext      string
+		fileType trid.FileType
+		expected bool
+	}{
+		{"Match", ".pdf", trid.FileType{Extension: ".pdf"}, false},
+		{"Mismatch", ".doc", trid.FileType{Extension: ".pdf"}, true},
+		{"Alternative", ".jpeg", trid.FileType{Extension: ".jpg/.jpeg"}, false},
+		{"Compound Extension", ".tar.gz", trid.FileType{Extension: ".gz"}, false},
+		{"Compound Type", ".gz", trid.FileType{Extension: ".tgz/.tar.gz"}, false},
+		{"Different Compound", ".nii.gz", trid.FileType{Extension: ".tar.gz"}, true},
+		{"No Dot Boundary", ".jpeg", trid.FileType{Extension: ".peg"}, true},
+		{"Case", ".JPG", trid.FileType{Extension: ".jpg"}, true},
+		{"No Type Extension", ".so", trid.FileType{}, true},
+		{"No Extensions", "", trid.FileType{}, false},
+		{"Missing Extension", "", trid.FileType{Extension: ".pdf"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, extMismatch(tc.ext, tc.fileType))
+		})
+	}
+}
+
+func TestMetaExtractor_CaseSensitiveExtensions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "sample.mp3"))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "Sample.MP3")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	metadata, err := NewMetaExtractor(Options{PureGo: true}).Extract(path)
+	require.NoError(t, err)
+	assert.Equal(t, ".mp3", metadata.Extension)
+	assert.Equal(t, ".MP3", metadata.RawExtension)
+	assert.False(t, metadata.ExtMismatch)
+
+	metadata, err = NewMetaExtractor(Options{PureGo: true, CaseSensitiveExtensions: true}).Extract(path)
+	require.NoError(t, err)
+	assert.True(t, metadata.ExtMismatch)
+	assert.Equal(t, "Sample.mp3", metadata.SuggestedName)
+}
@@ -0,0 +1,54 @@
+package metaextractor
+
+import (
+	"context"
+	"io"
+	"io/fs"
+)
+
+// Interface is the extraction API of MetaExtractor. It is implemented by
+// MetaExtractor and by the fake of the metaextractortest package, so that
+// applications can depend on the interface and be unit-tested without TrID
+// and ExifTool.
+type Interface interface {
+	// Extract extracts metadata from a single file.
+	Extract(filePath string) (Metadata, error)
+
+	// ExtractContext is like Extract, but aborts the extraction when ctx is
+	// done.
+	ExtractContext(ctx context.Context, filePath string) (Metadata, error)
+
+	// ExtractBatch extracts metadata from each of the given files.
+	ExtractBatch(paths []string) []Result
+
+	// ExtractStream extracts metadata from the files received from paths,
+	// delivering the results in order until paths is closed or ctx is done.
+	ExtractStream(ctx context.Context, paths <-chan string) <-chan Result
+
+	// ExtractReader extracts metadata from the content read from r as if it
+	// were a file with the given name.
+	ExtractReader(r io.Reader, name string) (Metadata, error)
+
+	// ExtractDir extracts metadata from the files below root.
+	ExtractDir(root string, opts WalkOptions) ([]Result, error)
+
+	// ExtractDirMetadata returns metadata of a directory and the tree
+	// below it.
+	ExtractDirMetadata(dirPath string) (DirMetadata, error)
+
+	// ExtractFS extracts metadata from the files below root in fsys.
+	ExtractFS(fsys fs.FS, root string, opts WalkOptions) ([]Result, error)
+
+	// ExtractTar extracts metadata from the entries of a TAR stream.
+	ExtractTar(r io.Reader, fn func(Result)) error
+
+	// FixExtension renames files whose extension does not match their
+	// detected type.
+	FixExtension(path string, dryRun bool) ([]Rename, error)
+
+	// Close releases the resources of the extractor; later extractions fail
+	// with ErrClosed.
+	Close() error
+}
+
+var _ Interface = (*MetaExtractor)(nil)
@@ -0,0 +1,227 @@
+package metaextractor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ffprobeOutput is the output of ffprobe with -show_format and
+// -show_streams. Most numbers are reported as strings.
+type ffprobeOutput struct {
+	Streams []struct {
+		Index            int               `json:"index"`
+		CodecType        string            `json:"codec_type"`
+		CodecName        string            `json:"codec_name"`
+		CodecLongName    string            `json:"codec_long_name"`
+		Profile          string            `json:"profile"`
+		Duration         string            `json:"duration"`
+		BitRate          string            `json:"bit_rate"`
+		Width            int               `json:"width"`
+		Height           int               `json:"height"`
+		AvgFrameRate     string            `json:"avg_frame_rate"`
+		RFrameRate       string            `json:"r_frame_rate"`
+		PixFmt           string            `json:"pix_fmt"`
+		BitsPerRawSample string            `json:"bits_per_raw_sample"`
+		SampleRate       string            `json:"sample_rate"`
+		Channels         int               `json:"channels"`
+		ChannelLayout    string            `json:"channel_layout"`
+		Tags             map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format *struct {
+		FormatName     string            `json:"format_name"`
+		FormatLongName string            `json:"format_long_name"`
+		Duration       string            `json:"duration"`
+		BitRate        string            `json:"bit_rate"`
+		Tags           map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// errNoMediaStreams is returned by the media backends for files they do not
+// recognize as audio or video.
+var errNoMediaStreams = errors.New("no media streams")
+
+// runFFprobe reads the container and stream properties of the file with
+// ffprobe.
+func runFFprobe(ctx context.Context, cmd, filePath string) (*Media, error) {
+	args := []string{"-v", "error", "-print_format", "json", "-show_format", "-show_streams", "--", filePath}
+
+	out, err := exec.CommandContext(ctx, cmd, args...).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			stderr := strings.TrimSpace(string(exitErr.Stderr))
+			// ffprobe fails on files it cannot demux.
+			if strings.Contains(stderr, "Invalid data found when processing input") {
+				return nil, errNoMediaStreams
+			}
+			return nil, fmt.Errorf("%w: %s", err, stderr)
+		}
+		return nil, err
+	}
+
+	return parseFFprobe(out)
+}
+
+// parseFFprobe converts the JSON output of ffprobe into the media
+// properties.
+func parseFFprobe(data []byte) (*Media, error) {
+	var out ffprobeOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("error parsing ffprobe output: %w", err)
+	}
+
+	if out.Format == nil && len(out.Streams) == 0 {
+		return nil, errNoMediaStreams
+	}
+
+	media := &Media{}
+	if f := out.Format; f != nil {
+		media.Format = f.FormatName
+		media.FormatName = f.FormatLongName
+		media.Duration = parseFloat(f.Duration)
+		media.BitRate = int64(parseFloat(f.BitRate))
+		media.Tags = f.Tags
+	}
+
+	for _, s := range out.Streams {
+		stream := MediaStream{
+			Index:         s.Index,
+			Type:          s.CodecType,
+			Codec:         s.CodecName,
+			CodecName:     s.CodecLongName,
+			Profile:       s.Profile,
+			Duration:      parseFloat(s.Duration),
+			BitRate:       int64(parseFloat(s.BitRate)),
+			Width:         s.Width,
+			Height:        s.Height,
+			PixelFormat:   s.PixFmt,
+			BitDepth:      int(parseFloat(s.BitsPerRawSample)),
+			SampleRate:    int(parseFloat(s.SampleRate)),
+			Channels:      s.Channels,
+			ChannelLayout: s.ChannelLayout,
+			Language:      s.Tags["language"],
+			Tags:          s.Tags,
+		}
+
+		if s.CodecType == "video" {
+			stream.FrameRate = firstNonZero(parseFrameRate(s.AvgFrameRate), parseFrameRate(s.RFrameRate))
+		}
+		// "und" is the ISO 639-2 code of an undetermined language.
+		if stream.Language == "und" {
+			stream.Language = ""
+		}
+
+		media.Streams = append(media.Streams, stream)
+	}
+
+	return media, nil
+}
+
+// parseFloat parses a number reported as a string, or returns 0 if it is
+// missing or invalid (e.g., "N/A").
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return f
+}
+
+// parseFrameRate parses a frame rate reported as a fraction (e.g.,
+// "30000/1001"). Unknown rates are reported as "0/0".
+func parseFrameRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		return parseFloat(s)
+	}
+
+	d := parseFloat(den)
+	if d == 0 {
+		return 0
+	}
+
+	return parseFloat(num) / d
+}
+
+// ffprobeBackend is the ffprobe stage bound to an extractor.
+type ffprobeBackend struct {
+	me *MetaExtractor
+}
+
+func (b ffprobeBackend) Name() string {
+	return "ffprobe"
+}
+
+func (b ffprobeBackend) Extract(ctx context.Context, filePath string, metadata *Metadata) error {
+	if b.me == nil {
+		return fmt.Errorf("%s: %w", b.Name(), errBackendNotConfigured)
+	}
+
+	return b.me.ffprobe(ctx, filePath, metadata)
+}
+
+// ffprobe reads the media properties of audio and video files with ffprobe
+// and merges them into Metadata.Media.
+func (me *MetaExtractor) ffprobe(ctx context.Context, filePath string, metadata *Metadata) error {
+	if me.pureGo || !isMediaStreamFile(*metadata) {
+		return nil
+	}
+
+	media, err := runFFprobe(ctx, me.ffprobeCmd, filePath)
+	if errors.Is(err, errNoMediaStreams) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	metadata.addMedia(SourceFFprobe, media)
+
+	return nil
+}
+
+// ffprobeStage runs the ffprobe backend on the file.
+func (me *MetaExtractor) ffprobeStage(ctx context.Context, toolPath string, metadata *Metadata, trace *stageTrace) error {
+	if me.pureGo || toolPath == "" {
+		return nil
+	}
+
+	start := time.Now()
+	err := me.ffprobe(ctx, toolPath, metadata)
+	trace.done("ffprobe", start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error running ffprobe: %w", err))
+	}
+
+	return nil
+}
+
+// checkFFprobe runs ffprobe and returns its version.
+func checkFFprobe(cmd string) (string, error) {
+	out, err := exec.Command(cmd, "-version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	// e.g., "ffprobe version 6.1.1-3ubuntu5 Copyright (c) 2007-2023 ..."
+	fields := strings.Fields(string(out))
+	if len(fields) >= 3 && fields[0] == "ffprobe" && fields[1] == "version" {
+		return fields[2], nil
+	}
+
+	return "", fmt.Errorf("unexpected output: %q", strings.TrimSpace(string(out)))
+}
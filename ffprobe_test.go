@@ -0,0 +1,168 @@
+package metaextractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ffprobeSample is the ffprobe output of a video with an H.264 and an AAC
+// stream.
+const ffprobeSample = `{
+	"streams": [
+		{
+			"index": 0, "codec_name": "h264", "codec_long_name": "H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10",
+			"profile": "High", "codec_type": "video", "width": 1920, "height": 1080, "pix_fmt": "yuv420p",
+			"r_frame_rate": "30000/1001", "avg_frame_rate": "30000/1001", "duration": "10.010000",
+			"bit_rate": "4872311", "bits_per_raw_sample": "8",
+			"tags": {"language": "und", "handler_name": "VideoHandler"}
+		},
+		{
+			"index": 1, "codec_name": "aac", "codec_long_name": "AAC (Advanced Audio Coding)", "profile": "LC",
+			"codec_type": "audio", "sample_rate": "48000", "channels": 2, "channel_layout": "stereo",
+			"r_frame_rate": "0/0", "avg_frame_rate": "0/0", "duration": "N/A", "bit_rate": "128000",
+			"tags": {"language": "eng"}
+		}
+	],
+	"format": {
+		"filename": "clip.mp4", "nb_streams": 2, "format_name": "mov,mp4,m4a,3gp,3g2,mj2",
+		"format_long_name": "QuickTime / MOV", "duration": "10.010000", "size": "6271104",
+		"bit_rate": "5011871", "probe_score": 100,
+		"tags": {"major_brand": "isom", "encoder": "Lavf60.16.100", "location": "+47.5034-008.2500/"}
+	}
+}`
+
+// ffprobeSampleMedia is the media properties of ffprobeSample.
+var ffprobeSampleMedia = Media{
+	Format:     "mov,mp4,m4a,3gp,3g2,mj2",
+	FormatName: "QuickTime / MOV",
+	Duration:   10.01,
+	BitRate:    5011871,
+	Streams: []MediaStream{
+		{
+			Index: 0, Type: "video", Codec: "h264", CodecName: "H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10",
+			Profile: "High", Duration: 10.01, BitRate: 4872311, Width: 1920, Height: 1080,
+			FrameRate: 30000.0 / 1001, PixelFormat: "yuv420p", BitDepth: 8,
+			Tags: map[string]string{"language": "und", "handler_name": "VideoHandler"},
+		},
+		{
+			Index: 1, Type: "audio", Codec: "aac", CodecName: "AAC (Advanced Audio Coding)", Profile: "LC",
+			BitRate: 128000, Language: "eng", SampleRate: 48000, Channels: 2, ChannelLayout: "stereo",
+			Tags: map[string]string{"language": "eng"},
+		},
+	},
+	Tags: map[string]string{"major_brand": "isom", "encoder": "Lavf60.16.100", "location": "+47.5034-008.2500/"},
+}
+
+// fakeFFprobe writes a script standing in for ffprobe, which prints the
+// given output for every file, or fails like ffprobe on files it cannot
+// demux if the output is empty.
+func fakeFFprobe(t *testing.T, output string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "ffprobe")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+[ "$1" = "-version" ] && { echo "ffprobe version 6.1.1 Copyright (c) 2007-2023 the FFmpeg developers"; exit 0; }
+if [ -z '`+output+`' ]; then
+	for last; do :; done
+	echo "$last: Invalid data found when processing input" >&2
+	exit 1
+fi
+cat <<'EOF'
+`+output+`
+EOF
+`), 0o755))
+
+	return script
+}
+
+func TestParseFFprobe(t *testing.T) {
+	media, err := parseFFprobe([]byte(ffprobeSample))
+	require.NoError(t, err)
+	assert.Equal(t, ffprobeSampleMedia, *media)
+
+	_, err = parseFFprobe([]byte(`{}`))
+	assert.ErrorIs(t, err, errNoMediaStreams)
+
+	_, err = parseFFprobe([]byte(`not json`))
+	assert.ErrorContains(t, err, "error parsing ffprobe output")
+}
+
+func TestFFprobeBackend(t *testing.T) {
+	if pureGoBuild {
+		t.Skip("external tools are disabled in pure-Go builds")
+	}
+
+	script := fakeFFprobe(t, ffprobeSample)
+	sample := filepath.Join("testdata", "sample.mp3")
+
+	newExtractor := func(opts Options) *MetaExtractor {
+		opts.Detectors = []Detector{SignatureDetector}
+		opts.Backends = []Extractor{TridBackend, FFprobeBackend}
+		return NewMetaExtractor(opts)
+	}
+
+	t.Run("Extract", func(t *testing.T) {
+		me := newExtractor(Options{FFprobePath: script, Provenance: true})
+
+		metadata, err := me.Extract(sample)
+		require.NoError(t, err)
+		require.NotNil(t, metadata.Media)
+		expected := ffprobeSampleMedia
+		expected.Analyzer = "ffprobe"
+		assert.Equal(t, expected, *metadata.Media)
+		assert.Equal(t, SourceFFprobe, metadata.Provenance["Media"])
+
+		statuses, err := me.CheckTools()
+		require.NoError(t, err)
+		assert.Equal(t, []ToolStatus{{Name: "ffprobe", Path: script, Version: "6.1.1"}}, statuses)
+	})
+
+	t.Run("Not Media", func(t *testing.T) {
+		metadata, err := newExtractor(Options{FFprobePath: script}).Extract(filepath.Join("testdata", "sample.doc"))
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Media)
+	})
+
+	t.Run("Invalid Data", func(t *testing.T) {
+		me := NewMetaExtractor(Options{FFprobePath: fakeFFprobe(t, ""), Backends: []Extractor{FFprobeBackend}})
+
+		metadata, err := me.Extract(sample)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Media)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "ffprobe")
+
+		_, err := newExtractor(Options{FFprobePath: missing}).Extract(sample)
+		assert.ErrorContains(t, err, "error running ffprobe")
+
+		me := newExtractor(Options{FFprobePath: missing, BestEffort: true})
+		metadata, err := me.Extract(sample)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Media)
+		assert.Len(t, metadata.Warnings, 1)
+		assert.ErrorContains(t, me.Check(), "ffprobe (")
+	})
+
+	t.Run("Pure Go", func(t *testing.T) {
+		metadata, err := newExtractor(Options{PureGo: true, FFprobePath: script}).Extract(sample)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Media)
+	})
+
+	t.Run("Not Configured", func(t *testing.T) {
+		err := FFprobeBackend.Extract(context.Background(), sample, &Metadata{})
+		assert.ErrorIs(t, err, errBackendNotConfigured)
+	})
+}
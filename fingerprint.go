@@ -0,0 +1,293 @@
+package metaextractor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAcoustIDURL is the default endpoint of the AcoustID web service.
+const DefaultAcoustIDURL = "https://api.acoustid.org/v2"
+
+// AudioFingerprintOptions configures the fingerprinting of audio files with
+// Chromaprint (see Metadata.AudioFingerprint).
+type AudioFingerprintOptions struct {
+	// Enabled enables fingerprinting audio files with fpcalc, the
+	// Chromaprint command-line tool. It is skipped by pure-Go extractors.
+	Enabled bool
+
+	// FpcalcPath is the path to fpcalc. Defaults to "fpcalc".
+	FpcalcPath string
+
+	// Length is the number of seconds of audio fingerprinted from the
+	// start of the file. Defaults to 120, as in fpcalc.
+	Length int
+
+	// Timeout is the maximum time fpcalc may take on a file. Defaults to
+	// 30 seconds.
+	Timeout time.Duration
+
+	// Lookup identifies the fingerprinted recordings, e.g. an AcoustID
+	// client. Files are only fingerprinted if nil.
+	Lookup FingerprintLookup
+}
+
+// AudioFingerprint is the Chromaprint fingerprint of an audio file, with
+// the recordings it was identified as.
+type AudioFingerprint struct {
+	// Fingerprint is the compressed fingerprint, as submitted to AcoustID.
+	Fingerprint string `json:"fingerprint"`
+
+	// Duration is the duration of the audio in seconds.
+	Duration float64 `json:"duration"`
+
+	// Recordings are the recordings matching the fingerprint, in
+	// decreasing order of score. It is only set if
+	// AudioFingerprintOptions.Lookup is set.
+	Recordings []Recording `json:"recordings,omitempty"`
+}
+
+// Recording is a MusicBrainz recording identified by its fingerprint.
+type Recording struct {
+	// ID is the MusicBrainz identifier (MBID) of the recording.
+	ID string `json:"id"`
+
+	// Title is the title of the recording.
+	Title string `json:"title,omitempty"`
+
+	// Artists are the names of the credited artists.
+	Artists []string `json:"artists,omitempty"`
+
+	// Score is the confidence (0-1) of the match.
+	Score float64 `json:"score"`
+}
+
+// FingerprintLookup identifies recordings by their Chromaprint fingerprint,
+// such as the AcoustID web service.
+type FingerprintLookup interface {
+	// Lookup returns the recordings matching the fingerprint of audio of
+	// the given duration in seconds.
+	Lookup(ctx context.Context, fingerprint string, duration float64) ([]Recording, error)
+}
+
+// AcoustID is a FingerprintLookup using the AcoustID web service, which
+// returns MusicBrainz recordings.
+type AcoustID struct {
+	// APIKey is the API key of the application.
+	APIKey string
+
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// BaseURL is the API endpoint. Defaults to DefaultAcoustIDURL.
+	BaseURL string
+}
+
+// acoustIDResponse is the response of the AcoustID lookup.
+type acoustIDResponse struct {
+	Status string `json:"status"`
+	Error  struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Results []struct {
+		Score      float64 `json:"score"`
+		Recordings []struct {
+			ID      string `json:"id"`
+			Title   string `json:"title"`
+			Artists []struct {
+				Name string `json:"name"`
+			} `json:"artists"`
+		} `json:"recordings"`
+	} `json:"results"`
+}
+
+// Lookup returns the MusicBrainz recordings matching the fingerprint. A
+// recording matched by several results gets the highest score.
+func (a AcoustID) Lookup(ctx context.Context, fingerprint string, duration float64) ([]Recording, error) {
+	params := url.Values{
+		"client":      {a.APIKey},
+		"meta":        {"recordings"},
+		"duration":    {strconv.Itoa(int(duration))},
+		"fingerprint": {fingerprint},
+	}
+	baseURL := strings.TrimSuffix(firstNonEmpty(a.BaseURL, DefaultAcoustIDURL), "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/lookup?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Errors are reported in the body with a 4xx status.
+	var body acoustIDResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("acoustid request failed: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("error decoding acoustid response: %w", err)
+	}
+
+	if body.Status != "ok" {
+		if body.Error.Message != "" {
+			return nil, fmt.Errorf("acoustid request failed: %s", body.Error.Message)
+		}
+		return nil, fmt.Errorf("acoustid request failed: %s", resp.Status)
+	}
+
+	var recordings []Recording
+	index := make(map[string]int)
+
+	for _, result := range body.Results {
+		for _, r := range result.Recordings {
+			if i, ok := index[r.ID]; ok {
+				recordings[i].Score = max(recordings[i].Score, result.Score)
+				continue
+			}
+
+			recording := Recording{ID: r.ID, Title: r.Title, Score: result.Score}
+			for _, artist := range r.Artists {
+				recording.Artists = append(recording.Artists, artist.Name)
+			}
+
+			index[r.ID] = len(recordings)
+			recordings = append(recordings, recording)
+		}
+	}
+
+	sort.SliceStable(recordings, func(i, j int) bool {
+		return recordings[i].Score > recordings[j].Score
+	})
+
+	return recordings, nil
+}
+
+// defaultFpcalcTimeout is the fpcalc timeout used if
+// AudioFingerprintOptions.Timeout is not set.
+const defaultFpcalcTimeout = 30 * time.Second
+
+// runFpcalc computes the fingerprint of the audio file with fpcalc.
+func runFpcalc(ctx context.Context, opts AudioFingerprintOptions, filePath string) (*AudioFingerprint, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultFpcalcTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := []string{"-json"}
+	if opts.Length > 0 {
+		args = append(args, "-length", strconv.Itoa(opts.Length))
+	}
+	args = append(args, filePath)
+
+	out, err := exec.CommandContext(ctx, fpcalcPath(opts), args...).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+
+	var result struct {
+		Duration    float64 `json:"duration"`
+		Fingerprint string  `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("error parsing fpcalc output: %w", err)
+	}
+	if result.Fingerprint == "" {
+		return nil, errors.New("fpcalc reported no fingerprint")
+	}
+
+	return &AudioFingerprint{Fingerprint: result.Fingerprint, Duration: result.Duration}, nil
+}
+
+// fpcalcPath returns the path to fpcalc.
+func fpcalcPath(opts AudioFingerprintOptions) string {
+	return firstNonEmpty(opts.FpcalcPath, "fpcalc")
+}
+
+// fingerprintStage fingerprints audio files and looks up their recordings,
+// setting Metadata.AudioFingerprint. A failed lookup keeps the fingerprint.
+func (me *MetaExtractor) fingerprintStage(ctx context.Context, toolPath string, metadata *Metadata, trace *stageTrace) error {
+	opts := me.audioFingerprint
+	if !opts.Enabled || me.pureGo || toolPath == "" || mimeTypeKind(metadata.MimeType) != KindAudio {
+		return nil
+	}
+
+	start := time.Now()
+	fingerprint, err := runFpcalc(ctx, opts, toolPath)
+	trace.done("fingerprint", start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error running fpcalc: %w", err))
+	}
+
+	metadata.AudioFingerprint = fingerprint
+	metadata.setSource("AudioFingerprint", SourceChromaprint)
+
+	if opts.Lookup == nil {
+		return nil
+	}
+
+	start = time.Now()
+	recordings, err := opts.Lookup.Lookup(ctx, fingerprint.Fingerprint, fingerprint.Duration)
+	trace.done("fingerprint-lookup", start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error looking up fingerprint: %w", err))
+	}
+
+	fingerprint.Recordings = recordings
+
+	return nil
+}
+
+// checkFpcalc runs fpcalc and returns its version.
+func checkFpcalc(cmd string) (string, error) {
+	out, err := exec.Command(cmd, "-version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	// e.g., "fpcalc version 1.5.1 (FFmpeg Lavc58.134.100 ...)"
+	fields := strings.Fields(string(out))
+	for i, f := range fields {
+		if f == "version" && i+1 < len(fields) {
+			return fields[i+1], nil
+		}
+	}
+
+	return "", fmt.Errorf("unexpected output: %q", strings.TrimSpace(string(out)))
+}
@@ -0,0 +1,153 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFpcalc writes a script printing the given fpcalc output for every file.
+func fakeFpcalc(t *testing.T, output string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "fpcalc")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+[ "$1" = "-version" ] && { echo "fpcalc version 1.5.1 (FFmpeg Lavc58.134.100)"; exit 0; }
+cat <<'EOF'
+`+output+`
+EOF
+`), 0o755))
+
+	return script
+}
+
+// fakeLookup is a FingerprintLookup returning fixed recordings.
+type fakeLookup struct {
+	recordings []Recording
+	err        error
+	queries    *[]string
+}
+
+func (l fakeLookup) Lookup(ctx context.Context, fingerprint string, duration float64) ([]Recording, error) {
+	*l.queries = append(*l.queries, fingerprint)
+	return l.recordings, l.err
+}
+
+func TestMetaExtractor_AudioFingerprint(t *testing.T) {
+	if pureGoBuild {
+		t.Skip("external tools are disabled in pure-Go builds")
+	}
+
+	fpcalc := fakeFpcalc(t, `{"duration": 207.44, "fingerprint": "AQADtEmUJEkS"}`)
+	sample := filepath.Join("testdata", "sample.mp3")
+	recordings := []Recording{{ID: "b1a9c0e9", Title: "Song", Artists: []string{"Artist"}, Score: 0.97}}
+
+	newExtractor := func(opts AudioFingerprintOptions) *MetaExtractor {
+		opts.Enabled, opts.FpcalcPath = true, fpcalc
+		return NewMetaExtractor(Options{DisableTrid: true, DisableExif: true, Provenance: true, AudioFingerprint: opts})
+	}
+
+	t.Run("Lookup", func(t *testing.T) {
+		var queries []string
+		me := newExtractor(AudioFingerprintOptions{Lookup: fakeLookup{recordings: recordings, queries: &queries}})
+
+		metadata, err := me.Extract(sample)
+		require.NoError(t, err)
+		assert.Equal(t, &AudioFingerprint{Fingerprint: "AQADtEmUJEkS", Duration: 207.44, Recordings: recordings}, metadata.AudioFingerprint)
+		assert.Equal(t, SourceChromaprint, metadata.Provenance["AudioFingerprint"])
+		assert.Equal(t, []string{"AQADtEmUJEkS"}, queries)
+
+		metadata, err = me.Extract(filepath.Join("testdata", "sample.doc"))
+		require.NoError(t, err)
+		assert.Nil(t, metadata.AudioFingerprint)
+		assert.Len(t, queries, 1)
+
+		statuses, err := me.CheckTools()
+		require.NoError(t, err)
+		assert.Equal(t, []ToolStatus{{Name: "fpcalc", Path: fpcalc, Version: "1.5.1"}}, statuses)
+	})
+
+	t.Run("LookupError", func(t *testing.T) {
+		var queries []string
+		lookup := fakeLookup{err: errors.New("rate limited"), queries: &queries}
+
+		_, err := newExtractor(AudioFingerprintOptions{Lookup: lookup}).Extract(sample)
+		assert.ErrorContains(t, err, "error looking up fingerprint: rate limited")
+
+		me := NewMetaExtractor(Options{
+			DisableTrid:      true,
+			DisableExif:      true,
+			BestEffort:       true,
+			AudioFingerprint: AudioFingerprintOptions{Enabled: true, FpcalcPath: fpcalc, Lookup: lookup},
+		})
+		metadata, err := me.Extract(sample)
+		require.NoError(t, err)
+		assert.Equal(t, "AQADtEmUJEkS", metadata.AudioFingerprint.Fingerprint)
+		assert.Nil(t, metadata.AudioFingerprint.Recordings)
+		assert.Len(t, metadata.Warnings, 1)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			DisableTrid:      true,
+			DisableExif:      true,
+			AudioFingerprint: AudioFingerprintOptions{Enabled: true, FpcalcPath: filepath.Join(t.TempDir(), "missing")},
+		})
+
+		_, err := me.Extract(sample)
+		assert.ErrorContains(t, err, "error running fpcalc")
+		assert.ErrorContains(t, me.Check(), "fpcalc (")
+	})
+}
+
+func TestAcoustID(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/lookup", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("client") != "key" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": "error", "error": {"code": 4, "message": "invalid API key"}}`))
+			return
+		}
+
+		assert.Equal(t, "recordings", q.Get("meta"))
+		assert.Equal(t, "207", q.Get("duration"))
+		assert.Equal(t, "AQADtEmUJEkS", q.Get("fingerprint"))
+
+		w.Write([]byte(`{"status": "ok", "results": [
+			{"id": "r1", "score": 0.6, "recordings": [{"id": "m2", "title": "Cover", "artists": [{"name": "Band"}]}]},
+			{"id": "r2", "score": 0.97, "recordings": [
+				{"id": "m1", "title": "Song", "artists": [{"name": "Artist"}, {"name": "Guest"}]},
+				{"id": "m2", "title": "Cover", "artists": [{"name": "Band"}]}
+			]},
+			{"id": "r3", "score": 0.4}
+		]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	recordings, err := AcoustID{APIKey: "key", Client: srv.Client(), BaseURL: srv.URL + "/v2/"}.Lookup(context.Background(), "AQADtEmUJEkS", 207.44)
+	require.NoError(t, err)
+	assert.Equal(t, []Recording{
+		{ID: "m2", Title: "Cover", Artists: []string{"Band"}, Score: 0.97},
+		{ID: "m1", Title: "Song", Artists: []string{"Artist", "Guest"}, Score: 0.97},
+	}, recordings)
+
+	_, err = AcoustID{APIKey: "wrong", BaseURL: srv.URL + "/v2"}.Lookup(context.Background(), "AQADtEmUJEkS", 207.44)
+	assert.EqualError(t, err, "acoustid request failed: invalid API key")
+
+	_, err = AcoustID{APIKey: "key", BaseURL: srv.URL + "/v1"}.Lookup(context.Background(), "AQADtEmUJEkS", 207.44)
+	assert.ErrorContains(t, err, "404")
+}
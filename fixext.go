@@ -0,0 +1,183 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Rename describes a rename performed (or planned, in dry-run mode) by
+// FixExtension.
+type Rename struct {
+	// OldPath is the original path of the file.
+	OldPath string
+
+	// NewPath is the path the file was (or would be) renamed to. It is empty
+	// if the file could not be analyzed.
+	NewPath string
+
+	// Err is the error encountered while analyzing or renaming the file, if any.
+	Err error
+}
+
+// FixExtension renames files whose extension does not match their detected
+// type. If path is a directory, all regular files below it are processed.
+// When a file with the corrected name already exists, a numeric suffix is
+// added (e.g., "photo (1).jpg"). If dryRun is true, no files are renamed,
+// but the returned report lists the renames that would have been performed.
+// Empty files, placeholders of cloud storage and the content of macOS
+// bundles are left as they are.
+func (me *MetaExtractor) FixExtension(path string, dryRun bool) ([]Rename, error) {
+	return me.FixExtensionContext(context.Background(), path, dryRun)
+}
+
+// FixExtensionContext is like FixExtension, but stops when ctx is done or
+// the extractor is closed, returning the renames performed so far and
+// ctx.Err() or ErrClosed.
+func (me *MetaExtractor) FixExtensionContext(ctx context.Context, path string, dryRun bool) ([]Rename, error) {
+	if path == "" {
+		return nil, ErrNoFileSpecified
+	}
+
+	if me.initErr != nil {
+		return nil, me.initErr
+	}
+
+	ctx, release, err := me.lifecycle.bind(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+
+	var files []string
+	if fileInfo.IsDir() {
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if info, err := d.Info(); err == nil && isMacBundle(p, info) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = append(files, path)
+	}
+
+	var renames []Rename
+	reserved := make(map[string]bool)
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return renames, closedErr(ctx, err)
+		}
+
+		rename, ok := me.fixExtension(ctx, file, dryRun, reserved)
+		if ok {
+			renames = append(renames, rename)
+		}
+	}
+
+	return renames, nil
+}
+
+// fixExtension determines the corrected name of a single file and renames it
+// unless dryRun is true. It returns false if the file needs no rename. As in
+// Extract, the content of empty files and placeholders is not examined.
+func (me *MetaExtractor) fixExtension(ctx context.Context, filePath string, dryRun bool, reserved map[string]bool) (Rename, bool) {
+	rename := Rename{OldPath: filePath}
+
+	fileInfo, err := os.Stat(longPath(filePath))
+	if err != nil {
+		rename.Err = err
+		return rename, true
+	}
+	if !fileInfo.Mode().IsRegular() || fileInfo.Size() == 0 || isPlaceholder(filePath, fileInfo) {
+		return rename, false
+	}
+
+	toolPath, cleanup, err := me.sandboxFile(longPath(filePath))
+	if err != nil {
+		rename.Err = err
+		return rename, true
+	}
+	defer cleanup()
+
+	result, err := me.detectTypes(ctx, toolPath, nil)
+	if err != nil {
+		rename.Err = closedErr(ctx, err)
+		return rename, true
+	}
+	fileTypes := result.types
+
+	if len(fileTypes) == 0 {
+		return rename, false
+	}
+
+	ext, raw := me.fileExtension(filepath.Base(filePath))
+	if !me.extMismatch(ext, raw, fileTypes[0]) {
+		return rename, false
+	}
+
+	_, name := me.suggestName(filepath.Base(filePath), fileTypes[0])
+	if name == "" {
+		return rename, false
+	}
+
+	rename.NewPath, err = uniquePath(filepath.Join(filepath.Dir(filePath), name), reserved)
+	if err != nil {
+		rename.Err = err
+		return rename, true
+	}
+	reserved[rename.NewPath] = true
+
+	if !dryRun {
+		rename.Err = os.Rename(filePath, rename.NewPath)
+	}
+
+	return rename, true
+}
+
+// uniquePath returns filePath if no file exists at that location and it has
+// not been reserved, otherwise it appends a numeric suffix to the file name
+// until a free path is found.
+func uniquePath(filePath string, reserved map[string]bool) (string, error) {
+	ext := filepath.Ext(filePath)
+	base := strings.TrimSuffix(filePath, ext)
+
+	candidate := filePath
+	for i := 1; ; i++ {
+		if !reserved[candidate] {
+			_, err := os.Lstat(candidate)
+			if errors.Is(err, fs.ErrNotExist) {
+				return candidate, nil
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
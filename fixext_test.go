@@ -0,0 +1,66 @@
+package metaextractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniquePath(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "photo.jpg"), nil, 0o644))
+
+	reserved := map[string]bool{filepath.Join(dir, "photo (1).jpg"): true}
+
+	p, err := uniquePath(filepath.Join(dir, "photo.jpg"), reserved)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "photo (2).jpg"), p)
+
+	p, err = uniquePath(filepath.Join(dir, "other.jpg"), reserved)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "other.jpg"), p)
+}
+
+func TestFixExtension_Errors(t *testing.T) {
+	extractor := NewMetaExtractor(Options{})
+
+	t.Run("No File Specified", func(t *testing.T) {
+		_, err := extractor.FixExtension("", true)
+		assert.ErrorIs(t, err, ErrNoFileSpecified)
+	})
+
+	t.Run("File Not Found", func(t *testing.T) {
+		_, err := extractor.FixExtension("nonexistent_file", true)
+		assert.ErrorIs(t, err, ErrFileNotFound)
+	})
+}
+
+func TestFixExtension_Skips(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89"
+	root := createTree(t, map[string]string{
+		"image.jpg":                         png,
+		"empty.jpg":                         "",
+		"Tool.app/Contents/Resources/a.jpg": png,
+	})
+
+	extractor := NewMetaExtractor(Options{PureGo: true})
+
+	renames, err := extractor.FixExtension(root, true)
+	require.NoError(t, err)
+	require.Len(t, renames, 1)
+	assert.Equal(t, filepath.Join(root, "image.jpg"), renames[0].OldPath)
+	assert.Equal(t, filepath.Join(root, "image.png"), renames[0].NewPath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = extractor.FixExtensionContext(ctx, root, true)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, extractor.Close())
+	_, err = extractor.FixExtension(root, true)
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,147 @@
+package metaextractor
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/attilabuti/trid"
+)
+
+// BestType is the file type reconciled from the answers of all detectors that
+// ran, including the file type reported by ExifTool.
+type BestType struct {
+	// Extension is the preferred extension of the type (e.g., ".jpg").
+	Extension string `json:"extension,omitempty"`
+
+	// MimeType is the MIME type of the type, as reported by a detector or
+	// looked up by extension.
+	MimeType string `json:"mime_type,omitempty"`
+
+	// Name is the descriptive name of the type.
+	Name string `json:"name,omitempty"`
+
+	// Agreement is the share of detectors (0-1) that agree on the type.
+	Agreement float64 `json:"agreement"`
+
+	// Detectors are the names of the detectors that agree on the type.
+	Detectors []string `json:"detectors,omitempty"`
+
+	// Conflicts are the names of the detectors that reported a different
+	// type. Detectors that identified nothing are not considered.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// typeVote is the most likely type reported by a detector.
+type typeVote struct {
+	detector string
+	fileType trid.FileType
+}
+
+// exifToolVote returns the type reported by ExifTool, if any.
+func exifToolVote(exif ExifMetadata) (typeVote, bool) {
+	ext := strings.ToLower(stringValue(exifValue(exif, "FileTypeExtension")))
+	mimeType := stringValue(exifValue(exif, "MIMEType"))
+	if ext == "" && mimeType == "" {
+		return typeVote{}, false
+	}
+
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	return typeVote{
+		detector: "exiftool",
+		fileType: trid.FileType{
+			Extension:   ext,
+			Probability: 100,
+			Name:        stringValue(exifValue(exif, "FileType")),
+			MimeType:    mimeType,
+		},
+	}, true
+}
+
+// votesAgree reports whether two votes identify the same type: either their
+// MIME types are equal, or they share an extension.
+func votesAgree(a, b trid.FileType) bool {
+	if a.MimeType != "" && b.MimeType != "" && strings.EqualFold(a.MimeType, b.MimeType) {
+		return true
+	}
+
+	for _, x := range typeExtensions(a) {
+		for _, y := range typeExtensions(b) {
+			if x == y {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fuseTypes reconciles the votes into a single type. Votes are grouped by
+// agreement, and the group with the highest total probability wins; ties are
+// broken in favor of the earlier vote. It returns nil if there are no votes.
+func fuseTypes(votes []typeVote) *BestType {
+	if len(votes) == 0 {
+		return nil
+	}
+
+	type group struct {
+		votes  []typeVote
+		weight float64
+	}
+
+	var groups []*group
+	for _, vote := range votes {
+		var found *group
+		for _, g := range groups {
+			if votesAgree(g.votes[0].fileType, vote.fileType) {
+				found = g
+				break
+			}
+		}
+
+		if found == nil {
+			found = &group{}
+			groups = append(groups, found)
+		}
+
+		found.votes = append(found.votes, vote)
+		found.weight += vote.fileType.Probability
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].weight > groups[j].weight
+	})
+
+	winner := groups[0]
+	best := &BestType{
+		Agreement: float64(len(winner.votes)) / float64(len(votes)),
+	}
+
+	for _, vote := range winner.votes {
+		best.Detectors = append(best.Detectors, vote.detector)
+
+		if best.Extension == "" {
+			if exts := typeExtensions(vote.fileType); len(exts) > 0 {
+				best.Extension = exts[0]
+			}
+		}
+
+		if best.MimeType == "" {
+			best.MimeType = typeMimeType(vote.fileType)
+		}
+
+		if best.Name == "" {
+			best.Name = vote.fileType.Name
+		}
+	}
+
+	for _, g := range groups[1:] {
+		for _, vote := range g.votes {
+			best.Conflicts = append(best.Conflicts, vote.detector)
+		}
+	}
+
+	return best
+}
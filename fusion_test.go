@@ -0,0 +1,72 @@
+package metaextractor
+
+import (
+	"testing"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVotesAgree(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a, b     trid.FileType
+		expected bool
+	}{
+		{"Same MIME Type", trid.FileType{MimeType: "image/jpeg"}, trid.FileType{MimeType: "IMAGE/JPEG"}, true},
+		{"Shared Extension", trid.FileType{Extension: ".jpg/.jpeg"}, trid.FileType{Extension: ".jpeg"}, true},
+		{"Different", trid.FileType{Extension: ".pdf", MimeType: "application/pdf"}, trid.FileType{Extension: ".doc", MimeType: "application/msword"}, false},
+		{"Empty", trid.FileType{}, trid.FileType{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, votesAgree(tc.a, tc.b))
+		})
+	}
+}
+
+func TestFuseTypes(t *testing.T) {
+	assert.Nil(t, fuseTypes(nil))
+
+	t.Run("Agreement", func(t *testing.T) {
+		best := fuseTypes([]typeVote{
+			{"trid", trid.FileType{Extension: ".jpg/.jpeg", Probability: 70, Name: "JFIF JPEG Bitmap"}},
+			{"signature", trid.FileType{Extension: ".jpg/.jpeg", Probability: 100, MimeType: "image/jpeg"}},
+			{"exiftool", trid.FileType{Extension: ".jpg", Probability: 100, MimeType: "image/jpeg", Name: "JPEG"}},
+		})
+
+		require.NotNil(t, best)
+		assert.Equal(t, ".jpg", best.Extension)
+		assert.Equal(t, "image/jpeg", best.MimeType)
+		assert.Equal(t, "JFIF JPEG Bitmap", best.Name)
+		assert.Equal(t, 1.0, best.Agreement)
+		assert.Equal(t, []string{"trid", "signature", "exiftool"}, best.Detectors)
+		assert.Empty(t, best.Conflicts)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		best := fuseTypes([]typeVote{
+			{"trid", trid.FileType{Extension: ".doc", Probability: 60}},
+			{"signature", trid.FileType{Extension: ".pdf", Probability: 100, MimeType: "application/pdf"}},
+			{"exiftool", trid.FileType{Extension: ".pdf", Probability: 100, MimeType: "application/pdf"}},
+		})
+
+		require.NotNil(t, best)
+		assert.Equal(t, ".pdf", best.Extension)
+		assert.InDelta(t, 2.0/3.0, best.Agreement, 0.001)
+		assert.Equal(t, []string{"signature", "exiftool"}, best.Detectors)
+		assert.Equal(t, []string{"trid"}, best.Conflicts)
+	})
+}
+
+func TestExifToolVote(t *testing.T) {
+	vote, ok := exifToolVote(ExifMetadata{"FileType": "PDF", "FileTypeExtension": "PDF", "MIMEType": "application/pdf"})
+	require.True(t, ok)
+	assert.Equal(t, "exiftool", vote.detector)
+	assert.Equal(t, trid.FileType{Extension: ".pdf", Probability: 100, Name: "PDF", MimeType: "application/pdf"}, vote.fileType)
+
+	_, ok = exifToolVote(ExifMetadata{"FileName": "file"})
+	assert.False(t, ok)
+}
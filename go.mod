@@ -4,14 +4,17 @@ go 1.22.4
 
 require (
 	github.com/attilabuti/trid v1.0.0
-	github.com/barasher/go-exiftool v1.10.0
 	github.com/djherbis/times v1.6.0
+	github.com/hirochachacha/go-smb2 v1.1.0
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/crypto v0.21.0
+	golang.org/x/sys v0.18.0
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/geoffgarside/ber v1.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/sys v0.0.0-20220615213510-4f61da869c0c // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,70 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// GPSCoordinates is a location in signed decimal degrees, parsed from the
+// forms ExifTool and the native parser report GPS tags in: signed decimal
+// degrees, or degrees, minutes and seconds with a hemisphere reference
+// (e.g., `47 deg 30' 12.30" N`).
+type GPSCoordinates struct {
+	// Latitude and Longitude are negative in the southern and western
+	// hemispheres.
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	// Altitude is the altitude in meters above sea level, negative below
+	// it, or nil if not recorded.
+	Altitude *float64 `json:"altitude,omitempty"`
+
+	// Timestamp is the time of the GPS fix in UTC, or the zero time if it
+	// is not recorded.
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// MarshalJSON encodes the location, omitting a zero timestamp.
+func (g GPSCoordinates) MarshalJSON() ([]byte, error) {
+	type gpsCoordinates GPSCoordinates
+
+	return json.Marshal(struct {
+		gpsCoordinates
+		Timestamp *time.Time `json:"timestamp,omitempty"`
+	}{gpsCoordinates(g), optionalTime(g.Timestamp)})
+}
+
+// parseGPS returns the location recorded in the EXIF metadata, or nil if it
+// has no valid coordinates.
+func parseGPS(exif ExifMetadata, precedence []string) *GPSCoordinates {
+	point, ok := gpsPosition(exif, precedence)
+	if !ok {
+		return nil
+	}
+
+	gps := &GPSCoordinates{Latitude: point.lat, Longitude: point.lon}
+	if alt, ok := gpsAltitude(exif, precedence); ok {
+		gps.Altitude = &alt
+	}
+	gps.Timestamp = gpsTimestamp(exif, precedence)
+
+	return gps
+}
+
+// gpsTimestamp returns the time of the GPS fix, taken from the GPSDateTime
+// composite tag or from GPSDateStamp and GPSTimeStamp, which are in UTC.
+func gpsTimestamp(exif ExifMetadata, precedence []string) time.Time {
+	if t, _, ok := parseExifTime(stringValue(exifValue(exif, "GPSDateTime", precedence...))); ok {
+		return t.UTC()
+	}
+
+	date := strings.TrimSpace(stringValue(exifValue(exif, "GPSDateStamp", precedence...)))
+	clock := strings.TrimSpace(stringValue(exifValue(exif, "GPSTimeStamp", precedence...)))
+	if date == "" || clock == "" {
+		return time.Time{}
+	}
+
+	t, _, _ := parseExifTime(date + " " + clock)
+	return t
+}
@@ -0,0 +1,96 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGPS(t *testing.T) {
+	alt := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name string
+		exif ExifMetadata
+		want *GPSCoordinates
+	}{
+		{
+			"DMS",
+			ExifMetadata{
+				"GPSLatitude":    `47 deg 30' 12.30" N`,
+				"GPSLongitude":   `8 deg 15' 0.00" W`,
+				"GPSAltitude":    "123.4 m",
+				"GPSAltitudeRef": "Below Sea Level",
+				"GPSDateTime":    "2023:01:02 13:04:05Z",
+			},
+			&GPSCoordinates{Latitude: 47.50341666, Longitude: -8.25, Altitude: alt(-123.4), Timestamp: time.Date(2023, 1, 2, 13, 4, 5, 0, time.UTC)},
+		},
+		{
+			"References",
+			ExifMetadata{
+				"EXIF:GPSLatitude":     `33 deg 51' 54.00"`,
+				"EXIF:GPSLatitudeRef":  "South",
+				"EXIF:GPSLongitude":    `151 deg 12' 36.00"`,
+				"EXIF:GPSLongitudeRef": "East",
+				"EXIF:GPSDateStamp":    "2024:05:06",
+				"EXIF:GPSTimeStamp":    "07:08:09.5",
+			},
+			&GPSCoordinates{Latitude: -33.865, Longitude: 151.21, Timestamp: time.Date(2024, 5, 6, 7, 8, 9, 500000000, time.UTC)},
+		},
+		{
+			"Numeric",
+			ExifMetadata{"GPSLatitude": 33.865, "GPSLatitudeRef": "S", "GPSLongitude": 151.21, "GPSLongitudeRef": "E", "GPSAltitude": 12.0, "GPSAltitudeRef": 0.0},
+			&GPSCoordinates{Latitude: -33.865, Longitude: 151.21, Altitude: alt(12)},
+		},
+		{"Position", ExifMetadata{"Composite:GPSPosition": "-33.865, 151.21"}, &GPSCoordinates{Latitude: -33.865, Longitude: 151.21}},
+		{"NoFix", ExifMetadata{"GPSLatitude": 0.0, "GPSLongitude": 0.0}, nil},
+		{"Missing", ExifMetadata{"GPSAltitude": "12 m"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseGPS(tt.exif, nil)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got)
+			assert.InDelta(t, tt.want.Latitude, got.Latitude, 1e-6)
+			assert.InDelta(t, tt.want.Longitude, got.Longitude, 1e-6)
+			if tt.want.Altitude == nil {
+				assert.Nil(t, got.Altitude)
+			} else if assert.NotNil(t, got.Altitude) {
+				assert.InDelta(t, *tt.want.Altitude, *got.Altitude, 1e-9)
+			}
+			assert.True(t, tt.want.Timestamp.Equal(got.Timestamp), got.Timestamp)
+		})
+	}
+}
+
+func TestGPSCoordinates_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(GPSCoordinates{Latitude: 1.5, Longitude: -2})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"latitude":1.5,"longitude":-2}`, string(data))
+
+	data, err = json.Marshal(GPSCoordinates{Latitude: 1.5, Longitude: -2, Timestamp: time.Date(2023, 1, 2, 13, 4, 5, 0, time.UTC)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"latitude":1.5,"longitude":-2,"timestamp":"2023-01-02T13:04:05Z"}`, string(data))
+}
+
+func TestRedactionPolicy_GPS(t *testing.T) {
+	metadata := Metadata{Exif: ExifMetadata{"GPSLatitude": `47 deg 30' 12.30" N`, "GPSLongitude": `8 deg 15' 0.00" W`}}
+	metadata.GPS = parseGPS(metadata.Exif, nil)
+
+	redacted := DefaultRedactionPolicy.Apply(metadata)
+	require.NotNil(t, redacted.GPS)
+	assert.Equal(t, 47.5, redacted.GPS.Latitude)
+	assert.Equal(t, -8.25, redacted.GPS.Longitude)
+	assert.InDelta(t, 47.5034, metadata.GPS.Latitude, 1e-4)
+
+	dropped := RedactionPolicy{Drop: []string{"GPS*"}}.Apply(metadata)
+	assert.Nil(t, dropped.GPS)
+}
@@ -0,0 +1,208 @@
+package metaextractor
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// hashFuncs are the supported hash algorithms, keyed by name.
+var hashFuncs = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+
+	// Non-cryptographic and modern hashes: CRC32 (IEEE) for legacy
+	// manifests, xxHash64 for deduplication keys and BLAKE3 for speed.
+	"crc32":    func() hash.Hash { return crc32.NewIEEE() },
+	"xxhash64": newXXHash64,
+	"blake3":   newBLAKE3,
+}
+
+// fuzzyHashFuncs are the supported fuzzy hash algorithms, keyed by name.
+// Their digests are text rather than bytes, they have no chunked variant,
+// and they are not used to verify manifests.
+var fuzzyHashFuncs = map[string]func() hash.Hash{
+	"ssdeep": newSSDeep,
+}
+
+const (
+	// treeSuffix selects the chunked variant of a hash algorithm
+	// (e.g., "sha256-tree").
+	treeSuffix = "-tree"
+
+	// DefaultHashChunkSize is the default chunk size of chunked hashes.
+	DefaultHashChunkSize = 4 << 20
+)
+
+// checkHashes reports an error if any of the hash algorithms is unknown.
+func checkHashes(names []string) error {
+	for _, name := range names {
+		if _, ok := fuzzyHashFuncs[name]; ok {
+			continue
+		}
+		if _, ok := hashFuncs[strings.TrimSuffix(name, treeSuffix)]; !ok {
+			return fmt.Errorf("unknown hash algorithm: %s", name)
+		}
+	}
+
+	return nil
+}
+
+// newHash returns a new hash of the given algorithm. Chunked algorithms hash
+// chunks of chunkSize bytes using up to workers goroutines.
+func newHash(name string, chunkSize, workers int) hash.Hash {
+	if newFuzzy, ok := fuzzyHashFuncs[name]; ok {
+		return newFuzzy()
+	}
+
+	if base, ok := strings.CutSuffix(name, treeSuffix); ok {
+		return newTreeHash(hashFuncs[base], chunkSize, workers)
+	}
+
+	return hashFuncs[name]()
+}
+
+// hashDigest returns the digest of a hash as reported in Metadata.Hashes:
+// the text of fuzzy hashes, and hex-encoded bytes otherwise.
+func hashDigest(name string, h hash.Hash) string {
+	if _, ok := fuzzyHashFuncs[name]; ok {
+		return string(h.Sum(nil))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// treeHash is a chunked hash that can be computed in parallel. The content is
+// split into chunks of a fixed size, which are hashed independently; the
+// digest is the hash of the concatenated chunk digests. The digest therefore
+// depends on the chunk size, but not on the number of workers.
+type treeHash struct {
+	newHash   func() hash.Hash
+	chunkSize int
+
+	buf  []byte
+	free chan []byte
+	sem  chan struct{}
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	digests [][]byte
+}
+
+// newTreeHash returns a chunked hash based on the given hash function.
+func newTreeHash(newHash func() hash.Hash, chunkSize, workers int) *treeHash {
+	if chunkSize <= 0 {
+		chunkSize = DefaultHashChunkSize
+	}
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	return &treeHash{
+		newHash:   newHash,
+		chunkSize: chunkSize,
+		free:      make(chan []byte, workers+1),
+		sem:       make(chan struct{}, workers),
+	}
+}
+
+// Write buffers the content and hands complete chunks to the workers. The
+// number of chunks in flight is bounded by the number of workers.
+func (t *treeHash) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		if t.buf == nil {
+			select {
+			case t.buf = <-t.free:
+			default:
+				t.buf = make([]byte, 0, t.chunkSize)
+			}
+		}
+
+		c := min(t.chunkSize-len(t.buf), len(p))
+		t.buf = append(t.buf, p[:c]...)
+		p = p[c:]
+
+		if len(t.buf) == t.chunkSize {
+			t.flush()
+		}
+	}
+
+	return n, nil
+}
+
+// flush hashes the buffered chunk in a worker goroutine.
+func (t *treeHash) flush() {
+	chunk := t.buf
+	t.buf = nil
+
+	t.mu.Lock()
+	i := len(t.digests)
+	t.digests = append(t.digests, nil)
+	t.mu.Unlock()
+
+	t.sem <- struct{}{}
+	t.wg.Add(1)
+
+	go func() {
+		defer t.wg.Done()
+
+		h := t.newHash()
+		h.Write(chunk)
+		digest := h.Sum(nil)
+
+		t.mu.Lock()
+		t.digests[i] = digest
+		t.mu.Unlock()
+
+		select {
+		case t.free <- chunk[:0]:
+		default:
+		}
+
+		<-t.sem
+	}()
+}
+
+// Sum hashes the remaining content, waits for the workers and appends the
+// combined digest to b.
+func (t *treeHash) Sum(b []byte) []byte {
+	if len(t.buf) > 0 {
+		t.flush()
+	}
+	t.wg.Wait()
+
+	h := t.newHash()
+	for _, digest := range t.digests {
+		h.Write(digest)
+	}
+
+	return h.Sum(b)
+}
+
+// Reset discards all content.
+func (t *treeHash) Reset() {
+	t.wg.Wait()
+	t.buf = nil
+	t.digests = nil
+}
+
+func (t *treeHash) Size() int {
+	return t.newHash().Size()
+}
+
+func (t *treeHash) BlockSize() int {
+	return t.newHash().BlockSize()
+}
@@ -0,0 +1,101 @@
+package metaextractor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o644))
+
+	scan, err := scanFile(path, scanOptions{hashes: []string{"md5", "sha1", "sha256", "sha512"}})
+	require.NoError(t, err)
+	hashes := scan.hashes
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", hashes["md5"])
+	assert.Equal(t, "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d", hashes["sha1"])
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", hashes["sha256"])
+	assert.Len(t, hashes["sha512"], 128)
+
+	scan, err = scanFile(path, scanOptions{hashes: []string{"crc32", "xxhash64", "blake3", "blake3-tree"}})
+	require.NoError(t, err)
+	hashes = scan.hashes
+	assert.Equal(t, "3610a686", hashes["crc32"])
+	assert.Equal(t, "26c7827d889f6da3", hashes["xxhash64"])
+	assert.Equal(t, "ea8f163db38682925e4491c5e58d4bb3506ef8c14eb78a86e908c5624a67200f", hashes["blake3"])
+	assert.Len(t, hashes["blake3-tree"], 64)
+
+	scan, err = scanFile(path, scanOptions{hashes: []string{"ssdeep", "sha256"}})
+	require.NoError(t, err)
+	assert.Equal(t, "3:iKn:p", scan.hashes["ssdeep"], "fuzzy hashes are reported as text")
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", scan.hashes["sha256"])
+}
+
+func TestTreeHash(t *testing.T) {
+	content := make([]byte, 10_000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+
+	// The expected digest combines the digests of chunks of 4096 bytes.
+	var combined []byte
+	for off := 0; off < len(content); off += 4096 {
+		sum := sha256.Sum256(content[off:min(off+4096, len(content))])
+		combined = append(combined, sum[:]...)
+	}
+	expected := sha256.Sum256(combined)
+
+	for _, workers := range []int{1, 2, 8} {
+		t.Run(fmt.Sprintf("%d Workers", workers), func(t *testing.T) {
+			h := newHash("sha256-tree", 4096, workers)
+
+			// Uneven writes cross the chunk boundaries.
+			for off := 0; off < len(content); off += 3000 {
+				_, err := h.Write(content[off:min(off+3000, len(content))])
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, expected[:], h.Sum(nil))
+			assert.Equal(t, expected[:], h.Sum(nil))
+			assert.Equal(t, sha256.Size, h.Size())
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		empty := sha256.Sum256(nil)
+		assert.Equal(t, empty[:], newHash("sha256-tree", 4096, 2).Sum(nil))
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "file.bin")
+		require.NoError(t, os.WriteFile(path, content, 0o644))
+
+		scan, err := scanFile(path, scanOptions{
+			hashes:      []string{"sha256-tree"},
+			bufferSize:  1000,
+			chunkSize:   4096,
+			hashWorkers: 4,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(expected[:]), scan.hashes["sha256-tree"])
+	})
+}
+
+func TestCheckHashes(t *testing.T) {
+	assert.NoError(t, checkHashes(nil))
+	assert.NoError(t, checkHashes([]string{"md5", "sha256", "sha256-tree", "ssdeep"}))
+	assert.Error(t, checkHashes([]string{"crc64-tree"}))
+	assert.Error(t, checkHashes([]string{"ssdeep-tree"}))
+	assert.ErrorContains(t, checkHashes([]string{"md5", "crc64"}), "crc64")
+
+	me := NewMetaExtractor(Options{Hashes: []string{"crc64"}})
+	_, err := me.Extract(filepath.Join("testdata", "sample.mp3"))
+	assert.ErrorContains(t, err, "unknown hash algorithm")
+}
@@ -0,0 +1,129 @@
+package metaextractor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultIgnoreFile is the name of the ignore file honored during directory
+// walks when WalkOptions.IgnoreFile is empty.
+const DefaultIgnoreFile = ".metaignore"
+
+// ignoreRule is a single pattern of an ignore file.
+type ignoreRule struct {
+	base     string   // Slash-separated directory of the ignore file, relative to the walk root.
+	segments []string // Pattern split into path segments.
+	negate   bool     // Pattern starts with "!".
+	dirOnly  bool     // Pattern ends with "/".
+	anchored bool     // Pattern contains a slash and is matched relative to base.
+}
+
+// ignoreMatcher evaluates the rules of all ignore files found during a walk.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// load reads the ignore file with the given name in dir and adds its rules.
+// base is the slash-separated path of dir relative to the walk root. A missing
+// ignore file is not an error.
+func (m *ignoreMatcher) load(dir, base, name string) error {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	rules, err := parseIgnoreRules(f, base)
+	if err != nil {
+		return err
+	}
+
+	m.rules = append(m.rules, rules...)
+
+	return nil
+}
+
+// ignored reports whether the slash-separated path, relative to the walk root,
+// is ignored. As in gitignore, the last matching rule wins.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.matches(rel, isDir) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// parseIgnoreRules parses gitignore-style patterns.
+func parseIgnoreRules(r io.Reader, base string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " \t")
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		rule.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		rule.segments = strings.Split(line, "/")
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// matches reports whether the rule applies to the given path.
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+
+	return matchGlob(r.segments, strings.Split(rel, "/"))
+}
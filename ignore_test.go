@@ -0,0 +1,78 @@
+package metaextractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	rules, err := parseIgnoreRules(strings.NewReader(strings.Join([]string{
+		"# comment",
+		"",
+		"node_modules/",
+		"*.log",
+		"!important.log",
+		"/build",
+		"docs/**/*.tmp",
+		`\#notes`,
+	}, "\n")), "")
+	require.NoError(t, err)
+
+	nested, err := parseIgnoreRules(strings.NewReader("cache\n"), "sub")
+	require.NoError(t, err)
+
+	m := ignoreMatcher{rules: append(rules, nested...)}
+
+	testCases := []struct {
+		rel      string
+		isDir    bool
+		expected bool
+	}{
+		{"node_modules", true, true},
+		{"a/node_modules", true, true},
+		{"node_modules", false, false},
+		{"debug.log", false, true},
+		{"a/b/debug.log", false, true},
+		{"important.log", false, false},
+		{"build", true, true},
+		{"a/build", true, false},
+		{"docs/a/b/x.tmp", false, true},
+		{"x.tmp", false, false},
+		{"#notes", false, true},
+		{"sub/cache", true, true},
+		{"other/cache", true, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.rel, func(t *testing.T) {
+			assert.Equal(t, tc.expected, m.ignored(tc.rel, tc.isDir))
+		})
+	}
+}
+
+func TestExtractDir_IgnoreFile(t *testing.T) {
+	root := createTree(t, map[string]string{
+		".metaignore":          "node_modules/\n*.log\n",
+		"a.jpg":                "image",
+		"debug.log":            "log",
+		"node_modules/b.js":    "js",
+		"sub/.metaignore":      "*.tmp\n!keep.log\n",
+		"sub/c.tmp":            "tmp",
+		"sub/keep.log":         "log",
+		"other/c.tmp":          "tmp",
+		"custom/.customignore": "*",
+	})
+
+	extractor := NewMetaExtractor(Options{})
+
+	results, err := extractor.ExtractDir(root, WalkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg", "custom/.customignore", "other/c.tmp", "sub/keep.log"}, resultPaths(t, root, results))
+
+	results, err = extractor.ExtractDir(root, WalkOptions{NoIgnoreFile: true, Include: []string{"*.log"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"debug.log", "sub/keep.log"}, resultPaths(t, root, results))
+}
@@ -0,0 +1,284 @@
+package metaextractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register the GIF decoder
+	_ "image/jpeg" // register the JPEG decoder
+	_ "image/png"  // register the PNG decoder
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Checks reporting anomalies.
+const (
+	// AnomalyTruncated reports an image whose data ends prematurely.
+	AnomalyTruncated = "truncated"
+
+	// AnomalyUndecodable reports an image whose header cannot be decoded.
+	AnomalyUndecodable = "undecodable"
+
+	// AnomalyCorrupt reports an image whose structure is invalid past its
+	// header, e.g. a JPEG segment with an invalid length.
+	AnomalyCorrupt = "corrupt"
+
+	// AnomalyDimensions reports EXIF dimensions differing from the decoded
+	// dimensions, e.g. after resizing or cropping without updating EXIF.
+	AnomalyDimensions = "dimensions"
+
+	// AnomalyOrientation reports an image whose pixels were rotated without
+	// resetting the EXIF Orientation tag, so viewers rotate it twice.
+	AnomalyOrientation = "orientation"
+)
+
+// Anomaly is a discrepancy found by a sanity check of the file content.
+type Anomaly struct {
+	// Check is the name of the failed check (e.g., AnomalyTruncated).
+	Check string `json:"check"`
+
+	// Message describes the discrepancy.
+	Message string `json:"message,omitempty"`
+}
+
+// JPEG markers.
+const (
+	jpegSOS = 0xda
+	jpegEOI = 0xd9
+)
+
+// errInvalidJPEGSegment is returned by jpegComplete for a segment whose
+// length is shorter than the length field itself.
+var errInvalidJPEGSegment = errors.New("invalid JPEG segment length")
+
+// Image signatures recognized by checkImage.
+var (
+	jpegMagic = []byte{0xff, 0xd8, 0xff}
+	pngMagic  = []byte("\x89PNG\r\n\x1a\n")
+	gifMagic  = []byte("GIF8")
+)
+
+// checkImage cross-checks a JPEG, PNG or GIF image against its EXIF
+// metadata: the image data must be complete, and the dimensions declared in
+// EXIF must match the decoded ones. Other files have no anomalies.
+func checkImage(filePath string, exif ExifMetadata, precedence []string) ([]Anomaly, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, len(pngMagic))
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	format := ""
+	switch {
+	case bytes.HasPrefix(head, jpegMagic):
+		format = "jpeg"
+	case bytes.HasPrefix(head, pngMagic):
+		format = "png"
+	case bytes.HasPrefix(head, gifMagic):
+		format = "gif"
+	default:
+		return nil, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var anomalies []Anomaly
+
+	config, _, err := image.DecodeConfig(bufio.NewReader(f))
+	if err != nil {
+		return []Anomaly{{Check: AnomalyUndecodable, Message: fmt.Sprintf("%s header cannot be decoded: %v", format, err)}}, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var complete bool
+	switch format {
+	case "jpeg":
+		complete, err = jpegComplete(bufio.NewReader(f))
+	case "png":
+		complete, err = pngComplete(f)
+	default:
+		complete = true
+	}
+	if errors.Is(err, errInvalidJPEGSegment) {
+		return append([]Anomaly{{Check: AnomalyCorrupt, Message: format + " image data is corrupt: " + err.Error()}},
+			checkDimensions(config.Width, config.Height, exif, precedence)...), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		anomalies = append(anomalies, Anomaly{Check: AnomalyTruncated, Message: format + " image data is truncated"})
+	}
+
+	return append(anomalies, checkDimensions(config.Width, config.Height, exif, precedence)...), nil
+}
+
+// checkDimensions compares the decoded dimensions with the EXIF dimensions
+// and orientation.
+func checkDimensions(width, height int, exif ExifMetadata, precedence []string) []Anomaly {
+	w, ok1 := exifInt(exif, "ExifImageWidth", precedence...)
+	h, ok2 := exifInt(exif, "ExifImageHeight", precedence...)
+	exifWidth, exifHeight := int(w), int(h)
+	if !ok1 || !ok2 || (exifWidth == width && exifHeight == height) {
+		return nil
+	}
+
+	if exifWidth == height && exifHeight == width {
+		if orientation := exifValue(exif, "Orientation", precedence...); rotates90(orientation) {
+			return []Anomaly{{
+				Check: AnomalyOrientation,
+				Message: fmt.Sprintf("image is %dx%d, rotated from the EXIF dimensions %dx%d, but Orientation is still %v",
+					width, height, exifWidth, exifHeight, orientation),
+			}}
+		}
+	}
+
+	return []Anomaly{{
+		Check:   AnomalyDimensions,
+		Message: fmt.Sprintf("EXIF declares %dx%d, image is %dx%d", exifWidth, exifHeight, width, height),
+	}}
+}
+
+// rotates90 reports whether an EXIF Orientation value (numeric, or as
+// printed by ExifTool, e.g. "Rotate 90 CW") rotates the image by 90 or 270
+// degrees.
+func rotates90(orientation interface{}) bool {
+	switch v := orientation.(type) {
+	case float64:
+		return v >= 5 && v <= 8
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n >= 5 && n <= 8
+		}
+		return strings.Contains(v, "90") || strings.Contains(v, "270")
+	}
+	return false
+}
+
+// jpegComplete reports whether a JPEG stream has an end-of-image marker
+// after its first scan. Within entropy-coded data, 0xFF bytes are followed by
+// 0x00 or a restart marker, so the first 0xFF 0xD9 after the start of scan
+// marks the end of the image. Data after it (e.g., an embedded video) is
+// ignored. A segment with a length below 2 yields errInvalidJPEGSegment.
+func jpegComplete(r *bufio.Reader) (bool, error) {
+	// Skip the segments up to the first start of scan.
+	if _, err := r.Discard(2); err != nil {
+		return false, nil
+	}
+
+	for {
+		marker, err := nextJPEGMarker(r)
+		if err != nil {
+			return false, ignoreEOF(err)
+		}
+
+		switch {
+		case marker == jpegEOI:
+			return true, nil
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// Markers without a segment.
+			continue
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return false, ignoreEOF(err)
+		}
+		n := int(binary.BigEndian.Uint16(length[:]))
+		if n < 2 {
+			return false, fmt.Errorf("%w: marker 0x%02x has length %d", errInvalidJPEGSegment, marker, n)
+		}
+		if _, err := r.Discard(n - 2); err != nil {
+			return false, ignoreEOF(err)
+		}
+
+		if marker == jpegSOS {
+			break
+		}
+	}
+
+	for {
+		marker, err := nextJPEGMarker(r)
+		if err != nil {
+			return false, ignoreEOF(err)
+		}
+		if marker == jpegEOI {
+			return true, nil
+		}
+	}
+}
+
+// nextJPEGMarker returns the next marker of a JPEG stream, skipping stuffed
+// 0xFF 0x00 sequences and fill bytes.
+func nextJPEGMarker(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != 0xff {
+			continue
+		}
+
+		for b == 0xff {
+			if b, err = r.ReadByte(); err != nil {
+				return 0, err
+			}
+		}
+		if b != 0x00 {
+			return b, nil
+		}
+	}
+}
+
+// pngComplete reports whether a PNG file has an IEND chunk.
+func pngComplete(r io.ReadSeeker) (bool, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	var header [8]byte
+	for offset := int64(len(pngMagic)); offset+int64(len(header)) <= size; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return false, err
+		}
+		if string(header[4:]) == "IEND" {
+			return true, nil
+		}
+
+		// The chunk header is followed by the data and a CRC.
+		offset += int64(len(header)) + int64(binary.BigEndian.Uint32(header[:4])) + 4
+	}
+
+	return false, nil
+}
+
+// ignoreEOF returns nil for end-of-file errors, which indicate truncated
+// data rather than a read failure.
+func ignoreEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	return err
+}
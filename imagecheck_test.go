@@ -0,0 +1,139 @@
+package metaextractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testImage returns a noisy 40x30 image, so that the encoded data is not
+// trivially small.
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 37), G: uint8(y * 91), B: uint8(x * y), A: 255})
+		}
+	}
+	return img
+}
+
+// encodeImage encodes the test image in the given format.
+func encodeImage(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		require.NoError(t, jpeg.Encode(&buf, testImage(), nil))
+	case "png":
+		require.NoError(t, png.Encode(&buf, testImage()))
+	case "gif":
+		require.NoError(t, gif.Encode(&buf, testImage(), nil))
+	}
+	return buf.Bytes()
+}
+
+// corruptJPEGSegment returns the JPEG image with a JFIF header, so that its
+// configuration is decoded up to the frame header only, and a comment segment
+// of length 0 after the frame header.
+func corruptJPEGSegment(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	jfif := []byte("\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	for i := 2; i+4 <= len(data); {
+		marker := data[i+1]
+		i += 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xc0 {
+			corrupt := append(append([]byte(nil), data[:2]...), jfif...)
+			corrupt = append(append(corrupt, data[2:i]...), 0xff, 0xfe, 0, 0)
+			return append(corrupt, data[i:]...)
+		}
+	}
+
+	t.Fatal("no frame header")
+	return nil
+}
+
+func TestCheckImage(t *testing.T) {
+	dir := t.TempDir()
+
+	jpegData := encodeImage(t, "jpeg")
+	pngData := encodeImage(t, "png")
+
+	tests := []struct {
+		name   string
+		data   []byte
+		exif   ExifMetadata
+		checks []string
+	}{
+		{"jpeg", jpegData, nil, nil},
+		{"png", pngData, nil, nil},
+		{"gif", encodeImage(t, "gif"), nil, nil},
+		{"jpeg with trailer", append(append([]byte(nil), jpegData...), "trailing video"...), nil, nil},
+		{"truncated jpeg", jpegData[:len(jpegData)-100], nil, []string{AnomalyTruncated}},
+		{"truncated png", pngData[:len(pngData)-12], nil, []string{AnomalyTruncated}},
+		{"undecodable png", pngData[:20], nil, []string{AnomalyUndecodable}},
+		{"corrupt jpeg", corruptJPEGSegment(t, jpegData), nil, []string{AnomalyCorrupt}},
+		{"matching exif", jpegData, ExifMetadata{"ExifImageWidth": float64(40), "ExifImageHeight": float64(30)}, nil},
+		{"resized", jpegData, ExifMetadata{"ExifImageWidth": float64(400), "ExifImageHeight": float64(300)}, []string{AnomalyDimensions}},
+		{"text file", []byte("not an image"), ExifMetadata{"ExifImageWidth": float64(400)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "image")
+			require.NoError(t, os.WriteFile(path, tt.data, 0o644))
+
+			anomalies, err := checkImage(path, tt.exif, nil)
+			require.NoError(t, err)
+
+			var checks []string
+			for _, a := range anomalies {
+				assert.NotEmpty(t, a.Message)
+				checks = append(checks, a.Check)
+			}
+			assert.Equal(t, tt.checks, checks)
+		})
+	}
+
+	_, err := checkImage(filepath.Join(dir, "missing"), nil, nil)
+	assert.Error(t, err)
+}
+
+func TestCheckDimensions(t *testing.T) {
+	tests := []struct {
+		name  string
+		exif  ExifMetadata
+		check string
+	}{
+		{"no exif", nil, ""},
+		{"matching", ExifMetadata{"ExifImageWidth": float64(40), "ExifImageHeight": float64(30)}, ""},
+		{"string values", ExifMetadata{"ExifImageWidth": "40", "ExifImageHeight": "30"}, ""},
+		{"cropped", ExifMetadata{"ExifImageWidth": float64(80), "ExifImageHeight": float64(30)}, AnomalyDimensions},
+		{"rotated, orientation reset", ExifMetadata{"ExifImageWidth": float64(30), "ExifImageHeight": float64(40), "Orientation": float64(1)}, AnomalyDimensions},
+		{"rotated, orientation kept", ExifMetadata{"ExifImageWidth": float64(30), "ExifImageHeight": float64(40), "Orientation": float64(6)}, AnomalyOrientation},
+		{"rotated, printed orientation", ExifMetadata{"ExifImageWidth": float64(30), "ExifImageHeight": float64(40), "Orientation": "Rotate 90 CW"}, AnomalyOrientation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anomalies := checkDimensions(40, 30, tt.exif, nil)
+			if tt.check == "" {
+				assert.Empty(t, anomalies)
+				return
+			}
+
+			require.Len(t, anomalies, 1)
+			assert.Equal(t, tt.check, anomalies[0].Check)
+		})
+	}
+}
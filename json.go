@@ -0,0 +1,148 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/attilabuti/trid"
+)
+
+// fileTypeJSON is the JSON encoding of a detected type in Metadata.Types.
+type fileTypeJSON struct {
+	Extension   string  `json:"extension,omitempty"`
+	Probability float64 `json:"probability"`
+	Name        string  `json:"name,omitempty"`
+	MimeType    string  `json:"mime_type,omitempty"`
+	RelatedURL  string  `json:"related_url,omitempty"`
+	Remarks     string  `json:"remarks,omitempty"`
+	Definition  string  `json:"definition,omitempty"`
+}
+
+// MarshalJSON encodes the metadata with a stable schema: keys are the
+// snake_case names of the fields given by their json tags (e.g.,
+// "mime_type", "best_created_at"), and the detected types are encoded the
+// same way. Empty fields and zero times are omitted, and times are encoded
+// in RFC 3339 format with nanoseconds. The keys of Exif, Extra,
+// IndexProperties and Provenance are kept as is.
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	// metadata has the fields of Metadata without its methods.
+	type metadata Metadata
+
+	var types []fileTypeJSON
+	for _, t := range m.Types {
+		types = append(types, fileTypeJSON(t))
+	}
+
+	var fileTime *FileTime
+	if m.Time != (FileTime{}) {
+		fileTime = &m.Time
+	}
+
+	return json.Marshal(struct {
+		metadata
+		Time           *FileTime      `json:"time,omitempty"`
+		Types          []fileTypeJSON `json:"types,omitempty"`
+		ContentCreated *time.Time     `json:"content_created,omitempty"`
+	}{metadata(m), fileTime, types, optionalTime(m.ContentCreated)})
+}
+
+// UnmarshalJSON decodes metadata encoded by MarshalJSON.
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	type metadata Metadata
+
+	aux := struct {
+		*metadata
+		Types []fileTypeJSON `json:"types"`
+	}{metadata: (*metadata)(m)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.Types = nil
+	for _, t := range aux.Types {
+		m.Types = append(m.Types, trid.FileType(t))
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the result with the keys of its json tags, the
+// metadata encoded by Metadata.MarshalJSON and the message of Err as "error".
+func (r Result) MarshalJSON() ([]byte, error) {
+	// result has the fields of Result without its methods.
+	type result Result
+
+	var errMsg string
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+
+	return json.Marshal(struct {
+		result
+		Err string `json:"error,omitempty"`
+	}{result(r), errMsg})
+}
+
+// UnmarshalJSON decodes a result encoded by MarshalJSON. Err only keeps the
+// message of the error.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	type result Result
+
+	aux := struct {
+		*result
+		Err string `json:"error"`
+	}{result: (*result)(r)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	r.Err = nil
+	if aux.Err != "" {
+		r.Err = errors.New(aux.Err)
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the file times, omitting the zero times.
+func (ft FileTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ModTime    *time.Time `json:"mod_time,omitempty"`
+		AccessTime *time.Time `json:"access_time,omitempty"`
+		ChangeTime *time.Time `json:"change_time,omitempty"`
+		BirthTime  *time.Time `json:"birth_time,omitempty"`
+	}{optionalTime(ft.ModTime), optionalTime(ft.AccessTime), optionalTime(ft.ChangeTime), optionalTime(ft.BirthTime)})
+}
+
+// MarshalJSON encodes the entry, omitting the zero times.
+func (e ZipEntry) MarshalJSON() ([]byte, error) {
+	type zipEntry ZipEntry
+
+	return json.Marshal(struct {
+		zipEntry
+		Modified   *time.Time `json:"modified,omitempty"`
+		AccessTime *time.Time `json:"access_time,omitempty"`
+		CreateTime *time.Time `json:"create_time,omitempty"`
+	}{zipEntry(e), optionalTime(e.Modified), optionalTime(e.AccessTime), optionalTime(e.CreateTime)})
+}
+
+// MarshalJSON encodes the file, omitting a zero modification time.
+func (f CloudFile) MarshalJSON() ([]byte, error) {
+	type cloudFile CloudFile
+
+	return json.Marshal(struct {
+		cloudFile
+		ModTime *time.Time `json:"mod_time,omitempty"`
+	}{cloudFile(f), optionalTime(f.ModTime)})
+}
+
+// optionalTime returns a pointer to t, or nil if t is the zero time.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
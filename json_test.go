@@ -0,0 +1,89 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata_JSON(t *testing.T) {
+	modTime := time.Date(2024, 8, 3, 14, 2, 11, 520000000, time.UTC)
+	metadata := Metadata{
+		Name:           "photo.jpg",
+		Extension:      ".jpg",
+		Size:           2048,
+		Kind:           KindImage,
+		MimeType:       "image/jpeg",
+		Time:           FileTime{ModTime: modTime},
+		Hashes:         map[string]string{"sha256": "9f2c"},
+		Types:          []trid.FileType{{Extension: ".jpg", Probability: 100, Name: "JPEG", MimeType: "image/jpeg"}},
+		Exif:           ExifMetadata{"Model": "EOS R6"},
+		ExifTimes:      map[string]time.Time{"DateTimeOriginal": modTime},
+		ContentCreated: modTime,
+		Zip:            &ZipArchive{EntryCount: 1, Entries: []ZipEntry{{Name: "a.txt", Modified: modTime}}},
+		Cloud:          &CloudFile{Provider: "gdrive", Path: "photo.jpg", Size: 2048},
+		Provenance: map[string]string{
+			"Time": SourceFileSystem,
+		},
+	}
+
+	data, err := json.Marshal(metadata)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "photo.jpg",
+		"extension": ".jpg",
+		"size": 2048,
+		"kind": "image",
+		"mime_type": "image/jpeg",
+		"time": {"mod_time": "2024-08-03T14:02:11.52Z"},
+		"cloud": {"provider": "gdrive", "path": "photo.jpg", "size": 2048},
+		"hashes": {"sha256": "9f2c"},
+		"zip": {"entry_count": 1, "entries": [{"name": "a.txt", "modified": "2024-08-03T14:02:11.52Z"}]},
+		"types": [{"extension": ".jpg", "probability": 100, "name": "JPEG", "mime_type": "image/jpeg"}],
+		"exif": {"Model": "EOS R6"},
+		"exif_times": {"DateTimeOriginal": "2024-08-03T14:02:11.52Z"},
+		"content_created": "2024-08-03T14:02:11.52Z",
+		"provenance": {"Time": "filesystem"}
+	}`, string(data))
+
+	var decoded Metadata
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, metadata, decoded)
+
+	data, err = json.Marshal(Metadata{Name: "empty"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "empty", "size": 0}`, string(data))
+}
+
+func TestResult_JSON(t *testing.T) {
+	result := Result{
+		Path:        "backup/photo.jpg",
+		Metadata:    Metadata{Name: "photo.jpg", Size: 2048},
+		Err:         errors.New("permission denied"),
+		Depth:       1,
+		DuplicateOf: "photo.jpg",
+		RunID:       "run",
+	}
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"path": "backup/photo.jpg",
+		"metadata": {"name": "photo.jpg", "size": 2048},
+		"error": "permission denied",
+		"depth": 1,
+		"duplicate_of": "photo.jpg",
+		"run_id": "run"
+	}`, string(data))
+
+	var decoded Result
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.EqualError(t, decoded.Err, "permission denied")
+	decoded.Err = result.Err
+	assert.Equal(t, result, decoded)
+}
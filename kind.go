@@ -0,0 +1,24 @@
+package metaextractor
+
+import "errors"
+
+// Kind is a coarse category of a file.
+type Kind string
+
+const (
+	// KindEmpty is the kind of zero-byte regular files. Type detection and
+	// EXIF extraction are skipped for them, so their metadata has no Types.
+	KindEmpty Kind = "empty"
+
+	// KindBundle is the kind of macOS bundles (e.g., ".app" directories),
+	// which are extracted as a single item.
+	KindBundle Kind = "bundle"
+
+	// KindPlaceholder is the kind of cloud placeholder files whose content
+	// is not stored locally. Only the stages not reading the content (file
+	// times, change tracking and index properties) are run for them.
+	KindPlaceholder Kind = "placeholder"
+)
+
+// ErrEmptyFile is returned for zero-byte files if Options.FailEmpty is set.
+var ErrEmptyFile = errors.New("empty file")
@@ -0,0 +1,83 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by extractions started after Close, and by those
+// interrupted by it.
+var ErrClosed = errors.New("extractor closed")
+
+// lifecycle tracks whether an extractor, and the profiles sharing its
+// resources, has been closed.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	once   sync.Once
+	err    error
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// bind returns a context that is also canceled when the extractor is
+// closed, along with a function releasing it. It returns ErrClosed if the
+// extractor is already closed.
+func (l *lifecycle) bind(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if l == nil {
+		return ctx, func() {}, nil
+	}
+
+	if l.ctx.Err() != nil {
+		return ctx, func() {}, ErrClosed
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(l.ctx, func() {
+		cancel(ErrClosed)
+	})
+
+	return ctx, func() {
+		stop()
+		cancel(nil)
+	}, nil
+}
+
+// closed reports whether the extractor is closed.
+func (l *lifecycle) closed() bool {
+	return l != nil && l.ctx.Err() != nil
+}
+
+// closedErr returns ErrClosed in place of the cancellation error of an
+// extraction interrupted by Close.
+func closedErr(ctx context.Context, err error) error {
+	if errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
+
+// Close releases the resources of the extractor: the ExifTool processes kept
+// running between extractions are stopped and the parsed checksum manifests
+// are dropped. Extractions in progress are canceled as with ExtractContext,
+// killing the external tools they run, and fail with ErrClosed, as do
+// extractions started afterwards. Profiles (see WithProfile) share the
+// resources of the extractor, so closing either closes both. Close is
+// idempotent.
+func (me *MetaExtractor) Close() error {
+	me.lifecycle.once.Do(func() {
+		me.lifecycle.cancel(ErrClosed)
+
+		if me.manifests != nil {
+			me.manifests.reset()
+		}
+
+		me.lifecycle.err = me.exifTools.close()
+	})
+
+	return me.lifecycle.err
+}
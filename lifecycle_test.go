@@ -0,0 +1,61 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaExtractor_Close(t *testing.T) {
+	samplePath := filepath.Join("testdata", "sample.doc")
+
+	t.Run("Closed", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, VerifyManifests: true})
+		profiled, err := me.WithProfile("documents")
+		require.NoError(t, err)
+
+		_, err = me.Extract(samplePath)
+		require.NoError(t, err)
+
+		require.NoError(t, profiled.Close())
+		require.NoError(t, me.Close(), "Close is idempotent")
+		assert.Empty(t, me.manifests.dirs)
+
+		_, err = me.Extract(samplePath)
+		assert.ErrorIs(t, err, ErrClosed)
+
+		_, err = me.ExtractStreamInput(bytes.NewReader([]byte("%PDF-1.7\n")))
+		assert.ErrorIs(t, err, ErrClosed)
+
+		results := me.ExtractBatch([]string{samplePath})
+		require.Len(t, results, 1)
+		assert.ErrorIs(t, results[0].Err, ErrClosed)
+	})
+
+	t.Run("In Progress", func(t *testing.T) {
+		started := make(chan struct{})
+		blocking := BackendFunc{
+			BackendName: "blocking",
+			Fn: func(ctx context.Context, filePath string, metadata *Metadata) error {
+				close(started)
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}
+		me := NewMetaExtractor(Options{PureGo: true, Backends: []Extractor{blocking}})
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := me.ExtractContext(context.Background(), samplePath)
+			done <- err
+		}()
+
+		<-started
+		require.NoError(t, me.Close())
+		assert.ErrorIs(t, <-done, ErrClosed)
+	})
+}
@@ -0,0 +1,85 @@
+package metaextractor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLimitsUnsupported is returned when resource limits are configured on a
+// platform that does not support them.
+var ErrLimitsUnsupported = errors.New("resource limits are not supported on this platform")
+
+// Limits configures resource limits enforced on the spawned TrID and ExifTool
+// processes, so a malicious file cannot exhaust the host through the helper
+// tools. Zero values mean no limit.
+type Limits struct {
+	// Memory is the maximum virtual memory size of each process in bytes.
+	Memory int64
+
+	// CPUTime is the maximum CPU time of each process. ExifTool processes
+	// kept running between extractions are replaced well before their
+	// accumulated work reaches it, so the limit applies to each file.
+	CPUTime time.Duration
+
+	// OpenFiles is the maximum number of open file descriptors per process.
+	OpenFiles int
+}
+
+// isZero reports whether no limit is configured.
+func (l Limits) isZero() bool {
+	return l == Limits{}
+}
+
+// writeWrapper writes an executable wrapper script with the given content
+// into a private cache directory and returns its path. The file name is
+// derived from the content, so identical wrappers are shared.
+func writeWrapper(content string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "metaextractor")
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	wrapper := filepath.Join(dir, "wrapper-"+hex.EncodeToString(sum[:8])+wrapperExt)
+
+	if existing, err := os.ReadFile(wrapper); err == nil && string(existing) == content {
+		return wrapper, nil
+	}
+
+	tmp, err := os.CreateTemp(dir, "wrapper-*")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o700); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), wrapper); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return wrapper, nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package metaextractor
+
+const wrapperExt = ".cmd"
+
+// limitsSupported reports whether resource limits can be enforced. Windows
+// job objects are not used, so limits are rejected rather than ignored.
+const limitsSupported = false
+
+// wrapCommand is not supported on this platform.
+func wrapCommand(cmd string, limits Limits, prefix []string) (string, error) {
+	if !limits.isZero() {
+		return "", ErrLimitsUnsupported
+	}
+
+	return "", ErrSandboxUnsupported
+}
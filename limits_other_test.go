@@ -0,0 +1,14 @@
+//go:build !unix
+
+package metaextractor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits_Unsupported(t *testing.T) {
+	me := NewMetaExtractor(Options{Limits: Limits{Memory: 1 << 30}})
+	assert.ErrorIs(t, me.Check(), ErrLimitsUnsupported)
+}
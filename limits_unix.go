@@ -0,0 +1,56 @@
+//go:build unix
+
+package metaextractor
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const wrapperExt = ".sh"
+
+// limitsSupported reports whether resource limits can be enforced.
+const limitsSupported = true
+
+// wrapCommand returns the path of a wrapper script that applies the limits
+// using ulimit and then executes the given command with all arguments,
+// preceded by the optional prefix (e.g., a sandbox command).
+func wrapCommand(cmd string, limits Limits, prefix []string) (string, error) {
+	path, err := exec.LookPath(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+
+	if limits.Memory > 0 {
+		fmt.Fprintf(&b, "ulimit -v %d || exit 126\n", (limits.Memory+1023)/1024)
+	}
+
+	if limits.CPUTime > 0 {
+		seconds := int64(limits.CPUTime.Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		fmt.Fprintf(&b, "ulimit -t %d || exit 126\n", seconds)
+	}
+
+	if limits.OpenFiles > 0 {
+		fmt.Fprintf(&b, "ulimit -n %d || exit 126\n", limits.OpenFiles)
+	}
+
+	b.WriteString("exec")
+	for _, arg := range append(prefix, path) {
+		b.WriteString(" " + shellQuote(arg))
+	}
+	b.WriteString(" \"$@\"\n")
+
+	return writeWrapper(b.String())
+}
+
+// shellQuote quotes s for use in a POSIX shell script.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package metaextractor
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapCommand(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	wrapper, err := wrapCommand("sh", Limits{CPUTime: 30 * time.Second, OpenFiles: 64}, nil)
+	require.NoError(t, err)
+
+	out, err := exec.Command(wrapper, "-c", "ulimit -n; ulimit -t").Output()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"64", "30"}, strings.Fields(string(out)))
+
+	again, err := wrapCommand("sh", Limits{CPUTime: 30 * time.Second, OpenFiles: 64}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, wrapper, again)
+
+	_, err = wrapCommand("metaextractor-missing-command", Limits{OpenFiles: 64}, nil)
+	assert.Error(t, err)
+}
+
+func TestNewMetaExtractor_Limits(t *testing.T) {
+	if pureGoBuild {
+		t.Skip("external tools are disabled in pure-Go builds")
+	}
+
+	me := NewMetaExtractor(Options{
+		TridPath: "metaextractor-missing-trid",
+		Limits:   Limits{OpenFiles: 64},
+	})
+
+	_, err := me.Extract("file")
+	assert.ErrorContains(t, err, "error wrapping TrID")
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'/usr/bin/exiftool'`, shellQuote("/usr/bin/exiftool"))
+	assert.Equal(t, `'/opt/it'\''s/trid'`, shellQuote("/opt/it's/trid"))
+}
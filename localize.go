@@ -0,0 +1,67 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// MessageCatalog maps English messages (type descriptions, labels and
+// quarantine reasons) to their translation in one language.
+type MessageCatalog map[string]string
+
+// ParseMessageCatalog reads a catalog from a JSON object mapping messages to
+// their translation, e.g. {"Adobe Portable Document Format": "Adobe PDF-Dokument"}.
+func ParseMessageCatalog(r io.Reader) (MessageCatalog, error) {
+	var catalog MessageCatalog
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("error reading message catalog: %w", err)
+	}
+
+	return catalog, nil
+}
+
+// Translate returns the translation of the message, or the message itself if
+// the catalog has none.
+func (c MessageCatalog) Translate(message string) string {
+	if t, ok := c[message]; ok && t != "" {
+		return t
+	}
+	return message
+}
+
+// Localize returns a copy of the metadata with the type descriptions
+// (Types[i].Name and BestType.Name), the labels and the quarantine reason
+// translated. The metadata passed in is not modified. Localize is meant for
+// presentation; rules and routes always see the original messages.
+func (c MessageCatalog) Localize(metadata Metadata) Metadata {
+	if metadata.Types != nil {
+		metadata.Types = slices.Clone(metadata.Types)
+		for i := range metadata.Types {
+			metadata.Types[i].Name = c.Translate(metadata.Types[i].Name)
+		}
+	}
+
+	if metadata.BestType != nil {
+		bestType := *metadata.BestType
+		bestType.Name = c.Translate(bestType.Name)
+		metadata.BestType = &bestType
+	}
+
+	if metadata.Labels != nil {
+		labels := make([]string, len(metadata.Labels))
+		for i, label := range metadata.Labels {
+			labels[i] = c.Translate(label)
+		}
+		metadata.Labels = labels
+	}
+
+	if metadata.Quarantine != nil {
+		quarantine := *metadata.Quarantine
+		quarantine.Reason = c.Translate(quarantine.Reason)
+		metadata.Quarantine = &quarantine
+	}
+
+	return metadata
+}
@@ -0,0 +1,48 @@
+package metaextractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageCatalog_Localize(t *testing.T) {
+	catalog, err := ParseMessageCatalog(strings.NewReader(`{
+		"Adobe Portable Document Format": "Adobe PDF-Dokument",
+		"macro-enabled document": "Dokument mit Makros",
+		"large": "groß"
+	}`))
+	require.NoError(t, err)
+
+	metadata := Metadata{
+		Types: []trid.FileType{
+			{Extension: ".pdf", Name: "Adobe Portable Document Format"},
+			{Extension: ".ai", Name: "Adobe Illustrator graphics"},
+		},
+		BestType:   &BestType{Extension: ".pdf", Name: "Adobe Portable Document Format"},
+		Labels:     []string{"large", "reviewed"},
+		Quarantine: &QuarantineAction{Reason: "macro-enabled document"},
+	}
+
+	localized := catalog.Localize(metadata)
+
+	assert.Equal(t, "Adobe PDF-Dokument", localized.Types[0].Name)
+	assert.Equal(t, "Adobe Illustrator graphics", localized.Types[1].Name)
+	assert.Equal(t, "Adobe PDF-Dokument", localized.BestType.Name)
+	assert.Equal(t, []string{"groß", "reviewed"}, localized.Labels)
+	assert.Equal(t, "Dokument mit Makros", localized.Quarantine.Reason)
+
+	// The original metadata is not modified.
+	assert.Equal(t, "Adobe Portable Document Format", metadata.Types[0].Name)
+	assert.Equal(t, "Adobe Portable Document Format", metadata.BestType.Name)
+	assert.Equal(t, "large", metadata.Labels[0])
+	assert.Equal(t, "macro-enabled document", metadata.Quarantine.Reason)
+}
+
+func TestParseMessageCatalog_Error(t *testing.T) {
+	_, err := ParseMessageCatalog(strings.NewReader(`["not", "an", "object"]`))
+	assert.ErrorContains(t, err, "error reading message catalog")
+}
@@ -0,0 +1,139 @@
+package metaextractor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MacBundleExtensions lists the extensions of directories treated as macOS
+// bundles. The comparison is case-insensitive.
+var MacBundleExtensions = []string{
+	".app", ".appex", ".bundle", ".framework", ".kext", ".mdimporter",
+	".photoslibrary", ".plugin", ".prefpane", ".qlgenerator", ".saver",
+	".systemextension", ".xpc",
+}
+
+// macBundlePlists are the locations of the Info.plist file relative to the
+// bundle root, in order of preference.
+var macBundlePlists = []string{
+	"Contents/Info.plist",
+	"Resources/Info.plist",
+	"Versions/Current/Resources/Info.plist",
+	"Info.plist",
+}
+
+// MacBundle contains metadata of a macOS bundle, read from its Info.plist.
+type MacBundle struct {
+	// Identifier is the bundle identifier (CFBundleIdentifier), e.g.
+	// "com.apple.Safari".
+	Identifier string `json:"identifier,omitempty"`
+
+	// Name is the user-visible name of the bundle (CFBundleDisplayName or
+	// CFBundleName).
+	Name string `json:"name,omitempty"`
+
+	// Version is the release version (CFBundleShortVersionString).
+	Version string `json:"version,omitempty"`
+
+	// Build is the build version (CFBundleVersion).
+	Build string `json:"build,omitempty"`
+
+	// Executable is the name of the main executable (CFBundleExecutable).
+	Executable string `json:"executable,omitempty"`
+
+	// PackageType is the four-letter package type (CFBundlePackageType),
+	// e.g. "APPL" or "FMWK".
+	PackageType string `json:"package_type,omitempty"`
+
+	// MinimumSystemVersion is the minimum macOS version required
+	// (LSMinimumSystemVersion).
+	MinimumSystemVersion string `json:"minimum_system_version,omitempty"`
+
+	// Files is the number of regular files in the bundle.
+	Files int `json:"files,omitempty"`
+}
+
+// isMacBundle reports whether the path is a directory with the extension of
+// a macOS bundle.
+func isMacBundle(path string, info os.FileInfo) bool {
+	if !info.IsDir() {
+		return false
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range MacBundleExtensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extractMacBundle fills the metadata of a macOS bundle: its total size,
+// the number of files and the fields of its Info.plist, if any.
+func (me *MetaExtractor) extractMacBundle(sysPath string, metadata *Metadata) error {
+	metadata.Kind = KindBundle
+
+	dirs, err := me.collectDirMetadata(sysPath)
+	if err != nil {
+		return err
+	}
+
+	dir := dirs[filepath.Clean(sysPath)]
+	metadata.Size = dir.TotalSize
+
+	bundle, err := readMacBundle(sysPath)
+	if err != nil {
+		if err := me.stageError(metadata, nil, err); err != nil {
+			return err
+		}
+		bundle = &MacBundle{}
+	}
+	bundle.Files = dir.TotalFiles
+	metadata.MacBundle = bundle
+
+	return nil
+}
+
+// readMacBundle reads the Info.plist of the bundle. Bundles without an
+// Info.plist (e.g., photo libraries) have an empty MacBundle.
+func readMacBundle(dir string) (*MacBundle, error) {
+	for _, name := range macBundlePlists {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("error reading Info.plist: %w", err)
+		}
+
+		info, err := parsePlist(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Info.plist: %w", err)
+		}
+
+		str := func(keys ...string) string {
+			for _, key := range keys {
+				if s, ok := info[key].(string); ok && s != "" {
+					return s
+				}
+			}
+			return ""
+		}
+
+		return &MacBundle{
+			Identifier:           str("CFBundleIdentifier"),
+			Name:                 str("CFBundleDisplayName", "CFBundleName"),
+			Version:              str("CFBundleShortVersionString"),
+			Build:                str("CFBundleVersion"),
+			Executable:           str("CFBundleExecutable"),
+			PackageType:          str("CFBundlePackageType"),
+			MinimumSystemVersion: str("LSMinimumSystemVersion"),
+		}, nil
+	}
+
+	return &MacBundle{}, nil
+}
@@ -0,0 +1,95 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaExtractor_MacBundle(t *testing.T) {
+	root := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeTree(t, root, map[string]time.Time{
+		"Example.app/Contents/MacOS/Example":       base,
+		"Example.app/Contents/Resources/icon.icns": base,
+		"Photos.photoslibrary/database/Photos.db":  base,
+		"notes.txt": base,
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(root, "Example.app", "Contents", "Info.plist"), []byte(testXMLPlist), 0o644))
+
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	t.Run("Extract", func(t *testing.T) {
+		metadata, err := me.Extract(filepath.Join(root, "Example.app"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "Example.app", metadata.Name)
+		assert.Equal(t, KindBundle, metadata.Kind)
+		assert.Empty(t, metadata.Types)
+		require.NotNil(t, metadata.MacBundle)
+		assert.Equal(t, "com.example.App", metadata.MacBundle.Identifier)
+		assert.Equal(t, 3, metadata.MacBundle.Files)
+		assert.Equal(t, int64(len("Example.app/Contents/MacOS/Example")+
+			len("Example.app/Contents/Resources/icon.icns")+len(testXMLPlist)), metadata.Size)
+	})
+
+	t.Run("no Info.plist", func(t *testing.T) {
+		metadata, err := me.Extract(filepath.Join(root, "Photos.photoslibrary"))
+		require.NoError(t, err)
+
+		assert.Equal(t, KindBundle, metadata.Kind)
+		assert.Equal(t, &MacBundle{Files: 1}, metadata.MacBundle)
+	})
+
+	t.Run("invalid Info.plist", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "Broken.framework")
+		writeTree(t, dir, map[string]time.Time{"Resources/Info.plist": base})
+
+		_, err := me.Extract(dir)
+		assert.ErrorIs(t, err, errInvalidPlist)
+
+		metadata, err := NewMetaExtractor(Options{PureGo: true, BestEffort: true}).Extract(dir)
+		require.NoError(t, err)
+		assert.Len(t, metadata.Warnings, 1)
+		assert.Equal(t, &MacBundle{Files: 1}, metadata.MacBundle)
+	})
+
+	paths := func(results []Result) []string {
+		var paths []string
+		for _, r := range results {
+			require.NoError(t, r.Err)
+			rel, err := filepath.Rel(root, r.Path)
+			require.NoError(t, err)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return paths
+	}
+
+	t.Run("ExtractDir", func(t *testing.T) {
+		results, err := me.ExtractDir(root, WalkOptions{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Example.app", "Photos.photoslibrary", "notes.txt"}, paths(results))
+
+		results, err = me.ExtractDir(filepath.Join(root, "Example.app"), WalkOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, KindBundle, results[0].Metadata.Kind)
+	})
+
+	t.Run("DescendBundles", func(t *testing.T) {
+		results, err := me.ExtractDir(root, WalkOptions{DescendBundles: true})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"Example.app/Contents/Info.plist",
+			"Example.app/Contents/MacOS/Example",
+			"Example.app/Contents/Resources/icon.icns",
+			"Photos.photoslibrary/database/Photos.db",
+			"notes.txt",
+		}, paths(results))
+	})
+}
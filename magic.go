@@ -0,0 +1,217 @@
+package metaextractor
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/attilabuti/trid"
+)
+
+// Magic is the identification of a file by libmagic, as reported by the
+// file command.
+type Magic struct {
+	// MimeType is the MIME type of the file (e.g., "image/png"), or
+	// "application/octet-stream" if it is unknown.
+	MimeType string `json:"mime_type,omitempty"`
+
+	// Encoding is the character encoding of the file (e.g., "utf-8",
+	// "us-ascii"), or "binary" for binary files.
+	Encoding string `json:"encoding,omitempty"`
+
+	// Description is the human-readable description of the file (e.g.,
+	// "PNG image data, 1 x 1, 8-bit/color RGBA").
+	Description string `json:"description,omitempty"`
+
+	// Extensions are the usual extensions of the type (e.g., ".jpeg",
+	// ".jpg"). They are only reported by file 5.33 and later.
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+// runMagic identifies the file by running the file command cmd. If ctx
+// carries a magic cache, the identification is taken from it when the file
+// was already identified with cmd.
+func runMagic(ctx context.Context, cmd, filePath string) (*Magic, error) {
+	cache := magicCacheFromContext(ctx)
+	if magic := cache.get(cmd, filePath); magic != nil {
+		return magic, nil
+	}
+
+	run := func(args ...string) (string, error) {
+		out, err := exec.CommandContext(ctx, cmd, append(append([]string{"--brief"}, args...), "--", filePath)...).Output()
+		if err != nil && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return strings.TrimSpace(string(out)), err
+	}
+
+	out, err := run("--mime")
+	if err != nil {
+		return nil, err
+	}
+
+	magic := &Magic{}
+	if mediaType, params, err := mime.ParseMediaType(out); err == nil {
+		magic.MimeType, magic.Encoding = mediaType, params["charset"]
+	} else {
+		mediaType, _, _ := strings.Cut(out, ";")
+		magic.MimeType = strings.TrimSpace(mediaType)
+	}
+
+	if magic.Description, err = run(); err != nil {
+		return nil, err
+	}
+
+	// The file command lists the extensions separated by slashes
+	// (e.g., "jpeg/jpg/jpe/jfif"), or "???" if they are unknown.
+	if out, err := run("--extension"); err == nil && out != "???" && out != "" {
+		for _, ext := range strings.Split(out, "/") {
+			magic.Extensions = append(magic.Extensions, "."+ext)
+		}
+	}
+
+	cache.put(cmd, filePath, magic)
+
+	return magic, nil
+}
+
+// magicKey identifies a file and the file command that identified it.
+type magicKey struct {
+	cmd, filePath string
+}
+
+// magicCache caches the identifications of the file being extracted, so that
+// MagicDetector and MagicBackend share the output of the file command rather
+// than running it again. A nil cache stores nothing.
+type magicCache struct {
+	mu    sync.Mutex
+	magic map[magicKey]*Magic
+}
+
+// get returns the cached identification of the file, or nil.
+func (c *magicCache) get(cmd, filePath string) *Magic {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.magic[magicKey{cmd, filePath}]
+}
+
+// put caches the identification of the file.
+func (c *magicCache) put(cmd, filePath string, magic *Magic) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.magic == nil {
+		c.magic = make(map[magicKey]*Magic)
+	}
+	c.magic[magicKey{cmd, filePath}] = magic
+}
+
+// magicCacheKey is the context key of the magic cache of an extraction.
+type magicCacheKey struct{}
+
+// withMagicCache returns ctx carrying a new magic cache, shared by the stages
+// of a single extraction.
+func withMagicCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, magicCacheKey{}, &magicCache{})
+}
+
+// magicCacheFromContext returns the magic cache of ctx, or nil.
+func magicCacheFromContext(ctx context.Context) *magicCache {
+	c, _ := ctx.Value(magicCacheKey{}).(*magicCache)
+	return c
+}
+
+// fileType returns the identification as a detected type. It reports false
+// if the type is unknown.
+func (m *Magic) fileType() (trid.FileType, bool) {
+	if m == nil || m.MimeType == "" || m.MimeType == "application/octet-stream" {
+		return trid.FileType{}, false
+	}
+
+	return trid.FileType{
+		Extension:   strings.Join(m.Extensions, "/"),
+		Probability: 100,
+		Name:        m.Description,
+		MimeType:    m.MimeType,
+	}, true
+}
+
+// magicBackend is the libmagic stage bound to an extractor.
+type magicBackend struct {
+	me *MetaExtractor
+}
+
+func (b magicBackend) Name() string {
+	return "magic"
+}
+
+func (b magicBackend) Extract(ctx context.Context, filePath string, metadata *Metadata) error {
+	if b.me == nil {
+		return fmt.Errorf("%s: %w", b.Name(), errBackendNotConfigured)
+	}
+
+	_, err := b.me.magic(ctx, filePath, metadata)
+	return err
+}
+
+// magic identifies the file using libmagic and sets Metadata.Magic. If no
+// type was detected by an earlier stage, the type identified by libmagic
+// is also set as Metadata.Types. It reports the identified type, if any.
+func (me *MetaExtractor) magic(ctx context.Context, filePath string, metadata *Metadata) (trid.FileType, error) {
+	if me.pureGo {
+		return trid.FileType{}, nil
+	}
+
+	magic, err := runMagic(ctx, me.fileCmd, filePath)
+	if err != nil {
+		return trid.FileType{}, err
+	}
+	metadata.Magic = magic
+
+	fileType, ok := magic.fileType()
+	if ok && len(metadata.Types) == 0 {
+		metadata.Types = withMimeTypes([]trid.FileType{fileType})
+		metadata.Detector = "magic"
+	}
+
+	return fileType, nil
+}
+
+// magicStage runs the libmagic backend on the file, adding its answer to
+// the votes reconciled into Metadata.BestType.
+func (me *MetaExtractor) magicStage(ctx context.Context, toolPath string, metadata *Metadata, detected *detection, trace *stageTrace) error {
+	if me.pureGo || toolPath == "" {
+		return nil
+	}
+
+	start := time.Now()
+	fileType, err := me.magic(ctx, toolPath, metadata)
+	trace.done("magic", start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error running file: %w", err))
+	}
+
+	if fileType.MimeType != "" {
+		detected.votes = append(detected.votes, typeVote{detector: "magic", fileType: fileType})
+	}
+
+	return nil
+}
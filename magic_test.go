@@ -0,0 +1,174 @@
+package metaextractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFileCommand returns a script standing in for the file command, which
+// reports the given --mime and --extension output and a PNG description.
+// Each run is logged as a line of the file "calls" next to the script.
+func fakeFileCommand(t *testing.T, mimeOut, extOut string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+if [ "$1" = --version ]; then
+	echo file-5.44
+	echo "magic file from /usr/share/misc/magic"
+	exit
+fi
+echo "$@" >> "$(dirname "$0")/calls"
+case "$2" in
+--mime) echo "`+mimeOut+`" ;;
+--extension) echo "`+extOut+`" ;;
+*) echo "PNG image data, 1 x 1, 8-bit/color RGBA" ;;
+esac
+`), 0o755))
+
+	return script
+}
+
+func TestRunMagic(t *testing.T) {
+	tests := []struct {
+		name    string
+		mimeOut string
+		extOut  string
+		expect  Magic
+		known   bool
+	}{
+		{
+			name:    "Known",
+			mimeOut: "image/png; charset=binary",
+			extOut:  "png",
+			expect:  Magic{MimeType: "image/png", Encoding: "binary", Description: "PNG image data, 1 x 1, 8-bit/color RGBA", Extensions: []string{".png"}},
+			known:   true,
+		},
+		{
+			name:    "Several Extensions",
+			mimeOut: "image/jpeg; charset=binary",
+			extOut:  "jpeg/jpg/jpe/jfif",
+			expect:  Magic{MimeType: "image/jpeg", Encoding: "binary", Description: "PNG image data, 1 x 1, 8-bit/color RGBA", Extensions: []string{".jpeg", ".jpg", ".jpe", ".jfif"}},
+			known:   true,
+		},
+		{
+			name:    "Unknown",
+			mimeOut: "application/octet-stream; charset=binary",
+			extOut:  "???",
+			expect:  Magic{MimeType: "application/octet-stream", Encoding: "binary", Description: "PNG image data, 1 x 1, 8-bit/color RGBA"},
+		},
+		{
+			name:    "Malformed",
+			mimeOut: "text/plain; charset",
+			extOut:  "???",
+			expect:  Magic{MimeType: "text/plain", Description: "PNG image data, 1 x 1, 8-bit/color RGBA"},
+			known:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			magic, err := runMagic(context.Background(), fakeFileCommand(t, tt.mimeOut, tt.extOut), "image")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, *magic)
+
+			_, known := magic.fileType()
+			assert.Equal(t, tt.known, known)
+		})
+	}
+
+	t.Run("Missing Command", func(t *testing.T) {
+		_, err := runMagic(context.Background(), filepath.Join(t.TempDir(), "file"), "image")
+		assert.Error(t, err)
+	})
+}
+
+func TestMagicBackend(t *testing.T) {
+	if pureGoBuild {
+		t.Skip("external tools are disabled in pure-Go builds")
+	}
+
+	script := fakeFileCommand(t, "image/png; charset=binary", "png")
+	filePath := filepath.Join("testdata", "sample.mp3")
+
+	t.Run("Replacing TrID", func(t *testing.T) {
+		me := NewMetaExtractor(Options{FileCommandPath: script, Backends: []Extractor{MagicBackend}})
+
+		metadata, err := me.Extract(filePath)
+		require.NoError(t, err)
+		require.NotNil(t, metadata.Magic)
+		assert.Equal(t, "image/png", metadata.Magic.MimeType)
+		assert.Equal(t, "magic", metadata.Detector)
+		require.Len(t, metadata.Types, 1)
+		assert.Equal(t, ".png", metadata.Types[0].Extension)
+		assert.Equal(t, KindImage, metadata.Kind)
+		require.NotNil(t, metadata.BestType)
+		assert.Equal(t, []string{"magic"}, metadata.BestType.Detectors)
+	})
+
+	t.Run("After TrID", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			FileCommandPath: script,
+			Detectors:       []Detector{SignatureDetector},
+			Backends:        []Extractor{TridBackend, MagicBackend},
+		})
+
+		metadata, err := me.Extract(filePath)
+		require.NoError(t, err)
+		require.NotNil(t, metadata.Magic)
+		assert.Equal(t, "signature", metadata.Detector, "earlier types are kept")
+		assert.Equal(t, "audio/mpeg", metadata.Types[0].MimeType)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "file")
+
+		_, err := NewMetaExtractor(Options{FileCommandPath: missing, Backends: []Extractor{MagicBackend}}).Extract(filePath)
+		assert.Error(t, err)
+
+		metadata, err := NewMetaExtractor(Options{FileCommandPath: missing, Backends: []Extractor{MagicBackend}, BestEffort: true}).Extract(filePath)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Magic)
+		assert.Len(t, metadata.Warnings, 1)
+	})
+
+	t.Run("Pure Go", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{PureGo: true, FileCommandPath: script, Backends: []Extractor{MagicBackend}}).Extract(filePath)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Magic)
+	})
+
+	t.Run("Not Configured", func(t *testing.T) {
+		err := MagicBackend.Extract(context.Background(), filePath, &Metadata{})
+		assert.ErrorIs(t, err, errBackendNotConfigured)
+	})
+}
+
+func TestRunMagic_Cache(t *testing.T) {
+	cmd := fakeFileCommand(t, "image/png; charset=binary", "png")
+	ctx := withMagicCache(context.Background())
+
+	first, err := runMagic(ctx, cmd, "image")
+	require.NoError(t, err)
+	second, err := runMagic(ctx, cmd, "image")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	_, err = runMagic(ctx, cmd, "other")
+	require.NoError(t, err)
+
+	calls, err := os.ReadFile(filepath.Join(filepath.Dir(cmd), "calls"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, strings.Count(string(calls), "\n"))
+}
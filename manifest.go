@@ -0,0 +1,466 @@
+package metaextractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChecksumStatus is the outcome of verifying a file against a checksum
+// manifest.
+type ChecksumStatus string
+
+const (
+	// ChecksumOK indicates that the digest of the file matches the manifest.
+	ChecksumOK ChecksumStatus = "ok"
+
+	// ChecksumMismatch indicates that the digest of the file differs from
+	// the manifest, i.e., the file is corrupt or was modified.
+	ChecksumMismatch ChecksumStatus = "mismatch"
+)
+
+// ChecksumCheck is the verification of a file against an entry of a
+// checksum manifest found in its directory (see Options.VerifyManifests).
+type ChecksumCheck struct {
+	// Manifest is the path of the manifest.
+	Manifest string `json:"manifest,omitempty"`
+
+	// Algorithm is the hash algorithm of the entry (e.g., "crc32" for SFV
+	// files, "md5" for PAR2 files).
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Expected is the hex-encoded digest listed in the manifest.
+	Expected string `json:"expected,omitempty"`
+
+	// Status is the outcome of the verification.
+	Status ChecksumStatus `json:"status"`
+}
+
+// manifestEntry is an entry of a checksum manifest.
+type manifestEntry struct {
+	manifest  string
+	algorithm string
+	digest    string
+}
+
+// manifestNames are the (upper-case) names of checksum manifests without a
+// distinctive extension, with the algorithm they imply; an empty algorithm
+// is inferred from the length of the digests.
+var manifestNames = map[string]string{
+	"MD5SUMS":    "md5",
+	"SHA1SUMS":   "sha1",
+	"SHA256SUMS": "sha256",
+	"SHA512SUMS": "sha512",
+	"B3SUMS":     "blake3",
+	"CHECKSUMS":  "",
+}
+
+// manifestExts are the extensions of checksum manifests, with the algorithm
+// they imply.
+var manifestExts = map[string]string{
+	".sfv":       "crc32",
+	".md5":       "md5",
+	".md5sum":    "md5",
+	".sha1":      "sha1",
+	".sha1sum":   "sha1",
+	".sha256":    "sha256",
+	".sha256sum": "sha256",
+	".sha512":    "sha512",
+	".sha512sum": "sha512",
+	".b3":        "blake3",
+	".par2":      "md5",
+}
+
+// digestAlgorithms are the algorithms of checksum manifests, keyed by the
+// length of their hex-encoded digests, for manifests not implying one.
+var digestAlgorithms = map[int]string{
+	32:  "md5",
+	40:  "sha1",
+	64:  "sha256",
+	128: "sha512",
+}
+
+// manifestAlgorithm reports whether the file name is that of a checksum
+// manifest, and the algorithm it implies.
+func manifestAlgorithm(name string) (string, bool) {
+	if algorithm, ok := manifestNames[strings.ToUpper(name)]; ok {
+		return algorithm, true
+	}
+
+	algorithm, ok := manifestExts[strings.ToLower(filepath.Ext(name))]
+	return algorithm, ok
+}
+
+// parseManifest parses the checksum manifest at path. It returns the entries
+// keyed by file name relative to the directory of the manifest, using
+// slashes. Lines that cannot be parsed are skipped.
+func parseManifest(path string) (map[string][]manifestEntry, error) {
+	algorithm, _ := manifestAlgorithm(filepath.Base(path))
+
+	f, err := os.Open(longPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make(map[string][]manifestEntry)
+	add := func(name, algorithm, digest string) {
+		name = strings.TrimPrefix(strings.ReplaceAll(name, `\`, "/"), "./")
+		if name == "" || algorithm == "" {
+			return
+		}
+		entries[name] = append(entries[name], manifestEntry{manifest: path, algorithm: algorithm, digest: strings.ToLower(digest)})
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".par2":
+		err = parsePAR2(f, add)
+	case ".sfv":
+		err = parseSFV(f, add)
+	default:
+		err = parseSums(f, algorithm, add)
+	}
+
+	return entries, err
+}
+
+// parseSFV parses a Simple File Verification file: lines of a file name and
+// its CRC32, with comments starting with a semicolon.
+func parseSFV(r io.Reader, add func(name, algorithm, digest string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' {
+			continue
+		}
+
+		i := strings.LastIndexAny(line, " \t")
+		if i < 0 {
+			continue
+		}
+
+		name, digest := strings.TrimSpace(line[:i]), line[i+1:]
+		if isHexDigest(digest, 8) {
+			add(name, "crc32", digest)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseSums parses the output of md5sum and related tools, in either the
+// GNU format ("digest  name" or "digest *name") or the BSD format
+// ("SHA256 (name) = digest").
+func parseSums(r io.Reader, algorithm string, add func(name, algorithm, digest string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		// BSD format.
+		if tag, rest, ok := strings.Cut(line, " ("); ok {
+			if name, digest, ok := cutLast(rest, ") = "); ok {
+				algorithm := strings.ToLower(strings.ReplaceAll(tag, "-", ""))
+				if _, ok := hashFuncs[algorithm]; ok && isHexDigest(digest, 0) {
+					add(name, algorithm, digest)
+				}
+				continue
+			}
+		}
+
+		// GNU format; a leading backslash marks escaped names.
+		line = strings.TrimPrefix(line, `\`)
+		digest, name, ok := strings.Cut(line, " ")
+		if !ok || !isHexDigest(digest, 0) {
+			continue
+		}
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+
+		lineAlgorithm := algorithm
+		if lineAlgorithm == "" {
+			lineAlgorithm = digestAlgorithms[len(digest)]
+		}
+		add(name, lineAlgorithm, digest)
+	}
+
+	return scanner.Err()
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
+// isHexDigest reports whether s is a hex-encoded digest of n characters, or
+// of any even length if n is 0.
+func isHexDigest(s string, n int) bool {
+	if s == "" || len(s)%2 != 0 || (n > 0 && len(s) != n) {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+var (
+	par2Magic        = []byte("PAR2\x00PKT")
+	par2FileDescType = []byte("PAR 2.0\x00FileDesc")
+)
+
+// par2HeaderSize is the size of the header of a PAR2 packet.
+const par2HeaderSize = 64
+
+// parsePAR2 parses the file description packets of a PAR2 file, which hold
+// the MD5 digest of every file of the recovery set. The recovery slices of
+// volume files are skipped without being read.
+func parsePAR2(f io.ReadSeeker, add func(name, algorithm, digest string)) error {
+	seen := make(map[string]bool)
+	header := make([]byte, par2HeaderSize)
+
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if !bytes.Equal(header[:8], par2Magic) {
+			return errors.New("invalid PAR2 packet")
+		}
+
+		length := binary.LittleEndian.Uint64(header[8:16])
+		if length < par2HeaderSize || length%4 != 0 || length > 1<<62 {
+			return errors.New("invalid PAR2 packet length")
+		}
+		bodySize := int64(length - par2HeaderSize)
+
+		if !bytes.Equal(header[48:64], par2FileDescType) {
+			if _, err := f.Seek(bodySize, io.SeekCurrent); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// File ID, MD5 of the file, MD5 of its first 16 KiB, length and the
+		// name padded with zeros.
+		if bodySize < 56 || bodySize > 56+4096 {
+			return errors.New("invalid PAR2 file description")
+		}
+		body := make([]byte, bodySize)
+		if _, err := io.ReadFull(f, body); err != nil {
+			return err
+		}
+
+		name := string(bytes.TrimRight(body[56:], "\x00"))
+		if !seen[name] {
+			seen[name] = true
+			add(name, "md5", hex.EncodeToString(body[16:32]))
+		}
+	}
+}
+
+// manifestCache holds the parsed checksum manifests of the directories of
+// the extracted files, so that a manifest is parsed once per directory
+// rather than once per file. Entries are revalidated against the
+// modification times of the directory and of its manifests.
+type manifestCache struct {
+	mu   sync.Mutex
+	dirs map[string]*manifestDir
+}
+
+// manifestDir holds the parsed manifests of a directory.
+type manifestDir struct {
+	modTime   time.Time
+	manifests map[string]time.Time
+	entries   map[string][]manifestEntry
+}
+
+// newManifestCache returns an empty cache.
+func newManifestCache() *manifestCache {
+	return &manifestCache{dirs: make(map[string]*manifestDir)}
+}
+
+// reset drops the parsed manifests.
+func (c *manifestCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.dirs)
+}
+
+// lookup returns the manifest entries for the file at filePath.
+func (c *manifestCache) lookup(filePath string) []manifestEntry {
+	dirPath, name := filepath.Split(filePath)
+	dirPath = filepath.Clean(dirPath)
+
+	dirInfo, err := os.Stat(longPath(dirPath))
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dir, ok := c.dirs[dirPath]
+	if !ok || !dir.valid(dirPath, dirInfo.ModTime()) {
+		dir = loadManifestDir(dirPath, dirInfo.ModTime())
+		c.dirs[dirPath] = dir
+	}
+
+	if entries, ok := dir.entries[name]; ok {
+		return entries
+	}
+
+	// Manifests created on Windows may differ in case.
+	for entryName, entries := range dir.entries {
+		if strings.EqualFold(entryName, name) {
+			return entries
+		}
+	}
+
+	return nil
+}
+
+// valid reports whether the manifests of the directory are unchanged.
+func (d *manifestDir) valid(dirPath string, modTime time.Time) bool {
+	if !d.modTime.Equal(modTime) {
+		return false
+	}
+
+	for name, manifestTime := range d.manifests {
+		info, err := os.Stat(longPath(filepath.Join(dirPath, name)))
+		if err != nil || !info.ModTime().Equal(manifestTime) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// loadManifestDir parses the manifests of the directory. Manifests that
+// cannot be read are skipped.
+func loadManifestDir(dirPath string, modTime time.Time) *manifestDir {
+	dir := &manifestDir{
+		modTime:   modTime,
+		manifests: make(map[string]time.Time),
+		entries:   make(map[string][]manifestEntry),
+	}
+
+	files, err := os.ReadDir(longPath(dirPath))
+	if err != nil {
+		return dir
+	}
+
+	for _, file := range files {
+		if _, ok := manifestAlgorithm(file.Name()); !ok || !file.Type().IsRegular() {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		dir.manifests[file.Name()] = info.ModTime()
+
+		entries, _ := parseManifest(filepath.Join(dirPath, file.Name()))
+		for name, nameEntries := range entries {
+			// Only the files of the directory itself are verified.
+			if !strings.Contains(name, "/") {
+				dir.entries[name] = append(dir.entries[name], nameEntries...)
+			}
+		}
+	}
+
+	return dir
+}
+
+// verifyManifests verifies the file against the manifests of its directory,
+// using the digests of Metadata.Hashes where available.
+func (me *MetaExtractor) verifyManifests(filePath string, metadata *Metadata) error {
+	entries := me.manifests.lookup(filePath)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var missing []string
+	for _, entry := range entries {
+		if _, ok := metadata.Hashes[entry.algorithm]; !ok && !slices.Contains(missing, entry.algorithm) {
+			missing = append(missing, entry.algorithm)
+		}
+	}
+
+	digests, err := fileDigests(filePath, missing)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		digest, ok := metadata.Hashes[entry.algorithm]
+		if !ok {
+			digest = digests[entry.algorithm]
+		}
+
+		status := ChecksumOK
+		if !strings.EqualFold(digest, entry.digest) {
+			status = ChecksumMismatch
+		}
+
+		metadata.Checksums = append(metadata.Checksums, ChecksumCheck{
+			Manifest:  entry.manifest,
+			Algorithm: entry.algorithm,
+			Expected:  entry.digest,
+			Status:    status,
+		})
+	}
+
+	return nil
+}
+
+// fileDigests computes the hex-encoded digests of the file for the given
+// algorithms in a single read.
+func fileDigests(filePath string, algorithms []string) (map[string]string, error) {
+	if len(algorithms) == 0 {
+		return nil, nil
+	}
+
+	f, err := os.Open(longPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, algorithm := range algorithms {
+		hashes[i] = hashFuncs[algorithm]()
+		writers[i] = hashes[i]
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		digests[algorithm] = hex.EncodeToString(hashes[i].Sum(nil))
+	}
+
+	return digests, nil
+}
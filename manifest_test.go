@@ -0,0 +1,171 @@
+package metaextractor
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// par2Packet returns a PAR2 packet of the given type and body.
+func par2Packet(packetType string, body []byte) []byte {
+	var b bytes.Buffer
+	b.Write(par2Magic)
+	binary.Write(&b, binary.LittleEndian, uint64(par2HeaderSize+len(body)))
+	b.Write(make([]byte, 32)) // packet MD5 and recovery set ID
+	b.WriteString(packetType)
+	b.Write(body)
+	return b.Bytes()
+}
+
+// par2FileDesc returns a PAR2 file description packet.
+func par2FileDesc(name string, content []byte) []byte {
+	var body bytes.Buffer
+	body.Write(make([]byte, 16)) // file ID
+	sum := md5.Sum(content)
+	body.Write(sum[:])
+	body.Write(make([]byte, 16)) // MD5 of the first 16 KiB
+	binary.Write(&body, binary.LittleEndian, uint64(len(content)))
+	body.WriteString(name)
+	for body.Len()%4 != 0 {
+		body.WriteByte(0)
+	}
+
+	return par2Packet(string(par2FileDescType), body.Bytes())
+}
+
+func TestParseManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+		return p
+	}
+
+	t.Run("SFV", func(t *testing.T) {
+		p := write("set.sfv", "; generated by cfv\r\nfile one.txt 3610A686\r\nbad line\r\nsub\\two.txt\t00000000\r\n")
+
+		entries, err := parseManifest(p)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]manifestEntry{
+			"file one.txt": {{manifest: p, algorithm: "crc32", digest: "3610a686"}},
+			"sub/two.txt":  {{manifest: p, algorithm: "crc32", digest: "00000000"}},
+		}, entries)
+	})
+
+	t.Run("GNU", func(t *testing.T) {
+		p := write("SHA256SUMS", ""+
+			"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  hello.txt\n"+
+			"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824 *./binary.bin\n"+
+			"# comment\n"+
+			"nothex  other.txt\n")
+
+		entries, err := parseManifest(p)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "sha256", entries["hello.txt"][0].algorithm)
+		assert.Contains(t, entries, "binary.bin")
+	})
+
+	t.Run("Inferred Algorithm", func(t *testing.T) {
+		p := write("CHECKSUMS", "5d41402abc4b2a76b9719d911017c592  a.txt\naaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d  b.txt\n0123  c.txt\n")
+
+		entries, err := parseManifest(p)
+		require.NoError(t, err)
+		assert.Equal(t, "md5", entries["a.txt"][0].algorithm)
+		assert.Equal(t, "sha1", entries["b.txt"][0].algorithm)
+		assert.NotContains(t, entries, "c.txt")
+	})
+
+	t.Run("BSD", func(t *testing.T) {
+		p := write("release.sha256", "SHA256 (app (1).tar.gz) = 2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824\nMD5 (a.txt) = 5d41402abc4b2a76b9719d911017c592\n")
+
+		entries, err := parseManifest(p)
+		require.NoError(t, err)
+		assert.Equal(t, []manifestEntry{{manifest: p, algorithm: "sha256", digest: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"}}, entries["app (1).tar.gz"])
+		assert.Equal(t, "md5", entries["a.txt"][0].algorithm)
+	})
+
+	t.Run("PAR2", func(t *testing.T) {
+		var content []byte
+		content = append(content, par2Packet("PAR 2.0\x00Main\x00\x00\x00\x00", make([]byte, 12))...)
+		content = append(content, par2FileDesc("hello.txt", []byte("hello"))...)
+		content = append(content, par2Packet("PAR 2.0\x00RecvSlic", make([]byte, 1024))...)
+		content = append(content, par2FileDesc("hello.txt", []byte("hello"))...)
+		p := write("set.vol00+01.par2", string(content))
+
+		entries, err := parseManifest(p)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]manifestEntry{
+			"hello.txt": {{manifest: p, algorithm: "md5", digest: "5d41402abc4b2a76b9719d911017c592"}},
+		}, entries)
+
+		_, err = parseManifest(write("broken.par2", "PAR2\x00PKT\x01"))
+		assert.Error(t, err)
+
+		_, err = parseManifest(write("invalid.par2", strings.Repeat("x", par2HeaderSize)))
+		assert.Error(t, err)
+	})
+}
+
+func TestVerifyManifests(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+		return p
+	}
+
+	hello := write("hello.txt", "hello")
+	other := write("other.txt", "other")
+	unlisted := write("unlisted.txt", "unlisted")
+	write("set.sfv", "hello.txt 3610a686\nOTHER.TXT 00000000\n")
+	write("MD5SUMS", "5d41402abc4b2a76b9719d911017c592  hello.txt\n5d41402abc4b2a76b9719d911017c592  sub/hello.txt\n")
+
+	me := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"md5"}, VerifyManifests: true})
+
+	metadata, err := me.Extract(hello)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ChecksumCheck{
+		{Manifest: filepath.Join(dir, "set.sfv"), Algorithm: "crc32", Expected: "3610a686", Status: ChecksumOK},
+		{Manifest: filepath.Join(dir, "MD5SUMS"), Algorithm: "md5", Expected: "5d41402abc4b2a76b9719d911017c592", Status: ChecksumOK},
+	}, metadata.Checksums)
+	assert.Equal(t, map[string]string{"md5": "5d41402abc4b2a76b9719d911017c592"}, metadata.Hashes, "only the selected hashes are reported")
+
+	metadata, err = me.Extract(other)
+	require.NoError(t, err)
+	require.Len(t, metadata.Checksums, 1)
+	assert.Equal(t, ChecksumMismatch, metadata.Checksums[0].Status)
+
+	metadata, err = me.Extract(unlisted)
+	require.NoError(t, err)
+	assert.Empty(t, metadata.Checksums)
+
+	t.Run("Changed Manifest", func(t *testing.T) {
+		p := write("set.sfv", "hello.txt 00000000\n")
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(p, later, later))
+
+		metadata, err := me.Extract(hello)
+		require.NoError(t, err)
+		for _, check := range metadata.Checksums {
+			if check.Algorithm == "crc32" {
+				assert.Equal(t, ChecksumMismatch, check.Status)
+			}
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{PureGo: true}).Extract(hello)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Checksums)
+	})
+}
@@ -0,0 +1,205 @@
+package metaextractor
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Media contains the container and stream properties of audio and video
+// files, as reported by the media backends (see FFprobeBackend and
+// MediaInfoBackend). Format and codec names are those of the analyzer that
+// reported them.
+type Media struct {
+	// Analyzer is the name of the backend that reported the properties
+	// (e.g., "ffprobe"), or the comma-separated names of the backends if
+	// several did.
+	Analyzer string `json:"analyzer"`
+
+	// Format is the short name of the container format (e.g.,
+	// "mov,mp4,m4a,3gp,3g2,mj2"), and FormatName its descriptive name
+	// (e.g., "QuickTime / MOV").
+	Format     string `json:"format,omitempty"`
+	FormatName string `json:"format_name,omitempty"`
+
+	// Duration is the duration of the file in seconds.
+	Duration float64 `json:"duration,omitempty"`
+
+	// BitRate is the overall bit rate in bits per second.
+	BitRate int64 `json:"bit_rate,omitempty"`
+
+	// Streams are the audio, video, subtitle and data streams of the file,
+	// in the order they are stored.
+	Streams []MediaStream `json:"streams,omitempty"`
+
+	// Tags are the metadata tags of the container (e.g., "encoder",
+	// "creation_time").
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// MediaStream is a stream of an audio or video file.
+type MediaStream struct {
+	// Index is the index of the stream in the container.
+	Index int `json:"index"`
+
+	// Type is the type of the stream: "video", "audio", "subtitle",
+	// "data" or "attachment".
+	Type string `json:"type"`
+
+	// Codec is the short name of the codec (e.g., "h264"), CodecName its
+	// descriptive name and Profile the codec profile (e.g., "High").
+	Codec     string `json:"codec,omitempty"`
+	CodecName string `json:"codec_name,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+
+	// Duration is the duration of the stream in seconds.
+	Duration float64 `json:"duration,omitempty"`
+
+	// BitRate is the bit rate of the stream in bits per second.
+	BitRate int64 `json:"bit_rate,omitempty"`
+
+	// Language is the language of the stream (e.g., "eng"), if recorded.
+	Language string `json:"language,omitempty"`
+
+	// Width and Height are the dimensions of video streams in pixels,
+	// FrameRate their frame rate in frames per second and PixelFormat the
+	// layout of their pixels (e.g., "yuv420p").
+	Width       int     `json:"width,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	FrameRate   float64 `json:"frame_rate,omitempty"`
+	PixelFormat string  `json:"pixel_format,omitempty"`
+
+	// BitDepth is the number of bits per sample of audio streams or per
+	// color component of video streams.
+	BitDepth int `json:"bit_depth,omitempty"`
+
+	// SampleRate is the sample rate of audio streams in Hz, Channels their
+	// number of channels and ChannelLayout the arrangement of the channels
+	// (e.g., "stereo", "5.1").
+	SampleRate    int    `json:"sample_rate,omitempty"`
+	Channels      int    `json:"channels,omitempty"`
+	ChannelLayout string `json:"channel_layout,omitempty"`
+
+	// Tags are the metadata tags of the stream (e.g., "handler_name").
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// addMedia merges the properties reported by a media backend into
+// Metadata.Media. Properties already reported by an earlier backend are
+// kept, so later backends only fill the gaps.
+func (m *Metadata) addMedia(name string, media *Media) {
+	if m.Media == nil {
+		media.Analyzer = name
+		m.Media = media
+		return
+	}
+
+	dst := m.Media
+	if !slices.Contains(strings.Split(dst.Analyzer, ","), name) {
+		dst.Analyzer += "," + name
+	}
+
+	dst.Format = firstNonEmpty(dst.Format, media.Format)
+	dst.FormatName = firstNonEmpty(dst.FormatName, media.FormatName)
+	dst.Duration = firstNonZero(dst.Duration, media.Duration)
+	dst.BitRate = firstNonZero(dst.BitRate, media.BitRate)
+	dst.Tags = mergeTags(dst.Tags, media.Tags)
+
+	// Analyzers number the streams differently, so streams are matched by
+	// their position among the streams of their type.
+	seen := make(map[string]int)
+	for _, s := range media.Streams {
+		n := seen[s.Type]
+		seen[s.Type]++
+
+		i := nthStream(dst.Streams, s.Type, n)
+		if i < 0 {
+			s.Index = len(dst.Streams)
+			dst.Streams = append(dst.Streams, s)
+			continue
+		}
+		dst.Streams[i].merge(s)
+	}
+}
+
+// merge fills the properties of the stream missing from s with those of
+// other.
+func (s *MediaStream) merge(other MediaStream) {
+	s.Codec = firstNonEmpty(s.Codec, other.Codec)
+	s.CodecName = firstNonEmpty(s.CodecName, other.CodecName)
+	s.Profile = firstNonEmpty(s.Profile, other.Profile)
+	s.Duration = firstNonZero(s.Duration, other.Duration)
+	s.BitRate = firstNonZero(s.BitRate, other.BitRate)
+	s.Language = firstNonEmpty(s.Language, other.Language)
+	s.Width = firstNonZero(s.Width, other.Width)
+	s.Height = firstNonZero(s.Height, other.Height)
+	s.FrameRate = firstNonZero(s.FrameRate, other.FrameRate)
+	s.PixelFormat = firstNonEmpty(s.PixelFormat, other.PixelFormat)
+	s.BitDepth = firstNonZero(s.BitDepth, other.BitDepth)
+	s.SampleRate = firstNonZero(s.SampleRate, other.SampleRate)
+	s.Channels = firstNonZero(s.Channels, other.Channels)
+	s.ChannelLayout = firstNonEmpty(s.ChannelLayout, other.ChannelLayout)
+	s.Tags = mergeTags(s.Tags, other.Tags)
+}
+
+// nthStream returns the position of the n-th stream (counting from 0) of
+// the given type, or -1 if there are fewer.
+func nthStream(streams []MediaStream, typ string, n int) int {
+	for i, s := range streams {
+		if s.Type != typ {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+
+	return -1
+}
+
+// mergeTags returns dst with the tags of src it is missing.
+func mergeTags(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		return maps.Clone(src)
+	}
+
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+
+	return dst
+}
+
+// firstNonZero returns the first of the values that is not zero.
+func firstNonZero[T int | int64 | float64](values ...T) T {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+
+	return 0
+}
+
+// isMediaStreamFile reports whether the detected type of the file is an
+// audio or video type, or no type was detected, so that the media backends
+// only run on files that may have streams.
+func isMediaStreamFile(metadata Metadata) bool {
+	mimeType := detectedMimeType(metadata)
+	if mimeType == "" {
+		return true
+	}
+
+	switch mimeTypeKind(mimeType) {
+	case KindAudio, KindVideo:
+		return true
+	}
+
+	return false
+}
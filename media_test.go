@@ -0,0 +1,61 @@
+package metaextractor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata_AddMedia(t *testing.T) {
+	var metadata Metadata
+
+	metadata.addMedia("ffprobe", &Media{
+		Format:   "mov,mp4,m4a,3gp,3g2,mj2",
+		Duration: 10.01,
+		Streams: []MediaStream{
+			{Index: 0, Type: "video", Codec: "h264", Width: 1920, Height: 1080},
+			{Index: 1, Type: "audio", Codec: "aac", SampleRate: 48000},
+		},
+		Tags: map[string]string{"encoder": "Lavf60.16.100"},
+	})
+	metadata.addMedia("mediainfo", &Media{
+		Format:     "MPEG-4",
+		FormatName: "MPEG-4 Part 14",
+		BitRate:    5011871,
+		Streams: []MediaStream{
+			{Index: 1, Type: "video", Codec: "AVC", FrameRate: 29.97, BitDepth: 8},
+			{Index: 2, Type: "audio", Codec: "AAC", Channels: 2, ChannelLayout: "L R"},
+			{Index: 3, Type: "text", Codec: "tx3g", Language: "en"},
+		},
+		Tags: map[string]string{"encoder": "other", "title": "Clip"},
+	})
+	metadata.addMedia("mediainfo", &Media{})
+
+	assert.Equal(t, &Media{
+		Analyzer:   "ffprobe,mediainfo",
+		Format:     "mov,mp4,m4a,3gp,3g2,mj2",
+		FormatName: "MPEG-4 Part 14",
+		Duration:   10.01,
+		BitRate:    5011871,
+		Streams: []MediaStream{
+			{Index: 0, Type: "video", Codec: "h264", Width: 1920, Height: 1080, FrameRate: 29.97, BitDepth: 8},
+			{Index: 1, Type: "audio", Codec: "aac", SampleRate: 48000, Channels: 2, ChannelLayout: "L R"},
+			{Index: 2, Type: "text", Codec: "tx3g", Language: "en"},
+		},
+		Tags: map[string]string{"encoder": "Lavf60.16.100", "title": "Clip"},
+	}, metadata.Media)
+}
+
+func TestRedactionPolicy_Media(t *testing.T) {
+	metadata := Metadata{Media: &Media{
+		Analyzer: "ffprobe",
+		Streams:  []MediaStream{{Type: "audio", Tags: map[string]string{"artist": "Someone", "language": "eng"}}},
+		Tags:     map[string]string{"location": "+47.5034-008.2500/", "xyz": "+47.5034-008.2500/", "encoder": "Lavf60.16.100"},
+	}}
+
+	redacted := DefaultRedactionPolicy.Apply(metadata)
+	assert.Equal(t, map[string]string{"location": RedactedValue, "xyz": RedactedValue, "encoder": "Lavf60.16.100"}, redacted.Media.Tags)
+	assert.Equal(t, map[string]string{"language": "eng"}, redacted.Media.Streams[0].Tags)
+	assert.Equal(t, "+47.5034-008.2500/", metadata.Media.Tags["location"], "the metadata passed in is not modified")
+	assert.Equal(t, "Someone", metadata.Media.Streams[0].Tags["artist"])
+}
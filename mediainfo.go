@@ -0,0 +1,236 @@
+package metaextractor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mediaInfoOutput is the JSON output of MediaInfo. Values are reported as
+// strings, except for the "extra" object holding the tags without a
+// MediaInfo field.
+type mediaInfoOutput struct {
+	Media *struct {
+		Track []map[string]interface{} `json:"track"`
+	} `json:"media"`
+}
+
+// mediaInfoStreamTypes maps the MediaInfo track types to the stream types
+// of MediaStream, as named by ffprobe. Menu (chapter) tracks are skipped.
+var mediaInfoStreamTypes = map[string]string{
+	"Video": "video",
+	"Audio": "audio",
+	"Text":  "subtitle",
+	"Image": "attachment",
+	"Other": "data",
+}
+
+// The MediaInfo fields reported as tags of the container and of the
+// streams, along with the "extra" tags. The technical fields are mapped to
+// the properties of Media and MediaStream instead.
+var (
+	mediaInfoTags = []string{
+		"Title", "Movie", "Album", "Track", "Performer", "Composer", "Genre",
+		"Description", "Comment", "Copyright", "Recorded_Date", "Encoded_Date",
+		"Tagged_Date", "Encoded_Application", "Encoded_Library", "TimeCode_FirstFrame",
+	}
+	mediaInfoStreamTags = []string{
+		"Title", "Default", "Forced", "ScanType", "ScanOrder", "Standard",
+		"DisplayAspectRatio", "ColorSpace", "ChromaSubsampling", "TimeCode_FirstFrame",
+		"Encoded_Library",
+	}
+)
+
+var reMediaInfoVersion = regexp.MustCompile(`MediaInfoLib - v(\S+)`)
+
+// runMediaInfo reads the container and stream properties of the file with
+// MediaInfo.
+func runMediaInfo(ctx context.Context, cmd, filePath string) (*Media, error) {
+	out, err := exec.CommandContext(ctx, cmd, "--Output=JSON", "--", filePath).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+
+	return parseMediaInfo(out)
+}
+
+// parseMediaInfo converts the JSON output of MediaInfo into the media
+// properties. Files without audio, video, text or other streams are
+// reported as errNoMediaStreams, as MediaInfo describes any file.
+func parseMediaInfo(data []byte) (*Media, error) {
+	var out mediaInfoOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("error parsing mediainfo output: %w", err)
+	}
+	if out.Media == nil {
+		return nil, errNoMediaStreams
+	}
+
+	media := &Media{}
+	for _, track := range out.Media.Track {
+		field := func(name string) string {
+			s, _ := track[name].(string)
+			return strings.TrimSpace(s)
+		}
+
+		trackType := field("@type")
+		if trackType == "General" {
+			media.Format = field("Format")
+			media.FormatName = firstNonEmpty(field("Format_Info"), field("Format_Commercial_IfAny"))
+			media.Duration = parseFloat(field("Duration"))
+			media.BitRate = int64(parseFloat(field("OverallBitRate")))
+			media.Tags = mediaInfoTrackTags(track, mediaInfoTags)
+			continue
+		}
+
+		streamType, ok := mediaInfoStreamTypes[trackType]
+		if !ok {
+			continue
+		}
+
+		// StreamOrder is the index in the container, reported as e.g.
+		// "0-1" for streams of nested containers.
+		index, err := strconv.Atoi(field("StreamOrder"))
+		if err != nil {
+			index = len(media.Streams)
+		}
+
+		stream := MediaStream{
+			Index:         index,
+			Type:          streamType,
+			Codec:         field("Format"),
+			CodecName:     firstNonEmpty(field("Format_Info"), field("Format_Commercial_IfAny")),
+			Profile:       firstNonEmpty(field("Format_Profile"), field("Format_AdditionalFeatures")),
+			Duration:      parseFloat(field("Duration")),
+			BitRate:       int64(parseFloat(field("BitRate"))),
+			Language:      field("Language"),
+			Width:         int(parseFloat(field("Width"))),
+			Height:        int(parseFloat(field("Height"))),
+			FrameRate:     parseFloat(field("FrameRate")),
+			BitDepth:      int(parseFloat(field("BitDepth"))),
+			SampleRate:    int(parseFloat(field("SamplingRate"))),
+			Channels:      int(parseFloat(field("Channels"))),
+			ChannelLayout: field("ChannelLayout"),
+			Tags:          mediaInfoTrackTags(track, mediaInfoStreamTags),
+		}
+
+		media.Streams = append(media.Streams, stream)
+	}
+
+	if len(media.Streams) == 0 {
+		return nil, errNoMediaStreams
+	}
+
+	return media, nil
+}
+
+// mediaInfoTrackTags returns the given fields and the "extra" tags of a
+// MediaInfo track, or nil if it has none.
+func mediaInfoTrackTags(track map[string]interface{}, fields []string) map[string]string {
+	tags := make(map[string]string)
+	for _, name := range fields {
+		if s, ok := track[name].(string); ok && strings.TrimSpace(s) != "" {
+			tags[name] = strings.TrimSpace(s)
+		}
+	}
+
+	if extra, ok := track["extra"].(map[string]interface{}); ok {
+		for k, v := range extra {
+			if s, ok := v.(string); ok {
+				tags[k] = s
+			}
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return tags
+}
+
+// mediaInfoBackend is the MediaInfo stage bound to an extractor.
+type mediaInfoBackend struct {
+	me *MetaExtractor
+}
+
+func (b mediaInfoBackend) Name() string {
+	return "mediainfo"
+}
+
+func (b mediaInfoBackend) Extract(ctx context.Context, filePath string, metadata *Metadata) error {
+	if b.me == nil {
+		return fmt.Errorf("%s: %w", b.Name(), errBackendNotConfigured)
+	}
+
+	return b.me.mediaInfo(ctx, filePath, metadata)
+}
+
+// mediaInfo reads the media properties of audio and video files with
+// MediaInfo and merges them into Metadata.Media.
+func (me *MetaExtractor) mediaInfo(ctx context.Context, filePath string, metadata *Metadata) error {
+	if me.pureGo || !isMediaStreamFile(*metadata) {
+		return nil
+	}
+
+	media, err := runMediaInfo(ctx, me.mediaInfoCmd, filePath)
+	if errors.Is(err, errNoMediaStreams) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	metadata.addMedia(SourceMediaInfo, media)
+
+	return nil
+}
+
+// mediaInfoStage runs the MediaInfo backend on the file.
+func (me *MetaExtractor) mediaInfoStage(ctx context.Context, toolPath string, metadata *Metadata, trace *stageTrace) error {
+	if me.pureGo || toolPath == "" {
+		return nil
+	}
+
+	start := time.Now()
+	err := me.mediaInfo(ctx, toolPath, metadata)
+	trace.done("mediainfo", start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error running mediainfo: %w", err))
+	}
+
+	return nil
+}
+
+// checkMediaInfo runs MediaInfo and returns its version.
+func checkMediaInfo(cmd string) (string, error) {
+	out, err := exec.Command(cmd, "--Version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	// e.g., "MediaInfo Command line,\nMediaInfoLib - v23.11"
+	if m := reMediaInfoVersion.FindSubmatch(out); m != nil {
+		return string(m[1]), nil
+	}
+
+	return "", fmt.Errorf("unexpected output: %q", strings.TrimSpace(string(out)))
+}
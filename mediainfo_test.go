@@ -0,0 +1,188 @@
+package metaextractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mediaInfoSample is the MediaInfo output of a video with an AVC, an AAC
+// and a timed text stream, and a chapter menu.
+const mediaInfoSample = `{
+	"creatingLibrary": {"name": "MediaInfoLib", "version": "23.11", "url": "https://mediaarea.net/MediaInfo"},
+	"media": {
+		"@ref": "clip.mp4",
+		"track": [
+			{
+				"@type": "General", "VideoCount": "1", "AudioCount": "1", "TextCount": "1",
+				"Format": "MPEG-4", "Format_Profile": "Base Media", "CodecID": "isom",
+				"FileSize": "6271104", "Duration": "10.010", "OverallBitRate": "5011871",
+				"Title": "Clip", "Encoded_Date": "2024-01-02 10:20:30 UTC",
+				"Encoded_Application": "Lavf60.16.100",
+				"extra": {"xyz": "+47.5034-008.2500/"}
+			},
+			{
+				"@type": "Video", "StreamOrder": "0", "ID": "1", "Format": "AVC",
+				"Format_Profile": "High", "Format_Level": "4", "CodecID": "avc1",
+				"Duration": "10.010", "BitRate": "4872311", "Width": "1920", "Height": "1080",
+				"FrameRate": "29.970", "ColorSpace": "YUV", "ChromaSubsampling": "4:2:0",
+				"BitDepth": "8", "ScanType": "Progressive", "Language": "en"
+			},
+			{
+				"@type": "Audio", "StreamOrder": "1", "ID": "2", "Format": "AAC",
+				"Format_AdditionalFeatures": "LC", "Duration": "10.010", "BitRate": "128000",
+				"Channels": "2", "ChannelLayout": "L R", "SamplingRate": "48000", "Default": "Yes"
+			},
+			{"@type": "Text", "StreamOrder": "0-1", "Format": "Timed Text", "Language": "en"},
+			{"@type": "Menu", "extra": {"_00_00_00_000": "Chapter 1"}}
+		]
+	}
+}`
+
+// mediaInfoSampleMedia is the media properties of mediaInfoSample.
+var mediaInfoSampleMedia = Media{
+	Format:   "MPEG-4",
+	Duration: 10.01,
+	BitRate:  5011871,
+	Streams: []MediaStream{
+		{
+			Index: 0, Type: "video", Codec: "AVC", Profile: "High", Duration: 10.01, BitRate: 4872311,
+			Language: "en", Width: 1920, Height: 1080, FrameRate: 29.97, BitDepth: 8,
+			Tags: map[string]string{"ColorSpace": "YUV", "ChromaSubsampling": "4:2:0", "ScanType": "Progressive"},
+		},
+		{
+			Index: 1, Type: "audio", Codec: "AAC", Profile: "LC", Duration: 10.01, BitRate: 128000,
+			SampleRate: 48000, Channels: 2, ChannelLayout: "L R",
+			Tags: map[string]string{"Default": "Yes"},
+		},
+		{Index: 2, Type: "subtitle", Codec: "Timed Text", Language: "en"},
+	},
+	Tags: map[string]string{
+		"Title":               "Clip",
+		"Encoded_Date":        "2024-01-02 10:20:30 UTC",
+		"Encoded_Application": "Lavf60.16.100",
+		"xyz":                 "+47.5034-008.2500/",
+	},
+}
+
+// fakeMediaInfo writes a script standing in for MediaInfo, which prints the
+// given output for every file.
+func fakeMediaInfo(t *testing.T, output string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "mediainfo")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+[ "$1" = "--Version" ] && { printf 'MediaInfo Command line,\nMediaInfoLib - v23.11\n'; exit 0; }
+cat <<'EOF'
+`+output+`
+EOF
+`), 0o755))
+
+	return script
+}
+
+func TestParseMediaInfo(t *testing.T) {
+	media, err := parseMediaInfo([]byte(mediaInfoSample))
+	require.NoError(t, err)
+	assert.Equal(t, mediaInfoSampleMedia, *media)
+
+	// MediaInfo reports a General track for any file.
+	_, err = parseMediaInfo([]byte(`{"media": {"@ref": "a.txt", "track": [{"@type": "General", "FileSize": "5"}]}}`))
+	assert.ErrorIs(t, err, errNoMediaStreams)
+
+	_, err = parseMediaInfo([]byte(`{"media": null}`))
+	assert.ErrorIs(t, err, errNoMediaStreams)
+
+	_, err = parseMediaInfo([]byte(`not json`))
+	assert.ErrorContains(t, err, "error parsing mediainfo output")
+}
+
+func TestMediaInfoBackend(t *testing.T) {
+	if pureGoBuild {
+		t.Skip("external tools are disabled in pure-Go builds")
+	}
+
+	script := fakeMediaInfo(t, mediaInfoSample)
+	sample := filepath.Join("testdata", "sample.mp3")
+
+	newExtractor := func(opts Options, backends ...Extractor) *MetaExtractor {
+		opts.Detectors = []Detector{SignatureDetector}
+		opts.Backends = append([]Extractor{TridBackend}, backends...)
+		return NewMetaExtractor(opts)
+	}
+
+	t.Run("Extract", func(t *testing.T) {
+		me := newExtractor(Options{MediaInfoPath: script, Provenance: true}, MediaInfoBackend)
+
+		metadata, err := me.Extract(sample)
+		require.NoError(t, err)
+		require.NotNil(t, metadata.Media)
+		expected := mediaInfoSampleMedia
+		expected.Analyzer = "mediainfo"
+		assert.Equal(t, expected, *metadata.Media)
+		assert.Equal(t, SourceMediaInfo, metadata.Provenance["Media"])
+
+		statuses, err := me.CheckTools()
+		require.NoError(t, err)
+		assert.Equal(t, []ToolStatus{{Name: "mediainfo", Path: script, Version: "23.11"}}, statuses)
+	})
+
+	t.Run("After FFprobe", func(t *testing.T) {
+		me := newExtractor(Options{FFprobePath: fakeFFprobe(t, ffprobeSample), MediaInfoPath: script, Provenance: true}, FFprobeBackend, MediaInfoBackend)
+
+		metadata, err := me.Extract(sample)
+		require.NoError(t, err)
+		require.NotNil(t, metadata.Media)
+		assert.Equal(t, "ffprobe,mediainfo", metadata.Media.Analyzer)
+		assert.Equal(t, SourceFFprobe, metadata.Provenance["Media"])
+		assert.Equal(t, "mov,mp4,m4a,3gp,3g2,mj2", metadata.Media.Format)
+		assert.Equal(t, "Clip", metadata.Media.Tags["Title"])
+
+		require.Len(t, metadata.Media.Streams, 3)
+		video := metadata.Media.Streams[0]
+		assert.Equal(t, "h264", video.Codec)
+		assert.Equal(t, "en", video.Language, "the undetermined language is filled in")
+		assert.Equal(t, "Progressive", video.Tags["ScanType"])
+		assert.Equal(t, MediaStream{Index: 2, Type: "subtitle", Codec: "Timed Text", Language: "en"}, metadata.Media.Streams[2])
+	})
+
+	t.Run("Not Media", func(t *testing.T) {
+		metadata, err := newExtractor(Options{MediaInfoPath: script}, MediaInfoBackend).Extract(filepath.Join("testdata", "sample.doc"))
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Media)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "mediainfo")
+
+		_, err := newExtractor(Options{MediaInfoPath: missing}, MediaInfoBackend).Extract(sample)
+		assert.ErrorContains(t, err, "error running mediainfo")
+
+		me := newExtractor(Options{MediaInfoPath: missing, BestEffort: true}, MediaInfoBackend)
+		metadata, err := me.Extract(sample)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Media)
+		assert.Len(t, metadata.Warnings, 1)
+		assert.ErrorContains(t, me.Check(), "mediainfo (")
+	})
+
+	t.Run("Pure Go", func(t *testing.T) {
+		metadata, err := newExtractor(Options{PureGo: true, MediaInfoPath: script}, MediaInfoBackend).Extract(sample)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Media)
+	})
+
+	t.Run("Not Configured", func(t *testing.T) {
+		err := MediaInfoBackend.Extract(context.Background(), sample, &Metadata{})
+		assert.ErrorIs(t, err, errBackendNotConfigured)
+	})
+}
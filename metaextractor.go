@@ -3,23 +3,93 @@
 package metaextractor
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/attilabuti/trid"
-	"github.com/barasher/go-exiftool"
 	"github.com/djherbis/times"
 )
 
 // MetaExtractor represents a metadata extraction instance with specific configurations.
+//
+// A MetaExtractor is safe for concurrent use by multiple goroutines. Its
+// configuration is not modified after NewMetaExtractor returns. Every
+// extraction runs its own TrID process; ExifTool processes are kept running
+// between extractions, each serving one extraction at a time. Close
+// releases them once the extractor is no longer needed.
 type MetaExtractor struct {
-	trid         *trid.Trid
-	tridMatches  int
-	exifToolOpts []func(*exiftool.Exiftool) error
+	tridPath          string
+	tridDefs          string
+	tridTimeout       time.Duration
+	tridMatches       int
+	exifToolPath      string
+	exifToolOpts      []exifToolOption
+	exifTools         *exifToolPool
+	lifecycle         *lifecycle
+	exifKeys          ExifKeyMode
+	exifPrecedence    []string
+	maxExifSize       int
+	binaryStore       BinaryStore
+	binaryThreshold   int
+	timeOpts          timeOptions
+	createdAt         CreatedAtPolicy
+	nameForm          NameForm
+	compoundExts      []string
+	caseSensitiveExts bool
+	runID             string
+	bestEffort        bool
+	strict            bool
+	failEmpty         bool
+	placeholders      PlaceholderPolicy
+	deduplicate       bool
+	stability         StabilityOptions
+	audit             *auditLog
+	indexProps        []string
+	changeTracking    bool
+	detectors         []Detector
+	minConfidence     float64
+	fuseDetectors     bool
+	backends          []Extractor
+	fileCmd           string
+	ffprobeCmd        string
+	mediaInfoCmd      string
+	pureGo            bool
+	scanOpts          scanOptions
+	manifests         *manifestCache
+	imageChecks       bool
+	imageAnalyzer     ImageAnalyzer
+	contentClassifier ContentClassifier
+	audioFingerprint  AudioFingerprintOptions
+	summary           SummaryOptions
+	errorBudgets      []ErrorBudget
+	zipMetadata       bool
+	provenance        bool
+	skipExif          bool
+	skipExifBinary    bool
+	exifTags          []string
+	profiles          map[string]Profile
+	sampleSize        int
+	routes            []Route
+	postProcessors    []PostProcessor
+	concurrency       int
+	resultBuffer      int
+	spillDir          string
+	progress          ProgressFunc
+	quarantineOpts    QuarantineOptions
+	rules             []compiledRule
+	rulesErr          error
+	passwords         []string
+	maxEntrySize      int64
+	maxEntryRatio     float64
+	sandboxDir        string
+	initErr           error
 }
 
 // Options configures the metadata extraction parameters.
@@ -38,48 +108,586 @@ type Options struct {
 
 	// ExifToolPath is the file system path to the ExifTool executable.
 	ExifToolPath string
+
+	// ExifToolArgs are additional ExifTool command-line arguments, such as
+	// "-fast2" to skip the trailers of large media files, "-n" to report
+	// numeric values, or "-charset", "utf8". Only the flags with an
+	// equivalent option are supported: -fast[N], -m, -u, -U, -struct, -L,
+	// -n, -charset, -api, -d and -c; other arguments are reported as a
+	// configuration error.
+	ExifToolArgs []string
+
+	// SkipExifBinary does not extract binary values such as embedded
+	// thumbnails and previews (ExifTool's -b option), which makes the
+	// extraction much faster on large media files. Binary tags are then
+	// reported with a placeholder such as "(Binary data 5043 bytes, use -b
+	// option to extract)".
+	SkipExifBinary bool
+
+	// FileCommandPath is the file system path to the file command, which
+	// is used by MagicDetector and MagicBackend. Defaults to "file".
+	FileCommandPath string
+
+	// FFprobePath is the file system path to ffprobe, which is used by
+	// FFprobeBackend. Defaults to "ffprobe".
+	FFprobePath string
+
+	// MediaInfoPath is the file system path to the MediaInfo command-line
+	// tool, which is used by MediaInfoBackend. Defaults to "mediainfo".
+	MediaInfoPath string
+
+	// ExifKeys selects how tags present in several metadata groups are
+	// represented in Metadata.Exif. Defaults to ExifKeysDefault.
+	ExifKeys ExifKeyMode
+
+	// ExifPrecedence is the group precedence order used by
+	// ExifKeysDeduplicate, and to resolve tags present in several groups
+	// when deriving Metadata.Normalized, Metadata.GPS and the EXIF times
+	// (see ExifMetadata.GetPrecedence). Defaults to DefaultExifPrecedence.
+	ExifPrecedence []string
+
+	// MaxExifSize caps the size of the JSON encoding of Metadata.Exif in
+	// bytes. Larger metadata is trimmed by dropping binary values first,
+	// then the largest remaining values; the dropped keys are recorded in
+	// Metadata.ExifDropped. Zero disables the limit.
+	MaxExifSize int
+
+	// BinaryStore receives the binary EXIF values (e.g., embedded
+	// thumbnails), which are replaced in Metadata.Exif with a BinaryRef. If
+	// nil, binary values are kept inline as "base64:..." strings.
+	BinaryStore BinaryStore
+
+	// BinaryThreshold is the minimum size in bytes of the binary values
+	// written to the BinaryStore; smaller values are kept inline.
+	BinaryThreshold int
+
+	// Routes maps detected file types to additional stages (e.g., thumbnails
+	// for images, text extraction for documents). Stages only run for files
+	// matched by at least one route.
+	Routes []Route
+
+	// PostProcessors are run in order on the metadata of every file before
+	// it is returned (e.g., TrimExif, a RedactionPolicy or a
+	// MessageCatalog).
+	PostProcessors []PostProcessor
+
+	// Quarantine configures an optional action that moves or copies files
+	// matching suspicious-file rules into a quarantine directory.
+	Quarantine QuarantineOptions
+
+	// Rules define conditions over the extracted metadata mapped to labels
+	// and actions. Invalid conditions are reported by Extract.
+	Rules []Rule
+
+	// Passwords is a list of candidate passwords tried, in order, on
+	// password-protected PDF documents, password-protected OOXML documents
+	// (Standard and Agile AES encryption) and encrypted ZIP entries
+	// (traditional PKWARE encryption) during archive walks. Legacy binary
+	// Office documents encrypted with RC4 are not supported.
+	Passwords []string
+
+	// MaxEntrySize limits the size of the archive entries and attachments
+	// written to disk for extraction, in bytes. Larger entries are skipped
+	// with a warning in Metadata.Warnings. Defaults to DefaultMaxEntrySize;
+	// a negative value disables the limit.
+	MaxEntrySize int64
+
+	// MaxEntryRatio limits the compression ratio of ZIP entries (their
+	// uncompressed size divided by their compressed size), which guards
+	// against ZIP bombs. Entries exceeding it once they are larger than
+	// 1 MiB are skipped with a warning. Defaults to DefaultMaxEntryRatio; a
+	// negative value disables the limit.
+	MaxEntryRatio float64
+
+	// Limits configures resource limits (memory, CPU time, open files)
+	// enforced on the TrID and ExifTool processes. Limits are applied
+	// using rlimits and are only supported on Unix systems; elsewhere,
+	// setting them is a configuration error (ErrLimitsUnsupported).
+	Limits Limits
+
+	// Sandbox runs TrID and ExifTool in a restricted environment on a
+	// private copy of each file. Only supported on Unix systems.
+	Sandbox SandboxOptions
+
+	// Hashes lists the hash algorithms ("md5", "sha1", "sha256", "sha512",
+	// "blake3", the non-cryptographic "xxhash64" and "crc32", and the
+	// "ssdeep" fuzzy hash) computed over the file content into
+	// Metadata.Hashes. Appending "-tree" (e.g., "sha256-tree") selects a
+	// chunked variant, which is computed in parallel: chunks of
+	// HashChunkSize bytes are hashed independently, and the digest is the
+	// hash of the concatenated chunk digests. Fuzzy hashes have no chunked
+	// variant.
+	Hashes []string
+
+	// VerifyManifests enables verifying files against the checksum
+	// manifests found in their directory: SFV files, the output of md5sum,
+	// sha256sum and related tools (e.g., "SHA256SUMS" or "*.md5"), and PAR2
+	// files. The results are reported in Metadata.Checksums. Digests not
+	// selected by Hashes are computed with an additional read of the file.
+	VerifyManifests bool
+
+	// HashChunkSize is the chunk size of chunked hashes. Defaults to
+	// DefaultHashChunkSize. Digests are only comparable if they were
+	// computed with the same chunk size.
+	HashChunkSize int
+
+	// Profiles are the named profiles selectable with WithProfile, in
+	// addition to DefaultProfiles, which they override.
+	Profiles map[string]Profile
+
+	// Concurrency is the number of files ExtractBatch, ExtractDir and
+	// ExtractStream extract in parallel. Every extraction runs its own
+	// TrID and ExifTool processes. Defaults to 1. With Deduplicate, which
+	// depends on the order of the files, ExtractBatch and ExtractDir extract
+	// one file at a time.
+	Concurrency int
+
+	// ResultBuffer is the number of results ExtractStream buffers for a
+	// consumer that is not ready to receive them. Once the buffer is full,
+	// no further file is started until the consumer catches up, so that
+	// memory use is bounded regardless of the number of files. Defaults to
+	// 0 (no buffer).
+	ResultBuffer int
+
+	// SpillDir, if set, is the directory (e.g., os.TempDir()) where
+	// ExtractStream spills the results beyond ResultBuffer to a temporary
+	// file instead of pausing the extraction, reading them back in order
+	// as the consumer catches up. The errors of spilled results only keep
+	// their message.
+	SpillDir string
+
+	// Progress, if set, is called after each file extracted by ExtractBatch
+	// and ExtractDir (see ProgressFunc).
+	Progress ProgressFunc
+
+	// HashWorkers is the maximum number of chunks hashed in parallel per
+	// chunked hash. Defaults to the number of CPUs.
+	HashWorkers int
+
+	// ReadBufferSize is the size of the buffer used to read file content.
+	// Defaults to DefaultReadBufferSize; larger buffers may speed up reading
+	// from network file systems.
+	ReadBufferSize int
+
+	// Entropy enables computing the Shannon entropy of the file content
+	// into Metadata.Entropy.
+	Entropy bool
+
+	// Sketch enables computing a similarity sketch of the file content into
+	// Metadata.Sketch, used by ClusterSimilar to group near-duplicate files.
+	Sketch bool
+
+	// ImageChecks enables cross-checking JPEG, PNG and GIF images against
+	// their EXIF metadata, reporting truncated data and EXIF dimensions or
+	// orientation not matching the decoded image in Metadata.Anomalies.
+	ImageChecks bool
+
+	// ImageAnalyzer detects the content of image files, such as objects and
+	// faces, into Metadata.Analysis (e.g., a CommandAnalyzer). It is run on
+	// the sandboxed copy of the file if Options.Sandbox is set.
+	ImageAnalyzer ImageAnalyzer
+
+	// ContentClassifier scores the content of image and video files for
+	// safety (e.g., "nsfw") into Metadata.ContentScores, which rules can
+	// screen (e.g., `ContentScores["nsfw"] > 0.8`). It is run on the
+	// sandboxed copy of the file if Options.Sandbox is set.
+	ContentClassifier ContentClassifier
+
+	// AudioFingerprint enables computing the Chromaprint fingerprint of
+	// audio files into Metadata.AudioFingerprint, and identifying their
+	// recordings (e.g., with AcoustID).
+	AudioFingerprint AudioFingerprintOptions
+
+	// Summary configures the summarization of text and document files into
+	// Metadata.Summary by a local model or an API, e.g. for search-result
+	// previews.
+	Summary SummaryOptions
+
+	// ZipMetadata enables reading the central directory of ZIP archives and
+	// ZIP-based formats into Metadata.Zip: the archive comment and, for
+	// each entry, the operating system it was created on, its compression
+	// method and the times of its extended timestamp fields.
+	ZipMetadata bool
+
+	// Provenance records the source of every field of the metadata in
+	// Metadata.Provenance (e.g., "filesystem" for the file times, "trid" for
+	// the detected types, "exiftool" for each EXIF tag, or the name of the
+	// custom backend or routed stage that added a value). Values changed by
+	// post-processors are not attributed to them.
+	Provenance bool
+
+	// SampleSize is the number of bytes sampled from the start and the end
+	// of the file into Metadata.Head and Metadata.Tail. Zero disables
+	// sampling.
+	SampleSize int
+
+	// Detectors is the ordered chain of type detectors (e.g., TridDetector,
+	// MagicDetector, SignatureDetector, ExtensionDetector). Later detectors
+	// only run if the earlier ones fail or report a low confidence. Defaults
+	// to TridDetector followed by SignatureDetector, which takes over when
+	// TrID is not installed, or SignatureDetector alone if PureGo is set.
+	Detectors []Detector
+
+	// FuseDetectors runs every detector of the chain, rather than stopping
+	// at the first confident one, so that all of them contribute to
+	// Metadata.BestType.
+	FuseDetectors bool
+
+	// Backends are the extraction stages run on the content of non-empty
+	// files, in order (e.g., TridBackend, MagicBackend, ExifToolBackend and
+	// custom backends such as ffprobe). Defaults to DefaultBackends.
+	// Leaving out a built-in backend disables its stage. Backends
+	// implementing DependentBackend are moved after their dependencies.
+	Backends []Extractor
+
+	// MinConfidence is the minimum probability (0-100) of the most likely
+	// type for a detector to win the chain. Defaults to 0, in which case
+	// later detectors only run if the earlier ones fail.
+	MinConfidence float64
+
+	// NameForm is the Unicode normalization form of Metadata.Name, so that
+	// scans of the same files on macOS (NFD) and Linux (usually NFC) report
+	// the same names. The original name is kept in Metadata.RawName.
+	// Defaults to NameAsIs.
+	NameForm NameForm
+
+	// CompoundExtensions are the multi-part extensions reported as a whole
+	// in Metadata.Extension and replaced as a whole in
+	// Metadata.SuggestedName. Defaults to DefaultCompoundExtensions.
+	CompoundExtensions []string
+
+	// CaseSensitiveExtensions compares the extension with the detected type
+	// as it appears in the file name, so that ".JPG" is reported as a
+	// mismatch of ".jpg". By default, the comparison is case-insensitive.
+	CaseSensitiveExtensions bool
+
+	// UTC converts file times and parsed EXIF times to UTC.
+	UTC bool
+
+	// TimeLocation is the location used to interpret EXIF times without a
+	// time zone (e.g., DateTimeOriginal). Defaults to UTC.
+	TimeLocation *time.Location
+
+	// GPSTimeZone derives the time zone of EXIF times without a time zone
+	// from the GPS time stamp of the file, if present. TimeLocation is used
+	// for files without one.
+	GPSTimeZone bool
+
+	// CreatedAt selects how Metadata.BestCreatedAt is chosen among the
+	// candidate creation dates. By default, the first plausible date of
+	// DefaultCreatedAtPrecedence is chosen.
+	CreatedAt CreatedAtPolicy
+
+	// RunID is the run ID reported in the results of ExtractBatch and
+	// ExtractDir. If empty, a new ID is generated for each batch.
+	RunID string
+
+	// BestEffort records the errors of failing stages (e.g., a TrID or
+	// ExifTool failure) in Metadata.Warnings instead of returning them, so
+	// that Extract returns the metadata gathered by the remaining stages.
+	// Errors accessing the file itself are still returned.
+	BestEffort bool
+
+	// Strict makes Extract fail with ErrIncomplete when the metadata is
+	// incomplete: no type was detected, an image, audio or video file has no
+	// embedded EXIF metadata, or the birth time of the file is unavailable.
+	Strict bool
+
+	// FailEmpty makes Extract return ErrEmptyFile for zero-byte files. By
+	// default, they are reported with Kind set to KindEmpty and no Types.
+	FailEmpty bool
+
+	// Placeholders selects how cloud placeholder files (e.g., OneDrive files
+	// available online only) are handled. By default, they are reported
+	// with Kind set to KindPlaceholder without reading their content; rules,
+	// routes and quarantine still apply to them.
+	Placeholders PlaceholderPolicy
+
+	// ErrorBudgets abort batch runs when a stage fails on too many files
+	// (e.g., ExifTool failing on more than 20% of them); the files not
+	// extracted then fail with a BudgetError giving the reason.
+	ErrorBudgets []ErrorBudget
+
+	// Deduplicate makes ExtractBatch and ExtractDir run the content stages
+	// (hashing, type detection and EXIF extraction) only once for files with
+	// identical content, sharing their results. EXIF tags describing the
+	// file in the file system (e.g., FileName, FileModifyDate) are those of
+	// each path. The paths are recorded in Result.DuplicateOf and
+	// Result.Aliases.
+	Deduplicate bool
+
+	// Stability detects files that change during extraction (e.g., files
+	// still being written) and repeats their extraction or marks them as
+	// unstable.
+	Stability StabilityOptions
+
+	// IndexProperties lists the properties read from the OS search index
+	// into Metadata.IndexProperties: Spotlight attributes (e.g.,
+	// "kMDItemStarRating") on macOS and Shell properties (e.g.,
+	// "System.Rating") on Windows. These often hold user-entered metadata
+	// not stored in the file. See DefaultIndexProperties. Ignored on other
+	// platforms and in pure-Go mode.
+	IndexProperties []string
+
+	// ChangeTracking records the NTFS file reference number and USN of
+	// each file in Metadata.ChangeTracking, so that repeated scans can use
+	// the change journal to find changed files. Only supported on Windows.
+	ChangeTracking bool
+
+	// Audit appends a record of every extraction (path, hashes, stages run
+	// and their durations, outcome and operator-supplied context) to an
+	// audit log.
+	Audit AuditOptions
+
+	// PureGo disables all external tools. File types are detected using the
+	// built-in signature detector and no EXIF metadata is extracted. It is
+	// always enabled when the package is built with the purego build tag.
+	PureGo bool
+
+	// DisableTrid removes TridDetector from the detector chain, so that TrID
+	// is never run. Types are still detected by the remaining detectors
+	// (the built-in signature detector by default).
+	DisableTrid bool
+
+	// DisableExif removes ExifToolBackend and NativeExifBackend from the
+	// backends, so that ExifTool is never started and Metadata.Exif is
+	// empty. Unlike Profile.SkipExif, profiles cannot enable it again.
+	DisableExif bool
 }
 
 // Metadata contains comprehensive metadata extracted from a file.
 type Metadata struct {
 	// Name is the base name of the file, including the extension.
-	Name string
+	Name string `json:"name"`
+
+	// RawName is the file name as stored by the file system, if it differs
+	// from Name because of Options.NameForm. It may contain invalid UTF-8.
+	RawName string `json:"raw_name,omitempty"`
+
+	// Extension is the lowercased file extension (e.g., ".txt", ".pdf").
+	// Compound extensions are reported as a whole (e.g., ".tar.gz"; see
+	// Options.CompoundExtensions).
+	Extension string `json:"extension,omitempty"`
 
-	// Extension is the file extension (e.g., ".txt", ".pdf").
-	Extension string
+	// RawExtension is the file extension as it appears in the file name
+	// (e.g., ".JPG").
+	RawExtension string `json:"raw_extension,omitempty"`
 
 	// ExtMismatch indicates whether the file's extension differs from its detected type.
-	ExtMismatch bool
+	ExtMismatch bool `json:"ext_mismatch,omitempty"`
+
+	// SuggestedExtension is the extension of the most likely file type (e.g., ".pdf").
+	// It is only set when ExtMismatch is true.
+	SuggestedExtension string `json:"suggested_extension,omitempty"`
+
+	// SuggestedName is the file name with its extension replaced by SuggestedExtension.
+	// Compound extensions (Options.CompoundExtensions) are replaced as a whole.
+	// It is only set when ExtMismatch is true.
+	SuggestedName string `json:"suggested_name,omitempty"`
 
 	// Size is the file size in bytes.
-	Size int64
+	Size int64 `json:"size"`
+
+	// Kind is the category of the file (e.g., KindEmpty for zero-byte
+	// files, or KindImage for a detected image). It is empty if the
+	// detected type has no known category.
+	Kind Kind `json:"kind,omitempty"`
+
+	// MimeType is the canonical MIME type of the file (e.g., "image/jpeg"),
+	// taken from ExifTool's MIMEType tag or, failing that, from the detected
+	// types or by sniffing the content. It is "application/octet-stream" for
+	// unidentified binary content and empty if the content was not read.
+	MimeType string `json:"mime_type,omitempty"`
+
+	// MacBundle contains the Info.plist metadata of a macOS bundle. It is
+	// only set if Kind is KindBundle.
+	MacBundle *MacBundle `json:"mac_bundle,omitempty"`
+
+	// Placeholder indicates that the file was a cloud placeholder whose
+	// content was not stored locally when the extraction started.
+	Placeholder bool `json:"placeholder,omitempty"`
 
 	// Time contains various timestamps associated with the file.
-	Time FileTime
+	Time FileTime `json:"time,omitempty"`
+
+	// Cloud contains the metadata reported by the cloud drive the file was
+	// downloaded from. It is only set by ExtractCloud.
+	Cloud *CloudFile `json:"cloud,omitempty"`
+
+	// ChangeTracking identifies the file in the NTFS change journal. It is
+	// only set on Windows if Options.ChangeTracking is true.
+	ChangeTracking *ChangeTracking `json:"change_tracking,omitempty"`
+
+	// Hashes contains the hex-encoded digests selected by Options.Hashes,
+	// keyed by algorithm name. Fuzzy hashes are reported as printed by
+	// their tools (e.g., "ssdeep": "3:hMCEpn:hu"; see SSDeepScore).
+	Hashes map[string]string `json:"hashes,omitempty"`
+
+	// Checksums contains the verification of the file against the entries
+	// of the checksum manifests in its directory. It is only set if
+	// Options.VerifyManifests is true and a manifest lists the file.
+	Checksums []ChecksumCheck `json:"checksums,omitempty"`
+
+	// Entropy is the Shannon entropy of the file content in bits per byte
+	// (0-8). High values indicate compressed or encrypted content. It is
+	// only set if Options.Entropy is true.
+	Entropy float64 `json:"entropy,omitempty"`
+
+	// Sketch is the similarity sketch of the file content. It is only set
+	// if Options.Sketch is true.
+	Sketch *Sketch `json:"sketch,omitempty"`
+
+	// Head and Tail contain the first and last Options.SampleSize bytes of
+	// the file.
+	Head []byte `json:"head,omitempty"`
+	Tail []byte `json:"tail,omitempty"`
+
+	// SplitArchive describes the split or multi-volume archive the file is a
+	// part of. It is nil if the file is not part of a split set.
+	SplitArchive *SplitArchive `json:"split_archive,omitempty"`
+
+	// Zip contains the central directory metadata of a ZIP archive. It is
+	// only set if Options.ZipMetadata is true and the file is a ZIP archive.
+	Zip *ZipArchive `json:"zip,omitempty"`
 
 	// Types is a slice of detected file types.
 	// The first element (if present) is considered the most likely file type.
-	Types []trid.FileType
+	Types []trid.FileType `json:"types,omitempty"`
+
+	// Detector is the name of the detector that produced Types
+	// (e.g., "trid", "signature").
+	Detector string `json:"detector,omitempty"`
+
+	// BestType is the type reconciled from the answers of all detectors
+	// that ran and ExifTool's FileType, with their agreement. It is nil if
+	// no type was identified.
+	BestType *BestType `json:"best_type,omitempty"`
+
+	// Magic is the identification of the file by libmagic. It is only set
+	// if Options.Backends contains MagicBackend.
+	Magic *Magic `json:"magic,omitempty"`
 
 	// Exif contains extracted EXIF metadata from the file.
-	Exif ExifMetadata
+	Exif ExifMetadata `json:"exif,omitempty"`
+
+	// ExifBackend is the name of the backend that extracted Exif
+	// ("exiftool" or "exif-native"), or the comma-separated names of the
+	// backends if several did. It is empty if no EXIF metadata was found.
+	ExifBackend string `json:"exif_backend,omitempty"`
+
+	// IndexProperties contains the properties of the file read from the OS
+	// search index (see Options.IndexProperties), keyed by property name.
+	IndexProperties map[string]interface{} `json:"index_properties,omitempty"`
+
+	// ExifTimes contains the date/time values of Exif, keyed as in Exif and
+	// interpreted according to Options.UTC, Options.TimeLocation and
+	// Options.GPSTimeZone.
+	ExifTimes map[string]time.Time `json:"exif_times,omitempty"`
+
+	// BestCreatedAt is the creation time of the content chosen among the
+	// birth time of the file and the creation dates of Exif (see
+	// Options.CreatedAt), or nil if none is plausible.
+	BestCreatedAt *BestCreatedAt `json:"best_created_at,omitempty"`
+
+	// ContentCreated is the time the content was created according to its
+	// embedded metadata (e.g., EXIF DateTimeOriginal or the creation date
+	// of a document), chosen among the dates of ExifTimes like
+	// BestCreatedAt, but never taken from the file times. It is the zero
+	// time if no plausible date is recorded.
+	ContentCreated time.Time `json:"content_created,omitempty"`
+
+	// GPS is the location parsed from the GPS tags of Exif (e.g.,
+	// GPSLatitude and GPSLongitude), or nil if none is recorded.
+	GPS *GPSCoordinates `json:"gps,omitempty"`
+
+	// Normalized contains the title, creator, dates, software, location,
+	// duration and dimensions of the content under canonical names, mapped
+	// from the format-specific tags of Exif, or nil if none is found.
+	Normalized *Normalized `json:"normalized,omitempty"`
+
+	// Media contains the container and stream properties of audio and
+	// video files reported by the media backends (see FFprobeBackend and
+	// MediaInfoBackend), or nil if none ran or the file has no streams.
+	Media *Media `json:"media,omitempty"`
+
+	// Anomalies contains the discrepancies found by the image checks (see
+	// Options.ImageChecks).
+	Anomalies []Anomaly `json:"anomalies,omitempty"`
+
+	// Analysis contains the objects and faces detected in the image by
+	// Options.ImageAnalyzer, or nil if no analyzer ran.
+	Analysis *ImageAnalysis `json:"analysis,omitempty"`
+
+	// ContentScores contains the safety scores (0-1) of the content of
+	// images and videos by category, as reported by
+	// Options.ContentClassifier.
+	ContentScores map[string]float64 `json:"content_scores,omitempty"`
+
+	// AudioFingerprint is the Chromaprint fingerprint of audio files and the
+	// recordings it was identified as (see Options.AudioFingerprint).
+	AudioFingerprint *AudioFingerprint `json:"audio_fingerprint,omitempty"`
+
+	// Summary is the summary and keywords of text and document files (see
+	// Options.Summary).
+	Summary *Summary `json:"summary,omitempty"`
+
+	// ExifDropped contains the keys of the values dropped from Exif to meet
+	// Options.MaxExifSize, in the order they were dropped.
+	ExifDropped []string `json:"exif_dropped,omitempty"`
+
+	// Extra contains the results of additional stages selected by Options.Routes,
+	// keyed by stage name.
+	Extra map[string]interface{} `json:"extra,omitempty"`
+
+	// Password is the password from Options.Passwords that unlocked the file,
+	// if it was password protected.
+	Password string `json:"password,omitempty"`
+
+	// Labels contains the labels of all rules whose conditions matched.
+	Labels []string `json:"labels,omitempty"`
+
+	// Quarantine records the quarantine action taken on the file, if any.
+	Quarantine *QuarantineAction `json:"quarantine,omitempty"`
+
+	// Unstable indicates that the file changed during extraction, so the
+	// metadata may be incomplete. It is only set if Options.Stability is
+	// enabled.
+	Unstable bool `json:"unstable,omitempty"`
+
+	// Warnings contains the errors of the stages that failed in best-effort
+	// mode (Options.BestEffort), the errors of the detectors that failed
+	// while a later detector succeeded, and the archive entries skipped
+	// because of Options.MaxEntrySize or Options.MaxEntryRatio.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Provenance maps the fields of the metadata to the stage they were
+	// read from (see Options.Provenance). EXIF tags and the results of
+	// routed stages are keyed as "Exif.<key>" and "Extra.<key>".
+	Provenance map[string]string `json:"provenance,omitempty"`
+
+	// exifPrecedence is the group precedence (Options.ExifPrecedence) used
+	// to resolve the tags present in several groups, or nil for
+	// DefaultExifPrecedence.
+	exifPrecedence []string
 }
 
 // FileTime represents various timestamps associated with a file.
 type FileTime struct {
 	// ModTime is the last modification time of the file.
-	ModTime time.Time
+	ModTime time.Time `json:"mod_time,omitempty"`
 
 	// AccessTime is the last access time of the file.
-	AccessTime time.Time
+	AccessTime time.Time `json:"access_time,omitempty"`
 
 	// ChangeTime is the last status change time of the file.
 	// This can differ from ModTime as it includes changes to permissions, ownership, etc.
-	ChangeTime time.Time
+	// On Windows, this is the NTFS change time, not the creation time; it is
+	// zero on file systems that do not record it (e.g., FAT).
+	ChangeTime time.Time `json:"change_time,omitempty"`
 
 	// BirthTime is the creation time of the file.
 	// Note: This may not be available on all file systems.
-	BirthTime time.Time
+	BirthTime time.Time `json:"birth_time,omitempty"`
 }
 
 // ExifMetadata is a map of EXIF metadata extracted from a file.
@@ -97,42 +705,354 @@ var (
 )
 
 // NewMetaExtractor creates a new MetaExtractor instance with the given options.
+// The slices of the options are copied, so modifying them afterwards does not
+// affect the extractor.
 func NewMetaExtractor(opts Options) *MetaExtractor {
 	if opts.TridMatches <= 0 {
 		opts.TridMatches = 5 // Default to 5 matches if not specified
 	}
 
-	exifToolOpts := []func(*exiftool.Exiftool) error{
-		exiftool.ExtractAllBinaryMetadata(),
-		exiftool.ExtractEmbedded(),
+	pureGo := opts.PureGo || pureGoBuild
+
+	initErr := checkHashes(opts.Hashes)
+	if initErr == nil {
+		initErr = checkProfiles(opts.Profiles)
+	}
+
+	var (
+		sandboxDir   string
+		prefix       []string
+		fileCmd      = "file"
+		ffprobeCmd   = "ffprobe"
+		mediaInfoCmd = "mediainfo"
+	)
+
+	if opts.FileCommandPath != "" {
+		fileCmd = opts.FileCommandPath
+	}
+	if opts.FFprobePath != "" {
+		ffprobeCmd = opts.FFprobePath
+	}
+	if opts.MediaInfoPath != "" {
+		mediaInfoCmd = opts.MediaInfoPath
+	}
+
+	if !pureGo {
+		if !opts.Limits.isZero() && !limitsSupported && initErr == nil {
+			initErr = ErrLimitsUnsupported
+		}
+
+		if opts.Sandbox.Enabled && initErr == nil {
+			var err error
+			if sandboxDir, err = prepareSandbox(opts.Sandbox); err != nil {
+				initErr = fmt.Errorf("error preparing sandbox: %w", err)
+			} else if prefix, err = sandboxCommand(opts.Sandbox, sandboxDir); err != nil {
+				initErr = fmt.Errorf("error preparing sandbox: %w", err)
+			}
+		}
+
+		if opts.TridPath == "" {
+			opts.TridPath = findTool("trid")
+		}
+
+		if opts.ExifToolPath == "" {
+			opts.ExifToolPath = findTool("exiftool")
+		}
+
+		if initErr == nil && (!opts.Limits.isZero() || opts.Sandbox.Enabled) {
+			var err error
+			if opts.TridPath, err = wrapCommand(opts.TridPath, opts.Limits, sandboxTool(prefix, opts.Sandbox, opts.TridPath, opts.TridDefs)); err != nil {
+				initErr = fmt.Errorf("error wrapping TrID: %w", err)
+			} else if opts.ExifToolPath, err = wrapCommand(opts.ExifToolPath, opts.Limits, sandboxTool(prefix, opts.Sandbox, opts.ExifToolPath)); err != nil {
+				initErr = fmt.Errorf("error wrapping ExifTool: %w", err)
+			} else if hasMagicDetector(opts.Detectors) || hasMagicBackend(opts.Backends) {
+				if fileCmd, err = wrapCommand(fileCmd, opts.Limits, sandboxTool(prefix, opts.Sandbox, fileCmd)); err != nil {
+					initErr = fmt.Errorf("error wrapping file: %w", err)
+				}
+			}
+			if initErr == nil && hasFFprobeBackend(opts.Backends) {
+				if ffprobeCmd, err = wrapCommand(ffprobeCmd, opts.Limits, sandboxTool(prefix, opts.Sandbox, ffprobeCmd)); err != nil {
+					initErr = fmt.Errorf("error wrapping ffprobe: %w", err)
+				}
+			}
+			if initErr == nil && hasMediaInfoBackend(opts.Backends) {
+				if mediaInfoCmd, err = wrapCommand(mediaInfoCmd, opts.Limits, sandboxTool(prefix, opts.Sandbox, mediaInfoCmd)); err != nil {
+					initErr = fmt.Errorf("error wrapping mediainfo: %w", err)
+				}
+			}
+		}
+	}
+
+	tridOpts := trid.Options{
+		Cmd:         opts.TridPath,
+		Definitions: opts.TridDefs,
+		Timeout:     opts.TridTimeout,
 	}
 
-	if opts.ExifToolPath != "" {
-		exifToolOpts = append(exifToolOpts, exiftool.SetExiftoolBinaryPath(opts.ExifToolPath))
+	if len(opts.Detectors) == 0 {
+		// The signature detector takes over when TrID is not installed or
+		// fails, so that the types are reported on any host.
+		opts.Detectors = []Detector{TridDetector, SignatureDetector}
+		if pureGo {
+			opts.Detectors = []Detector{SignatureDetector}
+		}
+	}
+
+	detectors := make([]Detector, 0, len(opts.Detectors))
+	for _, d := range opts.Detectors {
+		if pureGo && isExternalDetector(d) {
+			continue
+		}
+		if _, ok := d.(tridDetector); ok && opts.DisableTrid {
+			continue
+		}
+		detectors = append(detectors, bindDetector(d, tridOpts, opts.TridMatches, fileCmd))
+	}
+
+	rules, rulesErr := compileRules(opts.Rules)
+	if rulesErr != nil && initErr == nil {
+		initErr = rulesErr
+	}
+
+	compoundExts := slices.Clone(opts.CompoundExtensions)
+	if compoundExts == nil {
+		compoundExts = slices.Clone(DefaultCompoundExtensions)
+	}
+
+	stability := opts.Stability
+	if stability.Delay <= 0 {
+		stability.Delay = DefaultStabilityDelay
+	}
+
+	var manifests *manifestCache
+	if opts.VerifyManifests {
+		manifests = newManifestCache()
+	}
+
+	quarantineOpts := opts.Quarantine
+	quarantineOpts.Rules = slices.Clone(quarantineOpts.Rules)
+
+	scanOpts := scanOptions{
+		hashes:      slices.Clone(opts.Hashes),
+		entropy:     opts.Entropy,
+		sketch:      opts.Sketch,
+		headSize:    max(opts.SampleSize, 0),
+		tailSize:    max(opts.SampleSize, 0),
+		bufferSize:  opts.ReadBufferSize,
+		chunkSize:   opts.HashChunkSize,
+		hashWorkers: opts.HashWorkers,
+	}
+	if hasSignatureDetector(detectors) {
+		scanOpts.headSize = max(scanOpts.headSize, signatureHeadSize)
+	}
+
+	backends := opts.Backends
+	if len(backends) == 0 {
+		backends = DefaultBackends
+	}
+	if opts.DisableExif {
+		backends = slices.DeleteFunc(slices.Clone(backends), func(b Extractor) bool {
+			switch b.(type) {
+			case exifToolBackend, nativeExifBackend:
+				return true
+			}
+			return false
+		})
+	}
+
+	if ordered, err := orderBackends(backends); err != nil {
+		if initErr == nil {
+			initErr = err
+		}
+	} else {
+		backends = ordered
 	}
 
-	return &MetaExtractor{
-		trid: trid.NewTrid(trid.Options{
-			Cmd:         opts.TridPath,
-			Definitions: opts.TridDefs,
-			Timeout:     opts.TridTimeout,
-		}),
-		tridMatches:  opts.TridMatches,
-		exifToolOpts: exifToolOpts,
+	exifToolOpts, err := newExifToolOpts(opts.ExifKeys != ExifKeysDefault, !opts.SkipExifBinary, opts.ExifToolArgs)
+	if err != nil && initErr == nil {
+		initErr = err
 	}
+
+	me := &MetaExtractor{
+		tridPath:          opts.TridPath,
+		tridDefs:          opts.TridDefs,
+		tridTimeout:       opts.TridTimeout,
+		tridMatches:       opts.TridMatches,
+		exifToolPath:      opts.ExifToolPath,
+		exifToolOpts:      exifToolOpts,
+		exifTools:         newExifToolPool(opts.ExifToolPath, exifToolOpts, opts.Limits.CPUTime),
+		lifecycle:         newLifecycle(),
+		exifKeys:          opts.ExifKeys,
+		exifPrecedence:    slices.Clone(opts.ExifPrecedence),
+		maxExifSize:       max(opts.MaxExifSize, 0),
+		binaryStore:       opts.BinaryStore,
+		binaryThreshold:   opts.BinaryThreshold,
+		runID:             opts.RunID,
+		bestEffort:        opts.BestEffort,
+		strict:            opts.Strict,
+		failEmpty:         opts.FailEmpty,
+		placeholders:      opts.Placeholders,
+		deduplicate:       opts.Deduplicate,
+		stability:         stability,
+		audit:             newAuditLog(opts.Audit),
+		indexProps:        slices.Clone(opts.IndexProperties),
+		changeTracking:    opts.ChangeTracking,
+		nameForm:          opts.NameForm,
+		compoundExts:      compoundExts,
+		caseSensitiveExts: opts.CaseSensitiveExtensions,
+		timeOpts: timeOptions{
+			utc:         opts.UTC,
+			location:    opts.TimeLocation,
+			gpsTimeZone: opts.GPSTimeZone,
+			precedence:  slices.Clone(opts.ExifPrecedence),
+		},
+		createdAt: CreatedAtPolicy{
+			Precedence: slices.Clone(opts.CreatedAt.Precedence),
+			Earliest:   opts.CreatedAt.Earliest,
+		},
+		detectors:         detectors,
+		minConfidence:     opts.MinConfidence,
+		fuseDetectors:     opts.FuseDetectors,
+		fileCmd:           fileCmd,
+		ffprobeCmd:        ffprobeCmd,
+		mediaInfoCmd:      mediaInfoCmd,
+		pureGo:            pureGo,
+		scanOpts:          scanOpts,
+		manifests:         manifests,
+		imageChecks:       opts.ImageChecks,
+		imageAnalyzer:     opts.ImageAnalyzer,
+		contentClassifier: opts.ContentClassifier,
+		audioFingerprint:  opts.AudioFingerprint,
+		summary:           opts.Summary,
+		errorBudgets:      slices.Clone(opts.ErrorBudgets),
+		zipMetadata:       opts.ZipMetadata,
+		provenance:        opts.Provenance,
+		profiles:          maps.Clone(opts.Profiles),
+		sampleSize:        max(opts.SampleSize, 0),
+		routes:            slices.Clone(opts.Routes),
+		postProcessors:    slices.Clone(opts.PostProcessors),
+		skipExifBinary:    opts.SkipExifBinary,
+		concurrency:       max(opts.Concurrency, 1),
+		resultBuffer:      max(opts.ResultBuffer, 0),
+		spillDir:          opts.SpillDir,
+		progress:          opts.Progress,
+		quarantineOpts:    quarantineOpts,
+		rules:             rules,
+		rulesErr:          rulesErr,
+		passwords:         slices.Clone(opts.Passwords),
+		maxEntrySize:      entryOption(opts.MaxEntrySize, DefaultMaxEntrySize),
+		maxEntryRatio:     entryOption(opts.MaxEntryRatio, DefaultMaxEntryRatio),
+		sandboxDir:        sandboxDir,
+		initErr:           initErr,
+	}
+	me.backends = bindBackends(backends, me)
+
+	return me
 }
 
 // Extract examines the given file, extracting its metadata, determining its
 // type, and gathering EXIF information if available. It returns a Metadata
 // struct or an error.
 func (me *MetaExtractor) Extract(filePath string) (Metadata, error) {
-	var metadata Metadata
+	return me.ExtractContext(context.Background(), filePath)
+}
+
+// ExtractContext is like Extract, but aborts the extraction when ctx is
+// done, returning the metadata extracted so far and ctx.Err(). Running
+// external tools, such as TrID, ExifTool and the file command, are killed.
+func (me *MetaExtractor) ExtractContext(ctx context.Context, filePath string) (Metadata, error) {
+	return me.extractFile(ctx, filePath, filePath, nil)
+}
+
+// extractFile extracts the metadata of the file, reporting it under
+// auditPath in the audit log. If shared is not nil, the results of the
+// content stages are taken from it instead of reading the file.
+func (me *MetaExtractor) extractFile(ctx context.Context, filePath, auditPath string, shared *Metadata) (Metadata, error) {
+	ctx, release, err := me.lifecycle.bind(ctx)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer release()
+
+	budget := budgetFromContext(ctx)
+
+	var trace *stageTrace
+	if me.audit != nil || budget != nil {
+		trace = &stageTrace{}
+	}
+
+	start := time.Now()
+
+	for attempt := 0; ; attempt++ {
+		metadata, err := me.extract(ctx, filePath, attempt < me.stability.Retries, trace, shared)
+		tagProvenance(&metadata)
+		if errors.Is(err, errFileChanged) {
+			select {
+			case <-time.After(me.stability.Delay):
+				continue
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		err = closedErr(ctx, err)
+
+		if postErr := me.postProcess(auditPath, &metadata, trace); postErr != nil {
+			if err == nil {
+				err = postErr
+			} else {
+				err = errors.Join(err, postErr)
+			}
+		}
+
+		if me.audit != nil {
+			if auditErr := me.audit.write(auditPath, start, trace, metadata, err); auditErr != nil {
+				if err == nil {
+					err = auditErr
+				} else {
+					err = errors.Join(err, auditErr)
+				}
+			}
+		}
+
+		if ctx.Err() == nil {
+			budget.record(trace.failed())
+		}
+
+		return metadata, err
+	}
+}
+
+// extract performs a single extraction of the file, recording its stages in
+// trace. If retry is true, it returns errFileChanged when the file changes
+// during the extraction. If shared is not nil, the content stages are
+// skipped and their results are copied from it.
+func (me *MetaExtractor) extract(ctx context.Context, filePath string, retry bool, trace *stageTrace, shared *Metadata) (Metadata, error) {
+	metadata := Metadata{exifPrecedence: me.exifPrecedence}
+	if me.provenance {
+		metadata.Provenance = make(map[string]string)
+	}
 
 	if filePath == "" {
 		return metadata, ErrNoFileSpecified
 	}
 
-	fileInfo, err := os.Stat(filePath)
+	if me.initErr != nil {
+		return metadata, me.initErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return metadata, err
+	}
+
+	// The file is identified by the file command at most once per attempt,
+	// even if both MagicDetector and MagicBackend run.
+	ctx = withMagicCache(ctx)
+
+	// sysPath is used to access the file and to run the external tools;
+	// on Windows, it has the long-path prefix if the path exceeds MAX_PATH.
+	sysPath := longPath(filePath)
+
+	fileInfo, err := os.Stat(sysPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return metadata, ErrFileNotFound
@@ -140,50 +1060,426 @@ func (me *MetaExtractor) Extract(filePath string) (Metadata, error) {
 		return metadata, err
 	}
 
-	metadata.Name = filepath.Base(filePath)
-	metadata.Extension = strings.ToLower(filepath.Ext(filePath))
+	name := fileNameFromContext(ctx, filePath)
+	metadata.Name = me.nameForm.normalize(name)
+	if metadata.Name != name {
+		metadata.RawName = name
+	}
+	metadata.Extension, metadata.RawExtension = me.fileExtension(metadata.Name)
 	metadata.Size = fileInfo.Size()
 
-	if fileTime, err := getFileTimes(filePath); err == nil {
-		metadata.Time = fileTime
-	} else {
+	if fileInfo.Mode().IsRegular() && metadata.Size == 0 {
+		if me.failEmpty {
+			return metadata, ErrEmptyFile
+		}
+		metadata.Kind = KindEmpty
+	}
+
+	if isPlaceholder(filePath, fileInfo) {
+		if me.placeholders == PlaceholderFail {
+			return metadata, ErrPlaceholder
+		}
+
+		metadata.Placeholder = true
+		switch {
+		case me.placeholders == PlaceholderSkip:
+			metadata.Kind = KindPlaceholder
+		case isICloudStub(filePath):
+			metadata.Kind = KindPlaceholder
+			metadata.Warnings = append(metadata.Warnings, "iCloud Drive stub: the file is not downloaded")
+		}
+	}
+
+	if splitArchive, err := detectSplitArchive(filePath, splitDirCacheFromContext(ctx)); err == nil {
+		metadata.SplitArchive = splitArchive
+	} else if err := me.stageError(&metadata, nil, err); err != nil {
 		return metadata, err
 	}
 
-	if fileTypes, err := me.tridAnalysis(filePath); err == nil {
-		metadata.Types = fileTypes
+	if fileTime, err := getFileTimes(sysPath); err == nil {
+		metadata.Time = me.timeOpts.fileTime(fileTime)
+	} else if err := me.stageError(&metadata, nil, err); err != nil {
+		return metadata, err
+	}
 
-		if len(fileTypes) > 0 {
-			if strings.Contains(fileTypes[0].Extension, "/") {
-				metadata.ExtMismatch = true
-				es := strings.Split(strings.ReplaceAll(fileTypes[0].Extension, ".", ""), "/")
-				for _, e := range es {
-					if "."+e == metadata.Extension {
-						metadata.ExtMismatch = false
-						break
-					}
-				}
-			} else if metadata.Extension != fileTypes[0].Extension {
-				metadata.ExtMismatch = true
-			}
+	if me.changeTracking {
+		if ct, err := readChangeTracking(sysPath); err == nil {
+			metadata.ChangeTracking = ct
+		} else if err := me.stageError(&metadata, nil, err); err != nil {
+			return metadata, err
 		}
-	} else {
+	}
+
+	// macOS bundles are extracted as a single item; their content stages are
+	// skipped.
+	if isMacBundle(filePath, fileInfo) {
+		err := me.extractMacBundle(sysPath, &metadata)
 		return metadata, err
 	}
 
-	if exifData, err := me.extractExifData(filePath); err == nil {
-		metadata.Exif = exifData
-	} else if errors.Is(err, ErrNoMetadataExtracted) {
-		metadata.Exif = ExifMetadata{}
-	} else {
+	if len(me.indexProps) > 0 && !me.pureGo {
+		start := time.Now()
+		props, err := readIndexProperties(sysPath, me.indexProps)
+		trace.done("index", start)
+
+		if err == nil {
+			metadata.IndexProperties = props
+		} else if err := me.stageError(&metadata, trace, err); err != nil {
+			return metadata, err
+		}
+	}
+
+	// Reading the content of a placeholder would trigger its download, so
+	// only the stages working on the metadata run.
+	placeholder := metadata.Kind == KindPlaceholder
+
+	var detectErr error
+	switch {
+	case placeholder:
+	case shared != nil:
+		me.shareContent(&metadata, shared, filePath, fileInfo)
+	default:
+		if detectErr, err = me.extractContent(ctx, sysPath, fileInfo, retry, &metadata, trace); err != nil {
+			return metadata, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return metadata, err
+	}
+
+	if me.manifests != nil && !placeholder {
+		start := time.Now()
+		err := me.verifyManifests(filePath, &metadata)
+		trace.done("manifests", start)
+
+		if err := me.stageError(&metadata, trace, err); err != nil {
+			return metadata, err
+		}
+	}
+
+	if len(metadata.Types) > 0 {
+		metadata.ExtMismatch = me.extMismatch(metadata.Extension, metadata.RawExtension, metadata.Types[0])
+		if metadata.ExtMismatch {
+			metadata.SuggestedExtension, metadata.SuggestedName = me.suggestName(metadata.Name, metadata.Types[0])
+		}
+	}
+
+	if metadata.Kind == "" {
+		metadata.Kind = typeKind(metadata)
+	}
+
+	now := time.Now()
+	metadata.BestCreatedAt = bestCreatedAt(metadata, me.createdAt, now)
+	metadata.ContentCreated = contentCreated(metadata, me.createdAt, now)
+	metadata.GPS = parseGPS(metadata.Exif, metadata.exifPrecedence)
+	metadata.Normalized = normalizeMetadata(metadata)
+
+	if err := me.runRoutes(ctx, filePath, &metadata, trace); err != nil {
 		return metadata, err
 	}
 
-	return metadata, nil
+	if len(me.rules) > 0 {
+		start := time.Now()
+		err := me.applyRules(filePath, &metadata)
+		trace.done("rules", start)
+
+		if err != nil {
+			return metadata, err
+		}
+	}
+
+	if me.quarantineOpts.Dir != "" {
+		start := time.Now()
+		err := me.quarantine(filePath, &metadata)
+		trace.done("quarantine", start)
+
+		if err := me.stageError(&metadata, trace, err); err != nil {
+			return metadata, err
+		}
+	}
+
+	if me.strict && detectErr == nil && !placeholder {
+		if err := validate(metadata); err != nil {
+			return metadata, err
+		}
+	}
+
+	return metadata, detectErr
+}
+
+// extractContent runs the stages reading the file content: hashing, type
+// detection and EXIF extraction. detectErr is a type detection error that
+// does not abort the extraction (a TrID timeout). The stages running
+// external tools return ctx.Err() when ctx is done, even in best-effort mode.
+func (me *MetaExtractor) extractContent(ctx context.Context, sysPath string, fileInfo os.FileInfo, retry bool, metadata *Metadata, trace *stageTrace) (detectErr, err error) {
+	// The content is read once for all content-based stages.
+	var scan contentScan
+	if me.scanOpts.fullRead() || me.scanOpts.headSize > 0 {
+		start := time.Now()
+		scan, err = scanFile(sysPath, me.scanOpts)
+		trace.done("scan", start)
+
+		if err != nil {
+			if err := me.stageError(metadata, trace, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	me.setScan(metadata, scan)
+
+	toolPath, cleanup, err := me.sandboxFile(sysPath)
+	if err != nil {
+		if err := me.stageError(metadata, nil, err); err != nil {
+			return nil, err
+		}
+		// Without a sandboxed copy, the external tools are not run on the file.
+		cleanup = func() {}
+	}
+	defer cleanup()
+
+	// A TrID timeout does not abort the extraction; the remaining stages
+	// run and the error is returned with their results.
+	var detected detection
+	metadata.Exif = ExifMetadata{}
+	if metadata.Kind != KindEmpty {
+		for _, b := range me.backends {
+			var err error
+			switch b.(type) {
+			case tridBackend:
+				votes := detected.votes
+				detected, detectErr, err = me.detectStage(ctx, toolPath, scan.head, metadata, trace)
+				detected.votes = append(votes, detected.votes...)
+			case exifToolBackend:
+				err = me.exifStage(ctx, toolPath, metadata, trace)
+			case magicBackend:
+				err = me.magicStage(ctx, toolPath, metadata, &detected, trace)
+			case nativeExifBackend:
+				err = me.nativeExifStage(ctx, toolPath, metadata, trace)
+			case ffprobeBackend:
+				err = me.ffprobeStage(ctx, toolPath, metadata, trace)
+			case mediaInfoBackend:
+				err = me.mediaInfoStage(ctx, toolPath, metadata, trace)
+			default:
+				// Without a sandboxed copy, backends are not run on the file.
+				if toolPath != "" {
+					err = me.runBackend(ctx, b, toolPath, metadata, trace)
+				}
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if me.stability.Enabled && fileChanged(sysPath, fileInfo) {
+		if retry {
+			return nil, errFileChanged
+		}
+		metadata.Unstable = true
+	}
+
+	// The MIME type is taken before the tags are filtered.
+	exifMimeType := stringValue(exifValue(metadata.Exif, "MIMEType"))
+
+	metadata.Exif = filterExifTags(metadata.Exif, me.exifTags)
+
+	switch me.exifKeys {
+	case ExifKeysDeduplicate:
+		metadata.Exif = deduplicateExif(metadata.Exif, me.exifPrecedence)
+	case ExifKeysNested:
+		metadata.Exif = nestExif(metadata.Exif)
+	}
+
+	metadata.ExifTimes = me.timeOpts.exifTimes(metadata.Exif)
+
+	if me.imageChecks {
+		start := time.Now()
+		anomalies, err := checkImage(sysPath, metadata.Exif, me.exifPrecedence)
+		trace.done("imagecheck", start)
+
+		if err := me.stageError(metadata, trace, err); err != nil {
+			return nil, err
+		}
+		metadata.Anomalies = anomalies
+	}
+
+	if me.zipMetadata {
+		start := time.Now()
+		archive, err := readZipArchive(sysPath)
+		trace.done("zip", start)
+
+		if err := me.stageError(metadata, trace, err); err != nil {
+			return nil, err
+		}
+		metadata.Zip = archive
+	}
+
+	votes := detected.votes
+	if vote, ok := exifToolVote(metadata.Exif); ok {
+		votes = append(votes, vote)
+	}
+	metadata.BestType = fuseTypes(votes)
+	mimeType, mimeSource := contentMimeType(exifMimeType, *metadata, scan.head)
+	metadata.MimeType = mimeType
+	metadata.setSource("MimeType", mimeSource)
+
+	if err := me.analyzeStage(ctx, toolPath, metadata, trace); err != nil {
+		return nil, err
+	}
+
+	if err := me.classifyStage(ctx, toolPath, metadata, trace); err != nil {
+		return nil, err
+	}
+
+	if err := me.fingerprintStage(ctx, toolPath, metadata, trace); err != nil {
+		return nil, err
+	}
+
+	if err := me.summarizeStage(ctx, toolPath, metadata, trace); err != nil {
+		return nil, err
+	}
+
+	if me.binaryStore != nil {
+		err := externalizeBinary(metadata.Exif, me.binaryStore, me.binaryThreshold)
+		if err := me.stageError(metadata, nil, err); err != nil {
+			return nil, err
+		}
+	}
+
+	metadata.ExifDropped = limitExifSize(metadata.Exif, me.maxExifSize)
+
+	return detectErr, nil
+}
+
+// detectStage runs the detector chain on the file and sets the detected
+// types. A TrID timeout is returned as detectErr unless in best-effort mode.
+func (me *MetaExtractor) detectStage(ctx context.Context, toolPath string, head []byte, metadata *Metadata, trace *stageTrace) (detected detection, detectErr, err error) {
+	start := time.Now()
+	detected, detectErr = me.detectTypes(ctx, toolPath, head)
+	trace.done("detect", start)
+
+	if err := ctx.Err(); err != nil {
+		return detection{}, nil, err
+	}
+
+	if detectErr != nil && (me.bestEffort || !errors.Is(detectErr, ErrTridTimeout)) {
+		if err := me.stageError(metadata, trace, detectErr); err != nil {
+			return detection{}, nil, err
+		}
+		detectErr = nil
+	}
+
+	metadata.Types = withMimeTypes(detected.types)
+	metadata.Detector = detected.detector
+	metadata.Warnings = append(metadata.Warnings, detected.warnings...)
+
+	return detected, detectErr, nil
+}
+
+// exifStage extracts the EXIF metadata of the file with ExifTool, retrying
+// with Options.Passwords if the file is password protected. Password-protected
+// OOXML documents are decrypted and the decrypted package is processed.
+func (me *MetaExtractor) exifStage(ctx context.Context, toolPath string, metadata *Metadata, trace *stageTrace) error {
+	if me.pureGo || me.skipExif || toolPath == "" {
+		return nil
+	}
+
+	start := time.Now()
+	exifData, err := me.extractExifData(ctx, toolPath)
+	trace.done("exif", start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err == nil {
+		maps.Copy(metadata.Exif, exifData)
+		metadata.addExifBackend(SourceExifTool)
+	} else if !errors.Is(err, ErrNoMetadataExtracted) {
+		if err := me.stageError(metadata, trace, err); err != nil {
+			return err
+		}
+	}
+
+	if len(me.passwords) > 0 && isPasswordProtected(metadata.Exif) {
+		start := time.Now()
+		exifData, password, err := me.extractProtectedExifData(ctx, toolPath)
+		trace.done("password", start)
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err == nil {
+			metadata.Exif = exifData
+			metadata.Password = password
+		} else if !errors.Is(err, ErrNoValidPassword) {
+			if err := me.stageError(metadata, trace, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(me.passwords) > 0 && metadata.Password == "" {
+		start := time.Now()
+		exifData, password, err := me.extractProtectedOfficeExifData(ctx, toolPath, metadata.Time)
+		trace.done("password", start)
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err == nil && exifData != nil {
+			metadata.Exif = exifData
+			metadata.Password = password
+		} else if err != nil && !errors.Is(err, ErrNoValidPassword) {
+			if err := me.stageError(metadata, trace, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// setScan sets the results of the content scan in metadata.
+func (me *MetaExtractor) setScan(metadata *Metadata, scan contentScan) {
+	metadata.Hashes = scan.hashes
+	metadata.Entropy = scan.entropy
+	metadata.Sketch = scan.sketch
+
+	if me.sampleSize > 0 {
+		metadata.Head = append([]byte(nil), scan.head[:min(len(scan.head), me.sampleSize)]...)
+		metadata.Tail = scan.tail
+	}
+}
+
+// stageError returns the error of a stage. In best-effort mode, the error is
+// recorded in the warnings of the metadata instead, and nil is returned. If
+// trace is not nil, the stage it recorded last is marked as failed, so
+// callers pass it right after recording the failed stage.
+func (me *MetaExtractor) stageError(metadata *Metadata, trace *stageTrace, err error) error {
+	if err != nil {
+		trace.fail()
+	}
+
+	if err == nil || !me.bestEffort {
+		return err
+	}
+
+	metadata.Warnings = append(metadata.Warnings, err.Error())
+	return nil
 }
 
 // getFileTimes retrieves various timestamps associated with the file.
 func getFileTimes(filePath string) (FileTime, error) {
+	if ft, ok := nativeFileTimes(filePath); ok {
+		return ft, nil
+	}
+
 	t, err := times.Stat(filePath)
 	if err != nil {
 		return FileTime{}, err
@@ -207,29 +1503,52 @@ func getFileTimes(filePath string) (FileTime, error) {
 	}, nil
 }
 
-// tridAnalysis performs file type analysis using TrID.
-// It returns a slice of possible file types, sorted by likelihood.
-func (me *MetaExtractor) tridAnalysis(filePath string) ([]trid.FileType, error) {
-	return me.trid.Scan(filePath, me.tridMatches)
+// extMismatch reports whether the extension differs from the extension(s) of
+// the given file type. TrID reports alternatives as ".jpg/.jpeg"; matching any
+// of them is sufficient. Compound extensions match their last part and vice
+// versa (e.g., ".tar.gz" matches ".gz").
+func extMismatch(ext string, fileType trid.FileType) bool {
+	exts := typeExtensions(fileType)
+	if len(exts) == 0 {
+		return ext != ""
+	}
+
+	for _, e := range exts {
+		if e == ext || ext != "" && (strings.HasSuffix(ext, e) || strings.HasSuffix(e, ext)) {
+			return false
+		}
+	}
+
+	return true
 }
 
-// extractExifData extracts EXIF metadata from the file using ExifTool.
-// It returns a map of metadata fields or an error if extraction fails.
-func (me *MetaExtractor) extractExifData(filePath string) (ExifMetadata, error) {
-	et, err := exiftool.NewExiftool(me.exifToolOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("error initializing ExifTool: %v", err)
+// suggestName returns the extension of the given file type and the file name
+// with its extension replaced accordingly. Compound extensions (e.g.,
+// ".tar.gz") are replaced as a whole, unless the file type is a compressor
+// (see isCompressorExt), in which case only the compression suffix is
+// replaced ("archive.tar.bz2" -> "archive.tar.gz"). When TrID reports
+// alternatives (e.g., ".jpg/.jpeg"), the first one is used.
+func (me *MetaExtractor) suggestName(name string, fileType trid.FileType) (string, string) {
+	ext := strings.TrimSpace(strings.Split(fileType.Extension, "/")[0])
+	if ext == "" {
+		return "", ""
 	}
-	defer et.Close()
 
-	fileInfos := et.ExtractMetadata(filePath)
-	if len(fileInfos) == 0 {
-		return nil, ErrNoMetadataExtracted
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
 	}
+	ext = strings.ToLower(ext)
 
-	if fileInfos[0].Err != nil {
-		return nil, fmt.Errorf("error extracting metadata: %v", fileInfos[0].Err)
+	_, raw := me.fileExtension(name)
+	if raw != filepath.Ext(name) && me.isCompressorExt(ext) {
+		raw = filepath.Ext(name)
 	}
 
-	return fileInfos[0].Fields, nil
+	return ext, strings.TrimSuffix(name, raw) + ext
+}
+
+// extractExifData extracts EXIF metadata from the file using ExifTool.
+// It returns a map of metadata fields or an error if extraction fails.
+func (me *MetaExtractor) extractExifData(ctx context.Context, filePath string) (ExifMetadata, error) {
+	return me.exifTools.extract(ctx, filePath)
 }
@@ -1,15 +1,22 @@
 package metaextractor
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/attilabuti/trid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestMetaExtractor(t *testing.T) {
+	if pureGoBuild {
+		t.Skip("external tools are disabled in pure-Go builds")
+	}
+
 	// Setup MetaExtractor
 	extractor := NewMetaExtractor(Options{
 		//TridPath:     "/usr/bin/trid", // Adjust this path as needed
@@ -46,6 +53,7 @@ func TestMetaExtractor(t *testing.T) {
 				assert.Equal(t, "sample.mp3", metadata.Name)
 				assert.Equal(t, ".mp3", metadata.Extension)
 				assert.False(t, metadata.ExtMismatch)
+				assert.Empty(t, metadata.SuggestedName)
 				assert.NotEmpty(t, metadata.Types)
 				assert.NotEmpty(t, metadata.Exif)
 				assert.Contains(t, metadata.Exif, "FileType")
@@ -60,6 +68,8 @@ func TestMetaExtractor(t *testing.T) {
 				assert.Equal(t, "sample.doc", metadata.Name)
 				assert.Equal(t, ".doc", metadata.Extension)
 				assert.True(t, metadata.ExtMismatch)
+				assert.Equal(t, ".pdf", metadata.SuggestedExtension)
+				assert.Equal(t, "sample.pdf", metadata.SuggestedName)
 				assert.NotEmpty(t, metadata.Types)
 				assert.NotEmpty(t, metadata.Exif)
 				assert.Contains(t, metadata.Exif, "FileType")
@@ -91,6 +101,167 @@ func TestMetaExtractor_Errors(t *testing.T) {
 	})
 }
 
+func TestMetaExtractor_PureGo(t *testing.T) {
+	extractor := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"sha256"}})
+
+	metadata, err := extractor.Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "sample.doc", metadata.Name)
+	require.Len(t, metadata.Types, 1)
+	assert.Equal(t, ".pdf", metadata.Types[0].Extension)
+	assert.True(t, metadata.ExtMismatch)
+	assert.Equal(t, "sample.pdf", metadata.SuggestedName)
+	assert.Empty(t, metadata.Exif)
+	assert.Len(t, metadata.Hashes["sha256"], 64)
+	assert.False(t, metadata.Time.ModTime.IsZero())
+}
+
+func TestMetaExtractor_BestEffort(t *testing.T) {
+	failing := DetectorFunc{
+		DetectorName: "failing",
+		Fn: func(string) ([]trid.FileType, error) {
+			return nil, errors.New("detector failed")
+		},
+	}
+
+	opts := Options{
+		PureGo:    true,
+		Detectors: []Detector{failing},
+		Routes: []Route{{
+			Match: func(Metadata) bool { return true },
+			Stages: []Stage{
+				StageFunc{StageName: "broken", Fn: func(context.Context, string, *Metadata) (interface{}, error) {
+					return nil, errors.New("stage failed")
+				}},
+				StageFunc{StageName: "ok", Fn: func(context.Context, string, *Metadata) (interface{}, error) {
+					return "done", nil
+				}},
+			},
+		}},
+		Rules: []Rule{{Label: "any", Condition: "Size >= 0"}},
+	}
+
+	path := filepath.Join("testdata", "sample.doc")
+
+	t.Run("Fail Fast", func(t *testing.T) {
+		_, err := NewMetaExtractor(opts).Extract(path)
+		assert.ErrorContains(t, err, "detector failed")
+	})
+
+	t.Run("Best Effort", func(t *testing.T) {
+		opts := opts
+		opts.BestEffort = true
+
+		metadata, err := NewMetaExtractor(opts).Extract(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"detector failed", "error running stage broken: stage failed"}, metadata.Warnings)
+		assert.Empty(t, metadata.Types)
+		assert.Equal(t, "done", metadata.Extra["ok"])
+		assert.Equal(t, []string{"any"}, metadata.Labels)
+	})
+
+	t.Run("File Not Found", func(t *testing.T) {
+		_, err := NewMetaExtractor(Options{PureGo: true, BestEffort: true}).Extract("nonexistent_file")
+		assert.ErrorIs(t, err, ErrFileNotFound)
+	})
+}
+
+func TestMetaExtractor_EmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zero.jpg")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+
+	t.Run("Empty Kind", func(t *testing.T) {
+		// TrID is not run on empty files, so the result does not depend on
+		// the external tools.
+		metadata, err := NewMetaExtractor(Options{Hashes: []string{"md5"}}).Extract(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, KindEmpty, metadata.Kind)
+		assert.Empty(t, metadata.Types)
+		assert.Empty(t, metadata.Exif)
+		assert.False(t, metadata.ExtMismatch)
+		assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e", metadata.Hashes["md5"])
+	})
+
+	t.Run("Fail Empty", func(t *testing.T) {
+		_, err := NewMetaExtractor(Options{PureGo: true, FailEmpty: true}).Extract(path)
+		assert.ErrorIs(t, err, ErrEmptyFile)
+	})
+
+	t.Run("Non-Empty", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{PureGo: true}).Extract(filepath.Join("testdata", "sample.doc"))
+		require.NoError(t, err)
+		assert.Equal(t, KindDocument, metadata.Kind)
+	})
+}
+
+func TestMetaExtractor_LargeFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("creates a sparse file of more than 4 GiB")
+	}
+
+	const size = 5<<30 + 1
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "disk.img")
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("\x7fELF"))
+	require.NoError(t, err)
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		t.Skipf("sparse files not supported: %v", err)
+	}
+	require.NoError(t, f.Close())
+
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	metadata, err := me.Extract(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), metadata.Size)
+	assert.Equal(t, KindExecutable, metadata.Kind)
+
+	results, err := me.ExtractDir(dir, WalkOptions{MinSize: 4 << 30})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, int64(size), results[0].Metadata.Size)
+
+	results, err = me.ExtractDir(dir, WalkOptions{MaxSize: 4 << 30})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestSuggestName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		fileType trid.FileType
+		ext      string
+		newName  string
+	}{
+		{"Single Extension", "sample.doc", trid.FileType{Extension: ".pdf"}, ".pdf", "sample.pdf"},
+		{"Alternatives", "photo.png", trid.FileType{Extension: ".jpg/.jpeg"}, ".jpg", "photo.jpg"},
+		{"No Extension", "README", trid.FileType{Extension: ".txt"}, ".txt", "README.txt"},
+		{"Multiple Dots", "report.v2.txt", trid.FileType{Extension: ".pdf"}, ".pdf", "report.v2.pdf"},
+		{"Compound Extension", "image.nii.gz", trid.FileType{Extension: ".zip"}, ".zip", "image.zip"},
+		{"Compressor", "archive.tar.bz2", trid.FileType{Extension: ".gz"}, ".gz", "archive.tar.gz"},
+		{"Unknown Extension", "file.bin", trid.FileType{}, "", ""},
+	}
+
+	me := NewMetaExtractor(Options{})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ext, newName := me.suggestName(tc.fileName, tc.fileType)
+			assert.Equal(t, tc.ext, ext)
+			assert.Equal(t, tc.newName, newName)
+		})
+	}
+}
+
 func TestGetFileTimes(t *testing.T) {
 	tempFile, err := os.CreateTemp("", "test_file_times")
 	require.NoError(t, err)
@@ -0,0 +1,386 @@
+// Package metaextractortest provides a fake metaextractor.Interface and
+// fixture builders, so that code using the metaextractor package can be
+// unit-tested without TrID and ExifTool installed.
+package metaextractortest
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/attilabuti/metaextractor"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Fake is an in-memory metaextractor.Interface. It returns the metadata and
+// errors registered for a path and never reads the file system. It is safe
+// for concurrent use.
+type Fake struct {
+	mu     sync.Mutex
+	files  map[string]metaextractor.Metadata
+	errs   map[string]error
+	calls  []string
+	closed bool
+}
+
+var _ metaextractor.Interface = (*Fake)(nil)
+
+// NewFake returns a fake without any registered files.
+func NewFake() *Fake {
+	return &Fake{
+		files: make(map[string]metaextractor.Metadata),
+		errs:  make(map[string]error),
+	}
+}
+
+// Add registers the metadata returned for the path.
+func (f *Fake) Add(path string, metadata metaextractor.Metadata) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.files[filepath.Clean(path)] = metadata
+	return f
+}
+
+// AddError registers the error returned for the path. If metadata is also
+// registered for the path, it is returned together with the error.
+func (f *Fake) AddError(path string, err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.errs[filepath.Clean(path)] = err
+	return f
+}
+
+// Calls returns the paths metadata was extracted from, in order, including
+// the ones extracted by the other methods (e.g., ExtractDir and ExtractTar).
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.calls...)
+}
+
+// Extract returns the metadata and error registered for the path. It returns
+// metaextractor.ErrFileNotFound for unknown paths, and
+// metaextractor.ErrClosed once the fake is closed.
+func (f *Fake) Extract(filePath string) (metaextractor.Metadata, error) {
+	if filePath == "" {
+		return metaextractor.Metadata{}, metaextractor.ErrNoFileSpecified
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return metaextractor.Metadata{}, metaextractor.ErrClosed
+	}
+
+	f.calls = append(f.calls, filePath)
+
+	key := filepath.Clean(filePath)
+	metadata, found := f.files[key]
+	if err, ok := f.errs[key]; ok {
+		return metadata, err
+	}
+
+	if !found {
+		return metaextractor.Metadata{}, metaextractor.ErrFileNotFound
+	}
+
+	return metadata, nil
+}
+
+// ExtractContext is like Extract, but returns ctx.Err() if ctx is done.
+func (f *Fake) ExtractContext(ctx context.Context, filePath string) (metaextractor.Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return metaextractor.Metadata{}, err
+	}
+
+	return f.Extract(filePath)
+}
+
+// ExtractBatch extracts metadata from each of the given paths.
+func (f *Fake) ExtractBatch(paths []string) []metaextractor.Result {
+	results := make([]metaextractor.Result, 0, len(paths))
+	for _, p := range paths {
+		metadata, err := f.Extract(p)
+		results = append(results, metaextractor.Result{Path: p, Metadata: metadata, Err: err})
+	}
+
+	return results
+}
+
+// ExtractStream extracts metadata from each path received from paths and
+// sends the results in order. The channel is closed once paths is closed or
+// ctx is done.
+func (f *Fake) ExtractStream(ctx context.Context, paths <-chan string) <-chan metaextractor.Result {
+	results := make(chan metaextractor.Result)
+
+	go func() {
+		defer close(results)
+
+		for {
+			var p string
+			select {
+			case <-ctx.Done():
+				return
+			case path, ok := <-paths:
+				if !ok {
+					return
+				}
+				p = path
+			}
+
+			metadata, err := f.ExtractContext(ctx, p)
+			select {
+			case <-ctx.Done():
+				return
+			case results <- metaextractor.Result{Path: p, Metadata: metadata, Err: err}:
+			}
+		}
+	}()
+
+	return results
+}
+
+// ExtractReader returns the metadata and error registered for name, or for
+// metaextractor.StreamPath if name is empty. The content read from r is
+// ignored.
+func (f *Fake) ExtractReader(r io.Reader, name string) (metaextractor.Metadata, error) {
+	if name == "" {
+		name = metaextractor.StreamPath
+	}
+
+	return f.Extract(name)
+}
+
+// ExtractDir extracts metadata from every registered path below root, in
+// lexical order. The filters of opts are not applied. It returns
+// metaextractor.ErrFileNotFound if no path is registered below root.
+func (f *Fake) ExtractDir(root string, opts metaextractor.WalkOptions) ([]metaextractor.Result, error) {
+	if root == "" {
+		return nil, metaextractor.ErrNoFileSpecified
+	}
+
+	paths := f.pathsBelow(root)
+	if len(paths) == 0 {
+		return nil, metaextractor.ErrFileNotFound
+	}
+
+	return f.ExtractBatch(paths), nil
+}
+
+// ExtractFS extracts metadata from every registered path below root, which
+// is slash-separated as in fsys, and reports the results under
+// slash-separated paths. fsys is not read and the filters of opts are not
+// applied. It returns metaextractor.ErrFileNotFound if no path is registered
+// below root.
+func (f *Fake) ExtractFS(fsys fs.FS, root string, opts metaextractor.WalkOptions) ([]metaextractor.Result, error) {
+	if root == "" {
+		root = "."
+	}
+
+	paths := f.pathsBelow(filepath.FromSlash(root))
+	if len(paths) == 0 {
+		return nil, metaextractor.ErrFileNotFound
+	}
+
+	results := f.ExtractBatch(paths)
+	for i := range results {
+		results[i].Path = filepath.ToSlash(results[i].Path)
+	}
+
+	return results, nil
+}
+
+// ExtractDirMetadata derives the metadata of a directory from the metadata
+// registered below it (Size and Time.ModTime). It returns
+// metaextractor.ErrFileNotFound if no path is registered below dirPath.
+func (f *Fake) ExtractDirMetadata(dirPath string) (metaextractor.DirMetadata, error) {
+	if dirPath == "" {
+		return metaextractor.DirMetadata{}, metaextractor.ErrNoFileSpecified
+	}
+
+	paths := f.pathsBelow(dirPath)
+	if len(paths) == 0 {
+		return metaextractor.DirMetadata{}, metaextractor.ErrFileNotFound
+	}
+
+	root := filepath.Clean(dirPath)
+	dir := metaextractor.DirMetadata{Name: filepath.Base(root), Path: dirPath}
+
+	subdirs := make(map[string]bool)
+	for _, p := range paths {
+		if p == root {
+			continue
+		}
+
+		// Every directory between the root and the file is a subdirectory.
+		for d := filepath.Dir(p); d != root && d != "."; d = filepath.Dir(d) {
+			if !subdirs[d] {
+				subdirs[d] = true
+				if filepath.Dir(d) == root {
+					dir.Dirs++
+				}
+			}
+		}
+
+		if filepath.Dir(p) == root {
+			dir.Files++
+		}
+
+		f.mu.Lock()
+		metadata := f.files[p]
+		f.mu.Unlock()
+
+		dir.TotalFiles++
+		dir.TotalSize += metadata.Size
+
+		child := metaextractor.DirChild{Path: p, ModTime: metadata.Time.ModTime}
+		if dir.Newest == nil || child.ModTime.After(dir.Newest.ModTime) {
+			newest := child
+			dir.Newest = &newest
+		}
+		if dir.Oldest == nil || child.ModTime.Before(dir.Oldest.ModTime) {
+			oldest := child
+			dir.Oldest = &oldest
+		}
+	}
+	dir.TotalDirs = len(subdirs)
+
+	return dir, nil
+}
+
+// ExtractTar reads the entries of a TAR stream, which may be
+// gzip-compressed, and passes the metadata registered for the entry names to
+// fn. The content of the entries is ignored.
+func (f *Fake) ExtractTar(r io.Reader, fn func(metaextractor.Result)) error {
+	br := bufio.NewReader(r)
+
+	r = br
+	if head, _ := br.Peek(len(gzipMagic)); bytes.Equal(head, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		metadata, err := f.Extract(hdr.Name)
+		fn(metaextractor.Result{Path: hdr.Name, Metadata: metadata, Err: err})
+	}
+}
+
+// FixExtension reports a rename for every registered path below path whose
+// metadata has ExtMismatch and SuggestedName set. Unless dryRun is true, the
+// metadata is moved to the new path, updated as if the file was renamed.
+func (f *Fake) FixExtension(path string, dryRun bool) ([]metaextractor.Rename, error) {
+	if path == "" {
+		return nil, metaextractor.ErrNoFileSpecified
+	}
+
+	paths := f.pathsBelow(path)
+	if len(paths) == 0 {
+		return nil, metaextractor.ErrFileNotFound
+	}
+
+	var renames []metaextractor.Rename
+	for _, p := range paths {
+		metadata, err := f.Extract(p)
+		if err != nil {
+			renames = append(renames, metaextractor.Rename{OldPath: p, Err: err})
+			continue
+		}
+
+		if !metadata.ExtMismatch || metadata.SuggestedName == "" {
+			continue
+		}
+
+		newPath := filepath.Join(filepath.Dir(p), metadata.SuggestedName)
+		renames = append(renames, metaextractor.Rename{OldPath: p, NewPath: newPath})
+
+		if dryRun {
+			continue
+		}
+
+		metadata.Name = metadata.SuggestedName
+		metadata.Extension = metadata.SuggestedExtension
+		metadata.ExtMismatch = false
+		metadata.SuggestedName = ""
+		metadata.SuggestedExtension = ""
+
+		f.mu.Lock()
+		delete(f.files, filepath.Clean(p))
+		f.files[filepath.Clean(newPath)] = metadata
+		f.mu.Unlock()
+	}
+
+	return renames, nil
+}
+
+// Close makes later extractions fail with metaextractor.ErrClosed. It is
+// idempotent.
+func (f *Fake) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return nil
+}
+
+// pathsBelow returns the registered paths equal to or below root, sorted.
+func (f *Fake) pathsBelow(root string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	root = filepath.Clean(root)
+	prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+
+	below := func(p string) bool {
+		if root == "." {
+			return !filepath.IsAbs(p)
+		}
+		return p == root || strings.HasPrefix(p, prefix)
+	}
+
+	var paths []string
+	for p := range f.files {
+		if below(p) {
+			paths = append(paths, p)
+		}
+	}
+	for p := range f.errs {
+		if _, ok := f.files[p]; !ok && below(p) {
+			paths = append(paths, p)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,213 @@
+package metaextractortest
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/attilabuti/metaextractor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake_Extract(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	fake := NewFake().
+		Add(filepath.Join("photos", "a.jpg"), JPEG("a.jpg").Size(100).Build()).
+		AddError(filepath.Join("photos", "b.jpg"), errBroken)
+
+	var extractor metaextractor.Interface = fake
+
+	metadata, err := extractor.Extract(filepath.Join("photos", "a.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), metadata.Size)
+
+	_, err = extractor.Extract(filepath.Join("photos", "b.jpg"))
+	assert.ErrorIs(t, err, errBroken)
+
+	_, err = extractor.Extract("missing.jpg")
+	assert.ErrorIs(t, err, metaextractor.ErrFileNotFound)
+
+	_, err = extractor.Extract("")
+	assert.ErrorIs(t, err, metaextractor.ErrNoFileSpecified)
+
+	assert.Equal(t, []string{filepath.Join("photos", "a.jpg"), filepath.Join("photos", "b.jpg"), "missing.jpg"}, fake.Calls())
+}
+
+func TestFake_ExtractContext(t *testing.T) {
+	fake := NewFake().Add("a.jpg", JPEG("a.jpg").Build())
+
+	_, err := fake.ExtractContext(context.Background(), "a.jpg")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fake.ExtractContext(ctx, "a.jpg")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFake_ExtractStream(t *testing.T) {
+	fake := NewFake().
+		Add("a.jpg", JPEG("a.jpg").Build()).
+		Add("b.png", PNG("b.png").Build())
+
+	paths := make(chan string, 3)
+	paths <- "a.jpg"
+	paths <- "b.png"
+	paths <- "missing.jpg"
+	close(paths)
+
+	var results []metaextractor.Result
+	for r := range fake.ExtractStream(context.Background(), paths) {
+		results = append(results, r)
+	}
+
+	require.Len(t, results, 3)
+	assert.Equal(t, "a.jpg", results[0].Metadata.Name)
+	assert.Equal(t, "b.png", results[1].Metadata.Name)
+	assert.ErrorIs(t, results[2].Err, metaextractor.ErrFileNotFound)
+}
+
+func TestFake_ExtractReader(t *testing.T) {
+	fake := NewFake().
+		Add("upload.pdf", PDF("upload.pdf").Build()).
+		Add(metaextractor.StreamPath, PNG("").Build())
+
+	metadata, err := fake.ExtractReader(strings.NewReader("ignored"), "upload.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "upload.pdf", metadata.Name)
+
+	metadata, err = fake.ExtractReader(strings.NewReader("ignored"), "")
+	require.NoError(t, err)
+	assert.Equal(t, ".png", metadata.BestType.Extension)
+}
+
+func TestFake_ExtractFS(t *testing.T) {
+	fake := NewFake().
+		Add(filepath.Join("share", "docs", "a.pdf"), PDF("a.pdf").Build()).
+		Add(filepath.Join("share", "b.pdf"), PDF("b.pdf").Build())
+
+	results, err := fake.ExtractFS(fstest.MapFS{}, "share/docs", metaextractor.WalkOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "share/docs/a.pdf", results[0].Path)
+
+	results, err = fake.ExtractFS(fstest.MapFS{}, "", metaextractor.WalkOptions{})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	_, err = fake.ExtractFS(fstest.MapFS{}, "other", metaextractor.WalkOptions{})
+	assert.ErrorIs(t, err, metaextractor.ErrFileNotFound)
+}
+
+func TestFake_Close(t *testing.T) {
+	fake := NewFake().Add("a.jpg", JPEG("a.jpg").Build())
+
+	require.NoError(t, fake.Close())
+	require.NoError(t, fake.Close())
+
+	_, err := fake.Extract("a.jpg")
+	assert.ErrorIs(t, err, metaextractor.ErrClosed)
+}
+
+func TestFake_ExtractDir(t *testing.T) {
+	fake := NewFake().
+		Add(filepath.Join("docs", "b.pdf"), PDF("b.pdf").Build()).
+		Add(filepath.Join("docs", "a.pdf"), PDF("a.pdf").Build()).
+		Add(filepath.Join("docsx", "c.pdf"), PDF("c.pdf").Build())
+
+	results, err := fake.ExtractDir("docs", metaextractor.WalkOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, filepath.Join("docs", "a.pdf"), results[0].Path)
+	assert.Equal(t, filepath.Join("docs", "b.pdf"), results[1].Path)
+
+	results, err = fake.ExtractDir(".", metaextractor.WalkOptions{})
+	require.NoError(t, err)
+	assert.Len(t, results, 3)
+
+	_, err = fake.ExtractDir("other", metaextractor.WalkOptions{})
+	assert.ErrorIs(t, err, metaextractor.ErrFileNotFound)
+}
+
+func TestFake_ExtractDirMetadata(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fake := NewFake().
+		Add(filepath.Join("docs", "a.pdf"), PDF("a.pdf").Size(10).ModTime(recent).Build()).
+		Add(filepath.Join("docs", "2020", "q1", "b.pdf"), PDF("b.pdf").Size(20).ModTime(old).Build())
+
+	dir, err := fake.ExtractDirMetadata("docs")
+	require.NoError(t, err)
+
+	assert.Equal(t, "docs", dir.Name)
+	assert.Equal(t, 1, dir.Files)
+	assert.Equal(t, 1, dir.Dirs)
+	assert.Equal(t, 2, dir.TotalFiles)
+	assert.Equal(t, 2, dir.TotalDirs)
+	assert.Equal(t, int64(30), dir.TotalSize)
+	assert.Equal(t, filepath.Join("docs", "a.pdf"), dir.Newest.Path)
+	assert.Equal(t, old, dir.Oldest.ModTime)
+
+	_, err = fake.ExtractDirMetadata("other")
+	assert.ErrorIs(t, err, metaextractor.ErrFileNotFound)
+}
+
+func TestFake_ExtractTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"a.png", "missing.png"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte("x"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	fake := NewFake().Add("a.png", PNG("a.png").Build())
+
+	var results []metaextractor.Result
+	require.NoError(t, fake.ExtractTar(&buf, func(r metaextractor.Result) {
+		results = append(results, r)
+	}))
+
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "a.png", results[0].Metadata.Name)
+	assert.ErrorIs(t, results[1].Err, metaextractor.ErrFileNotFound)
+}
+
+func TestFake_FixExtension(t *testing.T) {
+	oldPath := filepath.Join("dir", "invoice.pdf")
+	newPath := filepath.Join("dir", "invoice.exe")
+
+	fake := NewFake().
+		Add(oldPath, Executable("invoice.pdf").Build()).
+		Add(filepath.Join("dir", "photo.jpg"), JPEG("photo.jpg").Build())
+
+	renames, err := fake.FixExtension("dir", true)
+	require.NoError(t, err)
+	assert.Equal(t, []metaextractor.Rename{{OldPath: oldPath, NewPath: newPath}}, renames)
+
+	_, err = fake.Extract(oldPath)
+	require.NoError(t, err)
+
+	renames, err = fake.FixExtension("dir", false)
+	require.NoError(t, err)
+	require.Len(t, renames, 1)
+
+	_, err = fake.Extract(oldPath)
+	assert.ErrorIs(t, err, metaextractor.ErrFileNotFound)
+
+	metadata, err := fake.Extract(newPath)
+	require.NoError(t, err)
+	assert.Equal(t, "invoice.exe", metadata.Name)
+	assert.False(t, metadata.ExtMismatch)
+}
@@ -0,0 +1,205 @@
+package metaextractortest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/metaextractor"
+	"github.com/attilabuti/trid"
+)
+
+// MetadataBuilder builds metadata fixtures for the fake.
+type MetadataBuilder struct {
+	metadata metaextractor.Metadata
+}
+
+// NewMetadata returns a builder for the metadata of a file with the given
+// name. Name and Extension are derived from it, recognizing
+// metaextractor.DefaultCompoundExtensions.
+func NewMetadata(name string) *MetadataBuilder {
+	raw := rawExtension(name)
+	return &MetadataBuilder{metadata: metaextractor.Metadata{
+		Name:         name,
+		Extension:    strings.ToLower(raw),
+		RawExtension: raw,
+		Exif:         metaextractor.ExifMetadata{},
+	}}
+}
+
+// rawExtension returns the extension of the file name as it appears in the
+// name, recognizing metaextractor.DefaultCompoundExtensions.
+func rawExtension(name string) string {
+	lower := strings.ToLower(name)
+	for _, compound := range metaextractor.DefaultCompoundExtensions {
+		if len(lower) > len(compound) && strings.HasSuffix(lower, compound) {
+			return name[len(name)-len(compound):]
+		}
+	}
+
+	return filepath.Ext(name)
+}
+
+// suggestedName returns the file name with its extension replaced by ext as
+// Extract would: compound extensions are replaced as a whole, unless ext is
+// their compression suffix.
+func suggestedName(name, ext string) string {
+	raw := rawExtension(name)
+	if raw != filepath.Ext(name) {
+		for _, compound := range metaextractor.DefaultCompoundExtensions {
+			if filepath.Ext(compound) == strings.ToLower(ext) {
+				raw = filepath.Ext(name)
+				break
+			}
+		}
+	}
+
+	return strings.TrimSuffix(name, raw) + ext
+}
+
+// JPEG returns a builder for a JPEG image.
+func JPEG(name string) *MetadataBuilder {
+	return NewMetadata(name).
+		Type(".jpg/.jpeg", "image/jpeg", "JPEG Bitmap", 100).
+		Exif("FileType", "JPEG").
+		Exif("MIMEType", "image/jpeg")
+}
+
+// PNG returns a builder for a PNG image.
+func PNG(name string) *MetadataBuilder {
+	return NewMetadata(name).
+		Type(".png", "image/png", "Portable Network Graphics", 100).
+		Exif("FileType", "PNG").
+		Exif("MIMEType", "image/png")
+}
+
+// PDF returns a builder for a PDF document.
+func PDF(name string) *MetadataBuilder {
+	return NewMetadata(name).
+		Type(".pdf", "application/pdf", "Adobe Portable Document Format", 100).
+		Exif("FileType", "PDF").
+		Exif("MIMEType", "application/pdf")
+}
+
+// Executable returns a builder for a Windows executable, which may be
+// disguised by the extension of name.
+func Executable(name string) *MetadataBuilder {
+	return NewMetadata(name).
+		Type(".exe/.dll", "application/vnd.microsoft.portable-executable", "Win32 Executable", 100).
+		Exif("FileType", "Win32 EXE").
+		Exif("MIMEType", "application/octet-stream")
+}
+
+// Size sets the file size.
+func (b *MetadataBuilder) Size(size int64) *MetadataBuilder {
+	b.metadata.Size = size
+	return b
+}
+
+// ModTime sets the modification time of the file.
+func (b *MetadataBuilder) ModTime(t time.Time) *MetadataBuilder {
+	b.metadata.Time.ModTime = t
+	return b
+}
+
+// Type appends a detected type. The first type is the most likely one.
+func (b *MetadataBuilder) Type(ext, mimeType, name string, probability float64) *MetadataBuilder {
+	b.metadata.Types = append(b.metadata.Types, trid.FileType{
+		Extension:   ext,
+		MimeType:    mimeType,
+		Name:        name,
+		Probability: probability,
+	})
+	return b
+}
+
+// Exif sets an EXIF tag.
+func (b *MetadataBuilder) Exif(tag string, value interface{}) *MetadataBuilder {
+	b.metadata.Exif[tag] = value
+	return b
+}
+
+// Hash sets the hex-encoded digest of a hash algorithm.
+func (b *MetadataBuilder) Hash(algorithm, digest string) *MetadataBuilder {
+	if b.metadata.Hashes == nil {
+		b.metadata.Hashes = make(map[string]string)
+	}
+	b.metadata.Hashes[algorithm] = digest
+	return b
+}
+
+// Labels appends rule labels.
+func (b *MetadataBuilder) Labels(labels ...string) *MetadataBuilder {
+	b.metadata.Labels = append(b.metadata.Labels, labels...)
+	return b
+}
+
+// Build returns the metadata. The detector fields, ExtMismatch and the
+// suggested name are derived from the most likely type as Extract would.
+func (b *MetadataBuilder) Build() metaextractor.Metadata {
+	metadata := b.metadata
+	metadata.Exif = make(metaextractor.ExifMetadata, len(b.metadata.Exif))
+	for k, v := range b.metadata.Exif {
+		metadata.Exif[k] = v
+	}
+
+	if len(metadata.Types) == 0 {
+		return metadata
+	}
+
+	best := metadata.Types[0]
+	exts := strings.Split(best.Extension, "/")
+
+	metadata.Detector = "fake"
+	metadata.BestType = &metaextractor.BestType{
+		Extension: exts[0],
+		MimeType:  best.MimeType,
+		Name:      best.Name,
+		Agreement: 1,
+		Detectors: []string{"fake"},
+	}
+
+	metadata.ExtMismatch = true
+	for _, ext := range exts {
+		if ext == metadata.Extension {
+			metadata.ExtMismatch = false
+		}
+	}
+
+	if metadata.ExtMismatch && exts[0] != "" {
+		metadata.SuggestedExtension = exts[0]
+		metadata.SuggestedName = suggestedName(metadata.Name, exts[0])
+	}
+
+	return metadata
+}
+
+// Minimal file contents recognized by the signature detector, for tests that
+// run a real extractor with Options.PureGo.
+var (
+	JPEGBytes = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00\xff\xd9")
+	PNGBytes  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+	PDFBytes  = []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\ntrailer\n<<>>\n%%EOF\n")
+)
+
+// WriteFile writes a fixture file into dir, which defaults to a temporary
+// directory removed at the end of the test, and returns its path.
+func WriteFile(tb testing.TB, dir, name string, content []byte) string {
+	tb.Helper()
+
+	if dir == "" {
+		dir = tb.TempDir()
+	}
+
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(p, content, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+
+	return p
+}
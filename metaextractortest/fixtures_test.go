@@ -0,0 +1,82 @@
+package metaextractortest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attilabuti/metaextractor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataBuilder(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	metadata := JPEG("photo.JPEG").
+		Size(42).
+		ModTime(modTime).
+		Hash("sha256", "abc").
+		Exif("Make", "Canon").
+		Labels("image").
+		Build()
+
+	assert.Equal(t, "photo.JPEG", metadata.Name)
+	assert.Equal(t, ".jpeg", metadata.Extension)
+	assert.Equal(t, int64(42), metadata.Size)
+	assert.Equal(t, modTime, metadata.Time.ModTime)
+	assert.Equal(t, "abc", metadata.Hashes["sha256"])
+	assert.Equal(t, "Canon", metadata.Exif["Make"])
+	assert.Equal(t, []string{"image"}, metadata.Labels)
+	assert.False(t, metadata.ExtMismatch)
+	require.NotNil(t, metadata.BestType)
+	assert.Equal(t, ".jpg", metadata.BestType.Extension)
+
+	disguised := Executable("invoice.pdf").Build()
+	assert.True(t, disguised.ExtMismatch)
+	assert.Equal(t, ".exe", disguised.SuggestedExtension)
+	assert.Equal(t, "invoice.exe", disguised.SuggestedName)
+
+	compressed := NewMetadata("backup.tar.bz2").Type(".gz", "application/gzip", "GZipped data", 100).Build()
+	assert.Equal(t, ".tar.bz2", compressed.Extension)
+	assert.Equal(t, "backup.tar.gz", compressed.SuggestedName)
+
+	scan := NewMetadata("scan.nii.gz").Type(".zip", "application/zip", "ZIP compressed archive", 100).Build()
+	assert.Equal(t, "scan.zip", scan.SuggestedName)
+
+	plain := NewMetadata("data.bin").Build()
+	assert.Empty(t, plain.Types)
+	assert.False(t, plain.ExtMismatch)
+}
+
+func TestMetadataBuilder_Independent(t *testing.T) {
+	b := PNG("a.png")
+	first := b.Build()
+	b.Exif("Make", "Nikon")
+
+	assert.NotContains(t, first.Exif, "Make")
+}
+
+func TestWriteFile(t *testing.T) {
+	me := metaextractor.NewMetaExtractor(metaextractor.Options{PureGo: true})
+
+	testCases := []struct {
+		name     string
+		content  []byte
+		mimeType string
+	}{
+		{"photo.jpg", JPEGBytes, "image/jpeg"},
+		{"image.png", PNGBytes, "image/png"},
+		{"doc.pdf", PDFBytes, "application/pdf"},
+	}
+
+	dir := t.TempDir()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metadata, err := me.Extract(WriteFile(t, dir, tc.name, tc.content))
+			require.NoError(t, err)
+			require.NotEmpty(t, metadata.Types)
+			assert.Equal(t, tc.mimeType, metadata.Types[0].MimeType)
+			assert.False(t, metadata.ExtMismatch)
+		})
+	}
+}
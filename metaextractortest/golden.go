@@ -0,0 +1,270 @@
+package metaextractortest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/metaextractor"
+)
+
+// UpdateGoldenEnv is the environment variable that, if set to a non-empty
+// value, enables GoldenOptions.Update.
+const UpdateGoldenEnv = "METAEXTRACTOR_UPDATE_GOLDEN"
+
+// volatileExifTags are tags that depend on where and when a fixture is
+// extracted rather than on its content.
+var volatileExifTags = map[string]bool{
+	"SourceFile":          true,
+	"Directory":           true,
+	"FileName":            true,
+	"FileModifyDate":      true,
+	"FileAccessDate":      true,
+	"FileInodeChangeDate": true,
+	"FileCreateDate":      true,
+	"FilePermissions":     true,
+	"FileAttributes":      true,
+	"ExifToolVersion":     true,
+}
+
+// GoldenOptions configures RunGolden.
+type GoldenOptions struct {
+	// Dir is the fixtures directory.
+	Dir string
+
+	// GoldenDir is the directory of the golden files. Every fixture has a
+	// golden file with the same relative path and a ".json" suffix.
+	// Defaults to Dir with a ".golden" suffix (e.g., "testdata/fixtures.golden").
+	GoldenDir string
+
+	// Walk configures the walk of the fixtures directory.
+	Walk metaextractor.WalkOptions
+
+	// Update rewrites the golden files with the current results instead of
+	// comparing them, and removes golden files without a fixture. It is also
+	// enabled by the UpdateGoldenEnv environment variable.
+	Update bool
+
+	// Normalize is applied to the metadata of each fixture after the
+	// default normalization (see NormalizeMetadata), for example to remove
+	// fields that are not stable in the test environment.
+	Normalize func(*metaextractor.Metadata)
+}
+
+// goldenRecord is the content of a golden file.
+type goldenRecord struct {
+	Error    string                  `json:"error,omitempty"`
+	Metadata *metaextractor.Metadata `json:"metadata,omitempty"`
+}
+
+// RunGolden extracts metadata from every fixture below opts.Dir and compares
+// the normalized results with the golden files, in a subtest per fixture.
+func RunGolden(t *testing.T, extractor metaextractor.Interface, opts GoldenOptions) {
+	t.Helper()
+
+	dir := filepath.Clean(opts.Dir)
+	goldenDir := opts.GoldenDir
+	if goldenDir == "" {
+		goldenDir = dir + ".golden"
+	}
+
+	update := opts.Update || os.Getenv(UpdateGoldenEnv) != ""
+
+	results, err := extractor.ExtractDir(dir, opts.Walk)
+	if err != nil {
+		t.Fatalf("error extracting fixtures: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, r := range results {
+		rel, err := filepath.Rel(dir, r.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		goldenPath := filepath.Join(goldenDir, rel+".json")
+		seen[goldenPath] = true
+
+		data, err := goldenJSON(r, opts.Normalize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run(filepath.ToSlash(rel), func(t *testing.T) {
+			if update {
+				if err := writeGolden(goldenPath, data); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if err := checkGolden(goldenPath, data); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	stale, err := staleGoldenFiles(goldenDir, seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range stale {
+		if update {
+			if err := os.Remove(p); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		t.Errorf("golden file %s has no fixture; set %s=1 to remove it", p, UpdateGoldenEnv)
+	}
+}
+
+// NormalizeMetadata removes the fields that depend on where and when a file
+// is extracted: file times and the creation times compared with them, the
+// quarantine path and volatile EXIF tags (e.g., SourceFile, FileModifyDate),
+// in flat, grouped and nested form. The maps of the metadata are replaced
+// rather than modified.
+func NormalizeMetadata(metadata *metaextractor.Metadata) {
+	metadata.Time = metaextractor.FileTime{}
+
+	if b := metadata.BestCreatedAt; b != nil {
+		if fileTimeSource(b.Source) {
+			metadata.BestCreatedAt = nil
+		} else {
+			created := *b
+			created.Conflicts = slices.DeleteFunc(slices.Clone(b.Conflicts), fileTimeSource)
+			metadata.BestCreatedAt = &created
+		}
+	}
+
+	if metadata.Quarantine != nil {
+		q := *metadata.Quarantine
+		q.Path = ""
+		metadata.Quarantine = &q
+	}
+
+	exif := make(metaextractor.ExifMetadata, len(metadata.Exif))
+	for key, value := range metadata.Exif {
+		if volatileExifKey(key) {
+			continue
+		}
+
+		if group, ok := value.(map[string]interface{}); ok {
+			tags := make(map[string]interface{}, len(group))
+			for tag, v := range group {
+				if !volatileExifTags[tag] {
+					tags[tag] = v
+				}
+			}
+			value = tags
+		}
+
+		exif[key] = value
+	}
+	metadata.Exif = exif
+
+	if metadata.ExifTimes != nil {
+		times := make(map[string]time.Time, len(metadata.ExifTimes))
+		for key, t := range metadata.ExifTimes {
+			if !volatileExifKey(key) {
+				times[key] = t
+			}
+		}
+		metadata.ExifTimes = times
+	}
+}
+
+// volatileExifKey reports whether a possibly group-prefixed key names a
+// volatile tag.
+func volatileExifKey(key string) bool {
+	if _, tag, ok := strings.Cut(key, ":"); ok {
+		key = tag
+	}
+
+	return volatileExifTags[key]
+}
+
+// fileTimeSource reports whether a creation time candidate is a file time.
+func fileTimeSource(source string) bool {
+	return source == "BirthTime" || source == "ModTime"
+}
+
+// goldenJSON returns the normalized golden file content of a result.
+func goldenJSON(r metaextractor.Result, normalize func(*metaextractor.Metadata)) ([]byte, error) {
+	var rec goldenRecord
+	if r.Err != nil {
+		rec.Error = r.Err.Error()
+	} else {
+		metadata := r.Metadata
+		NormalizeMetadata(&metadata)
+		if normalize != nil {
+			normalize(&metadata)
+		}
+		rec.Metadata = &metadata
+	}
+
+	data, err := json.MarshalIndent(rec, "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding result of %s: %w", r.Path, err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// checkGolden compares data with the content of the golden file.
+func checkGolden(goldenPath string, data []byte) error {
+	want, err := os.ReadFile(goldenPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("missing golden file %s; set %s=1 to create it", goldenPath, UpdateGoldenEnv)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n")), data) {
+		return fmt.Errorf("result differs from golden file %s; set %s=1 to update it\n--- want\n%s--- got\n%s", goldenPath, UpdateGoldenEnv, want, data)
+	}
+
+	return nil
+}
+
+// writeGolden writes the golden file, creating its directory.
+func writeGolden(goldenPath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(goldenPath, data, 0o644)
+}
+
+// staleGoldenFiles returns the golden files below goldenDir that were not
+// produced by any fixture.
+func staleGoldenFiles(goldenDir string, seen map[string]bool) ([]string, error) {
+	var stale []string
+
+	err := filepath.WalkDir(goldenDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == goldenDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if !d.IsDir() && strings.HasSuffix(p, ".json") && !seen[p] {
+			stale = append(stale, p)
+		}
+
+		return nil
+	})
+
+	return stale, err
+}
@@ -0,0 +1,114 @@
+package metaextractortest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/metaextractor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunGolden(t *testing.T) {
+	me := metaextractor.NewMetaExtractor(metaextractor.Options{
+		PureGo: true,
+		Hashes: []string{"sha256"},
+	})
+
+	RunGolden(t, me, GoldenOptions{
+		Dir:       filepath.Join("..", "testdata"),
+		GoldenDir: filepath.Join("testdata", "golden"),
+	})
+}
+
+func TestRunGolden_Update(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	WriteFile(t, dir, "photo.jpg", JPEGBytes)
+	WriteFile(t, dir, filepath.Join("docs", "doc.pdf"), PDFBytes)
+
+	goldenDir := dir + ".golden"
+	stale := WriteFile(t, goldenDir, "removed.png.json", []byte("{}\n"))
+
+	me := metaextractor.NewMetaExtractor(metaextractor.Options{PureGo: true})
+	RunGolden(t, me, GoldenOptions{Dir: dir, Update: true})
+
+	assert.FileExists(t, filepath.Join(goldenDir, "photo.jpg.json"))
+	assert.FileExists(t, filepath.Join(goldenDir, "docs", "doc.pdf.json"))
+	assert.NoFileExists(t, stale)
+
+	RunGolden(t, me, GoldenOptions{Dir: dir})
+}
+
+func TestCheckGolden(t *testing.T) {
+	goldenPath := filepath.Join(t.TempDir(), "a.json")
+
+	assert.ErrorContains(t, checkGolden(goldenPath, []byte("{}\n")), "missing golden file")
+
+	require.NoError(t, writeGolden(goldenPath, []byte("{}\n")))
+	assert.NoError(t, checkGolden(goldenPath, []byte("{}\n")))
+	assert.ErrorContains(t, checkGolden(goldenPath, []byte("{\"error\":\"x\"}\n")), "result differs")
+
+	require.NoError(t, os.WriteFile(goldenPath, []byte("{}\r\n"), 0o644))
+	assert.NoError(t, checkGolden(goldenPath, []byte("{}\n")))
+}
+
+func TestStaleGoldenFiles(t *testing.T) {
+	dir := t.TempDir()
+	kept := WriteFile(t, dir, "a.json", nil)
+	stale := WriteFile(t, dir, filepath.Join("sub", "b.json"), nil)
+	WriteFile(t, dir, "notes.txt", nil)
+
+	files, err := staleGoldenFiles(dir, map[string]bool{kept: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{stale}, files)
+
+	files, err = staleGoldenFiles(filepath.Join(dir, "missing"), nil)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestNormalizeMetadata(t *testing.T) {
+	original := metaextractor.Metadata{
+		Time: metaextractor.FileTime{ModTime: time.Now()},
+		Exif: metaextractor.ExifMetadata{
+			"SourceFile":          "/tmp/job-1/a.jpg",
+			"File:FileModifyDate": "2024:01:02 03:04:05+00:00",
+			"Make":                "Canon",
+			"File": map[string]interface{}{
+				"FileName": "a.jpg",
+				"FileType": "JPEG",
+			},
+		},
+		ExifTimes: map[string]time.Time{
+			"File:FileModifyDate": time.Now(),
+			"DateTimeOriginal":    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		Quarantine: &metaextractor.QuarantineAction{Reason: "r", Path: "/quarantine/a.jpg"},
+	}
+
+	metadata := original
+	NormalizeMetadata(&metadata)
+
+	assert.True(t, metadata.Time.ModTime.IsZero())
+	assert.Equal(t, metaextractor.ExifMetadata{
+		"Make": "Canon",
+		"File": map[string]interface{}{"FileType": "JPEG"},
+	}, metadata.Exif)
+	assert.Equal(t, []string{"DateTimeOriginal"}, keys(metadata.ExifTimes))
+	assert.Equal(t, "r", metadata.Quarantine.Reason)
+	assert.Empty(t, metadata.Quarantine.Path)
+
+	assert.Len(t, original.Exif, 4)
+	assert.Len(t, original.ExifTimes, 2)
+	assert.Equal(t, "/quarantine/a.jpg", original.Quarantine.Path)
+}
+
+func keys(m map[string]time.Time) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
@@ -0,0 +1,255 @@
+package metaextractor
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/attilabuti/trid"
+)
+
+// File kinds derived from the detected type (see Metadata.Kind).
+const (
+	KindImage      Kind = "image"
+	KindAudio      Kind = "audio"
+	KindVideo      Kind = "video"
+	KindText       Kind = "text"
+	KindDocument   Kind = "document"
+	KindArchive    Kind = "archive"
+	KindExecutable Kind = "executable"
+)
+
+// extensionMimeTypes maps the extensions reported by the detectors to their
+// IANA media types. TrID definitions often lack a MIME type, so it is looked
+// up here by extension.
+var extensionMimeTypes = map[string]string{
+	// Images.
+	".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".jpe": "image/jpeg",
+	".png": "image/png", ".gif": "image/gif", ".bmp": "image/bmp",
+	".tif": "image/tiff", ".tiff": "image/tiff", ".webp": "image/webp",
+	".heic": "image/heic", ".heif": "image/heif", ".avif": "image/avif",
+	".ico": "image/vnd.microsoft.icon", ".svg": "image/svg+xml",
+	".psd": "image/vnd.adobe.photoshop", ".jp2": "image/jp2", ".jxl": "image/jxl",
+	".cr2": "image/x-canon-cr2", ".cr3": "image/x-canon-cr3", ".nef": "image/x-nikon-nef",
+	".arw": "image/x-sony-arw", ".dng": "image/x-adobe-dng", ".orf": "image/x-olympus-orf",
+	".raf": "image/x-fuji-raf", ".rw2": "image/x-panasonic-rw2",
+
+	// Audio.
+	".mp3": "audio/mpeg", ".wav": "audio/wav", ".flac": "audio/flac",
+	".ogg": "audio/ogg", ".oga": "audio/ogg", ".opus": "audio/opus",
+	".m4a": "audio/mp4", ".aac": "audio/aac", ".aif": "audio/aiff",
+	".aiff": "audio/aiff", ".mid": "audio/midi", ".midi": "audio/midi",
+	".wma": "audio/x-ms-wma", ".amr": "audio/amr",
+
+	// Video.
+	".mp4": "video/mp4", ".m4v": "video/mp4", ".mov": "video/quicktime",
+	".qt": "video/quicktime", ".avi": "video/x-msvideo", ".mkv": "video/x-matroska",
+	".webm": "video/webm", ".ogv": "video/ogg", ".3gp": "video/3gpp",
+	".3g2": "video/3gpp2", ".wmv": "video/x-ms-wmv", ".flv": "video/x-flv",
+	".mpg": "video/mpeg", ".mpeg": "video/mpeg", ".ts": "video/mp2t",
+
+	// Text.
+	".txt": "text/plain", ".csv": "text/csv", ".htm": "text/html",
+	".html": "text/html", ".xml": "text/xml", ".css": "text/css",
+	".js": "text/javascript", ".md": "text/markdown", ".ics": "text/calendar",
+	".vcf": "text/vcard", ".json": "application/json", ".eml": "message/rfc822",
+
+	// Documents.
+	".pdf": "application/pdf", ".rtf": "application/rtf",
+	".doc": "application/msword", ".dot": "application/msword",
+	".xls": "application/vnd.ms-excel", ".ppt": "application/vnd.ms-powerpoint",
+	".msg":  "application/vnd.ms-outlook",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".docm": "application/vnd.ms-word.document.macroenabled.12",
+	".xlsm": "application/vnd.ms-excel.sheet.macroenabled.12",
+	".pptm": "application/vnd.ms-powerpoint.presentation.macroenabled.12",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".epub": "application/epub+zip", ".ps": "application/postscript",
+
+	// Archives.
+	".zip": "application/zip", ".gz": "application/gzip", ".tgz": "application/gzip",
+	".bz2": "application/x-bzip2", ".xz": "application/x-xz", ".zst": "application/zstd",
+	".7z": "application/x-7z-compressed", ".rar": "application/vnd.rar",
+	".tar": "application/x-tar", ".cab": "application/vnd.ms-cab-compressed",
+	".iso": "application/x-iso9660-image", ".jar": "application/java-archive",
+	".apk": "application/vnd.android.package-archive",
+
+	// Executables.
+	".exe": "application/vnd.microsoft.portable-executable",
+	".dll": "application/vnd.microsoft.portable-executable",
+	".sys": "application/vnd.microsoft.portable-executable",
+	".scr": "application/vnd.microsoft.portable-executable",
+	".msi": "application/x-msi", ".elf": "application/x-executable",
+	".so": "application/x-sharedlib", ".dylib": "application/x-mach-binary",
+	".wasm": "application/wasm", ".class": "application/java-vm",
+}
+
+// mimeKinds maps the media types outside of the image, audio, video and text
+// top-level types to their kind.
+var mimeKinds = map[string]Kind{
+	"application/pdf":                               KindDocument,
+	"application/rtf":                               KindDocument,
+	"application/msword":                            KindDocument,
+	"application/vnd.ms-excel":                      KindDocument,
+	"application/vnd.ms-outlook":                    KindDocument,
+	"application/epub+zip":                          KindDocument,
+	"application/postscript":                        KindDocument,
+	"application/x-ole-storage":                     KindDocument,
+	"application/json":                              KindText,
+	"message/rfc822":                                KindText,
+	"application/ogg":                               KindAudio,
+	"application/zip":                               KindArchive,
+	"application/gzip":                              KindArchive,
+	"application/x-bzip2":                           KindArchive,
+	"application/x-xz":                              KindArchive,
+	"application/zstd":                              KindArchive,
+	"application/x-7z-compressed":                   KindArchive,
+	"application/vnd.rar":                           KindArchive,
+	"application/x-tar":                             KindArchive,
+	"application/x-iso9660-image":                   KindArchive,
+	"application/java-archive":                      KindArchive,
+	"application/vnd.ms-cab-compressed":             KindArchive,
+	"application/x-msi":                             KindExecutable,
+	"application/x-executable":                      KindExecutable,
+	"application/x-elf":                             KindExecutable,
+	"application/x-sharedlib":                       KindExecutable,
+	"application/x-mach-binary":                     KindExecutable,
+	"application/x-dosexec":                         KindExecutable,
+	"application/x-msdownload":                      KindExecutable,
+	"application/wasm":                              KindExecutable,
+	"application/java-vm":                           KindExecutable,
+	"application/vnd.android.package-archive":       KindExecutable,
+	"application/vnd.microsoft.portable-executable": KindExecutable,
+}
+
+// mimePrefixKinds maps media type prefixes to their kind, for the families
+// of types too large to list.
+var mimePrefixKinds = []struct {
+	prefix string
+	kind   Kind
+}{
+	{"image/", KindImage},
+	{"audio/", KindAudio},
+	{"video/", KindVideo},
+	{"text/", KindText},
+	{"application/vnd.openxmlformats-officedocument.", KindDocument},
+	{"application/vnd.oasis.opendocument.", KindDocument},
+	{"application/vnd.ms-word.", KindDocument},
+	{"application/vnd.ms-excel.", KindDocument},
+	{"application/vnd.ms-powerpoint", KindDocument},
+}
+
+// typeMimeType returns the MIME type of the file type: the one reported by
+// the detector, or else the one registered for its first known extension.
+func typeMimeType(fileType trid.FileType) string {
+	if fileType.MimeType != "" {
+		return fileType.MimeType
+	}
+
+	for _, ext := range typeExtensions(fileType) {
+		if mimeType, ok := extensionMimeTypes[ext]; ok {
+			return mimeType
+		}
+	}
+
+	return ""
+}
+
+// withMimeTypes returns the file types with missing MIME types filled in
+// from the mapping table. The slice is copied if any type is changed, as
+// detectors may return shared values.
+func withMimeTypes(types []trid.FileType) []trid.FileType {
+	var filled []trid.FileType
+	for i, fileType := range types {
+		if fileType.MimeType != "" {
+			continue
+		}
+
+		mimeType := typeMimeType(fileType)
+		if mimeType == "" {
+			continue
+		}
+
+		if filled == nil {
+			filled = append([]trid.FileType(nil), types...)
+		}
+		filled[i].MimeType = mimeType
+	}
+
+	if filled == nil {
+		return types
+	}
+
+	return filled
+}
+
+// mimeTypeKind returns the kind of files of the MIME type, or an empty kind
+// if the type is not known.
+func mimeTypeKind(mimeType string) Kind {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:i])
+	}
+
+	if kind, ok := mimeKinds[mimeType]; ok {
+		return kind
+	}
+
+	for _, p := range mimePrefixKinds {
+		if strings.HasPrefix(mimeType, p.prefix) {
+			return p.kind
+		}
+	}
+
+	return ""
+}
+
+// typeKind returns the kind of the file from its most likely type, falling
+// back to the MIME type reported by ExifTool.
+func typeKind(metadata Metadata) Kind {
+	if metadata.BestType != nil {
+		if kind := mimeTypeKind(metadata.BestType.MimeType); kind != "" {
+			return kind
+		}
+	}
+
+	return mimeTypeKind(detectedMimeType(metadata))
+}
+
+// contentMimeType returns the canonical MIME type of the file and its
+// source: the MIME type reported by ExifTool, else the type reconciled from
+// the detectors, the most likely detected type or the type identified by
+// libmagic, else the type sniffed from the head of the content (see
+// http.DetectContentType). It is empty if no content was read.
+func contentMimeType(exifMimeType string, metadata Metadata, head []byte) (mimeType, source string) {
+	type candidate struct {
+		mimeType, source string
+	}
+
+	candidates := []candidate{{exifMimeType, SourceExifTool}}
+	if metadata.BestType != nil {
+		candidates = append(candidates, candidate{metadata.BestType.MimeType, SourceFusion})
+	}
+	if len(metadata.Types) > 0 {
+		candidates = append(candidates, candidate{typeMimeType(metadata.Types[0]), metadata.Detector})
+	}
+	if metadata.Magic != nil && metadata.Magic.MimeType != "application/octet-stream" {
+		candidates = append(candidates, candidate{metadata.Magic.MimeType, SourceMagic})
+	}
+
+	for _, c := range candidates {
+		if c.mimeType != "" {
+			return strings.ToLower(c.mimeType), c.source
+		}
+	}
+
+	if len(head) == 0 {
+		return "", ""
+	}
+
+	mimeType, _, _ = strings.Cut(http.DetectContentType(head), ";")
+	return mimeType, SourceContent
+}
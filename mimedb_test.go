@@ -0,0 +1,179 @@
+package metaextractor
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeMimeType(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileType trid.FileType
+		want     string
+	}{
+		{"Reported", trid.FileType{Extension: ".jpg", MimeType: "image/x-custom"}, "image/x-custom"},
+		{"By Extension", trid.FileType{Extension: ".DOCX"}, "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"Alternatives", trid.FileType{Extension: ".xyz/.mkv"}, "video/x-matroska"},
+		{"Unknown", trid.FileType{Extension: ".xyz"}, ""},
+		{"No Extension", trid.FileType{}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, typeMimeType(tc.fileType))
+		})
+	}
+}
+
+func TestWithMimeTypes(t *testing.T) {
+	types := []trid.FileType{{Extension: ".pdf"}, {Extension: ".xyz"}}
+
+	filled := withMimeTypes(types)
+	assert.Equal(t, "application/pdf", filled[0].MimeType)
+	assert.Empty(t, filled[1].MimeType)
+	assert.Empty(t, types[0].MimeType, "the input must not be modified")
+
+	complete := []trid.FileType{{Extension: ".pdf", MimeType: "application/pdf"}}
+	assert.Same(t, &complete[0], &withMimeTypes(complete)[0])
+	assert.Nil(t, withMimeTypes(nil))
+}
+
+func TestMimeTypeKind(t *testing.T) {
+	testCases := []struct {
+		mimeType string
+		want     Kind
+	}{
+		{"image/jpeg", KindImage},
+		{"Audio/MPEG", KindAudio},
+		{"video/mp4", KindVideo},
+		{"text/plain; charset=utf-8", KindText},
+		{"application/pdf", KindDocument},
+		{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", KindDocument},
+		{"application/x-7z-compressed", KindArchive},
+		{"application/x-dosexec", KindExecutable},
+		{"application/octet-stream", ""},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mimeType, func(t *testing.T) {
+			assert.Equal(t, tc.want, mimeTypeKind(tc.mimeType))
+		})
+	}
+}
+
+func TestTypeKind(t *testing.T) {
+	t.Run("Best Type", func(t *testing.T) {
+		metadata := Metadata{
+			Types:    []trid.FileType{{Extension: ".zip", MimeType: "application/zip"}},
+			BestType: &BestType{Extension: ".docx", MimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		}
+		assert.Equal(t, KindDocument, typeKind(metadata))
+	})
+
+	t.Run("ExifTool", func(t *testing.T) {
+		metadata := Metadata{Exif: ExifMetadata{"MIMEType": "audio/flac"}}
+		assert.Equal(t, KindAudio, typeKind(metadata))
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		assert.Empty(t, typeKind(Metadata{}))
+	})
+
+	t.Run("Extract", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+
+		metadata, err := me.ExtractStreamInput(bytes.NewReader([]byte("%PDF-1.7\n")))
+		require.NoError(t, err)
+		assert.Equal(t, KindDocument, metadata.Kind)
+		require.NotEmpty(t, metadata.Types)
+		assert.Equal(t, "application/pdf", metadata.Types[0].MimeType)
+	})
+}
+
+func TestContentMimeType(t *testing.T) {
+	tests := []struct {
+		name         string
+		exifMimeType string
+		metadata     Metadata
+		head         []byte
+		expect       string
+		source       string
+	}{
+		{
+			name:         "ExifTool",
+			exifMimeType: "Image/JPEG",
+			metadata:     Metadata{Types: []trid.FileType{{Extension: ".png", MimeType: "image/png"}}},
+			expect:       "image/jpeg",
+			source:       SourceExifTool,
+		},
+		{
+			name: "Best Type",
+			metadata: Metadata{
+				Types:    []trid.FileType{{Extension: ".zip", MimeType: "application/zip"}},
+				BestType: &BestType{Extension: ".docx", MimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+			},
+			expect: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			source: SourceFusion,
+		},
+		{
+			name:     "Detected Type",
+			metadata: Metadata{Types: []trid.FileType{{Extension: ".flac"}}, Detector: "trid"},
+			expect:   "audio/flac",
+			source:   "trid",
+		},
+		{
+			name:     "Magic",
+			metadata: Metadata{Magic: &Magic{MimeType: "text/x-script.python"}},
+			head:     []byte("print(1)\n"),
+			expect:   "text/x-script.python",
+			source:   SourceMagic,
+		},
+		{
+			name:     "Unknown Magic",
+			metadata: Metadata{Magic: &Magic{MimeType: "application/octet-stream"}},
+			head:     []byte("hello\n"),
+			expect:   "text/plain",
+			source:   SourceContent,
+		},
+		{
+			name:   "Sniffed Binary",
+			head:   []byte{0x00, 0x01, 0x02, 0xff},
+			expect: "application/octet-stream",
+			source: SourceContent,
+		},
+		{
+			name:   "No Content",
+			expect: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mimeType, source := contentMimeType(tt.exifMimeType, tt.metadata, tt.head)
+			assert.Equal(t, tt.expect, mimeType)
+			assert.Equal(t, tt.source, source)
+		})
+	}
+
+	t.Run("Extract", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+
+		metadata, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+		require.NoError(t, err)
+		assert.Equal(t, "application/pdf", metadata.MimeType)
+
+		metadata, err = me.ExtractStreamInput(bytes.NewReader([]byte("just some text\n")))
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", metadata.MimeType)
+
+		metadata, err = me.ExtractBytes(nil, "empty.txt")
+		require.NoError(t, err)
+		assert.Empty(t, metadata.MimeType)
+	})
+}
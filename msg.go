@@ -0,0 +1,141 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Names of the storages and streams of Outlook messages (MS-OXMSG).
+const (
+	msgAttachPrefix = "__attach_version1.0_#"
+	msgProperties   = "__properties_version1.0"
+	msgSubstg       = "__substg1.0_"
+)
+
+// Attachment properties (MS-OXPROPS).
+const (
+	msgAttachData         = 0x3701
+	msgAttachFilename     = 0x3704
+	msgAttachLongFilename = 0x3707
+	msgDisplayName        = 0x3001
+	msgCreationTime       = 0x3007
+	msgLastModified       = 0x3008
+)
+
+// Property types.
+const (
+	msgTypeString8 = 0x001e
+	msgTypeUnicode = 0x001f
+	msgTypeBinary  = 0x0102
+	msgTypeSystime = 0x0040
+)
+
+// msgProperty returns the name of the stream of a variable-length property.
+func msgProperty(id, typ uint16) string {
+	return fmt.Sprintf("%s%04X%04X", msgSubstg, id, typ)
+}
+
+// walkMsg processes the attachments of an Outlook message. Attachments
+// that are Outlook items themselves (attached messages, stored as
+// substorages rather than files) are not descended into. Other compound
+// files, such as legacy Office documents, have no attachments.
+func (me *MetaExtractor) walkMsg(ctx context.Context, filePath, virtualPath string, depth int, accept entryFilter, emit func(Result)) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	c, err := openCFB(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, id := range c.children(0) {
+		storage := c.entries[id]
+		if storage.kind != cfbStorage || !strings.HasPrefix(storage.name, msgAttachPrefix) {
+			continue
+		}
+
+		streams := make(map[string]cfbEntry)
+		for _, child := range c.children(id) {
+			streams[c.entries[child].name] = c.entries[child]
+		}
+
+		dataEntry, ok := streams[msgProperty(msgAttachData, msgTypeBinary)]
+		if !ok {
+			continue
+		}
+
+		count++
+		name := msgString(c, streams, msgAttachLongFilename, msgAttachFilename, msgDisplayName)
+		if name == "" {
+			name = fmt.Sprintf("attachment-%d", count)
+		}
+		entryPath := joinEntryPath(virtualPath, name)
+
+		data, err := c.stream(dataEntry)
+		if err != nil {
+			emit(Result{Path: entryPath, Parent: virtualPath, Depth: depth + 1, Err: err})
+			continue
+		}
+
+		entryInfo := attachmentInfo{name: name, size: int64(len(data))}
+		if props, ok := streams[msgProperties]; ok {
+			if b, err := c.stream(props); err == nil {
+				entryInfo.modTime = msgTime(b, msgLastModified)
+				if entryInfo.modTime.IsZero() {
+					entryInfo.modTime = msgTime(b, msgCreationTime)
+				}
+			}
+		}
+
+		me.extractEntry(ctx, bytes.NewReader(data), name, entryInfo, entryPath, virtualPath, depth, accept, emit)
+	}
+
+	return nil
+}
+
+// msgString returns the first of the given string properties present in
+// the streams.
+func msgString(c *cfbFile, streams map[string]cfbEntry, ids ...uint16) string {
+	for _, id := range ids {
+		if e, ok := streams[msgProperty(id, msgTypeUnicode)]; ok {
+			if b, err := c.stream(e); err == nil && len(b) > 0 {
+				return decodeUTF16(b)
+			}
+		}
+		if e, ok := streams[msgProperty(id, msgTypeString8)]; ok {
+			if b, err := c.stream(e); err == nil && len(b) > 0 {
+				return strings.TrimRight(string(b), "\x00")
+			}
+		}
+	}
+
+	return ""
+}
+
+// msgTime returns the value of a time property from the properties stream
+// of an attachment: an 8-byte header followed by 16-byte entries holding
+// the property tag, flags and value.
+func msgTime(props []byte, id uint16) time.Time {
+	tag := uint32(id)<<16 | msgTypeSystime
+	for off := 8; off+16 <= len(props); off += 16 {
+		if binary.LittleEndian.Uint32(props[off:]) == tag {
+			return ntfsTime(binary.LittleEndian.Uint64(props[off+8:]))
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,92 @@
+package metaextractor
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// utf16LE encodes s as null-terminated little-endian UTF-16.
+func utf16LE(s string) []byte {
+	units := utf16.Encode([]rune(s + "\x00"))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// msgTimeProperty returns the properties stream of an attachment with the
+// given last modification time.
+func msgTimeProperty(t time.Time) []byte {
+	b := make([]byte, 8+16)
+	binary.LittleEndian.PutUint32(b[8:], uint32(msgLastModified)<<16|msgTypeSystime)
+	binary.LittleEndian.PutUint64(b[16:], uint64(t.UnixNano()/100+ntfsEpoch))
+	return b
+}
+
+func TestWalkMsg(t *testing.T) {
+	modified := time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC)
+	msg := cfbBytes(t, []cfbNode{
+		{name: msgProperties, data: make([]byte, 32)},
+		{name: msgProperty(0x0037, msgTypeUnicode), data: utf16LE("Invoice")},
+		{name: msgAttachPrefix + "00000000", children: []cfbNode{
+			{name: msgProperties, data: msgTimeProperty(modified)},
+			{name: msgProperty(msgAttachLongFilename, msgTypeUnicode), data: utf16LE("invoice.zip")},
+			{name: msgProperty(msgAttachData, msgTypeBinary), data: zipBytes(t, map[string][]byte{"invoice.exe": []byte("MZ\x90\x00")})},
+		}},
+		{name: msgAttachPrefix + "00000001", children: []cfbNode{
+			{name: msgProperty(msgAttachData, msgTypeBinary), data: []byte("notes")},
+		}},
+		{name: msgAttachPrefix + "00000002", children: []cfbNode{
+			{name: msgProperty(msgAttachData, 0x000d), children: []cfbNode{
+				{name: msgProperties, data: make([]byte, 32)},
+			}},
+		}},
+	})
+
+	root := createTree(t, map[string]string{"mail.msg": string(msg)})
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	results, err := me.ExtractDir(root, WalkOptions{Archives: true})
+	require.NoError(t, err)
+
+	mail := filepath.Join(root, "mail.msg")
+	byPath := make(map[string]Result)
+	for _, r := range results {
+		require.NoError(t, r.Err, r.Path)
+		byPath[r.Path] = r
+	}
+	require.Len(t, byPath, 4)
+
+	assert.Equal(t, 0, byPath[mail].Depth)
+	assert.Empty(t, byPath[mail].Parent)
+
+	attachment := byPath[mail+"!/invoice.zip"]
+	assert.Equal(t, mail, attachment.Parent)
+	assert.Equal(t, 1, attachment.Depth)
+	assert.True(t, modified.Equal(attachment.Metadata.Time.ModTime))
+
+	entry := byPath[mail+"!/invoice.zip!/invoice.exe"]
+	assert.Equal(t, mail+"!/invoice.zip", entry.Parent)
+	assert.Equal(t, 2, entry.Depth)
+
+	unnamed := byPath[mail+"!/attachment-2"]
+	assert.Equal(t, int64(5), unnamed.Metadata.Size)
+	assert.Equal(t, 1, unnamed.Depth)
+
+	t.Run("Not a Message", func(t *testing.T) {
+		doc := cfbBytes(t, []cfbNode{{name: "WordDocument", data: []byte("text")}})
+		root := createTree(t, map[string]string{"report.doc": string(doc)})
+
+		results, err := me.ExtractDir(root, WalkOptions{Archives: true})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.NoError(t, results[0].Err)
+	})
+}
@@ -0,0 +1,51 @@
+package metaextractor
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NameForm is the Unicode normalization form of the file names reported in
+// Metadata.
+type NameForm int
+
+const (
+	// NameAsIs reports file names as stored by the file system.
+	NameAsIs NameForm = iota
+
+	// NameNFC reports file names in Normalization Form C (composed), as
+	// used by most Linux and Windows software.
+	NameNFC
+
+	// NameNFD reports file names in Normalization Form D (decomposed), as
+	// stored by macOS HFS+.
+	NameNFD
+)
+
+// normalize returns the name in the normalization form. Invalid UTF-8
+// sequences are replaced with U+FFFD, unless the form is NameAsIs.
+func (f NameForm) normalize(name string) string {
+	switch f {
+	case NameNFC:
+		return toNFC(name)
+	case NameNFD:
+		return toNFD(name)
+	}
+
+	return name
+}
+
+// toNFC returns s in Unicode Normalization Form C, so that names stored
+// decomposed (e.g., by macOS) and composed compare equal. Invalid UTF-8
+// sequences are replaced with U+FFFD.
+func toNFC(s string) string {
+	return norm.NFC.String(strings.ToValidUTF8(s, string(utf8.RuneError)))
+}
+
+// toNFD returns s in Unicode Normalization Form D. Invalid UTF-8 sequences
+// are replaced with U+FFFD.
+func toNFD(s string) string {
+	return norm.NFD.String(strings.ToValidUTF8(s, string(utf8.RuneError)))
+}
@@ -0,0 +1,63 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToNFC(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"ASCII", "photo.jpg", "photo.jpg"},
+		{"Decomposed", "Cafe\u0301.txt", "Caf\u00e9.txt"},
+		{"Composed", "Caf\u00e9.txt", "Caf\u00e9.txt"},
+		{"Hangul", "\u1112\u1161\u11ab\u1100\u1173\u11af.doc", "\ud55c\uae00.doc"},
+		{"Kana", "\u30ab\u3099", "\u30ac"},
+		{"Reordered Marks", "a\u0302\u0323", "\u1ead"},
+		{"Blocked Mark", "e\u0301\u0301", "\u00e9\u0301"},
+		{"Singleton", "\u0340", "\u0300"},
+		{"Excluded Composite", "\u0958", "\u0915\u093c"},
+		{"Invalid UTF-8", "a\xffb", "a\ufffdb"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, toNFC(tc.input))
+		})
+	}
+}
+
+func TestToNFD(t *testing.T) {
+	assert.Equal(t, "Cafe\u0301.txt", toNFD("Caf\u00e9.txt"))
+	assert.Equal(t, "\u1112\u1161\u11ab", toNFD("\ud55c"))
+	assert.Equal(t, "a\u0323\u0302", toNFD("\u1ead"))
+	assert.Equal(t, "x\ufffd", toNFD("x\xc3"))
+}
+
+func TestMetaExtractor_NameForm(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("the file system normalizes names")
+	}
+
+	path := filepath.Join(t.TempDir(), "Cafe\u0301.TXT")
+	require.NoError(t, os.WriteFile(path, []byte("menu"), 0o644))
+
+	metadata, err := NewMetaExtractor(Options{PureGo: true, NameForm: NameNFC}).Extract(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Caf\u00e9.TXT", metadata.Name)
+	assert.Equal(t, "Cafe\u0301.TXT", metadata.RawName)
+	assert.Equal(t, ".txt", metadata.Extension)
+
+	metadata, err = NewMetaExtractor(Options{PureGo: true}).Extract(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Cafe\u0301.TXT", metadata.Name)
+	assert.Empty(t, metadata.RawName)
+}
@@ -0,0 +1,348 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Normalized contains the common properties of the content under canonical
+// names, mapped from the format-specific tags of Exif (e.g., the EXIF and
+// ID3 Artist, the XMP Creator and the PDF Author all set Creator).
+type Normalized struct {
+	// Title is the title of the content.
+	Title string `json:"title,omitempty"`
+
+	// Creator is the author, artist or creator of the content.
+	Creator string `json:"creator,omitempty"`
+
+	// CreatedDate is the time the content was created or captured.
+	CreatedDate time.Time `json:"created_date,omitempty"`
+
+	// ModifiedDate is the time the content was last modified.
+	ModifiedDate time.Time `json:"modified_date,omitempty"`
+
+	// Software is the application or device firmware that created or last
+	// edited the file.
+	Software string `json:"software,omitempty"`
+
+	// GPS is the location where the content was captured, or nil if none
+	// is recorded.
+	GPS *GPSCoordinates `json:"gps,omitempty"`
+
+	// Duration is the duration of audio and video in seconds.
+	Duration float64 `json:"duration,omitempty"`
+
+	// Width and Height are the dimensions of images and videos in pixels.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+
+	// Sources are the keys of the tags of Exif (or ExifTimes) the
+	// properties were taken from, keyed by property name (e.g., "Creator":
+	// "XMP:Creator").
+	Sources map[string]string `json:"sources,omitempty"`
+}
+
+// MarshalJSON encodes the properties, omitting the zero times.
+func (n Normalized) MarshalJSON() ([]byte, error) {
+	type normalized Normalized
+
+	return json.Marshal(struct {
+		normalized
+		CreatedDate  *time.Time `json:"created_date,omitempty"`
+		ModifiedDate *time.Time `json:"modified_date,omitempty"`
+	}{normalized(n), optionalTime(n.CreatedDate), optionalTime(n.ModifiedDate)})
+}
+
+// The tags the normalized properties are taken from, in order of
+// preference. Tags without a group match the tag in any group; the PDF
+// Creator is the creating application rather than the author.
+var (
+	titleTags    = []string{"Title", "ObjectName", "XPTitle", "DisplayName", "DocumentName"}
+	creatorTags  = []string{"Artist", "Author", "XMP:Creator", "By-line", "XPAuthor", "Creator"}
+	softwareTags = []string{"Software", "CreatorTool", "PDF:Creator", "Producer", "Encoder", "EncodedBy"}
+	createdTags  = []string{"DateTimeOriginal", "CreateDate", "DateCreated", "CreationDate", "ContentCreateDate"}
+	modifiedTags = []string{"ModifyDate", "ContentModifyDate", "MetadataDate"}
+	durationTags = []string{"Duration", "MediaDuration", "TrackDuration"}
+	sizeTags     = [][2]string{
+		{"ImageWidth", "ImageHeight"},
+		{"ExifImageWidth", "ExifImageHeight"},
+		{"SourceImageWidth", "SourceImageHeight"},
+	}
+)
+
+// imageSizePattern matches the ImageSize tag (e.g., "1920x1080").
+var imageSizePattern = regexp.MustCompile(`^\s*(\d+)\s*[x ]\s*(\d+)\s*$`)
+
+// normalizeMetadata maps the tags of Exif and ExifTimes to the normalized
+// properties, resolving tags present in several groups with the precedence
+// of the metadata. It returns nil if none is found.
+func normalizeMetadata(metadata Metadata) *Normalized {
+	precedence := metadata.exifPrecedence
+
+	n := &Normalized{Sources: make(map[string]string)}
+
+	// In PDF files, the Creator without a group is the creating
+	// application.
+	pdf := metadata.MimeType == "application/pdf"
+
+	text := func(field string, tags []string) string {
+		for _, tag := range tags {
+			if tag == "Creator" && pdf {
+				continue
+			}
+
+			key, v, ok := lookupExif(metadata.Exif, tag, precedence)
+			if !ok && tag == "PDF:Creator" && pdf {
+				key = "Creator"
+				v, ok = metadata.Exif[key]
+			}
+			if !ok {
+				continue
+			}
+			if s := textValue(v); s != "" {
+				n.Sources[field] = key
+				return s
+			}
+		}
+		return ""
+	}
+
+	date := func(field string, tags []string) time.Time {
+		for _, tag := range tags {
+			if key, t, ok := lookupExifTime(metadata.ExifTimes, tag, precedence); ok {
+				n.Sources[field] = key
+				return t
+			}
+		}
+		return time.Time{}
+	}
+
+	n.Title = text("Title", titleTags)
+	n.Creator = text("Creator", creatorTags)
+	n.Software = text("Software", softwareTags)
+	n.CreatedDate = date("CreatedDate", createdTags)
+	n.ModifiedDate = date("ModifiedDate", modifiedTags)
+
+	if gps := parseGPS(metadata.Exif, precedence); gps != nil {
+		n.GPS = gps
+		for _, tag := range []string{"GPSPosition", "GPSLatitude"} {
+			if key, _, ok := lookupExif(metadata.Exif, tag, precedence); ok {
+				n.Sources["GPS"] = key
+				break
+			}
+		}
+	}
+
+	for _, tag := range durationTags {
+		key, v, ok := lookupExif(metadata.Exif, tag, precedence)
+		if !ok {
+			continue
+		}
+		if d, ok := parseDuration(v); ok {
+			n.Duration = d
+			n.Sources["Duration"] = key
+			break
+		}
+	}
+
+	for _, tags := range sizeTags {
+		wKey, w, ok1 := lookupExif(metadata.Exif, tags[0], precedence)
+		_, h, ok2 := lookupExif(metadata.Exif, tags[1], precedence)
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		width, ok1 := intValue(w)
+		height, ok2 := intValue(h)
+		if ok1 && ok2 && width > 0 && height > 0 {
+			n.Width, n.Height = width, height
+			n.Sources["Dimensions"] = wKey
+			break
+		}
+	}
+	if n.Width == 0 {
+		if key, v, ok := lookupExif(metadata.Exif, "ImageSize", precedence); ok {
+			if m := imageSizePattern.FindStringSubmatch(stringValue(v)); m != nil {
+				n.Width, _ = strconv.Atoi(m[1])
+				n.Height, _ = strconv.Atoi(m[2])
+				n.Sources["Dimensions"] = key
+			}
+		}
+	}
+
+	if len(n.Sources) == 0 {
+		return nil
+	}
+
+	return n
+}
+
+// lookupExif returns the key and value of a tag. A tag with a group (e.g.,
+// "XMP:Creator") matches the grouped key or the tag in the nested group;
+// a tag without a group matches the plain key, or else the tag in the
+// group ranking first in the precedence list (see rankGroup).
+func lookupExif(exif ExifMetadata, tag string, precedence []string) (string, interface{}, bool) {
+	if v, ok := exif[tag]; ok {
+		return tag, v, true
+	}
+
+	if group, name, ok := strings.Cut(tag, ":"); ok {
+		if nested, ok := exif[group].(map[string]interface{}); ok {
+			if v, ok := nested[name]; ok {
+				return tag, v, true
+			}
+		}
+		return "", nil, false
+	}
+
+	var (
+		key   string
+		value interface{}
+		found bool
+		best  groupRank
+	)
+
+	for k, v := range exif {
+		group, name, ok := strings.Cut(k, ":")
+		if nested, isGroup := v.(map[string]interface{}); isGroup && !ok {
+			v, ok = nested[tag]
+			name = tag
+		}
+
+		if !ok || name != tag {
+			continue
+		}
+
+		if rank := rankGroup(group, precedence); !found || rank.less(best) {
+			key, value, found, best = group+":"+tag, v, true, rank
+		}
+	}
+
+	return key, value, found
+}
+
+// lookupExifTime is like lookupExif, but looks up the time of a tag in
+// ExifTimes.
+func lookupExifTime(times map[string]time.Time, tag string, precedence []string) (string, time.Time, bool) {
+	if t, ok := times[tag]; ok {
+		return tag, t, true
+	}
+	if strings.Contains(tag, ":") {
+		return "", time.Time{}, false
+	}
+
+	var (
+		key   string
+		value time.Time
+		found bool
+		best  groupRank
+	)
+
+	for k, t := range times {
+		group, name, ok := strings.Cut(k, ":")
+		if !ok || name != tag {
+			continue
+		}
+
+		if rank := rankGroup(group, precedence); !found || rank.less(best) {
+			key, value, found, best = k, t, true, rank
+		}
+	}
+
+	return key, value, found
+}
+
+// textValue returns a tag value as text; lists (e.g., several XMP
+// creators) are joined with commas.
+func textValue(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			if s := strings.TrimSpace(stringValue(item)); s != "" {
+				items = append(items, s)
+			}
+		}
+		return strings.Join(items, ", ")
+	}
+
+	return strings.TrimSpace(stringValue(v))
+}
+
+// intValue returns a tag value as an integer.
+func intValue(v interface{}) (int, bool) {
+	f, ok := numberValue(v)
+	if !ok {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// parseDuration parses a duration as printed by ExifTool: seconds (e.g.,
+// 12.5 or "12.50 s"), or hours, minutes and seconds (e.g., "0:03:25"),
+// optionally followed by "(approx)".
+func parseDuration(v interface{}) (float64, bool) {
+	if f, ok := v.(float64); ok {
+		return f, f > 0
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "(approx)"))
+	s = strings.TrimSpace(strings.TrimSuffix(s, " s"))
+
+	var seconds float64
+	for _, part := range strings.Split(s, ":") {
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil || f < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + f
+	}
+
+	return seconds, seconds > 0
+}
+
+// gpsAltitude returns the GPS altitude in meters, negative below sea
+// level. ExifTool prints it as "123.4 m" or "123.4 m Below Sea Level", or
+// as a number with the reference in GPSAltitudeRef.
+func gpsAltitude(exif ExifMetadata, precedence []string) (float64, bool) {
+	var alt float64
+	below := false
+
+	switch v := exifValue(exif, "GPSAltitude", precedence...).(type) {
+	case float64:
+		alt = v
+	case string:
+		fields := strings.Fields(v)
+		if len(fields) == 0 {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, false
+		}
+		alt = f
+		below = strings.Contains(v, "Below")
+	default:
+		return 0, false
+	}
+
+	switch ref := exifValue(exif, "GPSAltitudeRef", precedence...).(type) {
+	case float64:
+		below = below || ref == 1
+	case string:
+		below = below || strings.Contains(ref, "Below") || strings.TrimSpace(ref) == "1"
+	}
+
+	if below && alt > 0 {
+		alt = -alt
+	}
+
+	return alt, true
+}
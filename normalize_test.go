@@ -0,0 +1,203 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeMetadata(t *testing.T) {
+	created := time.Date(2024, 8, 3, 14, 2, 11, 0, time.UTC)
+	modified := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	alt := -12.5
+
+	tests := []struct {
+		name     string
+		metadata Metadata
+		want     *Normalized
+	}{
+		{
+			name: "Photo",
+			metadata: Metadata{
+				Exif: ExifMetadata{
+					"EXIF:Artist":          "Jane Doe",
+					"XMP:Creator":          "J. Doe",
+					"EXIF:Software":        "Firmware 1.2",
+					"EXIF:ExifImageWidth":  4000.0,
+					"EXIF:ExifImageHeight": 3000.0,
+					"GPSLatitude":          `52 deg 30' 0.00" N`,
+					"GPSLongitude":         `13 deg 24' 0.00" E`,
+					"GPSAltitude":          "12.5 m Below Sea Level",
+				},
+				ExifTimes: map[string]time.Time{
+					"XMP:CreateDate":        created.Add(time.Hour),
+					"EXIF:DateTimeOriginal": created,
+					"EXIF:ModifyDate":       modified,
+				},
+			},
+			want: &Normalized{
+				Creator:      "Jane Doe",
+				CreatedDate:  created,
+				ModifiedDate: modified,
+				Software:     "Firmware 1.2",
+				GPS:          &GPSCoordinates{Latitude: 52.5, Longitude: 13.4, Altitude: &alt},
+				Width:        4000,
+				Height:       3000,
+				Sources: map[string]string{
+					"Creator":      "EXIF:Artist",
+					"CreatedDate":  "EXIF:DateTimeOriginal",
+					"ModifiedDate": "EXIF:ModifyDate",
+					"Software":     "EXIF:Software",
+					"GPS":          "GPSLatitude",
+					"Dimensions":   "EXIF:ExifImageWidth",
+				},
+			},
+		},
+		{
+			name: "PDF",
+			metadata: Metadata{
+				MimeType: "application/pdf",
+				Exif: ExifMetadata{
+					"Title":    "Quarterly Report",
+					"Creator":  "Microsoft Word",
+					"Producer": "macOS Quartz PDFContext",
+				},
+			},
+			want: &Normalized{
+				Title:    "Quarterly Report",
+				Software: "Microsoft Word",
+				Sources:  map[string]string{"Title": "Title", "Software": "Creator"},
+			},
+		},
+		{
+			name: "Nested",
+			metadata: Metadata{
+				Exif: ExifMetadata{
+					"XMP": map[string]interface{}{"Creator": []interface{}{"Jane", "John"}, "Title": "Beach"},
+					"PDF": map[string]interface{}{"Creator": "Writer"},
+				},
+			},
+			want: &Normalized{
+				Title:    "Beach",
+				Creator:  "Jane, John",
+				Software: "Writer",
+				Sources:  map[string]string{"Title": "XMP:Title", "Creator": "XMP:Creator", "Software": "PDF:Creator"},
+			},
+		},
+		{
+			name: "Audio",
+			metadata: Metadata{
+				Exif: ExifMetadata{
+					"ID3:Title":    "Song",
+					"ID3:Artist":   "Band",
+					"ID3:Duration": "0:03:25 (approx)",
+					"ID3:Encoder":  "LAME3.100",
+				},
+			},
+			want: &Normalized{
+				Title:    "Song",
+				Creator:  "Band",
+				Software: "LAME3.100",
+				Duration: 205,
+				Sources: map[string]string{
+					"Title":    "ID3:Title",
+					"Creator":  "ID3:Artist",
+					"Software": "ID3:Encoder",
+					"Duration": "ID3:Duration",
+				},
+			},
+		},
+		{
+			name: "ImageSize",
+			metadata: Metadata{
+				Exif: ExifMetadata{"ImageSize": "1920x1080", "Duration": "12.50 s"},
+			},
+			want: &Normalized{
+				Duration: 12.5,
+				Width:    1920,
+				Height:   1080,
+				Sources:  map[string]string{"Duration": "Duration", "Dimensions": "ImageSize"},
+			},
+		},
+		{
+			name: "Configured Precedence",
+			metadata: Metadata{
+				Exif:           ExifMetadata{"XMP:Title": "XMP Title", "PDF:Title": "PDF Title"},
+				exifPrecedence: []string{"PDF", "XMP"},
+			},
+			want: &Normalized{
+				Title:   "PDF Title",
+				Sources: map[string]string{"Title": "PDF:Title"},
+			},
+		},
+		{
+			name:     "None",
+			metadata: Metadata{Exif: ExifMetadata{"FileSize": "10 kB"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeMetadata(tt.metadata))
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want float64
+		ok   bool
+	}{
+		{12.5, 12.5, true},
+		{"12.50 s", 12.5, true},
+		{"0:03:25", 205, true},
+		{"1:00:00 (approx)", 3600, true},
+		{"0 s", 0, false},
+		{"n/a", 0, false},
+		{nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseDuration(tt.in)
+		assert.Equal(t, tt.ok, ok, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestNormalized_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Normalized{Title: "Beach", Sources: map[string]string{"Title": "Title"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title":"Beach","sources":{"Title":"Title"}}`, string(data))
+
+	var n Normalized
+	data, err = json.Marshal(Normalized{CreatedDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &n))
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), n.CreatedDate)
+}
+
+func TestRedactionPolicy_Normalized(t *testing.T) {
+	metadata := Metadata{
+		Exif: ExifMetadata{
+			"Artist":       "Jane Doe",
+			"Title":        "Beach",
+			"GPSLatitude":  52.5123,
+			"GPSLongitude": 13.4567,
+		},
+	}
+	metadata.Normalized = normalizeMetadata(metadata)
+	require.Equal(t, "Jane Doe", metadata.Normalized.Creator)
+
+	redacted := DefaultRedactionPolicy.Apply(metadata)
+	require.NotNil(t, redacted.Normalized)
+	assert.Empty(t, redacted.Normalized.Creator)
+	assert.Equal(t, "Beach", redacted.Normalized.Title)
+	assert.Equal(t, &GPSCoordinates{Latitude: 52.51, Longitude: 13.45}, redacted.Normalized.GPS)
+
+	// The metadata passed in is not modified.
+	assert.Equal(t, "Jane Doe", metadata.Normalized.Creator)
+}
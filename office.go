@@ -0,0 +1,497 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+)
+
+// Streams of a password-protected OOXML document (MS-OFFCRYPTO): the
+// encrypted ZIP package is stored in a compound file next to the parameters
+// needed to derive its key.
+const (
+	officeEncryptionInfo   = "EncryptionInfo"
+	officeEncryptedPackage = "EncryptedPackage"
+)
+
+const (
+	// officeSegmentSize is the size of the segments of an Agile-encrypted
+	// package, each encrypted with its own IV.
+	officeSegmentSize = 4096
+
+	// officeMaxSpinCount is the largest hash iteration count allowed by
+	// MS-OFFCRYPTO.
+	officeMaxSpinCount = 10000000
+
+	// officeStandardSpinCount is the hash iteration count of Standard
+	// encryption.
+	officeStandardSpinCount = 50000
+)
+
+// Block keys of the Agile key derivation.
+var (
+	officeBlockVerifierInput = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	officeBlockVerifierValue = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+	officeBlockKeyValue      = []byte{0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6}
+)
+
+// errInvalidEncryptionInfo is returned for malformed EncryptionInfo streams.
+var errInvalidEncryptionInfo = errors.New("invalid encryption info")
+
+// readEncryptedOffice returns the EncryptionInfo and EncryptedPackage streams
+// of the file. ok is false if the file is not a password-protected OOXML
+// document.
+func readEncryptedOffice(filePath string) (info, pkg []byte, ok bool, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, len(cfbMagic))
+	if _, err := io.ReadFull(f, head); err != nil || !bytes.Equal(head, cfbMagic) {
+		return nil, nil, false, nil
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	c, err := openCFB(f, stat.Size())
+	if err != nil {
+		return nil, nil, false, nil
+	}
+
+	streams := make(map[string]cfbEntry)
+	for _, id := range c.children(0) {
+		if e := c.entries[id]; e.kind == cfbStream {
+			streams[e.name] = e
+		}
+	}
+
+	infoEntry, ok := streams[officeEncryptionInfo]
+	if !ok {
+		return nil, nil, false, nil
+	}
+	pkgEntry, ok := streams[officeEncryptedPackage]
+	if !ok {
+		return nil, nil, false, nil
+	}
+
+	if info, err = c.stream(infoEntry); err != nil {
+		return nil, nil, false, err
+	}
+	if pkg, err = c.stream(pkgEntry); err != nil {
+		return nil, nil, false, err
+	}
+
+	return info, pkg, true, nil
+}
+
+// decryptOffice decrypts the package of a password-protected OOXML document
+// with the configured passwords. It returns the decrypted ZIP package and the
+// password that worked, or a nil package if the file is not a
+// password-protected OOXML document.
+func (me *MetaExtractor) decryptOffice(filePath string) ([]byte, string, error) {
+	info, pkg, ok, err := readEncryptedOffice(filePath)
+	if err != nil || !ok {
+		return nil, "", err
+	}
+
+	for _, password := range me.passwords {
+		data, err := decryptOfficePackage(info, pkg, password)
+		if errors.Is(err, ErrNoValidPassword) {
+			continue
+		}
+
+		return data, password, err
+	}
+
+	return nil, "", ErrNoValidPassword
+}
+
+// extractProtectedOfficeExifData decrypts a password-protected OOXML document
+// and extracts the EXIF metadata of the decrypted package. The path-dependent
+// tags are those of filePath. It returns a nil map if the file is not a
+// password-protected OOXML document.
+func (me *MetaExtractor) extractProtectedOfficeExifData(ctx context.Context, filePath string, times FileTime) (ExifMetadata, string, error) {
+	data, password, err := me.decryptOffice(filePath)
+	if err != nil || data == nil {
+		return nil, "", err
+	}
+
+	tmpFile, cleanup, err := spoolEntry(bytes.NewReader(data), filepath.Base(filePath))
+	if err != nil {
+		return nil, "", err
+	}
+	defer cleanup()
+
+	exif, err := me.extractExifData(ctx, tmpFile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return shareExif(exif, pathExifValues(filePath, info, times)), password, nil
+}
+
+// decryptOfficePackage decrypts the EncryptedPackage stream of an OOXML
+// document with the password. Standard and Agile AES encryption are
+// supported.
+func decryptOfficePackage(info, pkg []byte, password string) ([]byte, error) {
+	if len(info) < 8 {
+		return nil, errInvalidEncryptionInfo
+	}
+
+	major := binary.LittleEndian.Uint16(info)
+	minor := binary.LittleEndian.Uint16(info[2:])
+
+	switch {
+	case major == 4 && minor == 4:
+		return decryptAgile(info[8:], pkg, password)
+	case (major == 3 || major == 4) && minor == 2:
+		return decryptStandard(info[8:], pkg, password)
+	}
+
+	return nil, fmt.Errorf("%w: Office encryption version %d.%d", ErrUnsupportedEncryption, major, minor)
+}
+
+// agileEncryption is the XML descriptor of Agile encryption.
+type agileEncryption struct {
+	KeyData       agileKeyData `xml:"keyData"`
+	KeyEncryptors []struct {
+		EncryptedKey *agileEncryptedKey `xml:"encryptedKey"`
+	} `xml:"keyEncryptors>keyEncryptor"`
+}
+
+// agileKeyData describes the encryption of the package.
+type agileKeyData struct {
+	SaltValue       string `xml:"saltValue,attr"`
+	BlockSize       int    `xml:"blockSize,attr"`
+	KeyBits         int    `xml:"keyBits,attr"`
+	HashAlgorithm   string `xml:"hashAlgorithm,attr"`
+	CipherAlgorithm string `xml:"cipherAlgorithm,attr"`
+	CipherChaining  string `xml:"cipherChaining,attr"`
+}
+
+// agileEncryptedKey describes the password key encryptor.
+type agileEncryptedKey struct {
+	agileKeyData
+	SpinCount                  int    `xml:"spinCount,attr"`
+	EncryptedVerifierHashInput string `xml:"encryptedVerifierHashInput,attr"`
+	EncryptedVerifierHashValue string `xml:"encryptedVerifierHashValue,attr"`
+	EncryptedKeyValue          string `xml:"encryptedKeyValue,attr"`
+}
+
+// decryptAgile decrypts a package with Agile encryption.
+func decryptAgile(descriptor, pkg []byte, password string) ([]byte, error) {
+	var enc agileEncryption
+	if err := xml.Unmarshal(descriptor, &enc); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidEncryptionInfo, err)
+	}
+
+	var key *agileEncryptedKey
+	for _, ke := range enc.KeyEncryptors {
+		if ke.EncryptedKey != nil {
+			key = ke.EncryptedKey
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: no password key encryptor", ErrUnsupportedEncryption)
+	}
+
+	if err := checkAgileKeyData(enc.KeyData); err != nil {
+		return nil, err
+	}
+	if err := checkAgileKeyData(key.agileKeyData); err != nil {
+		return nil, err
+	}
+	if key.SpinCount < 0 || key.SpinCount > officeMaxSpinCount {
+		return nil, errInvalidEncryptionInfo
+	}
+
+	newHash := officeHash(key.HashAlgorithm)
+	keySalt, err1 := base64.StdEncoding.DecodeString(key.SaltValue)
+	verifierInput, err2 := base64.StdEncoding.DecodeString(key.EncryptedVerifierHashInput)
+	verifierValue, err3 := base64.StdEncoding.DecodeString(key.EncryptedVerifierHashValue)
+	keyValue, err4 := base64.StdEncoding.DecodeString(key.EncryptedKeyValue)
+	dataSalt, err5 := base64.StdEncoding.DecodeString(enc.KeyData.SaltValue)
+	if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidEncryptionInfo, err)
+	}
+
+	h := officePasswordHash(newHash, keySalt, password, key.SpinCount)
+	keyBytes := key.KeyBits / 8
+	iv := officeIV(keySalt, key.BlockSize)
+
+	input, err := aesCBCDecrypt(agileKey(newHash, h, officeBlockVerifierInput, keyBytes), iv, verifierInput)
+	if err != nil {
+		return nil, err
+	}
+	value, err := aesCBCDecrypt(agileKey(newHash, h, officeBlockVerifierValue, keyBytes), iv, verifierValue)
+	if err != nil {
+		return nil, err
+	}
+
+	inputHash := newHash()
+	inputHash.Write(input[:min(len(input), len(keySalt))])
+	sum := inputHash.Sum(nil)
+	if len(value) < len(sum) || !bytes.Equal(sum, value[:len(sum)]) {
+		return nil, ErrNoValidPassword
+	}
+
+	secret, err := aesCBCDecrypt(agileKey(newHash, h, officeBlockKeyValue, keyBytes), iv, keyValue)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) < enc.KeyData.KeyBits/8 {
+		return nil, errInvalidEncryptionInfo
+	}
+	secret = secret[:enc.KeyData.KeyBits/8]
+
+	size, data, err := officePackageData(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	dataHash := officeHash(enc.KeyData.HashAlgorithm)
+	out := make([]byte, 0, len(data))
+	var index [4]byte
+	for i := 0; len(data) > 0; i++ {
+		n := min(len(data), officeSegmentSize)
+
+		binary.LittleEndian.PutUint32(index[:], uint32(i))
+		ivHash := dataHash()
+		ivHash.Write(dataSalt)
+		ivHash.Write(index[:])
+
+		segment, err := aesCBCDecrypt(secret, officeIV(ivHash.Sum(nil), enc.KeyData.BlockSize), data[:n])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, segment...)
+		data = data[n:]
+	}
+
+	return out[:size], nil
+}
+
+// checkAgileKeyData reports whether the key data uses a supported cipher and
+// hash algorithm.
+func checkAgileKeyData(kd agileKeyData) error {
+	if kd.CipherAlgorithm != "AES" || kd.CipherChaining != "ChainingModeCBC" {
+		return fmt.Errorf("%w: Office cipher %s (%s)", ErrUnsupportedEncryption, kd.CipherAlgorithm, kd.CipherChaining)
+	}
+	if officeHash(kd.HashAlgorithm) == nil {
+		return fmt.Errorf("%w: Office hash algorithm %s", ErrUnsupportedEncryption, kd.HashAlgorithm)
+	}
+	if kd.BlockSize != aes.BlockSize || (kd.KeyBits != 128 && kd.KeyBits != 192 && kd.KeyBits != 256) {
+		return errInvalidEncryptionInfo
+	}
+
+	return nil
+}
+
+// officeHash returns the constructor of the named hash algorithm, or nil if
+// it is not supported.
+func officeHash(name string) func() hash.Hash {
+	switch name {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA384":
+		return sha512.New384
+	case "SHA512":
+		return sha512.New
+	}
+
+	return nil
+}
+
+// officePasswordHash hashes the salt and the UTF-16LE password, then rehashes
+// the result spinCount times, each time prefixed with the iteration number.
+func officePasswordHash(newHash func() hash.Hash, salt []byte, password string, spinCount int) []byte {
+	units := utf16.Encode([]rune(password))
+	pw := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(pw[2*i:], u)
+	}
+
+	h := newHash()
+	h.Write(salt)
+	h.Write(pw)
+	sum := h.Sum(nil)
+
+	var iteration [4]byte
+	for i := 0; i < spinCount; i++ {
+		binary.LittleEndian.PutUint32(iteration[:], uint32(i))
+		h.Reset()
+		h.Write(iteration[:])
+		h.Write(sum)
+		sum = h.Sum(sum[:0])
+	}
+
+	return sum
+}
+
+// agileKey derives a key of the given size from the password hash and the
+// block key, padding it with 0x36 bytes if the hash is too short.
+func agileKey(newHash func() hash.Hash, passwordHash, blockKey []byte, size int) []byte {
+	h := newHash()
+	h.Write(passwordHash)
+	h.Write(blockKey)
+
+	return officeIV(h.Sum(nil), size)
+}
+
+// officeIV truncates b to size bytes or pads it with 0x36 bytes.
+func officeIV(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b[:size]
+	}
+
+	return append(b[:len(b):len(b)], bytes.Repeat([]byte{0x36}, size-len(b))...)
+}
+
+// officePackageData returns the decrypted size and the encrypted data of an
+// EncryptedPackage stream.
+func officePackageData(pkg []byte) (uint64, []byte, error) {
+	if len(pkg) < 8 {
+		return 0, nil, errInvalidEncryptionInfo
+	}
+
+	size := binary.LittleEndian.Uint64(pkg)
+	data := pkg[8:]
+	data = data[:len(data)/aes.BlockSize*aes.BlockSize]
+	if size > uint64(len(data)) {
+		return 0, nil, errInvalidEncryptionInfo
+	}
+
+	return size, data, nil
+}
+
+// aesCBCDecrypt decrypts data with AES in CBC mode.
+func aesCBCDecrypt(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 || len(iv) != aes.BlockSize {
+		return nil, errInvalidEncryptionInfo
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	return out, nil
+}
+
+// Algorithm IDs of Standard encryption.
+const (
+	officeAlgAES128 = 0x660e
+	officeAlgAES192 = 0x660f
+	officeAlgAES256 = 0x6610
+	officeAlgSHA1   = 0x8004
+)
+
+// decryptStandard decrypts a package with Standard encryption, which uses
+// AES in ECB mode with a key derived from SHA-1.
+func decryptStandard(b, pkg []byte, password string) ([]byte, error) {
+	if len(b) < 4 {
+		return nil, errInvalidEncryptionInfo
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(b))
+	if headerSize < 32 || len(b) < 4+headerSize+4+16+16+4+32 {
+		return nil, errInvalidEncryptionInfo
+	}
+
+	header := b[4 : 4+headerSize]
+	algID := binary.LittleEndian.Uint32(header[8:])
+	algIDHash := binary.LittleEndian.Uint32(header[12:])
+	keyBits := int(binary.LittleEndian.Uint32(header[16:]))
+
+	switch algID {
+	case officeAlgAES128, officeAlgAES192, officeAlgAES256:
+	default:
+		return nil, fmt.Errorf("%w: Office algorithm 0x%04x", ErrUnsupportedEncryption, algID)
+	}
+	if algIDHash != 0 && algIDHash != officeAlgSHA1 {
+		return nil, fmt.Errorf("%w: Office hash algorithm 0x%04x", ErrUnsupportedEncryption, algIDHash)
+	}
+	if keyBits != 128 && keyBits != 192 && keyBits != 256 {
+		return nil, errInvalidEncryptionInfo
+	}
+
+	verifier := b[4+headerSize:]
+	if binary.LittleEndian.Uint32(verifier) != 16 {
+		return nil, errInvalidEncryptionInfo
+	}
+	salt := verifier[4:20]
+	encryptedVerifier := verifier[20:36]
+	encryptedVerifierHash := verifier[40:72]
+
+	h := officePasswordHash(sha1.New, salt, password, officeStandardSpinCount)
+	final := sha1.Sum(append(h, 0, 0, 0, 0))
+
+	var pad1, pad2 [64]byte
+	for i := range pad1 {
+		pad1[i], pad2[i] = 0x36, 0x5c
+		if i < len(final) {
+			pad1[i] ^= final[i]
+			pad2[i] ^= final[i]
+		}
+	}
+	x1, x2 := sha1.Sum(pad1[:]), sha1.Sum(pad2[:])
+	key := append(x1[:], x2[:]...)[:keyBits/8]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plainVerifier := aesECBDecrypt(block, encryptedVerifier)
+	plainVerifierHash := aesECBDecrypt(block, encryptedVerifierHash)
+	sum := sha1.Sum(plainVerifier)
+	if !bytes.Equal(sum[:], plainVerifierHash[:len(sum)]) {
+		return nil, ErrNoValidPassword
+	}
+
+	size, data, err := officePackageData(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	return aesECBDecrypt(block, data)[:size], nil
+}
+
+// aesECBDecrypt decrypts whole blocks of data with AES in ECB mode.
+func aesECBDecrypt(block cipher.Block, data []byte) []byte {
+	out := make([]byte, len(data))
+	for i := 0; i+aes.BlockSize <= len(data); i += aes.BlockSize {
+		block.Decrypt(out[i:], data[i:])
+	}
+
+	return out
+}
@@ -0,0 +1,225 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// officePackageBytes returns a minimal OOXML package.
+func officePackageBytes(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"[Content_Types].xml", "word/document.xml"} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(bytes.Repeat([]byte("<w:p/>"), 1000))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+// aesCBCEncrypt encrypts data with AES in CBC mode, padding it with zeros to
+// a whole number of blocks.
+func aesCBCEncrypt(t *testing.T, key, iv, data []byte) []byte {
+	t.Helper()
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+
+	out := make([]byte, (len(data)+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+	copy(out, data)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+
+	return out
+}
+
+// agileOfficeBytes returns a compound file holding pkg encrypted with Agile
+// encryption (AES-256, SHA-512).
+func agileOfficeBytes(t *testing.T, pkg []byte, password string) []byte {
+	t.Helper()
+
+	keySalt := bytes.Repeat([]byte{0x01}, 16)
+	dataSalt := bytes.Repeat([]byte{0x02}, 16)
+	secret := bytes.Repeat([]byte{0x03}, 32)
+	verifier := bytes.Repeat([]byte{0x04}, 16)
+	const spinCount = 1000
+
+	h := officePasswordHash(sha512.New, keySalt, password, spinCount)
+	verifierHash := sha512.Sum512(verifier)
+
+	encryptedInput := aesCBCEncrypt(t, agileKey(sha512.New, h, officeBlockVerifierInput, 32), keySalt, verifier)
+	encryptedValue := aesCBCEncrypt(t, agileKey(sha512.New, h, officeBlockVerifierValue, 32), keySalt, verifierHash[:])
+	encryptedKey := aesCBCEncrypt(t, agileKey(sha512.New, h, officeBlockKeyValue, 32), keySalt, secret)
+
+	stream := binary.LittleEndian.AppendUint64(nil, uint64(len(pkg)))
+	var index [4]byte
+	for i := 0; i*officeSegmentSize < len(pkg); i++ {
+		segment := pkg[i*officeSegmentSize : min(len(pkg), (i+1)*officeSegmentSize)]
+
+		binary.LittleEndian.PutUint32(index[:], uint32(i))
+		iv := sha512.Sum512(append(append([]byte(nil), dataSalt...), index[:]...))
+		stream = append(stream, aesCBCEncrypt(t, secret, iv[:16], segment)...)
+	}
+
+	b64 := base64.StdEncoding.EncodeToString
+	descriptor := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<encryption xmlns="http://schemas.microsoft.com/office/2006/encryption" xmlns:p="http://schemas.microsoft.com/office/2006/keyEncryptor/password">
+<keyData saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="%s"/>
+<keyEncryptors><keyEncryptor uri="http://schemas.microsoft.com/office/2006/keyEncryptor/password">
+<p:encryptedKey spinCount="%d" saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="%s" encryptedVerifierHashInput="%s" encryptedVerifierHashValue="%s" encryptedKeyValue="%s"/>
+</keyEncryptor></keyEncryptors>
+</encryption>`, b64(dataSalt), spinCount, b64(keySalt), b64(encryptedInput), b64(encryptedValue), b64(encryptedKey))
+
+	info := []byte{4, 0, 4, 0, 0x40, 0, 0, 0}
+	info = append(info, descriptor...)
+
+	return cfbBytes(t, []cfbNode{
+		{name: officeEncryptionInfo, data: info},
+		{name: officeEncryptedPackage, data: stream},
+	})
+}
+
+// standardEncryptionInfo returns the EncryptionInfo stream and the key of
+// Standard encryption (AES-128) with the password.
+func standardEncryptionInfo(t *testing.T, password string) ([]byte, cipher.Block) {
+	t.Helper()
+
+	salt := bytes.Repeat([]byte{0x05}, 16)
+	verifier := bytes.Repeat([]byte{0x06}, 16)
+
+	h := officePasswordHash(sha1.New, salt, password, officeStandardSpinCount)
+	final := sha1.Sum(append(h, 0, 0, 0, 0))
+	var pad [64]byte
+	for i := range pad {
+		pad[i] = 0x36
+		if i < len(final) {
+			pad[i] ^= final[i]
+		}
+	}
+	x1 := sha1.Sum(pad[:])
+
+	block, err := aes.NewCipher(x1[:16])
+	require.NoError(t, err)
+
+	ecb := func(data []byte) []byte {
+		out := make([]byte, (len(data)+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+		copy(out, data)
+		for i := 0; i < len(out); i += aes.BlockSize {
+			block.Encrypt(out[i:], out[i:])
+		}
+		return out
+	}
+	verifierHash := sha1.Sum(verifier)
+
+	header := make([]byte, 32)
+	binary.LittleEndian.PutUint32(header[0:], 0x24)
+	binary.LittleEndian.PutUint32(header[8:], officeAlgAES128)
+	binary.LittleEndian.PutUint32(header[12:], officeAlgSHA1)
+	binary.LittleEndian.PutUint32(header[16:], 128)
+
+	info := []byte{4, 0, 2, 0, 0x24, 0, 0, 0}
+	info = binary.LittleEndian.AppendUint32(info, uint32(len(header)))
+	info = append(info, header...)
+	info = binary.LittleEndian.AppendUint32(info, 16)
+	info = append(info, salt...)
+	info = append(info, ecb(verifier)...)
+	info = binary.LittleEndian.AppendUint32(info, sha1.Size)
+	info = append(info, ecb(verifierHash[:])...)
+
+	return info, block
+}
+
+func TestDecryptOfficePackage(t *testing.T) {
+	pkg := officePackageBytes(t)
+
+	t.Run("Agile", func(t *testing.T) {
+		me := NewMetaExtractor(Options{Passwords: []string{"wrong", "hunter2"}})
+		filePath := filepath.Join(createTree(t, map[string]string{
+			"secret.docx": string(agileOfficeBytes(t, pkg, "hunter2")),
+		}), "secret.docx")
+
+		data, password, err := me.decryptOffice(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, pkg, data)
+		assert.Equal(t, "hunter2", password)
+	})
+
+	t.Run("Standard", func(t *testing.T) {
+		info, block := standardEncryptionInfo(t, "hunter2")
+
+		stream := binary.LittleEndian.AppendUint64(nil, uint64(len(pkg)))
+		encrypted := make([]byte, (len(pkg)+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+		copy(encrypted, pkg)
+		for i := 0; i < len(encrypted); i += aes.BlockSize {
+			block.Encrypt(encrypted[i:], encrypted[i:])
+		}
+		stream = append(stream, encrypted...)
+
+		data, err := decryptOfficePackage(info, stream, "hunter2")
+		require.NoError(t, err)
+		assert.Equal(t, pkg, data)
+
+		_, err = decryptOfficePackage(info, stream, "wrong")
+		assert.ErrorIs(t, err, ErrNoValidPassword)
+	})
+
+	t.Run("No Valid Password", func(t *testing.T) {
+		me := NewMetaExtractor(Options{Passwords: []string{"wrong"}})
+		filePath := filepath.Join(createTree(t, map[string]string{
+			"secret.docx": string(agileOfficeBytes(t, pkg, "hunter2")),
+		}), "secret.docx")
+
+		_, _, err := me.decryptOffice(filePath)
+		assert.ErrorIs(t, err, ErrNoValidPassword)
+	})
+
+	t.Run("Unsupported Version", func(t *testing.T) {
+		_, err := decryptOfficePackage([]byte{3, 0, 3, 0, 0, 0, 0, 0}, nil, "hunter2")
+		assert.ErrorIs(t, err, ErrUnsupportedEncryption)
+	})
+
+	t.Run("Not Encrypted", func(t *testing.T) {
+		me := NewMetaExtractor(Options{Passwords: []string{"hunter2"}})
+		filePath := filepath.Join(createTree(t, map[string]string{
+			"plain.docx": string(pkg),
+		}), "plain.docx")
+
+		data, password, err := me.decryptOffice(filePath)
+		require.NoError(t, err)
+		assert.Nil(t, data)
+		assert.Empty(t, password)
+	})
+}
+
+func TestExtractDir_EncryptedOffice(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"secret.docx": string(agileOfficeBytes(t, officePackageBytes(t), "hunter2")),
+	})
+
+	me := NewMetaExtractor(Options{Passwords: []string{"hunter2"}})
+
+	results, err := me.ExtractDir(root, WalkOptions{Archives: true})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	entry := results[2]
+	assert.Equal(t, filepath.Join(root, "secret.docx")+"!/word/document.xml", entry.Path)
+	assert.Equal(t, "hunter2", entry.Metadata.Password)
+	assert.Equal(t, int64(6000), entry.Metadata.Size)
+}
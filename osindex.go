@@ -0,0 +1,64 @@
+package metaextractor
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indexTimeout is the maximum duration of a query of the OS search index.
+const indexTimeout = 10 * time.Second
+
+// parseSpotlightValues parses the output of `mdls -raw`, which holds the
+// values of the requested attributes separated by NUL characters. Arrays
+// (e.g., kMDItemKeywords) are returned as []string and numbers as float64;
+// missing attributes ("(null)") are skipped.
+func parseSpotlightValues(output string, names []string) map[string]interface{} {
+	values := strings.Split(output, "\x00")
+	props := make(map[string]interface{})
+
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+
+		value := strings.TrimSpace(values[i])
+		if value == "" || value == "(null)" {
+			continue
+		}
+
+		props[name] = parseSpotlightValue(value)
+	}
+
+	return props
+}
+
+// parseSpotlightValue parses a single value printed by mdls.
+func parseSpotlightValue(value string) interface{} {
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		items := []string{}
+		for _, line := range strings.Split(value[1:len(value)-1], "\n") {
+			if line = strings.TrimSuffix(strings.TrimSpace(line), ","); line != "" {
+				items = append(items, unquoteSpotlight(line))
+			}
+		}
+		return items
+	}
+
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n
+	}
+
+	return unquoteSpotlight(value)
+}
+
+// unquoteSpotlight removes the quotes mdls prints around strings in arrays.
+func unquoteSpotlight(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
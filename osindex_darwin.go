@@ -0,0 +1,36 @@
+//go:build darwin
+
+package metaextractor
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// DefaultIndexProperties are the Spotlight attributes read by
+// Options.IndexProperties by default.
+var DefaultIndexProperties = []string{
+	"kMDItemTitle", "kMDItemAuthors", "kMDItemKeywords", "kMDItemStarRating",
+	"kMDItemFinderComment", "kMDItemUserTags", "kMDItemWhereFroms",
+}
+
+// readIndexProperties reads the given Spotlight attributes of the file
+// using mdls.
+func readIndexProperties(filePath string, names []string) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+
+	args := make([]string, 0, 2*len(names)+2)
+	for _, name := range names {
+		args = append(args, "-name", name)
+	}
+	args = append(args, "-raw", filePath)
+
+	out, err := exec.CommandContext(ctx, "mdls", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading Spotlight attributes: %w", err)
+	}
+
+	return parseSpotlightValues(string(out), names), nil
+}
@@ -0,0 +1,13 @@
+//go:build !darwin && !windows
+
+package metaextractor
+
+// DefaultIndexProperties is empty on platforms without a supported OS search
+// index.
+var DefaultIndexProperties []string
+
+// readIndexProperties returns no properties; only macOS (Spotlight) and
+// Windows (Shell property store) are supported.
+func readIndexProperties(filePath string, names []string) (map[string]interface{}, error) {
+	return nil, nil
+}
@@ -0,0 +1,28 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpotlightValues(t *testing.T) {
+	names := []string{"kMDItemTitle", "kMDItemStarRating", "kMDItemKeywords", "kMDItemAuthors", "kMDItemComment"}
+	output := "Holiday\x004\x00(\n    \"beach\",\n    \"summer \\\"23\\\"\"\n)\x00(null)\x00"
+
+	assert.Equal(t, map[string]interface{}{
+		"kMDItemTitle":      "Holiday",
+		"kMDItemStarRating": 4.0,
+		"kMDItemKeywords":   []string{"beach", `summer "23"`},
+	}, parseSpotlightValues(output, names))
+}
+
+func TestMetaExtractor_IndexPropertiesPureGo(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true, IndexProperties: []string{"kMDItemTitle"}})
+
+	metadata, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+	assert.Nil(t, metadata.IndexProperties)
+}
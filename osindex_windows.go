@@ -0,0 +1,59 @@
+//go:build windows
+
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultIndexProperties are the Shell properties read by
+// Options.IndexProperties by default.
+var DefaultIndexProperties = []string{
+	"System.Title", "System.Author", "System.Keywords", "System.Rating",
+	"System.Comment", "System.Media.SubTitle", "System.Music.Artist",
+}
+
+// shellPropertyScript reads Shell properties of the file passed in the
+// environment and prints them as a JSON object.
+const shellPropertyScript = `$ErrorActionPreference = 'Stop'
+$file = Get-Item -LiteralPath $env:METAEXTRACTOR_FILE
+$item = (New-Object -ComObject Shell.Application).Namespace($file.DirectoryName).ParseName($file.Name)
+$props = @{}
+foreach ($name in $env:METAEXTRACTOR_PROPERTIES.Split(',')) {
+	$value = $item.ExtendedProperty($name)
+	if ($null -ne $value -and "$value" -ne '') { $props[$name] = $value }
+}
+$props | ConvertTo-Json -Compress -Depth 3`
+
+// readIndexProperties reads the given Shell property-store values of the file
+// using PowerShell.
+func readIndexProperties(filePath string, names []string) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", shellPropertyScript)
+	cmd.Env = append(os.Environ(),
+		"METAEXTRACTOR_FILE="+filePath,
+		"METAEXTRACTOR_PROPERTIES="+strings.Join(names, ","),
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading Shell properties: %w", err)
+	}
+
+	props := make(map[string]interface{})
+	if out = bytes.TrimSpace(out); len(out) > 0 {
+		if err := json.Unmarshal(out, &props); err != nil {
+			return nil, fmt.Errorf("error reading Shell properties: %w", err)
+		}
+	}
+
+	return props, nil
+}
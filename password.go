@@ -0,0 +1,179 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrNoValidPassword is returned when a protected file cannot be opened
+	// with any of the configured passwords.
+	ErrNoValidPassword = errors.New("no valid password")
+
+	// ErrUnsupportedEncryption is returned for encryption schemes that cannot
+	// be decrypted (e.g., AES-encrypted ZIP entries).
+	ErrUnsupportedEncryption = errors.New("unsupported encryption")
+)
+
+// isPasswordProtected reports whether ExifTool could not fully process the
+// file because it is password protected.
+func isPasswordProtected(exif ExifMetadata) bool {
+	warning, ok := exifValue(exif, "Warning").(string)
+	return ok && strings.Contains(strings.ToLower(warning), "password protected")
+}
+
+// extractProtectedExifData retries EXIF extraction of a password-protected
+// document (e.g., an encrypted PDF) with each configured password. It returns
+// the metadata and the password that worked.
+func (me *MetaExtractor) extractProtectedExifData(ctx context.Context, filePath string) (ExifMetadata, string, error) {
+	for _, password := range me.passwords {
+		opts := append(append([]exifToolOption(nil), me.exifToolOpts...), passwordOption(password))
+
+		exif, err := runExifTool(ctx, me.exifTools.cmd, filePath, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !isPasswordProtected(exif) {
+			return exif, password, nil
+		}
+	}
+
+	return nil, "", ErrNoValidPassword
+}
+
+// openZipEntry opens a ZIP entry, decrypting it with the configured passwords
+// if it is encrypted. It returns the password that worked, if any.
+func (me *MetaExtractor) openZipEntry(f *zip.File) (io.ReadCloser, string, error) {
+	if f.Flags&0x1 == 0 {
+		rc, err := f.Open()
+		return rc, "", err
+	}
+
+	// Method 99 indicates WinZip AES encryption.
+	if f.Method == 99 {
+		return nil, "", ErrUnsupportedEncryption
+	}
+
+	for _, password := range me.passwords {
+		ok, err := verifyZipPassword(f, password)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if ok {
+			rc, err := openZipCrypto(f, password)
+			return rc, password, err
+		}
+	}
+
+	return nil, "", ErrNoValidPassword
+}
+
+// verifyZipPassword decrypts the whole entry with the given password and
+// compares its checksum. The check byte of the encryption header alone
+// accepts 1 in 256 wrong passwords.
+func verifyZipPassword(f *zip.File, password string) (bool, error) {
+	rc, err := openZipCrypto(f, password)
+	if errors.Is(err, ErrNoValidPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, rc); err != nil {
+		// Decompression fails for wrong passwords that pass the header check.
+		return false, nil
+	}
+
+	return h.Sum32() == f.CRC32, nil
+}
+
+// openZipCrypto opens an entry encrypted with traditional PKWARE encryption
+// (ZipCrypto). It returns ErrNoValidPassword if the check byte of the
+// encryption header does not match.
+func openZipCrypto(f *zip.File, password string) (io.ReadCloser, error) {
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	zc := newZipCrypto([]byte(password))
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, err
+	}
+	zc.decrypt(header)
+
+	// The last header byte is the high byte of the CRC, or of the
+	// modification time if the data descriptor flag is set.
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&0x8 != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if header[11] != check {
+		return nil, ErrNoValidPassword
+	}
+
+	r := &zipCryptoReader{r: raw, zc: zc}
+
+	switch f.Method {
+	case zip.Store:
+		return io.NopCloser(r), nil
+	case zip.Deflate:
+		return flate.NewReader(r), nil
+	}
+
+	return nil, fmt.Errorf("%w: compression method %d", zip.ErrAlgorithm, f.Method)
+}
+
+// zipCrypto implements the traditional PKWARE stream cipher.
+type zipCrypto struct {
+	keys [3]uint32
+}
+
+func newZipCrypto(password []byte) *zipCrypto {
+	zc := &zipCrypto{keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+	for _, b := range password {
+		zc.update(b)
+	}
+
+	return zc
+}
+
+func (zc *zipCrypto) update(b byte) {
+	zc.keys[0] = crc32.IEEETable[byte(zc.keys[0])^b] ^ (zc.keys[0] >> 8)
+	zc.keys[1] = (zc.keys[1]+zc.keys[0]&0xff)*134775813 + 1
+	zc.keys[2] = crc32.IEEETable[byte(zc.keys[2])^byte(zc.keys[1]>>24)] ^ (zc.keys[2] >> 8)
+}
+
+func (zc *zipCrypto) decrypt(buf []byte) {
+	for i, c := range buf {
+		temp := uint16(zc.keys[2]) | 2
+		p := c ^ byte((uint32(temp)*uint32(temp^1))>>8)
+		zc.update(p)
+		buf[i] = p
+	}
+}
+
+// zipCryptoReader decrypts a ZipCrypto stream.
+type zipCryptoReader struct {
+	r  io.Reader
+	zc *zipCrypto
+}
+
+func (r *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.zc.decrypt(p[:n])
+	return n, err
+}
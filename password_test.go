@@ -0,0 +1,100 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encryptedZipBytes returns a ZIP archive with a single stored entry encrypted
+// with traditional PKWARE encryption.
+func encryptedZipBytes(t *testing.T, name string, content []byte, password string) []byte {
+	t.Helper()
+
+	crc := crc32.ChecksumIEEE(content)
+
+	plain := append([]byte("0123456789a"), byte(crc>>24))
+	plain = append(plain, content...)
+
+	zc := newZipCrypto([]byte(password))
+	encrypted := make([]byte, len(plain))
+	for i, p := range plain {
+		temp := uint16(zc.keys[2]) | 2
+		encrypted[i] = p ^ byte((uint32(temp)*uint32(temp^1))>>8)
+		zc.update(p)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		Flags:              0x1,
+		CRC32:              crc,
+		CompressedSize64:   uint64(len(encrypted)),
+		UncompressedSize64: uint64(len(content)),
+	})
+	require.NoError(t, err)
+	_, err = w.Write(encrypted)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func TestOpenZipEntry(t *testing.T) {
+	archive := encryptedZipBytes(t, "secret.txt", []byte("top secret"), "hunter2")
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+
+	t.Run("Valid Password", func(t *testing.T) {
+		me := NewMetaExtractor(Options{Passwords: []string{"wrong", "hunter2"}})
+
+		rc, password, err := me.openZipEntry(zr.File[0])
+		require.NoError(t, err)
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, "top secret", string(data))
+		assert.Equal(t, "hunter2", password)
+	})
+
+	t.Run("No Valid Password", func(t *testing.T) {
+		me := NewMetaExtractor(Options{Passwords: []string{"wrong"}})
+
+		_, _, err := me.openZipEntry(zr.File[0])
+		assert.ErrorIs(t, err, ErrNoValidPassword)
+	})
+}
+
+func TestExtractDir_EncryptedArchive(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"secret.zip": string(encryptedZipBytes(t, "inner.txt", []byte("text"), "hunter2")),
+	})
+
+	me := NewMetaExtractor(Options{Passwords: []string{"hunter2"}})
+
+	results, err := me.ExtractDir(root, WalkOptions{Archives: true})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	entry := results[1]
+	assert.Equal(t, filepath.Join(root, "secret.zip")+"!/inner.txt", entry.Path)
+	assert.Equal(t, "hunter2", entry.Metadata.Password)
+	assert.Equal(t, int64(4), entry.Metadata.Size)
+}
+
+func TestIsPasswordProtected(t *testing.T) {
+	assert.True(t, isPasswordProtected(ExifMetadata{"Warning": "Document is password protected (use Password option)"}))
+	assert.False(t, isPasswordProtected(ExifMetadata{"Warning": "Truncated file"}))
+	assert.False(t, isPasswordProtected(ExifMetadata{}))
+}
@@ -0,0 +1,52 @@
+package metaextractor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PlaceholderPolicy selects how cloud placeholder files (files of OneDrive,
+// Dropbox, iCloud Drive etc. whose content is not stored locally) are
+// handled.
+type PlaceholderPolicy int
+
+const (
+	// PlaceholderSkip reports placeholders with Kind set to KindPlaceholder
+	// and skips the stages reading the file content, so that no download is
+	// triggered.
+	PlaceholderSkip PlaceholderPolicy = iota
+
+	// PlaceholderHydrate extracts placeholders like regular files. Reading
+	// the content makes the sync client download (hydrate) the file. iCloud
+	// Drive stubs cannot be hydrated by reading them; they are reported as
+	// with PlaceholderSkip, with a warning that the file is not downloaded.
+	PlaceholderHydrate
+
+	// PlaceholderFail returns ErrPlaceholder for placeholders.
+	PlaceholderFail
+)
+
+// ErrPlaceholder is returned for cloud placeholder files if
+// Options.Placeholders is PlaceholderFail.
+var ErrPlaceholder = errors.New("cloud placeholder file")
+
+// isPlaceholder reports whether the file is a cloud placeholder: a file
+// marked as offline or dataless by the file system, or an iCloud Drive stub
+// (".Name.ext.icloud") standing in for an evicted file.
+func isPlaceholder(path string, info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+
+	return isICloudStub(path) || placeholderAttributes(info)
+}
+
+// isICloudStub reports whether the file is an iCloud Drive stub
+// (".Name.ext.icloud"). The stub is a small property list; the file it stands
+// in for is only downloaded by the sync client, not by reading the stub.
+func isICloudStub(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".icloud") && len(name) > len(".x.icloud")
+}
@@ -0,0 +1,20 @@
+package metaextractor
+
+import (
+	"os"
+	"syscall"
+)
+
+// sfDataless is the file flag of dataless files, whose content is fetched
+// by a file provider (e.g., iCloud Drive) on access.
+const sfDataless = 0x40000000
+
+// placeholderAttributes reports whether the file is dataless.
+func placeholderAttributes(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	return stat.Flags&sfDataless != 0
+}
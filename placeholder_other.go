@@ -0,0 +1,11 @@
+//go:build !darwin && !windows
+
+package metaextractor
+
+import "os"
+
+// placeholderAttributes reports false; placeholder attributes are only
+// available on macOS and Windows.
+func placeholderAttributes(info os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,82 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{".photo.jpg.icloud", true},
+		{".notes.icloud", true},
+		{".icloud", false},
+		{"photo.jpg.icloud", false},
+		{"photo.jpg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(dir, tt.name)
+			require.NoError(t, os.WriteFile(p, []byte("stub"), 0o644))
+
+			info, err := os.Stat(p)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, isPlaceholder(p, info))
+		})
+	}
+}
+
+func TestMetaExtractor_Placeholder(t *testing.T) {
+	p := filepath.Join(t.TempDir(), ".photo.jpg.icloud")
+	require.NoError(t, os.WriteFile(p, []byte("\xff\xd8\xff\xe0stub"), 0o644))
+
+	t.Run("skip", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"md5"}}).Extract(p)
+		require.NoError(t, err)
+
+		assert.True(t, metadata.Placeholder)
+		assert.Equal(t, KindPlaceholder, metadata.Kind)
+		assert.Empty(t, metadata.Types)
+		assert.Empty(t, metadata.Hashes)
+		assert.False(t, metadata.Time.ModTime.IsZero())
+	})
+
+	t.Run("skip with rules", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{
+			PureGo: true,
+			Rules:  []Rule{{Label: "offline", Condition: `Kind == "placeholder"`}},
+		}).Extract(p)
+		require.NoError(t, err)
+
+		assert.Equal(t, KindPlaceholder, metadata.Kind)
+		assert.Equal(t, []string{"offline"}, metadata.Labels)
+	})
+
+	t.Run("hydrate", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{
+			PureGo:       true,
+			Hashes:       []string{"md5"},
+			Placeholders: PlaceholderHydrate,
+		}).Extract(p)
+		require.NoError(t, err)
+
+		assert.True(t, metadata.Placeholder)
+		assert.Equal(t, KindPlaceholder, metadata.Kind)
+		assert.Empty(t, metadata.Hashes)
+		assert.Contains(t, metadata.Warnings, "iCloud Drive stub: the file is not downloaded")
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := NewMetaExtractor(Options{PureGo: true, Placeholders: PlaceholderFail}).Extract(p)
+		assert.ErrorIs(t, err, ErrPlaceholder)
+	})
+}
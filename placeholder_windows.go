@@ -0,0 +1,24 @@
+package metaextractor
+
+import (
+	"os"
+	"syscall"
+)
+
+// File attributes of cloud placeholders (see the Cloud Files API).
+const (
+	fileAttributeOffline            = 0x00001000
+	fileAttributeRecallOnOpen       = 0x00040000
+	fileAttributeRecallOnDataAccess = 0x00400000
+)
+
+// placeholderAttributes reports whether the file is marked as offline or
+// its content is recalled from a remote storage on access.
+func placeholderAttributes(info os.FileInfo) bool {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+
+	return data.FileAttributes&(fileAttributeOffline|fileAttributeRecallOnOpen|fileAttributeRecallOnDataAccess) != 0
+}
@@ -0,0 +1,180 @@
+package metaextractor
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// findTool returns the command used to run the given external tool. If the
+// tool is not found in PATH, the usual install locations of the platform are
+// searched. If the tool cannot be found, the name is returned unchanged, so
+// the error is reported when the tool is run.
+func findTool(name string) string {
+	if _, err := exec.LookPath(name); err == nil {
+		return name
+	}
+
+	for _, candidate := range toolCandidates(name) {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+
+	return name
+}
+
+// windowsToolDirs maps the external tools to the names of their usual install
+// directories on Windows.
+var windowsToolDirs = map[string]string{
+	"trid":     "TrID",
+	"exiftool": "ExifTool",
+}
+
+// windowsToolCandidates returns the paths where the given tool is usually
+// installed on Windows: next to the running executable, in Program Files and
+// in the per-user program directory. The standalone ExifTool distribution
+// ships as "exiftool(-k).exe", which is also considered.
+func windowsToolCandidates(name string, getenv func(string) string, exeDir string) []string {
+	base := strings.TrimSuffix(strings.ToLower(name), ".exe")
+
+	names := []string{base + ".exe"}
+	if base == "exiftool" {
+		names = append(names, "exiftool(-k).exe")
+	}
+
+	dirs := []string{exeDir}
+	if dir, ok := windowsToolDirs[base]; ok {
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+			if root := getenv(env); root != "" {
+				dirs = append(dirs, windowsJoin(root, dir))
+			}
+		}
+
+		if root := getenv("LOCALAPPDATA"); root != "" {
+			dirs = append(dirs, windowsJoin(root, "Programs", dir))
+		}
+	}
+
+	var candidates []string
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+
+		for _, n := range names {
+			candidates = append(candidates, windowsJoin(dir, n))
+		}
+	}
+
+	return candidates
+}
+
+// windowsJoin joins path elements using backslashes.
+func windowsJoin(elem ...string) string {
+	for i := range elem[:len(elem)-1] {
+		elem[i] = strings.TrimRight(elem[i], `\/`)
+	}
+
+	return strings.Join(elem, `\`)
+}
+
+// windowsMaxPath is the length from which paths are given the long-path
+// prefix. It is below MAX_PATH (260), as directories are limited to 248
+// characters.
+const windowsMaxPath = 248
+
+// windowsLongPath returns the path with the \\?\ prefix if its absolute form
+// exceeds the MAX_PATH limit of the Windows API, so that it can be passed to
+// the file system functions and to the external tools. UNC paths are given
+// the \\?\UNC\ prefix. Short and already prefixed paths are returned
+// unchanged.
+func windowsLongPath(p string, abs func(string) (string, error)) string {
+	if strings.HasPrefix(p, `\\?\`) || strings.HasPrefix(p, `\\.\`) {
+		return p
+	}
+
+	a, err := abs(p)
+	if err != nil || len(a) < windowsMaxPath {
+		return p
+	}
+
+	// The prefix disables path normalization, so only backslashes may be
+	// used as separators.
+	a = strings.ReplaceAll(a, "/", `\`)
+
+	if strings.HasPrefix(a, `\\`) {
+		return `\\?\UNC\` + a[2:]
+	}
+
+	return `\\?\` + a
+}
+
+// cutDirPrefix returns the rest of p below the directory root, starting with
+// a separator, or "" if p is root itself, and reports whether p is root or
+// below it. Separators at the end of root are ignored. If windows is true,
+// both slashes and backslashes are separators and the paths are compared
+// case-insensitively, so that drive letters ("c:" and "C:") and UNC roots
+// (`\\server\share`) given in any form match the paths found by a walk.
+func cutDirPrefix(p, root string, windows bool) (string, bool) {
+	seps := "/"
+	equal := func(a, b string) bool { return a == b }
+	if windows {
+		seps = `\/`
+		equal = func(a, b string) bool {
+			return strings.EqualFold(strings.ReplaceAll(a, "/", `\`), strings.ReplaceAll(b, "/", `\`))
+		}
+	}
+
+	root = strings.TrimRight(root, seps)
+	if len(p) < len(root) || !equal(p[:len(root)], root) {
+		return "", false
+	}
+
+	rest := p[len(root):]
+	if strings.TrimRight(rest, seps) == "" {
+		return "", true
+	}
+	if !strings.ContainsRune(seps, rune(rest[0])) {
+		return "", false
+	}
+
+	return rest, true
+}
+
+// windowsReservedNames are the device names that cannot be used as file
+// names on Windows, regardless of the extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// windowsFileName returns the base name of an archive entry as a valid
+// Windows file name. Both slashes and backslashes are treated as separators,
+// invalid characters are replaced with underscores, trailing dots and spaces
+// are removed, and reserved device names are prefixed with an underscore.
+func windowsFileName(name string) string {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+
+	stem, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		name = "_" + name
+	}
+
+	return name
+}
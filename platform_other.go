@@ -0,0 +1,33 @@
+//go:build !windows
+
+package metaextractor
+
+import "path"
+
+// toolCandidates returns the usual install locations of the given tool.
+// Outside Windows, the tools are expected to be in PATH.
+func toolCandidates(name string) []string {
+	return nil
+}
+
+// longPath returns the path unchanged; only Windows limits the path length.
+func longPath(p string) string {
+	return p
+}
+
+// cutPathPrefix returns the rest of p below the directory root, and reports
+// whether p is root or below it (see cutDirPrefix).
+func cutPathPrefix(p, root string) (string, bool) {
+	return cutDirPrefix(p, root, false)
+}
+
+// nativeFileTimes reports false, as the times of the file are read with
+// getFileTimes outside Windows.
+func nativeFileTimes(filePath string) (FileTime, bool) {
+	return FileTime{}, false
+}
+
+// entryFileName returns the file name an archive entry is reported with.
+func entryFileName(name string) string {
+	return path.Base(name)
+}
@@ -0,0 +1,145 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindTool(t *testing.T) {
+	assert.Equal(t, "go", findTool("go"))
+	assert.Equal(t, "metaextractor-missing-tool", findTool("metaextractor-missing-tool"))
+}
+
+func TestWindowsToolCandidates(t *testing.T) {
+	env := map[string]string{
+		"ProgramFiles": `C:\Program Files`,
+		"LOCALAPPDATA": `C:\Users\test\AppData\Local\`,
+	}
+	getenv := func(key string) string { return env[key] }
+
+	assert.Equal(t, []string{
+		`D:\tools\trid.exe`,
+		`C:\Program Files\TrID\trid.exe`,
+		`C:\Users\test\AppData\Local\Programs\TrID\trid.exe`,
+	}, windowsToolCandidates("trid", getenv, `D:\tools`))
+
+	assert.Equal(t, []string{
+		`C:\Program Files\ExifTool\exiftool.exe`,
+		`C:\Program Files\ExifTool\exiftool(-k).exe`,
+		`C:\Users\test\AppData\Local\Programs\ExifTool\exiftool.exe`,
+		`C:\Users\test\AppData\Local\Programs\ExifTool\exiftool(-k).exe`,
+	}, windowsToolCandidates("ExifTool.exe", getenv, ""))
+
+	assert.Equal(t, []string{`\\server\share\other.exe`}, windowsToolCandidates("other", getenv, `\\server\share`))
+}
+
+func TestWindowsFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"dir/photo.jpg", "photo.jpg"},
+		{`dir\sub\photo.jpg`, "photo.jpg"},
+		{`C:\evil\photo.jpg`, "photo.jpg"},
+		{"what?.txt", "what_.txt"},
+		{"a<b>c:d\"e|f*g.txt", "a_b_c_d_e_f_g.txt"},
+		{"tab\there.txt", "tab_here.txt"},
+		{"trailing. . ", "trailing"},
+		{"CON", "_CON"},
+		{"nul.txt", "_nul.txt"},
+		{"com1.tar.gz", "_com1.tar.gz"},
+		{"console.txt", "console.txt"},
+		{"...", "_"},
+		{"dir/", "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, windowsFileName(tt.name))
+		})
+	}
+}
+
+func TestWindowsLongPath(t *testing.T) {
+	long := strings.Repeat(`\very-long-directory-name`, 12)
+
+	abs := func(p string) (string, error) {
+		if strings.HasPrefix(p, `\\`) || strings.Contains(p, `:`) {
+			return p, nil
+		}
+		return `C:\work\` + p, nil
+	}
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"Short", `C:\photos\a.jpg`, `C:\photos\a.jpg`},
+		{"Short Relative", `a.jpg`, `a.jpg`},
+		{"Long", `C:` + long + `\a.jpg`, `\\?\C:` + long + `\a.jpg`},
+		{"Long Relative", `dir` + long + `\a.jpg`, `\\?\C:\work\dir` + long + `\a.jpg`},
+		{"Long Slashes", `C:` + strings.ReplaceAll(long, `\`, `/`) + `/a.jpg`, `\\?\C:` + long + `\a.jpg`},
+		{"Long UNC", `\\server\share` + long + `\a.jpg`, `\\?\UNC\server\share` + long + `\a.jpg`},
+		{"Prefixed", `\\?\C:` + long, `\\?\C:` + long},
+		{"Device", `\\.\PhysicalDrive0`, `\\.\PhysicalDrive0`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, windowsLongPath(tc.path, abs))
+		})
+	}
+}
+
+func TestCutDirPrefix(t *testing.T) {
+	tests := []struct {
+		p, root string
+		windows bool
+		rest    string
+		ok      bool
+	}{
+		{"/mnt/snap/a.jpg", "/mnt/snap/", false, "/a.jpg", true},
+		{"/mnt/snap", "/mnt/snap", false, "", true},
+		{"/mnt/snapshot/a.jpg", "/mnt/snap", false, "", false},
+		{"/MNT/snap/a.jpg", "/mnt/snap", false, "", false},
+		{"/a.jpg", "/", false, "/a.jpg", true},
+		{`C:\snap\a.jpg`, `c:/snap`, true, `\a.jpg`, true},
+		{`C:\a.jpg`, `C:\`, true, `\a.jpg`, true},
+		{`D:\a.jpg`, `C:\`, true, "", false},
+		{`\\server\share\dir\a.jpg`, `\\SERVER\Share\`, true, `\dir\a.jpg`, true},
+		{`\\server\share`, `//server/share`, true, "", true},
+		{`\\server\shared\a.jpg`, `\\server\share`, true, "", false},
+	}
+
+	for _, tt := range tests {
+		rest, ok := cutDirPrefix(tt.p, tt.root, tt.windows)
+		assert.Equal(t, tt.ok, ok, tt.p)
+		assert.Equal(t, tt.rest, rest, tt.p)
+	}
+}
+
+func TestNativeFileTimes(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("the change time is read with times.Stat outside Windows")
+	}
+
+	filePath := filepath.Join(t.TempDir(), "sample.txt")
+	require.NoError(t, os.WriteFile(filePath, []byte("hello"), 0o444))
+
+	ft, ok := nativeFileTimes(filePath)
+	require.True(t, ok, "read-only files are supported")
+	assert.False(t, ft.ChangeTime.IsZero())
+	assert.False(t, ft.BirthTime.IsZero())
+
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(ft.ModTime))
+}
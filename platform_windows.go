@@ -0,0 +1,85 @@
+//go:build windows
+
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// toolCandidates returns the usual install locations of the given tool.
+func toolCandidates(name string) []string {
+	var exeDir string
+	if exe, err := os.Executable(); err == nil {
+		exeDir = filepath.Dir(exe)
+	}
+
+	return windowsToolCandidates(name, os.Getenv, exeDir)
+}
+
+// longPath returns the path with the long-path prefix if it exceeds MAX_PATH.
+func longPath(p string) string {
+	return windowsLongPath(p, filepath.Abs)
+}
+
+// cutPathPrefix returns the rest of p below the directory root, and reports
+// whether p is root or below it (see cutDirPrefix). Windows paths are
+// compared case-insensitively.
+func cutPathPrefix(p, root string) (string, bool) {
+	return cutDirPrefix(p, root, true)
+}
+
+// fileBasicInfo is the FILE_BASIC_INFO structure.
+type fileBasicInfo struct {
+	CreationTime   int64
+	LastAccessTime int64
+	LastWriteTime  int64
+	ChangeTime     int64
+	FileAttributes uint32
+	_              uint32
+}
+
+// nativeFileTimes reads the times of the file, including the NTFS change
+// time, which os.Stat does not report. The file is opened for reading its
+// attributes only, so read-only files and files on read-only shares are
+// supported. It reports false if the times cannot be read this way.
+func nativeFileTimes(filePath string) (FileTime, bool) {
+	h, err := openForQuery(filePath)
+	if err != nil {
+		return FileTime{}, false
+	}
+	defer syscall.CloseHandle(h)
+
+	var info fileBasicInfo
+	if err := windows.GetFileInformationByHandleEx(windows.Handle(h), windows.FileBasicInfo, (*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		return FileTime{}, false
+	}
+
+	return FileTime{
+		AccessTime: fileTime(info.LastAccessTime),
+		ModTime:    fileTime(info.LastWriteTime),
+		ChangeTime: fileTime(info.ChangeTime),
+		BirthTime:  fileTime(info.CreationTime),
+	}, true
+}
+
+// fileTime converts a FILETIME value. Zero means that the file system does
+// not record the time (e.g., the change time on FAT).
+func fileTime(ft int64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+
+	t := syscall.Filetime{LowDateTime: uint32(ft), HighDateTime: uint32(ft >> 32)}
+	return time.Unix(0, t.Nanoseconds())
+}
+
+// entryFileName returns the file name an archive entry is reported with.
+func entryFileName(name string) string {
+	return windowsFileName(name)
+}
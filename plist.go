@@ -0,0 +1,407 @@
+package metaextractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// errInvalidPlist is returned for malformed property lists.
+var errInvalidPlist = errors.New("invalid property list")
+
+const (
+	// plistMaxDepth limits the nesting of decoded property lists.
+	plistMaxDepth = 32
+
+	// plistMaxObjects limits the number of objects decoded from a binary
+	// property list. Objects referenced several times are decoded each time,
+	// so a small file of shared references would otherwise expand
+	// exponentially.
+	plistMaxObjects = 1 << 18
+)
+
+// parsePlist decodes a property list in the XML or the binary format whose
+// top-level object is a dictionary. Strings are returned as string, integers
+// as int64, reals as float64, booleans as bool, arrays as []interface{} and
+// dictionaries as map[string]interface{}; other values are skipped.
+func parsePlist(data []byte) (map[string]interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+
+	if bytes.HasPrefix(data, []byte("bplist00")) {
+		v, err = parseBinaryPlist(data)
+	} else {
+		v, err = parseXMLPlist(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: top-level object is not a dictionary", errInvalidPlist)
+	}
+
+	return dict, nil
+}
+
+// parseXMLPlist decodes a property list in the XML format.
+func parseXMLPlist(data []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPlist, err)
+		}
+
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local != "plist" {
+			return decodeXMLPlistValue(dec, start, 0)
+		}
+	}
+}
+
+// decodeXMLPlistValue decodes the value starting with the given element.
+func decodeXMLPlistValue(dec *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth > plistMaxDepth {
+		return nil, fmt.Errorf("%w: nesting too deep", errInvalidPlist)
+	}
+
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]interface{})
+		key := ""
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errInvalidPlist, err)
+			}
+
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "key" {
+					if key, err = xmlText(dec); err != nil {
+						return nil, err
+					}
+					continue
+				}
+
+				v, err := decodeXMLPlistValue(dec, t, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				if v != nil {
+					dict[key] = v
+				}
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+
+	case "array":
+		array := []interface{}{}
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errInvalidPlist, err)
+			}
+
+			switch t := tok.(type) {
+			case xml.StartElement:
+				v, err := decodeXMLPlistValue(dec, t, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				if v != nil {
+					array = append(array, v)
+				}
+			case xml.EndElement:
+				return array, nil
+			}
+		}
+
+	case "true", "false":
+		if err := dec.Skip(); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPlist, err)
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	text, err := xmlText(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		n, err := strconv.ParseInt(strings.TrimSpace(text), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPlist, err)
+		}
+		return n, nil
+	case "real":
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPlist, err)
+		}
+		return f, nil
+	}
+
+	// Dates and data are skipped.
+	return nil, nil
+}
+
+// xmlText returns the character data of the current element and consumes
+// its end.
+func xmlText(dec *xml.Decoder) (string, error) {
+	var sb strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", errInvalidPlist, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.StartElement:
+			if err := dec.Skip(); err != nil {
+				return "", fmt.Errorf("%w: %w", errInvalidPlist, err)
+			}
+		case xml.EndElement:
+			return sb.String(), nil
+		}
+	}
+}
+
+// binaryPlist is a property list in the binary format ("bplist00").
+type binaryPlist struct {
+	data    []byte
+	offsets []uint64
+	refSize int
+
+	// decoded counts the objects decoded so far.
+	decoded int
+}
+
+// parseBinaryPlist decodes a property list in the binary format.
+func parseBinaryPlist(data []byte) (interface{}, error) {
+	if len(data) < 8+32 {
+		return nil, fmt.Errorf("%w: truncated", errInvalidPlist)
+	}
+
+	trailer := data[len(data)-32:]
+	offsetSize := int(trailer[6])
+	refSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	topObject := binary.BigEndian.Uint64(trailer[16:])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:])
+
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 ||
+		numObjects > uint64(len(data)) || topObject >= numObjects ||
+		tableOffset > uint64(len(data)) || numObjects*uint64(offsetSize) > uint64(len(data))-tableOffset {
+		return nil, fmt.Errorf("%w: invalid trailer", errInvalidPlist)
+	}
+
+	p := &binaryPlist{data: data, refSize: refSize, offsets: make([]uint64, numObjects)}
+	for i := range p.offsets {
+		start := tableOffset + uint64(i*offsetSize)
+		p.offsets[i] = readBigEndian(data[start : start+uint64(offsetSize)])
+	}
+
+	return p.object(topObject, 0)
+}
+
+// object decodes the object with the given index.
+func (p *binaryPlist) object(index uint64, depth int) (interface{}, error) {
+	if depth > plistMaxDepth {
+		return nil, fmt.Errorf("%w: nesting too deep", errInvalidPlist)
+	}
+	if p.decoded++; p.decoded > plistMaxObjects {
+		return nil, fmt.Errorf("%w: too many objects", errInvalidPlist)
+	}
+	if index >= uint64(len(p.offsets)) || p.offsets[index] >= uint64(len(p.data)) {
+		return nil, fmt.Errorf("%w: invalid object reference", errInvalidPlist)
+	}
+
+	off := p.offsets[index]
+	marker := p.data[off]
+	kind, info := marker>>4, int(marker&0x0f)
+	off++
+
+	switch kind {
+	case 0x0:
+		switch marker {
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+		return nil, nil
+
+	case 0x1:
+		b, err := p.bytes(off, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		return int64(readBigEndian(b)), nil
+
+	case 0x2:
+		b, err := p.bytes(off, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		switch len(b) {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+		return nil, fmt.Errorf("%w: invalid real", errInvalidPlist)
+	}
+
+	count, off, err := p.count(info, off)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case 0x5:
+		b, err := p.bytes(off, count)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+
+	case 0x6:
+		b, err := p.bytes(off, 2*count)
+		if err != nil {
+			return nil, err
+		}
+		units := make([]uint16, count)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units)), nil
+
+	case 0xa:
+		refs, err := p.refs(off, count)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, 0, count)
+		for _, ref := range refs {
+			v, err := p.object(ref, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				array = append(array, v)
+			}
+		}
+		return array, nil
+
+	case 0xd:
+		refs, err := p.refs(off, 2*count)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]interface{}, count)
+		for i := 0; i < count; i++ {
+			k, err := p.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: dictionary key is not a string", errInvalidPlist)
+			}
+
+			v, err := p.object(refs[count+i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				dict[key] = v
+			}
+		}
+		return dict, nil
+	}
+
+	// Dates, data, UIDs and sets are skipped.
+	return nil, nil
+}
+
+// count returns the element count of an object. Counts of 15 or more are
+// stored in a following integer object.
+func (p *binaryPlist) count(info int, off uint64) (int, uint64, error) {
+	if info != 0x0f {
+		return info, off, nil
+	}
+
+	b, err := p.bytes(off, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if b[0]>>4 != 0x1 {
+		return 0, 0, fmt.Errorf("%w: invalid count", errInvalidPlist)
+	}
+
+	size := 1 << (b[0] & 0x0f)
+	n, err := p.bytes(off+1, size)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	count := readBigEndian(n)
+	if count > uint64(len(p.data)) {
+		return 0, 0, fmt.Errorf("%w: invalid count", errInvalidPlist)
+	}
+
+	return int(count), off + 1 + uint64(size), nil
+}
+
+// refs returns n object references starting at off.
+func (p *binaryPlist) refs(off uint64, n int) ([]uint64, error) {
+	b, err := p.bytes(off, n*p.refSize)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readBigEndian(b[i*p.refSize : (i+1)*p.refSize])
+	}
+
+	return refs, nil
+}
+
+// bytes returns n bytes starting at off.
+func (p *binaryPlist) bytes(off uint64, n int) ([]byte, error) {
+	if n < 0 || off > uint64(len(p.data)) || uint64(n) > uint64(len(p.data))-off {
+		return nil, fmt.Errorf("%w: %w", errInvalidPlist, io.ErrUnexpectedEOF)
+	}
+
+	return p.data[off : off+uint64(n)], nil
+}
+
+// readBigEndian reads an unsigned big-endian integer of up to 8 bytes.
+func readBigEndian(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
@@ -0,0 +1,123 @@
+package metaextractor
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testXMLPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CFBundleIdentifier</key>
+	<string>com.example.App</string>
+	<key>CFBundleVersion</key>
+	<integer>42</integer>
+	<key>Ratio</key>
+	<real>1.5</real>
+	<key>LSRequiresNativeExecution</key>
+	<true/>
+	<key>CFBundleIconData</key>
+	<data>AAEC</data>
+	<key>CFBundleDocumentTypes</key>
+	<array>
+		<dict>
+			<key>CFBundleTypeName</key>
+			<string>Text &amp; Data</string>
+		</dict>
+	</array>
+</dict>
+</plist>`
+
+// binaryPlistOf builds a binary property list of the encoded objects, using
+// one-byte offsets and object references. The first object is the top object.
+func binaryPlistOf(objects ...[]byte) []byte {
+	data := []byte("bplist00")
+
+	offsets := make([]byte, len(objects))
+	for i, obj := range objects {
+		offsets[i] = byte(len(data))
+		data = append(data, obj...)
+	}
+
+	tableOffset := len(data)
+	data = append(data, offsets...)
+
+	trailer := make([]byte, 32)
+	trailer[6], trailer[7] = 1, 1
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(objects)))
+	binary.BigEndian.PutUint64(trailer[24:], uint64(tableOffset))
+
+	return append(data, trailer...)
+}
+
+// sharedReferencePlist returns a binary property list of 30 nested arrays,
+// each holding the next one twice, which expands to 2^30 strings.
+func sharedReferencePlist() []byte {
+	objects := [][]byte{{0xd1, 1, 2}, append([]byte{0x51}, 'a')}
+	for i := 2; i < 32; i++ {
+		objects = append(objects, []byte{0xa2, byte(i + 1), byte(i + 1)})
+	}
+	objects = append(objects, append([]byte{0x51}, 'x'))
+
+	return binaryPlistOf(objects...)
+}
+
+func TestParsePlist(t *testing.T) {
+	t.Run("XML", func(t *testing.T) {
+		info, err := parsePlist([]byte(testXMLPlist))
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]interface{}{
+			"CFBundleIdentifier":        "com.example.App",
+			"CFBundleVersion":           int64(42),
+			"Ratio":                     1.5,
+			"LSRequiresNativeExecution": true,
+			"CFBundleDocumentTypes": []interface{}{
+				map[string]interface{}{"CFBundleTypeName": "Text & Data"},
+			},
+		}, info)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		data := binaryPlistOf(
+			[]byte{0xd3, 1, 3, 5, 2, 4, 6},
+			append([]byte{0x5f, 0x10, 18}, "CFBundleIdentifier"...),
+			append([]byte{0x5f, 0x10, 15}, "com.example.App"...),
+			append([]byte{0x5c}, "CFBundleName"...),
+			[]byte{0x62, 0x00, 'A', 0x00, 0xe9},
+			append([]byte{0x55}, "Count"...),
+			[]byte{0x11, 0x01, 0x00},
+		)
+
+		info, err := parsePlist(data)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]interface{}{
+			"CFBundleIdentifier": "com.example.App",
+			"CFBundleName":       "Aé",
+			"Count":              int64(256),
+		}, info)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := map[string][]byte{
+			"not a dictionary":  []byte(`<plist><string>x</string></plist>`),
+			"malformed XML":     []byte(`<plist><dict><key>a</key>`),
+			"truncated binary":  []byte("bplist00"),
+			"invalid reference": binaryPlistOf([]byte{0xd1, 1, 9}, append([]byte{0x51}, 'a')),
+			"cyclic":            binaryPlistOf([]byte{0xd1, 1, 0}, append([]byte{0x51}, 'a')),
+			"shared references": sharedReferencePlist(),
+		}
+
+		for name, data := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, err := parsePlist(data)
+				assert.ErrorIs(t, err, errInvalidPlist)
+			})
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package metaextractor
+
+import (
+	"context"
+	"sync"
+)
+
+// extractQueue defers the extraction of the files of a batch, so that they
+// can be extracted on a pool of Options.Concurrency workers once all paths
+// are known. Results keep the order in which the files were queued.
+type extractQueue struct {
+	// pending holds the indices of the results to extract.
+	pending []int
+}
+
+// newExtractQueue returns a new queue, or nil if files are extracted as they
+// are found, either because deduplication depends on the order of the
+// extractions, or because Options.Concurrency is 1 and no progress reporting
+// needs the number of files in advance.
+func (me *MetaExtractor) newExtractQueue() *extractQueue {
+	if me.deduplicate || (me.concurrency <= 1 && me.progress == nil) {
+		return nil
+	}
+
+	return &extractQueue{}
+}
+
+// add appends a result for the file to results and queues its extraction.
+func (q *extractQueue) add(results []Result, p string) []Result {
+	q.pending = append(q.pending, len(results))
+	return append(results, Result{Path: p})
+}
+
+// run extracts the queued files. Once an error budget of the batch run in
+// ctx is exceeded, the remaining files fail with the BudgetError instead.
+func (q *extractQueue) run(ctx context.Context, me *MetaExtractor, results []Result) {
+	progress := me.newProgress(len(q.pending))
+	me.parallel(len(q.pending), func(k int) {
+		r := &results[q.pending[k]]
+		if err := budgetFromContext(ctx).err(); err != nil {
+			r.Err = err
+		} else {
+			r.Metadata, r.Err = me.ExtractContext(ctx, r.Path)
+		}
+		progress.report(r.Path)
+	})
+}
+
+// parallel calls fn for each index from 0 to n-1 on up to
+// Options.Concurrency goroutines and waits for all calls to return.
+func (me *MetaExtractor) parallel(n int, fn func(i int)) {
+	workers := min(max(me.concurrency, 1), n)
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+
+	wg.Wait()
+}
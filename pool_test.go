@@ -0,0 +1,129 @@
+package metaextractor
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallel(t *testing.T) {
+	for _, concurrency := range []int{1, 3, 100} {
+		me := &MetaExtractor{concurrency: concurrency}
+
+		var running, peak atomic.Int32
+		var mu sync.Mutex
+		seen := make(map[int]bool)
+
+		me.parallel(20, func(i int) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+
+			mu.Lock()
+			seen[i] = true
+			mu.Unlock()
+		})
+
+		assert.Len(t, seen, 20)
+		assert.LessOrEqual(t, int(peak.Load()), concurrency)
+	}
+
+	// No work must not block.
+	(&MetaExtractor{concurrency: 4}).parallel(0, func(int) {})
+}
+
+// slowDetector returns a detector that sleeps longer for earlier files, so
+// that parallel extractions finish out of order.
+func slowDetector() Detector {
+	var calls atomic.Int32
+	return DetectorFunc{
+		DetectorName: "slow",
+		Fn: func(string) ([]trid.FileType, error) {
+			delay := 10 - calls.Add(1)
+			time.Sleep(time.Duration(max(delay, 0)) * time.Millisecond)
+			return nil, nil
+		},
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"a.txt":     "a",
+		"b.txt":     "bb",
+		"c/d.txt":   "ddd",
+		"c/e.txt":   "eeee",
+		"c/f/g.txt": "ggggg",
+	})
+	paths := []string{
+		filepath.Join(root, "a.txt"),
+		filepath.Join(root, "b.txt"),
+		filepath.Join(root, "c", "d.txt"),
+		filepath.Join(root, "c", "e.txt"),
+		filepath.Join(root, "c", "f", "g.txt"),
+		filepath.Join(root, "missing"),
+	}
+
+	sequential := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"md5"}})
+	want := sequential.ExtractBatch(paths)
+
+	parallel := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"md5"}, Concurrency: 4, Detectors: []Detector{slowDetector(), SignatureDetector}})
+
+	t.Run("Batch", func(t *testing.T) {
+		results := parallel.ExtractBatch(paths)
+		require.Len(t, results, len(want))
+		for i, r := range results {
+			assert.Equal(t, want[i].Path, r.Path)
+			assert.Equal(t, want[i].Metadata.Hashes, r.Metadata.Hashes)
+			assert.Equal(t, want[i].Err, r.Err)
+			assert.Equal(t, results[0].RunID, r.RunID)
+		}
+	})
+
+	t.Run("Dir", func(t *testing.T) {
+		wantDir, err := sequential.ExtractDir(root, WalkOptions{})
+		require.NoError(t, err)
+
+		results, err := parallel.ExtractDir(root, WalkOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, len(wantDir))
+		for i, r := range results {
+			assert.Equal(t, wantDir[i].Path, r.Path)
+			assert.Equal(t, wantDir[i].Metadata.Hashes, r.Metadata.Hashes)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		in := make(chan string)
+		go func() {
+			defer close(in)
+			for _, p := range paths {
+				in <- p
+			}
+		}()
+
+		var got []string
+		for r := range parallel.ExtractStream(context.Background(), in) {
+			got = append(got, r.Path)
+		}
+		assert.Equal(t, paths, got)
+	})
+
+	t.Run("Deduplicate", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Concurrency: 4, Deduplicate: true})
+		assert.Nil(t, me.newExtractQueue())
+		assert.Len(t, me.ExtractBatch(paths), len(paths))
+	})
+}
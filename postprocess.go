@@ -0,0 +1,119 @@
+package metaextractor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PostProcessor transforms the metadata of a file before it is returned,
+// e.g. to normalize, enrich or redact it. Post-processors run in the order
+// of Options.PostProcessors, after all other stages, including for files
+// whose extraction failed.
+type PostProcessor interface {
+	// Name returns the name of the post-processor, used in error messages.
+	Name() string
+
+	// Process returns the transformed metadata of the given file. The
+	// metadata passed in must not be modified, as its maps and slices may
+	// be shared.
+	Process(filePath string, metadata Metadata) (Metadata, error)
+}
+
+// PostProcessorFunc adapts an ordinary function to the PostProcessor
+// interface.
+type PostProcessorFunc struct {
+	// ProcessorName is the name returned by Name.
+	ProcessorName string
+
+	// Fn is the function invoked by Process.
+	Fn func(filePath string, metadata Metadata) (Metadata, error)
+}
+
+// Name returns the name of the post-processor.
+func (pf PostProcessorFunc) Name() string {
+	return pf.ProcessorName
+}
+
+// Process calls pf.Fn(filePath, metadata).
+func (pf PostProcessorFunc) Process(filePath string, metadata Metadata) (Metadata, error) {
+	return pf.Fn(filePath, metadata)
+}
+
+// Name returns "redact".
+func (p RedactionPolicy) Name() string {
+	return "redact"
+}
+
+// Process returns the redacted metadata (see Apply).
+func (p RedactionPolicy) Process(filePath string, metadata Metadata) (Metadata, error) {
+	return p.Apply(metadata), nil
+}
+
+// Name returns "localize".
+func (c MessageCatalog) Name() string {
+	return "localize"
+}
+
+// Process returns the localized metadata (see Localize).
+func (c MessageCatalog) Process(filePath string, metadata Metadata) (Metadata, error) {
+	return c.Localize(metadata), nil
+}
+
+// TrimExif is a post-processor that trims the white space around EXIF
+// string values, which some devices pad to a fixed length, and removes the
+// values left empty.
+var TrimExif PostProcessor = PostProcessorFunc{
+	ProcessorName: "trim-exif",
+	Fn: func(filePath string, metadata Metadata) (Metadata, error) {
+		metadata.Exif = trimExif(metadata.Exif)
+		return metadata, nil
+	},
+}
+
+// trimExif returns a copy of exif with trimmed string values.
+func trimExif(exif ExifMetadata) ExifMetadata {
+	if exif == nil {
+		return nil
+	}
+
+	trimmed := make(ExifMetadata, len(exif))
+	for key, value := range exif {
+		switch v := value.(type) {
+		case string:
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			trimmed[key] = v
+		case map[string]interface{}:
+			trimmed[key] = map[string]interface{}(trimExif(v))
+		default:
+			trimmed[key] = value
+		}
+	}
+
+	return trimmed
+}
+
+// postProcess runs the post-processors on the metadata of the file,
+// recording them in trace. A failing post-processor stops the chain, unless
+// Options.BestEffort is set.
+func (me *MetaExtractor) postProcess(filePath string, metadata *Metadata, trace *stageTrace) error {
+	for _, p := range me.postProcessors {
+		start := time.Now()
+		processed, err := p.Process(filePath, *metadata)
+		trace.done("postprocess:"+p.Name(), start)
+
+		if err != nil {
+			err := me.stageError(metadata, trace, fmt.Errorf("error running post-processor %q: %w", p.Name(), err))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		*metadata = processed
+	}
+
+	return nil
+}
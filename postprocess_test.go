@@ -0,0 +1,120 @@
+package metaextractor
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendLabel returns a post-processor appending a label.
+func appendLabel(label string) PostProcessor {
+	return PostProcessorFunc{
+		ProcessorName: "label-" + label,
+		Fn: func(filePath string, metadata Metadata) (Metadata, error) {
+			metadata.Labels = append(append([]string(nil), metadata.Labels...), label)
+			return metadata, nil
+		},
+	}
+}
+
+func TestPostProcessors(t *testing.T) {
+	samplePath := filepath.Join("testdata", "sample.doc")
+
+	t.Run("Order", func(t *testing.T) {
+		var paths []string
+		record := PostProcessorFunc{
+			ProcessorName: "record",
+			Fn: func(filePath string, metadata Metadata) (Metadata, error) {
+				paths = append(paths, filePath)
+				return metadata, nil
+			},
+		}
+
+		me := NewMetaExtractor(Options{PureGo: true, PostProcessors: []PostProcessor{appendLabel("a"), appendLabel("b"), record}})
+
+		metadata, err := me.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, metadata.Labels)
+		assert.Equal(t, []string{samplePath}, paths)
+
+		metadata, err = me.ExtractStreamInput(bytes.NewReader([]byte("text")))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, metadata.Labels)
+		assert.Equal(t, []string{samplePath, StreamPath}, paths)
+	})
+
+	t.Run("Failed Extraction", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, PostProcessors: []PostProcessor{appendLabel("a")}})
+
+		metadata, err := me.Extract("nonexistent_file")
+		assert.ErrorIs(t, err, ErrFileNotFound)
+		assert.Equal(t, []string{"a"}, metadata.Labels)
+	})
+
+	errFailed := errors.New("failed")
+	failing := PostProcessorFunc{
+		ProcessorName: "failing",
+		Fn: func(filePath string, metadata Metadata) (Metadata, error) {
+			return metadata, errFailed
+		},
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, PostProcessors: []PostProcessor{failing, appendLabel("a")}})
+
+		metadata, err := me.Extract(samplePath)
+		assert.ErrorIs(t, err, errFailed)
+		assert.Contains(t, err.Error(), `"failing"`)
+		assert.Empty(t, metadata.Labels)
+	})
+
+	t.Run("Best Effort", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, BestEffort: true, PostProcessors: []PostProcessor{failing, appendLabel("a")}})
+
+		metadata, err := me.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, metadata.Labels)
+		require.Len(t, metadata.Warnings, 1)
+		assert.Contains(t, metadata.Warnings[0], "failed")
+	})
+}
+
+func TestBuiltinPostProcessors(t *testing.T) {
+	metadata := Metadata{
+		Exif: ExifMetadata{
+			"Make":         "Canon  ",
+			"Model":        "   ",
+			"ISO":          float64(100),
+			"SerialNumber": "123",
+			"EXIF":         map[string]interface{}{"Software": " v1 "},
+		},
+		Labels: []string{"suspicious"},
+	}
+
+	trimmed, err := TrimExif.Process("file", metadata)
+	require.NoError(t, err)
+	assert.Equal(t, ExifMetadata{
+		"Make":         "Canon",
+		"ISO":          float64(100),
+		"SerialNumber": "123",
+		"EXIF":         map[string]interface{}{"Software": "v1"},
+	}, trimmed.Exif)
+	assert.Equal(t, "Canon  ", metadata.Exif["Make"])
+
+	redacted, err := DefaultRedactionPolicy.Process("file", metadata)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRedactionPolicy.Apply(metadata), redacted)
+
+	catalog := MessageCatalog{"suspicious": "verdächtig"}
+	localized, err := catalog.Process("file", metadata)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"verdächtig"}, localized.Labels)
+
+	for _, p := range []PostProcessor{TrimExif, DefaultRedactionPolicy, catalog} {
+		assert.NotEmpty(t, p.Name())
+	}
+}
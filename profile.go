@@ -0,0 +1,169 @@
+package metaextractor
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownProfile is returned by WithProfile for a profile that is neither
+// built in nor configured in Options.Profiles.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Profile bundles the content stages, hashes and EXIF tags suited to a kind
+// of files, so that applications can switch between them per call (see
+// MetaExtractor.WithProfile) without building separate option sets. A
+// profile replaces the corresponding options of the extractor.
+type Profile struct {
+	// Hashes are the hash algorithms computed (see Options.Hashes).
+	Hashes []string
+
+	// Entropy enables computing the entropy (see Options.Entropy).
+	Entropy bool
+
+	// Sketch enables computing the similarity sketch (see Options.Sketch).
+	Sketch bool
+
+	// ImageChecks enables the image checks (see Options.ImageChecks).
+	ImageChecks bool
+
+	// SampleSize is the size of the head and tail samples (see
+	// Options.SampleSize).
+	SampleSize int
+
+	// SkipExif disables the EXIF extraction with ExifTool.
+	SkipExif bool
+
+	// ExifTags limits Metadata.Exif to the tags matching any of the
+	// patterns, using the syntax of RedactionPolicy (e.g., "GPS*" or
+	// "*Date*"). An empty list keeps all tags.
+	ExifTags []string
+}
+
+// DefaultProfiles are the built-in profiles.
+var DefaultProfiles = map[string]Profile{
+	// photos keeps the camera, capture and location tags of images and
+	// cross-checks them against the image data.
+	"photos": {
+		Hashes:      []string{"sha256"},
+		ImageChecks: true,
+		ExifTags: []string{
+			"*Date*", "*Time*", "Make", "Model", "Lens*", "GPS*", "Image*",
+			"Orientation", "ISO", "FNumber", "ExposureTime", "FocalLength",
+			"Flash", "Software", "MIMEType",
+		},
+	},
+
+	// documents keeps the descriptive tags of office documents and PDFs.
+	"documents": {
+		Hashes: []string{"sha256"},
+		ExifTags: []string{
+			"Title", "Subject", "Author", "Creator", "Producer", "Keywords",
+			"Company", "LastModifiedBy", "*Date*", "PageCount", "Pages",
+			"Language", "Template", "MIMEType", "Encryption", "Linearized",
+		},
+	},
+
+	// malware-triage computes the hashes used by threat intelligence
+	// services, the entropy and samples of the content, and keeps all tags.
+	"malware-triage": {
+		Hashes:     []string{"md5", "sha1", "sha256"},
+		Entropy:    true,
+		SampleSize: 64,
+	},
+}
+
+// WithProfile returns an extractor sharing the configuration and resources
+// of me, with the options covered by the named profile replaced. Profiles
+// are looked up in Options.Profiles first, then in DefaultProfiles. The
+// returned extractor is cheap to create and can be discarded after the call.
+func (me *MetaExtractor) WithProfile(name string) (*MetaExtractor, error) {
+	profile, ok := me.profiles[name]
+	if !ok {
+		if profile, ok = DefaultProfiles[name]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, name)
+		}
+	}
+
+	if err := checkHashes(profile.Hashes); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+
+	clone := *me
+	clone.imageChecks = profile.ImageChecks
+	clone.sampleSize = max(profile.SampleSize, 0)
+	clone.skipExif = profile.SkipExif
+	clone.exifTags = slices.Clone(profile.ExifTags)
+	clone.scanOpts = me.scanOpts.withProfile(profile, hasSignatureDetector(me.detectors))
+	clone.backends = bindBackends(me.backends, &clone)
+
+	return &clone, nil
+}
+
+// checkProfiles reports an error if any of the profiles uses an unknown hash
+// algorithm.
+func checkProfiles(profiles map[string]Profile) error {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := checkHashes(profiles[name].Hashes); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// withProfile returns the scan options with the content stages of the
+// profile. The head is kept for the signature detector if needed.
+func (opts scanOptions) withProfile(profile Profile, signature bool) scanOptions {
+	opts.hashes = slices.Clone(profile.Hashes)
+	opts.entropy = profile.Entropy
+	opts.sketch = profile.Sketch
+	opts.headSize = max(profile.SampleSize, 0)
+	opts.tailSize = max(profile.SampleSize, 0)
+
+	if signature {
+		opts.headSize = max(opts.headSize, signatureHeadSize)
+	}
+
+	return opts
+}
+
+// filterExifTags returns the values of exif whose tags match any of the
+// patterns. An empty list of patterns keeps all values.
+func filterExifTags(exif ExifMetadata, patterns []string) ExifMetadata {
+	if len(patterns) == 0 || exif == nil {
+		return exif
+	}
+
+	filtered := make(ExifMetadata)
+	for key, value := range exif {
+		if matchTag(patterns, key) {
+			filtered[key] = value
+		}
+	}
+
+	return filtered
+}
+
+// matchTag reports whether the tag of the key matches any of the patterns.
+// Patterns use path.Match syntax, are case-insensitive and match tags
+// regardless of their group.
+func matchTag(patterns []string, key string) bool {
+	tag := strings.ToLower(tagName(key))
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), tag); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProfile(t *testing.T) {
+	samplePath := filepath.Join("testdata", "sample.doc")
+
+	me := NewMetaExtractor(Options{
+		PureGo:     true,
+		Hashes:     []string{"md5"},
+		SampleSize: 4,
+		Profiles: map[string]Profile{
+			"custom":    {Hashes: []string{"sha1"}, Sketch: true},
+			"documents": {Hashes: []string{"sha512"}},
+		},
+	})
+
+	t.Run("Built-in", func(t *testing.T) {
+		triage, err := me.WithProfile("malware-triage")
+		require.NoError(t, err)
+
+		metadata, err := triage.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Len(t, metadata.Hashes, 3)
+		assert.Greater(t, metadata.Entropy, 0.0)
+		assert.Len(t, metadata.Head, 64)
+		assert.NotEmpty(t, metadata.Types)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		custom, err := me.WithProfile("custom")
+		require.NoError(t, err)
+
+		metadata, err := custom.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Contains(t, metadata.Hashes, "sha1")
+		assert.NotContains(t, metadata.Hashes, "md5")
+		assert.NotNil(t, metadata.Sketch)
+		assert.Empty(t, metadata.Head)
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		documents, err := me.WithProfile("documents")
+		require.NoError(t, err)
+
+		metadata, err := documents.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"sha512"}, keys(metadata.Hashes))
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		metadata, err := me.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"md5"}, keys(metadata.Hashes))
+		assert.Len(t, metadata.Head, 4)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := me.WithProfile("unknown")
+		assert.ErrorIs(t, err, ErrUnknownProfile)
+	})
+
+	t.Run("Unknown Hash", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Profiles: map[string]Profile{"x": {Hashes: []string{"sha3"}}}})
+
+		_, err := me.WithProfile("x")
+		assert.ErrorContains(t, err, `profile "x": unknown hash algorithm: sha3`)
+
+		_, err = me.Extract(samplePath)
+		assert.ErrorContains(t, err, "unknown hash algorithm")
+	})
+}
+
+// keys returns the keys of a map of hashes.
+func keys(m map[string]string) []string {
+	var k []string
+	for key := range m {
+		k = append(k, key)
+	}
+	return k
+}
+
+func TestFilterExifTags(t *testing.T) {
+	exif := ExifMetadata{
+		"EXIF:Make":             "Canon",
+		"EXIF:DateTimeOriginal": "2024:01:02 03:04:05",
+		"GPSLatitude":           "52 deg",
+		"SerialNumber":          "123",
+	}
+
+	assert.Equal(t, exif, filterExifTags(exif, nil))
+	assert.Equal(t, ExifMetadata{
+		"EXIF:Make":             "Canon",
+		"EXIF:DateTimeOriginal": "2024:01:02 03:04:05",
+		"GPSLatitude":           "52 deg",
+	}, filterExifTags(exif, []string{"make", "*Date*", "GPS*"}))
+	assert.Nil(t, filterExifTags(nil, []string{"Make"}))
+}
@@ -0,0 +1,46 @@
+package metaextractor
+
+import "sync"
+
+// ProgressFunc is called by ExtractBatch and ExtractDir after each file is
+// extracted, with the number of files done so far, the total number of files
+// to extract and the path of the file just extracted. The total is 0 if it is
+// not known in advance, which is the case for ExtractDir with Deduplicate,
+// as files are then extracted while the tree is walked.
+//
+// Calls are serialized, even with Options.Concurrency greater than 1, so the
+// function does not need to be safe for concurrent use; it should return
+// quickly, as it blocks the workers meanwhile.
+type ProgressFunc func(done, total int, current string)
+
+// progressTracker counts the extracted files of a batch and reports them to
+// Options.Progress.
+type progressTracker struct {
+	mu    sync.Mutex
+	fn    ProgressFunc
+	done  int
+	total int
+}
+
+// newProgress returns a tracker for a batch of total files, or nil if no
+// progress is reported.
+func (me *MetaExtractor) newProgress(total int) *progressTracker {
+	if me.progress == nil {
+		return nil
+	}
+
+	return &progressTracker{fn: me.progress, total: total}
+}
+
+// report records that the file has been extracted.
+func (p *progressTracker) report(current string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	p.fn(p.done, p.total, current)
+}
@@ -0,0 +1,93 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// progressCall is a recorded call of a ProgressFunc.
+type progressCall struct {
+	done, total int
+	current     string
+}
+
+// recordProgress returns a ProgressFunc appending its calls to calls. It is
+// deliberately not synchronized, as calls must be serialized.
+func recordProgress(calls *[]progressCall) ProgressFunc {
+	return func(done, total int, current string) {
+		*calls = append(*calls, progressCall{done, total, current})
+	}
+}
+
+func TestProgress(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"a.txt":   "a",
+		"b.txt":   "bb",
+		"c/d.txt": "ddd",
+		"c/e.txt": "eeee",
+	})
+	paths := []string{
+		filepath.Join(root, "a.txt"),
+		filepath.Join(root, "b.txt"),
+		filepath.Join(root, "c", "d.txt"),
+		filepath.Join(root, "c", "e.txt"),
+		filepath.Join(root, "missing"),
+	}
+
+	// assertProgress checks that done counts up to the number of files, with
+	// every file reported once.
+	assertProgress := func(t *testing.T, calls []progressCall, files []string, total int) {
+		t.Helper()
+
+		require.Len(t, calls, len(files))
+		var current []string
+		for i, c := range calls {
+			assert.Equal(t, i+1, c.done)
+			assert.Equal(t, total, c.total)
+			current = append(current, c.current)
+		}
+		assert.ElementsMatch(t, files, current)
+	}
+
+	for _, concurrency := range []int{1, 4} {
+		t.Run("Batch", func(t *testing.T) {
+			var calls []progressCall
+			me := NewMetaExtractor(Options{PureGo: true, Concurrency: concurrency, Progress: recordProgress(&calls)})
+
+			me.ExtractBatch(paths)
+			assertProgress(t, calls, paths, len(paths))
+		})
+
+		t.Run("Dir", func(t *testing.T) {
+			var calls []progressCall
+			me := NewMetaExtractor(Options{PureGo: true, Concurrency: concurrency, Progress: recordProgress(&calls)})
+
+			_, err := me.ExtractDir(root, WalkOptions{})
+			require.NoError(t, err)
+			assertProgress(t, calls, paths[:4], 4)
+		})
+	}
+
+	t.Run("Deduplicate", func(t *testing.T) {
+		var calls []progressCall
+		me := NewMetaExtractor(Options{PureGo: true, Deduplicate: true, Progress: recordProgress(&calls)})
+
+		me.ExtractBatch(paths)
+		assertProgress(t, calls, paths, len(paths))
+
+		calls = nil
+		_, err := me.ExtractDir(root, WalkOptions{})
+		require.NoError(t, err)
+		assertProgress(t, calls, paths[:4], 0)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+		assert.Nil(t, me.newProgress(1))
+		assert.Nil(t, me.newExtractQueue())
+		assert.NotPanics(t, func() { me.newProgress(1).report("file") })
+	})
+}
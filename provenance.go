@@ -0,0 +1,251 @@
+package metaextractor
+
+import (
+	"strings"
+)
+
+// Sources of the fields reported in Metadata.Provenance. In addition,
+// detected types are attributed to the detector that produced them (e.g.,
+// "trid", "signature"), and values added by custom backends and routed
+// stages to their names.
+const (
+	// SourceFileSystem marks values read from the file system: the name,
+	// size and times of the file.
+	SourceFileSystem = "filesystem"
+
+	// SourceContent marks values computed from the content of the file:
+	// hashes, entropy, samples and sniffed MIME types.
+	SourceContent = "content"
+
+	// SourceExifTool marks values reported by ExifTool.
+	SourceExifTool = "exiftool"
+
+	// SourceNativeExif marks values read by the native EXIF parser (see
+	// NativeExifBackend).
+	SourceNativeExif = "exif-native"
+
+	// SourceFFprobe marks the media properties reported by ffprobe (see
+	// FFprobeBackend).
+	SourceFFprobe = "ffprobe"
+
+	// SourceMediaInfo marks the media properties reported by MediaInfo (see
+	// MediaInfoBackend).
+	SourceMediaInfo = "mediainfo"
+
+	// SourceFusion marks the type reconciled from the answers of the
+	// detectors and ExifTool (see Metadata.BestType).
+	SourceFusion = "fusion"
+
+	// SourceMagic marks values reported by libmagic (see MagicBackend).
+	SourceMagic = "magic"
+
+	// SourceManifest marks the verification against checksum manifests.
+	SourceManifest = "manifest"
+
+	// SourceIndex marks properties read from the OS search index.
+	SourceIndex = "index"
+
+	// SourceChangeJournal marks the identification in the NTFS change
+	// journal.
+	SourceChangeJournal = "changejournal"
+
+	// SourcePlist marks the metadata read from the Info.plist of a macOS
+	// bundle.
+	SourcePlist = "plist"
+
+	// SourceCloud marks the metadata reported by a cloud drive.
+	SourceCloud = "cloud"
+
+	// SourceImageCheck marks the anomalies found by the image checks.
+	SourceImageCheck = "imagecheck"
+
+	// SourceZip marks the central directory metadata of ZIP archives.
+	SourceZip = "zip"
+
+	// SourcePassword marks the password that unlocked the file.
+	SourcePassword = "password"
+
+	// SourceRules marks the labels of the matching rules.
+	SourceRules = "rules"
+
+	// SourceQuarantine marks the quarantine action.
+	SourceQuarantine = "quarantine"
+
+	// SourceStability marks the stability check of the file.
+	SourceStability = "stability"
+
+	// SourceChromaprint marks the audio fingerprint computed by fpcalc and
+	// the recordings it was identified as.
+	SourceChromaprint = "chromaprint"
+)
+
+// setSource records the source of a field if provenance is tracked.
+func (m *Metadata) setSource(field, source string) {
+	if m.Provenance != nil {
+		m.Provenance[field] = source
+	}
+}
+
+// snapshotKeys returns the keys of the Exif and Extra maps of the metadata,
+// used to attribute the keys added by a stage. It returns nil if provenance
+// is not tracked.
+func snapshotKeys(m *Metadata) map[string]bool {
+	if m.Provenance == nil {
+		return nil
+	}
+
+	keys := make(map[string]bool, len(m.Exif)+len(m.Extra))
+	for k := range m.Exif {
+		keys["Exif."+k] = true
+	}
+	for k := range m.Extra {
+		keys["Extra."+k] = true
+	}
+
+	return keys
+}
+
+// tagAddedKeys attributes the Exif and Extra keys added since the snapshot
+// to source.
+func tagAddedKeys(m *Metadata, before map[string]bool, source string) {
+	if before == nil {
+		return
+	}
+
+	for k := range m.Exif {
+		if !before["Exif."+k] {
+			m.setSource("Exif."+k, source)
+		}
+	}
+	for k := range m.Extra {
+		if !before["Extra."+k] {
+			m.setSource("Extra."+k, source)
+		}
+	}
+}
+
+// tagProvenance completes Metadata.Provenance once the extraction is done:
+// the fields whose source was not recorded by the stages are attributed to
+// the stage that sets them, and the entries of Exif and Extra keys that were
+// removed (e.g., by Options.ExifTags) are dropped.
+func tagProvenance(m *Metadata) {
+	if m.Provenance == nil {
+		return
+	}
+
+	for field := range m.Provenance {
+		if k, ok := strings.CutPrefix(field, "Exif."); ok {
+			if _, found := m.Exif[k]; !found {
+				delete(m.Provenance, field)
+			}
+		} else if k, ok := strings.CutPrefix(field, "Extra."); ok {
+			if _, found := m.Extra[k]; !found {
+				delete(m.Provenance, field)
+			}
+		}
+	}
+
+	tag := func(field string, present bool, source string) {
+		if _, ok := m.Provenance[field]; present && !ok {
+			m.Provenance[field] = source
+		}
+	}
+
+	tag("Name", m.Name != "", SourceFileSystem)
+	tag("RawName", m.RawName != "", SourceFileSystem)
+	tag("Extension", m.Extension != "", SourceFileSystem)
+	tag("RawExtension", m.RawExtension != "", SourceFileSystem)
+	tag("Size", m.Name != "", SourceFileSystem)
+	tag("Placeholder", m.Placeholder, SourceFileSystem)
+	tag("Time", m.Time != FileTime{}, SourceFileSystem)
+	tag("SplitArchive", m.SplitArchive != nil, SourceFileSystem)
+	tag("MacBundle", m.MacBundle != nil, SourcePlist)
+	tag("Cloud", m.Cloud != nil, SourceCloud)
+	tag("ChangeTracking", m.ChangeTracking != nil, SourceChangeJournal)
+	tag("IndexProperties", len(m.IndexProperties) > 0, SourceIndex)
+
+	tag("Hashes", len(m.Hashes) > 0, SourceContent)
+	tag("Entropy", m.Entropy != 0, SourceContent)
+	tag("Sketch", m.Sketch != nil, SourceContent)
+	tag("Head", len(m.Head) > 0, SourceContent)
+	tag("Tail", len(m.Tail) > 0, SourceContent)
+	tag("Checksums", len(m.Checksums) > 0, SourceManifest)
+
+	// The fields derived from the detected types share their source.
+	typesSource := m.Detector
+	tag("Types", len(m.Types) > 0, typesSource)
+	tag("Detector", m.Detector != "", typesSource)
+	tag("ExtMismatch", m.ExtMismatch, typesSource)
+	tag("SuggestedExtension", m.SuggestedExtension != "", typesSource)
+	tag("SuggestedName", m.SuggestedName != "", typesSource)
+	tag("BestType", m.BestType != nil, SourceFusion)
+	tag("Kind", m.Kind != "", kindSource(*m))
+	tag("Magic", m.Magic != nil, SourceMagic)
+	tag("Zip", m.Zip != nil, SourceZip)
+
+	for k := range m.Exif {
+		tag("Exif."+k, true, SourceExifTool)
+	}
+	tag("ExifBackend", m.ExifBackend != "", exifSource(*m))
+	tag("ExifTimes", len(m.ExifTimes) > 0, exifSource(*m))
+	tag("GPS", m.GPS != nil, exifSource(*m))
+	if m.BestCreatedAt != nil {
+		tag("BestCreatedAt", true, createdAtSource(*m))
+	}
+	tag("ContentCreated", !m.ContentCreated.IsZero(), exifSource(*m))
+	if m.Media != nil {
+		tag("Media", true, mediaSource(*m))
+	}
+	tag("Anomalies", len(m.Anomalies) > 0, SourceImageCheck)
+	tag("Password", m.Password != "", SourcePassword)
+
+	tag("Labels", len(m.Labels) > 0, SourceRules)
+	tag("Quarantine", m.Quarantine != nil, SourceQuarantine)
+	tag("Unstable", m.Unstable, SourceStability)
+}
+
+// kindSource returns the source of Metadata.Kind, following typeKind.
+func kindSource(m Metadata) string {
+	switch m.Kind {
+	case KindEmpty, KindPlaceholder, KindBundle:
+		return SourceFileSystem
+	}
+
+	if m.BestType != nil && mimeTypeKind(m.BestType.MimeType) != "" {
+		return SourceFusion
+	}
+	if len(m.Types) > 0 && m.Types[0].MimeType != "" {
+		return m.Detector
+	}
+
+	return SourceExifTool
+}
+
+// exifSource returns the source of the values derived from Exif: the native
+// parser if it extracted Exif alone, ExifTool otherwise.
+func exifSource(m Metadata) string {
+	if m.ExifBackend == SourceNativeExif {
+		return SourceNativeExif
+	}
+	return SourceExifTool
+}
+
+// mediaSource returns the source of Metadata.Media: the first backend that
+// reported it.
+func mediaSource(m Metadata) string {
+	name, _, _ := strings.Cut(m.Media.Analyzer, ",")
+	return name
+}
+
+// createdAtSource returns the source of Metadata.BestCreatedAt.
+func createdAtSource(m Metadata) string {
+	switch source := m.BestCreatedAt.Source; source {
+	case "BirthTime", "ModTime":
+		return SourceFileSystem
+	default:
+		if s, ok := m.Provenance["Exif."+source]; ok {
+			return s
+		}
+		return SourceExifTool
+	}
+}
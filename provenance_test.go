@@ -0,0 +1,109 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance(t *testing.T) {
+	samplePath := filepath.Join("testdata", "sample.doc")
+
+	probe := BackendFunc{
+		BackendName: "probe",
+		Fn: func(ctx context.Context, filePath string, metadata *Metadata) error {
+			metadata.Exif["Duration"] = 1.5
+			metadata.Exif["Codec"] = "h264"
+			if metadata.Extra == nil {
+				metadata.Extra = make(map[string]interface{})
+			}
+			metadata.Extra["streams"] = 2
+			return nil
+		},
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{PureGo: true}).Extract(samplePath)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Provenance)
+	})
+
+	t.Run("Stages", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			PureGo:     true,
+			Provenance: true,
+			Hashes:     []string{"sha256"},
+			Backends:   []Extractor{TridBackend, probe},
+		})
+
+		metadata, err := me.Extract(samplePath)
+		require.NoError(t, err)
+		require.NotEmpty(t, metadata.Types)
+
+		for field, source := range map[string]string{
+			"Name":          SourceFileSystem,
+			"Extension":     SourceFileSystem,
+			"Size":          SourceFileSystem,
+			"Time":          SourceFileSystem,
+			"Hashes":        SourceContent,
+			"Types":         "signature",
+			"Detector":      "signature",
+			"MimeType":      SourceFusion,
+			"Exif.Duration": "probe",
+			"Exif.Codec":    "probe",
+			"Extra.streams": "probe",
+		} {
+			assert.Equal(t, source, metadata.Provenance[field], field)
+		}
+		assert.NotContains(t, metadata.Provenance, "Magic")
+	})
+
+	t.Run("Filtered Tags", func(t *testing.T) {
+		me, err := NewMetaExtractor(Options{
+			PureGo:     true,
+			Provenance: true,
+			Backends:   []Extractor{probe},
+			Profiles:   map[string]Profile{"video": {ExifTags: []string{"Codec"}}},
+		}).WithProfile("video")
+		require.NoError(t, err)
+
+		metadata, err := me.Extract(samplePath)
+		require.NoError(t, err)
+		assert.Equal(t, "probe", metadata.Provenance["Exif.Codec"])
+		assert.NotContains(t, metadata.Provenance, "Exif.Duration")
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Provenance: true})
+
+		metadata, err := me.ExtractStreamInput(bytes.NewReader([]byte("%PDF-1.7\n")))
+		require.NoError(t, err)
+		assert.Equal(t, SourceContent, metadata.Provenance["Size"])
+		assert.Equal(t, "signature", metadata.Provenance["Types"])
+		assert.NotContains(t, metadata.Provenance, "Name")
+	})
+}
+
+func TestTagProvenance(t *testing.T) {
+	metadata := Metadata{
+		Provenance: map[string]string{"Exif.Removed": "probe", "Extra.thumbnail": "thumbnail"},
+		Exif:       ExifMetadata{"Model": "X100"},
+		Extra:      map[string]interface{}{"thumbnail": "thumb.jpg"},
+		Kind:       KindEmpty,
+	}
+
+	tagProvenance(&metadata)
+	assert.Equal(t, map[string]string{
+		"Exif.Model":      SourceExifTool,
+		"Extra.thumbnail": "thumbnail",
+		"Kind":            SourceFileSystem,
+	}, metadata.Provenance)
+
+	metadata.Provenance = nil
+	tagProvenance(&metadata)
+	assert.Nil(t, metadata.Provenance)
+}
@@ -0,0 +1,233 @@
+package metaextractor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// QuarantineOptions configures the quarantine action.
+type QuarantineOptions struct {
+	// Dir is the quarantine directory. The quarantine action is disabled if
+	// it is empty.
+	Dir string
+
+	// Copy copies suspicious files into Dir instead of moving them.
+	Copy bool
+
+	// Rules decide which files are quarantined. If nil, DefaultQuarantineRules
+	// are used.
+	Rules []QuarantineRule
+}
+
+// QuarantineRule inspects the metadata of a file and reports whether it should
+// be quarantined, together with a human-readable reason.
+type QuarantineRule func(Metadata) (reason string, ok bool)
+
+// QuarantineAction records that a file has been quarantined.
+type QuarantineAction struct {
+	// Reason is the reason reported by the matching rule.
+	Reason string `json:"reason,omitempty"`
+
+	// Path is the location of the file inside the quarantine directory.
+	Path string `json:"path,omitempty"`
+
+	// Copied indicates whether the file was copied rather than moved.
+	Copied bool `json:"copied,omitempty"`
+}
+
+// DefaultQuarantineRules are the rules used when QuarantineOptions.Rules is nil.
+var DefaultQuarantineRules = []QuarantineRule{
+	MismatchedExecutable,
+	MacroDocument,
+	EncryptedArchive,
+}
+
+var (
+	executableExts = map[string]bool{
+		".exe": true, ".dll": true, ".scr": true, ".com": true, ".sys": true,
+		".cpl": true, ".ocx": true, ".elf": true, ".so": true, ".dylib": true,
+	}
+	macroDocumentExts = map[string]bool{
+		".docm": true, ".dotm": true, ".xlsm": true, ".xltm": true, ".xlam": true,
+		".pptm": true, ".potm": true, ".ppsm": true, ".ppam": true,
+	}
+	archiveExts = map[string]bool{
+		".zip": true, ".rar": true, ".7z": true, ".jar": true,
+	}
+)
+
+// MismatchedExecutable matches files with executable content whose extension
+// does not match their detected type (e.g., an .exe disguised as a .pdf).
+func MismatchedExecutable(metadata Metadata) (string, bool) {
+	if metadata.ExtMismatch && isExecutable(metadata) {
+		return "executable content with mismatched extension", true
+	}
+
+	return "", false
+}
+
+// MacroDocument matches office documents that may contain macros.
+func MacroDocument(metadata Metadata) (string, bool) {
+	for _, ext := range append(detectedExtensions(metadata), metadata.Extension) {
+		if macroDocumentExts[ext] {
+			return "macro-enabled document", true
+		}
+	}
+
+	if len(metadata.Types) > 0 && strings.Contains(strings.ToLower(metadata.Types[0].Name), "macro") {
+		return "macro-enabled document", true
+	}
+
+	return "", false
+}
+
+// EncryptedArchive matches password-protected archives.
+func EncryptedArchive(metadata Metadata) (string, bool) {
+	isArchive := false
+	for _, ext := range detectedExtensions(metadata) {
+		if archiveExts[ext] {
+			isArchive = true
+			break
+		}
+	}
+
+	if !isArchive {
+		return "", false
+	}
+
+	if len(metadata.Types) > 0 && strings.Contains(strings.ToLower(metadata.Types[0].Name), "encrypted") {
+		return "encrypted archive", true
+	}
+
+	// Bit 0 of the ZIP general purpose flag indicates an encrypted entry.
+	if flag, ok := exifInt(metadata.Exif, "ZipBitFlag"); ok && flag&1 == 1 {
+		return "encrypted archive", true
+	}
+
+	return "", false
+}
+
+// isExecutable reports whether the detected type of the file is an executable.
+func isExecutable(metadata Metadata) bool {
+	for _, ext := range detectedExtensions(metadata) {
+		if executableExts[ext] {
+			return true
+		}
+	}
+
+	switch detectedMimeType(metadata) {
+	case "application/x-dosexec", "application/x-msdownload", "application/x-executable",
+		"application/x-elf", "application/x-mach-binary", "application/vnd.microsoft.portable-executable":
+		return true
+	}
+
+	if fileType, ok := exifValue(metadata.Exif, "FileType").(string); ok {
+		fileType = strings.ToUpper(fileType)
+		return strings.Contains(fileType, "EXE") || strings.Contains(fileType, "DLL") ||
+			fileType == "ELF" || fileType == "MACH-O"
+	}
+
+	return false
+}
+
+// exifInt returns the integer value of an EXIF field. ExifTool reports some
+// numeric values as hexadecimal strings (e.g., "0x0009").
+func exifInt(exif ExifMetadata, key string, precedence ...string) (int64, bool) {
+	switch v := exifValue(exif, key, precedence...).(type) {
+	case float64:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+// quarantine moves or copies the file into the quarantine directory if any of
+// the configured rules match.
+func (me *MetaExtractor) quarantine(filePath string, metadata *Metadata) error {
+	if me.quarantineOpts.Dir == "" {
+		return nil
+	}
+
+	rules := me.quarantineOpts.Rules
+	if rules == nil {
+		rules = DefaultQuarantineRules
+	}
+
+	for _, rule := range rules {
+		reason, ok := rule(*metadata)
+		if !ok {
+			continue
+		}
+
+		if err := os.MkdirAll(me.quarantineOpts.Dir, 0o700); err != nil {
+			return fmt.Errorf("error creating quarantine directory: %w", err)
+		}
+
+		dest, err := uniquePath(filepath.Join(me.quarantineOpts.Dir, metadata.Name), nil)
+		if err != nil {
+			return fmt.Errorf("error quarantining file: %w", err)
+		}
+
+		if me.quarantineOpts.Copy {
+			err = copyFile(filePath, dest)
+		} else {
+			err = moveFile(filePath, dest)
+		}
+		if err != nil {
+			return fmt.Errorf("error quarantining file: %w", err)
+		}
+
+		metadata.Quarantine = &QuarantineAction{
+			Reason: reason,
+			Path:   dest,
+			Copied: me.quarantineOpts.Copy,
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// moveFile renames src to dst, falling back to copy and delete when the
+// paths are on different file systems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// copyFile copies the contents of src into a new file at dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,89 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantineRules(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     QuarantineRule
+		metadata Metadata
+		expected bool
+	}{
+		{
+			name: "Disguised Executable",
+			rule: MismatchedExecutable,
+			metadata: Metadata{
+				Extension:   ".pdf",
+				ExtMismatch: true,
+				Types:       []trid.FileType{{Extension: ".exe/.dll"}},
+			},
+			expected: true,
+		},
+		{
+			name: "Executable Without Mismatch",
+			rule: MismatchedExecutable,
+			metadata: Metadata{
+				Extension: ".exe",
+				Types:     []trid.FileType{{Extension: ".exe"}},
+			},
+			expected: false,
+		},
+		{
+			name:     "Macro Document",
+			rule:     MacroDocument,
+			metadata: Metadata{Extension: ".docm"},
+			expected: true,
+		},
+		{
+			name:     "Encrypted ZIP",
+			rule:     EncryptedArchive,
+			metadata: Metadata{Types: []trid.FileType{{Extension: ".zip"}}, Exif: ExifMetadata{"ZipBitFlag": "0x0009"}},
+			expected: true,
+		},
+		{
+			name:     "Plain ZIP",
+			rule:     EncryptedArchive,
+			metadata: Metadata{Types: []trid.FileType{{Extension: ".zip"}}, Exif: ExifMetadata{"ZipBitFlag": float64(0)}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := tc.rule(tc.metadata)
+			assert.Equal(t, tc.expected, ok)
+		})
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "invoice.docm")
+	require.NoError(t, os.WriteFile(src, []byte("data"), 0o644))
+
+	dir := filepath.Join(t.TempDir(), "quarantine")
+
+	for _, cp := range []bool{true, false} {
+		me := NewMetaExtractor(Options{Quarantine: QuarantineOptions{Dir: dir, Copy: cp}})
+
+		metadata := Metadata{Name: "invoice.docm", Extension: ".docm"}
+		require.NoError(t, me.quarantine(src, &metadata))
+		require.NotNil(t, metadata.Quarantine)
+		assert.Equal(t, cp, metadata.Quarantine.Copied)
+		assert.FileExists(t, metadata.Quarantine.Path)
+
+		_, err := os.Stat(src)
+		assert.Equal(t, cp, err == nil)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
@@ -0,0 +1,278 @@
+package metaextractor
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedactedValue replaces the values of masked tags.
+const RedactedValue = "[redacted]"
+
+// RedactionPolicy describes how metadata is redacted before it is shared.
+// Tag patterns use path.Match syntax, are case-insensitive and match tags
+// regardless of their group (e.g., "*SerialNumber" matches
+// "EXIF:BodySerialNumber").
+type RedactionPolicy struct {
+	// Mask lists the tags whose values are replaced with RedactedValue.
+	Mask []string
+
+	// Drop lists the tags that are removed.
+	Drop []string
+
+	// TruncateGPS truncates GPS coordinates to GPSDecimals decimal places
+	// of a degree (2 decimals are about 1 km).
+	TruncateGPS bool
+	GPSDecimals int
+
+	// DropPassword removes the password that unlocked the file.
+	DropPassword bool
+
+	// DropSharing removes the owners, the users the file is shared with and
+	// the sharing links of cloud files (Metadata.Cloud).
+	DropSharing bool
+}
+
+// DefaultRedactionPolicy masks serial numbers, drops the names of owners and
+// authors, the download origins of the OS search index and the sharing of
+// cloud files, and truncates GPS coordinates to about 1 km.
+var DefaultRedactionPolicy = RedactionPolicy{
+	Mask: []string{"*SerialNumber", "ImageUniqueID"},
+	Drop: []string{
+		"OwnerName", "CameraOwnerName", "Artist", "Author", "Creator",
+		"By-line", "LastModifiedBy", "XPAuthor", "Copyright",
+		"kMDItemAuthors", "kMDItemWhereFroms", "System.Author",
+	},
+	TruncateGPS:  true,
+	GPSDecimals:  2,
+	DropPassword: true,
+	DropSharing:  true,
+}
+
+// gpsCoordinateTags are the tags holding GPS coordinates. GPSPosition and
+// GPSCoordinates hold a latitude and a longitude separated by a comma.
+var gpsCoordinateTags = map[string]bool{
+	"gpslatitude": true, "gpslongitude": true, "gpsdestlatitude": true,
+	"gpsdestlongitude": true, "gpsposition": true, "gpscoordinates": true,
+}
+
+var coordinateNumbers = regexp.MustCompile(`[-+]?\d+(?:\.\d+)?`)
+
+// Apply returns a redacted copy of the metadata. The metadata passed in is
+// not modified.
+func (p RedactionPolicy) Apply(metadata Metadata) Metadata {
+	exif := make(ExifMetadata, len(metadata.Exif))
+	for key, value := range metadata.Exif {
+		if nested, ok := value.(map[string]interface{}); ok {
+			tags := make(map[string]interface{}, len(nested))
+			for tag, v := range nested {
+				if v, keep := p.redact(tag, v); keep {
+					tags[tag] = v
+				}
+			}
+			exif[key] = tags
+			continue
+		}
+
+		if v, keep := p.redact(key, value); keep {
+			exif[key] = v
+		}
+	}
+	metadata.Exif = exif
+
+	if metadata.ExifTimes != nil {
+		times := make(map[string]time.Time, len(metadata.ExifTimes))
+		for key, t := range metadata.ExifTimes {
+			if !p.matches(p.Drop, key) && !p.matches(p.Mask, key) {
+				times[key] = t
+			}
+		}
+		metadata.ExifTimes = times
+	}
+
+	// The creation time is dropped if it was taken from a redacted tag.
+	if b := metadata.BestCreatedAt; b != nil && (p.matches(p.Drop, b.Source) || p.matches(p.Mask, b.Source)) {
+		metadata.BestCreatedAt = nil
+	}
+
+	// The content creation time is dropped if no remaining date holds it.
+	if !metadata.ContentCreated.IsZero() {
+		kept := false
+		for _, t := range metadata.ExifTimes {
+			kept = kept || t.Equal(metadata.ContentCreated)
+		}
+		if !kept {
+			metadata.ContentCreated = time.Time{}
+		}
+	}
+
+	// The location and the normalized properties are mapped again from the
+	// redacted tags.
+	if metadata.GPS != nil {
+		metadata.GPS = parseGPS(metadata.Exif, metadata.exifPrecedence)
+	}
+	if metadata.Normalized != nil {
+		metadata.Normalized = normalizeMetadata(metadata)
+	}
+
+	if metadata.Media != nil {
+		metadata.Media = p.redactMedia(*metadata.Media)
+	}
+
+	// The properties of the OS search index are redacted like EXIF tags.
+	if metadata.IndexProperties != nil {
+		props := make(map[string]interface{}, len(metadata.IndexProperties))
+		for key, value := range metadata.IndexProperties {
+			if v, keep := p.redact(key, value); keep {
+				props[key] = v
+			}
+		}
+		metadata.IndexProperties = props
+	}
+
+	if p.DropPassword {
+		metadata.Password = ""
+	}
+
+	if p.DropSharing && metadata.Cloud != nil {
+		cloud := *metadata.Cloud
+		cloud.Owners, cloud.SharedWith, cloud.Links = nil, nil, nil
+		metadata.Cloud = &cloud
+	}
+
+	return metadata
+}
+
+// redactMedia returns a copy of the media properties with their tags
+// redacted like EXIF tags. Locations (e.g., the ISO 6709 location of
+// QuickTime files, reported as "location" by ffprobe and "xyz" by
+// MediaInfo) are masked rather than truncated.
+func (p RedactionPolicy) redactMedia(media Media) *Media {
+	redactTags := func(tags map[string]string) map[string]string {
+		if tags == nil {
+			return nil
+		}
+
+		redacted := make(map[string]string, len(tags))
+		for k, v := range tags {
+			switch {
+			case p.matches(p.Drop, k):
+				continue
+			case p.matches(p.Mask, k), p.TruncateGPS && isMediaLocationTag(k):
+				v = RedactedValue
+			}
+			redacted[k] = v
+		}
+		return redacted
+	}
+
+	media.Tags = redactTags(media.Tags)
+	media.Streams = slices.Clone(media.Streams)
+	for i := range media.Streams {
+		media.Streams[i].Tags = redactTags(media.Streams[i].Tags)
+	}
+
+	return &media
+}
+
+// isMediaLocationTag reports whether a media tag holds a location.
+func isMediaLocationTag(key string) bool {
+	key = strings.ToLower(key)
+	return key == "xyz" || strings.Contains(key, "location")
+}
+
+// redact returns the redacted value of a tag and whether the tag is kept.
+func (p RedactionPolicy) redact(key string, value interface{}) (interface{}, bool) {
+	switch {
+	case p.matches(p.Drop, key):
+		return nil, false
+	case p.matches(p.Mask, key):
+		return RedactedValue, true
+	case p.TruncateGPS && gpsCoordinateTags[strings.ToLower(tagName(key))]:
+		return truncateCoordinates(value, p.GPSDecimals), true
+	}
+
+	return value, true
+}
+
+// matches reports whether the tag of the key matches any of the patterns.
+func (p RedactionPolicy) matches(patterns []string, key string) bool {
+	return matchTag(patterns, key)
+}
+
+// tagName returns the tag name of a key, without its group.
+func tagName(key string) string {
+	if _, tag, ok := strings.Cut(key, ":"); ok {
+		return tag
+	}
+	return key
+}
+
+// truncateCoordinates truncates the GPS coordinates of a value. Numeric
+// values are truncated as is. Textual values (e.g., `52 deg 30' 12.34" N`)
+// are converted to signed decimal degrees; values holding several
+// coordinates separated by commas are returned as text. Values that cannot
+// be parsed are replaced with RedactedValue.
+func truncateCoordinates(value interface{}, decimals int) interface{} {
+	if v, ok := value.(float64); ok {
+		return truncateDecimals(v, decimals)
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return RedactedValue
+	}
+
+	parts := strings.Split(s, ",")
+	coords := make([]string, len(parts))
+
+	for i, part := range parts {
+		v, ok := parseCoordinate(part)
+		if !ok {
+			return RedactedValue
+		}
+
+		v = truncateDecimals(v, decimals)
+		if len(parts) == 1 {
+			return v
+		}
+		coords[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return strings.Join(coords, ", ")
+}
+
+// parseCoordinate parses a coordinate in degrees, minutes and seconds or in
+// decimal degrees, with an optional hemisphere reference (N, S, E or W).
+func parseCoordinate(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+
+	numbers := coordinateNumbers.FindAllString(s, -1)
+	if len(numbers) == 0 || len(numbers) > 3 {
+		return 0, false
+	}
+
+	var v float64
+	for i, n := range numbers {
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, false
+		}
+		v += math.Abs(f) / math.Pow(60, float64(i))
+	}
+
+	if strings.HasPrefix(numbers[0], "-") || strings.HasSuffix(s, "S") || strings.HasSuffix(s, "W") {
+		v = -v
+	}
+
+	return v, true
+}
+
+// truncateDecimals truncates v to the given number of decimal places.
+func truncateDecimals(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(max(decimals, 0)))
+	return math.Trunc(v*scale) / scale
+}
@@ -0,0 +1,106 @@
+package metaextractor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactionPolicy_Apply(t *testing.T) {
+	taken := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	metadata := Metadata{
+		Name:     "photo.jpg",
+		Password: "secret",
+		Exif: ExifMetadata{
+			"Make":                  "Canon",
+			"EXIF:BodySerialNumber": "123456",
+			"OwnerName":             "Jane Doe",
+			"GPSLatitude":           `52 deg 30' 12.34" N`,
+			"GPSLongitude":          -13.40567,
+			"GPSPosition":           `52 deg 30' 12.34" N, 13 deg 24' 20.41" W`,
+			"XMP": map[string]interface{}{
+				"Creator": "Jane Doe",
+				"Rating":  5.0,
+			},
+		},
+		ExifTimes: map[string]time.Time{"DateTimeOriginal": taken, "Artist": taken},
+		IndexProperties: map[string]interface{}{
+			"kMDItemTitle":      "Holiday",
+			"kMDItemAuthors":    []string{"Jane Doe"},
+			"kMDItemWhereFroms": []string{"https://example.com/photo.jpg"},
+			"System.Author":     "Jane Doe",
+		},
+		Cloud: &CloudFile{
+			Provider:   "gdrive",
+			Path:       "/photo.jpg",
+			Owners:     []string{"jane@example.com"},
+			SharedWith: []string{"bob@example.com"},
+			Links:      []string{"https://drive/1"},
+		},
+	}
+
+	redacted := DefaultRedactionPolicy.Apply(metadata)
+
+	assert.Equal(t, ExifMetadata{
+		"Make":                  "Canon",
+		"EXIF:BodySerialNumber": RedactedValue,
+		"GPSLatitude":           52.5,
+		"GPSLongitude":          -13.4,
+		"GPSPosition":           "52.5, -13.4",
+		"XMP":                   map[string]interface{}{"Rating": 5.0},
+	}, redacted.Exif)
+	assert.Equal(t, map[string]time.Time{"DateTimeOriginal": taken}, redacted.ExifTimes)
+	assert.Equal(t, map[string]interface{}{"kMDItemTitle": "Holiday"}, redacted.IndexProperties)
+	assert.Equal(t, &CloudFile{Provider: "gdrive", Path: "/photo.jpg"}, redacted.Cloud)
+	assert.Empty(t, redacted.Password)
+	assert.Equal(t, "photo.jpg", redacted.Name)
+
+	// The original metadata is not modified.
+	assert.Equal(t, "123456", metadata.Exif["EXIF:BodySerialNumber"])
+	assert.Equal(t, "Jane Doe", metadata.Exif["XMP"].(map[string]interface{})["Creator"])
+	assert.Equal(t, "secret", metadata.Password)
+	assert.Len(t, metadata.IndexProperties, 4)
+	assert.Equal(t, []string{"jane@example.com"}, metadata.Cloud.Owners)
+
+	t.Run("Creation Time", func(t *testing.T) {
+		metadata := Metadata{BestCreatedAt: &BestCreatedAt{Time: taken, Source: "DateTimeOriginal"}}
+		assert.NotNil(t, DefaultRedactionPolicy.Apply(metadata).BestCreatedAt)
+
+		policy := RedactionPolicy{Drop: []string{"DateTime*"}}
+		assert.Nil(t, policy.Apply(metadata).BestCreatedAt)
+	})
+}
+
+func TestParseCoordinate(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+		ok       bool
+	}{
+		{`52 deg 30' 36.00" N`, 52.51, true},
+		{`33 deg 52' 4.80" S`, -33.868, true},
+		{`13 deg 24' 0.00"`, 13.4, true},
+		{"-122.4194", -122.4194, true},
+		{"151.2 E", 151.2, true},
+		{"", 0, false},
+		{"1 2 3 4", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			v, ok := parseCoordinate(tc.input)
+			assert.Equal(t, tc.ok, ok)
+			assert.InDelta(t, tc.expected, v, 1e-9)
+		})
+	}
+}
+
+func TestTruncateCoordinates(t *testing.T) {
+	assert.Equal(t, 52.5, truncateCoordinates(52.5678, 1))
+	assert.Equal(t, -52.56, truncateCoordinates(-52.5678, 2))
+	assert.Equal(t, 52.0, truncateCoordinates("52.9 N", 0))
+	assert.Equal(t, RedactedValue, truncateCoordinates("unknown", 2))
+	assert.Equal(t, RedactedValue, truncateCoordinates(true, 2))
+}
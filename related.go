@@ -0,0 +1,211 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Relation is the way files taken together by a camera are related.
+type Relation string
+
+const (
+	// RelationLivePhoto links the still image and the video of a live
+	// photo (e.g., "IMG_0001.HEIC" and "IMG_0001.MOV").
+	RelationLivePhoto Relation = "live-photo"
+
+	// RelationRawPair links the RAW and the processed image of a shot
+	// (e.g., "DSC_0001.NEF" and "DSC_0001.JPG").
+	RelationRawPair Relation = "raw-pair"
+
+	// RelationBurst links the images of a burst sequence.
+	RelationBurst Relation = "burst"
+)
+
+// RelatedFile is a file related to a result (see WalkOptions.RelatedFiles).
+type RelatedFile struct {
+	// Path is the path of the related result.
+	Path string
+
+	// Relation is how the files are related.
+	Relation Relation
+}
+
+// burstInterval is the maximum time between consecutive images of a burst
+// sequence without a burst identifier.
+const burstInterval = time.Second
+
+// minBurstLength is the minimum number of images of a burst sequence without
+// a burst identifier, so that two photos taken in quick succession are not
+// reported as a burst.
+const minBurstLength = 3
+
+var (
+	// stillExtensions are the extensions of the still images of live photos
+	// and the processed images of RAW pairs.
+	stillExtensions = map[string]bool{".heic": true, ".heif": true, ".jpg": true, ".jpeg": true}
+
+	// motionExtensions are the extensions of the videos of live photos.
+	motionExtensions = map[string]bool{".mov": true, ".mp4": true}
+
+	// rawExtensions are the extensions of camera RAW images.
+	rawExtensions = map[string]bool{
+		".3fr": true, ".arw": true, ".cr2": true, ".cr3": true, ".crw": true,
+		".dng": true, ".erf": true, ".iiq": true, ".kdc": true, ".mos": true,
+		".mrw": true, ".nef": true, ".nrw": true, ".orf": true, ".pef": true,
+		".raf": true, ".raw": true, ".rw2": true, ".rwl": true, ".sr2": true,
+		".srf": true, ".srw": true, ".x3f": true,
+	}
+)
+
+// relatedLinker collects the relations between the results of a scan.
+type relatedLinker struct {
+	results []Result
+	linked  map[relatedLink]bool
+}
+
+// relatedLink is a relation from one result to another.
+type relatedLink struct {
+	from, to int
+	relation Relation
+}
+
+// linkRelatedFiles sets Result.RelatedFiles for the live photos, RAW pairs
+// and burst sequences among the results. Files are paired by name within
+// their directory, or by the content and burst identifiers recorded by
+// Apple devices; bursts without an identifier are sequences of at least
+// three images of the same camera and format taken at most a second apart.
+func linkRelatedFiles(results []Result) {
+	l := relatedLinker{results: results, linked: make(map[relatedLink]bool)}
+
+	stems := make(map[string][]int)
+	contentIDs := make(map[string][]int)
+	burstIDs := make(map[string][]int)
+	sequences := make(map[string][]int)
+	captured := make(map[int]time.Time)
+
+	for i, r := range results {
+		if r.Err != nil || r.Dir != nil || r.Bag != nil {
+			continue
+		}
+
+		dir, base := filepath.Dir(r.Path), filepath.Base(r.Path)
+		ext := strings.ToLower(filepath.Ext(base))
+		stem := dir + "\x00" + strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base)))
+		stems[stem] = append(stems[stem], i)
+
+		exif, precedence := r.Metadata.Exif, r.Metadata.exifPrecedence
+		if id := stringValue(exifValue(exif, "ContentIdentifier", precedence...)); id != "" {
+			contentIDs[id] = append(contentIDs[id], i)
+		}
+
+		if id := stringValue(exifValue(exif, "BurstUUID", precedence...)); id != "" {
+			burstIDs[id] = append(burstIDs[id], i)
+		} else if t, ok := captureTime(r.Metadata); ok && (stillExtensions[ext] || rawExtensions[ext]) {
+			captured[i] = t
+			camera := stringValue(exifValue(exif, "Make", precedence...)) + "\x00" + stringValue(exifValue(exif, "Model", precedence...))
+			key := dir + "\x00" + camera + "\x00" + ext
+			sequences[key] = append(sequences[key], i)
+		}
+	}
+
+	for _, group := range stems {
+		var stills, motions, raws []int
+		for _, i := range group {
+			switch ext := strings.ToLower(filepath.Ext(results[i].Path)); {
+			case stillExtensions[ext]:
+				stills = append(stills, i)
+			case motionExtensions[ext]:
+				motions = append(motions, i)
+			case rawExtensions[ext]:
+				raws = append(raws, i)
+			}
+		}
+
+		if len(stills) > 0 && len(motions) > 0 {
+			l.link(append(stills, motions...), RelationLivePhoto)
+		}
+		if len(raws) > 0 && len(stills) > 0 {
+			l.link(append(raws, stills...), RelationRawPair)
+		}
+	}
+
+	for _, group := range contentIDs {
+		l.link(group, RelationLivePhoto)
+	}
+
+	for _, group := range burstIDs {
+		l.link(group, RelationBurst)
+	}
+
+	for _, group := range sequences {
+		sort.SliceStable(group, func(a, b int) bool {
+			return captured[group[a]].Before(captured[group[b]])
+		})
+
+		start := 0
+		for i := 1; i <= len(group); i++ {
+			if i < len(group) && captured[group[i]].Sub(captured[group[i-1]]) <= burstInterval {
+				continue
+			}
+
+			if i-start >= minBurstLength {
+				l.link(group[start:i], RelationBurst)
+			}
+			start = i
+		}
+	}
+
+	for i := range results {
+		sort.Slice(results[i].RelatedFiles, func(a, b int) bool {
+			ra, rb := results[i].RelatedFiles[a], results[i].RelatedFiles[b]
+			if ra.Relation != rb.Relation {
+				return ra.Relation < rb.Relation
+			}
+			return ra.Path < rb.Path
+		})
+	}
+}
+
+// link relates every result of the group to the others.
+func (l *relatedLinker) link(group []int, relation Relation) {
+	if len(group) < 2 {
+		return
+	}
+
+	for _, from := range group {
+		for _, to := range group {
+			link := relatedLink{from: from, to: to, relation: relation}
+			if from == to || l.linked[link] {
+				continue
+			}
+			l.linked[link] = true
+
+			l.results[from].RelatedFiles = append(l.results[from].RelatedFiles, RelatedFile{
+				Path:     l.results[to].Path,
+				Relation: relation,
+			})
+		}
+	}
+}
+
+// captureTime returns the time the image was taken, preferring the time with
+// subseconds, which orders the images of a burst.
+func captureTime(metadata Metadata) (time.Time, bool) {
+	for _, tag := range []string{"SubSecDateTimeOriginal", "DateTimeOriginal"} {
+		var keys []string
+		for key := range metadata.ExifTimes {
+			if tagName(key) == tag {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		if len(keys) > 0 {
+			return metadata.ExifTimes[keys[0]], true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,98 @@
+package metaextractor
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkRelatedFiles(t *testing.T) {
+	shot := time.Date(2024, 8, 3, 14, 0, 0, 0, time.UTC)
+	photo := func(path string, taken time.Time, exif ExifMetadata) Result {
+		if exif == nil {
+			exif = ExifMetadata{}
+		}
+		exif["Make"], exif["Model"] = "Canon", "EOS R6"
+		return Result{Path: path, Metadata: Metadata{
+			Exif:      exif,
+			ExifTimes: map[string]time.Time{"EXIF:SubSecDateTimeOriginal": taken},
+		}}
+	}
+
+	results := []Result{
+		{Path: "dcim/IMG_0001.HEIC"},
+		{Path: "dcim/IMG_0001.MOV"},
+		{Path: "dcim/img_0001.aae"},
+		{Path: "dcim/IMG_0002.JPG", Metadata: Metadata{Exif: ExifMetadata{"MakerNotes:ContentIdentifier": "A1"}}},
+		{Path: "dcim/clip.mov", Metadata: Metadata{Exif: ExifMetadata{"QuickTime:ContentIdentifier": "A1"}}},
+		{Path: "raw/DSC_0001.NEF"},
+		{Path: "raw/DSC_0001.jpg"},
+		{Path: "other/DSC_0001.jpg"},
+		{Path: "iphone/IMG_0010.HEIC", Metadata: Metadata{Exif: ExifMetadata{"BurstUUID": "B1"}}},
+		{Path: "iphone/IMG_0011.HEIC", Metadata: Metadata{Exif: ExifMetadata{"BurstUUID": "B1"}}},
+		photo("burst/1.jpg", shot, nil),
+		photo("burst/2.jpg", shot.Add(300*time.Millisecond), nil),
+		photo("burst/3.jpg", shot.Add(600*time.Millisecond), nil),
+		photo("burst/4.jpg", shot.Add(10*time.Second), nil),
+		photo("burst/5.jpg", shot.Add(10500*time.Millisecond), nil),
+		{Path: "broken/IMG_0001.MOV", Err: errors.New("failed")},
+		{Path: "broken/IMG_0001.HEIC"},
+	}
+	for i := range results {
+		results[i].Path = filepath.FromSlash(results[i].Path)
+	}
+
+	linkRelatedFiles(results)
+
+	related := make(map[string][]RelatedFile)
+	for _, r := range results {
+		for _, f := range r.RelatedFiles {
+			f.Path = filepath.ToSlash(f.Path)
+			related[filepath.ToSlash(r.Path)] = append(related[filepath.ToSlash(r.Path)], f)
+		}
+	}
+
+	assert.Equal(t, map[string][]RelatedFile{
+		"dcim/IMG_0001.HEIC":   {{Path: "dcim/IMG_0001.MOV", Relation: RelationLivePhoto}},
+		"dcim/IMG_0001.MOV":    {{Path: "dcim/IMG_0001.HEIC", Relation: RelationLivePhoto}},
+		"dcim/IMG_0002.JPG":    {{Path: "dcim/clip.mov", Relation: RelationLivePhoto}},
+		"dcim/clip.mov":        {{Path: "dcim/IMG_0002.JPG", Relation: RelationLivePhoto}},
+		"raw/DSC_0001.NEF":     {{Path: "raw/DSC_0001.jpg", Relation: RelationRawPair}},
+		"raw/DSC_0001.jpg":     {{Path: "raw/DSC_0001.NEF", Relation: RelationRawPair}},
+		"iphone/IMG_0010.HEIC": {{Path: "iphone/IMG_0011.HEIC", Relation: RelationBurst}},
+		"iphone/IMG_0011.HEIC": {{Path: "iphone/IMG_0010.HEIC", Relation: RelationBurst}},
+		"burst/1.jpg":          {{Path: "burst/2.jpg", Relation: RelationBurst}, {Path: "burst/3.jpg", Relation: RelationBurst}},
+		"burst/2.jpg":          {{Path: "burst/1.jpg", Relation: RelationBurst}, {Path: "burst/3.jpg", Relation: RelationBurst}},
+		"burst/3.jpg":          {{Path: "burst/1.jpg", Relation: RelationBurst}, {Path: "burst/2.jpg", Relation: RelationBurst}},
+	}, related)
+}
+
+func TestExtractDir_RelatedFiles(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"IMG_0001.HEIC": "heic",
+		"IMG_0001.MOV":  "mov",
+		"notes.txt":     "text",
+	})
+	me := NewMetaExtractor(Options{PureGo: true})
+
+	results, err := me.ExtractDir(root, WalkOptions{})
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.Empty(t, r.RelatedFiles)
+	}
+
+	results, err = me.ExtractDir(root, WalkOptions{RelatedFiles: true})
+	require.NoError(t, err)
+
+	byPath := make(map[string]Result)
+	for _, r := range results {
+		byPath[r.Path] = r
+	}
+	assert.Equal(t, []RelatedFile{{Path: filepath.Join(root, "IMG_0001.MOV"), Relation: RelationLivePhoto}},
+		byPath[filepath.Join(root, "IMG_0001.HEIC")].RelatedFiles)
+	assert.Empty(t, byPath[filepath.Join(root, "notes.txt")].RelatedFiles)
+}
@@ -0,0 +1,615 @@
+package metaextractor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hirochachacha/go-smb2"
+	"golang.org/x/crypto/ssh"
+)
+
+// RemoteFS is the file system of a remote host, opened by OpenSFTP or
+// OpenSMB, which can be scanned with ExtractFS without mounting it. Close
+// closes the connection.
+type RemoteFS interface {
+	fs.FS
+	io.Closer
+}
+
+// OpenSFTP connects to the SSH server at addr ("host:port") and returns the
+// tree rooted at root on the server, read through its SFTP subsystem. An
+// empty root is the login directory of the user. The file system is
+// read-only.
+func OpenSFTP(addr string, config *ssh.ClientConfig, root string) (RemoteFS, error) {
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys, err := newSFTPFS(conn, root)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return fsys, nil
+}
+
+// OpenSMB connects to the SMB server at addr ("host:445"), authenticating
+// with initiator (e.g., an *smb2.NTLMInitiator), and returns the tree rooted
+// at root (a path in the share, empty for the whole share) of the share.
+func OpenSMB(addr string, initiator smb2.Initiator, share, root string) (RemoteFS, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &smb2.Dialer{Initiator: initiator}
+	session, err := d.Dial(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	mounted, err := session.Mount(share)
+	if err != nil {
+		session.Logoff()
+		conn.Close()
+		return nil, err
+	}
+
+	return &smbFS{FS: mounted.DirFS(root), share: mounted, session: session, conn: conn}, nil
+}
+
+// smbFS is the file system of an SMB share.
+type smbFS struct {
+	fs.FS
+	share   *smb2.Share
+	session *smb2.Session
+	conn    net.Conn
+}
+
+func (f *smbFS) Close() error {
+	return errors.Join(f.share.Umount(), f.session.Logoff(), f.conn.Close())
+}
+
+// SFTP (version 3) packet types.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpOpenDir  = 11
+	sftpReadDir  = 12
+	sftpStat     = 17
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+	sftpReadFlag = 1
+)
+
+// SFTP attribute flags.
+const (
+	sftpAttrSize        = 0x1
+	sftpAttrUIDGID      = 0x2
+	sftpAttrPermissions = 0x4
+	sftpAttrACModTime   = 0x8
+	sftpAttrExtended    = 0x80000000
+)
+
+// sftpMaxPacket bounds the size of the packets accepted from the server.
+const sftpMaxPacket = 1 << 20
+
+// sftpChunkSize is the size of the reads requested from the server, which
+// all servers must support.
+const sftpChunkSize = 32 << 10
+
+// sftpConn is an SFTP client session. Requests are sent one at a time.
+type sftpConn struct {
+	mu sync.Mutex
+	r  io.Reader
+	w  io.Writer
+	id uint32
+}
+
+// newSFTPConn initializes an SFTP session reading the replies of the server
+// from r and writing the requests to w.
+func newSFTPConn(r io.Reader, w io.Writer) (*sftpConn, error) {
+	c := &sftpConn{r: r, w: w}
+
+	if err := c.writePacket(sftpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return nil, err
+	}
+
+	typ, payload, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion || len(payload) < 4 {
+		return nil, errors.New("SFTP: unexpected reply to the initialization")
+	}
+	if v := binary.BigEndian.Uint32(payload); v < 3 {
+		return nil, fmt.Errorf("SFTP: unsupported protocol version %d", v)
+	}
+
+	return c, nil
+}
+
+func (c *sftpConn) writePacket(typ byte, payload []byte) error {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	b = append(b, typ)
+	_, err := c.w.Write(append(b, payload...))
+	return err
+}
+
+func (c *sftpConn) readPacket() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	n := binary.BigEndian.Uint32(header[:4])
+	if n == 0 || n > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("SFTP: invalid packet length %d", n)
+	}
+
+	payload := make([]byte, n-1)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return header[4], payload, nil
+}
+
+// request sends a request and returns the type and the payload (without the
+// request ID) of the reply. A status reply is returned as an error, or as
+// io.EOF at the end of a file or directory.
+func (c *sftpConn) request(typ byte, payload []byte) (byte, *sftpDecoder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.id++
+	if err := c.writePacket(typ, append(binary.BigEndian.AppendUint32(nil, c.id), payload...)); err != nil {
+		return 0, nil, err
+	}
+
+	replyType, reply, err := c.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	d := &sftpDecoder{b: reply}
+	if id := d.uint32(); d.err != nil || id != c.id {
+		return 0, nil, errors.New("SFTP: reply to an unexpected request")
+	}
+
+	if replyType == sftpStatus {
+		return 0, nil, sftpStatusError(d)
+	}
+
+	return replyType, d, nil
+}
+
+// sftpStatusError returns the error reported by a status reply.
+func sftpStatusError(d *sftpDecoder) error {
+	code, msg := d.uint32(), d.string()
+	if d.err != nil {
+		return d.err
+	}
+
+	switch code {
+	case 0:
+		return nil
+	case 1:
+		return io.EOF
+	case 2:
+		return fs.ErrNotExist
+	case 3:
+		return fs.ErrPermission
+	}
+
+	return fmt.Errorf("SFTP: %s (status %d)", msg, code)
+}
+
+// expectSFTP returns the decoder of a reply, or an error if the request
+// failed or the reply is not of the wanted type.
+func expectSFTP(typ, want byte, d *sftpDecoder, err error) (*sftpDecoder, error) {
+	if err != nil {
+		return nil, err
+	}
+	if typ != want {
+		return nil, fmt.Errorf("SFTP: unexpected reply of type %d", typ)
+	}
+
+	return d, nil
+}
+
+func (c *sftpConn) stat(p string) (sftpFileInfo, error) {
+	typ, d, err := c.request(sftpStat, appendSFTPString(nil, p))
+	if d, err = expectSFTP(typ, sftpAttrs, d, err); err != nil {
+		return sftpFileInfo{}, err
+	}
+
+	info := d.attrs(path.Base(p))
+	return info, d.err
+}
+
+func (c *sftpConn) handle(typ byte, payload []byte) (string, error) {
+	replyType, d, err := c.request(typ, payload)
+	if d, err = expectSFTP(replyType, sftpHandle, d, err); err != nil {
+		return "", err
+	}
+
+	h := d.string()
+	return h, d.err
+}
+
+func (c *sftpConn) open(p string) (string, error) {
+	payload := appendSFTPString(nil, p)
+	payload = binary.BigEndian.AppendUint32(payload, sftpReadFlag)
+	payload = binary.BigEndian.AppendUint32(payload, 0)
+
+	return c.handle(sftpOpen, payload)
+}
+
+func (c *sftpConn) openDir(p string) (string, error) {
+	return c.handle(sftpOpenDir, appendSFTPString(nil, p))
+}
+
+func (c *sftpConn) closeHandle(h string) error {
+	_, _, err := c.request(sftpClose, appendSFTPString(nil, h))
+	return err
+}
+
+func (c *sftpConn) read(h string, off uint64, n uint32) ([]byte, error) {
+	payload := appendSFTPString(nil, h)
+	payload = binary.BigEndian.AppendUint64(payload, off)
+	payload = binary.BigEndian.AppendUint32(payload, n)
+
+	typ, d, err := c.request(sftpRead, payload)
+	if d, err = expectSFTP(typ, sftpData, d, err); err != nil {
+		return nil, err
+	}
+
+	data := d.string()
+	return []byte(data), d.err
+}
+
+func (c *sftpConn) readDir(h string) ([]sftpFileInfo, error) {
+	typ, d, err := c.request(sftpReadDir, appendSFTPString(nil, h))
+	if d, err = expectSFTP(typ, sftpName, d, err); err != nil {
+		return nil, err
+	}
+
+	n := d.uint32()
+	var infos []sftpFileInfo
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		name := d.string()
+		d.string() // long name
+		infos = append(infos, d.attrs(name))
+	}
+
+	return infos, d.err
+}
+
+func appendSFTPString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// sftpDecoder decodes the fields of an SFTP packet. The first error is
+// kept, and later fields decode to zero values.
+type sftpDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *sftpDecoder) next(n int) []byte {
+	if d.err != nil || len(d.b) < n {
+		d.err = errors.New("SFTP: truncated packet")
+		return nil
+	}
+
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *sftpDecoder) uint32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *sftpDecoder) uint64() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *sftpDecoder) string() string {
+	n := d.uint32()
+	if n > uint32(len(d.b)) {
+		d.err = errors.New("SFTP: truncated packet")
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// attrs decodes the attributes of the named file.
+func (d *sftpDecoder) attrs(name string) sftpFileInfo {
+	info := sftpFileInfo{name: name}
+
+	flags := d.uint32()
+	if flags&sftpAttrSize != 0 {
+		info.size = int64(d.uint64())
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		d.uint32()
+		d.uint32()
+	}
+	if flags&sftpAttrPermissions != 0 {
+		info.mode = sftpFileMode(d.uint32())
+	}
+	if flags&sftpAttrACModTime != 0 {
+		d.uint32() // access time
+		info.modTime = time.Unix(int64(d.uint32()), 0)
+	}
+	if flags&sftpAttrExtended != 0 {
+		for n := d.uint32(); n > 0 && d.err == nil; n-- {
+			d.string()
+			d.string()
+		}
+	}
+
+	return info
+}
+
+// sftpFileMode converts the POSIX mode reported by the server.
+func sftpFileMode(mode uint32) fs.FileMode {
+	m := fs.FileMode(mode & 0o777)
+
+	switch mode & 0o170000 {
+	case 0o040000:
+		m |= fs.ModeDir
+	case 0o120000:
+		m |= fs.ModeSymlink
+	case 0o010000:
+		m |= fs.ModeNamedPipe
+	case 0o140000:
+		m |= fs.ModeSocket
+	case 0o020000:
+		m |= fs.ModeDevice | fs.ModeCharDevice
+	case 0o060000:
+		m |= fs.ModeDevice
+	}
+
+	return m
+}
+
+// sftpFileInfo describes a file of an SFTP server.
+type sftpFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi sftpFileInfo) Name() string       { return fi.name }
+func (fi sftpFileInfo) Size() int64        { return fi.size }
+func (fi sftpFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi sftpFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi sftpFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi sftpFileInfo) Sys() interface{}   { return nil }
+
+// sftpFS is the tree rooted at root on an SFTP server.
+type sftpFS struct {
+	c     *sftpConn
+	root  string
+	close func() error
+}
+
+// newSFTPFS starts the SFTP subsystem on the SSH connection and returns the
+// tree rooted at root. Closing it closes the connection.
+func newSFTPFS(conn *ssh.Client, root string) (*sftpFS, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	r, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	c, err := newSFTPConn(r, w)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	if root == "" {
+		root = "."
+	}
+
+	return &sftpFS{c: c, root: root, close: func() error {
+		session.Close()
+		return conn.Close()
+	}}, nil
+}
+
+func (f *sftpFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return path.Join(f.root, name), nil
+}
+
+func (f *sftpFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.c.stat(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	info.name = path.Base(name)
+
+	return info, nil
+}
+
+func (f *sftpFS) Open(name string) (fs.File, error) {
+	p, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.c.stat(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	info.name = path.Base(name)
+
+	if info.IsDir() {
+		h, err := f.c.openDir(p)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &sftpDir{c: f.c, handle: h, info: info}, nil
+	}
+
+	h, err := f.c.open(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &sftpFile{c: f.c, handle: h, info: info}, nil
+}
+
+func (f *sftpFS) Close() error {
+	return f.close()
+}
+
+// sftpFile is a file of an SFTP server opened for reading.
+type sftpFile struct {
+	c      *sftpConn
+	handle string
+	info   sftpFileInfo
+	off    uint64
+}
+
+func (f *sftpFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *sftpFile) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	data, err := f.c.read(f.handle, f.off, uint32(min(len(b), sftpChunkSize)))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > len(b) {
+		return 0, errors.New("SFTP: server returned more data than requested")
+	}
+
+	// A reply without data would otherwise be retried forever; the end of
+	// the file is reported with an EOF status instead.
+	if len(data) == 0 {
+		if f.off >= uint64(f.info.size) {
+			return 0, io.EOF
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	f.off += uint64(len(data))
+	return copy(b, data), nil
+}
+
+func (f *sftpFile) Close() error {
+	return f.c.closeHandle(f.handle)
+}
+
+// sftpDir is a directory of an SFTP server opened for listing.
+type sftpDir struct {
+	c       *sftpConn
+	handle  string
+	info    sftpFileInfo
+	entries []fs.DirEntry
+	eof     bool
+}
+
+func (d *sftpDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *sftpDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *sftpDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	for !d.eof && (n <= 0 || len(d.entries) < n) {
+		infos, err := d.c.readDir(d.handle)
+		if err == io.EOF {
+			d.eof = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range infos {
+			if info.name != "." && info.name != ".." {
+				d.entries = append(d.entries, fs.FileInfoToDirEntry(info))
+			}
+		}
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	k := min(n, len(d.entries))
+	entries := d.entries[:k:k]
+	d.entries = d.entries[k:]
+
+	return entries, nil
+}
+
+func (d *sftpDir) Close() error {
+	return d.c.closeHandle(d.handle)
+}
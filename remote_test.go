@@ -0,0 +1,180 @@
+package metaextractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveSFTP serves fsys over the SFTP protocol until the client closes its
+// end, answering the requests used by sftpConn.
+func serveSFTP(t *testing.T, fsys fs.FS, r io.Reader, w io.Writer) {
+	c := &sftpConn{r: r, w: w}
+	handles := make(map[string]interface{})
+
+	status := func(id, code uint32) []byte {
+		b := binary.BigEndian.AppendUint32(nil, id)
+		b = binary.BigEndian.AppendUint32(b, code)
+		b = appendSFTPString(b, "")
+		return appendSFTPString(b, "")
+	}
+	attrs := func(b []byte, info fs.FileInfo) []byte {
+		mode := uint32(info.Mode().Perm()) | 0o100000
+		if info.IsDir() {
+			mode = uint32(info.Mode().Perm()) | 0o040000
+		}
+		b = binary.BigEndian.AppendUint32(b, sftpAttrSize|sftpAttrPermissions|sftpAttrACModTime)
+		b = binary.BigEndian.AppendUint64(b, uint64(info.Size()))
+		b = binary.BigEndian.AppendUint32(b, mode)
+		b = binary.BigEndian.AppendUint32(b, uint32(info.ModTime().Unix()))
+		return binary.BigEndian.AppendUint32(b, uint32(info.ModTime().Unix()))
+	}
+
+	for {
+		typ, payload, err := c.readPacket()
+		if err != nil {
+			return
+		}
+
+		d := &sftpDecoder{b: payload}
+		id := d.uint32()
+		if typ == sftpInit {
+			require.NoError(t, c.writePacket(sftpVersion, binary.BigEndian.AppendUint32(nil, 3)))
+			continue
+		}
+
+		reply := func(typ byte, b []byte) {
+			require.NoError(t, c.writePacket(typ, append(binary.BigEndian.AppendUint32(nil, id), b...)))
+		}
+
+		switch typ {
+		case sftpStat:
+			info, err := fs.Stat(fsys, d.string())
+			if err != nil {
+				require.NoError(t, c.writePacket(sftpStatus, status(id, 2)))
+				continue
+			}
+			reply(sftpAttrs, attrs(nil, info))
+		case sftpOpen, sftpOpenDir:
+			p := d.string()
+			h := strconv.Itoa(len(handles))
+			if typ == sftpOpen {
+				data, err := fs.ReadFile(fsys, p)
+				require.NoError(t, err)
+				handles[h] = data
+			} else {
+				entries, err := fs.ReadDir(fsys, p)
+				require.NoError(t, err)
+				handles[h] = entries
+			}
+			reply(sftpHandle, appendSFTPString(nil, h))
+		case sftpRead:
+			data := handles[d.string()].([]byte)
+			off, n := d.uint64(), d.uint32()
+			if off >= uint64(len(data)) {
+				require.NoError(t, c.writePacket(sftpStatus, status(id, 1)))
+				continue
+			}
+			// Files starting with "stall" are answered without data.
+			if bytes.HasPrefix(data, []byte("stall")) {
+				reply(sftpData, appendSFTPString(nil, ""))
+				continue
+			}
+			reply(sftpData, appendSFTPString(nil, string(data[off:min(off+uint64(n), uint64(len(data)))])))
+		case sftpReadDir:
+			h := d.string()
+			entries := handles[h].([]fs.DirEntry)
+			if len(entries) == 0 {
+				require.NoError(t, c.writePacket(sftpStatus, status(id, 1)))
+				continue
+			}
+
+			// Entries are listed one at a time, after "." and "..".
+			b := binary.BigEndian.AppendUint32(nil, 2)
+			for _, name := range []string{".", entries[0].Name()} {
+				info, err := entries[0].Info()
+				require.NoError(t, err)
+				b = appendSFTPString(b, name)
+				b = appendSFTPString(b, name)
+				b = attrs(b, info)
+			}
+			handles[h] = entries[1:]
+			reply(sftpName, b)
+		case sftpClose:
+			delete(handles, d.string())
+			require.NoError(t, c.writePacket(sftpStatus, status(id, 0)))
+		default:
+			t.Errorf("unexpected request %d", typ)
+			return
+		}
+	}
+}
+
+func TestSFTPFS(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	content := make([]byte, 3*sftpChunkSize/2)
+	for i := range content {
+		content[i] = byte(i)
+	}
+
+	remote := fstest.MapFS{
+		"home/share/a.jpg":      {Data: []byte("\xff\xd8\xff\xe0jpeg"), ModTime: modTime},
+		"home/share/docs/b.bin": {Data: content, ModTime: modTime},
+		"home/stall.bin":        {Data: []byte("stalled"), ModTime: modTime},
+	}
+
+	serverR, clientW := io.Pipe()
+	clientR, serverW := io.Pipe()
+	go serveSFTP(t, remote, serverR, serverW)
+
+	c, err := newSFTPConn(clientR, clientW)
+	require.NoError(t, err)
+	fsys := &sftpFS{c: c, root: "home", close: clientW.Close}
+	defer fsys.Close()
+
+	t.Run("Read", func(t *testing.T) {
+		data, err := fs.ReadFile(fsys, "share/docs/b.bin")
+		require.NoError(t, err)
+		assert.Equal(t, content, data)
+
+		info, err := fs.Stat(fsys, "share/a.jpg")
+		require.NoError(t, err)
+		assert.Equal(t, "a.jpg", info.Name())
+		assert.Equal(t, int64(8), info.Size())
+		assert.True(t, modTime.Equal(info.ModTime()))
+
+		_, err = fsys.Open("share/missing")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+
+		_, err = fsys.Open("../etc")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+
+		_, err = fs.ReadFile(fsys, "stall.bin")
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("ExtractFS", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+
+		results, err := me.ExtractFS(fsys, "share", WalkOptions{})
+		require.NoError(t, err)
+
+		var paths []string
+		for _, r := range results {
+			require.NoError(t, r.Err)
+			paths = append(paths, r.Path)
+		}
+		assert.Equal(t, []string{"share/a.jpg", "share/docs/b.bin"}, paths)
+		assert.Equal(t, int64(len(content)), results[1].Metadata.Size)
+		assert.Equal(t, path.Base(results[1].Path), results[1].Metadata.Name)
+	})
+}
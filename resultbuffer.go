@@ -0,0 +1,219 @@
+package metaextractor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// resultSpool is a FIFO queue of results keeping up to limit results in
+// memory and spilling the others to a temporary file in dir as JSON lines.
+// Spilled results are decoded from their JSON encoding (see
+// Result.MarshalJSON), so their errors only keep their message.
+type resultSpool struct {
+	dir   string
+	limit int
+
+	// mem holds the results preceding the unread spilled results.
+	mem []Result
+
+	w       *os.File
+	r       *os.File
+	enc     *json.Encoder
+	br      *bufio.Reader
+	spilled int
+	read    int
+}
+
+// len returns the number of queued results.
+func (s *resultSpool) len() int {
+	return len(s.mem) + s.spilled - s.read
+}
+
+// push appends the result to the queue. The result is kept in memory if the
+// file cannot be written.
+func (s *resultSpool) push(r Result) error {
+	if s.spilled == s.read && len(s.mem) < s.limit {
+		s.mem = append(s.mem, r)
+		return nil
+	}
+
+	if err := s.spill(r); err != nil {
+		s.mem = append(s.mem, r)
+		return err
+	}
+
+	return nil
+}
+
+// spill writes the result to the file.
+func (s *resultSpool) spill(r Result) error {
+	if s.w == nil {
+		w, err := os.CreateTemp(s.dir, "metaextractor-results-*.jsonl")
+		if err != nil {
+			return err
+		}
+
+		r, err := os.Open(w.Name())
+		if err != nil {
+			w.Close()
+			os.Remove(w.Name())
+			return err
+		}
+
+		s.w, s.r = w, r
+		s.enc, s.br = json.NewEncoder(w), bufio.NewReader(r)
+	}
+
+	if err := s.enc.Encode(r); err != nil {
+		return err
+	}
+	s.spilled++
+
+	return nil
+}
+
+// peek returns the first queued result. The queue must not be empty.
+func (s *resultSpool) peek() (Result, error) {
+	if len(s.mem) == 0 {
+		r, err := s.unspill()
+		if err != nil {
+			return Result{}, err
+		}
+		s.mem = append(s.mem, r)
+	}
+
+	return s.mem[0], nil
+}
+
+// pop removes the first queued result.
+func (s *resultSpool) pop() {
+	s.mem[0] = Result{}
+	s.mem = s.mem[1:]
+}
+
+// unspill reads the next spilled result. A result that cannot be decoded is
+// replaced by a result with the error, keeping its path if it can be read.
+// Once all spilled results are read, the file is truncated.
+func (s *resultSpool) unspill() (Result, error) {
+	line, err := s.br.ReadBytes('\n')
+	if err != nil {
+		return Result{}, fmt.Errorf("error reading spilled result: %w", err)
+	}
+	s.read++
+
+	var r Result
+	if err := json.Unmarshal(line, &r); err != nil {
+		var rec struct {
+			Path string `json:"path"`
+		}
+		_ = json.Unmarshal(line, &rec)
+		r = Result{Path: rec.Path, Err: fmt.Errorf("error decoding spilled result: %w", err)}
+	}
+
+	if s.read == s.spilled {
+		if err := s.reset(); err != nil {
+			return Result{}, err
+		}
+	}
+
+	return r, nil
+}
+
+// reset truncates the file.
+func (s *resultSpool) reset() error {
+	s.spilled, s.read = 0, 0
+
+	if err := s.w.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := s.r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.br.Reset(s.r)
+
+	return nil
+}
+
+// discard drops the unread spilled results.
+func (s *resultSpool) discard() {
+	s.read = s.spilled
+	_ = s.reset()
+}
+
+// close removes the file.
+func (s *resultSpool) close() {
+	if s.w == nil {
+		return
+	}
+
+	s.r.Close()
+	s.w.Close()
+	os.Remove(s.w.Name())
+}
+
+// bufferResults returns a channel receiving the results received from in,
+// in order. The results the consumer is not ready for are buffered: up to
+// Options.ResultBuffer in memory, and the others spilled to
+// Options.SpillDir, so that the extraction never waits for the consumer. A
+// result that cannot be decoded is replaced by a result with the error; if
+// the spilled results cannot be read at all, they are replaced by one.
+// The channel is closed once in is closed and the buffer is drained, or
+// when ctx is done.
+func (me *MetaExtractor) bufferResults(ctx context.Context, in <-chan Result) <-chan Result {
+	out := make(chan Result)
+
+	go func() {
+		defer close(out)
+
+		spool := &resultSpool{dir: me.spillDir, limit: max(me.resultBuffer, 1)}
+		defer spool.close()
+
+		// readErr is the error reported in place of the spilled results
+		// that could not be read back.
+		var readErr error
+
+		for in != nil || spool.len() > 0 || readErr != nil {
+			var send chan<- Result
+			var next Result
+			switch {
+			case readErr != nil:
+				send, next = out, Result{Err: readErr}
+			case spool.len() > 0:
+				r, err := spool.peek()
+				if err != nil {
+					spool.discard()
+					readErr = err
+					continue
+				}
+				send, next = out, r
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case r, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				// If the result cannot be spilled, it is kept in memory.
+				_ = spool.push(r)
+			case send <- next:
+				if readErr != nil {
+					readErr = nil
+				} else {
+					spool.pop()
+				}
+			}
+		}
+	}()
+
+	return out
+}
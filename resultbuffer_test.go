@@ -0,0 +1,153 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultSpool(t *testing.T) {
+	dir := t.TempDir()
+	spool := &resultSpool{dir: dir, limit: 2}
+	defer spool.close()
+
+	result := func(i int) Result {
+		r := Result{Path: fmt.Sprintf("file-%d", i), Depth: i, Metadata: Metadata{Name: fmt.Sprintf("file-%d", i), Size: int64(i)}}
+		if i%3 == 0 {
+			r.Err = errors.New("permission denied")
+		}
+		return r
+	}
+
+	// Results are read back in order, across two rounds of spilling.
+	next := 0
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 5; i++ {
+			require.NoError(t, spool.push(result(next+i)))
+		}
+		assert.Equal(t, 5, spool.len())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		for i := 0; i < 5; i++ {
+			r, err := spool.peek()
+			require.NoError(t, err)
+			spool.pop()
+
+			want := result(next + i)
+			assert.Equal(t, want.Path, r.Path)
+			assert.Equal(t, want.Depth, r.Depth)
+			assert.Equal(t, want.Metadata, r.Metadata)
+			if want.Err != nil {
+				assert.EqualError(t, r.Err, want.Err.Error())
+			} else {
+				assert.NoError(t, r.Err)
+			}
+		}
+		assert.Equal(t, 0, spool.len())
+		next += 5
+	}
+
+	// The file is truncated once drained, and removed on close.
+	info, err := os.Stat(spool.w.Name())
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	t.Run("Decoding Error", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			require.NoError(t, spool.push(result(i)))
+		}
+
+		// The third result (the first spilled one) is corrupted.
+		data, err := os.ReadFile(spool.w.Name())
+		require.NoError(t, err)
+		data = bytes.Replace(data, []byte(`"depth":2`), []byte(`"depth":"2"`), 1)
+		require.NoError(t, os.WriteFile(spool.w.Name(), data, 0o600))
+
+		for i := 0; i < 5; i++ {
+			r, err := spool.peek()
+			require.NoError(t, err)
+			spool.pop()
+
+			assert.Equal(t, result(i).Path, r.Path)
+			if i == 2 {
+				assert.ErrorContains(t, r.Err, "error decoding spilled result")
+			} else {
+				assert.Equal(t, i, r.Depth)
+			}
+		}
+		assert.Equal(t, 0, spool.len())
+	})
+
+	spool.close()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestExtractStream_ResultBuffer(t *testing.T) {
+	paths := budgetFiles(t, "1.txt", "2.txt", "3.txt", "4.txt", "5.txt", "6.txt", "7.txt", "8.txt")
+
+	// extracting returns an extractor counting the extracted files.
+	extracting := func(opts Options) (*MetaExtractor, *atomic.Int32) {
+		var n atomic.Int32
+		opts.PureGo = true
+		opts.Backends = []Extractor{TridBackend, BackendFunc{
+			BackendName: "count",
+			Fn: func(ctx context.Context, filePath string, metadata *Metadata) error {
+				n.Add(1)
+				return nil
+			},
+		}}
+		return NewMetaExtractor(opts), &n
+	}
+
+	stream := func(me *MetaExtractor) <-chan Result {
+		in := make(chan string, len(paths))
+		for _, p := range paths {
+			in <- p
+		}
+		close(in)
+		return me.ExtractStream(context.Background(), in)
+	}
+
+	drain := func(t *testing.T, results <-chan Result) {
+		var got []string
+		for r := range results {
+			require.NoError(t, r.Err)
+			got = append(got, r.Path)
+		}
+		assert.Equal(t, paths, got)
+	}
+
+	t.Run("Backpressure", func(t *testing.T) {
+		me, n := extracting(Options{ResultBuffer: 2})
+
+		results := stream(me)
+		assert.Never(t, func() bool { return n.Load() == int32(len(paths)) }, 100*time.Millisecond, 5*time.Millisecond)
+		drain(t, results)
+	})
+
+	t.Run("Spill", func(t *testing.T) {
+		dir := t.TempDir()
+		me, n := extracting(Options{ResultBuffer: 2, SpillDir: dir})
+
+		results := stream(me)
+		assert.Eventually(t, func() bool { return n.Load() == int32(len(paths)) }, 5*time.Second, 5*time.Millisecond)
+		drain(t, results)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
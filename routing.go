@@ -0,0 +1,160 @@
+package metaextractor
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/attilabuti/trid"
+)
+
+// Stage is an additional extraction step that runs after the core pipeline
+// (file system information, TrID analysis and EXIF extraction) has completed.
+type Stage interface {
+	// Name returns the unique name of the stage. Stage results are stored
+	// in Metadata.Extra under this name.
+	Name() string
+
+	// Run performs the stage on the given file. The metadata gathered so far
+	// is available for inspection. The returned value is stored in
+	// Metadata.Extra. It should return ctx.Err() when ctx is done.
+	Run(ctx context.Context, filePath string, metadata *Metadata) (interface{}, error)
+}
+
+// StageFunc adapts an ordinary function to the Stage interface.
+type StageFunc struct {
+	// StageName is the name returned by Name.
+	StageName string
+
+	// Fn is the function invoked by Run.
+	Fn func(ctx context.Context, filePath string, metadata *Metadata) (interface{}, error)
+}
+
+// Name returns the name of the stage.
+func (sf StageFunc) Name() string {
+	return sf.StageName
+}
+
+// Run calls sf.Fn(ctx, filePath, metadata).
+func (sf StageFunc) Run(ctx context.Context, filePath string, metadata *Metadata) (interface{}, error) {
+	return sf.Fn(ctx, filePath, metadata)
+}
+
+// Route maps detected file types to the additional stages that should run for
+// them. A route applies to a file when any of its MIME types or extensions
+// match, or when Match returns true.
+type Route struct {
+	// MimeTypes is a list of MIME type patterns (e.g., "image/*", "application/pdf").
+	// Patterns use path.Match syntax.
+	MimeTypes []string
+
+	// Extensions is a list of detected extension patterns (e.g., ".exe", ".doc*").
+	// Patterns use path.Match syntax.
+	Extensions []string
+
+	// Match is an optional custom predicate evaluated against the metadata.
+	Match func(Metadata) bool
+
+	// Stages are the stages to run when the route applies.
+	Stages []Stage
+}
+
+// matches reports whether the route applies to the given metadata.
+func (r Route) matches(metadata Metadata) bool {
+	if mimeType := detectedMimeType(metadata); mimeType != "" {
+		for _, pattern := range r.MimeTypes {
+			if ok, _ := path.Match(strings.ToLower(pattern), mimeType); ok {
+				return true
+			}
+		}
+	}
+
+	for _, ext := range detectedExtensions(metadata) {
+		for _, pattern := range r.Extensions {
+			if ok, _ := path.Match(strings.ToLower(pattern), ext); ok {
+				return true
+			}
+		}
+	}
+
+	return r.Match != nil && r.Match(metadata)
+}
+
+// runRoutes executes the stages of every route that applies to the file,
+// recording them in trace. Each stage runs at most once, even if several
+// routes select it.
+func (me *MetaExtractor) runRoutes(ctx context.Context, filePath string, metadata *Metadata, trace *stageTrace) error {
+	done := make(map[string]bool)
+
+	for _, route := range me.routes {
+		if !route.matches(*metadata) {
+			continue
+		}
+
+		for _, stage := range route.Stages {
+			name := stage.Name()
+			if done[name] {
+				continue
+			}
+			done[name] = true
+
+			start := time.Now()
+			result, err := stage.Run(ctx, filePath, metadata)
+			trace.done(name, start)
+
+			if err != nil {
+				if err := me.stageError(metadata, trace, fmt.Errorf("error running stage %s: %w", name, err)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if metadata.Extra == nil {
+				metadata.Extra = make(map[string]interface{})
+			}
+			metadata.Extra[name] = result
+			metadata.setSource("Extra."+name, name)
+		}
+	}
+
+	return nil
+}
+
+// detectedMimeType returns the MIME type of the most likely file type, falling
+// back to the MIME type reported by ExifTool.
+func detectedMimeType(metadata Metadata) string {
+	if len(metadata.Types) > 0 && metadata.Types[0].MimeType != "" {
+		return strings.ToLower(metadata.Types[0].MimeType)
+	}
+
+	if mimeType, ok := exifValue(metadata.Exif, "MIMEType").(string); ok {
+		return strings.ToLower(mimeType)
+	}
+
+	return ""
+}
+
+// detectedExtensions returns the extensions of the most likely file type.
+// TrID reports alternatives as ".jpg/.jpeg", which are split into separate values.
+func detectedExtensions(metadata Metadata) []string {
+	if len(metadata.Types) == 0 {
+		return nil
+	}
+
+	return typeExtensions(metadata.Types[0])
+}
+
+// typeExtensions returns the extensions of the file type, split into
+// alternatives (e.g., ".jpg/.jpeg" becomes [".jpg", ".jpeg"]).
+func typeExtensions(fileType trid.FileType) []string {
+	var exts []string
+	for _, e := range strings.Split(fileType.Extension, "/") {
+		if e = strings.TrimPrefix(strings.TrimSpace(e), "."); e != "" {
+			exts = append(exts, "."+strings.ToLower(e))
+		}
+	}
+
+	return exts
+}
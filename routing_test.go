@@ -0,0 +1,80 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteMatches(t *testing.T) {
+	image := Metadata{Types: []trid.FileType{{Extension: ".jpg/.jpeg", MimeType: "image/jpeg"}}}
+	pdf := Metadata{Exif: ExifMetadata{"MIMEType": "application/pdf"}}
+
+	testCases := []struct {
+		name     string
+		route    Route
+		metadata Metadata
+		expected bool
+	}{
+		{"MIME Wildcard", Route{MimeTypes: []string{"image/*"}}, image, true},
+		{"MIME Mismatch", Route{MimeTypes: []string{"video/*"}}, image, false},
+		{"Extension Alternative", Route{Extensions: []string{".jpeg"}}, image, true},
+		{"Exif MIME Fallback", Route{MimeTypes: []string{"application/pdf"}}, pdf, true},
+		{"Custom Match", Route{Match: func(m Metadata) bool { return len(m.Types) == 0 }}, pdf, true},
+		{"No Criteria", Route{}, image, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.route.matches(tc.metadata))
+		})
+	}
+}
+
+func TestRunRoutes(t *testing.T) {
+	calls := 0
+	thumbnail := StageFunc{
+		StageName: "thumbnail",
+		Fn: func(ctx context.Context, filePath string, metadata *Metadata) (interface{}, error) {
+			calls++
+			return filePath + ".thumb", nil
+		},
+	}
+
+	me := NewMetaExtractor(Options{
+		Routes: []Route{
+			{MimeTypes: []string{"image/*"}, Stages: []Stage{thumbnail}},
+			{Extensions: []string{".jpg"}, Stages: []Stage{thumbnail}},
+		},
+	})
+
+	metadata := Metadata{Types: []trid.FileType{{Extension: ".jpg", MimeType: "image/jpeg"}}}
+	require.NoError(t, me.runRoutes(context.Background(), "photo.jpg", &metadata, nil))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "photo.jpg.thumb", metadata.Extra["thumbnail"])
+
+	failing := StageFunc{
+		StageName: "failing",
+		Fn: func(context.Context, string, *Metadata) (interface{}, error) {
+			return nil, errors.New("boom")
+		},
+	}
+	me = NewMetaExtractor(Options{Routes: []Route{{MimeTypes: []string{"*/*"}, Stages: []Stage{failing}}}})
+	assert.Error(t, me.runRoutes(context.Background(), "photo.jpg", &metadata, nil))
+
+	// The stage receives the context of the extraction.
+	canceled := StageFunc{
+		StageName: "canceled",
+		Fn: func(ctx context.Context, _ string, _ *Metadata) (interface{}, error) {
+			return nil, ctx.Err()
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	me = NewMetaExtractor(Options{Routes: []Route{{MimeTypes: []string{"*/*"}, Stages: []Stage{canceled}}}})
+	assert.ErrorIs(t, me.runRoutes(ctx, "photo.jpg", &metadata, nil), context.Canceled)
+}
@@ -0,0 +1,73 @@
+package metaextractor
+
+import "fmt"
+
+// Rule maps a condition over Metadata to a label and an optional action.
+// Rules are evaluated during extraction, after all other stages have run.
+type Rule struct {
+	// Label is attached to Metadata.Labels when the condition matches.
+	Label string
+
+	// Condition is an expression evaluated against the metadata
+	// (e.g., `Size > 1GB && Types[0].Extension == ".mov"`). See Expr for the syntax.
+	Condition string
+
+	// Action is an optional function invoked when the condition matches.
+	Action func(filePath string, metadata *Metadata) error
+}
+
+// compiledRule is a Rule with its condition parsed.
+type compiledRule struct {
+	Rule
+	expr *Expr
+}
+
+// compileRules parses the conditions of the given rules.
+func compileRules(rules []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		expr, err := CompileExpr(rule.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rule.Label, err)
+		}
+		compiled = append(compiled, compiledRule{Rule: rule, expr: expr})
+	}
+
+	return compiled, nil
+}
+
+// applyRules evaluates the configured rules, attaching labels and running the
+// actions of matching rules.
+func (me *MetaExtractor) applyRules(filePath string, metadata *Metadata) error {
+	if me.rulesErr != nil {
+		return me.rulesErr
+	}
+
+	for _, rule := range me.rules {
+		ok, err := rule.expr.Eval(*metadata)
+		if err != nil {
+			if err := me.stageError(metadata, nil, fmt.Errorf("error evaluating rule %q: %w", rule.Label, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		if rule.Label != "" {
+			metadata.Labels = append(metadata.Labels, rule.Label)
+		}
+
+		if rule.Action != nil {
+			if err := rule.Action(filePath, metadata); err != nil {
+				if err := me.stageError(metadata, nil, fmt.Errorf("error running action of rule %q: %w", rule.Label, err)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
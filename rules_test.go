@@ -0,0 +1,53 @@
+package metaextractor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRules(t *testing.T) {
+	var actionCalled bool
+
+	me := NewMetaExtractor(Options{
+		Rules: []Rule{
+			{Label: "large", Condition: `Size > 1MB`},
+			{Label: "small", Condition: `Size <= 1MB`},
+			{
+				Label:     "mismatch",
+				Condition: `ExtMismatch`,
+				Action: func(filePath string, metadata *Metadata) error {
+					actionCalled = true
+					return nil
+				},
+			},
+		},
+	})
+
+	metadata := Metadata{Size: 5 << 20, ExtMismatch: true}
+	require.NoError(t, me.applyRules("file", &metadata))
+	assert.Equal(t, []string{"large", "mismatch"}, metadata.Labels)
+	assert.True(t, actionCalled)
+}
+
+func TestApplyRules_Errors(t *testing.T) {
+	t.Run("Invalid Condition", func(t *testing.T) {
+		me := NewMetaExtractor(Options{Rules: []Rule{{Label: "broken", Condition: `Size >`}}})
+		assert.Error(t, me.applyRules("file", &Metadata{}))
+	})
+
+	t.Run("Failing Action", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			Rules: []Rule{{
+				Label:     "always",
+				Condition: `true`,
+				Action: func(string, *Metadata) error {
+					return errors.New("boom")
+				},
+			}},
+		})
+		assert.Error(t, me.applyRules("file", &Metadata{}))
+	})
+}
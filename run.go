@@ -0,0 +1,49 @@
+package metaextractor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// NewRunID returns a new random identifier in the UUID version 4 format,
+// suitable for Options.RunID.
+func NewRunID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("metaextractor: error generating ID: %v", err))
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// stampResults sets the correlation fields of the results of a batch.
+func (me *MetaExtractor) stampResults(results []Result) {
+	stamp := me.newStamp()
+	for i := range results {
+		stamp(&results[i])
+	}
+}
+
+// newStamp returns a function setting the correlation fields of the results
+// of a batch. All results share the run ID, which is Options.RunID or a new
+// ID per batch; every result gets its own record ID.
+func (me *MetaExtractor) newStamp() func(*Result) {
+	runID := me.runID
+	if runID == "" {
+		runID = NewRunID()
+	}
+
+	host, _ := os.Hostname()
+
+	return func(r *Result) {
+		r.RunID = runID
+		r.RecordID = NewRunID()
+		r.Host = host
+	}
+}
@@ -0,0 +1,47 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRunID(t *testing.T) {
+	id := NewRunID()
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+	assert.NotEqual(t, id, NewRunID())
+}
+
+func TestStampResults(t *testing.T) {
+	paths := []string{filepath.Join("testdata", "sample.doc"), "nonexistent_file"}
+
+	t.Run("Generated", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true})
+
+		first := me.ExtractBatch(paths)
+		second := me.ExtractBatch(paths)
+		require.Len(t, first, 2)
+		require.Len(t, second, 2)
+
+		assert.NotEmpty(t, first[0].RunID)
+		assert.Equal(t, first[0].RunID, first[1].RunID)
+		assert.NotEqual(t, first[0].RunID, second[0].RunID)
+		assert.NotEqual(t, first[0].RecordID, first[1].RecordID)
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, RunID: "nightly-42"})
+
+		results, err := me.ExtractDir("testdata", WalkOptions{})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+
+		for _, r := range results {
+			assert.Equal(t, "nightly-42", r.RunID)
+			assert.NotEmpty(t, r.RecordID)
+		}
+	})
+}
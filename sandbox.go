@@ -0,0 +1,214 @@
+package metaextractor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+)
+
+// ErrSandboxUnsupported is returned when the sandbox is enabled on a platform
+// that does not support it.
+var ErrSandboxUnsupported = errors.New("sandbox is not supported on this platform")
+
+// SandboxWrapper selects an external tool used to isolate TrID and ExifTool.
+type SandboxWrapper string
+
+const (
+	// SandboxNone runs the tools without an isolation wrapper. The file is
+	// still copied and the environment is still cleared.
+	SandboxNone SandboxWrapper = ""
+
+	// SandboxBubblewrap runs the tools using bubblewrap (bwrap) with all
+	// namespaces (including the network) unshared. Only the system
+	// directories holding the libraries and data of the tools, the
+	// directory of each tool, its data files and the sandbox directory are
+	// visible, read-only; /tmp is a private, writable tmpfs.
+	SandboxBubblewrap SandboxWrapper = "bwrap"
+
+	// SandboxFirejail runs the tools using firejail without network access,
+	// capabilities or new privileges.
+	SandboxFirejail SandboxWrapper = "firejail"
+)
+
+// SandboxOptions configures the restricted environment TrID and ExifTool are
+// run in. When enabled, each file is copied into a private working directory
+// before analysis, and the tools are started with a minimal environment.
+type SandboxOptions struct {
+	// Enabled turns the sandbox on.
+	Enabled bool
+
+	// Dir is the directory the files are copied into. Defaults to a
+	// "metaextractor-sandbox" directory in the system temporary directory.
+	Dir string
+
+	// Wrapper is an optional isolation tool (bubblewrap or firejail) used
+	// to deny network access and restrict the file system.
+	Wrapper SandboxWrapper
+}
+
+// sandboxEnv is the environment the sandboxed tools are started with.
+var sandboxEnv = []string{
+	"PATH=/usr/local/bin:/usr/bin:/bin",
+	"LANG=C.UTF-8",
+}
+
+// sandboxSystemPaths are the system directories and files holding the shared
+// libraries, interpreters (e.g., Perl for ExifTool) and data of the tools,
+// bound read-only into the bubblewrap sandbox if they exist.
+var sandboxSystemPaths = []string{
+	"/usr",
+	"/bin",
+	"/sbin",
+	"/lib",
+	"/lib32",
+	"/lib64",
+	"/etc/alternatives",
+	"/etc/ld.so.cache",
+}
+
+// prepareSandbox creates the sandbox directory and returns its absolute path.
+func prepareSandbox(opts SandboxOptions) (string, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "metaextractor-sandbox")
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// sandboxCommand returns the command line prefix that starts a tool inside
+// the sandbox rooted at dir. With bubblewrap, the tool must be bound into the
+// sandbox with sandboxTool.
+func sandboxCommand(opts SandboxOptions, dir string) ([]string, error) {
+	env, err := exec.LookPath("env")
+	if err != nil {
+		return nil, err
+	}
+
+	// The sandbox directory is read-only inside bubblewrap, where the tools
+	// get a private /tmp instead.
+	tmpDir := dir
+	if opts.Wrapper == SandboxBubblewrap {
+		tmpDir = "/tmp"
+	}
+
+	args := append([]string{env, "-i", "TMPDIR=" + tmpDir}, sandboxEnv...)
+
+	switch opts.Wrapper {
+	case SandboxNone:
+	case SandboxBubblewrap:
+		bwrap, err := exec.LookPath(string(opts.Wrapper))
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, bwrap)
+		for _, p := range sandboxSystemPaths {
+			args = append(args, "--ro-bind-try", p, p)
+		}
+		args = append(args,
+			"--dev", "/dev",
+			"--proc", "/proc",
+			"--tmpfs", "/tmp",
+			"--ro-bind", dir, dir,
+			"--unshare-all",
+			"--die-with-parent",
+			"--new-session",
+			"--",
+		)
+	case SandboxFirejail:
+		firejail, err := exec.LookPath(string(opts.Wrapper))
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, firejail,
+			"--quiet",
+			"--noprofile",
+			"--net=none",
+			"--nonewprivs",
+			"--caps.drop=all",
+			"--seccomp",
+			"--private-dev",
+			"--",
+		)
+	default:
+		return nil, fmt.Errorf("unknown sandbox wrapper: %s", opts.Wrapper)
+	}
+
+	return args, nil
+}
+
+// sandboxTool returns the sandbox command prefix for the given tool. With
+// bubblewrap, the directory of the tool (and of its target, if it is a
+// symbolic link) and its data files, such as the TrID definitions, are bound
+// read-only into the sandbox, so that tools installed outside the system
+// directories find their libraries and data.
+func sandboxTool(prefix []string, opts SandboxOptions, tool string, data ...string) []string {
+	if opts.Wrapper != SandboxBubblewrap || len(prefix) == 0 {
+		return prefix
+	}
+
+	var paths []string
+	if path, err := exec.LookPath(tool); err == nil {
+		paths = append(paths, filepath.Dir(path))
+		if target, err := filepath.EvalSymlinks(path); err == nil {
+			paths = append(paths, filepath.Dir(target))
+		}
+	}
+	paths = append(paths, data...)
+
+	var binds []string
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			binds = append(binds, "--ro-bind-try", abs, abs)
+		}
+	}
+
+	// The binds go before the "--" ending the options of bubblewrap.
+	n := len(prefix) - 1
+	return slices.Concat(prefix[:n], binds, prefix[n:])
+}
+
+// sandboxFile copies the file into a fresh directory inside the sandbox and
+// returns the path of the copy, along with a function removing it. Without
+// a sandbox, the original path is returned.
+func (me *MetaExtractor) sandboxFile(filePath string) (string, func(), error) {
+	if me.sandboxDir == "" {
+		return filePath, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp(me.sandboxDir, "job-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() { os.RemoveAll(dir) }
+
+	dst := filepath.Join(dir, filepath.Base(filePath))
+	if err := copyFile(filePath, dst); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	if info, err := os.Stat(filePath); err == nil {
+		os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+
+	return dst, cleanup, nil
+}
@@ -0,0 +1,100 @@
+package metaextractor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxFile(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "sample.txt")
+	require.NoError(t, os.WriteFile(src, []byte("hello"), 0o644))
+
+	t.Run("Disabled", func(t *testing.T) {
+		me := NewMetaExtractor(Options{})
+
+		path, cleanup, err := me.sandboxFile(src)
+		require.NoError(t, err)
+		defer cleanup()
+		assert.Equal(t, src, path)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		dir := t.TempDir()
+		me := &MetaExtractor{sandboxDir: dir}
+
+		path, cleanup, err := me.sandboxFile(src)
+		require.NoError(t, err)
+		assert.NotEqual(t, src, path)
+		assert.Equal(t, "sample.txt", filepath.Base(path))
+		assert.True(t, strings.HasPrefix(path, dir))
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+
+		cleanup()
+		assert.NoDirExists(t, filepath.Dir(path))
+	})
+}
+
+func TestSandboxCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sandbox is not supported on windows")
+	}
+
+	dir := t.TempDir()
+
+	prefix, err := sandboxCommand(SandboxOptions{}, dir)
+	require.NoError(t, err)
+	assert.Contains(t, prefix, "-i")
+	assert.Contains(t, prefix, "TMPDIR="+dir)
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("SANDBOX_SECRET", "secret")
+
+	wrapper, err := wrapCommand("sh", Limits{}, prefix)
+	require.NoError(t, err)
+
+	out, err := exec.Command(wrapper, "-c", "env").Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "SANDBOX_SECRET")
+	assert.Contains(t, string(out), "TMPDIR="+dir)
+
+	_, err = sandboxCommand(SandboxOptions{Wrapper: "chroot-jail"}, dir)
+	assert.ErrorContains(t, err, "unknown sandbox wrapper")
+
+	t.Run("Bubblewrap", func(t *testing.T) {
+		toolDir, err := filepath.EvalSymlinks(t.TempDir())
+		require.NoError(t, err)
+		tool := filepath.Join(toolDir, "trid")
+		require.NoError(t, os.WriteFile(tool, []byte("#!/bin/sh\n"), 0o755))
+		defs := filepath.Join(t.TempDir(), "triddefs.trd")
+
+		opts := SandboxOptions{Wrapper: SandboxBubblewrap}
+		prefix := []string{"env", "bwrap", "--ro-bind", dir, dir, "--"}
+		assert.Equal(t, []string{
+			"env", "bwrap", "--ro-bind", dir, dir,
+			"--ro-bind-try", toolDir, toolDir,
+			"--ro-bind-try", toolDir, toolDir,
+			"--ro-bind-try", defs, defs,
+			"--",
+		}, sandboxTool(prefix, opts, tool, defs))
+		assert.Equal(t, prefix, sandboxTool(prefix, SandboxOptions{}, tool), "only bubblewrap binds the tools")
+
+		if _, err := exec.LookPath("bwrap"); err != nil {
+			t.Skip("bwrap is not installed")
+		}
+
+		prefix, err = sandboxCommand(opts, dir)
+		require.NoError(t, err)
+		assert.Contains(t, prefix, "TMPDIR=/tmp")
+		assert.NotContains(t, prefix, "/", "the root file system is not bound")
+	})
+}
@@ -0,0 +1,206 @@
+package metaextractor
+
+import (
+	"hash"
+	"io"
+	"math"
+	"os"
+)
+
+// DefaultReadBufferSize is the default size of the buffer used to read files.
+const DefaultReadBufferSize = 64 << 10
+
+// scanOptions selects what is computed while scanning a file.
+type scanOptions struct {
+	hashes      []string
+	entropy     bool
+	sketch      bool
+	headSize    int
+	tailSize    int
+	bufferSize  int
+	chunkSize   int
+	hashWorkers int
+}
+
+// fullRead reports whether the whole file has to be read, rather than only
+// its head.
+func (o scanOptions) fullRead() bool {
+	return len(o.hashes) > 0 || o.entropy || o.sketch || o.tailSize > 0
+}
+
+// contentScan is the result of scanning a file.
+type contentScan struct {
+	hashes  map[string]string
+	entropy float64
+	sketch  *Sketch
+	head    []byte
+	tail    []byte
+
+	// size is the number of bytes read.
+	size int64
+}
+
+// scanFile reads the file once, fanning the content out to the hashes, the
+// entropy counter, the similarity sketch and the head and tail samplers, so
+// that no stage has to re-open and re-read the file. Only the head is read if
+// nothing else is needed.
+func scanFile(filePath string, opts scanOptions) (contentScan, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return contentScan{}, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if !opts.fullRead() {
+		r = io.LimitReader(f, int64(opts.headSize))
+	}
+
+	return scanReader(r, opts)
+}
+
+// scanReader reads r to the end like scanFile.
+func scanReader(r io.Reader, opts scanOptions) (contentScan, error) {
+	var scan contentScan
+
+	head := &headWriter{size: opts.headSize, buf: make([]byte, 0, opts.headSize)}
+	writers := []io.Writer{head}
+
+	hashes := make(map[string]hash.Hash, len(opts.hashes))
+	for _, name := range opts.hashes {
+		h := newHash(name, opts.chunkSize, opts.hashWorkers)
+		hashes[name] = h
+		writers = append(writers, h)
+	}
+
+	var counter *byteCounter
+	if opts.entropy {
+		counter = &byteCounter{}
+		writers = append(writers, counter)
+	}
+
+	var sketch *sketchWriter
+	if opts.sketch {
+		sketch = newSketchWriter()
+		writers = append(writers, sketch)
+	}
+
+	var tail *tailWriter
+	if opts.tailSize > 0 {
+		tail = &tailWriter{buf: make([]byte, opts.tailSize)}
+		writers = append(writers, tail)
+	}
+
+	bufferSize := opts.bufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultReadBufferSize
+	}
+
+	n, err := io.CopyBuffer(io.MultiWriter(writers...), r, make([]byte, bufferSize))
+	if err != nil {
+		return scan, err
+	}
+
+	scan.size = n
+
+	scan.head = head.buf
+
+	if len(hashes) > 0 {
+		scan.hashes = make(map[string]string, len(hashes))
+		for name, h := range hashes {
+			scan.hashes[name] = hashDigest(name, h)
+		}
+	}
+
+	if counter != nil {
+		scan.entropy = counter.entropy()
+	}
+
+	if sketch != nil {
+		scan.sketch = sketch.sketch()
+	}
+
+	if tail != nil {
+		scan.tail = tail.bytes()
+	}
+
+	return scan, nil
+}
+
+// headWriter keeps the first size bytes written to it.
+type headWriter struct {
+	size int
+	buf  []byte
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if n := w.size - len(w.buf); n > 0 {
+		w.buf = append(w.buf, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// tailWriter keeps the last len(buf) bytes written to it in a ring buffer.
+type tailWriter struct {
+	buf   []byte
+	pos   int
+	total int64
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	w.total += int64(n)
+
+	if len(p) > len(w.buf) {
+		p = p[len(p)-len(w.buf):]
+	}
+
+	for len(p) > 0 {
+		c := copy(w.buf[w.pos:], p)
+		w.pos = (w.pos + c) % len(w.buf)
+		p = p[c:]
+	}
+
+	return n, nil
+}
+
+// bytes returns the kept bytes in order.
+func (w *tailWriter) bytes() []byte {
+	if w.total < int64(len(w.buf)) {
+		return append([]byte(nil), w.buf[:w.total]...)
+	}
+	return append(append([]byte(nil), w.buf[w.pos:]...), w.buf[:w.pos]...)
+}
+
+// byteCounter counts the occurrences of each byte value.
+type byteCounter struct {
+	counts [256]int64
+	total  int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		c.counts[b]++
+	}
+	c.total += int64(len(p))
+	return len(p), nil
+}
+
+// entropy returns the Shannon entropy of the counted bytes in bits per byte,
+// ranging from 0 (constant content) to 8 (random or encrypted content).
+func (c *byteCounter) entropy() float64 {
+	if c.total == 0 {
+		return 0
+	}
+
+	var e float64
+	for _, n := range c.counts {
+		if n == 0 {
+			continue
+		}
+		p := float64(n) / float64(c.total)
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
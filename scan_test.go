@@ -0,0 +1,83 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanFile(t *testing.T) {
+	content := make([]byte, 200<<10)
+	for i := range content {
+		content[i] = byte(i)
+	}
+
+	path := filepath.Join(t.TempDir(), "file.bin")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	t.Run("Full Read", func(t *testing.T) {
+		scan, err := scanFile(path, scanOptions{
+			hashes:   []string{"md5"},
+			entropy:  true,
+			headSize: 16,
+			tailSize: 100,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, content[:16], scan.head)
+		assert.Equal(t, content[len(content)-100:], scan.tail)
+		assert.InDelta(t, 8.0, scan.entropy, 0.0001)
+		assert.Len(t, scan.hashes["md5"], 32)
+	})
+
+	t.Run("Head Only", func(t *testing.T) {
+		scan, err := scanFile(path, scanOptions{headSize: 8})
+		require.NoError(t, err)
+		assert.Equal(t, content[:8], scan.head)
+		assert.Nil(t, scan.hashes)
+		assert.Nil(t, scan.tail)
+	})
+
+	t.Run("Small File", func(t *testing.T) {
+		small := filepath.Join(t.TempDir(), "small")
+		require.NoError(t, os.WriteFile(small, []byte("aaaa"), 0o644))
+
+		scan, err := scanFile(small, scanOptions{entropy: true, headSize: 16, tailSize: 16})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("aaaa"), scan.head)
+		assert.Equal(t, []byte("aaaa"), scan.tail)
+		assert.Zero(t, scan.entropy)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := scanFile("nonexistent_file", scanOptions{headSize: 8})
+		assert.Error(t, err)
+	})
+}
+
+func TestTailWriter(t *testing.T) {
+	w := &tailWriter{buf: make([]byte, 5)}
+
+	for _, chunk := range []string{"ab", "cdefg", "h", "ijklmnopq", "r"} {
+		_, err := w.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []byte("nopqr"), w.bytes())
+}
+
+func TestMetaExtractor_Scan(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true, Entropy: true, SampleSize: 4, Hashes: []string{"sha1"}})
+
+	metadata, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("%PDF"), metadata.Head)
+	assert.Len(t, metadata.Tail, 4)
+	assert.Greater(t, metadata.Entropy, 0.0)
+	assert.Len(t, metadata.Hashes["sha1"], 40)
+	assert.Equal(t, "signature", metadata.Detector)
+}
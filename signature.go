@@ -0,0 +1,262 @@
+package metaextractor
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/attilabuti/trid"
+)
+
+// signatureHeadSize is the number of leading bytes inspected by the
+// signature detector.
+const signatureHeadSize = 8192
+
+// signature describes a file type recognized by its leading bytes.
+type signature struct {
+	// match reports whether the head of a file matches the signature.
+	match func(head []byte) bool
+
+	// fileType is the detected file type. Extensions follow the TrID
+	// notation (e.g., ".jpg/.jpeg").
+	fileType trid.FileType
+}
+
+// signatures are the file types known to the built-in detector, ordered from
+// the most to the least specific.
+var signatures = []signature{
+	{sigPrefix("%PDF-"), sigType(".pdf", "Adobe Portable Document Format", "application/pdf", 100)},
+	{sigPrefix("\x89PNG\r\n\x1a\n"), sigType(".png", "Portable Network Graphics", "image/png", 100)},
+	{sigPrefix("\xff\xd8\xff"), sigType(".jpg/.jpeg", "JPEG Bitmap", "image/jpeg", 100)},
+	{sigPrefix("GIF87a", "GIF89a"), sigType(".gif", "Graphics Interchange Format", "image/gif", 100)},
+	{sigPrefix("II*\x00", "MM\x00*"), sigType(".tif/.tiff", "Tagged Image File Format", "image/tiff", 90)},
+	{sigRIFF("WEBP"), sigType(".webp", "WebP Bitmap", "image/webp", 100)},
+	{sigRIFF("WAVE"), sigType(".wav", "Waveform Audio", "audio/wav", 100)},
+	{sigRIFF("AVI "), sigType(".avi", "Audio Video Interleave", "video/x-msvideo", 100)},
+	{sigPrefix("8BPS"), sigType(".psd", "Adobe Photoshop Image", "image/vnd.adobe.photoshop", 100)},
+	{isBMP, sigType(".bmp", "Windows Bitmap", "image/bmp", 80)},
+	{sigFtyp("qt  "), sigType(".mov/.qt", "QuickTime Movie", "video/quicktime", 100)},
+	{sigFtyp("M4A "), sigType(".m4a", "Apple iTunes Audio", "audio/mp4", 100)},
+	{sigFtyp("heic", "heix", "heim", "heis", "hevc", "mif1", "msf1"), sigType(".heic/.heif", "High Efficiency Image Container", "image/heic", 100)},
+	{sigFtyp("avif"), sigType(".avif", "AV1 Image File Format", "image/avif", 100)},
+	{sigFtyp("crx "), sigType(".cr3", "Canon RAW 3", "image/x-canon-cr3", 100)},
+	{sigFtyp("3gp4", "3gp5", "3gp6", "3g2a"), sigType(".3gp", "3GPP Multimedia", "video/3gpp", 100)},
+	{sigFtyp(), sigType(".mp4/.m4v", "MPEG-4 Media", "video/mp4", 90)},
+	{sigMatroska("webm"), sigType(".webm", "WebM Video", "video/webm", 100)},
+	{sigMatroska(""), sigType(".mkv", "Matroska Video", "video/x-matroska", 100)},
+	{sigPrefix("fLaC"), sigType(".flac", "Free Lossless Audio Codec", "audio/flac", 100)},
+	{sigPrefix("OggS"), sigType(".ogg/.oga/.ogv", "Ogg Container", "application/ogg", 100)},
+	{sigPrefix("ID3"), sigType(".mp3", "MP3 Audio (ID3 tag)", "audio/mpeg", 100)},
+	{sigPrefix("\xff\xfb", "\xff\xf3", "\xff\xf2"), sigType(".mp3", "MPEG-1 Audio Layer 3", "audio/mpeg", 60)},
+	{sigPrefix("MThd"), sigType(".mid/.midi", "MIDI Audio", "audio/midi", 100)},
+	{sigZipMimetype("application/epub+zip"), sigType(".epub", "EPUB Electronic Publication", "application/epub+zip", 100)},
+	{sigZipMimetype("application/vnd.oasis.opendocument.text"), sigType(".odt", "OpenDocument Text", "application/vnd.oasis.opendocument.text", 100)},
+	{sigZipMimetype("application/vnd.oasis.opendocument.spreadsheet"), sigType(".ods", "OpenDocument Spreadsheet", "application/vnd.oasis.opendocument.spreadsheet", 100)},
+	{sigZipMimetype("application/vnd.oasis.opendocument.presentation"), sigType(".odp", "OpenDocument Presentation", "application/vnd.oasis.opendocument.presentation", 100)},
+	{sigZipContains("word/"), sigType(".docx", "Word Microsoft Office Open XML Format document", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", 90)},
+	{sigZipContains("xl/"), sigType(".xlsx", "Excel Microsoft Office Open XML Format document", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", 90)},
+	{sigZipContains("ppt/"), sigType(".pptx", "PowerPoint Microsoft Office Open XML Format document", "application/vnd.openxmlformats-officedocument.presentationml.presentation", 90)},
+	{sigZipContains("AndroidManifest.xml"), sigType(".apk", "Android Package", "application/vnd.android.package-archive", 90)},
+	{sigZipContains("META-INF/MANIFEST.MF"), sigType(".jar", "Java Archive", "application/java-archive", 90)},
+	{sigPrefix("PK\x03\x04", "PK\x05\x06"), sigType(".zip", "ZIP compressed archive", "application/zip", 100)},
+	{sigPrefix("\x1f\x8b"), sigType(".gz/.tgz", "GZipped data", "application/gzip", 100)},
+	{sigPrefix("BZh"), sigType(".bz2", "bzip2 compressed archive", "application/x-bzip2", 90)},
+	{sigPrefix("\xfd7zXZ\x00"), sigType(".xz", "XZ compressed archive", "application/x-xz", 100)},
+	{sigPrefix("7z\xbc\xaf\x27\x1c"), sigType(".7z", "7-Zip compressed archive", "application/x-7z-compressed", 100)},
+	{sigPrefix("Rar!\x1a\x07\x00", "Rar!\x1a\x07\x01\x00"), sigType(".rar", "RAR compressed archive", "application/vnd.rar", 100)},
+	{sigPrefix("\x28\xb5\x2f\xfd"), sigType(".zst", "Zstandard compressed data", "application/zstd", 100)},
+	{isTarHeader, sigType(".tar", "TAR - Tape ARchive", "application/x-tar", 100)},
+	{sigPrefix("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), sigType(".doc/.xls/.ppt/.msg", "Generic OLE2 / Multistream Compound", "application/x-ole-storage", 80)},
+	{sigPrefix("{\\rtf"), sigType(".rtf", "Rich Text Format", "application/rtf", 100)},
+	{sigPrefix("SQLite format 3\x00"), sigType(".sqlite/.db", "SQLite Database", "application/vnd.sqlite3", 100)},
+	{sigPrefix("\x00asm"), sigType(".wasm", "WebAssembly binary", "application/wasm", 100)},
+	{sigPrefix("\x7fELF"), sigType("", "ELF Executable and Linkable format", "application/x-executable", 100)},
+	{isPE, sigType(".exe/.dll", "Win32 Executable", "application/vnd.microsoft.portable-executable", 100)},
+	{sigPrefix("MZ"), sigType(".exe", "Generic DOS Executable", "application/x-dosexec", 60)},
+	{sigPrefix("\xfe\xed\xfa\xce", "\xfe\xed\xfa\xcf", "\xce\xfa\xed\xfe", "\xcf\xfa\xed\xfe"), sigType("", "Mach-O Executable", "application/x-mach-binary", 100)},
+	{isFatMachO, sigType("", "Mach-O Universal Binary", "application/x-mach-binary", 90)},
+	{sigPrefix("\xca\xfe\xba\xbe"), sigType(".class", "Java bytecode", "application/java-vm", 90)},
+	{sigText(isSVG), sigType(".svg", "Scalable Vector Graphics", "image/svg+xml", 90)},
+	{sigText(sigPrefixFold("<?xml")), sigType(".xml", "Extensible Markup Language", "application/xml", 80)},
+	{sigText(sigPrefixFold("<!doctype html", "<html", "<head", "<body")), sigType(".html/.htm", "HyperText Markup Language", "text/html", 80)},
+}
+
+// detectSignature detects the type of a file from its leading bytes. It
+// returns nil if the type is not recognized.
+func detectSignature(head []byte) []trid.FileType {
+	for _, sig := range signatures {
+		if sig.match(head) {
+			return []trid.FileType{sig.fileType}
+		}
+	}
+
+	return nil
+}
+
+// signatureAnalysis detects the type of the file using the built-in
+// signature detector.
+func signatureAnalysis(filePath string) ([]trid.FileType, error) {
+	scan, err := scanFile(filePath, scanOptions{headSize: signatureHeadSize})
+	if err != nil {
+		return nil, err
+	}
+
+	return detectSignature(scan.head), nil
+}
+
+// sigType returns a file type as reported by the signature detector.
+func sigType(ext, name, mimeType string, probability float64) trid.FileType {
+	return trid.FileType{
+		Extension:   ext,
+		Probability: probability,
+		Name:        name,
+		MimeType:    mimeType,
+	}
+}
+
+// sigPrefix matches files starting with any of the given magic strings.
+func sigPrefix(magics ...string) func([]byte) bool {
+	return func(head []byte) bool {
+		for _, magic := range magics {
+			if bytes.HasPrefix(head, []byte(magic)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// sigPrefixFold matches text starting with any of the given strings, ignoring case.
+func sigPrefixFold(prefixes ...string) func([]byte) bool {
+	return func(head []byte) bool {
+		for _, p := range prefixes {
+			if len(head) >= len(p) && bytes.EqualFold(head[:len(p)], []byte(p)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// sigText applies the matcher to the head with a UTF-8 byte order mark and
+// leading white space removed.
+func sigText(match func([]byte) bool) func([]byte) bool {
+	return func(head []byte) bool {
+		head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+		return match(bytes.TrimLeft(head, " \t\r\n"))
+	}
+}
+
+// isSVG matches XML documents with an svg root element.
+func isSVG(head []byte) bool {
+	if !sigPrefixFold("<?xml", "<svg", "<!doctype svg")(head) {
+		return false
+	}
+	return bytes.Contains(head, []byte("<svg"))
+}
+
+// sigRIFF matches RIFF containers of the given form type.
+func sigRIFF(form string) func([]byte) bool {
+	return func(head []byte) bool {
+		return len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == form
+	}
+}
+
+// sigFtyp matches ISO base media files with any of the given major brands, or
+// any brand if none is given.
+func sigFtyp(brands ...string) func([]byte) bool {
+	return func(head []byte) bool {
+		if len(head) < 12 || string(head[4:8]) != "ftyp" {
+			return false
+		}
+
+		if len(brands) == 0 {
+			return true
+		}
+
+		for _, brand := range brands {
+			if string(head[8:12]) == brand {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// sigMatroska matches EBML files, optionally with the given document type.
+func sigMatroska(docType string) func([]byte) bool {
+	return func(head []byte) bool {
+		if !bytes.HasPrefix(head, []byte("\x1a\x45\xdf\xa3")) {
+			return false
+		}
+		return docType == "" || bytes.Contains(head[:min(len(head), 64)], []byte(docType))
+	}
+}
+
+// sigZipMimetype matches ZIP-based formats (OpenDocument, EPUB) whose first,
+// stored entry is named "mimetype" and holds the given MIME type.
+func sigZipMimetype(mimeType string) func([]byte) bool {
+	return func(head []byte) bool {
+		return bytes.HasPrefix(head, []byte("PK\x03\x04")) && len(head) >= 38 &&
+			string(head[30:38]) == "mimetype" && bytes.HasPrefix(head[38:], []byte(mimeType))
+	}
+}
+
+// sigZipContains matches ZIP archives with an entry whose name starts with the
+// given prefix in the inspected head of the file.
+func sigZipContains(name string) func([]byte) bool {
+	return func(head []byte) bool {
+		if !bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+			return false
+		}
+
+		for i := 0; i+30 <= len(head); {
+			j := bytes.Index(head[i:], []byte("PK\x03\x04"))
+			if j < 0 {
+				break
+			}
+			i += j
+
+			if i+30 > len(head) {
+				break
+			}
+
+			nameLen := int(binary.LittleEndian.Uint16(head[i+26:]))
+			if end := i + 30 + nameLen; end <= len(head) && bytes.HasPrefix(head[i+30:end], []byte(name)) {
+				return true
+			}
+			i += 4
+		}
+		return false
+	}
+}
+
+// isBMP matches Windows bitmaps with a known DIB header size.
+func isBMP(head []byte) bool {
+	if len(head) < 18 || string(head[:2]) != "BM" {
+		return false
+	}
+
+	switch binary.LittleEndian.Uint32(head[14:]) {
+	case 12, 40, 52, 56, 64, 108, 124:
+		return true
+	}
+	return false
+}
+
+// isPE matches DOS executables with a PE header.
+func isPE(head []byte) bool {
+	if len(head) < 64 || string(head[:2]) != "MZ" {
+		return false
+	}
+
+	offset := int(binary.LittleEndian.Uint32(head[60:]))
+	return offset > 0 && offset+4 <= len(head) && string(head[offset:offset+4]) == "PE\x00\x00"
+}
+
+// isFatMachO matches Mach-O universal binaries, which share their magic with
+// Java class files but have a small number of architectures in place of the
+// class file version.
+func isFatMachO(head []byte) bool {
+	return len(head) >= 8 && bytes.HasPrefix(head, []byte("\xca\xfe\xba\xbe")) &&
+		binary.BigEndian.Uint32(head[4:]) < 20
+}
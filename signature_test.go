@@ -0,0 +1,108 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectSignature(t *testing.T) {
+	pe := make([]byte, 256)
+	copy(pe, "MZ")
+	pe[60] = 128
+	copy(pe[128:], "PE\x00\x00")
+
+	bmp := make([]byte, 64)
+	copy(bmp, "BM")
+	bmp[14] = 40
+
+	testCases := []struct {
+		name     string
+		head     []byte
+		expected string
+	}{
+		{"PDF", []byte("%PDF-1.7\n"), ".pdf"},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ".png"},
+		{"JPEG", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), ".jpg/.jpeg"},
+		{"GIF", []byte("GIF89a\x01\x00"), ".gif"},
+		{"BMP", bmp, ".bmp"},
+		{"WebP", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), ".webp"},
+		{"WAV", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ".wav"},
+		{"QuickTime", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00"), ".mov/.qt"},
+		{"HEIC", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), ".heic/.heif"},
+		{"MP4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00"), ".mp4/.m4v"},
+		{"WebM", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), ".webm"},
+		{"Matroska", []byte("\x1a\x45\xdf\xa3\xa3\x42\x86\x81\x01\x42\x82\x88matroska"), ".mkv"},
+		{"MP3 ID3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), ".mp3"},
+		{"GZIP", gzipMagic, ".gz/.tgz"},
+		{"7-Zip", []byte("7z\xbc\xaf\x27\x1c\x00\x04"), ".7z"},
+		{"ELF", []byte("\x7fELF\x02\x01\x01"), ""},
+		{"PE", pe, ".exe/.dll"},
+		{"DOS", []byte("MZ\x90\x00"), ".exe"},
+		{"Java Class", []byte("\xca\xfe\xba\xbe\x00\x00\x00\x34"), ".class"},
+		{"Mach-O Universal", []byte("\xca\xfe\xba\xbe\x00\x00\x00\x02"), ""},
+		{"SVG", []byte("\xef\xbb\xbf<?xml version=\"1.0\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\"/>"), ".svg"},
+		{"XML", []byte("  <?xml version=\"1.0\"?><root/>"), ".xml"},
+		{"HTML", []byte("\n<!DOCTYPE html><html></html>"), ".html/.htm"},
+		{"ZIP", zipBytes(t, map[string][]byte{"a.txt": []byte("a")}), ".zip"},
+		{"DOCX", zipBytes(t, map[string][]byte{"[Content_Types].xml": []byte("<Types/>"), "word/document.xml": []byte("<w/>")}), ".docx"},
+		{"TAR", tarHead(), ".tar"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			types := detectSignature(tc.head)
+			require.Len(t, types, 1)
+			assert.Equal(t, tc.expected, types[0].Extension)
+			assert.NotEmpty(t, types[0].MimeType)
+		})
+	}
+
+	t.Run("Unknown", func(t *testing.T) {
+		assert.Nil(t, detectSignature([]byte("plain text")))
+		assert.Nil(t, detectSignature(nil))
+	})
+}
+
+func TestDetectSignature_OpenDocument(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("application/vnd.oasis.opendocument.text"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	types := detectSignature(buf.Bytes())
+	require.Len(t, types, 1)
+	assert.Equal(t, ".odt", types[0].Extension)
+}
+
+func TestSignatureAnalysis(t *testing.T) {
+	types, err := signatureAnalysis(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+	require.Len(t, types, 1)
+	assert.Equal(t, "application/pdf", types[0].MimeType)
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+	types, err = signatureAnalysis(empty)
+	require.NoError(t, err)
+	assert.Empty(t, types)
+
+	_, err = signatureAnalysis("nonexistent_file")
+	assert.Error(t, err)
+}
+
+// tarHead returns a minimal TAR header block.
+func tarHead() []byte {
+	head := make([]byte, 512)
+	copy(head, "file.txt")
+	copy(head[257:], tarMagic)
+	return head
+}
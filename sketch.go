@@ -0,0 +1,231 @@
+package metaextractor
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// MinHashSize is the number of MinHash values of a Sketch.
+const MinHashSize = 64
+
+// Parameters of the content-defined chunking: a chunk ends where the gear
+// hash of the preceding bytes has its 9 high bits clear (about every 512
+// bytes), but chunks are at least 64 and at most 4 KiB long. The high bits
+// depend on the last 64 bytes, the low bits only on the last few.
+const (
+	sketchChunkMask = (1<<9 - 1) << 55
+	sketchMinChunk  = 64
+	sketchMaxChunk  = 4 << 10
+)
+
+// sketchBands and sketchRows split the MinHash values into bands for the
+// locality-sensitive hashing of ClusterSimilar.
+const (
+	sketchBands = 16
+	sketchRows  = MinHashSize / sketchBands
+)
+
+// gearTable holds the random values of the gear rolling hash.
+var gearTable = func() (table [256]uint64) {
+	seed := uint64(0x6d657461)
+	for i := range table {
+		seed += 0x9e3779b97f4a7c15
+		table[i] = mix64(seed)
+	}
+	return table
+}()
+
+// Sketch is a compact summary of the file content for finding near-duplicate
+// files. The content is split into chunks at content-defined boundaries, so
+// that insertions and deletions only change the chunks around them.
+type Sketch struct {
+	// SimHash is the 64-bit SimHash of the chunks; the Hamming distance of
+	// the SimHashes of similar files is small.
+	SimHash uint64 `json:"sim_hash"`
+
+	// MinHash holds the MinHashSize minimum hashes of the chunks, used to
+	// estimate the share of chunks two files have in common.
+	MinHash []uint64 `json:"min_hash"`
+
+	// Chunks is the number of chunks.
+	Chunks int `json:"chunks"`
+}
+
+// Similarity estimates the Jaccard similarity of the chunk sets of two
+// sketches, from 0 (nothing in common) to 1 (identical).
+func (s Sketch) Similarity(other Sketch) float64 {
+	if s.Chunks == 0 || other.Chunks == 0 || len(s.MinHash) != len(other.MinHash) {
+		return 0
+	}
+
+	equal := 0
+	for i, v := range s.MinHash {
+		if v == other.MinHash[i] {
+			equal++
+		}
+	}
+
+	return float64(equal) / float64(len(s.MinHash))
+}
+
+// SimHashDistance returns the number of differing bits of two SimHashes.
+func SimHashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// ClusterSimilar groups the results whose content sketches have a similarity
+// of at least threshold (see Sketch.Similarity) into clusters of
+// near-duplicates. Only clusters with at least two paths are returned, in the
+// order of their first result. Results without a sketch are ignored.
+func ClusterSimilar(results []Result, threshold float64) [][]string {
+	parent := make([]int, len(results))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// Candidate pairs share all rows of at least one band.
+	buckets := make(map[[2]uint64][]int)
+	for i, r := range results {
+		sketch := r.Metadata.Sketch
+		if r.Err != nil || sketch == nil || sketch.Chunks == 0 || len(sketch.MinHash) != MinHashSize {
+			continue
+		}
+
+		for band := 0; band < sketchBands; band++ {
+			h := uint64(band)
+			for _, v := range sketch.MinHash[band*sketchRows : (band+1)*sketchRows] {
+				h = mix64(h ^ v)
+			}
+
+			key := [2]uint64{uint64(band), h}
+			for _, j := range buckets[key] {
+				if find(i) != find(j) && sketch.Similarity(*results[j].Metadata.Sketch) >= threshold {
+					parent[find(i)] = find(j)
+				}
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	clusters := make(map[int][]int)
+	for i, r := range results {
+		if r.Metadata.Sketch != nil && r.Err == nil {
+			root := find(i)
+			clusters[root] = append(clusters[root], i)
+		}
+	}
+
+	var groups [][]int
+	for _, members := range clusters {
+		if len(members) > 1 {
+			groups = append(groups, members)
+		}
+	}
+	sort.Slice(groups, func(a, b int) bool { return groups[a][0] < groups[b][0] })
+
+	paths := make([][]string, len(groups))
+	for i, members := range groups {
+		for _, j := range members {
+			paths[i] = append(paths[i], results[j].Path)
+		}
+	}
+
+	return paths
+}
+
+// sketchWriter computes the Sketch of the content written to it.
+type sketchWriter struct {
+	gear      uint64
+	chunkHash uint64
+	chunkLen  int
+	chunks    int
+	minHash   [MinHashSize]uint64
+	simCounts [64]int
+}
+
+// newSketchWriter returns a new sketchWriter.
+func newSketchWriter() *sketchWriter {
+	w := &sketchWriter{chunkHash: fnvOffset}
+	for i := range w.minHash {
+		w.minHash[i] = math.MaxUint64
+	}
+	return w
+}
+
+// FNV-1a parameters used to hash the chunks.
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+func (w *sketchWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		w.gear = w.gear<<1 + gearTable[b]
+		w.chunkHash = (w.chunkHash ^ uint64(b)) * fnvPrime
+		w.chunkLen++
+
+		if (w.chunkLen >= sketchMinChunk && w.gear&sketchChunkMask == 0) || w.chunkLen >= sketchMaxChunk {
+			w.endChunk()
+		}
+	}
+
+	return len(p), nil
+}
+
+// endChunk adds the current chunk to the sketch.
+func (w *sketchWriter) endChunk() {
+	h := w.chunkHash
+	w.chunks++
+	w.chunkHash = fnvOffset
+	w.chunkLen = 0
+
+	for i := range w.minHash {
+		if v := mix64(h ^ uint64(i+1)*0x9e3779b97f4a7c15); v < w.minHash[i] {
+			w.minHash[i] = v
+		}
+	}
+
+	sim := mix64(h)
+	for i := range w.simCounts {
+		if sim&(1<<i) != 0 {
+			w.simCounts[i]++
+		} else {
+			w.simCounts[i]--
+		}
+	}
+}
+
+// sketch returns the sketch of the content written so far.
+func (w *sketchWriter) sketch() *Sketch {
+	if w.chunkLen > 0 {
+		w.endChunk()
+	}
+
+	s := &Sketch{MinHash: append([]uint64(nil), w.minHash[:]...), Chunks: w.chunks}
+	for i, n := range w.simCounts {
+		if n > 0 {
+			s.SimHash |= 1 << i
+		}
+	}
+
+	return s
+}
+
+// mix64 is the finalizer of SplitMix64, used as a fast 64-bit hash.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,98 @@
+package metaextractor
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sketchOf returns the sketch of the data.
+func sketchOf(data []byte) *Sketch {
+	w := newSketchWriter()
+	w.Write(data)
+	return w.sketch()
+}
+
+// randomText returns n bytes of random words.
+func randomText(rng *rand.Rand, n int) []byte {
+	words := []string{"metadata", "extract", "file", "type", "image", "the", "of", "and", "report", "scan"}
+
+	var b []byte
+	for len(b) < n {
+		b = append(b, words[rng.Intn(len(words))]...)
+		b = append(b, ' ')
+	}
+	return b[:n]
+}
+
+func TestSketch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	doc := randomText(rng, 64<<10)
+
+	edited := append(append(append([]byte(nil), doc[:20000]...), "an inserted paragraph"...), doc[20000:]...)
+	other := randomText(rng, 64<<10)
+
+	s := sketchOf(doc)
+	assert.Greater(t, s.Chunks, 32)
+	assert.Len(t, s.MinHash, MinHashSize)
+
+	// Written in pieces, the sketch is the same.
+	w := newSketchWriter()
+	for i := 0; i < len(doc); i += 1000 {
+		w.Write(doc[i:min(i+1000, len(doc))])
+	}
+	assert.Equal(t, s, w.sketch())
+
+	assert.Equal(t, 1.0, s.Similarity(*sketchOf(doc)))
+	assert.Greater(t, s.Similarity(*sketchOf(edited)), 0.8)
+	assert.Less(t, s.Similarity(*sketchOf(other)), 0.3)
+
+	assert.Less(t, SimHashDistance(s.SimHash, sketchOf(edited).SimHash), SimHashDistance(s.SimHash, sketchOf(other).SimHash))
+
+	empty := sketchOf(nil)
+	assert.Zero(t, empty.Chunks)
+	assert.Zero(t, empty.Similarity(*empty))
+}
+
+func TestClusterSimilar(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	a := randomText(rng, 32<<10)
+	b := randomText(rng, 32<<10)
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a1.txt": a,
+		"a2.txt": append(append([]byte(nil), a...), "appendix"...),
+		"b1.txt": b,
+		"a3.txt": append([]byte("preface "), a...),
+		"b2.txt": append(append([]byte(nil), b[:1000]...), b[1010:]...),
+		"c.txt":  randomText(rng, 32<<10),
+	}
+	names := []string{"a1.txt", "a2.txt", "b1.txt", "a3.txt", "b2.txt", "c.txt"}
+
+	var paths []string
+	for _, name := range names {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, files[name], 0o644))
+		paths = append(paths, p)
+	}
+
+	me := NewMetaExtractor(Options{PureGo: true, Sketch: true})
+	results := me.ExtractBatch(paths)
+	for _, r := range results {
+		require.NoError(t, r.Err)
+		require.NotNil(t, r.Metadata.Sketch)
+	}
+
+	assert.Equal(t, [][]string{
+		{paths[0], paths[1], paths[3]},
+		{paths[2], paths[4]},
+	}, ClusterSimilar(results, 0.7))
+
+	assert.Empty(t, ClusterSimilar(results[:1], 0.7))
+	assert.Empty(t, ClusterSimilar(me.ExtractBatch(paths[:1]), 0.7))
+}
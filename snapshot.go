@@ -0,0 +1,73 @@
+package metaextractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// shadowCopyDevice matches the device path of a VSS shadow copy volume
+// without a trailing backslash.
+var shadowCopyDevice = regexp.MustCompile(`(?i)^\\\\\?\\GLOBALROOT\\Device\\HarddiskVolumeShadowCopy\d+$`)
+
+// snapshotRoot returns the root of a directory walk. The root directory of a
+// VSS shadow copy can only be opened with a trailing backslash (e.g.,
+// `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\`).
+func snapshotRoot(root string) string {
+	if shadowCopyDevice.MatchString(root) {
+		return root + `\`
+	}
+	return root
+}
+
+// snapshotMapper maps paths below the root of a snapshot to the paths the
+// files have on the live system.
+type snapshotMapper struct {
+	root     string
+	original string
+}
+
+// mapPath returns the original path of p. Paths below the root, including
+// virtual archive entry paths, are mapped by prefix; other paths are returned
+// unchanged.
+func (m snapshotMapper) mapPath(p string) string {
+	if p == "" {
+		return p
+	}
+
+	rest, ok := cutPathPrefix(p, m.root)
+	if !ok {
+		return p
+	}
+	if rest == "" {
+		return m.original
+	}
+
+	return strings.TrimRight(m.original, `\/`) + rest
+}
+
+// mapResults reports the results under their original paths, recording the
+// paths read in SourcePath.
+func (m snapshotMapper) mapResults(results []Result) {
+	for i := range results {
+		r := &results[i]
+
+		r.SourcePath = r.Path
+		r.Path = m.mapPath(r.Path)
+		r.DuplicateOf = m.mapPath(r.DuplicateOf)
+		for j, alias := range r.Aliases {
+			r.Aliases[j] = m.mapPath(alias)
+		}
+
+		if r.Dir != nil {
+			dir := *r.Dir
+			dir.Path = m.mapPath(dir.Path)
+			if dir.Newest != nil {
+				dir.Newest = &DirChild{Path: m.mapPath(dir.Newest.Path), ModTime: dir.Newest.ModTime}
+			}
+			if dir.Oldest != nil {
+				dir.Oldest = &DirChild{Path: m.mapPath(dir.Oldest.Path), ModTime: dir.Oldest.ModTime}
+			}
+			r.Dir = &dir
+		}
+	}
+}
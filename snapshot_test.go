@@ -0,0 +1,91 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRoot(t *testing.T) {
+	tests := map[string]string{
+		`\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3`:       `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\`,
+		`\\?\globalroot\device\harddiskvolumeshadowcopy12`:      `\\?\globalroot\device\harddiskvolumeshadowcopy12\`,
+		`\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\`:      `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\`,
+		`\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\Users`: `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\Users`,
+		"/mnt/snapshot": "/mnt/snapshot",
+	}
+
+	for root, expected := range tests {
+		assert.Equal(t, expected, snapshotRoot(root), root)
+	}
+}
+
+func TestSnapshotMapper_MapPath(t *testing.T) {
+	sep := string(filepath.Separator)
+	m := snapshotMapper{root: filepath.FromSlash("/mnt/snap/home"), original: filepath.FromSlash("/home")}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/mnt/snap/home", "/home"},
+		{"/mnt/snap/home/", "/home"},
+		{"/mnt/snap/home/user/a.jpg", "/home/user/a.jpg"},
+		{"/mnt/snap/home/b.zip!/inner/c.jpg", "/home/b.zip!/inner/c.jpg"},
+		{"/mnt/snap/homework/a.jpg", "/mnt/snap/homework/a.jpg"},
+		{"/other/a.jpg", "/other/a.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, filepath.FromSlash(tt.expected), m.mapPath(filepath.FromSlash(tt.path)))
+		})
+	}
+
+	assert.Empty(t, m.mapPath(""))
+
+	m = snapshotMapper{root: sep, original: filepath.FromSlash("/live/")}
+	assert.Equal(t, filepath.FromSlash("/live/a/b.jpg"), m.mapPath(filepath.FromSlash("/a/b.jpg")))
+	assert.Equal(t, filepath.FromSlash("/live/"), m.mapPath(sep))
+}
+
+func TestExtractDir_OriginalRoot(t *testing.T) {
+	snapshot := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeTree(t, snapshot, map[string]time.Time{
+		"a.txt":     base,
+		"sub/b.txt": base.Add(time.Hour),
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(snapshot, "sub", "c.txt"), []byte("a.txt"), 0o644))
+
+	original := filepath.Join(string(filepath.Separator), "live", "data")
+	me := NewMetaExtractor(Options{PureGo: true, Deduplicate: true})
+
+	results, err := me.ExtractDir(snapshot, WalkOptions{OriginalRoot: original, Dirs: true})
+	require.NoError(t, err)
+
+	byPath := make(map[string]Result)
+	for _, r := range results {
+		require.NoError(t, r.Err)
+		byPath[r.Path] = r
+	}
+
+	a := byPath[filepath.Join(original, "a.txt")]
+	assert.Equal(t, filepath.Join(snapshot, "a.txt"), a.SourcePath)
+	assert.Equal(t, "a.txt", a.Metadata.Name)
+	assert.Equal(t, []string{filepath.Join(original, "sub", "c.txt")}, a.Aliases)
+
+	c := byPath[filepath.Join(original, "sub", "c.txt")]
+	assert.Equal(t, filepath.Join(original, "a.txt"), c.DuplicateOf)
+
+	root := byPath[original]
+	require.NotNil(t, root.Dir)
+	assert.Equal(t, original, root.Dir.Path)
+	assert.Equal(t, snapshot, root.SourcePath)
+	require.NotNil(t, root.Dir.Newest)
+	assert.Equal(t, filepath.Join(original, "sub", "c.txt"), root.Dir.Newest.Path)
+}
@@ -0,0 +1,59 @@
+package metaextractor
+
+import (
+	"unicode/utf8"
+
+	"github.com/attilabuti/trid"
+)
+
+// DetectType returns the most likely type of the content using the built-in
+// signature detector. Only the leading bytes of data are inspected. It never
+// touches the file system or runs external tools, and is safe to call with
+// arbitrary input.
+func DetectType(data []byte) (trid.FileType, bool) {
+	types := detectSignature(data[:min(len(data), signatureHeadSize)])
+	if len(types) == 0 {
+		return trid.FileType{}, false
+	}
+
+	return types[0], true
+}
+
+// SniffMime returns the MIME type of the content. Content without a known
+// signature is reported as "text/plain; charset=utf-8" if it is valid UTF-8
+// text, and as "application/octet-stream" otherwise.
+func SniffMime(data []byte) string {
+	if fileType, ok := DetectType(data); ok && fileType.MimeType != "" {
+		return fileType.MimeType
+	}
+
+	if isText(data[:min(len(data), signatureHeadSize)]) {
+		return "text/plain; charset=utf-8"
+	}
+
+	return "application/octet-stream"
+}
+
+// isText reports whether the content is non-empty UTF-8 text without control
+// characters other than whitespace and escape sequences. A rune cut off at the
+// end of the content is ignored.
+func isText(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size <= 1 {
+			return !utf8.FullRune(data) && len(data) < utf8.UTFMax
+		}
+
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != 0x1b {
+			return false
+		}
+
+		data = data[size:]
+	}
+
+	return true
+}
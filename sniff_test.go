@@ -0,0 +1,69 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectType(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "sample.mp3"))
+	require.NoError(t, err)
+
+	fileType, ok := DetectType(data)
+	require.True(t, ok)
+	assert.Equal(t, ".mp3", fileType.Extension)
+	assert.Equal(t, "audio/mpeg", fileType.MimeType)
+
+	_, ok = DetectType(nil)
+	assert.False(t, ok)
+
+	_, ok = DetectType([]byte{0x00, 0x01, 0x02})
+	assert.False(t, ok)
+}
+
+func TestSniffMime(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"PDF", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+		{"Text", []byte("hello, world\n"), "text/plain; charset=utf-8"},
+		{"UTF-8 Text", []byte("árvíztűrő tükörfúrógép"), "text/plain; charset=utf-8"},
+		{"Truncated Rune", []byte("abc\xc3"), "text/plain; charset=utf-8"},
+		{"Invalid UTF-8", []byte("abc\xff\xfe"), "application/octet-stream"},
+		{"Control Characters", []byte("abc\x00def"), "application/octet-stream"},
+		{"Empty", nil, "application/octet-stream"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, SniffMime(tc.data))
+		})
+	}
+}
+
+func FuzzDetectType(f *testing.F) {
+	f.Add([]byte("%PDF-1.7"))
+	f.Add([]byte("PK\x03\x04"))
+	f.Add([]byte("MZ"))
+	f.Add([]byte("RIFF\x00\x00\x00\x00WEBP"))
+	f.Add([]byte("\x00\x00\x00\x18ftypheic"))
+	f.Add(tarHead())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fileType, ok := DetectType(data)
+		if !ok && fileType.Extension != "" {
+			t.Errorf("unexpected type %v", fileType)
+		}
+
+		if SniffMime(data) == "" {
+			t.Error("empty MIME type")
+		}
+	})
+}
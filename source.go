@@ -0,0 +1,133 @@
+package metaextractor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ExtractFS walks the tree rooted at root in fsys and extracts metadata from
+// every regular file accepted by the walk options. It allows scanning
+// sources other than the local file system without mounting them, e.g.
+// SFTP servers and SMB shares opened with OpenSFTP and OpenSMB. Files
+// are copied to a temporary file one at a time, so only one file is
+// buffered on disk. Result paths are the slash-separated paths in fsys.
+//
+// Of the walk options, the filters, MaxDepth and Archives are honored;
+// ignore files, bundles, symbolic links, Dirs and OriginalRoot only apply to
+// ExtractDir.
+func (me *MetaExtractor) ExtractFS(fsys fs.FS, root string, opts WalkOptions) ([]Result, error) {
+	if root == "" {
+		root = "."
+	}
+
+	ctx := context.Background()
+
+	var results []Result
+	emit := func(r Result) {
+		results = append(results, r)
+	}
+
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			emit(Result{Path: p, Err: err})
+			return nil
+		}
+
+		rel := fsRel(root, p)
+
+		if d.IsDir() {
+			if p != root && (matchAny(opts.Exclude, rel) || (opts.MaxDepth > 0 && walkDepth(rel) >= opts.MaxDepth)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			emit(Result{Path: p, Err: err})
+			return nil
+		}
+
+		accepted := opts.accept(rel, info)
+		descend := opts.Archives && !matchAny(opts.Exclude, rel)
+		if !accepted && !descend {
+			return nil
+		}
+
+		if err := me.extractFSFile(ctx, fsys, p, info, accepted, descend, opts.acceptFSEntry(root), emit); err != nil {
+			emit(Result{Path: p, Err: err})
+		}
+
+		return nil
+	})
+
+	me.stampResults(results)
+
+	return results, err
+}
+
+// extractFSFile copies a file of fsys to a temporary file, extracts its
+// metadata if accepted is true, and descends into it if descend is true and
+// it is an archive.
+func (me *MetaExtractor) extractFSFile(ctx context.Context, fsys fs.FS, p string, info fs.FileInfo, accepted, descend bool, accept entryFilter, emit func(Result)) error {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return me.extractStream(ctx, f, path.Base(p), p, info, accepted, descend, accept, emit)
+}
+
+// extractStream copies the content of a file read from r to a temporary
+// file, extracts its metadata if accepted is true, and descends into it if
+// descend is true and it is an archive. The results are reported under p,
+// and the file under the given name.
+func (me *MetaExtractor) extractStream(ctx context.Context, r io.Reader, name, p string, info fs.FileInfo, accepted, descend bool, accept entryFilter, emit func(Result)) error {
+	tmpFile, cleanup, err := spoolEntry(r, name)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if accepted {
+		metadata, err := me.extractFile(withFileName(ctx, entryFileName(name)), tmpFile, p, nil)
+		metadata.Time = me.timeOpts.fileTime(FileTime{ModTime: info.ModTime()})
+		emit(Result{Path: p, Metadata: metadata, Err: err})
+	}
+
+	if descend {
+		if err := me.walkArchive(ctx, tmpFile, p, 0, accept, emit); err != nil {
+			return fmt.Errorf("error reading archive: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// acceptFSEntry returns a filter applying the walk filters to the entries of
+// archives in an fs.FS.
+func (opts WalkOptions) acceptFSEntry(root string) entryFilter {
+	return func(entryPath string, info fs.FileInfo) bool {
+		return opts.accept(fsRel(root, entryPath), info)
+	}
+}
+
+// fsRel returns the path of p in fsys relative to root.
+func fsRel(root, p string) string {
+	if root == "." {
+		return p
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+}
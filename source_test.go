@@ -0,0 +1,65 @@
+package metaextractor
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractFS(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"share/a.jpg":      {Data: []byte("\xff\xd8\xff\xe0jpeg"), ModTime: modTime},
+		"share/docs/b.txt": {Data: []byte("text"), ModTime: modTime},
+		"share/skip/c.jpg": {Data: []byte("skipped"), ModTime: modTime},
+		"share/photos.zip": {Data: zipBytes(t, map[string][]byte{"d.jpg": []byte("zipped")}), ModTime: modTime},
+		"other/e.jpg":      {Data: []byte("outside"), ModTime: modTime},
+		"share/link":       {Data: []byte("a.jpg"), Mode: fs.ModeSymlink | 0o777},
+	}
+
+	me := NewMetaExtractor(Options{PureGo: true, UTC: true, RunID: "run"})
+
+	paths := func(results []Result) []string {
+		var paths []string
+		for _, r := range results {
+			require.NoError(t, r.Err)
+			paths = append(paths, r.Path)
+		}
+		return paths
+	}
+
+	t.Run("filters", func(t *testing.T) {
+		results, err := me.ExtractFS(fsys, "share", WalkOptions{Exclude: []string{"skip"}, Extensions: []string{".jpg"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"share/a.jpg"}, paths(results))
+
+		metadata := results[0].Metadata
+		assert.Equal(t, "a.jpg", metadata.Name)
+		assert.Equal(t, int64(8), metadata.Size)
+		assert.Equal(t, modTime, metadata.Time.ModTime)
+		assert.Equal(t, "run", results[0].RunID)
+	})
+
+	t.Run("archives", func(t *testing.T) {
+		results, err := me.ExtractFS(fsys, "share", WalkOptions{Archives: true, Extensions: []string{".jpg"}})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"share/a.jpg", "share/skip/c.jpg", "share/photos.zip" + ArchiveSeparator + "d.jpg",
+		}, paths(results))
+	})
+
+	t.Run("whole file system", func(t *testing.T) {
+		results, err := me.ExtractFS(fsys, "", WalkOptions{})
+		require.NoError(t, err)
+		assert.Len(t, results, 5)
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		_, err := me.ExtractFS(fsys, "missing", WalkOptions{})
+		assert.Error(t, err)
+	})
+}
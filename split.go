@@ -0,0 +1,479 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrIncompleteSplitArchive is returned when a split archive cannot be read
+// because some of its parts are missing.
+var ErrIncompleteSplitArchive = errors.New("incomplete split archive")
+
+// SplitArchive describes a multi-volume or split archive, whose parts are
+// treated as one logical container.
+type SplitArchive struct {
+	// Format is the archive format derived from the part names (e.g., "zip",
+	// "7z", "rar", "tar.gz"). It is empty if the format cannot be determined
+	// from the names alone.
+	Format string `json:"format"`
+
+	// Parts lists the paths of the parts that are present, in order.
+	Parts []string `json:"parts"`
+
+	// Missing lists the file names of the parts missing from the set.
+	Missing []string `json:"missing,omitempty"`
+
+	// Truncated reports whether Missing lists only the first
+	// maxSplitMissing missing parts.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+const (
+	// maxSplitParts is the highest position of a part in a split set; files
+	// numbered higher (e.g., "a.part999999999.rar") are not parts.
+	maxSplitParts = 9999
+
+	// maxSplitMissing limits the number of missing parts listed.
+	maxSplitMissing = 1000
+)
+
+// zipFinalVolume is the position of the .zip part of a spanned ZIP archive,
+// which is always the final volume.
+const zipFinalVolume = 1 << 20
+
+var (
+	// archive.zip.001, archive.7z.001, archive.tar.gz.001, ...
+	reNumberedPart = regexp.MustCompile(`^(.+)\.(\d{3})$`)
+	// archive.part1.rar, archive.part01.rar, ...
+	reRarPart = regexp.MustCompile(`(?i)^(.+)\.part(\d+)\.rar$`)
+	// archive.rar, archive.r00, archive.r01, ...
+	reRarVolume = regexp.MustCompile(`(?i)^(.+)\.(rar|r\d{2})$`)
+	// archive.z01, archive.z02, ..., archive.zip
+	reZipVolume = regexp.MustCompile(`(?i)^(.+)\.(zip|z\d{2})$`)
+)
+
+// splitArchiveFormats are the formats of numbered parts recognized as split
+// archives.
+var splitArchiveFormats = map[string]bool{
+	"zip": true, "7z": true, "rar": true, "tar": true,
+	"tar.gz": true, "tar.bz2": true, "tar.xz": true,
+	"gz": true, "bz2": true, "xz": true,
+}
+
+// splitScheme describes a naming scheme of split archive parts.
+type splitScheme struct {
+	format string
+	re     *regexp.Regexp
+	// index returns the position of the part in the set, given the
+	// submatches of re.
+	index func(m []string) int
+	// name returns the file name of the part at the given position.
+	name func(base string, width, index int) string
+	// first is the position of the first part; last is the position of the
+	// final part if it is known from its name, or -1.
+	first, last func(indexes map[int]string) int
+}
+
+var splitSchemes = []splitScheme{
+	{
+		re: reNumberedPart,
+		index: func(m []string) int {
+			n, _ := strconv.Atoi(m[2])
+			return n
+		},
+		name: func(base string, width, index int) string {
+			return fmt.Sprintf("%s.%0*d", base, width, index)
+		},
+		first: func(indexes map[int]string) int {
+			if _, ok := indexes[0]; ok {
+				return 0
+			}
+			return 1
+		},
+		last: func(map[int]string) int { return -1 },
+	},
+	{
+		format: "rar",
+		re:     reRarPart,
+		index: func(m []string) int {
+			n, _ := strconv.Atoi(m[2])
+			return n
+		},
+		name: func(base string, width, index int) string {
+			return fmt.Sprintf("%s.part%0*d.rar", base, width, index)
+		},
+		first: func(map[int]string) int { return 1 },
+		last:  func(map[int]string) int { return -1 },
+	},
+	{
+		format: "rar",
+		re:     reRarVolume,
+		index: func(m []string) int {
+			if strings.EqualFold(m[2], "rar") {
+				return 0
+			}
+			n, _ := strconv.Atoi(m[2][1:])
+			return n + 1
+		},
+		name: func(base string, _, index int) string {
+			if index == 0 {
+				return base + ".rar"
+			}
+			return fmt.Sprintf("%s.r%02d", base, index-1)
+		},
+		first: func(map[int]string) int { return 0 },
+		last:  func(map[int]string) int { return -1 },
+	},
+	{
+		format: "zip",
+		re:     reZipVolume,
+		index: func(m []string) int {
+			if strings.EqualFold(m[2], "zip") {
+				return zipFinalVolume
+			}
+			n, _ := strconv.Atoi(m[2][1:])
+			return n
+		},
+		name: func(base string, _, index int) string {
+			if index == zipFinalVolume {
+				return base + ".zip"
+			}
+			return fmt.Sprintf("%s.z%02d", base, index)
+		},
+		first: func(map[int]string) int { return 1 },
+		last: func(indexes map[int]string) int {
+			last := 0
+			for i := range indexes {
+				if i != zipFinalVolume && i > last {
+					last = i
+				}
+			}
+			return last
+		},
+	},
+}
+
+// DetectSplitArchive reports whether the file is a part of a split or
+// multi-volume archive (e.g., "archive.zip.001", "archive.part2.rar",
+// "archive.z01"). It returns nil if the file is not part of a split set.
+// Missing parts are determined from gaps in the numbering; parts after the
+// last present one can only be detected for schemes with a known final part.
+func DetectSplitArchive(filePath string) (*SplitArchive, error) {
+	return detectSplitArchive(filePath, nil)
+}
+
+// detectSplitArchive is DetectSplitArchive, listing the directory of the file
+// through the cache, if not nil.
+func detectSplitArchive(filePath string, cache *splitDirCache) (*SplitArchive, error) {
+	name := filepath.Base(filePath)
+
+	for _, scheme := range splitSchemes {
+		m := scheme.re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+
+		if !splitIndexOK(scheme.index(m)) {
+			continue
+		}
+
+		base, width := m[1], len(m[2])
+
+		// Numbered parts are only considered archives if the name without
+		// the part number carries an archive extension (e.g.,
+		// "backup.zip.001", but not "log.txt.001").
+		if scheme.re == reNumberedPart && !splitArchiveFormats[splitFormat(base)] {
+			continue
+		}
+
+		names, err := cache.fileNames(filepath.Dir(filePath))
+		if err != nil {
+			return nil, err
+		}
+
+		indexes := make(map[int]string)
+		for _, entryName := range names {
+			sm := scheme.re.FindStringSubmatch(entryName)
+			if sm == nil || sm[1] != base {
+				continue
+			}
+			if scheme.re != reRarVolume && scheme.re != reZipVolume && len(sm[2]) != width {
+				continue
+			}
+			if !splitIndexOK(scheme.index(sm)) {
+				continue
+			}
+			indexes[scheme.index(sm)] = entryName
+		}
+
+		// A plain .rar or .zip file is only a volume if further volumes exist.
+		if len(indexes) < 2 && (scheme.re == reRarVolume || scheme.re == reZipVolume) {
+			continue
+		}
+
+		return newSplitArchive(filePath, scheme, base, width, indexes), nil
+	}
+
+	return nil, nil
+}
+
+// splitDirCache caches the names of the files in the directories listed while
+// detecting split archives, so that a walk lists each directory once rather
+// than once for every file named like a part. A nil cache lists the
+// directory every time.
+type splitDirCache struct {
+	mu    sync.Mutex
+	names map[string][]string
+}
+
+// fileNames returns the names of the entries of dir that are not
+// directories.
+func (c *splitDirCache) fileNames(dir string) ([]string, error) {
+	if c != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if names, ok := c.names[dir]; ok {
+			return names, nil
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	if c != nil {
+		if c.names == nil {
+			c.names = make(map[string][]string)
+		}
+		c.names[dir] = names
+	}
+
+	return names, nil
+}
+
+// splitDirKey is the context key of the directory cache of a walk.
+type splitDirKey struct{}
+
+// withSplitDirCache returns ctx carrying a new directory cache, shared by the
+// split archive detection of the files extracted with it.
+func withSplitDirCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, splitDirKey{}, &splitDirCache{})
+}
+
+// splitDirCacheFromContext returns the directory cache of ctx, or nil.
+func splitDirCacheFromContext(ctx context.Context) *splitDirCache {
+	c, _ := ctx.Value(splitDirKey{}).(*splitDirCache)
+	return c
+}
+
+// newSplitArchive builds the SplitArchive from the present part indexes.
+func newSplitArchive(filePath string, scheme splitScheme, base string, width int, indexes map[int]string) *SplitArchive {
+	sa := &SplitArchive{Format: scheme.format}
+	if sa.Format == "" {
+		sa.Format = splitFormat(base)
+	}
+
+	present := make([]int, 0, len(indexes))
+	for i := range indexes {
+		present = append(present, i)
+	}
+	sort.Ints(present)
+
+	dir := filepath.Dir(filePath)
+	for _, i := range present {
+		sa.Parts = append(sa.Parts, filepath.Join(dir, indexes[i]))
+	}
+
+	last := scheme.last(indexes)
+	if last < 0 {
+		last = present[len(present)-1]
+	}
+
+	for i := scheme.first(indexes); i <= last; i++ {
+		if _, ok := indexes[i]; ok {
+			continue
+		}
+		if len(sa.Missing) == maxSplitMissing {
+			sa.Truncated = true
+			break
+		}
+		sa.Missing = append(sa.Missing, scheme.name(base, width, i))
+	}
+
+	if scheme.re == reZipVolume && !sa.Truncated {
+		if _, ok := indexes[zipFinalVolume]; !ok {
+			sa.Missing = append(sa.Missing, scheme.name(base, width, zipFinalVolume))
+		}
+	}
+
+	return sa
+}
+
+// splitIndexOK reports whether i is a valid position of a part in a split
+// set.
+func splitIndexOK(i int) bool {
+	return i >= 0 && (i <= maxSplitParts || i == zipFinalVolume)
+}
+
+// splitFormat derives the archive format from the name of a numbered split
+// archive without its part number (e.g., "backup.tar.gz" -> "tar.gz").
+func splitFormat(base string) string {
+	lower := strings.ToLower(base)
+	for _, format := range []string{"tar.gz", "tar.bz2", "tar.xz"} {
+		if strings.HasSuffix(lower, "."+format) {
+			return format
+		}
+	}
+
+	if strings.HasSuffix(lower, ".tgz") {
+		return "tar.gz"
+	}
+
+	return strings.TrimPrefix(filepath.Ext(lower), ".")
+}
+
+// walkSplitArchive descends into a split archive as one logical container.
+// Only sets whose concatenated parts form a ZIP or TAR archive can be listed;
+// other formats are ignored.
+func (me *MetaExtractor) walkSplitArchive(ctx context.Context, sa *SplitArchive, virtualPath string, depth int, accept entryFilter, emit func(Result)) error {
+	if len(sa.Missing) > 0 {
+		missing := strings.Join(sa.Missing, ", ")
+		if sa.Truncated {
+			missing += ", ..."
+		}
+		return fmt.Errorf("%w: missing %s", ErrIncompleteSplitArchive, missing)
+	}
+
+	// Spanned ZIP and RAR volumes are not simple concatenations.
+	if sa.Format == "rar" || (sa.Format == "zip" && reZipVolume.MatchString(filepath.Base(sa.Parts[0]))) {
+		return nil
+	}
+
+	kind, err := detectArchive(sa.Parts[0])
+	if err != nil || (kind != archiveZip && kind != archiveTar && kind != archiveTarGz) {
+		return err
+	}
+
+	mr, err := openMultiReader(sa.Parts)
+	if err != nil {
+		return err
+	}
+	defer mr.Close()
+
+	if kind == archiveZip {
+		zr, err := zip.NewReader(mr, mr.size)
+		if err != nil {
+			return err
+		}
+		return me.walkZipReader(ctx, zr, virtualPath, depth, accept, emit)
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(mr, 0, mr.size))
+
+	var r io.Reader = br
+	if head, _ := br.Peek(len(gzipMagic)); bytes.Equal(head, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return me.walkTar(ctx, r, virtualPath, depth, accept, emit)
+}
+
+// multiReader is an io.ReaderAt over the concatenation of several files.
+type multiReader struct {
+	files   []*os.File
+	offsets []int64
+	size    int64
+}
+
+// openMultiReader opens the given files as one concatenated io.ReaderAt.
+func openMultiReader(paths []string) (*multiReader, error) {
+	mr := &multiReader{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			mr.Close()
+			return nil, err
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			mr.Close()
+			return nil, err
+		}
+
+		mr.files = append(mr.files, f)
+		mr.offsets = append(mr.offsets, mr.size)
+		mr.size += info.Size()
+	}
+
+	return mr, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (mr *multiReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= mr.size {
+		return 0, io.EOF
+	}
+
+	i := sort.Search(len(mr.offsets), func(i int) bool { return mr.offsets[i] > off }) - 1
+
+	n := 0
+	for n < len(p) && i < len(mr.files) {
+		m, err := mr.files[i].ReadAt(p[n:], off-mr.offsets[i])
+		n += m
+		off += int64(m)
+
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if err == io.EOF || (i+1 < len(mr.offsets) && off >= mr.offsets[i+1]) {
+			i++
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Close closes all underlying files.
+func (mr *multiReader) Close() error {
+	var errs []error
+	for _, f := range mr.files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
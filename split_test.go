@@ -0,0 +1,177 @@
+package metaextractor
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectSplitArchive(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"a.zip.001":      "",
+		"a.zip.002":      "",
+		"a.zip.004":      "",
+		"b.part1.rar":    "",
+		"b.part2.rar":    "",
+		"c.rar":          "",
+		"c.r00":          "",
+		"c.r02":          "",
+		"d.z01":          "",
+		"d.z02":          "",
+		"e.7z.002":       "",
+		"f.zip":          "",
+		"g.001":          "",
+		"h.tar.gz.001":   "",
+		"log.txt.002":    "",
+		"notes.txt":      "",
+		"single.part1.x": "",
+	})
+
+	testCases := []struct {
+		name    string
+		format  string
+		parts   []string
+		missing []string
+	}{
+		{"a.zip.002", "zip", []string{"a.zip.001", "a.zip.002", "a.zip.004"}, []string{"a.zip.003"}},
+		{"b.part2.rar", "rar", []string{"b.part1.rar", "b.part2.rar"}, nil},
+		{"c.r00", "rar", []string{"c.rar", "c.r00", "c.r02"}, []string{"c.r01"}},
+		{"d.z01", "zip", []string{"d.z01", "d.z02"}, []string{"d.zip"}},
+		{"e.7z.002", "7z", []string{"e.7z.002"}, []string{"e.7z.001"}},
+		{"h.tar.gz.001", "tar.gz", []string{"h.tar.gz.001"}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa, err := DetectSplitArchive(filepath.Join(root, tc.name))
+			require.NoError(t, err)
+			require.NotNil(t, sa)
+
+			var parts []string
+			for _, p := range sa.Parts {
+				parts = append(parts, filepath.Base(p))
+			}
+
+			assert.Equal(t, tc.format, sa.Format)
+			assert.Equal(t, tc.parts, parts)
+			assert.Equal(t, tc.missing, sa.Missing)
+		})
+	}
+
+	for _, name := range []string{"f.zip", "g.001", "log.txt.002", "notes.txt", "single.part1.x"} {
+		t.Run(name, func(t *testing.T) {
+			sa, err := DetectSplitArchive(filepath.Join(root, name))
+			require.NoError(t, err)
+			assert.Nil(t, sa)
+		})
+	}
+}
+
+func TestDetectSplitArchive_Limits(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"a.part999999999.rar": "",
+		"b.part0001.rar":      "",
+		"b.part5000.rar":      "",
+	})
+
+	sa, err := DetectSplitArchive(filepath.Join(root, "a.part999999999.rar"))
+	require.NoError(t, err)
+	assert.Nil(t, sa)
+
+	sa, err = DetectSplitArchive(filepath.Join(root, "b.part0001.rar"))
+	require.NoError(t, err)
+	require.NotNil(t, sa)
+	assert.Len(t, sa.Parts, 2)
+	assert.Len(t, sa.Missing, maxSplitMissing)
+	assert.Equal(t, "b.part0002.rar", sa.Missing[0])
+	assert.True(t, sa.Truncated)
+}
+
+func TestSplitDirCache(t *testing.T) {
+	root := createTree(t, map[string]string{"a.zip.001": "", "a.zip.002": ""})
+	cache := &splitDirCache{}
+
+	sa, err := detectSplitArchive(filepath.Join(root, "a.zip.001"), cache)
+	require.NoError(t, err)
+	require.NotNil(t, sa)
+	assert.Len(t, sa.Parts, 2)
+
+	// The listing is reused for the other parts of the directory.
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.zip.003"), nil, 0o644))
+	sa, err = detectSplitArchive(filepath.Join(root, "a.zip.002"), cache)
+	require.NoError(t, err)
+	assert.Len(t, sa.Parts, 2)
+
+	sa, err = DetectSplitArchive(filepath.Join(root, "a.zip.002"))
+	require.NoError(t, err)
+	assert.Len(t, sa.Parts, 3)
+}
+
+func TestMultiReader(t *testing.T) {
+	root := createTree(t, map[string]string{"1": "abc", "2": "", "3": "defg", "4": "h"})
+
+	mr, err := openMultiReader([]string{
+		filepath.Join(root, "1"), filepath.Join(root, "2"), filepath.Join(root, "3"), filepath.Join(root, "4"),
+	})
+	require.NoError(t, err)
+	defer mr.Close()
+
+	data, err := io.ReadAll(io.NewSectionReader(mr, 0, mr.size))
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefgh", string(data))
+
+	buf := make([]byte, 4)
+	n, err := mr.ReadAt(buf, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "cdef", string(buf[:n]))
+
+	n, err = mr.ReadAt(buf, 6)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "gh", string(buf[:n]))
+}
+
+func TestExtractDir_SplitArchive(t *testing.T) {
+	archive := zipBytes(t, map[string][]byte{"inner/a.txt": []byte("a"), "b.txt": []byte("b")})
+	third := len(archive) / 3
+
+	root := t.TempDir()
+	for i, part := range [][]byte{archive[:third], archive[third : 2*third], archive[2*third:]} {
+		name := filepath.Join(root, "set.zip.00"+string(rune('1'+i)))
+		require.NoError(t, os.WriteFile(name, part, 0o644))
+	}
+
+	extractor := NewMetaExtractor(Options{})
+
+	results, err := extractor.ExtractDir(root, WalkOptions{Archives: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"set.zip.001", "set.zip.001!/b.txt", "set.zip.001!/inner/a.txt"}, resultPaths(t, root, results))
+
+	require.NoError(t, os.Remove(filepath.Join(root, "set.zip.002")))
+
+	results, err = extractor.ExtractDir(root, WalkOptions{Archives: true})
+	require.NoError(t, err)
+
+	incomplete := 0
+	for _, r := range results {
+		if errors.Is(r.Err, ErrIncompleteSplitArchive) {
+			incomplete++
+		}
+	}
+	assert.Equal(t, 1, incomplete)
+
+	// Numbered files that are not archives are files of their own.
+	require.NoError(t, os.WriteFile(filepath.Join(root, "log.txt.002"), []byte("log"), 0o644))
+	results, err = extractor.ExtractDir(root, WalkOptions{Archives: true})
+	require.NoError(t, err)
+	assert.Contains(t, resultPaths(t, root, results), "log.txt.002")
+
+	// Without archive handling, every part is a file of its own.
+	results, err = extractor.ExtractDir(root, WalkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"log.txt.002", "set.zip.001", "set.zip.003"}, resultPaths(t, root, results))
+}
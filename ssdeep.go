@@ -0,0 +1,360 @@
+package metaextractor
+
+import (
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// Parameters of the ssdeep context triggered piecewise hash (CTPH).
+const (
+	ssdeepWindow       = 7
+	ssdeepMinBlockSize = 3
+	ssdeepLength       = 64
+	ssdeepBlockHashes  = 31
+
+	// ssdeepHashInit and ssdeepHashPrime are the low 6 bits of the FNV
+	// offset and prime used by ssdeep; only the low 6 bits of the piece
+	// hashes end up in the digest.
+	ssdeepHashInit  = 0x27
+	ssdeepHashPrime = 0x13
+)
+
+const ssdeepBase64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// ssdeepBlockHash is the state of the piece hashes of one block size.
+type ssdeepBlockHash struct {
+	digest [ssdeepLength]byte
+	n      int
+
+	// half is the last character of the digest truncated to half its
+	// length, and h and halfH the hashes of the current pieces.
+	half     byte
+	h, halfH byte
+}
+
+// ssdeep computes the context triggered piecewise hash of ssdeep, as printed
+// by the ssdeep tool: the block size and the digests of the pieces of the
+// content at the block size and at twice the block size, separated by
+// colons (e.g., "12:gq6UqvU1Qf/UiGWnnIudTxJWDKr+dy/PvYJ:yi1QHUITdT2DKr+dmm").
+// Piece boundaries depend on the content only, so similar files share
+// parts of their digests. The content is hashed in a single pass with all
+// candidate block sizes, as by libfuzzy's streaming interface.
+type ssdeep struct {
+	total uint64
+
+	// The rolling hash of the last ssdeepWindow bytes.
+	window     [ssdeepWindow]byte
+	h1, h2, h3 uint32
+	rollN      uint32
+
+	start, end int
+	bh         [ssdeepBlockHashes]ssdeepBlockHash
+}
+
+// newSSDeep returns a new ssdeep hash. Sum appends the text of the fuzzy
+// hash rather than bytes to be hex-encoded (see hashDigest).
+func newSSDeep() hash.Hash {
+	h := &ssdeep{}
+	h.Reset()
+	return h
+}
+
+func (h *ssdeep) Reset() {
+	*h = ssdeep{end: 1}
+	h.bh[0].h, h.bh[0].halfH = ssdeepHashInit, ssdeepHashInit
+}
+
+func (h *ssdeep) Size() int {
+	// The maximum length of the text: the block size, the two digests and
+	// the separators.
+	return 10 + 1 + ssdeepLength + 1 + ssdeepLength/2
+}
+
+func (h *ssdeep) BlockSize() int {
+	return 1
+}
+
+// ssdeepBlockSize returns the block size of the i-th piece hash.
+func ssdeepBlockSize(i int) uint64 {
+	return ssdeepMinBlockSize << i
+}
+
+// ssdeepSum adds a byte to the hash of a piece.
+func ssdeepSum(c byte, h byte) byte {
+	return (h*ssdeepHashPrime ^ c) & 0x3f
+}
+
+func (h *ssdeep) Write(p []byte) (int, error) {
+	h.total += uint64(len(p))
+
+	for _, c := range p {
+		h.roll(c)
+		sum := h.rollSum()
+
+		for i := h.start; i < h.end; i++ {
+			h.bh[i].h = ssdeepSum(c, h.bh[i].h)
+			h.bh[i].halfH = ssdeepSum(c, h.bh[i].halfH)
+		}
+
+		// A piece ends where the rolling hash hits a trigger value of
+		// the block size, which is also one of all smaller block sizes.
+		for i := h.start; i < h.end; i++ {
+			bs := ssdeepBlockSize(i)
+			if uint64(sum)%bs != bs-1 {
+				break
+			}
+
+			b := &h.bh[i]
+			if b.n == 0 {
+				h.fork()
+			}
+
+			b.digest[b.n] = ssdeepBase64[b.h]
+			b.half = ssdeepBase64[b.halfH]
+
+			// The last pieces are combined into the last character once
+			// the digest is full.
+			if b.n < ssdeepLength-1 {
+				b.n++
+				b.digest[b.n] = 0
+				b.h = ssdeepHashInit
+				if b.n < ssdeepLength/2 {
+					b.halfH = ssdeepHashInit
+					b.half = 0
+				}
+			} else {
+				h.reduce()
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// roll adds a byte to the rolling hash.
+func (h *ssdeep) roll(c byte) {
+	h.h2 -= h.h1
+	h.h2 += ssdeepWindow * uint32(c)
+	h.h1 += uint32(c)
+	h.h1 -= uint32(h.window[h.rollN%ssdeepWindow])
+	h.window[h.rollN%ssdeepWindow] = c
+	h.rollN++
+	h.h3 = h.h3<<5 ^ uint32(c)
+}
+
+func (h *ssdeep) rollSum() uint32 {
+	return h.h1 + h.h2 + h.h3
+}
+
+// fork starts hashing with the next larger block size, once the largest
+// one ended its first piece.
+func (h *ssdeep) fork() {
+	if h.end >= ssdeepBlockHashes {
+		return
+	}
+
+	prev := h.bh[h.end-1]
+	h.bh[h.end] = ssdeepBlockHash{h: prev.h, halfH: prev.halfH}
+	h.end++
+}
+
+// reduce stops hashing with the smallest block size once it can no longer
+// be selected: the content is too long for it, and the next larger block
+// size already has a digest of at least half the length.
+func (h *ssdeep) reduce() {
+	if h.end-h.start < 2 ||
+		ssdeepBlockSize(h.start)*ssdeepLength >= h.total ||
+		h.bh[h.start+1].n < ssdeepLength/2 {
+		return
+	}
+
+	h.start++
+}
+
+func (h *ssdeep) Sum(b []byte) []byte {
+	return append(b, h.digest()...)
+}
+
+// digest returns the fuzzy hash of the content written so far.
+func (h *ssdeep) digest() string {
+	// The block size is the smallest one whose digest fits the content,
+	// reduced while its digest is shorter than half the length.
+	i := h.start
+	for ssdeepBlockSize(i)*ssdeepLength < h.total && i < ssdeepBlockHashes-1 {
+		i++
+	}
+	for i >= h.end {
+		i--
+	}
+	for i > h.start && h.bh[i].n < ssdeepLength/2 {
+		i--
+	}
+
+	sum := h.rollSum()
+
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatUint(ssdeepBlockSize(i), 10))
+	sb.WriteByte(':')
+
+	b := &h.bh[i]
+	sb.Write(b.digest[:b.n])
+	if sum != 0 {
+		sb.WriteByte(ssdeepBase64[b.h])
+	} else if b.digest[b.n] != 0 {
+		sb.WriteByte(b.digest[b.n])
+	}
+	sb.WriteByte(':')
+
+	// The second digest is that of the double block size, truncated to
+	// half the length.
+	if i < h.end-1 {
+		b := &h.bh[i+1]
+		sb.Write(b.digest[:min(b.n, ssdeepLength/2-1)])
+		if sum != 0 {
+			sb.WriteByte(ssdeepBase64[b.halfH])
+		} else if b.half != 0 {
+			sb.WriteByte(b.half)
+		}
+	} else if sum != 0 {
+		sb.WriteByte(ssdeepBase64[b.h])
+	}
+
+	return sb.String()
+}
+
+// errInvalidSSDeep is returned by SSDeepScore for malformed fuzzy hashes.
+var errInvalidSSDeep = errors.New("invalid ssdeep hash")
+
+// SSDeepScore returns the similarity (0-100) of the content of two files
+// from their ssdeep fuzzy hashes (see Options.Hashes), as reported by
+// ssdeep's matching mode: 100 for identical hashes, 0 for unrelated
+// content or block sizes too far apart to be compared.
+func SSDeepScore(a, b string) (int, error) {
+	bs1, a1, a2, err := parseSSDeep(a)
+	if err != nil {
+		return 0, err
+	}
+	bs2, b1, b2, err := parseSSDeep(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// Digests are only compared at a block size both hashes have.
+	switch {
+	case bs1 == bs2:
+		if a1 == b1 && a2 == b2 {
+			return 100, nil
+		}
+		return max(ssdeepScore(a1, b1, bs1), ssdeepScore(a2, b2, bs1*2)), nil
+	case bs1*2 == bs2:
+		return ssdeepScore(a2, b1, bs2), nil
+	case bs2*2 == bs1:
+		return ssdeepScore(a1, b2, bs1), nil
+	}
+
+	return 0, nil
+}
+
+// parseSSDeep splits a fuzzy hash into its block size and its digests,
+// without runs of more than three identical characters. A file name
+// following the hash, as printed by the ssdeep tool, is ignored.
+func parseSSDeep(s string) (uint64, string, string, error) {
+	s, _, _ = strings.Cut(s, ",")
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, "", "", errInvalidSSDeep
+	}
+
+	bs, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || bs < ssdeepMinBlockSize || bs > 1<<62 {
+		return 0, "", "", errInvalidSSDeep
+	}
+
+	d1, d2 := eliminateSequences(parts[1]), eliminateSequences(parts[2])
+	if len(d1) > ssdeepLength || len(d2) > ssdeepLength {
+		return 0, "", "", errInvalidSSDeep
+	}
+
+	return bs, d1, d2, nil
+}
+
+// eliminateSequences shortens the runs of identical characters to three,
+// as they carry little information.
+func eliminateSequences(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if i < 3 || s[i] != s[i-1] || s[i] != s[i-2] || s[i] != s[i-3] {
+			out = append(out, s[i])
+		}
+	}
+
+	return string(out)
+}
+
+// ssdeepScore scores the similarity (0-100) of two digests of the given
+// block size.
+func ssdeepScore(a, b string, blockSize uint64) int {
+	if len(a) < ssdeepWindow || len(b) < ssdeepWindow || !hasCommonSubstring(a, b) {
+		return 0
+	}
+
+	// The edit distance is scaled to the lengths of the digests, then to
+	// 0-100.
+	score := uint64(editDistance(a, b)) * ssdeepLength / uint64(len(a)+len(b))
+	score = 100 * score / ssdeepLength
+	if score >= 100 {
+		return 0
+	}
+	score = 100 - score
+
+	// Matches of small block sizes are not exaggerated.
+	if blockSize < (99+ssdeepWindow)/ssdeepWindow*ssdeepMinBlockSize {
+		score = min(score, blockSize/ssdeepMinBlockSize*uint64(min(len(a), len(b))))
+	}
+
+	return int(score)
+}
+
+// hasCommonSubstring reports whether the digests share a substring of
+// ssdeepWindow characters.
+func hasCommonSubstring(a, b string) bool {
+	substrings := make(map[string]bool, len(a))
+	for i := 0; i+ssdeepWindow <= len(a); i++ {
+		substrings[a[i:i+ssdeepWindow]] = true
+	}
+
+	for i := 0; i+ssdeepWindow <= len(b); i++ {
+		if substrings[b[i:i+ssdeepWindow]] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// editDistance returns the edit distance of the digests, with insertions
+// and deletions costing 1 and substitutions 2.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 2
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
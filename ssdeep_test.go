@@ -0,0 +1,125 @@
+package metaextractor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ssdeepBytes returns n pseudo-random bytes.
+func ssdeepBytes(n int, seed uint64) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		seed = seed*6364136223846793005 + 1442695040888963407
+		data[i] = byte(seed >> 56)
+	}
+
+	return data
+}
+
+// ssdeepText returns n bytes of pseudo-random words.
+func ssdeepText(n int, seed uint64) []byte {
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta", "iota", "kappa", "lambda", "mu", "\n"}
+
+	var buf bytes.Buffer
+	for buf.Len() < n {
+		seed = seed*6364136223846793005 + 1442695040888963407
+		buf.WriteString(words[(seed>>33)%uint64(len(words))])
+		buf.WriteByte(' ')
+	}
+
+	return buf.Bytes()[:n]
+}
+
+// modifiedText returns the text of ssdeepText(20000, 1) with ten bytes
+// overwritten in the middle.
+func modifiedText() []byte {
+	data := ssdeepText(20000, 1)
+	copy(data[10000:], "XXXXXXXXXX")
+	return data
+}
+
+func ssdeepDigest(data []byte) string {
+	h := newSSDeep()
+	h.Write(data)
+	return string(h.Sum(nil))
+}
+
+func TestSSDeep(t *testing.T) {
+	// The expected digests are those of the ssdeep tool.
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"Empty", nil, "3::"},
+		{"Zeros", make([]byte, 100000), "3::"},
+		{"Repeated", bytes.Repeat([]byte("ab"), 25000), "3:uy:uy"},
+		{"Short", ssdeepBytes(100, 2), "3:ZVXA/66gLPi6gN7FD8xZ7COm/8FdGkSrmaT:Tkoa6gNR0FCb8LzCZT"},
+		{"Binary", ssdeepBytes(5000, 1), "96:KL4nrqGAxTGN7ewLXV/zpdCLByr5vx5FALEFnA6qk:+UrNeKxqgr5vbeEmzk"},
+		{"Text", ssdeepText(20000, 1), "192:rE2fhU7CgH15y/jOH1s5COM3UkyMH146bcAI6qXxO+o1Cccp1ux+7EP4Q1z0JI1j:6tyW"},
+		{"Modified Text", modifiedText(), "192:rE2fhU7CgH15y/jOH1s5COM3UkyMH146bcAI6qXxO+o1Cccp1ux+7EP4Q1z0JI1h:6tYW"},
+		{"Large", ssdeepText(300000, 3), "3072:/RM8SfUJRVjzkBasJ/dc/nPqh3EkiXSrCnITz:E"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ssdeepDigest(tt.data))
+
+			// The digest does not depend on how the content is written.
+			h := newSSDeep()
+			for data := tt.data; len(data) > 0; {
+				n := min(len(data), 4093)
+				h.Write(data[:n])
+				data = data[n:]
+			}
+			assert.Equal(t, tt.expected, string(h.Sum(nil)))
+			assert.LessOrEqual(t, len(tt.expected), h.Size())
+
+			h.Reset()
+			assert.Equal(t, "3::", string(h.Sum(nil)))
+		})
+	}
+}
+
+func TestSSDeepScore(t *testing.T) {
+	text := ssdeepDigest(ssdeepText(20000, 1))
+
+	score, err := SSDeepScore(text, text)
+	require.NoError(t, err)
+	assert.Equal(t, 100, score)
+
+	score, err = SSDeepScore(text, ssdeepDigest(modifiedText()))
+	require.NoError(t, err)
+	assert.Equal(t, 99, score)
+
+	score, err = SSDeepScore(text, ssdeepDigest(ssdeepText(20000, 2)))
+	require.NoError(t, err)
+	assert.Zero(t, score, "unrelated content")
+
+	score, err = SSDeepScore(text, ssdeepDigest(ssdeepText(300000, 3)))
+	require.NoError(t, err)
+	assert.Zero(t, score, "block sizes too far apart")
+
+	// Digests of adjacent block sizes are compared at the common one.
+	half := ssdeepDigest(ssdeepText(20000, 1)[:6000])
+	assert.Regexp(t, `^96:`, half)
+	score, err = SSDeepScore(half, text)
+	require.NoError(t, err)
+	assert.Greater(t, score, 0)
+	reversed, err := SSDeepScore(text, half)
+	require.NoError(t, err)
+	assert.Equal(t, score, reversed)
+
+	// The file name printed by the ssdeep tool is ignored.
+	score, err = SSDeepScore(text+`,"/tmp/file.txt"`, text)
+	require.NoError(t, err)
+	assert.Equal(t, 100, score)
+
+	for _, invalid := range []string{"", "3:abc", "x:abc:def", "0:abc:def", "3:a:b:c"} {
+		_, err := SSDeepScore(invalid, text)
+		assert.ErrorIs(t, err, errInvalidSSDeep, invalid)
+	}
+}
@@ -0,0 +1,42 @@
+package metaextractor
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// DefaultStabilityDelay is the default delay before an extraction is repeated
+// because the file changed.
+const DefaultStabilityDelay = time.Second
+
+// StabilityOptions configures the detection of files that change during
+// extraction, such as downloads or camera offloads still being written.
+type StabilityOptions struct {
+	// Enabled compares the size and modification time of the file before
+	// and after the external tools have run.
+	Enabled bool
+
+	// Retries is the number of times the extraction is repeated after the
+	// file changed. If the file still changes, Metadata.Unstable is set.
+	Retries int
+
+	// Delay is the time waited before repeating the extraction. Defaults to
+	// DefaultStabilityDelay.
+	Delay time.Duration
+}
+
+// errFileChanged signals that the file changed during an extraction that is
+// repeated.
+var errFileChanged = errors.New("file changed during extraction")
+
+// fileChanged reports whether the size or the modification time of the file
+// differs from info, or the file no longer exists.
+func fileChanged(path string, info os.FileInfo) bool {
+	current, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+
+	return current.Size() != info.Size() || !current.ModTime().Equal(info.ModTime())
+}
@@ -0,0 +1,73 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaExtractor_Stability(t *testing.T) {
+	testCases := []struct {
+		name      string
+		stability StabilityOptions
+		writes    int // Number of detections during which the file grows.
+		calls     int
+		unstable  bool
+	}{
+		{"Disabled", StabilityOptions{}, 5, 1, false},
+		{"Stable", StabilityOptions{Enabled: true}, 0, 1, false},
+		{"Unstable", StabilityOptions{Enabled: true}, 5, 1, true},
+		{"Settles After Retry", StabilityOptions{Enabled: true, Retries: 2, Delay: time.Millisecond}, 1, 2, false},
+		{"Still Growing", StabilityOptions{Enabled: true, Retries: 2, Delay: time.Millisecond}, 5, 3, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "download.part")
+			require.NoError(t, os.WriteFile(path, []byte("partial"), 0o644))
+
+			calls := 0
+			growing := DetectorFunc{
+				DetectorName: "growing",
+				Fn: func(p string) ([]trid.FileType, error) {
+					calls++
+					if calls <= tc.writes {
+						f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0)
+						require.NoError(t, err)
+						_, err = f.WriteString(" more")
+						require.NoError(t, err)
+						require.NoError(t, f.Close())
+					}
+					return nil, nil
+				},
+			}
+
+			me := NewMetaExtractor(Options{PureGo: true, Detectors: []Detector{growing}, Stability: tc.stability})
+
+			metadata, err := me.Extract(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.calls, calls)
+			assert.Equal(t, tc.unstable, metadata.Unstable)
+		})
+	}
+}
+
+func TestFileChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o644))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.False(t, fileChanged(path, info))
+
+	require.NoError(t, os.Chtimes(path, time.Now(), info.ModTime().Add(time.Second)))
+	assert.True(t, fileChanged(path, info))
+
+	require.NoError(t, os.Remove(path))
+	assert.True(t, fileChanged(path, info))
+}
@@ -0,0 +1,207 @@
+package metaextractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// StreamPath is the path under which the content read by ExtractStreamInput
+// is reported in the audit log, and the path by which the command-line tool
+// selects standard input.
+const StreamPath = "-"
+
+// ExtractStreamInput extracts metadata from the content read from r, such as
+// standard input in a pipeline or a character device. If every configured
+// stage can work on a stream (a pure-Go extractor using the built-in
+// signature detector, without image checks, routes, rule actions or
+// quarantine), the content is read once and never stored; otherwise it is
+// spooled to a temporary file, removed before returning. As the content has
+// no file, Name and Time are not set.
+func (me *MetaExtractor) ExtractStreamInput(r io.Reader) (Metadata, error) {
+	if me.initErr != nil {
+		return Metadata{}, me.initErr
+	}
+
+	if me.lifecycle.closed() {
+		return Metadata{}, ErrClosed
+	}
+
+	if me.streamNeedsPath() {
+		return me.ExtractReader(r, "")
+	}
+
+	var trace *stageTrace
+	if me.audit != nil {
+		trace = &stageTrace{}
+	}
+
+	start := time.Now()
+	metadata, err := me.extractStreamContent(r, trace)
+	tagProvenance(&metadata)
+
+	if postErr := me.postProcess(StreamPath, &metadata, trace); postErr != nil {
+		if err == nil {
+			err = postErr
+		} else {
+			err = errors.Join(err, postErr)
+		}
+	}
+
+	if me.audit != nil {
+		if auditErr := me.audit.write(StreamPath, start, trace, metadata, err); auditErr != nil {
+			if err == nil {
+				err = auditErr
+			} else {
+				err = errors.Join(err, auditErr)
+			}
+		}
+	}
+
+	return metadata, err
+}
+
+// streamNeedsPath reports whether a stage requires the content as a file.
+func (me *MetaExtractor) streamNeedsPath() bool {
+	if !me.pureGo || me.imageChecks || me.zipMetadata || len(me.routes) > 0 || me.quarantineOpts.Dir != "" || hasCustomBackend(me.backends) || hasNativeExifBackend(me.backends) {
+		return true
+	}
+
+	for _, d := range me.detectors {
+		switch d.(type) {
+		case signatureDetector, extensionDetector:
+		default:
+			return true
+		}
+	}
+
+	for _, rule := range me.rules {
+		if rule.Action != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExtractReader extracts metadata from the content read from r, such as an
+// upload, as if it were a file with the given name. The content is spooled
+// to a temporary file with the base name of name, so that all stages
+// (including TrID and ExifTool) run and the extension is checked against
+// the detected type; the file is removed before returning. Name is set
+// from name, and Time is not set. The content is reported under name in
+// the audit log, or under StreamPath if name is empty.
+func (me *MetaExtractor) ExtractReader(r io.Reader, name string) (Metadata, error) {
+	return me.ExtractReaderContext(context.Background(), r, name)
+}
+
+// ExtractReaderContext is like ExtractReader, but aborts the extraction when
+// ctx is done (see ExtractContext).
+func (me *MetaExtractor) ExtractReaderContext(ctx context.Context, r io.Reader, name string) (Metadata, error) {
+	if me.initErr != nil {
+		return Metadata{}, me.initErr
+	}
+
+	auditPath, spoolName := name, name
+	if name == "" {
+		auditPath, spoolName = StreamPath, "content"
+	}
+
+	tmpFile, cleanup, err := spoolEntry(r, spoolName)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer cleanup()
+
+	metadata, err := me.extractFile(withFileName(ctx, entryFileName(spoolName)), tmpFile, auditPath, nil)
+
+	// The times are those of the temporary file.
+	metadata.Time = FileTime{}
+	if name == "" {
+		metadata.Name, metadata.RawName = "", ""
+	}
+
+	return metadata, err
+}
+
+// ExtractBytes is like ExtractReader, but extracts metadata from an
+// in-memory buffer.
+func (me *MetaExtractor) ExtractBytes(b []byte, name string) (Metadata, error) {
+	return me.ExtractReader(bytes.NewReader(b), name)
+}
+
+// extractStreamContent runs the stages working on a stream: the content
+// scan, the built-in signature detection and the rules.
+func (me *MetaExtractor) extractStreamContent(r io.Reader, trace *stageTrace) (Metadata, error) {
+	var metadata Metadata
+	if me.provenance {
+		metadata.Provenance = make(map[string]string)
+	}
+
+	// The whole stream is read to determine its size, and the signature
+	// detector needs the head even if no sample is kept.
+	opts := me.scanOpts
+	opts.headSize = max(opts.headSize, signatureHeadSize)
+
+	start := time.Now()
+	scan, err := scanReader(r, opts)
+	trace.done("scan", start)
+
+	if err != nil {
+		return metadata, err
+	}
+
+	metadata.Size = scan.size
+	metadata.setSource("Size", SourceContent)
+	if metadata.Size == 0 {
+		if me.failEmpty {
+			return metadata, ErrEmptyFile
+		}
+		metadata.Kind = KindEmpty
+	}
+
+	me.setScan(&metadata, scan)
+
+	var detected detection
+	if metadata.Kind != KindEmpty && hasTridBackend(me.backends) {
+		start := time.Now()
+		detected, err = me.detectTypes(context.Background(), "", scan.head)
+		trace.done("detect", start)
+
+		if err := me.stageError(&metadata, trace, err); err != nil {
+			return metadata, err
+		}
+	}
+
+	metadata.Types = withMimeTypes(detected.types)
+	metadata.Detector = detected.detector
+	metadata.Warnings = append(metadata.Warnings, detected.warnings...)
+	metadata.BestType = fuseTypes(detected.votes)
+	mimeType, mimeSource := contentMimeType("", metadata, scan.head)
+	metadata.MimeType = mimeType
+	metadata.setSource("MimeType", mimeSource)
+	if metadata.Kind == "" {
+		metadata.Kind = typeKind(metadata)
+	}
+	metadata.Exif = ExifMetadata{}
+
+	if len(me.rules) > 0 {
+		start := time.Now()
+		err := me.applyRules("", &metadata)
+		trace.done("rules", start)
+
+		if err != nil {
+			return metadata, err
+		}
+	}
+
+	if me.strict {
+		if err := validate(metadata); err != nil {
+			return metadata, err
+		}
+	}
+
+	return metadata, nil
+}
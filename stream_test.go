@@ -0,0 +1,140 @@
+package metaextractor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// onceReader is a reader that fails if it is read after reaching the end,
+// like a pipe that cannot be rewound.
+type onceReader struct {
+	r   io.Reader
+	eof bool
+}
+
+func (o *onceReader) Read(p []byte) (int, error) {
+	if o.eof {
+		return 0, errors.New("read after EOF")
+	}
+
+	n, err := o.r.Read(p)
+	if err == io.EOF {
+		o.eof = true
+	}
+	return n, err
+}
+
+func TestExtractStreamInput(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+
+	fromFile, err := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"sha256"}}).Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+
+	t.Run("Streamed", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"sha256"}, Entropy: true, SampleSize: 16})
+		require.False(t, me.streamNeedsPath())
+
+		metadata, err := me.ExtractStreamInput(&onceReader{r: bytes.NewReader(data)})
+		require.NoError(t, err)
+
+		assert.Empty(t, metadata.Name)
+		assert.Equal(t, int64(len(data)), metadata.Size)
+		assert.Equal(t, fromFile.Hashes, metadata.Hashes)
+		assert.Equal(t, fromFile.Types, metadata.Types)
+		assert.Equal(t, data[:16], metadata.Head)
+		assert.Equal(t, data[len(data)-16:], metadata.Tail)
+		assert.Greater(t, metadata.Entropy, 0.0)
+		assert.True(t, metadata.Time.ModTime.IsZero())
+	})
+
+	t.Run("Spooled", func(t *testing.T) {
+		me := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"sha256"}, ImageChecks: true})
+		require.True(t, me.streamNeedsPath())
+
+		metadata, err := me.ExtractStreamInput(&onceReader{r: bytes.NewReader(data)})
+		require.NoError(t, err)
+
+		assert.Empty(t, metadata.Name)
+		assert.Equal(t, int64(len(data)), metadata.Size)
+		assert.Equal(t, fromFile.Hashes, metadata.Hashes)
+		assert.Equal(t, fromFile.Types, metadata.Types)
+		assert.True(t, metadata.Time.ModTime.IsZero())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{PureGo: true}).ExtractStreamInput(bytes.NewReader(nil))
+		require.NoError(t, err)
+		assert.Equal(t, KindEmpty, metadata.Kind)
+
+		_, err = NewMetaExtractor(Options{PureGo: true, FailEmpty: true}).ExtractStreamInput(bytes.NewReader(nil))
+		assert.ErrorIs(t, err, ErrEmptyFile)
+	})
+
+	t.Run("Read Error", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		_, err := NewMetaExtractor(Options{PureGo: true}).ExtractStreamInput(io.MultiReader(bytes.NewReader(data), &failingReader{err: errRead}))
+		assert.ErrorIs(t, err, errRead)
+	})
+}
+
+// failingReader is a reader returning err.
+type failingReader struct {
+	err error
+}
+
+func (f *failingReader) Read([]byte) (int, error) {
+	return 0, f.err
+}
+
+func TestExtractReader(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+
+	me := NewMetaExtractor(Options{PureGo: true, Hashes: []string{"sha256"}})
+	fromFile, err := me.Extract(filepath.Join("testdata", "sample.doc"))
+	require.NoError(t, err)
+
+	t.Run("Reader", func(t *testing.T) {
+		metadata, err := me.ExtractReader(&onceReader{r: bytes.NewReader(data)}, "uploads/report.doc")
+		require.NoError(t, err)
+
+		assert.Equal(t, "report.doc", metadata.Name)
+		assert.Equal(t, ".doc", metadata.Extension)
+		assert.Equal(t, int64(len(data)), metadata.Size)
+		assert.Equal(t, fromFile.Hashes, metadata.Hashes)
+		assert.Equal(t, fromFile.Types, metadata.Types)
+		assert.True(t, metadata.ExtMismatch)
+		assert.Equal(t, "report.pdf", metadata.SuggestedName)
+		assert.True(t, metadata.Time.ModTime.IsZero())
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		metadata, err := me.ExtractBytes(data, "report.pdf")
+		require.NoError(t, err)
+		assert.Equal(t, "report.pdf", metadata.Name)
+		assert.False(t, metadata.ExtMismatch)
+		assert.Equal(t, fromFile.Types, metadata.Types)
+	})
+
+	t.Run("No Name", func(t *testing.T) {
+		metadata, err := me.ExtractBytes(data, "")
+		require.NoError(t, err)
+		assert.Empty(t, metadata.Name)
+		assert.Empty(t, metadata.Extension)
+		assert.Equal(t, fromFile.Types, metadata.Types)
+	})
+
+	t.Run("Read Error", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		_, err := me.ExtractReader(&failingReader{err: errRead}, "a.txt")
+		assert.ErrorIs(t, err, errRead)
+	})
+}
@@ -0,0 +1,289 @@
+package metaextractor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultSummaryTextSize is the maximum size in bytes of the document text
+// passed to the summarizer if SummaryOptions.MaxTextSize is not set.
+const DefaultSummaryTextSize = 64 << 10
+
+// SummaryOptions configures the summarization of documents into
+// Metadata.Summary, e.g. for search-result previews.
+type SummaryOptions struct {
+	// Summarizer summarizes the text of text and document files, e.g. with
+	// a local model or an API. Documents are only summarized if it is set.
+	Summarizer Summarizer
+
+	// TextExtractor extracts the text of documents (e.g., with pdftotext).
+	// If nil, only text files are summarized, whose content is read as is.
+	TextExtractor TextExtractor
+
+	// MaxTextSize is the maximum size in bytes of the text passed to the
+	// summarizer; longer text is truncated. Defaults to
+	// DefaultSummaryTextSize.
+	MaxTextSize int
+}
+
+// Summarizer summarizes the text of documents.
+type Summarizer interface {
+	// Name returns the name of the summarizer, used in errors and in the
+	// stages of the audit log.
+	Name() string
+
+	// Summarize returns a short summary and the keywords of the document.
+	// It should return ctx.Err() when ctx is done.
+	Summarize(ctx context.Context, doc Document) (*Summary, error)
+}
+
+// SummarizerFunc adapts an ordinary function to the Summarizer interface.
+type SummarizerFunc struct {
+	// SummarizerName is the name returned by Name.
+	SummarizerName string
+
+	// Fn is the function invoked by Summarize.
+	Fn func(ctx context.Context, doc Document) (*Summary, error)
+}
+
+// Name returns the name of the summarizer.
+func (sf SummarizerFunc) Name() string {
+	return sf.SummarizerName
+}
+
+// Summarize calls sf.Fn(ctx, doc).
+func (sf SummarizerFunc) Summarize(ctx context.Context, doc Document) (*Summary, error) {
+	return sf.Fn(ctx, doc)
+}
+
+// TextExtractor extracts the text of documents for the summarizer.
+type TextExtractor interface {
+	// ExtractText returns the plain text of the file, or an empty string if
+	// the file has no text (e.g., a scanned PDF). The metadata gathered so
+	// far is available for inspection.
+	ExtractText(ctx context.Context, filePath string, metadata Metadata) (string, error)
+}
+
+// TextExtractorFunc adapts an ordinary function to the TextExtractor
+// interface.
+type TextExtractorFunc func(ctx context.Context, filePath string, metadata Metadata) (string, error)
+
+// ExtractText calls f(ctx, filePath, metadata).
+func (f TextExtractorFunc) ExtractText(ctx context.Context, filePath string, metadata Metadata) (string, error) {
+	return f(ctx, filePath, metadata)
+}
+
+// Document is the text of a document passed to a Summarizer.
+type Document struct {
+	// Text is the text of the document, up to SummaryOptions.MaxTextSize
+	// bytes.
+	Text string
+
+	// Truncated reports whether Text was truncated.
+	Truncated bool
+
+	// Language is the ISO 639-1 code of the language of the document (e.g.,
+	// "en"), taken from its metadata or guessed from the text, or empty if
+	// it is unknown. Summarizers should summarize in this language.
+	Language string
+
+	// MimeType is the MIME type of the document.
+	MimeType string
+}
+
+// Summary is a short summary of a document.
+type Summary struct {
+	// Text is the summary.
+	Text string `json:"text"`
+
+	// Keywords are the keywords of the document.
+	Keywords []string `json:"keywords,omitempty"`
+
+	// Language is the language of the document (see Document.Language).
+	Language string `json:"language,omitempty"`
+}
+
+// languageTags are the EXIF tags declaring the language of documents.
+var languageTags = []string{"Language", "ContentLanguage", "DocumentLanguage"}
+
+// summarizeStage extracts the text of text and document files and sets
+// Metadata.Summary.
+func (me *MetaExtractor) summarizeStage(ctx context.Context, toolPath string, metadata *Metadata, trace *stageTrace) error {
+	opts := me.summary
+	if opts.Summarizer == nil || toolPath == "" {
+		return nil
+	}
+
+	kind := mimeTypeKind(metadata.MimeType)
+	if kind != KindText && kind != KindDocument {
+		return nil
+	}
+
+	maxSize := opts.MaxTextSize
+	if maxSize <= 0 {
+		maxSize = DefaultSummaryTextSize
+	}
+
+	start := time.Now()
+	text, err := documentText(ctx, opts.TextExtractor, toolPath, kind, *metadata, maxSize)
+	trace.done("text", start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error extracting text: %w", err))
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	doc := Document{Text: text, MimeType: metadata.MimeType}
+	if len(text) > maxSize {
+		doc.Text, doc.Truncated = truncateText(text, maxSize), true
+	}
+
+	doc.Language = documentLanguage(metadata.Exif, metadata.exifPrecedence)
+	if doc.Language == "" {
+		doc.Language = detectLanguage(doc.Text)
+	}
+
+	start = time.Now()
+	summary, err := opts.Summarizer.Summarize(ctx, doc)
+	trace.done("summarize:"+opts.Summarizer.Name(), start)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return me.stageError(metadata, trace, fmt.Errorf("error running summarizer %q: %w", opts.Summarizer.Name(), err))
+	}
+
+	if summary == nil {
+		return nil
+	}
+
+	if summary.Language == "" {
+		summary.Language = doc.Language
+	}
+
+	metadata.Summary = summary
+	metadata.setSource("Summary", opts.Summarizer.Name())
+
+	return nil
+}
+
+// documentText returns the text of the file: the result of the text
+// extractor if set, or the content of text files. Text files are read up to
+// one byte more than maxSize, so that their text is reported as truncated.
+func documentText(ctx context.Context, extractor TextExtractor, filePath string, kind Kind, metadata Metadata, maxSize int) (string, error) {
+	if extractor != nil {
+		return extractor.ExtractText(ctx, filePath, metadata)
+	}
+
+	if kind != KindText {
+		return "", nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, int64(maxSize)+1))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToValidUTF8(string(data), "�"), nil
+}
+
+// truncateText truncates the text to at most size bytes, at a word boundary
+// if possible.
+func truncateText(text string, size int) string {
+	for size > 0 && !utf8.RuneStart(text[size]) {
+		size--
+	}
+	text = text[:size]
+
+	if i := strings.LastIndexFunc(text, unicode.IsSpace); i > len(text)/2 {
+		text = text[:i]
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// documentLanguage returns the language declared in the EXIF metadata of
+// the document, as a lowercase language code without region (e.g., "en"
+// for "en-US").
+func documentLanguage(exif ExifMetadata, precedence []string) string {
+	for _, tag := range languageTags {
+		s, ok := exifValue(exif, tag, precedence...).(string)
+		if !ok {
+			continue
+		}
+
+		lang, _, _ := strings.Cut(strings.TrimSpace(s), "-")
+		lang, _, _ = strings.Cut(lang, "_")
+		if lang = strings.ToLower(lang); lang != "" && lang != "x" {
+			return lang
+		}
+	}
+
+	return ""
+}
+
+// languageStopwords are frequent words of the languages guessed by
+// detectLanguage.
+var languageStopwords = map[string][]string{
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "ein", "zu", "sich", "auf"},
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "for", "with", "this", "are"},
+	"es": {"el", "los", "las", "y", "es", "una", "por", "con", "para", "del", "como", "pero"},
+	"fr": {"le", "les", "et", "des", "est", "une", "pour", "dans", "qui", "pas", "sur", "au"},
+	"hu": {"a", "az", "és", "hogy", "nem", "egy", "van", "meg", "már", "csak", "volt", "ez"},
+	"it": {"il", "di", "che", "e", "per", "non", "una", "sono", "della", "gli", "anche", "nel"},
+	"nl": {"het", "een", "en", "van", "niet", "dat", "met", "voor", "zijn", "op", "ook", "maar"},
+	"pt": {"o", "os", "que", "do", "da", "em", "um", "para", "não", "com", "uma", "mais"},
+}
+
+// detectLanguage guesses the language of the text by counting the
+// stopwords of each language. It returns an empty string if there are less
+// than three stopwords or several languages have the most.
+func detectLanguage(text string) string {
+	counts := make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		counts[w]++
+	}
+
+	best, bestScore, tie := "", 0, false
+	for lang, stopwords := range languageStopwords {
+		score := 0
+		for _, w := range stopwords {
+			score += counts[w]
+		}
+
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = lang, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+
+	if bestScore < 3 || tie {
+		return ""
+	}
+
+	return best
+}
@@ -0,0 +1,125 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaExtractor_Summary(t *testing.T) {
+	text := "The quarterly report covers the results of the sales team and the plans for the next year."
+	notes := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(notes, []byte(text+"\n"), 0o644))
+
+	var docs []Document
+	summarizer := SummarizerFunc{
+		SummarizerName: "test",
+		Fn: func(ctx context.Context, doc Document) (*Summary, error) {
+			docs = append(docs, doc)
+			return &Summary{Text: "Quarterly sales report.", Keywords: []string{"sales", "report"}}, nil
+		},
+	}
+
+	newExtractor := func(opts SummaryOptions) *MetaExtractor {
+		opts.Summarizer = summarizer
+		return NewMetaExtractor(Options{DisableTrid: true, DisableExif: true, Provenance: true, Summary: opts})
+	}
+
+	t.Run("Text", func(t *testing.T) {
+		docs = nil
+		metadata, err := newExtractor(SummaryOptions{}).Extract(notes)
+		require.NoError(t, err)
+		assert.Equal(t, &Summary{Text: "Quarterly sales report.", Keywords: []string{"sales", "report"}, Language: "en"}, metadata.Summary)
+		assert.Equal(t, "test", metadata.Provenance["Summary"])
+		assert.Equal(t, []Document{{Text: text, Language: "en", MimeType: metadata.MimeType}}, docs)
+
+		// Documents are only summarized with a text extractor.
+		metadata, err = newExtractor(SummaryOptions{}).Extract(filepath.Join("testdata", "sample.doc"))
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Summary)
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		docs = nil
+		_, err := newExtractor(SummaryOptions{MaxTextSize: 30}).Extract(notes)
+		require.NoError(t, err)
+		require.Len(t, docs, 1)
+		assert.Equal(t, "The quarterly report covers", docs[0].Text)
+		assert.True(t, docs[0].Truncated)
+	})
+
+	t.Run("TextExtractor", func(t *testing.T) {
+		docs = nil
+		extractor := TextExtractorFunc(func(ctx context.Context, filePath string, metadata Metadata) (string, error) {
+			if metadata.Name == "sample.doc" {
+				return "", errors.New("no converter")
+			}
+			return "Der Bericht ist nicht fertig und die Zahlen sind mit der Planung abgestimmt.", nil
+		})
+
+		metadata, err := newExtractor(SummaryOptions{TextExtractor: extractor}).Extract(notes)
+		require.NoError(t, err)
+		assert.Equal(t, "de", metadata.Summary.Language)
+
+		_, err = newExtractor(SummaryOptions{TextExtractor: extractor}).Extract(filepath.Join("testdata", "sample.doc"))
+		assert.ErrorContains(t, err, "error extracting text: no converter")
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		me := NewMetaExtractor(Options{
+			DisableTrid: true,
+			DisableExif: true,
+			BestEffort:  true,
+			Summary: SummaryOptions{Summarizer: SummarizerFunc{
+				SummarizerName: "api",
+				Fn: func(ctx context.Context, doc Document) (*Summary, error) {
+					return nil, errors.New("quota exceeded")
+				},
+			}},
+		})
+
+		metadata, err := me.Extract(notes)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Summary)
+		require.Len(t, metadata.Warnings, 1)
+		assert.Contains(t, metadata.Warnings[0], `error running summarizer "api": quota exceeded`)
+	})
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"The cat is in the garden and it is sleeping.", "en"},
+		{"Le chat est dans le jardin et il dort pour une heure.", "fr"},
+		{"A macska a kertben alszik, és nem akar felkelni, hogy egyen.", "hu"},
+		{"Il gatto non è in casa per una volta, che peccato.", "it"},
+		{"Hello world", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, detectLanguage(tt.text), tt.text)
+	}
+}
+
+func TestDocumentLanguage(t *testing.T) {
+	assert.Equal(t, "en", documentLanguage(ExifMetadata{"Language": "en-US"}, nil))
+	assert.Equal(t, "pt", documentLanguage(ExifMetadata{"ContentLanguage": "pt_BR"}, nil))
+	assert.Equal(t, "", documentLanguage(ExifMetadata{"Language": "x-default"}, nil))
+	assert.Equal(t, "", documentLanguage(nil, nil))
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "árvíztűrő", truncateText("árvíztűrő tükörfúrógép", 13))
+	assert.Equal(t, "árvízt", truncateText(strings.Repeat("árvíztűrő", 3), 9))
+}
@@ -0,0 +1,191 @@
+package metaextractor
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EventOptions configures how ClusterEvents groups results into events.
+type EventOptions struct {
+	// MaxGap is the longest time between consecutive photos or videos of an
+	// event. Defaults to DefaultEventGap.
+	MaxGap time.Duration
+
+	// MaxDistance is the largest distance in meters between consecutive
+	// geotagged photos or videos of an event. Defaults to
+	// DefaultEventDistance; a negative value ignores the GPS coordinates.
+	MaxDistance float64
+}
+
+const (
+	// DefaultEventGap is the default EventOptions.MaxGap.
+	DefaultEventGap = 3 * time.Hour
+
+	// DefaultEventDistance is the default EventOptions.MaxDistance.
+	DefaultEventDistance = 1000
+)
+
+// earthRadius is the mean radius of the Earth in meters.
+const earthRadius = 6371008.8
+
+// geoPoint is a position in signed decimal degrees.
+type geoPoint struct {
+	lat, lon float64
+}
+
+// timelineEntry is a result placed on the timeline.
+type timelineEntry struct {
+	index int
+	time  time.Time
+	point *geoPoint
+}
+
+// ClusterEvents groups the results into events, such as a trip or a party,
+// by their capture time and GPS coordinates, as a building block for album
+// generation. Sorted by capture time, a result starts a new event if it was
+// taken more than MaxGap after the previous one, or more than MaxDistance
+// away from the last geotagged result of the event; results without GPS
+// coordinates are grouped by time only.
+//
+// It returns the event of each result, numbered from 0 in chronological
+// order, or -1 for the results that failed or have no capture time. The
+// capture time is the original date of photos (see Metadata.ExifTimes), or
+// else Metadata.BestCreatedAt.
+func ClusterEvents(results []Result, opts EventOptions) []int {
+	if opts.MaxGap <= 0 {
+		opts.MaxGap = DefaultEventGap
+	}
+	if opts.MaxDistance == 0 {
+		opts.MaxDistance = DefaultEventDistance
+	}
+
+	events := make([]int, len(results))
+	var entries []timelineEntry
+
+	for i, r := range results {
+		events[i] = -1
+		if r.Err != nil || r.Dir != nil || r.Bag != nil {
+			continue
+		}
+
+		t, ok := captureTime(r.Metadata)
+		if !ok && r.Metadata.BestCreatedAt != nil {
+			t, ok = r.Metadata.BestCreatedAt.Time, true
+		}
+		if !ok {
+			continue
+		}
+
+		entry := timelineEntry{index: i, time: t}
+		if opts.MaxDistance > 0 {
+			if p, ok := gpsPosition(r.Metadata.Exif, r.Metadata.exifPrecedence); ok {
+				entry.point = &p
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].time.Before(entries[b].time)
+	})
+
+	event := -1
+	var last time.Time
+	var lastPoint *geoPoint
+
+	for _, e := range entries {
+		split := event < 0 || e.time.Sub(last) > opts.MaxGap
+		if !split && e.point != nil && lastPoint != nil {
+			split = distance(*lastPoint, *e.point) > opts.MaxDistance
+		}
+
+		if split {
+			event++
+			lastPoint = nil
+		}
+		if e.point != nil {
+			lastPoint = e.point
+		}
+		last = e.time
+
+		events[e.index] = event
+	}
+
+	return events
+}
+
+// gpsPosition returns the GPS coordinates recorded in the EXIF metadata,
+// either in GPSPosition or in GPSLatitude and GPSLongitude with their
+// optional hemisphere references.
+func gpsPosition(exif ExifMetadata, precedence []string) (geoPoint, bool) {
+	if s, ok := exifValue(exif, "GPSPosition", precedence...).(string); ok {
+		if lat, lon, ok := strings.Cut(s, ","); ok {
+			return geoCoordinates(lat, lon, "", "")
+		}
+	}
+
+	return geoCoordinates(
+		exifValue(exif, "GPSLatitude", precedence...),
+		exifValue(exif, "GPSLongitude", precedence...),
+		stringValue(exifValue(exif, "GPSLatitudeRef", precedence...)),
+		stringValue(exifValue(exif, "GPSLongitudeRef", precedence...)),
+	)
+}
+
+// geoCoordinates parses a latitude and a longitude. The references (e.g.,
+// "S" or "South") apply to coordinates without a hemisphere of their own.
+func geoCoordinates(lat, lon interface{}, latRef, lonRef string) (geoPoint, bool) {
+	parse := func(v interface{}, ref string) (float64, bool) {
+		var f float64
+		switch v := v.(type) {
+		case float64:
+			f = v
+		case string:
+			var ok bool
+			if f, ok = parseCoordinate(v); !ok {
+				return 0, false
+			}
+			if s := strings.TrimSpace(v); strings.ContainsAny(s[len(s)-1:], "NSEW") {
+				return f, true
+			}
+		default:
+			return 0, false
+		}
+
+		if ref = strings.ToUpper(strings.TrimSpace(ref)); strings.HasPrefix(ref, "S") || strings.HasPrefix(ref, "W") {
+			f = -math.Abs(f)
+		}
+		return f, true
+	}
+
+	la, ok := parse(lat, latRef)
+	if !ok || la < -90 || la > 90 {
+		return geoPoint{}, false
+	}
+
+	lo, ok := parse(lon, lonRef)
+	if !ok || lo < -180 || lo > 180 {
+		return geoPoint{}, false
+	}
+
+	// Cameras without a fix often record 0, 0.
+	if la == 0 && lo == 0 {
+		return geoPoint{}, false
+	}
+
+	return geoPoint{lat: la, lon: lo}, true
+}
+
+// distance returns the great-circle distance between two points in meters.
+func distance(a, b geoPoint) float64 {
+	rad := math.Pi / 180
+	dLat := (b.lat - a.lat) * rad
+	dLon := (b.lon - a.lon) * rad
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.lat*rad)*math.Cos(b.lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(min(h, 1)))
+}
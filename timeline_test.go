@@ -0,0 +1,70 @@
+package metaextractor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterEvents(t *testing.T) {
+	day := time.Date(2024, 7, 20, 10, 0, 0, 0, time.UTC)
+	photo := func(taken time.Time, exif ExifMetadata) Result {
+		return Result{Metadata: Metadata{
+			Exif:      exif,
+			ExifTimes: map[string]time.Time{"EXIF:DateTimeOriginal": taken},
+		}}
+	}
+	paris := ExifMetadata{"GPSLatitude": `48 deg 51' 29.60" N`, "GPSLongitude": `2 deg 17' 40.20" E`}
+	versailles := ExifMetadata{"GPSPosition": `48 deg 48' 17.00" N, 2 deg 7' 13.00" E`}
+
+	results := []Result{
+		photo(day.Add(time.Hour), nil),
+		photo(day, paris),
+		photo(day.Add(30*time.Minute), ExifMetadata{"GPSLatitude": 48.8581, "GPSLongitude": 2.2946}),
+		photo(day.Add(2*time.Hour), versailles),
+		{Metadata: Metadata{BestCreatedAt: &BestCreatedAt{Time: day.Add(2*time.Hour + 10*time.Minute)}}},
+		photo(day.Add(12*time.Hour), versailles),
+		{Metadata: Metadata{Exif: paris}},
+		{Err: errors.New("failed")},
+	}
+
+	assert.Equal(t, []int{0, 0, 0, 1, 1, 2, -1, -1}, ClusterEvents(results, EventOptions{}))
+	assert.Equal(t, []int{0, 0, 0, 0, 0, 1, -1, -1}, ClusterEvents(results, EventOptions{MaxDistance: -1}))
+	assert.Equal(t, []int{0, 0, 0, 0, 0, 0, -1, -1}, ClusterEvents(results, EventOptions{MaxGap: 24 * time.Hour, MaxDistance: 50000}))
+	assert.Empty(t, ClusterEvents(nil, EventOptions{}))
+}
+
+func TestGPSPosition(t *testing.T) {
+	tests := []struct {
+		name string
+		exif ExifMetadata
+		want geoPoint
+		ok   bool
+	}{
+		{"Text", ExifMetadata{"GPSLatitude": `33 deg 51' 54.00" S`, "GPSLongitude": `151 deg 12' 36.00" E`}, geoPoint{lat: -33.865, lon: 151.21}, true},
+		{"References", ExifMetadata{"GPSLatitude": 33.865, "GPSLatitudeRef": "South", "GPSLongitude": 151.21, "GPSLongitudeRef": "E"}, geoPoint{lat: -33.865, lon: 151.21}, true},
+		{"Position", ExifMetadata{"Composite:GPSPosition": "-33.865, 151.21"}, geoPoint{lat: -33.865, lon: 151.21}, true},
+		{"NoFix", ExifMetadata{"GPSLatitude": 0.0, "GPSLongitude": 0.0}, geoPoint{}, false},
+		{"OutOfRange", ExifMetadata{"GPSLatitude": 95.0, "GPSLongitude": 10.0}, geoPoint{}, false},
+		{"Missing", ExifMetadata{"GPSLatitude": 33.865}, geoPoint{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := gpsPosition(tt.exif, nil)
+			assert.Equal(t, tt.ok, ok)
+			assert.InDelta(t, tt.want.lat, got.lat, 1e-9)
+			assert.InDelta(t, tt.want.lon, got.lon, 1e-9)
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	paris := geoPoint{lat: 48.8566, lon: 2.3522}
+	london := geoPoint{lat: 51.5074, lon: -0.1278}
+
+	assert.InDelta(t, 343500, distance(paris, london), 1000)
+	assert.Zero(t, distance(paris, paris))
+}
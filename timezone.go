@@ -0,0 +1,213 @@
+package metaextractor
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exifTimeLayouts are the date/time formats used by ExifTool, with and
+// without a time zone: the EXIF format with optional subseconds, the same
+// without seconds (e.g., in some QuickTime and IPTC dates), and ISO 8601
+// (e.g., with the -d "%Y-%m-%dT%H:%M:%S" option).
+var exifTimeLayouts = []struct {
+	layout string
+	zoned  bool
+}{
+	{"2006:01:02 15:04:05.999999999Z07:00", true},
+	{"2006:01:02 15:04:05.999999999", false},
+	{"2006:01:02 15:04Z07:00", true},
+	{"2006:01:02 15:04", false},
+	{"2006-01-02T15:04:05.999999999Z07:00", true},
+	{"2006-01-02T15:04:05.999999999", false},
+}
+
+// exifTimeOffsets maps date/time tags to the EXIF 2.31 tags holding their
+// time zone offset.
+var exifTimeOffsets = map[string]string{
+	"DateTimeOriginal": "OffsetTimeOriginal",
+	"CreateDate":       "OffsetTimeDigitized",
+	"ModifyDate":       "OffsetTime",
+}
+
+// exifSubSecTags maps date/time tags to the EXIF tags holding their
+// fractional seconds as digits (e.g., "123" for 0.123 s).
+var exifSubSecTags = map[string]string{
+	"DateTimeOriginal": "SubSecTimeOriginal",
+	"CreateDate":       "SubSecTimeDigitized",
+	"ModifyDate":       "SubSecTime",
+}
+
+// timeOptions configures how file times and EXIF times are interpreted.
+type timeOptions struct {
+	utc         bool
+	location    *time.Location
+	gpsTimeZone bool
+
+	// precedence resolves tags present in several groups (see
+	// Options.ExifPrecedence).
+	precedence []string
+}
+
+// UTC returns the file times converted to UTC. Unavailable (zero) times stay
+// zero.
+func (ft FileTime) UTC() FileTime {
+	return FileTime{
+		ModTime:    utcTime(ft.ModTime),
+		AccessTime: utcTime(ft.AccessTime),
+		ChangeTime: utcTime(ft.ChangeTime),
+		BirthTime:  utcTime(ft.BirthTime),
+	}
+}
+
+func utcTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC()
+}
+
+// fileTime applies the time options to file times.
+func (o timeOptions) fileTime(ft FileTime) FileTime {
+	if o.utc {
+		return ft.UTC()
+	}
+	return ft
+}
+
+// parseExifTime parses an ExifTool date/time value. It reports whether the
+// value carries a time zone; naive values are returned in UTC.
+func parseExifTime(value string) (t time.Time, zoned bool, ok bool) {
+	value = strings.TrimSpace(value)
+
+	for _, l := range exifTimeLayouts {
+		if t, err := time.Parse(l.layout, value); err == nil {
+			return t, l.zoned, !t.IsZero()
+		}
+	}
+
+	return time.Time{}, false, false
+}
+
+// parseSubSec parses the digits of a subsecond tag (e.g., "123" or "05")
+// as a fraction of a second.
+func parseSubSec(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || len(value) > 9 || strings.Trim(value, "0123456789") != "" {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(value + strings.Repeat("0", 9-len(value)))
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(n), true
+}
+
+// parseOffset parses a time zone offset such as "+02:00".
+func parseOffset(value string) (*time.Location, bool) {
+	t, err := time.Parse("-07:00", strings.TrimSpace(value))
+	if err != nil {
+		return nil, false
+	}
+
+	_, offset := t.Zone()
+	return time.FixedZone(value, offset), true
+}
+
+// gpsLocation derives a time zone from the difference between the naive
+// capture time and the GPS time stamp, which is always in UTC. The offset is
+// rounded to 15 minutes.
+func gpsLocation(exif ExifMetadata, precedence []string) (*time.Location, bool) {
+	gps, zoned, ok := parseExifTime(stringValue(exifValue(exif, "GPSDateTime", precedence...)))
+	if !ok || !zoned {
+		return nil, false
+	}
+
+	local, zoned, ok := parseExifTime(stringValue(exifValue(exif, "DateTimeOriginal", precedence...)))
+	if !ok || zoned {
+		return nil, false
+	}
+
+	offset := local.Sub(gps).Round(15 * time.Minute)
+	if math.Abs(offset.Hours()) > 14 {
+		return nil, false
+	}
+
+	return time.FixedZone("GPS", int(offset.Seconds())), true
+}
+
+// exifTimes parses the date/time values of the EXIF metadata. Values without
+// a time zone are interpreted using, in order, the matching EXIF offset tag,
+// the GPS-derived time zone (if enabled) and the configured location. Values
+// without subseconds take them from the matching subsecond tag, if present.
+func (o timeOptions) exifTimes(exif ExifMetadata) map[string]time.Time {
+	location := o.location
+	if location == nil {
+		location = time.UTC
+	}
+
+	if o.gpsTimeZone {
+		if loc, ok := gpsLocation(exif, o.precedence); ok {
+			location = loc
+		}
+	}
+
+	result := make(map[string]time.Time)
+
+	add := func(key string, value interface{}, group ExifMetadata) {
+		s, isString := value.(string)
+		if !isString {
+			return
+		}
+
+		t, zoned, ok := parseExifTime(s)
+		if !ok {
+			return
+		}
+
+		_, tag, grouped := strings.Cut(key, ":")
+		if !grouped {
+			tag = key
+		}
+
+		if subSecTag, ok := exifSubSecTags[tag]; ok && t.Nanosecond() == 0 {
+			if d, ok := parseSubSec(stringValue(exifValue(group, subSecTag, o.precedence...))); ok {
+				t = t.Add(d)
+			}
+		}
+
+		if !zoned {
+			loc := location
+
+			if offsetTag, ok := exifTimeOffsets[tag]; ok {
+				if offset, ok := parseOffset(stringValue(exifValue(group, offsetTag, o.precedence...))); ok {
+					loc = offset
+				}
+			}
+
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+		}
+
+		if o.utc {
+			t = t.UTC()
+		}
+
+		result[key] = t
+	}
+
+	for key, value := range exif {
+		if nested, ok := value.(map[string]interface{}); ok {
+			for tag, v := range nested {
+				add(key+":"+tag, v, ExifMetadata(nested))
+			}
+			continue
+		}
+
+		add(key, value, exif)
+	}
+
+	return result
+}
@@ -0,0 +1,134 @@
+package metaextractor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTime_UTC(t *testing.T) {
+	loc := time.FixedZone("", 2*3600)
+	ft := FileTime{ModTime: time.Date(2024, 1, 2, 12, 0, 0, 0, loc)}
+
+	utc := ft.UTC()
+	assert.Equal(t, time.UTC, utc.ModTime.Location())
+	assert.True(t, utc.ModTime.Equal(ft.ModTime))
+	assert.True(t, utc.BirthTime.IsZero())
+}
+
+func TestParseExifTime(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected time.Time
+		zoned    bool
+		ok       bool
+	}{
+		{"Naive", "2024:01:02 10:20:30", time.Date(2024, 1, 2, 10, 20, 30, 0, time.UTC), false, true},
+		{"Sub-Seconds", "2024:01:02 10:20:30.25", time.Date(2024, 1, 2, 10, 20, 30, 250000000, time.UTC), false, true},
+		{"Offset", "2024:01:02 10:20:30+02:00", time.Date(2024, 1, 2, 8, 20, 30, 0, time.UTC), true, true},
+		{"UTC", "2024:01:02 10:20:30Z", time.Date(2024, 1, 2, 10, 20, 30, 0, time.UTC), true, true},
+		{"Offset Sub-Seconds", "2024:01:02 10:20:30.5-05:00", time.Date(2024, 1, 2, 15, 20, 30, 500000000, time.UTC), true, true},
+		{"Without Seconds", "2024:01:02 10:20", time.Date(2024, 1, 2, 10, 20, 0, 0, time.UTC), false, true},
+		{"ISO 8601", "2024-01-02T10:20:30+02:00", time.Date(2024, 1, 2, 8, 20, 30, 0, time.UTC), true, true},
+		{"Unset", "0000:00:00 00:00:00", time.Time{}, false, false},
+		{"Not A Time", "JPEG", time.Time{}, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, zoned, ok := parseExifTime(tc.value)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.True(t, tc.expected.Equal(parsed), parsed)
+				assert.Equal(t, tc.zoned, zoned)
+			}
+		})
+	}
+}
+
+func TestTimeOptions_ExifTimes(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	exif := ExifMetadata{
+		"DateTimeOriginal":    "2024:07:01 12:00:00",
+		"CreateDate":          "2024:07:01 12:00:00",
+		"OffsetTimeDigitized": "+09:00",
+		"FileModifyDate":      "2024:07:01 12:00:00+02:00",
+		"GPSDateTime":         "2024:07:01 10:00:00Z",
+		"FileType":            "JPEG",
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		times := timeOptions{}.exifTimes(exif)
+		assert.Len(t, times, 4)
+		assert.True(t, times["DateTimeOriginal"].Equal(time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)))
+		assert.True(t, times["CreateDate"].Equal(time.Date(2024, 7, 1, 3, 0, 0, 0, time.UTC)))
+		assert.True(t, times["FileModifyDate"].Equal(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("Location", func(t *testing.T) {
+		times := timeOptions{location: newYork}.exifTimes(exif)
+		assert.True(t, times["DateTimeOriginal"].Equal(time.Date(2024, 7, 1, 16, 0, 0, 0, time.UTC)))
+		assert.Equal(t, newYork, times["DateTimeOriginal"].Location())
+	})
+
+	t.Run("GPS Time Zone", func(t *testing.T) {
+		times := timeOptions{location: newYork, gpsTimeZone: true}.exifTimes(exif)
+		assert.True(t, times["DateTimeOriginal"].Equal(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("UTC", func(t *testing.T) {
+		times := timeOptions{utc: true, location: newYork}.exifTimes(exif)
+		assert.Equal(t, time.UTC, times["DateTimeOriginal"].Location())
+		assert.Equal(t, 16, times["DateTimeOriginal"].Hour())
+	})
+
+	t.Run("Grouped", func(t *testing.T) {
+		times := timeOptions{}.exifTimes(nestExif(ExifMetadata{
+			"EXIF:DateTimeOriginal":   "2024:07:01 12:00:00",
+			"EXIF:OffsetTimeOriginal": "-05:00",
+		}))
+		require.Contains(t, times, "EXIF:DateTimeOriginal")
+		assert.True(t, times["EXIF:DateTimeOriginal"].Equal(time.Date(2024, 7, 1, 17, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("Sub-Seconds", func(t *testing.T) {
+		times := timeOptions{}.exifTimes(ExifMetadata{
+			"DateTimeOriginal":    "2024:07:01 12:00:00",
+			"SubSecTimeOriginal":  "05",
+			"OffsetTimeOriginal":  "+02:00",
+			"CreateDate":          "2024:07:01 12:00:00.25",
+			"SubSecTimeDigitized": "999",
+			"ModifyDate":          "2024:07:01 12:00:00",
+			"SubSecTime":          "invalid",
+		})
+		assert.True(t, times["DateTimeOriginal"].Equal(time.Date(2024, 7, 1, 10, 0, 0, 50000000, time.UTC)))
+		assert.True(t, times["CreateDate"].Equal(time.Date(2024, 7, 1, 12, 0, 0, 250000000, time.UTC)))
+		assert.True(t, times["ModifyDate"].Equal(time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)))
+	})
+}
+
+func TestGPSLocation(t *testing.T) {
+	loc, ok := gpsLocation(ExifMetadata{
+		"DateTimeOriginal": "2024:07:01 12:14:00",
+		"GPSDateTime":      "2024:07:01 10:00:03Z",
+	}, nil)
+	require.True(t, ok)
+	_, offset := time.Date(2024, 7, 1, 0, 0, 0, 0, loc).Zone()
+	assert.Equal(t, 2*3600+15*60, offset)
+
+	_, ok = gpsLocation(ExifMetadata{"DateTimeOriginal": "2024:07:01 12:00:00"}, nil)
+	assert.False(t, ok)
+
+	_, ok = gpsLocation(ExifMetadata{
+		"DateTimeOriginal": "2024:07:03 12:00:00",
+		"GPSDateTime":      "2024:07:01 10:00:00Z",
+	}, nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,137 @@
+package metaextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attilabuti/trid"
+)
+
+// tridWaitDelay bounds the wait for the output of TrID once it is killed,
+// in case a sandbox wrapper left a process holding the output open.
+const tridWaitDelay = time.Second
+
+// The regular expressions parsing the verbose output of TrID, as in the trid
+// package.
+var (
+	reTridFileType = regexp.MustCompile(`(?mi)([0-9.]+%)\s+\((\..*?)\)\s+(.*?(?:\s+\([^()]+\))*?)(?:\s+\([^()]+\))?$`)
+	reTridDetails  = regexp.MustCompile(`(?mi)(Mime type|Related URL|Definition|Remarks)\s*:\s*(.*?)$`)
+)
+
+// runTrid identifies the file with TrID and returns up to matches possible
+// types, sorted by likelihood. It runs TrID as the trid package does, but
+// kills TrID when ctx is done or once opts.Timeout has elapsed, as the trid
+// package cannot be interrupted.
+func runTrid(ctx context.Context, opts trid.Options, matches int, filePath string) ([]trid.FileType, error) {
+	if _, err := os.Stat(filePath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, trid.ErrFileNotFound
+		}
+		return nil, err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTridTimeout
+	}
+
+	name := opts.Cmd
+	if name == "" {
+		name = "trid"
+	}
+
+	args := []string{"-v", "-n:" + strconv.Itoa(matches)}
+	if opts.Definitions != "" {
+		args = append(args, "-d:"+opts.Definitions)
+	}
+	args = append(args, filePath)
+
+	tridCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(tridCtx, name, args...)
+	cmd.WaitDelay = tridWaitDelay
+	out, err := cmd.CombinedOutput()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if errors.Is(tridCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrTridTimeout, err)
+	}
+
+	if tridErr := tridError(string(out)); tridErr != nil {
+		return nil, tridErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTrid(string(out)), nil
+}
+
+// tridError returns the error reported in the output of TrID, if any.
+func tridError(out string) error {
+	switch {
+	case strings.Contains(out, "you have to specify at least one file to analyze"):
+		return trid.ErrNoFileSpecified
+	case strings.Contains(out, "No definitions available!"):
+		return trid.ErrNoDefinitions
+	case strings.Contains(out, "Def package") && strings.Contains(out, "is empty!"):
+		return trid.ErrEmptyDefPackage
+	case strings.Contains(out, "Error: found no file(s) to analyze!"):
+		return trid.ErrFileNotFound
+	case strings.Contains(out, "Unknown!"):
+		return trid.ErrUnknownFileType
+	}
+
+	return nil
+}
+
+// parseTrid parses the verbose output of TrID. Each possible type is
+// reported in a paragraph starting with its probability and extension.
+func parseTrid(out string) []trid.FileType {
+	fileTypes := make([]trid.FileType, 0)
+
+	for _, paragraph := range strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n\n") {
+		m := reTridFileType.FindStringSubmatch(paragraph)
+		if m == nil {
+			continue
+		}
+
+		probability, err := strconv.ParseFloat(strings.TrimSuffix(m[1], "%"), 64)
+		if err != nil {
+			continue
+		}
+
+		fileType := trid.FileType{
+			Probability: probability,
+			Extension:   strings.ToLower(m[2]),
+			Name:        m[3],
+		}
+
+		for _, detail := range reTridDetails.FindAllStringSubmatch(paragraph, -1) {
+			switch detail[1] {
+			case "Mime type":
+				fileType.MimeType = detail[2]
+			case "Related URL":
+				fileType.RelatedURL = detail[2]
+			case "Definition":
+				fileType.Definition = detail[2]
+			case "Remarks":
+				fileType.Remarks = detail[2]
+			}
+		}
+
+		fileTypes = append(fileTypes, fileType)
+	}
+
+	return fileTypes
+}
@@ -0,0 +1,62 @@
+package metaextractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrid(t *testing.T) {
+	out := "TrID/32 - File Identifier v2.24 - (C) 2003-16 By M.Pontello\r\n" +
+		"Definitions found:  18422\r\n" +
+		"Analyzing...\r\n" +
+		"\r\n" +
+		"Collecting data from file: sample.mp3\r\n" +
+		" 50.0% (.MP3) MP3 audio (ID3 v2.x tag) (2/1)\r\n" +
+		"  Mime type  : audio/mpeg\r\n" +
+		"  Definition : mp3-id3v2.trid.xml\r\n" +
+		"\r\n" +
+		" 25.0% (.ABC) Something else (1/1)\r\n"
+
+	fileTypes := parseTrid(out)
+	require.Len(t, fileTypes, 2)
+	assert.Equal(t, trid.FileType{
+		Probability: 50,
+		Extension:   ".mp3",
+		Name:        "MP3 audio (ID3 v2.x tag)",
+		MimeType:    "audio/mpeg",
+		Definition:  "mp3-id3v2.trid.xml",
+	}, fileTypes[0])
+	assert.Equal(t, ".abc", fileTypes[1].Extension)
+
+	assert.Equal(t, trid.ErrUnknownFileType, tridError("Collecting data from file: x\n Unknown!\n"))
+	assert.Nil(t, tridError(out))
+}
+
+func TestRunTrid_Cancel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "trid")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 60\n"), 0o755))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := runTrid(ctx, trid.Options{Cmd: script, Timeout: time.Minute}, 1, filepath.Join("testdata", "sample.mp3"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrTridTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second, "TrID is killed")
+
+	_, err = runTrid(context.Background(), trid.Options{Cmd: script}, 1, filepath.Join("testdata", "missing"))
+	assert.ErrorIs(t, err, trid.ErrFileNotFound)
+}
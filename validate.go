@@ -0,0 +1,87 @@
+package metaextractor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrIncomplete is returned in strict mode (Options.Strict) when the
+// extracted metadata does not meet the expectations.
+var ErrIncomplete = errors.New("incomplete metadata")
+
+// fileSystemTags are the tags ExifTool reports for every file, which do not
+// come from metadata embedded in the file.
+var fileSystemTags = map[string]bool{
+	"SourceFile": true, "ExifToolVersion": true, "Error": true, "Warning": true,
+	"FileName": true, "Directory": true, "FileSize": true, "FileModifyDate": true,
+	"FileAccessDate": true, "FileInodeChangeDate": true, "FileCreateDate": true,
+	"FilePermissions": true, "FileAttributes": true, "FileType": true,
+	"FileTypeExtension": true, "MIMEType": true,
+}
+
+// fileSystemGroups are the groups of the tags in fileSystemTags.
+var fileSystemGroups = map[string]bool{
+	"File": true, "ExifTool": true, "System": true, "SourceFile": true,
+}
+
+// validate reports the expectations the metadata does not meet: a detected
+// type, the birth time of the file and, for images, audio and video, embedded
+// EXIF metadata.
+func validate(metadata Metadata) error {
+	var problems []string
+
+	if len(metadata.Types) == 0 {
+		problems = append(problems, "no type detected")
+	}
+
+	if isMedia(metadata) && !hasEmbeddedExif(metadata.Exif) {
+		problems = append(problems, "no EXIF metadata for media file")
+	}
+
+	if metadata.Time.BirthTime.IsZero() {
+		problems = append(problems, "birth time unavailable")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrIncomplete, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// isMedia reports whether the detected type of the file is an image, audio or
+// video type.
+func isMedia(metadata Metadata) bool {
+	mimeType := detectedMimeType(metadata)
+
+	return strings.HasPrefix(mimeType, "image/") ||
+		strings.HasPrefix(mimeType, "audio/") ||
+		strings.HasPrefix(mimeType, "video/")
+}
+
+// hasEmbeddedExif reports whether the EXIF metadata contains tags other than
+// the file system information ExifTool reports for every file.
+func hasEmbeddedExif(exif ExifMetadata) bool {
+	for key, value := range exif {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if !fileSystemGroups[key] && len(nested) > 0 {
+				return true
+			}
+			continue
+		}
+
+		group, tag, grouped := strings.Cut(key, ":")
+		if !grouped {
+			tag = key
+		} else if fileSystemGroups[group] {
+			continue
+		}
+
+		if !fileSystemTags[tag] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package metaextractor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attilabuti/trid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	born := FileTime{BirthTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	jpeg := []trid.FileType{{Extension: ".jpg", MimeType: "image/jpeg"}}
+	pdf := []trid.FileType{{Extension: ".pdf", MimeType: "application/pdf"}}
+
+	testCases := []struct {
+		name     string
+		metadata Metadata
+		problems string
+	}{
+		{"Complete", Metadata{Types: jpeg, Time: born, Exif: ExifMetadata{"Make": "Canon"}}, ""},
+		{"Non-Media Without EXIF", Metadata{Types: pdf, Time: born}, ""},
+		{"No Type", Metadata{Time: born}, "no type detected"},
+		{"Media Without EXIF", Metadata{Types: jpeg, Time: born, Exif: ExifMetadata{"FileSize": "1 kB", "File:FileType": "JPEG"}}, "no EXIF metadata for media file"},
+		{"No Birth Time", Metadata{Types: pdf}, "birth time unavailable"},
+		{"Several Problems", Metadata{}, "no type detected, birth time unavailable"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validate(tc.metadata)
+			if tc.problems == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrIncomplete)
+			assert.EqualError(t, err, "incomplete metadata: "+tc.problems)
+		})
+	}
+}
+
+func TestHasEmbeddedExif(t *testing.T) {
+	assert.False(t, hasEmbeddedExif(nil))
+	assert.False(t, hasEmbeddedExif(ExifMetadata{"SourceFile": "a.jpg", "MIMEType": "image/jpeg"}))
+	assert.False(t, hasEmbeddedExif(ExifMetadata{"File": map[string]interface{}{"Make": "x"}}))
+	assert.True(t, hasEmbeddedExif(ExifMetadata{"EXIF:Make": "Canon"}))
+	assert.True(t, hasEmbeddedExif(ExifMetadata{"EXIF": map[string]interface{}{"Make": "Canon"}}))
+}
+
+func TestMetaExtractor_Strict(t *testing.T) {
+	me := NewMetaExtractor(Options{PureGo: true, Strict: true})
+
+	metadata, err := me.Extract(filepath.Join("testdata", "empty"))
+	require.ErrorIs(t, err, ErrIncomplete)
+	assert.ErrorContains(t, err, "no type detected")
+	assert.Equal(t, "empty", metadata.Name)
+}
@@ -0,0 +1,487 @@
+package metaextractor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Result holds the outcome of extracting metadata from a single file during a
+// batch operation.
+type Result struct {
+	// Path is the path of the file.
+	Path string `json:"path"`
+
+	// Metadata is the extracted metadata. It may be partially populated if
+	// Err is not nil.
+	Metadata Metadata `json:"metadata"`
+
+	// Err is the error encountered while extracting metadata, if any.
+	Err error `json:"-"`
+
+	// Dir is the metadata of the directory at Path. It is only set for the
+	// directory results of ExtractDir (see WalkOptions.Dirs); Metadata is
+	// empty for them.
+	Dir *DirMetadata `json:"dir,omitempty"`
+
+	// Bag is the metadata and verification of the BagIt bag at Path. It is
+	// only set for the bag results of ExtractDir (see WalkOptions.Bags);
+	// Metadata is empty for them.
+	Bag *Bag `json:"bag,omitempty"`
+
+	// Parent is the virtual path of the archive or email the file was
+	// extracted from (e.g., "mail.eml!/photos.zip" for
+	// "mail.eml!/photos.zip!/beach.jpg"), linking every nested result to
+	// its container. It is empty for files on disk and for the entries of
+	// the stream read by ExtractTar.
+	Parent string `json:"parent,omitempty"`
+
+	// Depth is the number of archives and emails the file is nested in: 0
+	// for files on disk, 1 for their entries and attachments, and so on.
+	Depth int `json:"depth,omitempty"`
+
+	// SourcePath is the path the file was read from if it differs from Path,
+	// i.e., its path in a snapshot (see WalkOptions.OriginalRoot).
+	SourcePath string `json:"source_path,omitempty"`
+
+	// DuplicateOf is the path of an earlier result with identical content,
+	// whose content stages were shared (see Options.Deduplicate).
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+
+	// Aliases are the paths of later results with identical content.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// RelatedFiles are the results taken together with the file, such as
+	// the video of a live photo, the JPEG of a RAW image or the other
+	// images of a burst (see WalkOptions.RelatedFiles).
+	RelatedFiles []RelatedFile `json:"related_files,omitempty"`
+
+	// RunID identifies the batch (ExtractBatch or ExtractDir call) that
+	// produced the result.
+	RunID string `json:"run_id,omitempty"`
+
+	// RecordID uniquely identifies the result.
+	RecordID string `json:"record_id,omitempty"`
+
+	// Host is the name of the host that produced the result.
+	Host string `json:"host,omitempty"`
+}
+
+// WalkOptions configures directory extraction.
+type WalkOptions struct {
+	// Include is a list of glob patterns; if not empty, only files matching at
+	// least one pattern are extracted. Patterns without a slash are matched
+	// against the file name, others against the slash-separated path relative
+	// to the root. "**" matches any number of directories.
+	Include []string
+
+	// Exclude is a list of glob patterns for files and directories to skip.
+	// It uses the same syntax as Include and takes precedence over it.
+	Exclude []string
+
+	// Extensions limits extraction to files with the given extensions
+	// (e.g., ".jpg"). The comparison is case-insensitive.
+	Extensions []string
+
+	// MaxDepth limits the walk to the given number of directory levels below
+	// the root: 1 only extracts the files in the root itself. Zero means no
+	// limit.
+	MaxDepth int
+
+	// FollowSymlinks follows symbolic links to files and directories. Each
+	// directory is walked at most once through a link, which prevents
+	// loops. By default, symbolic links are skipped.
+	FollowSymlinks bool
+
+	// MinSize skips files smaller than the given size in bytes.
+	MinSize int64
+
+	// MaxSize skips files larger than the given size in bytes. Zero means no limit.
+	MaxSize int64
+
+	// ModifiedSince skips files last modified before the given time.
+	ModifiedSince time.Time
+
+	// IgnoreFile is the name of the gitignore-style ignore file honored in
+	// every walked directory. Defaults to DefaultIgnoreFile (".metaignore").
+	IgnoreFile string
+
+	// NoIgnoreFile disables ignore file handling.
+	NoIgnoreFile bool
+
+	// Archives enables descending into ZIP and TAR (optionally gzip-compressed)
+	// archives, including nested ones and sets of split parts (e.g.,
+	// "archive.zip.001", "archive.zip.002"), and into the attachments of
+	// emails (.eml) and Outlook messages (.msg). Entries are reported with
+	// virtual paths of the form "archive.zip!/inner/file.jpg", linked to
+	// their container by Result.Parent and Result.Depth, and are subject to
+	// the same filters as regular files. Archives are descended into even
+	// if they are not accepted by the filters themselves, unless they are
+	// excluded.
+	Archives bool
+
+	// Dirs adds a result with the DirMetadata of every walked directory,
+	// including the root. Directory counts and totals cover the whole tree,
+	// regardless of the filters.
+	Dirs bool
+
+	// Bags adds a result with the Bag of every walked BagIt bag (see
+	// ExtractBag), after the result of its directory. The files of the bag
+	// are extracted as usual.
+	Bags bool
+
+	// DescendBundles walks into macOS bundles (see MacBundleExtensions)
+	// like into regular directories. By default, a bundle is extracted as a
+	// single item with Kind set to KindBundle, and its content is skipped.
+	DescendBundles bool
+
+	// RelatedFiles links the results of files taken together by a camera
+	// in Result.RelatedFiles: the still image and video of live photos, RAW
+	// and JPEG (or HEIF) pairs, and burst sequences, so that photo
+	// organizers can handle each group as a whole.
+	RelatedFiles bool
+
+	// OriginalRoot is the path of root on the live system if root is a
+	// snapshot of it, such as a VSS shadow copy (e.g.,
+	// `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\Users` of `C:\Users`) or
+	// a mounted LVM or btrfs snapshot. Results are reported under their
+	// original paths, with the paths read in Result.SourcePath.
+	OriginalRoot string
+}
+
+// ExtractDir walks the directory tree rooted at root and extracts metadata
+// from every regular file accepted by the walk options, on up to
+// Options.Concurrency goroutines once the walk is complete. Errors for
+// individual files are reported in the corresponding Result; the returned
+// error is only set if the walk itself fails. The files are not extracted
+// once a stage exceeds its error budget (see Options.ErrorBudgets).
+func (me *MetaExtractor) ExtractDir(root string, opts WalkOptions) ([]Result, error) {
+	if root == "" {
+		return nil, ErrNoFileSpecified
+	}
+
+	root = snapshotRoot(root)
+
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+
+	// A bundle given as the root is extracted as a single item as well.
+	if !opts.DescendBundles && isMacBundle(root, rootInfo) {
+		metadata, err := me.Extract(root)
+		results := []Result{{Path: root, Metadata: metadata, Err: err}}
+		opts.mapSnapshot(root, results)
+		me.stampResults(results)
+		return results, nil
+	}
+
+	ignoreFile := opts.IgnoreFile
+	if ignoreFile == "" {
+		ignoreFile = DefaultIgnoreFile
+	}
+
+	var dirs map[string]*DirMetadata
+	if opts.Dirs {
+		var err error
+		if dirs, err = me.collectDirMetadata(root); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := withSplitDirCache(withBudget(context.Background(), me.newBudgetTracker()))
+	dedup := me.newDedupIndex()
+	queue := me.newExtractQueue()
+	progress := me.newProgress(0)
+
+	// followed holds the real paths of the directories walked through
+	// symbolic links.
+	followed := make(map[string]bool)
+	if opts.FollowSymlinks {
+		if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+			followed[realRoot] = true
+		}
+	}
+
+	var ignore ignoreMatcher
+	var results []Result
+	var walk fs.WalkDirFunc
+	walk = func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			results = append(results, Result{Path: p, Err: err})
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+
+		if p != root && ignore.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if p != root && matchAny(opts.Exclude, rel) {
+				return filepath.SkipDir
+			}
+
+			if p != root && !opts.DescendBundles {
+				if info, err := d.Info(); err == nil && isMacBundle(p, info) {
+					if opts.accept(rel, info) {
+						metadata, err := me.Extract(p)
+						results = append(results, Result{Path: p, Metadata: metadata, Err: err})
+					}
+					return filepath.SkipDir
+				}
+			}
+
+			if !opts.NoIgnoreFile {
+				base := rel
+				if p == root {
+					base = ""
+				}
+				if err := ignore.load(p, base, ignoreFile); err != nil {
+					results = append(results, Result{Path: filepath.Join(p, ignoreFile), Err: err})
+				}
+			}
+
+			if dir, ok := dirs[filepath.Clean(p)]; ok {
+				results = append(results, Result{Path: p, Dir: dir})
+			}
+
+			if opts.Bags && IsBag(p) {
+				bag, err := me.ExtractBag(p)
+				results = append(results, Result{Path: p, Bag: bag, Err: err})
+			}
+
+			if opts.MaxDepth > 0 && walkDepth(rel) >= opts.MaxDepth {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !opts.NoIgnoreFile && d.Name() == ignoreFile {
+			return nil
+		}
+
+		var info fs.FileInfo
+		if d.Type()&fs.ModeSymlink != 0 && opts.FollowSymlinks {
+			if info, err = os.Stat(p); err != nil {
+				results = append(results, Result{Path: p, Err: err})
+				return nil
+			}
+
+			if info.IsDir() {
+				realDir, err := filepath.EvalSymlinks(p)
+				if err != nil {
+					results = append(results, Result{Path: p, Err: err})
+					return nil
+				}
+				if followed[realDir] {
+					return nil
+				}
+				followed[realDir] = true
+
+				// The trailing separator makes WalkDir follow the link.
+				return filepath.WalkDir(p+string(filepath.Separator), walk)
+			}
+		} else if info, err = d.Info(); err != nil {
+			results = append(results, Result{Path: p, Err: err})
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		// Parts of a split archive are handled as one logical container,
+		// represented by its first present part.
+		var splitArchive *SplitArchive
+		if opts.Archives {
+			splitArchive, err = detectSplitArchive(p, splitDirCacheFromContext(ctx))
+			if err != nil {
+				results = append(results, Result{Path: p, Err: err})
+				return nil
+			}
+			if splitArchive != nil && splitArchive.Parts[0] != p {
+				return nil
+			}
+		}
+
+		if opts.accept(rel, info) {
+			if queue != nil {
+				results = queue.add(results, p)
+			} else {
+				results = dedup.extract(ctx, me, results, p)
+				progress.report(p)
+			}
+		}
+
+		if opts.Archives && !matchAny(opts.Exclude, rel) {
+			emit := func(r Result) {
+				results = append(results, r)
+			}
+
+			if splitArchive != nil {
+				err = me.walkSplitArchive(ctx, splitArchive, p, 0, opts.acceptEntry(root), emit)
+			} else {
+				err = me.walkArchive(ctx, p, p, 0, opts.acceptEntry(root), emit)
+			}
+
+			if err != nil {
+				emit(Result{Path: p, Err: fmt.Errorf("error reading archive: %w", err)})
+			}
+		}
+
+		return nil
+	}
+	err = filepath.WalkDir(root, walk)
+
+	if queue != nil {
+		queue.run(ctx, me, results)
+	}
+
+	opts.mapSnapshot(root, results)
+	if opts.RelatedFiles {
+		linkRelatedFiles(results)
+	}
+	me.stampResults(results)
+
+	return results, err
+}
+
+// mapSnapshot maps the paths of the results below root to OriginalRoot,
+// if set.
+func (opts WalkOptions) mapSnapshot(root string, results []Result) {
+	if opts.OriginalRoot != "" {
+		snapshotMapper{root: root, original: opts.OriginalRoot}.mapResults(results)
+	}
+}
+
+// walkDepth returns the number of directory levels of a slash-separated path
+// relative to the root of a walk; the root itself has depth 0.
+func walkDepth(rel string) int {
+	if rel == "" || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+// accept reports whether a file passes the walk filters.
+func (opts WalkOptions) accept(rel string, info fs.FileInfo) bool {
+	if matchAny(opts.Exclude, rel) {
+		return false
+	}
+
+	if len(opts.Include) > 0 && !matchAny(opts.Include, rel) {
+		return false
+	}
+
+	if len(opts.Extensions) > 0 {
+		ext := strings.ToLower(path.Ext(rel))
+
+		found := false
+		for _, e := range opts.Extensions {
+			if !strings.HasPrefix(e, ".") {
+				e = "." + e
+			}
+			if strings.ToLower(e) == ext {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if info.Size() < opts.MinSize || (opts.MaxSize > 0 && info.Size() > opts.MaxSize) {
+		return false
+	}
+
+	if !opts.ModifiedSince.IsZero() && info.ModTime().Before(opts.ModifiedSince) {
+		return false
+	}
+
+	return true
+}
+
+// acceptEntry returns a filter applying the walk filters to archive entries.
+func (opts WalkOptions) acceptEntry(root string) entryFilter {
+	return func(entryPath string, info fs.FileInfo) bool {
+		rel, err := filepath.Rel(root, entryPath)
+		if err != nil {
+			return false
+		}
+		return opts.accept(filepath.ToSlash(rel), info)
+	}
+}
+
+// matchAny reports whether the slash-separated relative path matches any of
+// the glob patterns.
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPattern matches a single glob pattern against a slash-separated
+// relative path. Patterns without a slash are matched against the base name.
+func matchPattern(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+
+	return matchGlob(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchGlob matches path segments against pattern segments, where a "**"
+// segment matches zero or more path segments.
+func matchGlob(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchGlob(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
@@ -0,0 +1,193 @@
+package metaextractor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTree creates the given files (relative slash-separated paths with
+// their contents) below a new temporary directory and returns its path.
+func createTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	return root
+}
+
+// resultPaths returns the sorted slash-separated paths of the results,
+// relative to root.
+func resultPaths(t *testing.T, root string, results []Result) []string {
+	t.Helper()
+
+	paths := make([]string, 0, len(results))
+	for _, r := range results {
+		rel, err := filepath.Rel(root, r.Path)
+		require.NoError(t, err)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func TestMatchPattern(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		rel      string
+		expected bool
+	}{
+		{"*.jpg", "photos/2024/a.jpg", true},
+		{"*.jpg", "photos/a.png", false},
+		{"photos/*.jpg", "photos/a.jpg", true},
+		{"photos/*.jpg", "photos/2024/a.jpg", false},
+		{"photos/**/*.jpg", "photos/2024/05/a.jpg", true},
+		{"photos/**/*.jpg", "photos/a.jpg", true},
+		{"**/cache", "a/b/cache", true},
+		{"/node_modules", "node_modules", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.rel, func(t *testing.T) {
+			assert.Equal(t, tc.expected, matchPattern(tc.pattern, tc.rel))
+		})
+	}
+}
+
+func TestExtractDir_Filters(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"a.jpg":                 "image",
+		"b.JPG":                 "image",
+		"c.txt":                 "some longer text content",
+		"photos/d.jpg":          "image",
+		"photos/raw/e.cr2":      "raw",
+		"node_modules/f.jpg":    "image",
+		"node_modules/x/g.json": "{}",
+	})
+
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "a.jpg"), old, old))
+
+	extractor := NewMetaExtractor(Options{})
+
+	testCases := []struct {
+		name     string
+		opts     WalkOptions
+		expected []string
+	}{
+		{
+			name:     "No Filters",
+			opts:     WalkOptions{},
+			expected: []string{"a.jpg", "b.JPG", "c.txt", "node_modules/f.jpg", "node_modules/x/g.json", "photos/d.jpg", "photos/raw/e.cr2"},
+		},
+		{
+			name:     "Exclude Directory",
+			opts:     WalkOptions{Exclude: []string{"node_modules"}},
+			expected: []string{"a.jpg", "b.JPG", "c.txt", "photos/d.jpg", "photos/raw/e.cr2"},
+		},
+		{
+			name:     "Include Glob",
+			opts:     WalkOptions{Include: []string{"photos/**"}},
+			expected: []string{"photos/d.jpg", "photos/raw/e.cr2"},
+		},
+		{
+			name:     "Extensions",
+			opts:     WalkOptions{Extensions: []string{"jpg"}, Exclude: []string{"node_modules"}},
+			expected: []string{"a.jpg", "b.JPG", "photos/d.jpg"},
+		},
+		{
+			name:     "Size",
+			opts:     WalkOptions{MinSize: 4, MaxSize: 10},
+			expected: []string{"a.jpg", "b.JPG", "node_modules/f.jpg", "photos/d.jpg"},
+		},
+		{
+			name:     "Max Depth 1",
+			opts:     WalkOptions{MaxDepth: 1},
+			expected: []string{"a.jpg", "b.JPG", "c.txt"},
+		},
+		{
+			name:     "Max Depth 2",
+			opts:     WalkOptions{MaxDepth: 2},
+			expected: []string{"a.jpg", "b.JPG", "c.txt", "node_modules/f.jpg", "photos/d.jpg"},
+		},
+		{
+			name:     "Modified Since",
+			opts:     WalkOptions{Extensions: []string{".jpg"}, ModifiedSince: time.Now().Add(-30 * 24 * time.Hour)},
+			expected: []string{"b.JPG", "node_modules/f.jpg", "photos/d.jpg"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := extractor.ExtractDir(root, tc.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, resultPaths(t, root, results))
+		})
+	}
+}
+
+func TestExtractDir_Symlinks(t *testing.T) {
+	root := createTree(t, map[string]string{
+		"a.txt":         "a",
+		"docs/b.txt":    "b",
+		"outside/c.txt": "c",
+	})
+	dir := filepath.Join(root, "dir")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+
+	for link, target := range map[string]string{
+		"file.txt": filepath.Join(root, "a.txt"),
+		"docs":     filepath.Join(root, "docs"),
+		"other":    filepath.Join(root, "outside"),
+		"loop":     dir,
+		"parent":   root,
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skipf("symbolic links not supported: %v", err)
+		}
+	}
+
+	extractor := NewMetaExtractor(Options{})
+
+	results, err := extractor.ExtractDir(dir, WalkOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, err = extractor.ExtractDir(dir, WalkOptions{FollowSymlinks: true})
+	require.NoError(t, err)
+
+	paths := resultPaths(t, dir, results)
+	assert.Contains(t, paths, "file.txt")
+	assert.Contains(t, paths, "docs/b.txt")
+	assert.Contains(t, paths, "other/c.txt")
+	assert.NotContains(t, paths, "loop/file.txt")
+
+	// The parent is walked once, without walking its links again.
+	assert.Contains(t, paths, "parent/a.txt")
+	assert.NotContains(t, paths, "parent/dir/parent/a.txt")
+
+	results, err = extractor.ExtractDir(dir, WalkOptions{FollowSymlinks: true, MaxDepth: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file.txt"}, resultPaths(t, dir, results))
+}
+
+func TestExtractDir_Errors(t *testing.T) {
+	extractor := NewMetaExtractor(Options{})
+
+	_, err := extractor.ExtractDir("", WalkOptions{})
+	assert.ErrorIs(t, err, ErrNoFileSpecified)
+
+	_, err = extractor.ExtractDir("nonexistent_dir", WalkOptions{})
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
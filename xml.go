@@ -0,0 +1,373 @@
+package metaextractor
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// XML namespaces of the RDF/XML encoding.
+const (
+	rdfNamespace      = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	exifToolNamespace = "http://ns.exiftool.org/1.0/"
+	xmlNamespace      = "https://github.com/attilabuti/metaextractor/ns/1.0/"
+	base64Datatype    = "http://www.w3.org/2001/XMLSchema#base64Binary"
+)
+
+// xmlGroup is the namespace prefix of the metadata not reported by ExifTool.
+const xmlGroup = "MetaExtractor"
+
+// xmlTimeFormat is the format of the file times, as printed by ExifTool.
+const xmlTimeFormat = "2006:01:02 15:04:05-07:00"
+
+// xmlSkipped are the JSON keys of Metadata encoded as ExifTool tags rather
+// than in the MetaExtractor namespace.
+var xmlSkipped = []string{"name", "size", "mime_type", "time", "exif"}
+
+// xmlTag is a tag of the RDF/XML encoding.
+type xmlTag struct {
+	group string
+	name  string
+	value interface{}
+}
+
+// MarshalXML encodes the metadata as RDF/XML compatible with the output of
+// "exiftool -X": an rdf:RDF element holding an rdf:Description of the file
+// named by Metadata.Name. The given start element is not used.
+//
+// The tags of Exif are elements in the namespace of their group (e.g.,
+// <EXIF:Make> with ExifKeysGrouped or ExifKeysNested); tags without a group
+// are in the et namespace. Lists are encoded as rdf:Bag elements,
+// structures as rdf:parseType="Resource" elements and "base64:" values as
+// base64Binary. The name, size, file times and MIME type are reported as
+// the File tags of ExifTool (e.g., <File:FileModifyDate>) unless Exif
+// contains them, and the other fields are in the MetaExtractor namespace
+// under their JSON names (e.g., <MetaExtractor:best_type>).
+func (m Metadata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	root := rdfRoot()
+	if err := e.EncodeToken(root); err != nil {
+		return err
+	}
+
+	if err := encodeDescription(e, m.Name, m, nil); err != nil {
+		return err
+	}
+
+	return e.EncodeToken(root.End())
+}
+
+// XMLWriter writes results as RDF/XML compatible with the output of
+// "exiftool -X", with an rdf:Description per result (see
+// Metadata.MarshalXML).
+type XMLWriter struct {
+	e       *xml.Encoder
+	started bool
+}
+
+// NewXMLWriter returns an XMLWriter writing to w.
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	e := xml.NewEncoder(w)
+	e.Indent("", " ")
+
+	return &XMLWriter{e: e}
+}
+
+// Write writes the rdf:Description of the result, about its path. The
+// error of a failed result is reported in an ExifTool:Error element, as by
+// ExifTool.
+func (xw *XMLWriter) Write(r Result) error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+
+	var extra []xmlTag
+	if r.Err != nil {
+		extra = append(extra, xmlTag{group: "ExifTool", name: "Error", value: r.Err.Error()})
+	}
+
+	return encodeDescription(xw.e, r.Path, r.Metadata, extra)
+}
+
+// Close ends the document and flushes it to the underlying writer. It does
+// not close the underlying writer.
+func (xw *XMLWriter) Close() error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+
+	if err := xw.e.EncodeToken(rdfRoot().End()); err != nil {
+		return err
+	}
+
+	return xw.e.Close()
+}
+
+func (xw *XMLWriter) start() error {
+	if xw.started {
+		return nil
+	}
+	xw.started = true
+
+	if err := xw.e.EncodeToken(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)}); err != nil {
+		return err
+	}
+	if err := xw.e.EncodeToken(xml.CharData("\n")); err != nil {
+		return err
+	}
+
+	return xw.e.EncodeToken(rdfRoot())
+}
+
+// WriteXML writes the results to w as an RDF/XML document (see XMLWriter).
+func WriteXML(w io.Writer, results []Result) error {
+	xw := NewXMLWriter(w)
+	for _, r := range results {
+		if err := xw.Write(r); err != nil {
+			return err
+		}
+	}
+
+	return xw.Close()
+}
+
+// rdfRoot returns the rdf:RDF start element.
+func rdfRoot() xml.StartElement {
+	return xml.StartElement{
+		Name: xml.Name{Local: "rdf:RDF"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns:rdf"}, Value: rdfNamespace}},
+	}
+}
+
+// encodeDescription encodes the rdf:Description of the metadata, followed
+// by the extra tags.
+func encodeDescription(e *xml.Encoder, about string, m Metadata, extra []xmlTag) error {
+	tags, err := xmlTags(m)
+	if err != nil {
+		return err
+	}
+	tags = append(tags, extra...)
+
+	start := xml.StartElement{
+		Name: xml.Name{Local: "rdf:Description"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "rdf:about"}, Value: about},
+			{Name: xml.Name{Local: "xmlns:et"}, Value: exifToolNamespace},
+			{Name: xml.Name{Local: "et:toolkit"}, Value: "metaextractor"},
+		},
+	}
+
+	declared := map[string]bool{"et": true}
+	for _, tag := range tags {
+		if declared[tag.group] {
+			continue
+		}
+		declared[tag.group] = true
+
+		ns := "http://ns.exiftool.org/" + tag.group + "/1.0/"
+		if tag.group == xmlGroup {
+			ns = xmlNamespace
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:" + tag.group}, Value: ns})
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if err := encodeXMLValue(e, tag.group+":"+tag.name, tag.group, tag.value); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// xmlTags returns the tags of the metadata: the File tags, the tags of Exif
+// by group and name, and the other fields in the MetaExtractor namespace.
+func xmlTags(m Metadata) ([]xmlTag, error) {
+	var tags []xmlTag
+
+	file := func(name string, value interface{}) {
+		if _, ok := m.Exif["File:"+name]; ok {
+			return
+		}
+		if _, ok := m.Exif[name]; ok {
+			return
+		}
+		if group, ok := m.Exif["File"].(map[string]interface{}); ok {
+			if _, ok := group[name]; ok {
+				return
+			}
+		}
+		tags = append(tags, xmlTag{group: "File", name: name, value: value})
+	}
+
+	if m.Name != "" {
+		file("FileName", m.Name)
+		file("FileSize", float64(m.Size))
+	}
+	for _, ft := range []struct {
+		name string
+		time time.Time
+	}{
+		{"FileModifyDate", m.Time.ModTime},
+		{"FileAccessDate", m.Time.AccessTime},
+		{"FileInodeChangeDate", m.Time.ChangeTime},
+		{"FileCreateDate", m.Time.BirthTime},
+	} {
+		if !ft.time.IsZero() {
+			file(ft.name, ft.time.Format(xmlTimeFormat))
+		}
+	}
+	if m.MimeType != "" {
+		file("MIMEType", m.MimeType)
+	}
+
+	var exifTags []xmlTag
+	for key, v := range m.Exif {
+		group, name, ok := strings.Cut(key, ":")
+		if nested, isGroup := v.(map[string]interface{}); isGroup && !ok {
+			for name, v := range nested {
+				exifTags = append(exifTags, xmlTag{group: xmlName(key), name: xmlName(name), value: v})
+			}
+			continue
+		}
+
+		if !ok {
+			if key == "SourceFile" {
+				continue
+			}
+			group, name = "et", key
+		}
+		exifTags = append(exifTags, xmlTag{group: xmlName(group), name: xmlName(name), value: v})
+	}
+	sort.Slice(exifTags, func(i, j int) bool {
+		if exifTags[i].group != exifTags[j].group {
+			return exifTags[i].group < exifTags[j].group
+		}
+		return exifTags[i].name < exifTags[j].name
+	})
+	tags = append(tags, exifTags...)
+
+	// The other fields are encoded with their JSON names and values, so
+	// that new fields are encoded without changes here.
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, key := range sortedKeys(fields) {
+		if !slices.Contains(xmlSkipped, key) {
+			tags = append(tags, xmlTag{group: xmlGroup, name: xmlName(key), value: fields[key]})
+		}
+	}
+
+	return tags, nil
+}
+
+// encodeXMLValue encodes the value as the element name. Fields of
+// structures are in the namespace of group.
+func encodeXMLValue(e *xml.Encoder, name, group string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	switch v := v.(type) {
+	case nil:
+		return nil
+
+	case string:
+		if data, ok := strings.CutPrefix(v, "base64:"); ok {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "rdf:datatype"}, Value: base64Datatype})
+			v = data
+		}
+		return e.EncodeElement(v, start)
+
+	case float64:
+		return e.EncodeElement(strconv.FormatFloat(v, 'f', -1, 64), start)
+
+	case bool:
+		return e.EncodeElement(strconv.FormatBool(v), start)
+
+	case []interface{}:
+		bag := xml.StartElement{Name: xml.Name{Local: "rdf:Bag"}}
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(bag); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeXMLValue(e, "rdf:li", group, item); err != nil {
+				return err
+			}
+		}
+		if err := e.EncodeToken(bag.End()); err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+
+	case map[string]interface{}:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "rdf:parseType"}, Value: "Resource"})
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(v) {
+			if err := encodeXMLValue(e, group+":"+xmlName(key), group, v[key]); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+
+	// Other values, such as BinaryRef, are encoded as their JSON encoding.
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	return encodeXMLValue(e, name, group, decoded)
+}
+
+// xmlName returns s with the characters not allowed in XML names replaced
+// by underscores.
+func xmlName(s string) string {
+	name := []rune(s)
+	for i, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			name[i] = '_'
+		}
+	}
+
+	if len(name) == 0 || (!unicode.IsLetter(name[0]) && name[0] != '_') {
+		name = append([]rune{'_'}, name...)
+	}
+
+	return string(name)
+}
+
+// sortedKeys returns the keys of the map in increasing order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
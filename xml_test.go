@@ -0,0 +1,112 @@
+package metaextractor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteXML(t *testing.T) {
+	modTime := time.Date(2024, 8, 3, 14, 2, 11, 0, time.UTC)
+	results := []Result{
+		{
+			Path: "photos/a.jpg",
+			Metadata: Metadata{
+				Name:     "a.jpg",
+				Size:     2048,
+				Kind:     KindImage,
+				MimeType: "image/jpeg",
+				Time:     FileTime{ModTime: modTime},
+				Hashes:   map[string]string{"sha256": "9f2c"},
+				Exif: ExifMetadata{
+					"SourceFile":               "photos/a.jpg",
+					"EXIF:Make":                "Canon & Co",
+					"XMP:Subject":              []interface{}{"beach", "sea"},
+					"File:MIMEType":            "image/jpeg",
+					"Composite:ThumbnailImage": "base64:AAEC",
+				},
+			},
+		},
+		{Path: "photos/b.raw", Err: errors.New("permission denied")},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteXML(&buf, results))
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+ <rdf:Description rdf:about="photos/a.jpg" xmlns:et="http://ns.exiftool.org/1.0/" et:toolkit="metaextractor" xmlns:File="http://ns.exiftool.org/File/1.0/" xmlns:Composite="http://ns.exiftool.org/Composite/1.0/" xmlns:EXIF="http://ns.exiftool.org/EXIF/1.0/" xmlns:XMP="http://ns.exiftool.org/XMP/1.0/" xmlns:MetaExtractor="https://github.com/attilabuti/metaextractor/ns/1.0/">
+  <File:FileName>a.jpg</File:FileName>
+  <File:FileSize>2048</File:FileSize>
+  <File:FileModifyDate>2024:08:03 14:02:11+00:00</File:FileModifyDate>
+  <Composite:ThumbnailImage rdf:datatype="http://www.w3.org/2001/XMLSchema#base64Binary">AAEC</Composite:ThumbnailImage>
+  <EXIF:Make>Canon &amp; Co</EXIF:Make>
+  <File:MIMEType>image/jpeg</File:MIMEType>
+  <XMP:Subject>
+   <rdf:Bag>
+    <rdf:li>beach</rdf:li>
+    <rdf:li>sea</rdf:li>
+   </rdf:Bag>
+  </XMP:Subject>
+  <MetaExtractor:hashes rdf:parseType="Resource">
+   <MetaExtractor:sha256>9f2c</MetaExtractor:sha256>
+  </MetaExtractor:hashes>
+  <MetaExtractor:kind>image</MetaExtractor:kind>
+ </rdf:Description>
+ <rdf:Description rdf:about="photos/b.raw" xmlns:et="http://ns.exiftool.org/1.0/" et:toolkit="metaextractor" xmlns:ExifTool="http://ns.exiftool.org/ExifTool/1.0/">
+  <ExifTool:Error>permission denied</ExifTool:Error>
+ </rdf:Description>
+</rdf:RDF>`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteXML(&buf, nil))
+	assert.Equal(t, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\"></rdf:RDF>", buf.String())
+}
+
+func TestMetadata_MarshalXML(t *testing.T) {
+	metadata := Metadata{
+		Name: "report.pdf",
+		Size: 10,
+		Exif: ExifMetadata{
+			"PDF":      map[string]interface{}{"Author": "Jane", "Pages": 3.0},
+			"Keywords": "q3",
+			"Bad Tag?": "x",
+		},
+		BestCreatedAt: &BestCreatedAt{Time: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Source: "ModTime"},
+	}
+
+	data, err := xml.Marshal(metadata)
+	require.NoError(t, err)
+
+	out := string(data)
+	assert.True(t, strings.HasPrefix(out, `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description rdf:about="report.pdf"`), out)
+	assert.Contains(t, out, `xmlns:PDF="http://ns.exiftool.org/PDF/1.0/"`)
+	assert.Contains(t, out, `<PDF:Author>Jane</PDF:Author><PDF:Pages>3</PDF:Pages>`)
+	assert.Contains(t, out, `<et:Bad_Tag_>x</et:Bad_Tag_><et:Keywords>q3</et:Keywords>`)
+	assert.Contains(t, out, `<MetaExtractor:best_created_at rdf:parseType="Resource"><MetaExtractor:source>ModTime</MetaExtractor:source><MetaExtractor:time>2024-01-02T00:00:00Z</MetaExtractor:time></MetaExtractor:best_created_at>`)
+
+	// The output is well-formed.
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestXMLName(t *testing.T) {
+	assert.Equal(t, "DateTimeOriginal", xmlName("DateTimeOriginal"))
+	assert.Equal(t, "XMP-dc", xmlName("XMP-dc"))
+	assert.Equal(t, "_3DModel", xmlName("3DModel"))
+	assert.Equal(t, "a_b", xmlName("a b"))
+	assert.Equal(t, "_", xmlName(""))
+}
@@ -0,0 +1,129 @@
+package metaextractor
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// Primes of the xxHash64 algorithm.
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxHash64 is the 64-bit xxHash with seed 0, a fast non-cryptographic hash
+// suited to deduplication keys. The digest is big-endian, as printed by the
+// reference xxhsum tool.
+type xxHash64 struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int
+}
+
+// newXXHash64 returns a new xxHash64 hash.
+func newXXHash64() hash.Hash {
+	h := &xxHash64{}
+	h.Reset()
+	return h
+}
+
+func (h *xxHash64) Reset() {
+	// The lanes wrap around, which constant expressions do not allow.
+	p1, p2 := xxPrime1, xxPrime2
+	h.v = [4]uint64{p1 + p2, p2, 0, -p1}
+	h.total = 0
+	h.n = 0
+}
+
+func (h *xxHash64) Size() int { return 8 }
+
+func (h *xxHash64) BlockSize() int { return 32 }
+
+func (h *xxHash64) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+
+	if h.n+len(p) < 32 {
+		h.n += copy(h.mem[h.n:], p)
+		return n, nil
+	}
+
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], p)
+		h.stripes(h.mem[:])
+		p = p[c:]
+		h.n = 0
+	}
+
+	if len(p) >= 32 {
+		full := len(p) &^ 31
+		h.stripes(p[:full])
+		p = p[full:]
+	}
+
+	h.n = copy(h.mem[:], p)
+
+	return n, nil
+}
+
+// stripes consumes 32-byte stripes.
+func (h *xxHash64) stripes(p []byte) {
+	for ; len(p) >= 32; p = p[32:] {
+		for i := range h.v {
+			h.v[i] = xxRound(h.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+		}
+	}
+}
+
+func (h *xxHash64) Sum(b []byte) []byte {
+	var sum uint64
+	if h.total >= 32 {
+		sum = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			sum = xxMergeRound(sum, v)
+		}
+	} else {
+		sum = xxPrime5
+	}
+	sum += h.total
+
+	p := h.mem[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		sum ^= xxRound(0, binary.LittleEndian.Uint64(p))
+		sum = bits.RotateLeft64(sum, 27)*xxPrime1 + xxPrime4
+	}
+	if len(p) >= 4 {
+		sum ^= uint64(binary.LittleEndian.Uint32(p)) * xxPrime1
+		sum = bits.RotateLeft64(sum, 23)*xxPrime2 + xxPrime3
+		p = p[4:]
+	}
+	for _, c := range p {
+		sum ^= uint64(c) * xxPrime5
+		sum = bits.RotateLeft64(sum, 11) * xxPrime1
+	}
+
+	sum ^= sum >> 33
+	sum *= xxPrime2
+	sum ^= sum >> 29
+	sum *= xxPrime3
+	sum ^= sum >> 32
+
+	return binary.BigEndian.AppendUint64(b, sum)
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, v uint64) uint64 {
+	acc ^= xxRound(0, v)
+	return acc*xxPrime1 + xxPrime4
+}
@@ -0,0 +1,55 @@
+package metaextractor
+
+import (
+	"encoding/hex"
+	"hash"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hashString returns the hexadecimal digest of s.
+func hashString(h hash.Hash, s string) string {
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestXXHash64(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{"", "ef46db3751d8e999"},
+		{"a", "d24ec4f1a98c6e5b"},
+		{"abc", "44bc2cf5ad770999"},
+		{"Nobody inspects the spammish repetition", "fbcea83c8a378bf1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.want, hashString(newXXHash64(), tc.input))
+
+			// Byte-wise writes cross the stripe boundaries.
+			h := newXXHash64()
+			for i := 0; i < len(tc.input); i++ {
+				h.Write([]byte{tc.input[i]})
+			}
+			assert.Equal(t, tc.want, hex.EncodeToString(h.Sum(nil)))
+		})
+	}
+
+	t.Run("Long", func(t *testing.T) {
+		input := blake3Input(1000)
+
+		h := newXXHash64()
+		h.Write(input)
+		want := h.Sum(nil)
+
+		h.Reset()
+		for off := 0; off < len(input); off += 13 {
+			h.Write(input[off:min(off+13, len(input))])
+		}
+		assert.Equal(t, want, h.Sum(nil))
+		assert.Equal(t, 8, h.Size())
+	})
+}
@@ -0,0 +1,155 @@
+package metaextractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalYAML encodes the metadata as a YAML mapping with the keys and
+// values of its JSON encoding (see Metadata.MarshalJSON), e.g. for sidecar
+// files: the fields are in declaration order, the keys of Exif and the other
+// maps in increasing order, and times in RFC 3339 format.
+func (m Metadata) MarshalYAML() (interface{}, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return yamlNode(data)
+}
+
+// UnmarshalYAML decodes metadata encoded by MarshalYAML.
+func (m *Metadata) UnmarshalYAML(value *yaml.Node) error {
+	var v interface{}
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, m)
+}
+
+// YAMLWriter writes results as a stream of YAML documents, one per result,
+// with the path and either the metadata (see Metadata.MarshalYAML) or the
+// error of the result.
+type YAMLWriter struct {
+	e *yaml.Encoder
+}
+
+// NewYAMLWriter returns a YAMLWriter writing to w.
+func NewYAMLWriter(w io.Writer) *YAMLWriter {
+	e := yaml.NewEncoder(w)
+	e.SetIndent(2)
+
+	return &YAMLWriter{e: e}
+}
+
+// Write writes the document of the result.
+func (yw *YAMLWriter) Write(r Result) error {
+	doc := struct {
+		Path     string    `yaml:"path"`
+		Metadata *Metadata `yaml:"metadata,omitempty"`
+		Error    string    `yaml:"error,omitempty"`
+	}{Path: r.Path}
+
+	if r.Err != nil {
+		doc.Error = r.Err.Error()
+	} else {
+		doc.Metadata = &r.Metadata
+	}
+
+	return yw.e.Encode(doc)
+}
+
+// Close flushes the stream to the underlying writer. It does not close the
+// underlying writer.
+func (yw *YAMLWriter) Close() error {
+	return yw.e.Close()
+}
+
+// WriteYAML writes the results to w as a stream of YAML documents (see
+// YAMLWriter).
+func WriteYAML(w io.Writer, results []Result) error {
+	yw := NewYAMLWriter(w)
+	for _, r := range results {
+		if err := yw.Write(r); err != nil {
+			return err
+		}
+	}
+
+	return yw.Close()
+}
+
+// yamlNode returns the YAML node of the JSON value, keeping the order of the
+// keys of its objects.
+func yamlNode(data []byte) (*yaml.Node, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	return decodeYAMLNode(d)
+}
+
+func decodeYAMLNode(d *json.Decoder) (*yaml.Node, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(tok)}, nil
+
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(tok.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: tok.String()}, nil
+
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: tok}, nil
+
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if tok == '{' {
+			node.Kind, node.Tag = yaml.MappingNode, "!!map"
+		}
+
+		for d.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+
+			value, err := decodeYAMLNode(d)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+
+		// The closing delimiter.
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
@@ -0,0 +1,71 @@
+package metaextractor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMetadata_MarshalYAML(t *testing.T) {
+	metadata := Metadata{
+		Name:     "a.jpg",
+		Size:     2048,
+		Kind:     KindImage,
+		MimeType: "image/jpeg",
+		Time:     FileTime{ModTime: time.Date(2024, 8, 3, 14, 2, 11, 0, time.UTC)},
+		Exif: ExifMetadata{
+			"Model":    "EOS R6",
+			"ISO":      100.0,
+			"Aperture": 2.8,
+			"Serial":   "0123",
+			"Keywords": []interface{}{"beach", "sea"},
+		},
+	}
+
+	data, err := yaml.Marshal(metadata)
+	require.NoError(t, err)
+
+	assert.Equal(t, `name: a.jpg
+size: 2048
+kind: image
+mime_type: image/jpeg
+exif:
+    Aperture: 2.8
+    ISO: 100
+    Keywords:
+        - beach
+        - sea
+    Model: EOS R6
+    Serial: "0123"
+time:
+    mod_time: "2024-08-03T14:02:11Z"
+`, string(data))
+
+	var decoded Metadata
+	require.NoError(t, yaml.Unmarshal(data, &decoded))
+	assert.Equal(t, metadata, decoded)
+}
+
+func TestWriteYAML(t *testing.T) {
+	results := []Result{
+		{Path: "photos/a.jpg", Metadata: Metadata{Name: "a.jpg", Size: 10}},
+		{Path: "photos/b.raw", Err: errors.New("permission denied")},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteYAML(&buf, results))
+
+	assert.Equal(t, `path: photos/a.jpg
+metadata:
+  name: a.jpg
+  size: 10
+---
+path: photos/b.raw
+error: permission denied
+`, buf.String())
+}
@@ -0,0 +1,420 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// maxZipEntries limits the number of entries reported in ZipArchive.Entries.
+const maxZipEntries = 1000
+
+// ZipArchive contains the metadata stored in the central directory of a ZIP
+// archive (or a ZIP-based format, e.g., DOCX, JAR, APK or EPUB), which
+// hints at the tools and systems that created it.
+type ZipArchive struct {
+	// Comment is the archive comment.
+	Comment string `json:"comment,omitempty"`
+
+	// EntryCount is the number of entries in the archive.
+	EntryCount int `json:"entry_count"`
+
+	// UncompressedSize is the total uncompressed size of the entries in
+	// bytes.
+	UncompressedSize uint64 `json:"uncompressed_size,omitempty"`
+
+	// Zip64 indicates that the archive has a Zip64 end of central directory
+	// record, as written for archives larger than 4 GiB or with more than
+	// 65535 entries.
+	Zip64 bool `json:"zip64,omitempty"`
+
+	// Systems are the distinct operating systems the entries were created
+	// on (e.g., "MS-DOS", "Unix"), in order of appearance.
+	Systems []string `json:"systems,omitempty"`
+
+	// Methods are the distinct compression methods of the entries (e.g.,
+	// "deflate", "store"), in order of appearance.
+	Methods []string `json:"methods,omitempty"`
+
+	// Entries contains the metadata of the first maxZipEntries (1000)
+	// entries.
+	Entries []ZipEntry `json:"entries,omitempty"`
+}
+
+// ZipEntry contains the metadata of an entry of a ZIP archive.
+type ZipEntry struct {
+	// Name is the name of the entry.
+	Name string `json:"name"`
+
+	// Comment is the entry comment.
+	Comment string `json:"comment,omitempty"`
+
+	// System is the operating system the entry was created on (e.g.,
+	// "Unix", "Windows NTFS").
+	System string `json:"system,omitempty"`
+
+	// Version is the version of the ZIP specification supported by the
+	// creating tool (e.g., "2.0", "6.3").
+	Version string `json:"version,omitempty"`
+
+	// Method is the compression method (e.g., "deflate").
+	Method string `json:"method,omitempty"`
+
+	// Size and CompressedSize are the uncompressed and compressed sizes of
+	// the entry in bytes.
+	Size           uint64 `json:"size,omitempty"`
+	CompressedSize uint64 `json:"compressed_size,omitempty"`
+
+	// Zip64 indicates that the entry has a Zip64 extra field, as written
+	// for entries larger than 4 GiB or stored beyond the first 4 GiB of the
+	// archive.
+	Zip64 bool `json:"zip64,omitempty"`
+
+	// Encrypted indicates whether the entry is encrypted.
+	Encrypted bool `json:"encrypted,omitempty"`
+
+	// NonUTF8 indicates that the name and comment are not flagged as UTF-8
+	// and are likely in a legacy code page.
+	NonUTF8 bool `json:"non_utf8,omitempty"`
+
+	// Modified is the modification time, read from the extended timestamp
+	// or NTFS extra field if present, otherwise from the MS-DOS date and
+	// time, which have no time zone.
+	Modified time.Time `json:"modified,omitempty"`
+
+	// AccessTime and CreateTime are read from the extended timestamp or
+	// NTFS extra field. They are zero if not recorded.
+	AccessTime time.Time `json:"access_time,omitempty"`
+	CreateTime time.Time `json:"create_time,omitempty"`
+
+	// ExtraFields are the names of the extra fields of the entry (e.g.,
+	// "extended-timestamp", "ntfs"), or their hexadecimal IDs if unknown.
+	ExtraFields []string `json:"extra_fields,omitempty"`
+}
+
+// zipSystems maps the upper byte of the "version made by" field to the
+// name of the operating system (APPNOTE 4.4.2).
+var zipSystems = map[byte]string{
+	0:  "MS-DOS",
+	1:  "Amiga",
+	2:  "OpenVMS",
+	3:  "Unix",
+	4:  "VM/CMS",
+	5:  "Atari ST",
+	6:  "OS/2 HPFS",
+	7:  "Macintosh",
+	8:  "Z-System",
+	9:  "CP/M",
+	10: "Windows NTFS",
+	11: "MVS",
+	12: "VSE",
+	13: "Acorn RISC OS",
+	14: "VFAT",
+	15: "alternate MVS",
+	16: "BeOS",
+	17: "Tandem",
+	18: "OS/400",
+	19: "OS X",
+}
+
+// zipMethods maps the compression methods to their names (APPNOTE 4.4.5).
+var zipMethods = map[uint16]string{
+	0:  "store",
+	1:  "shrink",
+	2:  "reduce",
+	3:  "reduce",
+	4:  "reduce",
+	5:  "reduce",
+	6:  "implode",
+	8:  "deflate",
+	9:  "deflate64",
+	10: "pkware-implode",
+	12: "bzip2",
+	14: "lzma",
+	18: "terse",
+	19: "lz77",
+	93: "zstd",
+	95: "xz",
+	96: "jpeg",
+	97: "wavpack",
+	98: "ppmd",
+	99: "aes",
+}
+
+// Extra field IDs.
+const (
+	zipExtraZip64     = 0x0001
+	zipExtraNTFS      = 0x000a
+	zipExtraTimestamp = 0x5455
+	zipExtraUnixOld   = 0x5855
+	zipExtraUnicode   = 0x7075
+	zipExtraUnix      = 0x7875
+	zipExtraJar       = 0xcafe
+	zipExtraAndroid   = 0xd935
+)
+
+// zipExtraNames maps the known extra field IDs to their names.
+var zipExtraNames = map[uint16]string{
+	zipExtraZip64:     "zip64",
+	0x0007:            "av-info",
+	0x0009:            "os2",
+	zipExtraNTFS:      "ntfs",
+	0x000d:            "unix-pkware",
+	0x0017:            "strong-encryption",
+	0x07c8:            "macintosh",
+	0x2605:            "zipit-macintosh",
+	0x334d:            "info-zip-macintosh",
+	0x4341:            "acorn",
+	0x4453:            "windows-acl",
+	0x4704:            "vm-cms",
+	0x470f:            "mvs",
+	zipExtraTimestamp: "extended-timestamp",
+	zipExtraUnixOld:   "info-zip-unix-old",
+	0x6375:            "unicode-comment",
+	zipExtraUnicode:   "unicode-path",
+	0x756e:            "asi-unix",
+	zipExtraUnix:      "info-zip-unix",
+	0x9901:            "aes",
+	zipExtraJar:       "jar",
+	zipExtraAndroid:   "android-alignment",
+}
+
+// ntfsEpoch is the difference between the NTFS epoch (1601-01-01) and the
+// Unix epoch in 100-nanosecond intervals.
+const ntfsEpoch = 116444736000000000
+
+// readZipArchive reads the central directory of a ZIP archive. Other files
+// have no ZIP metadata.
+func readZipArchive(filePath string) (*ZipArchive, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !bytes.Equal(head, zipMagic) && !bytes.Equal(head, zipEmpty) {
+		return nil, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("error reading ZIP archive: %w", err)
+	}
+
+	zip64, err := hasZip64End(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &ZipArchive{
+		Comment:    zr.Comment,
+		EntryCount: len(zr.File),
+		Zip64:      zip64,
+	}
+
+	for i, zf := range zr.File {
+		entry := zipEntry(&zf.FileHeader)
+		archive.UncompressedSize += entry.Size
+
+		if !slices.Contains(archive.Systems, entry.System) {
+			archive.Systems = append(archive.Systems, entry.System)
+		}
+		if !slices.Contains(archive.Methods, entry.Method) {
+			archive.Methods = append(archive.Methods, entry.Method)
+		}
+
+		if i < maxZipEntries {
+			archive.Entries = append(archive.Entries, entry)
+		}
+	}
+
+	return archive, nil
+}
+
+// zipEntry returns the metadata of the entry described by the header.
+func zipEntry(fh *zip.FileHeader) ZipEntry {
+	entry := ZipEntry{
+		Name:           fh.Name,
+		Comment:        fh.Comment,
+		System:         zipSystem(byte(fh.CreatorVersion >> 8)),
+		Version:        fmt.Sprintf("%d.%d", byte(fh.CreatorVersion)/10, byte(fh.CreatorVersion)%10),
+		Method:         zipMethod(fh.Method),
+		Size:           fh.UncompressedSize64,
+		CompressedSize: fh.CompressedSize64,
+		Encrypted:      fh.Flags&0x1 != 0,
+		NonUTF8:        fh.NonUTF8,
+		Modified:       fh.Modified,
+	}
+
+	forEachZipExtra(fh.Extra, func(id uint16, data []byte) {
+		if name, ok := zipExtraNames[id]; ok {
+			entry.ExtraFields = append(entry.ExtraFields, name)
+		} else {
+			entry.ExtraFields = append(entry.ExtraFields, fmt.Sprintf("0x%04x", id))
+		}
+
+		switch id {
+		case zipExtraZip64:
+			entry.Zip64 = true
+		case zipExtraTimestamp:
+			parseZipTimestamp(data, &entry)
+		case zipExtraNTFS:
+			parseZipNTFS(data, &entry)
+		}
+	})
+
+	return entry
+}
+
+// Signatures of the end of central directory record and of the Zip64 end of
+// central directory locator, which precedes it in Zip64 archives.
+var (
+	zipEndSignature       = []byte("PK\x05\x06")
+	zip64LocatorSignature = []byte("PK\x06\x07")
+)
+
+// Lengths of the end of central directory record (without its comment) and
+// of the Zip64 locator, and the maximum length of the archive comment.
+const (
+	zipEndLen        = 22
+	zip64LocatorLen  = 20
+	zipMaxCommentLen = 0xffff
+)
+
+// hasZip64End reports whether the archive of the given size has a Zip64 end
+// of central directory locator before its end of central directory record.
+func hasZip64End(r io.ReaderAt, size int64) (bool, error) {
+	n := min(size, int64(zip64LocatorLen+zipEndLen+zipMaxCommentLen))
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, size-n); err != nil && err != io.EOF {
+		return false, err
+	}
+
+	// The record is the last one whose comment ends the file; the comment
+	// itself may contain the signature.
+	for i := len(buf) - zipEndLen; i >= 0; i-- {
+		if !bytes.Equal(buf[i:i+4], zipEndSignature) {
+			continue
+		}
+		if commentLen := int(binary.LittleEndian.Uint16(buf[i+20:])); i+zipEndLen+commentLen != len(buf) {
+			continue
+		}
+
+		return i >= zip64LocatorLen && bytes.Equal(buf[i-zip64LocatorLen:i-zip64LocatorLen+4], zip64LocatorSignature), nil
+	}
+
+	return false, nil
+}
+
+// zipSystem returns the name of the operating system with the given ID.
+func zipSystem(id byte) string {
+	if name, ok := zipSystems[id]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("system %d", id)
+}
+
+// zipMethod returns the name of the compression method.
+func zipMethod(method uint16) string {
+	if name, ok := zipMethods[method]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("method %d", method)
+}
+
+// forEachZipExtra calls fn for each field of the extra data. Truncated
+// fields are ignored.
+func forEachZipExtra(extra []byte, fn func(id uint16, data []byte)) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			return
+		}
+
+		fn(id, extra[:size])
+		extra = extra[size:]
+	}
+}
+
+// parseZipTimestamp reads the times of the extended timestamp field: a flag
+// byte followed by the Unix modification, access and creation times that
+// are flagged as present. In the central directory, usually only the
+// modification time is stored.
+func parseZipTimestamp(data []byte, entry *ZipEntry) {
+	if len(data) < 1 {
+		return
+	}
+
+	flags := data[0]
+	data = data[1:]
+
+	for bit, t := range []*time.Time{&entry.Modified, &entry.AccessTime, &entry.CreateTime} {
+		if flags&(1<<bit) == 0 {
+			continue
+		}
+		if len(data) < 4 {
+			return
+		}
+
+		*t = time.Unix(int64(int32(binary.LittleEndian.Uint32(data))), 0).UTC()
+		data = data[4:]
+	}
+}
+
+// parseZipNTFS reads the times of the NTFS extra field: a reserved value
+// followed by attributes, of which attribute 1 holds the modification,
+// access and creation times as FILETIME values.
+func parseZipNTFS(data []byte, entry *ZipEntry) {
+	if len(data) < 4 {
+		return
+	}
+	data = data[4:]
+
+	for len(data) >= 4 {
+		tag := binary.LittleEndian.Uint16(data)
+		size := int(binary.LittleEndian.Uint16(data[2:]))
+		data = data[4:]
+		if size > len(data) {
+			return
+		}
+
+		if tag == 1 && size >= 24 {
+			entry.Modified = ntfsTime(binary.LittleEndian.Uint64(data))
+			entry.AccessTime = ntfsTime(binary.LittleEndian.Uint64(data[8:]))
+			entry.CreateTime = ntfsTime(binary.LittleEndian.Uint64(data[16:]))
+		}
+		data = data[size:]
+	}
+}
+
+// ntfsTime converts a FILETIME value to a time in UTC. Zero values are
+// returned as the zero time.
+func ntfsTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, (int64(ft)-ntfsEpoch)*100).UTC()
+}
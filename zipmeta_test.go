@@ -0,0 +1,213 @@
+package metaextractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ntfsExtra returns an NTFS extra field with the given times.
+func ntfsExtra(mtime, atime, ctime time.Time) []byte {
+	filetime := func(t time.Time) uint64 {
+		return uint64(t.UnixNano()/100 + ntfsEpoch)
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint16{zipExtraNTFS, 32})
+	b.Write(make([]byte, 4)) // reserved
+	binary.Write(&b, binary.LittleEndian, []uint16{1, 24})
+	binary.Write(&b, binary.LittleEndian, []uint64{filetime(mtime), filetime(atime), filetime(ctime)})
+	return b.Bytes()
+}
+
+func TestReadZipArchive(t *testing.T) {
+	dir := t.TempDir()
+	modified := time.Date(2023, 5, 1, 12, 30, 0, 0, time.UTC)
+	accessed := time.Date(2023, 6, 2, 8, 0, 0, 0, time.UTC)
+	created := time.Date(2022, 1, 3, 9, 15, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	require.NoError(t, zw.SetComment("built by release.sh"))
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:           "bin/tool",
+		Comment:        "the tool",
+		Method:         zip.Deflate,
+		CreatorVersion: 3<<8 | 30,
+		Modified:       modified,
+	})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("tool"))
+	require.NoError(t, err)
+
+	w, err = zw.CreateHeader(&zip.FileHeader{
+		Name:           "readme.txt",
+		Method:         zip.Store,
+		CreatorVersion: 10 << 8,
+		Extra:          append(ntfsExtra(modified, accessed, created), 0x34, 0x12, 0, 0),
+	})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("readme"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	archivePath := filepath.Join(dir, "release.zip")
+	require.NoError(t, os.WriteFile(archivePath, buf.Bytes(), 0o644))
+
+	t.Run("Archive", func(t *testing.T) {
+		archive, err := readZipArchive(archivePath)
+		require.NoError(t, err)
+		require.NotNil(t, archive)
+
+		assert.Equal(t, "built by release.sh", archive.Comment)
+		assert.Equal(t, 2, archive.EntryCount)
+		assert.Equal(t, []string{"Unix", "Windows NTFS"}, archive.Systems)
+		assert.Equal(t, []string{"deflate", "store"}, archive.Methods)
+		assert.Equal(t, uint64(10), archive.UncompressedSize)
+		assert.False(t, archive.Zip64)
+		require.Len(t, archive.Entries, 2)
+
+		tool := archive.Entries[0]
+		assert.Equal(t, "bin/tool", tool.Name)
+		assert.Equal(t, "the tool", tool.Comment)
+		assert.Equal(t, "2.0", tool.Version, "the writer records the version it supports")
+		assert.Equal(t, []string{"extended-timestamp"}, tool.ExtraFields)
+		assert.True(t, modified.Equal(tool.Modified))
+		assert.True(t, tool.AccessTime.IsZero())
+
+		readme := archive.Entries[1]
+		assert.Equal(t, "Windows NTFS", readme.System)
+		assert.Equal(t, []string{"ntfs", "0x1234"}, readme.ExtraFields)
+		assert.True(t, modified.Equal(readme.Modified))
+		assert.True(t, accessed.Equal(readme.AccessTime))
+		assert.True(t, created.Equal(readme.CreateTime))
+		assert.False(t, readme.Encrypted)
+		assert.Equal(t, uint64(6), readme.Size)
+		assert.Equal(t, uint64(6), readme.CompressedSize)
+		assert.False(t, readme.Zip64)
+	})
+
+	t.Run("Not a ZIP", func(t *testing.T) {
+		archive, err := readZipArchive(filepath.Join("testdata", "sample.mp3"))
+		require.NoError(t, err)
+		assert.Nil(t, archive)
+
+		empty := filepath.Join(dir, "empty")
+		require.NoError(t, os.WriteFile(empty, nil, 0o644))
+		archive, err = readZipArchive(empty)
+		require.NoError(t, err)
+		assert.Nil(t, archive)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		truncated := filepath.Join(dir, "truncated.zip")
+		require.NoError(t, os.WriteFile(truncated, buf.Bytes()[:buf.Len()/2], 0o644))
+
+		_, err := readZipArchive(truncated)
+		assert.Error(t, err)
+	})
+
+	t.Run("Extract", func(t *testing.T) {
+		metadata, err := NewMetaExtractor(Options{PureGo: true, ZipMetadata: true}).Extract(archivePath)
+		require.NoError(t, err)
+		require.NotNil(t, metadata.Zip)
+		assert.Equal(t, 2, metadata.Zip.EntryCount)
+
+		metadata, err = NewMetaExtractor(Options{PureGo: true}).Extract(archivePath)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.Zip)
+	})
+}
+
+func TestParseZipTimestamp(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		expect [3]int64
+	}{
+		{"All", []byte{7, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0}, [3]int64{1, 2, 3}},
+		{"Access Only", []byte{2, 5, 0, 0, 0}, [3]int64{0, 5, 0}},
+		{"Truncated", []byte{3, 1, 0, 0, 0, 2}, [3]int64{1, 0, 0}},
+		{"Empty", nil, [3]int64{0, 0, 0}},
+	}
+
+	unix := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+		return t.Unix()
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entry ZipEntry
+			parseZipTimestamp(tt.data, &entry)
+			assert.Equal(t, tt.expect, [3]int64{unix(entry.Modified), unix(entry.AccessTime), unix(entry.CreateTime)})
+		})
+	}
+}
+
+func TestReadZipArchive_Zip64(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Large entry", func(t *testing.T) {
+		// The central directory is written from the header, so an entry can
+		// claim a size above 4 GiB without its content.
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		_, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               "disk.img",
+			Method:             zip.Store,
+			CompressedSize64:   5 << 30,
+			UncompressedSize64: 5 << 30,
+		})
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		archivePath := filepath.Join(dir, "large.zip")
+		require.NoError(t, os.WriteFile(archivePath, buf.Bytes(), 0o644))
+
+		archive, err := readZipArchive(archivePath)
+		require.NoError(t, err)
+		require.Len(t, archive.Entries, 1)
+
+		entry := archive.Entries[0]
+		assert.Equal(t, uint64(5<<30), entry.Size)
+		assert.Equal(t, uint64(5<<30), entry.CompressedSize)
+		assert.True(t, entry.Zip64)
+		assert.Contains(t, entry.ExtraFields, "zip64")
+		assert.Equal(t, uint64(5<<30), archive.UncompressedSize)
+	})
+
+	t.Run("Many entries", func(t *testing.T) {
+		// More than 65535 entries require a Zip64 end of central directory
+		// record.
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for i := 0; i <= 0xffff; i++ {
+			_, err := zw.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("%05d", i), Method: zip.Store})
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.SetComment("PK\x05\x06 in the comment"))
+		require.NoError(t, zw.Close())
+
+		archivePath := filepath.Join(dir, "many.zip")
+		require.NoError(t, os.WriteFile(archivePath, buf.Bytes(), 0o644))
+
+		archive, err := readZipArchive(archivePath)
+		require.NoError(t, err)
+		assert.Equal(t, 0x10000, archive.EntryCount)
+		assert.Len(t, archive.Entries, maxZipEntries)
+		assert.True(t, archive.Zip64)
+		assert.False(t, archive.Entries[0].Zip64)
+	})
+}